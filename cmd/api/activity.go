@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/validator"
+)
+
+// listUpdate is the payload of events.TypeListUpdated.
+type listUpdate struct {
+	List *data.List     `json:"list"`
+	Item *data.ListItem `json:"item"`
+}
+
+// activityFromEvent turns an event from the bus into an activity feed entry,
+// or returns nil for events which do not belong in the feeds.
+func activityFromEvent(event events.Event) *data.Activity {
+	switch event.Type {
+	case events.TypeMovieCreated, events.TypeMoviePublished:
+		movie, ok := event.Data.(*data.Movie)
+		if !ok {
+			return nil
+		}
+		return &data.Activity{
+			Kind:   data.ActivityMoviePublished,
+			OrgID:  movie.OrgID,
+			Genres: movie.Genres,
+			Data:   map[string]any{"movie_id": movie.ID, "title": movie.Title, "year": movie.Year, "genres": movie.Genres},
+		}
+	case events.TypeCommentReply:
+		comment, ok := event.Data.(*data.Comment)
+		if !ok {
+			return nil
+		}
+		return &data.Activity{
+			Kind:        data.ActivityCommentReply,
+			ActorID:     comment.UserID,
+			RecipientID: event.UserID,
+			Data:        map[string]any{"comment_id": comment.ID, "movie_id": comment.MovieID, "parent_id": comment.ParentID, "body": comment.Body},
+		}
+	case events.TypeListUpdated:
+		update, ok := event.Data.(listUpdate)
+		if !ok {
+			return nil
+		}
+		return &data.Activity{
+			Kind:    data.ActivityListUpdated,
+			OrgID:   update.List.OrgID,
+			ActorID: update.List.UserID,
+			Data:    map[string]any{"list_id": update.List.ID, "name": update.List.Name, "movie_id": update.Item.MovieID, "title": update.Item.Title},
+		}
+	}
+
+	return nil
+}
+
+// recordActivities stores the events of sub which belong in the activity
+// feeds until the subscription is closed. The bus drops events for slow
+// subscribers, so the feeds are best effort.
+func (app *application) recordActivities(sub *events.Subscription) {
+	for event := range sub.C {
+		activity := activityFromEvent(event)
+		if activity == nil {
+			continue
+		}
+
+		err := app.models.Activities.Insert(activity)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"event": event.Type})
+		}
+	}
+}
+
+// showFeedHandler pages through the user's activity feed from the newest
+// entry back. The next_cursor in the metadata fetches the following page
+// and is left out on the last one.
+func (app *application) showFeedHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	q := data.FeedQuery{UserID: user.ID, OrgID: user.OrgID}
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	q.Kinds = qs.CSV("kinds", nil)
+	q.Before = qs.Int64("cursor", 0)
+	q.Limit = qs.Int("limit", 20)
+
+	for _, kind := range q.Kinds {
+		v.Check(validator.PermittedValue(kind, data.ActivityKinds...), "kinds", "must only contain movie_published, comment_reply or list_updated")
+	}
+	v.Check(q.Before >= 0, "cursor", "must not be negative")
+	v.Check(q.Limit > 0 && q.Limit <= 100, "limit", "must be between 1 and 100")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	activities, err := app.models.Activities.GetFeed(q)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	metadata := envelope{}
+	if len(activities) == q.Limit {
+		metadata["next_cursor"] = strconv.FormatInt(activities[len(activities)-1].ID, 10)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"activities": activities, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestActivityFeed(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	recorded := make(chan struct{})
+	sub := app.events.SubscribeAll(64)
+	go func() {
+		app.recordActivities(sub)
+		close(recorded)
+	}()
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	viewer := &data.User{Name: "Viewer", Email: "viewer@example.com", Locale: "en", Activated: true}
+	if err := viewer.Password.Set("pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(viewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(viewer.ID, "movies:read"); err != nil {
+		t.Fatal(err)
+	}
+	viewerToken, err := app.models.Tokens.NewAuthentication(viewer.ID, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// List updates only reach the list owner's followers.
+	code, _ := ts.do(t, http.MethodPut, fmt.Sprintf("/v1/users/me/following/%d", editor.ID), viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusDraft, OrgID: 1}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ = ts.do(t, http.MethodPut, fmt.Sprintf("/v1/movies/%d/status", movie.ID), token, `{"status": "published"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	commentsPath := fmt.Sprintf("/v1/movies/%d/comments", movie.ID)
+	code, body := ts.do(t, http.MethodPost, commentsPath, viewerToken.Plaintext, `{"body": "Great film"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	parentID := int64(body["comment"].(map[string]any)["id"].(float64))
+
+	code, _ = ts.do(t, http.MethodPost, commentsPath, token, fmt.Sprintf(`{"body": "Agreed", "parent_id": %d}`, parentID))
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, body = ts.do(t, http.MethodPost, "/v1/lists", token, `{"name": "Favourites", "public": true}`)
+	assert.Equal(t, code, http.StatusCreated)
+	listID := int64(body["list"].(map[string]any)["id"].(float64))
+
+	code, _ = ts.do(t, http.MethodPost, fmt.Sprintf("/v1/lists/%d/items", listID), token, fmt.Sprintf(`{"movie_id": %d}`, movie.ID))
+	assert.Equal(t, code, http.StatusCreated)
+
+	app.events.Close()
+	<-recorded
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/feed?limit=2", viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	activities := body["activities"].([]any)
+	assert.Equal(t, len(activities), 2)
+	assert.Equal(t, fmt.Sprint(activities[0].(map[string]any)["kind"]), data.ActivityListUpdated)
+	assert.Equal(t, fmt.Sprint(activities[1].(map[string]any)["kind"]), data.ActivityCommentReply)
+	cursor := body["metadata"].(map[string]any)["next_cursor"].(string)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/feed?limit=2&cursor="+cursor, viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	activities = body["activities"].([]any)
+	assert.Equal(t, len(activities), 1)
+	assert.Equal(t, fmt.Sprint(activities[0].(map[string]any)["kind"]), data.ActivityMoviePublished)
+	_, ok := body["metadata"].(map[string]any)["next_cursor"]
+	assert.Equal(t, ok, false)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/feed?kinds=comment_reply", viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	activities = body["activities"].([]any)
+	assert.Equal(t, len(activities), 1)
+	assert.Equal(t, fmt.Sprint(activities[0].(map[string]any)["data"].(map[string]any)["body"]), "Agreed")
+
+	// The editor's own activity stays out of their feed.
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/feed", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	activities = body["activities"].([]any)
+	assert.Equal(t, len(activities), 1)
+	assert.Equal(t, fmt.Sprint(activities[0].(map[string]any)["kind"]), data.ActivityMoviePublished)
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/users/me/feed?kinds=unknown", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
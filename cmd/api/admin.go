@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/jsonlog"
+	"greenlight.bcc/internal/validator"
+)
+
+const impersonationTTL = 15 * time.Minute
+
+func (app *application) showLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, r, http.StatusOK, envelope{"level": app.logger.Level().String()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Level string `json:"level"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	level, err := jsonlog.ParseLevel(input.Level)
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonlog.ErrInvalidLevel):
+			v.AddError("level", "must be one of info, error, fatal or off")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	previous := app.logger.Level()
+	app.logger.SetLevel(level)
+
+	app.logger.PrintInfo("log level changed", map[string]string{
+		"from": previous.String(),
+		"to":   level.String(),
+	})
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"level": level.String()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listUsersHandler lists every user, so that sync jobs can fetch the users
+// created in a time range.
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.CreatedRange
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.CreatedAfter = qs.Time("created_after", time.Time{})
+	input.CreatedBefore = qs.Time("created_before", time.Time{})
+	input.Filters.Page = qs.Int("page", 1)
+	input.Filters.PageSize = qs.Int("page_size", 20)
+	input.Filters.Sort = qs.String("sort", "id")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+
+	data.ValidateCreatedRange(v, input.CreatedRange)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.withinQueryCost(w, r, input.Filters, "id", "-id", "created_at", "-created_at") {
+		return
+	}
+
+	users, metadata, err := app.models.Users.GetAll(input.CreatedRange, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"users": users, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) impersonateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+
+	v := validator.New()
+	v.Check(input.Reason != "", "reason", "must be provided")
+	v.Check(len(input.Reason) <= 500, "reason", "must not be more than 500 bytes long")
+	v.Check(id != admin.ID, "id", "must not be your own account")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// An impersonation token must not be used to start another one.
+	if admin.ImpersonatorID != 0 {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	user, err := app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, code := range permissions {
+		if strings.HasPrefix(code, "admin:") {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	orgID, err := app.models.Organizations.GetDefaultForUser(user.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.NewImpersonation(user.ID, admin.ID, orgID, impersonationTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	properties := app.requestProperties(r)
+	properties["target_user_id"] = strconv.FormatInt(user.ID, 10)
+	properties["reason"] = input.Reason
+	properties["expiry"] = token.Expiry.UTC().Format(time.RFC3339)
+	app.logger.PrintInfo("impersonation started", properties)
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"authentication_token": token, "user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/imaging"
+	"greenlight.bcc/internal/storage"
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	// avatarSize is the width and height of stored avatars in pixels.
+	avatarSize = 256
+	// maxAvatarPixels guards against images which are small on the wire
+	// but enormous once decoded.
+	maxAvatarPixels = 40_000_000
+)
+
+var avatarContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// updateAvatarHandler takes the image in the request body, crops and
+// resizes it to a square JPEG and makes it the user's avatar. Avatars are
+// stored under the hash of their contents, so uploading the same picture
+// twice stores it once.
+func (app *application) updateAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !validator.PermittedValue(mediaType, avatarContentTypes...) {
+		app.unsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.avatars.maxBytes)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			err = fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	key, err := app.storeAvatar(r.Context(), body, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	app.generateThumbnails(r.Context(), key)
+
+	user := app.contextGetUser(r)
+	user.AvatarURL = app.storage.URL(key)
+
+	app.saveAvatar(w, r, user)
+}
+
+// storeAvatar crops and resizes the image in body and stores it, returning
+// its key. Images which cannot be used are reported through v.
+func (app *application) storeAvatar(ctx context.Context, body []byte, v *validator.Validator) (string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		v.AddError("avatar", "must be a JPEG, PNG or GIF image")
+	} else {
+		v.Check(config.Width > 0 && config.Height > 0, "avatar", "must not be empty")
+		v.Check(config.Width*config.Height <= maxAvatarPixels, "avatar", "must not have more than 40 megapixels")
+	}
+
+	if !v.Valid() {
+		return "", nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		v.AddError("avatar", "must be a JPEG, PNG or GIF image")
+		return "", nil
+	}
+
+	var avatar bytes.Buffer
+
+	err = jpeg.Encode(&avatar, imaging.Fill(img, avatarSize, avatarSize), &jpeg.Options{Quality: 90})
+	if err != nil {
+		return "", err
+	}
+
+	return storage.PutContent(ctx, app.storage, "avatars", ".jpg", &avatar, "image/jpeg")
+}
+
+// deleteAvatarHandler removes the user's avatar. The image itself is kept,
+// as other users may have uploaded the same one.
+func (app *application) deleteAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	user.AvatarURL = ""
+
+	app.saveAvatar(w, r, user)
+}
+
+func (app *application) saveAvatar(w http.ResponseWriter, r *http.Request, user *data.User) {
+	err := app.models.Users.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadFileHandler stores the request body through a URL signed by the
+// local store, which stands in for S3's pre-signed PUT URLs.
+func (app *application) uploadFileHandler(w http.ResponseWriter, r *http.Request) {
+	local, ok := app.storage.(*storage.Local)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	key := httprouter.ParamsFromContext(r.Context()).ByName("key")[1:]
+
+	err := local.Verify(key, http.MethodPut, r.URL.Query(), time.Now())
+	if err != nil {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	switch {
+	case r.ContentLength < 0:
+		app.errorResponse(w, r, http.StatusLengthRequired, "the Content-Length header must be set")
+		return
+	case r.ContentLength > app.config.storage.maxUploadBytes:
+		app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", app.config.storage.maxUploadBytes))
+		return
+	}
+
+	err = local.Put(r.Context(), key, r.Body, r.ContentLength, r.Header.Get("Content-Type"))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
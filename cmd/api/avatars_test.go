@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestAvatars(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.config.avatars.maxBytes = 1 << 20
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// A wide image, red on the left and blue on the right, which should be
+	// cropped to the middle.
+	src := image.NewRGBA(image.Rect(0, 0, 600, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 600; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 300 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	var upload bytes.Buffer
+	if err := png.Encode(&upload, src); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(contentType string, body []byte) (int, map[string]any) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/v1/users/me/avatar", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", contentType)
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		var decoded map[string]any
+		if err := json.NewDecoder(rs.Body).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		return rs.StatusCode, decoded
+	}
+
+	code, _ := put("text/plain", upload.Bytes())
+	assert.Equal(t, code, http.StatusUnsupportedMediaType)
+
+	code, body := put("image/png", []byte("not an image"))
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "must be a JPEG, PNG or GIF image")
+
+	code, _ = put("image/png", make([]byte, 2<<20))
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, body = put("image/png", upload.Bytes())
+	assert.Equal(t, code, http.StatusOK)
+	avatarURL := fmt.Sprint(body["user"].(map[string]any)["avatar_url"])
+	assert.StringContains(t, avatarURL, "/v1/files/avatars/")
+
+	rs, err := ts.Client().Get(ts.URL + avatarURL[strings.Index(avatarURL, "/v1/files/"):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("Content-Type"), "image/jpeg")
+
+	avatar, err := jpeg.Decode(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, avatar.Bounds(), image.Rect(0, 0, avatarSize, avatarSize))
+	r, _, b, _ := avatar.At(10, avatarSize/2).RGBA()
+	assert.Equal(t, r > b, true)
+	r, _, b, _ = avatar.At(avatarSize-10, avatarSize/2).RGBA()
+	assert.Equal(t, b > r, true)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/profile", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, fmt.Sprint(body["profile"].(map[string]any)["avatar_url"]), avatarURL)
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/files/../main.go", "", "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, body = ts.do(t, http.MethodDelete, "/v1/users/me/avatar", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	_, ok := body["user"].(map[string]any)["avatar_url"]
+	assert.Equal(t, ok, false)
+
+	// The thumbnails are written to the test's storage directory.
+	app.wg.Wait()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestBlockingAndMuting(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	troll := &data.User{Name: "Troll", Email: "troll@example.com", Locale: "en", Activated: true}
+	if err := troll.Password.Set("pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(troll); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(troll.ID, "movies:read"); err != nil {
+		t.Fatal(err)
+	}
+	trollToken, err := app.models.Tokens.NewAuthentication(troll.ID, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusPublished, OrgID: 1}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+	commentsPath := fmt.Sprintf("/v1/movies/%d/comments", movie.ID)
+	blockPath := fmt.Sprintf("/v1/users/me/blocks/%d", troll.ID)
+
+	code, body := ts.do(t, http.MethodPost, commentsPath, token, `{"body": "Lovely songs"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	parentID := int64(body["comment"].(map[string]any)["id"].(float64))
+
+	code, _ = ts.do(t, http.MethodPost, commentsPath, trollToken.Plaintext, `{"body": "Overrated"}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	reply := fmt.Sprintf(`{"body": "No they are not", "parent_id": %d}`, parentID)
+
+	code, _ = ts.do(t, http.MethodPost, commentsPath, trollToken.Plaintext, reply)
+	assert.Equal(t, code, http.StatusCreated)
+	app.wg.Wait()
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/notifications", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["unread_count"].(float64), 1)
+
+	code, _ = ts.do(t, http.MethodPut, "/v1/users/me/blocks/2", token, `{"kind": "mute"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _ = ts.do(t, http.MethodPut, blockPath, token, `{"kind": "ignore"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _ = ts.do(t, http.MethodPut, blockPath, token, `{"kind": "mute"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, body = ts.do(t, http.MethodGet, commentsPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	threads := body["comments"].([]any)
+	assert.Equal(t, len(threads), 1)
+	if _, ok := threads[0].(map[string]any)["replies"]; ok {
+		t.Error("want replies by a muted user to be hidden")
+	}
+
+	code, body = ts.do(t, http.MethodGet, commentsPath, trollToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["comments"].([]any)), 2)
+
+	code, _ = ts.do(t, http.MethodPost, commentsPath, trollToken.Plaintext, reply)
+	assert.Equal(t, code, http.StatusCreated)
+	app.wg.Wait()
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/notifications", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["unread_count"].(float64), 1)
+
+	code, _ = ts.do(t, http.MethodPut, blockPath, token, `{"kind": "block"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, body = ts.do(t, http.MethodPost, commentsPath, trollToken.Plaintext, reply)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "you cannot reply to this comment")
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/blocks", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["blocks"].([]any)), 1)
+
+	code, _ = ts.do(t, http.MethodDelete, blockPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodDelete, blockPath, token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, body = ts.do(t, http.MethodGet, commentsPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["comments"].([]any)), 2)
+}
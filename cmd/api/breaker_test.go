@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/breaker"
+	"greenlight.bcc/internal/mailer"
+)
+
+type failingBackend struct {
+	calls int
+}
+
+func (b *failingBackend) Deliver(ctx context.Context, msg *mailer.Message) error {
+	b.calls++
+	return &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	app := newTestApplication(t)
+
+	db := breaker.New("database", 2, time.Minute)
+	app.breakers = []*breaker.Breaker{db}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/v1/healthcheck")
+	assert.StringContains(t, body, `"circuit_breakers":{"database":"closed"},"status":"available"`)
+
+	for i := 0; i < 2; i++ {
+		assert.NilError(t, db.Allow())
+		db.Record(errors.New("dial tcp: connection refused"))
+	}
+
+	_, _, body = ts.get(t, "/v1/healthcheck")
+	assert.StringContains(t, body, `"circuit_breakers":{"database":"open"},"status":"degraded"`)
+
+	err := db.Allow()
+	if !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("want breaker.ErrOpen; got %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	app.serverErrorResponse(rr, r, fmt.Errorf("get movie: %w", err))
+
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "60")
+}
+
+func TestMailerCircuitBreaker(t *testing.T) {
+	backend := &failingBackend{}
+	m := mailer.New(backend, "test@example.com", time.Second, 0).WithBreaker(breaker.New("mailer", 2, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		err := m.Send("alice@example.com", "en", "user_welcome.tmpl", map[string]any{"userID": 1, "activationToken": "x"})
+		if err == nil {
+			t.Fatal("want an error")
+		}
+	}
+
+	assert.Equal(t, backend.calls, 2)
+}
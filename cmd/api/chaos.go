@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chaosRule is the faults injected into a route's requests. Each rate is
+// the fraction of requests affected. Latency is added before the handler
+// runs; a request then has its connection dropped or, failing that, is
+// answered with a 500.
+type chaosRule struct {
+	latencyRate float64
+	latency     time.Duration
+	errorRate   float64
+	dropRate    float64
+}
+
+// chaosAllRoutes is the key of the rule for routes without one of their own.
+const chaosAllRoutes = "*"
+
+var totalChaosFaults = publishMap("chaos_faults_injected")
+
+// parseChaosRules parses rules separated by semicolons. Each names a route
+// as it is registered, or * for every other route, followed by its faults:
+//
+//	GET /v1/movies/:id latency=0.5:300ms error=0.1; * drop=0.01
+func parseChaosRules(val string) (map[string]chaosRule, error) {
+	rules := make(map[string]chaosRule)
+
+	for _, spec := range strings.Split(val, ";") {
+		fields := strings.Fields(spec)
+		if len(fields) == 0 {
+			continue
+		}
+
+		route := fields[0]
+		if route != chaosAllRoutes {
+			if len(fields) < 2 || !strings.HasPrefix(fields[1], "/") {
+				return nil, fmt.Errorf("chaos rule %q must start with a method and path, or *", strings.TrimSpace(spec))
+			}
+			route = strings.ToUpper(fields[0]) + " " + fields[1]
+			fields = fields[1:]
+		}
+
+		var rule chaosRule
+		for _, fault := range fields[1:] {
+			name, value, _ := strings.Cut(fault, "=")
+
+			var err error
+			switch name {
+			case "latency":
+				rate, delay, ok := strings.Cut(value, ":")
+				if !ok {
+					return nil, fmt.Errorf("chaos fault %q must be latency=rate:duration", fault)
+				}
+				rule.latencyRate, err = parseChaosRate(rate)
+				if err == nil {
+					rule.latency, err = time.ParseDuration(delay)
+				}
+			case "error":
+				rule.errorRate, err = parseChaosRate(value)
+			case "drop":
+				rule.dropRate, err = parseChaosRate(value)
+			default:
+				return nil, fmt.Errorf("unknown chaos fault %q", fault)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("chaos fault %q: %w", fault, err)
+			}
+		}
+
+		rules[route] = rule
+	}
+
+	return rules, nil
+}
+
+func parseChaosRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate %q must be from 0 to 1", s)
+	}
+	return rate, nil
+}
+
+// chaosRule returns the faults configured for a route, if any.
+func (app *application) chaosRule(method, path string) (chaosRule, bool) {
+	rule, ok := app.config.chaos.rules[method+" "+path]
+	if !ok {
+		rule, ok = app.config.chaos.rules[chaosAllRoutes]
+	}
+	return rule, ok
+}
+
+// injectFaults delays, fails or drops a share of a route's requests, so that
+// clients and their retry policies can be tried against failures. Injected
+// faults are marked with the X-Chaos-Fault header where there is a response
+// to carry it, and counted in the metrics.
+func (app *application) injectFaults(route string, rule chaosRule, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rule.latencyRate > 0 && rand.Float64() < rule.latencyRate {
+			totalChaosFaults.Add("latency", 1)
+			w.Header().Add("X-Chaos-Fault", "latency")
+
+			timer := time.NewTimer(rule.latency)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		if rule.dropRate > 0 && rand.Float64() < rule.dropRate {
+			if hj, ok := w.(http.Hijacker); ok {
+				conn, _, err := hj.Hijack()
+				if err == nil {
+					totalChaosFaults.Add("drop", 1)
+					app.logger.PrintInfo("chaos fault injected", map[string]string{"route": route, "fault": "drop"})
+					conn.Close()
+					return
+				}
+			}
+
+			// Connections which cannot be dropped, such as HTTP/2 ones,
+			// fail instead.
+			app.injectError(w, r, route)
+			return
+		}
+
+		if rule.errorRate > 0 && rand.Float64() < rule.errorRate {
+			app.injectError(w, r, route)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) injectError(w http.ResponseWriter, r *http.Request, route string) {
+	totalChaosFaults.Add("error", 1)
+	app.logger.PrintInfo("chaos fault injected", map[string]string{"route": route, "fault": "error"})
+
+	w.Header().Add("X-Chaos-Fault", "error")
+	app.errorResponse(w, r, http.StatusInternalServerError, serverErrorMessage)
+}
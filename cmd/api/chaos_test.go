@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestParseChaosRules(t *testing.T) {
+	rules, err := parseChaosRules("get /v1/movies/:id latency=0.5:300ms error=0.1; * drop=0.01;")
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 2)
+	assert.Equal(t, rules["GET /v1/movies/:id"], chaosRule{latencyRate: 0.5, latency: 300 * time.Millisecond, errorRate: 0.1})
+	assert.Equal(t, rules[chaosAllRoutes], chaosRule{dropRate: 0.01})
+
+	for _, val := range []string{"/v1/movies error=0.1", "* error=2", "* latency=0.5", "* explode=1"} {
+		_, err := parseChaosRules(val)
+		assert.Equal(t, err != nil, true)
+	}
+}
+
+func TestInjectFaults(t *testing.T) {
+	app := newTestApplication(t)
+
+	rules, err := parseChaosRules("GET /v1/healthcheck error=1; GET /v1/readyz latency=1:50ms; * drop=1")
+	assert.NilError(t, err)
+	app.config.chaos.rules = rules
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/v1/healthcheck")
+	assert.Equal(t, code, http.StatusInternalServerError)
+	assert.Equal(t, header.Get("X-Chaos-Fault"), "error")
+
+	start := time.Now()
+	code, header, _ = ts.get(t, "/v1/readyz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("X-Chaos-Fault"), "latency")
+	assert.Equal(t, time.Since(start) >= 50*time.Millisecond, true)
+
+	_, err = ts.Client().Get(ts.URL + "/v1/movies/1")
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"greenlight.bcc/internal/cluster"
+)
+
+// newClusterNode returns this instance's membership of the cluster. Peers
+// are reported stale once they miss three heartbeats.
+func newClusterNode(cfg config, registry cluster.Registry) (*cluster.Node, error) {
+	addr := cfg.cluster.advertiseURL
+	if addr == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		addr = fmt.Sprintf("http://%s:%d", hostname, cfg.port)
+	}
+
+	self := cluster.Instance{
+		Addr:       addr,
+		MetricsURL: addr + "/debug/vars",
+		Version:    version,
+	}
+
+	return cluster.New(registry, self, 3*cfg.cluster.heartbeatInterval), nil
+}
+
+func (app *application) joinCluster() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := app.cluster.Join(ctx)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"task": "join cluster"})
+		return
+	}
+
+	app.logger.PrintInfo("joined cluster", map[string]string{
+		"id":   app.cluster.Self().ID,
+		"addr": app.cluster.Self().Addr,
+	})
+}
+
+func (app *application) heartbeatPeriodically() {
+	ticker := time.NewTicker(app.config.cluster.heartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if app.draining.Load() {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := app.cluster.Heartbeat(ctx)
+		cancel()
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"task": "cluster heartbeat"})
+		}
+	}
+}
+
+func (app *application) leaveCluster() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := app.cluster.Leave(ctx)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"task": "leave cluster"})
+	}
+}
+
+// listClusterHandler lists the instances in the cluster with their health,
+// so operators can see which peers are serving and where their metrics are.
+func (app *application) listClusterHandler(w http.ResponseWriter, r *http.Request) {
+	if app.cluster == nil {
+		err := app.writeJSON(w, r, http.StatusOK, envelope{"instances": []cluster.Peer{}}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	peers, err := app.cluster.Peers(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"instances": peers}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/cluster"
+)
+
+func TestClusterPeers(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(editor.ID, "admin:read"); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	draining := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer draining.Close()
+
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	ctx := context.Background()
+	registry := cluster.NewMemoryRegistry()
+	app.cluster = cluster.New(registry, cluster.Instance{ID: "a", Addr: ts.URL + "/"}, 200*time.Millisecond)
+
+	for _, instance := range []cluster.Instance{
+		{ID: "stale", Addr: ts.URL},
+		{ID: "b", Addr: ts.URL, MetricsURL: ts.URL + "/debug/vars"},
+		{ID: "c", Addr: draining.URL},
+		{ID: "d", Addr: gone.URL},
+	} {
+		if err := registry.Register(ctx, instance); err != nil {
+			t.Fatal(err)
+		}
+		if instance.ID == "stale" {
+			time.Sleep(300 * time.Millisecond)
+		}
+	}
+
+	// The node was never joined, so its heartbeat registers it.
+	if err := app.cluster.Heartbeat(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := ts.do(t, http.MethodGet, "/v1/admin/cluster", token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	statuses := make(map[string]string)
+	for _, instance := range body["instances"].([]any) {
+		instance := instance.(map[string]any)
+		statuses[instance["id"].(string)] = instance["status"].(string)
+		assert.Equal(t, instance["healthy"].(bool), instance["status"] == cluster.StatusUp)
+		assert.Equal(t, instance["self"].(bool), instance["id"] == "a")
+	}
+
+	assert.Equal(t, len(statuses), 5)
+	assert.Equal(t, statuses["a"], cluster.StatusUp)
+	assert.Equal(t, statuses["b"], cluster.StatusUp)
+	assert.Equal(t, statuses["c"], cluster.StatusDraining)
+	assert.Equal(t, statuses["d"], cluster.StatusUnreachable)
+	assert.Equal(t, statuses["stale"], cluster.StatusStale)
+
+	if err := app.cluster.Leave(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	peers, err := app.cluster.Peers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(peers), 4)
+}
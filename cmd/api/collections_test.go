@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestCollections(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	var movieIDs []int64
+	for _, title := range []string{"The Two Towers", "The Fellowship of the Ring", "Willow"} {
+		code, body := ts.do(t, http.MethodPost, "/v1/movies", token, fmt.Sprintf(`{"title": %q, "year": 2001, "runtime": "178 mins"}`, title))
+		assert.Equal(t, code, http.StatusCreated)
+		id := int64(body["movie"].(map[string]any)["id"].(float64))
+		movieIDs = append(movieIDs, id)
+
+		code, _ = ts.do(t, http.MethodPut, fmt.Sprintf("/v1/movies/%d/status", id), token, `{"status": "published"}`)
+		assert.Equal(t, code, http.StatusOK)
+	}
+
+	code, _ := ts.do(t, http.MethodPost, "/v1/collections", token, `{"name": ""}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body := ts.do(t, http.MethodPost, "/v1/collections", token, `{"name": "The Lord of the Rings Trilogy"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	collectionID := int64(body["collection"].(map[string]any)["id"].(float64))
+	collectionPath := fmt.Sprintf("/v1/collections/%d", collectionID)
+
+	code, body = ts.do(t, http.MethodPut, collectionPath+"/movies", token, fmt.Sprintf(`{"movie_ids": [%d, %d]}`, movieIDs[1], movieIDs[0]))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["collection"].(map[string]any)["movie_count"].(float64), float64(2))
+
+	movies := body["movies"].([]any)
+	assert.Equal(t, len(movies), 2)
+	assert.Equal(t, movies[0].(map[string]any)["title"].(string), "The Fellowship of the Ring")
+	assert.Equal(t, movies[1].(map[string]any)["position"].(float64), float64(2))
+
+	for _, ids := range []string{"[1000]", fmt.Sprintf("[%d, %d]", movieIDs[0], movieIDs[0]), "[-1]"} {
+		code, _ = ts.do(t, http.MethodPut, collectionPath+"/movies", token, `{"movie_ids": `+ids+`}`)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// A movie belongs to at most one collection.
+	code, body = ts.do(t, http.MethodPost, "/v1/collections", token, `{"name": "Fantasy"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	otherPath := fmt.Sprintf("/v1/collections/%d", int64(body["collection"].(map[string]any)["id"].(float64)))
+
+	code, _ = ts.do(t, http.MethodPut, otherPath+"/movies", token, fmt.Sprintf(`{"movie_ids": [%d]}`, movieIDs[0]))
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", movieIDs[0]), token, "")
+	assert.Equal(t, code, http.StatusOK)
+	collection := body["movie"].(map[string]any)["collection"].(map[string]any)
+	assert.Equal(t, collection["name"].(string), "The Lord of the Rings Trilogy")
+	assert.Equal(t, collection["position"].(float64), float64(2))
+
+	code, body = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", movieIDs[2]), token, "")
+	assert.Equal(t, code, http.StatusOK)
+	_, ok := body["movie"].(map[string]any)["collection"]
+	assert.Equal(t, ok, false)
+
+	code, body = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies?collection=%d&sort=title", collectionID), token, "")
+	assert.Equal(t, code, http.StatusOK)
+	listed := body["movies"].([]any)
+	assert.Equal(t, len(listed), 2)
+	assert.Equal(t, listed[0].(map[string]any)["title"].(string), "The Fellowship of the Ring")
+	assert.Equal(t, listed[1].(map[string]any)["collection"].(map[string]any)["position"].(float64), float64(2))
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/movies?collection=-1", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body = ts.do(t, http.MethodPatch, collectionPath, token, `{"name": "The Lord of the Rings"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["collection"].(map[string]any)["version"].(float64), float64(2))
+
+	code, body = ts.do(t, http.MethodGet, "/v1/collections", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	collections := body["collections"].([]any)
+	assert.Equal(t, len(collections), 2)
+	assert.Equal(t, collections[0].(map[string]any)["name"].(string), "Fantasy")
+
+	// Deleting a movie removes it from its collection.
+	code, _ = ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", movieIDs[1]), token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, body = ts.do(t, http.MethodGet, collectionPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["movies"].([]any)), 1)
+
+	code, _ = ts.do(t, http.MethodDelete, collectionPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodGet, collectionPath, token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, body = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", movieIDs[0]), token, "")
+	assert.Equal(t, code, http.StatusOK)
+	_, ok = body["movie"].(map[string]any)["collection"]
+	assert.Equal(t, ok, false)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/validator"
+)
+
+// visibleMovie fetches a movie for the user, answering 404 for movies they
+// cannot see: those of other organizations, and drafts for users who cannot
+// edit movies.
+func (app *application) visibleMovie(w http.ResponseWriter, r *http.Request, id int64) (*data.Movie, bool) {
+	movie, err := app.getMovie(app.contextGetUser(r).OrgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !movie.IsPublished() {
+		canEdit, err := app.userHasPermission(r, "movies:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil, false
+		}
+		if !canEdit {
+			app.notFoundResponse(w, r)
+			return nil, false
+		}
+	}
+
+	return movie, true
+}
+
+// ownComment fetches the comment named in the URL for its author to change.
+// Deleted comments are treated as missing, and other users' comments are
+// refused.
+func (app *application) ownComment(w http.ResponseWriter, r *http.Request) (*data.Comment, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	comment, err := app.models.Comments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if comment.IsDeleted() {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	if comment.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return comment, true
+}
+
+func (app *application) listMovieCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.Filters.Page = qs.Int("page", 1)
+	input.Filters.PageSize = qs.Int("page_size", 20)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafelist = []string{"-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.withinQueryCost(w, r, input.Filters) {
+		return
+	}
+
+	movie, ok := app.visibleMovie(w, r, id)
+	if !ok {
+		return
+	}
+
+	comments, metadata, err := app.models.Comments.GetThreads(movie.ID, app.contextGetUser(r).ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"comments": comments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createMovieCommentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Body     string `json:"body"`
+		ParentID int64  `json:"parent_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	movie, ok := app.visibleMovie(w, r, id)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	comment := &data.Comment{
+		MovieID: movie.ID,
+		UserID:  user.ID,
+		Body:    input.Body,
+	}
+
+	v := validator.New()
+
+	// notifyParent is set when the author of the comment being replied to
+	// wants to hear about the reply: not when replying to oneself, nor when
+	// the parent's author has blocked or muted the replier.
+	var parent *data.Comment
+	notifyParent := false
+
+	if input.ParentID != 0 {
+		parent, err = app.models.Comments.Get(input.ParentID)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("parent_id", "must be a comment on this movie")
+		case err != nil:
+			app.serverErrorResponse(w, r, err)
+			return
+		case parent.MovieID != movie.ID:
+			v.AddError("parent_id", "must be a comment on this movie")
+		default:
+			comment.ReplyTo(parent)
+
+			block, err := app.models.Blocks.Get(parent.UserID, user.ID)
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				notifyParent = parent.UserID != user.ID
+			case err != nil:
+				app.serverErrorResponse(w, r, err)
+				return
+			case block.Kind == data.BlockKindBlock:
+				v.AddError("parent_id", "you cannot reply to this comment")
+			}
+		}
+	}
+
+	if data.ValidateComment(v, comment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Insert(comment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if notifyParent {
+		app.notify(parent.UserID, data.NotificationCommentReply, fmt.Sprintf("%s replied to your comment on %s", user.Name, movie.Title))
+		app.events.Publish(events.Event{Type: events.TypeCommentReply, UserID: parent.UserID, Data: comment})
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateCommentHandler lets authors fix their comments for a short while
+// after posting them, so that a comment cannot be changed once others have
+// had time to reply to it.
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.ownComment(w, r)
+	if !ok {
+		return
+	}
+
+	if time.Since(comment.CreatedAt) > app.config.comments.editWindow {
+		app.commentEditWindowClosedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Body string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment.Body = input.Body
+
+	v := validator.New()
+
+	if data.ValidateComment(v, comment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Update(comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.ownComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Comments.Delete(comment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "comment successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
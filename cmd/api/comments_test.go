@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestMovieComments(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.config.comments.editWindow = time.Hour
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	other := &data.User{Name: "Viewer", Email: "viewer@example.com", Locale: "en", Activated: true}
+	if err := other.Password.Set("pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(other); err != nil {
+		t.Fatal(err)
+	}
+	otherToken, err := app.models.Tokens.NewAuthentication(other.ID, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusPublished, OrgID: 1}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+	commentsPath := fmt.Sprintf("/v1/movies/%d/comments", movie.ID)
+
+	post := func(parentID int64, text string) int64 {
+		t.Helper()
+		code, body := ts.do(t, http.MethodPost, commentsPath, token, fmt.Sprintf(`{"body": %q, "parent_id": %d}`, text, parentID))
+		assert.Equal(t, code, http.StatusCreated)
+		return int64(body["comment"].(map[string]any)["id"].(float64))
+	}
+
+	first := post(0, "First!")
+	second := post(0, "Second thread")
+
+	parent := first
+	for depth := 1; depth <= data.MaxCommentDepth; depth++ {
+		parent = post(parent, "reply "+strconv.Itoa(depth))
+	}
+
+	code, body := ts.do(t, http.MethodPost, commentsPath, token, fmt.Sprintf(`{"body": "too deep", "parent_id": %d}`, parent))
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "must not be nested more than")
+
+	code, _ = ts.do(t, http.MethodPost, commentsPath, token, `{"body": ""}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body = ts.do(t, http.MethodGet, commentsPath+"?page_size=1", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	threads := body["comments"].([]any)
+	assert.Equal(t, len(threads), 1)
+	assert.Equal(t, int64(threads[0].(map[string]any)["id"].(float64)), second)
+	assert.Equal(t, body["metadata"].(map[string]any)["total_records"].(float64), 2)
+
+	code, body = ts.do(t, http.MethodGet, commentsPath+"?page=2&page_size=1", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	thread := body["comments"].([]any)[0].(map[string]any)
+	assert.Equal(t, int64(thread["id"].(float64)), first)
+	for depth := 1; depth <= data.MaxCommentDepth; depth++ {
+		thread = thread["replies"].([]any)[0].(map[string]any)
+		assert.Equal(t, thread["depth"].(float64), float64(depth))
+	}
+
+	firstPath := "/v1/comments/" + strconv.FormatInt(first, 10)
+
+	code, _ = ts.do(t, http.MethodPatch, firstPath, otherToken.Plaintext, `{"body": "hijacked"}`)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	code, _ = ts.do(t, http.MethodDelete, firstPath, otherToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	code, body = ts.do(t, http.MethodPatch, firstPath, token, `{"body": "First! (edited)"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["comment"].(map[string]any)["body"].(string), "First! (edited)")
+	if _, ok := body["comment"].(map[string]any)["edited_at"]; !ok {
+		t.Error("want edited_at to be set")
+	}
+
+	app.config.comments.editWindow = 0
+
+	code, _ = ts.do(t, http.MethodPatch, firstPath, token, `{"body": "too late"}`)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	code, _ = ts.do(t, http.MethodDelete, firstPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodDelete, firstPath, token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, body = ts.do(t, http.MethodGet, commentsPath+"?page=2&page_size=1", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	thread = body["comments"].([]any)[0].(map[string]any)
+	assert.Equal(t, thread["body"].(string), "")
+	assert.Equal(t, len(thread["replies"].([]any)), 1)
+}
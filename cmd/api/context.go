@@ -9,8 +9,31 @@ import (
 type contextKey string
 
 const userContextKey = contextKey("user")
+const requestMetaContextKey = contextKey("requestMeta")
+
+// requestMeta is created once per request by the outermost middleware and
+// filled in by the layers beneath it, so that handlers wrapping the router
+// (such as recoverPanic) can still see the matched route and user.
+type requestMeta struct {
+	id       string
+	clientIP string
+	route    string
+	userID   int64
+	// impersonatorID is the admin acting as userID, if any.
+	impersonatorID int64
+	orgID          int64
+	// apiVersion is set by negotiateVersion for requests to versions after
+	// v1.
+	apiVersion int
+}
 
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	if meta := app.contextGetRequestMeta(r); meta != nil {
+		meta.userID = user.ID
+		meta.impersonatorID = user.ImpersonatorID
+		meta.orgID = user.OrgID
+	}
+
 	ctx := context.WithValue(r.Context(), userContextKey, user)
 	return r.WithContext(ctx)
 }
@@ -22,3 +45,13 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+func (app *application) contextSetRequestMeta(r *http.Request, meta *requestMeta) *http.Request {
+	ctx := context.WithValue(r.Context(), requestMetaContextKey, meta)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetRequestMeta(r *http.Request) *requestMeta {
+	meta, _ := r.Context().Value(requestMetaContextKey).(*requestMeta)
+	return meta
+}
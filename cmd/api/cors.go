@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultCORSMethods = "OPTIONS, PUT, PATCH, DELETE"
+	defaultCORSHeaders = "Authorization, Content-Type, X-Anonymous-ID, X-Nonce, X-Timestamp"
+)
+
+// corsRule overrides the methods and headers allowed in preflight responses
+// for origins matching its pattern.
+type corsRule struct {
+	origin  string
+	methods string
+	headers string
+}
+
+// parseCORSRule parses a rule in the form "origin;methods;headers", where
+// methods and headers are comma separated lists. Empty lists fall back to the
+// defaults.
+func parseCORSRule(val string) (corsRule, error) {
+	parts := strings.Split(val, ";")
+	if len(parts) > 3 || !strings.Contains(parts[0], "://") {
+		return corsRule{}, fmt.Errorf("invalid CORS rule %q", val)
+	}
+
+	rule := corsRule{
+		origin:  strings.TrimSpace(parts[0]),
+		methods: defaultCORSMethods,
+		headers: defaultCORSHeaders,
+	}
+	if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+		rule.methods = joinCORSList(parts[1], strings.ToUpper)
+	}
+	if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" {
+		rule.headers = joinCORSList(parts[2], nil)
+	}
+
+	return rule, nil
+}
+
+func joinCORSList(list string, transform func(string) string) string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if transform != nil {
+			value = transform(value)
+		}
+		values = append(values, value)
+	}
+	return strings.Join(values, ", ")
+}
+
+// matchOrigin reports whether origin matches pattern. A pattern such as
+// "https://*.example.com" matches any subdomain of example.com over https,
+// but not example.com itself.
+func matchOrigin(pattern, origin string) bool {
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return pattern == origin
+	}
+
+	prefix, suffix := scheme+"://", "."+host
+	if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	sub := strings.TrimSuffix(strings.TrimPrefix(origin, prefix), suffix)
+	return sub != "" && !strings.ContainsAny(sub, "/:@")
+}
+
+// corsPolicy returns the methods and headers allowed for origin, and whether
+// the origin is trusted at all.
+func (app *application) corsPolicy(origin string) (methods, headers string, ok bool) {
+	for _, rule := range app.config.cors.rules {
+		if matchOrigin(rule.origin, origin) {
+			return rule.methods, rule.headers, true
+		}
+	}
+
+	for _, pattern := range app.config.cors.trustedOrigins {
+		if matchOrigin(pattern, origin) {
+			return defaultCORSMethods, defaultCORSHeaders, true
+		}
+	}
+
+	return "", "", false
+}
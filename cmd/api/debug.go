@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+)
+
+const redacted = "[REDACTED]"
+
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// sensitiveKeys are matched anywhere in JSON keys and query parameter
+// names, so that new_password, client_secret and recovery_codes are
+// redacted too.
+var sensitiveKeys = []string{"password", "token", "secret", "code"}
+
+// Placeholders recorded instead of bodies which cannot be redacted.
+const (
+	omittedTruncated = "[OMITTED: larger than the recorded size]"
+	omittedNotJSON   = "[OMITTED: not JSON]"
+)
+
+type debugEntry struct {
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	ClientIP        string            `json:"client_ip,omitempty"`
+	Status          int               `json:"status"`
+	DurationMicros  int64             `json:"duration_μs"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+}
+
+// requestRecorder keeps the most recent debug entries in a fixed size ring
+// buffer, overwriting the oldest entry once it is full.
+type requestRecorder struct {
+	mu      sync.Mutex
+	entries []debugEntry
+	next    int
+	full    bool
+}
+
+func newRequestRecorder(size int) *requestRecorder {
+	if size < 1 {
+		size = 1
+	}
+	return &requestRecorder{entries: make([]debugEntry, size)}
+}
+
+func (rec *requestRecorder) add(entry debugEntry) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.entries[rec.next] = entry
+	rec.next = (rec.next + 1) % len(rec.entries)
+	if rec.next == 0 {
+		rec.full = true
+	}
+}
+
+// recent returns the recorded entries, newest first.
+func (rec *requestRecorder) recent() []debugEntry {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	count := rec.next
+	if rec.full {
+		count = len(rec.entries)
+	}
+
+	entries := make([]debugEntry, 0, count)
+	for i := 1; i <= count; i++ {
+		idx := (rec.next - i + len(rec.entries)) % len(rec.entries)
+		entries = append(entries, rec.entries[idx])
+	}
+	return entries
+}
+
+// limitedBuffer stores at most max bytes and silently drops the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.max - b.Len()
+	if remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.Buffer.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// String returns the redacted body. A truncated body cannot be parsed, so
+// could not be redacted, and is left out.
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return omittedTruncated
+	}
+	return sanitizeBody(b.Bytes())
+}
+
+func sanitizeHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for key, values := range header {
+		headers[key] = strings.Join(values, ", ")
+	}
+	for _, key := range sensitiveHeaders {
+		if _, ok := headers[key]; ok {
+			headers[key] = redacted
+		}
+	}
+	return headers
+}
+
+// sanitizeBody redacts the values of sensitive keys anywhere in a JSON body.
+// Other bodies, such as MessagePack or form encoded ones, are left out as
+// they cannot be redacted.
+func sanitizeBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return omittedNotJSON
+	}
+
+	js, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return omittedNotJSON
+	}
+	return string(js)
+}
+
+// sanitizeURL redacts the values of sensitive query parameters, such as
+// the token of an upload URL.
+func sanitizeURL(u *url.URL) string {
+	query := u.Query()
+
+	found := false
+	for key, values := range query {
+		if isSensitiveKey(key) {
+			for i := range values {
+				values[i] = redacted
+			}
+			found = true
+		}
+	}
+	if !found {
+		return u.String()
+	}
+
+	sanitized := *u
+	sanitized.RawQuery = query.Encode()
+	return sanitized.String()
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, nested := range v {
+			if isSensitiveKey(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(nested)
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return value
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *application) shouldRecord(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/v1/admin/debug/") {
+		return false
+	}
+	for _, prefix := range app.config.debug.routes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return rand.Float64() < app.config.debug.sampleRate
+}
+
+func (app *application) recordRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.debug.enabled || !app.shouldRecord(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestBody := &limitedBuffer{max: app.config.debug.maxBodyBytes}
+		if r.Body != nil {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, requestBody), r.Body}
+		}
+
+		responseBody := &limitedBuffer{max: app.config.debug.maxBodyBytes}
+		status := http.StatusOK
+
+		ww := httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					status = code
+					next(code)
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					responseBody.Write(b)
+					return next(b)
+				}
+			},
+		})
+
+		var clientIP string
+		if meta := app.contextGetRequestMeta(r); meta != nil {
+			clientIP = meta.clientIP
+		}
+
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+
+		app.recorder.add(debugEntry{
+			Time:            start.UTC(),
+			Method:          r.Method,
+			URL:             sanitizeURL(r.URL),
+			ClientIP:        clientIP,
+			Status:          status,
+			DurationMicros:  time.Since(start).Microseconds(),
+			RequestHeaders:  sanitizeHeaders(r.Header),
+			RequestBody:     requestBody.String(),
+			ResponseHeaders: sanitizeHeaders(w.Header()),
+			ResponseBody:    responseBody.String(),
+		})
+	})
+}
+
+func (app *application) listDebugRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.config.debug.enabled {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.writeJSON(w, r, http.StatusOK, envelope{"requests": app.recorder.recent()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
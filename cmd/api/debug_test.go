@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestRecordRequests(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.debug.enabled = true
+	app.config.debug.routes = []string{"/v1/tokens"}
+	app.config.debug.maxBodyBytes = 1024
+	app.recorder = newRequestRecorder(2)
+
+	handler := app.recordRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]string
+		app.readJSON(w, r, &input)
+		app.writeJSON(w, r, http.StatusCreated, envelope{"authentication_token": map[string]string{"token": "secret"}}, nil)
+	}))
+
+	for _, path := range []string{"/v1/tokens/authentication", "/v1/movies", "/v1/tokens/authentication"} {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"email": "a@b.com", "password": "pa55word"}`))
+		r.Header.Set("Authorization", "Bearer abc")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	entries := app.recorder.recent()
+	assert.Equal(t, len(entries), 2)
+
+	for _, entry := range entries {
+		assert.Equal(t, entry.URL, "/v1/tokens/authentication")
+		assert.Equal(t, entry.Status, http.StatusCreated)
+		assert.Equal(t, entry.RequestHeaders["Authorization"], redacted)
+		assert.Equal(t, entry.RequestBody, `{"email":"a@b.com","password":"[REDACTED]"}`)
+		assert.Equal(t, entry.ResponseBody, `{"authentication_token":"[REDACTED]"}`)
+	}
+}
+
+func TestRequestRecorderRing(t *testing.T) {
+	rec := newRequestRecorder(3)
+
+	for _, url := range []string{"/1", "/2", "/3", "/4"} {
+		rec.add(debugEntry{URL: url})
+	}
+
+	entries := rec.recent()
+	assert.Equal(t, len(entries), 3)
+	assert.Equal(t, entries[0].URL, "/4")
+	assert.Equal(t, entries[2].URL, "/2")
+}
+
+func TestListDebugRequestsDisabled(t *testing.T) {
+	app := newTestApplication(t)
+	app.recorder = newRequestRecorder(1)
+
+	w := httptest.NewRecorder()
+	app.listDebugRequestsHandler(w, httptest.NewRequest(http.MethodGet, "/v1/admin/debug/requests", nil))
+
+	assert.Equal(t, w.Code, http.StatusNotFound)
+}
+
+func TestRecordRequestsRedaction(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.debug.enabled = true
+	app.config.debug.routes = []string{"/"}
+	app.config.debug.maxBodyBytes = 128
+	app.recorder = newRequestRecorder(1)
+
+	tests := []struct {
+		url      string
+		body     string
+		wantURL  string
+		wantBody string
+	}{
+		{"/v1/uploads/1?token=abc&part=2", "", "/v1/uploads/1?part=2&token=%5BREDACTED%5D", ""},
+		{"/v1/movies?title=heat", "", "/v1/movies?title=heat", ""},
+		{"/v1/users/password", `{"new_password": "pa55word", "client_secret": "x", "recovery_codes": ["a"]}`, "/v1/users/password", `{"client_secret":"[REDACTED]","new_password":"[REDACTED]","recovery_codes":"[REDACTED]"}`},
+		{"/v1/tokens/authentication", `{"password": "` + strings.Repeat("a", 200) + `"}`, "/v1/tokens/authentication", omittedTruncated},
+		{"/v1/tokens/authentication", "\x82\xa8password\xa8pa55word", "/v1/tokens/authentication", omittedNotJSON},
+	}
+
+	for _, tt := range tests {
+		handler := app.recordRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body)))
+
+		entry := app.recorder.recent()[0]
+		assert.Equal(t, entry.URL, tt.wantURL)
+		assert.Equal(t, entry.RequestBody, tt.wantBody)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// deprecation describes a route which is on its way out. Clients are told
+// through the Deprecation (RFC 9745) and Sunset (RFC 8594) headers, with a
+// Link to the replacement when there is one.
+type deprecation struct {
+	// since is when the route was deprecated.
+	since time.Time
+	// sunset is when the route is expected to stop working. It is optional.
+	sunset time.Time
+	// successor is the path of the route replacing this one, if any.
+	successor string
+}
+
+// routeDeprecations holds the deprecated routes, keyed by method and path
+// pattern as they are registered, e.g. "GET /v1/movies/:id".
+var routeDeprecations = map[string]deprecation{}
+
+var totalDeprecatedRequests = publishMap("total_deprecated_requests")
+
+// deprecated adds the deprecation headers to the responses of a route and
+// counts its use, so that removals can be planned from the metrics.
+func (app *application) deprecated(route string, d deprecation, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", d.since.Unix()))
+
+		if !d.sunset.IsZero() {
+			w.Header().Set("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+		}
+
+		if d.successor != "" {
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, d.successor))
+		}
+
+		totalDeprecatedRequests.Add(route, 1)
+
+		next.ServeHTTP(w, r)
+	})
+}
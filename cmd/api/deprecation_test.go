@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestDeprecatedRoute(t *testing.T) {
+	routeDeprecations["GET /v1/readyz"] = deprecation{
+		since:     time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+		sunset:    time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC),
+		successor: "/v1/healthcheck",
+	}
+	defer delete(routeDeprecations, "GET /v1/readyz")
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	before := totalDeprecatedRequests.Get("GET /v1/readyz")
+
+	code, header, _ := ts.get(t, "/v1/readyz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Deprecation"), "@1767225600")
+	assert.Equal(t, header.Get("Sunset"), "Fri, 01 Jan 2027 00:00:00 GMT")
+	assert.Equal(t, header.Get("Link"), `</v1/healthcheck>; rel="successor-version"`)
+
+	code, header, _ = ts.get(t, "/v1/healthcheck")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Deprecation"), "")
+
+	after := totalDeprecatedRequests.Get("GET /v1/readyz")
+	if after == nil || (before != nil && after.String() == before.String()) {
+		t.Errorf("want deprecated request to be counted; got %v", after)
+	}
+}
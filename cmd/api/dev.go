@@ -0,0 +1,49 @@
+package main
+
+import (
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/jsonlog"
+)
+
+const (
+	devUserEmail    = "dev@example.com"
+	devUserPassword = "pa55word"
+)
+
+// seedDevUser creates an activated user with every permission so that a
+// server started with -dev can be used straight away.
+func seedDevUser(models data.Models, logger *jsonlog.Logger) error {
+	user := &data.User{
+		Name:      "Dev User",
+		Email:     devUserEmail,
+		Locale:    "en",
+		Activated: true,
+	}
+
+	err := user.Password.Set(devUserPassword)
+	if err != nil {
+		return err
+	}
+
+	err = models.Users.Insert(user)
+	if err != nil {
+		return err
+	}
+
+	err = models.Permissions.AddForUser(user.ID, "movies:read", "movies:write", "movies:publish", "admin:read", "admin:write", "admin:impersonate")
+	if err != nil {
+		return err
+	}
+
+	err = models.Organizations.AddMember(1, user.ID, data.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	logger.PrintInfo("running with in-memory models; data is lost on exit", map[string]string{
+		"email":    devUserEmail,
+		"password": devUserPassword,
+	})
+
+	return nil
+}
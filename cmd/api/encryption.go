@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"greenlight.bcc/internal/jobs"
+)
+
+// rotateEncryptionHandler starts a background job re-encrypting personal
+// data with the primary key, after a new key has been put first in
+// -encryption-keys. Data not yet encrypted at all is encrypted too. Each
+// step re-encrypts one batch of rows; once the job has succeeded the old
+// keys can be removed.
+func (app *application) rotateEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	if app.config.encryption.keys == "" {
+		app.encryptionNotConfiguredResponse(w, r)
+		return
+	}
+
+	stale, err := app.models.Users.CountStaleEncryption()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	batchSize := app.config.encryption.rotateBatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	batches := (stale + batchSize - 1) / batchSize
+
+	steps := make([]jobs.Step, batches)
+	for i := range steps {
+		steps[i] = jobs.Step{
+			Name: fmt.Sprintf("users batch %d of %d", i+1, batches),
+			Run: func() error {
+				_, err := app.models.Users.ReencryptBatch(batchSize)
+				return err
+			},
+		}
+	}
+
+	job, err := app.jobs.Start("rotate-encryption", steps)
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrAlreadyRunning):
+			app.jobAlreadyRunningResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.logger.PrintInfo("encryption key rotation started", map[string]string{
+		"rows":    strconv.Itoa(stale),
+		"job_id":  strconv.FormatInt(job.ID, 10),
+		"user_id": strconv.FormatInt(app.contextGetUser(r).ID, 10),
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/jobs/%d", job.ID))
+
+	err = app.writeJSON(w, r, http.StatusAccepted, envelope{"job": job}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
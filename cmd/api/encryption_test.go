@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/fieldcrypt"
+)
+
+func TestKeyring(t *testing.T) {
+	key := func(id string, b byte) fieldcrypt.Key {
+		return fieldcrypt.Key{ID: id, Secret: bytes.Repeat([]byte{b}, fieldcrypt.KeySize)}
+	}
+	indexKey := bytes.Repeat([]byte{9}, fieldcrypt.KeySize)
+
+	old, err := fieldcrypt.New([]fieldcrypt.Key{key("k1", 1)}, indexKey)
+	assert.NilError(t, err)
+
+	sealed, err := old.Seal("alice@example.com", "users.email")
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(sealed, "enc:k1:"), true)
+	assert.Equal(t, strings.Contains(sealed, "alice"), false)
+
+	opened, err := old.Open(sealed, "users.email")
+	assert.NilError(t, err)
+	assert.Equal(t, opened, "alice@example.com")
+
+	// A value only opens in the context it was sealed in.
+	_, err = old.Open(sealed, "users.name")
+	assert.Equal(t, errors.Is(err, fieldcrypt.ErrDecrypt), true)
+
+	_, err = old.Open("alice@example.com", "users.email")
+	assert.Equal(t, errors.Is(err, fieldcrypt.ErrMalformed), true)
+
+	// After rotation the old key still opens existing values, which are
+	// stale until sealed again with the new primary key.
+	rotated, err := fieldcrypt.New([]fieldcrypt.Key{key("k2", 2), key("k1", 1)}, indexKey)
+	assert.NilError(t, err)
+
+	opened, err = rotated.Open(sealed, "users.email")
+	assert.NilError(t, err)
+	assert.Equal(t, opened, "alice@example.com")
+	assert.Equal(t, rotated.Stale(sealed), true)
+	assert.Equal(t, rotated.Stale("alice@example.com"), true)
+
+	resealed, err := rotated.Seal(opened, "users.email")
+	assert.NilError(t, err)
+	assert.Equal(t, rotated.Stale(resealed), false)
+
+	withoutOld, err := fieldcrypt.New([]fieldcrypt.Key{key("k2", 2)}, indexKey)
+	assert.NilError(t, err)
+	_, err = withoutOld.Open(sealed, "users.email")
+	assert.Equal(t, errors.Is(err, fieldcrypt.ErrUnknownKey), true)
+
+	// Blind indexes survive rotation and ignore case.
+	assert.Equal(t, old.BlindIndex("Alice@Example.com"), rotated.BlindIndex("alice@example.com"))
+	assert.Equal(t, old.BlindIndex("alice@example.com") == old.BlindIndex("bob@example.com"), false)
+
+	_, err = fieldcrypt.New([]fieldcrypt.Key{key("k1", 1), key("k1", 2)}, indexKey)
+	assert.StringContains(t, err.Error(), "duplicate key ID")
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := func(b byte) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, fieldcrypt.KeySize))
+	}
+
+	keys, err := fieldcrypt.ParseKeys(context.Background(), "k2:"+secret(2)+", k1:"+secret(1), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(keys), 2)
+	assert.Equal(t, keys[0].ID, "k2")
+	assert.Equal(t, len(keys[1].Secret), fieldcrypt.KeySize)
+
+	_, err = fieldcrypt.ParseKeys(context.Background(), "k1:kms:AQID", nil)
+	assert.StringContains(t, err.Error(), "no key management service")
+
+	_, err = fieldcrypt.ParseKeys(context.Background(), "k1", nil)
+	assert.StringContains(t, err.Error(), "id:secret")
+}
+
+func TestRotateEncryption(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(editor.ID, "admin:read", "admin:write"); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, body := ts.do(t, http.MethodPost, "/v1/admin/encryption/rotate", token, "")
+	assert.Equal(t, code, http.StatusConflict)
+	assert.StringContains(t, body["error"].(string), "no encryption keys")
+
+	app.config.encryption.keys = "k1:configured"
+
+	code, body = ts.do(t, http.MethodPost, "/v1/admin/encryption/rotate", token, "")
+	assert.Equal(t, code, http.StatusAccepted)
+	assert.Equal(t, body["job"].(map[string]any)["name"].(string), "rotate-encryption")
+}
+
+func TestInvitationEmailEncryption(t *testing.T) {
+	keys, err := fieldcrypt.New([]fieldcrypt.Key{{ID: "k1", Secret: bytes.Repeat([]byte{1}, fieldcrypt.KeySize)}}, bytes.Repeat([]byte{9}, fieldcrypt.KeySize))
+	assert.NilError(t, err)
+
+	var exec []driver.NamedValue
+	db := sql.OpenDB(legacyUserConnector{legacyUserConn{exec: &exec}})
+	defer db.Close()
+
+	invitations := data.InvitationModel{DB: db, Keys: keys}
+
+	_, err = invitations.New("Friend@example.com", 1, time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, len(exec), 5)
+
+	// The email column holds the blind index, which matches users' no
+	// matter the case, and the address is only stored sealed.
+	assert.Equal(t, exec[1].Value.(string), keys.BlindIndex("friend@example.com"))
+
+	opened, err := keys.Open(exec[2].Value.(string), "invitations.email")
+	assert.NilError(t, err)
+	assert.Equal(t, opened, "Friend@example.com")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/enrich"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/validator"
+)
+
+// enrichMovieHandler fills in the movie's missing synopsis, poster URL,
+// runtime and catalog IDs from TMDb. Fields which already have a value are
+// left alone.
+func (app *application) enrichMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	movie, err := app.models.Movies.Get(user.OrgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	metadata, err := app.enricher.Lookup(enrich.Query{
+		TMDbID: movie.TMDbID,
+		IMDbID: movie.IMDbID,
+		Title:  movie.Title,
+		Year:   movie.Year,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, enrich.ErrNotConfigured):
+			app.errorResponse(w, r, http.StatusServiceUnavailable, "metadata enrichment is not configured")
+		case errors.Is(err, enrich.ErrNotFound):
+			app.errorResponse(w, r, http.StatusNotFound, "no matching movie was found on TMDb")
+		default:
+			app.logError(r, err)
+			app.errorResponse(w, r, http.StatusBadGateway, "the metadata provider could not be reached")
+		}
+		return
+	}
+
+	filled := []string{}
+
+	if movie.Synopsis == "" && metadata.Synopsis != "" {
+		movie.Synopsis = metadata.Synopsis
+		filled = append(filled, "synopsis")
+	}
+	if movie.PosterURL == "" && metadata.PosterURL != "" {
+		movie.PosterURL = metadata.PosterURL
+		filled = append(filled, "poster_url")
+	}
+	if movie.Runtime == 0 && metadata.Runtime > 0 {
+		movie.Runtime = data.Runtime(metadata.Runtime)
+		filled = append(filled, "runtime")
+	}
+	if movie.TMDbID == 0 && metadata.TMDbID > 0 {
+		movie.TMDbID = metadata.TMDbID
+		filled = append(filled, "tmdb_id")
+	}
+	if movie.IMDbID == "" && validator.Matches(metadata.IMDbID, data.IMDbIDRX) {
+		movie.IMDbID = metadata.IMDbID
+		filled = append(filled, "imdb_id")
+	}
+
+	if len(filled) > 0 {
+		v := validator.New()
+
+		err = app.models.Movies.Update(movie, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			case errors.Is(err, data.ErrDuplicateIMDbID):
+				v.AddError("imdb_id", "a movie with this IMDb ID already exists")
+				app.failedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, data.ErrDuplicateTMDbID):
+				v.AddError("tmdb_id", "a movie with this TMDb ID already exists")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		app.publishMovieEvent(events.TypeMovieUpdated, movie)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"movie": movie, "filled": filled}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
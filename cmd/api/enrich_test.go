@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/enrich"
+)
+
+type stubEnricher struct {
+	metadata *enrich.Metadata
+	err      error
+}
+
+func (s stubEnricher) Lookup(q enrich.Query) (*enrich.Metadata, error) {
+	return s.metadata, s.err
+}
+
+func TestEnrichMovie(t *testing.T) {
+	metadata := &enrich.Metadata{
+		TMDbID:    278,
+		IMDbID:    "tt0111161",
+		Synopsis:  "Two imprisoned men bond over a number of years.",
+		PosterURL: "https://image.tmdb.org/t/p/w500/poster.jpg",
+		Runtime:   142,
+	}
+
+	tests := []struct {
+		name     string
+		enricher enrich.Enricher
+		wantCode int
+		wantBody string
+	}{
+		{"Fills missing fields", stubEnricher{metadata: metadata}, http.StatusOK, `"filled":["synopsis","poster_url","tmdb_id","imdb_id"]`},
+		{"Keeps existing runtime", stubEnricher{metadata: metadata}, http.StatusOK, `"runtime":"105 mins"`},
+		{"Nothing to fill", stubEnricher{metadata: &enrich.Metadata{}}, http.StatusOK, `"filled":[]`},
+		{"Not configured", enrich.NoopEnricher{}, http.StatusServiceUnavailable, `"error"`},
+		{"No match", stubEnricher{err: enrich.ErrNotFound}, http.StatusNotFound, `no matching movie`},
+		{"Provider error", stubEnricher{err: errors.New("timeout")}, http.StatusBadGateway, `"error"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.enricher = tt.enricher
+			id := insertMovie(t, app, testMovie())
+
+			ts := newTestServer(t, app.routesTest())
+			defer ts.Close()
+
+			code, _, body := ts.postForm(t, fmt.Sprintf("/v1/movies/%d/enrich", id), nil)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	t.Run("Movie not found", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.enricher = stubEnricher{metadata: metadata}
+
+		ts := newTestServer(t, app.routesTest())
+		defer ts.Close()
+
+		code, _, body := ts.postForm(t, "/v1/movies/1000/enrich", nil)
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.StringContains(t, body, `"error"`)
+	})
+
+	t.Run("Update error", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.enricher = stubEnricher{metadata: metadata}
+		app.models.Movies = failingUpdateMovieModel{app.models.Movies.(data.MemoryMovieModel)}
+		id := insertMovie(t, app, testMovie())
+
+		ts := newTestServer(t, app.routesTest())
+		defer ts.Close()
+
+		code, _, body := ts.postForm(t, fmt.Sprintf("/v1/movies/%d/enrich", id), nil)
+
+		assert.Equal(t, code, http.StatusInternalServerError)
+		assert.StringContains(t, body, `"error"`)
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"greenlight.bcc/internal/data"
+)
+
+// profileBare is the Accept profile asking for responses without the
+// envelope, as in Accept: application/json; profile=bare.
+const profileBare = "bare"
+
+// wantsEnvelope reports whether the client wants resources wrapped in an
+// envelope, which is the default. Either envelope=false in the query string
+// or the bare Accept profile turns it off.
+func wantsEnvelope(r *http.Request) bool {
+	if s := r.URL.Query().Get("envelope"); s != "" {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && params["profile"] == profileBare {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unwrapEnvelope returns the body of a response without its envelope. The
+// total from the metadata is moved into the X-Total-Count header, next to
+// the Link header every list response has. If a single member remains it is
+// returned on its own, otherwise the remaining members are. Facets have no
+// header form, so clients that want them need the envelope.
+func unwrapEnvelope(env envelope, headers http.Header) any {
+	body := envelope{}
+	for key, value := range env {
+		if key != "metadata" {
+			body[key] = value
+		}
+	}
+
+	if metadata, ok := env["metadata"].(data.Metadata); ok {
+		headers.Set("X-Total-Count", strconv.Itoa(metadata.TotalRecords))
+	}
+
+	if len(body) == 1 {
+		for _, value := range body {
+			return value
+		}
+	}
+
+	return body
+}
+
+// addPaginationLinks adds an RFC 5988 Link header for the pages of a list
+// response, so that clients can paginate without reading the body. Pages
+// come from the metadata's page numbers or, for cursor pagination, its
+// next_cursor.
+func addPaginationLinks(r *http.Request, env envelope, headers http.Header) {
+	var links string
+
+	switch metadata := env["metadata"].(type) {
+	case data.Metadata:
+		links = paginationLinks(r.URL, metadata)
+	case envelope:
+		if cursor, ok := metadata["next_cursor"].(string); ok {
+			links = fmt.Sprintf(`<%s>; rel="next"`, pageURL(r.URL, "cursor", cursor))
+		}
+	}
+
+	if links != "" {
+		headers.Add("Link", links)
+	}
+}
+
+// paginationLinks returns the first, prev, next and last links for a page
+// of results in the form of a Link header, or "" if there are no results.
+func paginationLinks(u *url.URL, metadata data.Metadata) string {
+	if metadata.LastPage == 0 {
+		return ""
+	}
+
+	link := func(page int, rel string) string {
+		return fmt.Sprintf(`<%s>; rel="%s"`, pageURL(u, "page", strconv.Itoa(page)), rel)
+	}
+
+	links := []string{link(metadata.FirstPage, "first")}
+	if metadata.CurrentPage > metadata.FirstPage {
+		links = append(links, link(metadata.CurrentPage-1, "prev"))
+	}
+	if metadata.CurrentPage < metadata.LastPage {
+		links = append(links, link(metadata.CurrentPage+1, "next"))
+	}
+	links = append(links, link(metadata.LastPage, "last"))
+
+	return strings.Join(links, ", ")
+}
+
+// pageURL returns the path and query of u with key set to value.
+func pageURL(u *url.URL, key, value string) string {
+	qs := u.Query()
+	qs.Set(key, value)
+
+	return (&url.URL{Path: u.Path, RawQuery: qs.Encode()}).String()
+}
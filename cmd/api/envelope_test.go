@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestBareResponses(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	get := func(path, accept string, dst any) (int, http.Header) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		b, err := io.ReadAll(rs.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(b, dst); err != nil {
+			t.Fatalf("decoding %q: %v", b, err)
+		}
+		return rs.StatusCode, rs.Header
+	}
+
+	var id string
+	for _, title := range []string{"Moana", "Black Panther", "Arrival"} {
+		code, body := ts.do(t, http.MethodPost, "/v1/movies", token, fmt.Sprintf(`{"title": %q, "year": 2016, "runtime": "107 mins"}`, title))
+		assert.Equal(t, code, http.StatusCreated)
+		id = fmt.Sprint(body["movie"].(map[string]any)["id"])
+	}
+
+	var movies []map[string]any
+	code, headers := get("/v1/movies?status=draft&page_size=2&envelope=false", "", &movies)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(movies), 2)
+	assert.Equal(t, headers.Get("X-Total-Count"), "3")
+	assert.Equal(t, headers.Get("Link"), `</v1/movies?envelope=false&page=1&page_size=2&status=draft>; rel="first", `+
+		`</v1/movies?envelope=false&page=2&page_size=2&status=draft>; rel="next", `+
+		`</v1/movies?envelope=false&page=2&page_size=2&status=draft>; rel="last"`)
+
+	var movie map[string]any
+	code, _ = get("/v1/movies/"+id, "application/json; profile=bare", &movie)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, fmt.Sprint(movie["title"]), "Arrival")
+
+	var enveloped map[string]any
+	code, _ = get("/v1/movies/"+id+"?envelope=true", "", &enveloped)
+	assert.Equal(t, code, http.StatusOK)
+	_, ok := enveloped["movie"]
+	assert.Equal(t, ok, true)
+
+	var failed map[string]any
+	code, _ = get("/v1/movies/999?envelope=false", "", &failed)
+	assert.Equal(t, code, http.StatusNotFound)
+	_, ok = failed["error"]
+	assert.Equal(t, ok, true)
+}
+
+func TestPaginationLinks(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	for _, title := range []string{"Moana", "Black Panther", "Arrival"} {
+		code, _ := ts.do(t, http.MethodPost, "/v1/movies", token, fmt.Sprintf(`{"title": %q, "year": 2016, "runtime": "107 mins"}`, title))
+		assert.Equal(t, code, http.StatusCreated)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/movies?status=draft&page=2&page_size=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("X-Total-Count"), "")
+	assert.Equal(t, rs.Header.Get("Link"), `</v1/movies?page=1&page_size=1&status=draft>; rel="first", `+
+		`</v1/movies?page=1&page_size=1&status=draft>; rel="prev", `+
+		`</v1/movies?page=3&page_size=1&status=draft>; rel="next", `+
+		`</v1/movies?page=3&page_size=1&status=draft>; rel="last"`)
+}
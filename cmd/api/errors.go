@@ -1,21 +1,78 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/breaker"
+	"greenlight.bcc/internal/errtrack"
 )
 
-func (app *application) logError(r *http.Request, err error) {
-	app.logger.PrintError(err, map[string]string{
+const serverErrorMessage = "the server encountered a problem and could not process your request"
+
+func (app *application) requestProperties(r *http.Request) map[string]string {
+	properties := map[string]string{
 		"request_method": r.Method,
 		"request_url":    r.URL.String(),
+	}
+
+	if meta := app.contextGetRequestMeta(r); meta != nil {
+		properties["request_id"] = meta.id
+		if meta.clientIP != "" {
+			properties["client_ip"] = meta.clientIP
+		}
+		if meta.route != "" {
+			properties["route"] = meta.route
+		}
+		if meta.userID != 0 {
+			properties["user_id"] = strconv.FormatInt(meta.userID, 10)
+		}
+		if meta.impersonatorID != 0 {
+			properties["impersonator_id"] = strconv.FormatInt(meta.impersonatorID, 10)
+		}
+	}
+
+	return properties
+}
+
+func (app *application) logError(r *http.Request, err error) {
+	app.logger.PrintError(err, app.requestProperties(r))
+}
+
+// reportError forwards an error to the configured error tracker in the
+// background, tagged with the request context.
+func (app *application) reportError(r *http.Request, err error, level string, stack []byte) {
+	if app.errtrack == nil {
+		return
+	}
+
+	event := errtrack.Event{
+		Err:   err,
+		Level: level,
+		Stack: stack,
+		Tags:  app.requestProperties(r),
+	}
+	if meta := app.contextGetRequestMeta(r); meta != nil {
+		event.UserID = meta.userID
+	}
+
+	app.background(func() {
+		if err := app.errtrack.Report(event); err != nil {
+			app.logger.PrintError(err, nil)
+		}
 	})
 }
 
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
 	env := envelope{"error": message}
 
-	err := app.writeJSON(w, status, env, nil)
+	err := app.writeJSON(w, r, status, env, nil)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -23,9 +80,15 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 }
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var openErr *breaker.OpenError
+	if errors.As(err, &openErr) {
+		app.serviceUnavailableResponse(w, r, openErr.RetryAfter)
+		return
+	}
+
 	app.logError(r, err)
-	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.reportError(r, err, errtrack.LevelError, debug.Stack())
+	app.errorResponse(w, r, http.StatusInternalServerError, serverErrorMessage)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +102,11 @@ func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var jsonErr *jsonError
+	if errors.As(err, &jsonErr) {
+		app.errorResponse(w, r, http.StatusBadRequest, jsonErr)
+		return
+	}
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
 
@@ -51,7 +119,37 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
-func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+// throttle describes the limit a client ran into, for the headers telling
+// it when to try again.
+type throttle struct {
+	// limit is how many requests may be made in a burst.
+	limit     int
+	remaining int
+	// reset is how long until remaining is back to limit.
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// setRetryAfter sets the Retry-After header to d, rounded up to whole
+// seconds and at least one, so that clients never retry straight away.
+func setRetryAfter(h http.Header, d time.Duration) {
+	h.Set("Retry-After", strconv.Itoa(ceilSeconds(d)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, t throttle) {
+	setRetryAfter(w.Header(), t.retryAfter)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(t.limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(t.remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(t.reset)))
+
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
@@ -77,7 +175,121 @@ func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.R
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
 
+func (app *application) bannedAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account has been banned"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) originNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "cross-origin requests from this origin are not allowed"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) ipNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "access to this resource is not allowed from your network address"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) captchaFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := map[string]string{
+		"code":    "captcha_failed",
+		"message": "captcha verification failed, please try again",
+	}
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+func (app *application) quotaExceededResponse(w http.ResponseWriter, r *http.Request, resource string) {
+	message := map[string]string{
+		"code":     "quota_exceeded",
+		"resource": resource,
+		"message":  "you have reached the limit for this resource",
+	}
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) invalidWebhookSignatureResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or missing webhook signature"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) invalidUploadTokenResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid, expired or already used upload token"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) commentEditWindowClosedResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("comments can only be edited within %s of being posted", app.config.comments.editWindow)
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the %s content type is not supported for this resource", r.Header.Get("Content-Type"))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+func (app *application) patchTestFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	message := fmt.Sprintf("the patch could not be applied: %s", err)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) jobAlreadyRunningResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this task is already running, wait for it to finish before starting it again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) invalidNonceResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("this request must carry a unique X-Nonce header and an X-Timestamp header within %s of the server's clock", app.config.replay.window)
+	app.errorResponse(w, r, http.StatusBadRequest, message)
+}
+
+func (app *application) replayedRequestResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this nonce has already been used, send the request again with a new one"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) encryptionNotConfiguredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "no encryption keys are configured, there is nothing to rotate"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the requested API version is not supported"
+	app.errorResponse(w, r, http.StatusNotAcceptable, message)
+}
+
+// queryTooExpensiveResponse tells the client which limit of the query cost
+// guard its list request broke.
+func (app *application) queryTooExpensiveResponse(w http.ResponseWriter, r *http.Request, rejection *costRejection, indexedSorts []string) {
+	var message string
+	switch rejection.reason {
+	case costPageSizeTooLarge:
+		message = fmt.Sprintf("page_size must be a maximum of %d", rejection.limit)
+	case costOffsetTooLarge:
+		message = fmt.Sprintf("results beyond the first %d cannot be paged to, narrow the filters instead", rejection.limit)
+	case costUnindexedSort:
+		message = fmt.Sprintf("results beyond the first %d can only be paged to when sorting by %s", rejection.limit, strings.Join(indexedSorts, ", "))
+	}
+
+	response := map[string]any{
+		"code":    "query_too_expensive",
+		"reason":  rejection.reason,
+		"field":   rejection.field,
+		"limit":   rejection.limit,
+		"message": message,
+	}
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, response)
+}
+
+// serviceUnavailableResponse is sent while a dependency's circuit breaker is
+// open or the server is shedding load, telling the client when it is worth
+// trying again.
+func (app *application) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	setRetryAfter(w.Header(), retryAfter)
+	message := "the server is temporarily unable to handle your request, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
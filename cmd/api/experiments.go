@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/experiment"
+)
+
+const experimentsContextKey = contextKey("experiments")
+
+// anonymousIDRX matches the IDs anonymous clients identify themselves by in
+// the X-Anonymous-ID header.
+var anonymousIDRX = regexp.MustCompile(`^[A-Za-z0-9_-]{16,64}$`)
+
+// maxExposuresSeen bounds the exposures remembered as already recorded.
+// Forgetting them only costs a redundant insert.
+const maxExposuresSeen = 100000
+
+// exposureLog remembers the exposures already recorded, so that a subject
+// is not written to the database on every request. Its zero value is ready
+// to use.
+type exposureLog struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// add reports whether key is new, remembering it if so.
+func (l *exposureLog) add(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.seen[key]; ok {
+		return false
+	}
+	if l.seen == nil || len(l.seen) >= maxExposuresSeen {
+		l.seen = make(map[string]struct{})
+	}
+	l.seen[key] = struct{}{}
+	return true
+}
+
+func (l *exposureLog) forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.seen, key)
+}
+
+// experimentSubject returns who the caller is assigned variants as. Users
+// are assigned by their ID and anonymous clients by their X-Anonymous-ID
+// header; clients which send none are given an ID to send on later
+// requests.
+func experimentSubject(w http.ResponseWriter, r *http.Request, user *data.User) string {
+	if !user.IsAnonymous() {
+		return "user:" + strconv.FormatInt(user.ID, 10)
+	}
+
+	w.Header().Add("Vary", "X-Anonymous-ID")
+
+	id := r.Header.Get("X-Anonymous-ID")
+	if !anonymousIDRX.MatchString(id) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		id = hex.EncodeToString(b)
+		w.Header().Set("X-Anonymous-ID", id)
+	}
+
+	return "anonymous:" + id
+}
+
+// assignExperiments assigns the caller a variant of every experiment,
+// records their first exposure to each and announces the assignments in
+// the X-Experiments header, such as "search=fuzzy, poster=small", so that
+// clients can match their UI to what the API does.
+func (app *application) assignExperiments(next http.Handler) http.Handler {
+	if len(app.config.experiments.defined) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		subject := experimentSubject(w, r, user)
+
+		assignments := make(map[string]string, len(app.config.experiments.defined))
+		announced := make([]string, 0, len(app.config.experiments.defined))
+
+		for _, e := range app.config.experiments.defined {
+			variant := e.Assign(subject)
+			assignments[e.Name] = variant
+			announced = append(announced, e.Name+"="+variant)
+
+			app.recordExposure(&data.ExperimentExposure{
+				Experiment: e.Name,
+				Subject:    subject,
+				Variant:    variant,
+				UserID:     user.ID,
+			})
+		}
+
+		w.Header().Set("X-Experiments", strings.Join(announced, ", "))
+
+		ctx := context.WithValue(r.Context(), experimentsContextKey, assignments)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (app *application) recordExposure(exposure *data.ExperimentExposure) {
+	key := exposure.Experiment + "\x00" + exposure.Subject + "\x00" + exposure.Variant
+	if !app.exposures.add(key) {
+		return
+	}
+
+	app.background(func() {
+		err := app.models.Experiments.Record(exposure)
+		if err != nil {
+			app.exposures.forget(key)
+			app.logger.PrintError(err, map[string]string{"experiment": exposure.Experiment})
+		}
+	})
+}
+
+// experimentVariant returns the variant of the named experiment the caller
+// is in, or "" if there is no such experiment.
+func (app *application) experimentVariant(r *http.Request, name string) string {
+	assignments, _ := r.Context().Value(experimentsContextKey).(map[string]string)
+	return assignments[name]
+}
+
+// listExperimentsHandler lists the running experiments with the number of
+// subjects exposed to each variant.
+func (app *application) listExperimentsHandler(w http.ResponseWriter, r *http.Request) {
+	type experimentSummary struct {
+		experiment.Experiment
+		Exposures []*data.VariantExposures `json:"exposures"`
+	}
+
+	summaries := []experimentSummary{}
+
+	for _, e := range app.config.experiments.defined {
+		counts, err := app.models.Experiments.Counts(e.Name)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		summaries = append(summaries, experimentSummary{Experiment: e, Exposures: counts})
+	}
+
+	err := app.writeJSON(w, r, http.StatusOK, envelope{"experiments": summaries}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
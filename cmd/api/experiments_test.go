@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/experiment"
+)
+
+func TestParseExperiments(t *testing.T) {
+	experiments, err := experiment.Parse("search=control:90,fuzzy:10 poster=small,large")
+	assert.NilError(t, err)
+	assert.Equal(t, len(experiments), 2)
+	assert.Equal(t, experiments[0].Variants[1].Weight, 10)
+	assert.Equal(t, experiments[1].Variants[0].Weight, 1)
+
+	for _, spec := range []string{"search", "search=control", "search=a,a", "search=a:0,b", "Search=a,b", "s=a,b s=c,d"} {
+		_, err := experiment.Parse(spec)
+		if err == nil {
+			t.Errorf("%q: want error", spec)
+		}
+	}
+
+	fuzzy := 0
+	for i := 0; i < 1000; i++ {
+		if experiments[0].Assign(fmt.Sprint("user:", i)) == "fuzzy" {
+			fuzzy++
+		}
+	}
+	if fuzzy < 50 || fuzzy > 150 {
+		t.Errorf("got %d of 1000 subjects in a 10%% variant", fuzzy)
+	}
+}
+
+func TestExperimentAssignment(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(editor.ID, "admin:read"); err != nil {
+		t.Fatal(err)
+	}
+
+	app.config.experiments.defined, err = experiment.Parse("search=control,fuzzy poster=small,large")
+	assert.NilError(t, err)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	get := func(token, anonymousID string) http.Header {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/healthcheck", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if anonymousID != "" {
+			req.Header.Set("X-Anonymous-ID", anonymousID)
+		}
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		return rs.Header
+	}
+
+	subject := fmt.Sprint("user:", editor.ID)
+	want := fmt.Sprintf("search=%s, poster=%s", app.config.experiments.defined[0].Assign(subject), app.config.experiments.defined[1].Assign(subject))
+
+	headers := get(token, "")
+	assert.Equal(t, headers.Get("X-Experiments"), want)
+	assert.Equal(t, headers.Get("X-Anonymous-ID"), "")
+
+	headers = get("", "")
+	anonymousID := headers.Get("X-Anonymous-ID")
+	assert.Equal(t, len(anonymousID), 32)
+	assigned := headers.Get("X-Experiments")
+
+	for i := 0; i < 3; i++ {
+		headers = get("", anonymousID)
+		assert.Equal(t, headers.Get("X-Experiments"), assigned)
+		assert.Equal(t, headers.Get("X-Anonymous-ID"), "")
+	}
+
+	app.wg.Wait()
+
+	code, body := ts.do(t, http.MethodGet, "/v1/admin/experiments", token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	experiments := body["experiments"].([]any)
+	assert.Equal(t, len(experiments), 2)
+
+	subjects := 0
+	for _, exposure := range experiments[0].(map[string]any)["exposures"].([]any) {
+		subjects += int(exposure.(map[string]any)["subjects"].(float64))
+	}
+	assert.Equal(t, subjects, 2)
+}
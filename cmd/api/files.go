@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.bcc/internal/imaging"
+	"greenlight.bcc/internal/storage"
+	"greenlight.bcc/internal/validator"
+)
+
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+var totalImageVariants = publishMap("image_variants")
+
+// imageSize is the width and height an image is resized to. Either may be 0
+// to keep the image's aspect ratio, and both are 0 for the original.
+type imageSize struct {
+	width  int
+	height int
+}
+
+func (s imageSize) original() bool {
+	return s.width == 0 && s.height == 0
+}
+
+// imageSizes are the sizes images may be resized to. Anyone can ask for a
+// file, so the sizes are fixed to bound the resizing work and the number of
+// variants of each image.
+var imageSizes = []imageSize{
+	{width: 92}, {width: 185}, {width: 342}, {width: 500}, {width: 780},
+	{width: 64, height: 64}, {width: 128, height: 128}, {width: 256, height: 256},
+}
+
+// imageVariant is a resized copy of a stored image.
+type imageVariant struct {
+	body        []byte
+	contentType string
+}
+
+// showFileHandler serves files from storage, with an ETag from the SHA-256
+// sum of their contents so that clients can revalidate them, and answers
+// range requests. Images are resized to one of imageSizes with the w and h
+// query parameters.
+//
+// Most keys name their contents, and these files are cached for good and
+// revalidated without reading them. Files uploaded through signed URLs may
+// be replaced, so their caching is limited to a day.
+func (app *application) showFileHandler(w http.ResponseWriter, r *http.Request) {
+	key := httprouter.ParamsFromContext(r.Context()).ByName("key")[1:]
+	if strings.HasPrefix(key, debugProfilesPrefix) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	size := readImageSize(r, v)
+	if !size.original() {
+		v.Check(validator.PermittedValue(strings.ToLower(path.Ext(key)), imageExtensions...), "w", "only images can be resized")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+
+	sum := contentHash(key)
+	if sum != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+		etag := fileETag(sum, size)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if !size.original() {
+			if variant, ok := app.imageVariants.Get(variantKey(sum, size)); ok {
+				totalImageVariants.Add("hits", 1)
+				serveContent(w, r, etag, variant.contentType, bytes.NewReader(variant.body))
+				return
+			}
+		}
+	}
+
+	f, err := app.openStoredFile(r.Context(), key, sum)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrInvalidKey):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if size.original() {
+		serveContent(w, r, fileETag(f.sum, size), mime.TypeByExtension(path.Ext(key)), f)
+		return
+	}
+
+	variant, err := app.imageVariant(f, f.sum, size, strings.ToLower(path.Ext(key)))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) || errors.Is(err, errImageTooLarge) {
+			v.AddError("w", "the file cannot be resized, it is not a valid image or has more than 40 megapixels")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	serveContent(w, r, fileETag(f.sum, size), variant.contentType, bytes.NewReader(variant.body))
+}
+
+// serveContent writes content, answering conditional and range requests.
+func serveContent(w http.ResponseWriter, r *http.Request, etag, contentType string, content io.ReadSeeker) {
+	w.Header().Set("ETag", etag)
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
+
+// readImageSize reads the w and h query parameters.
+func readImageSize(r *http.Request, v *validator.Validator) imageSize {
+	query := r.URL.Query()
+	qs := newQueryBinder(query, v)
+
+	size := imageSize{width: qs.Int("w", 0), height: qs.Int("h", 0)}
+
+	if !size.original() && v.Valid() {
+		v.Check(permittedImageSize(size), "w", "must be one of the supported image sizes")
+	}
+
+	return size
+}
+
+func permittedImageSize(size imageSize) bool {
+	for _, permitted := range imageSizes {
+		if size == permitted {
+			return true
+		}
+	}
+	return false
+}
+
+// contentHash returns the hex SHA-256 sum named by a key made by
+// storage.ContentKey, or "" for other keys.
+func contentHash(key string) string {
+	name := strings.TrimSuffix(path.Base(key), path.Ext(key))
+	if len(name) != hex.EncodedLen(sha256.Size) {
+		return ""
+	}
+	if _, err := hex.DecodeString(name); err != nil {
+		return ""
+	}
+	return name
+}
+
+// fileETag returns the strong ETag of a file, or of its variant of size.
+func fileETag(sum string, size imageSize) string {
+	if size.original() {
+		return `"` + sum + `"`
+	}
+	return fmt.Sprintf(`"%s-%dx%d"`, sum, size.width, size.height)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. As the
+// header allows, weak tags match their strong counterparts.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// storedFile is a file from storage which can seek, as ServeContent needs
+// for range requests, along with the hex SHA-256 sum of its contents.
+type storedFile struct {
+	io.ReadSeeker
+	sum   string
+	close func() error
+}
+
+func (f *storedFile) Close() error {
+	return f.close()
+}
+
+// openStoredFile opens the file under key. Files from stores which cannot
+// seek, such as S3, are spooled to a temporary file. The sum of the file is
+// taken as given, if known from its key, or computed while it is read.
+func (app *application) openStoredFile(ctx context.Context, key, sum string) (*storedFile, error) {
+	f, err := app.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		if sum == "" {
+			hash := sha256.New()
+
+			_, err = io.Copy(hash, rs)
+			if err == nil {
+				_, err = rs.Seek(0, io.SeekStart)
+			}
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+
+			sum = hex.EncodeToString(hash.Sum(nil))
+		}
+
+		return &storedFile{ReadSeeker: rs, sum: sum, close: f.Close}, nil
+	}
+
+	defer f.Close()
+
+	tmp, err := os.CreateTemp("", "greenlight-download-*")
+	if err != nil {
+		return nil, err
+	}
+	closeTmp := func() error {
+		defer os.Remove(tmp.Name())
+		return tmp.Close()
+	}
+
+	hash := sha256.New()
+
+	_, err = io.Copy(io.MultiWriter(tmp, hash), f)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		closeTmp()
+		return nil, err
+	}
+
+	if sum == "" {
+		sum = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	return &storedFile{ReadSeeker: tmp, sum: sum, close: closeTmp}, nil
+}
+
+var errImageTooLarge = errors.New("image too large")
+
+func variantKey(sum string, size imageSize) string {
+	return fmt.Sprintf("%s:%dx%d", sum, size.width, size.height)
+}
+
+// imageVariant returns the image read from src, whose contents have the
+// given sum, resized to size. Variants are kept in a small LRU cache, as a
+// page tends to show the same few sizes of each image. JPEG images stay
+// JPEGs, and others become PNGs.
+func (app *application) imageVariant(src io.Reader, sum string, size imageSize, ext string) (imageVariant, error) {
+	key := variantKey(sum, size)
+
+	if variant, ok := app.imageVariants.Get(key); ok {
+		totalImageVariants.Add("hits", 1)
+		return variant, nil
+	}
+	totalImageVariants.Add("misses", 1)
+
+	body, err := io.ReadAll(src)
+	if err != nil {
+		return imageVariant{}, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return imageVariant{}, err
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width*config.Height > maxAvatarPixels {
+		return imageVariant{}, errImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return imageVariant{}, err
+	}
+
+	width, height := fitImage(config.Width, config.Height, size)
+	resized := imaging.Fill(img, width, height)
+
+	var buf bytes.Buffer
+	variant := imageVariant{contentType: "image/png"}
+
+	if ext == ".jpg" || ext == ".jpeg" {
+		variant.contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, resized)
+	}
+	if err != nil {
+		return imageVariant{}, err
+	}
+
+	variant.body = buf.Bytes()
+	app.imageVariants.Set(key, variant)
+
+	return variant, nil
+}
+
+// fitImage returns the dimensions an image of srcWidth by srcHeight pixels
+// is resized to for size. A missing dimension keeps the aspect ratio, and
+// images are never enlarged: a size larger than the image is scaled down
+// to fit it.
+func fitImage(srcWidth, srcHeight int, size imageSize) (int, int) {
+	width, height := size.width, size.height
+
+	switch {
+	case width == 0:
+		width = srcWidth * height / srcHeight
+	case height == 0:
+		height = srcHeight * width / srcWidth
+	}
+
+	if width > srcWidth {
+		height = height * srcWidth / width
+		width = srcWidth
+	}
+	if height > srcHeight {
+		width = width * srcHeight / height
+		height = srcHeight
+	}
+
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	return width, height
+}
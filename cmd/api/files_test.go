@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/storage"
+)
+
+func TestShowFile(t *testing.T) {
+	app := newTestApplication(t)
+
+	var poster bytes.Buffer
+	if err := png.Encode(&poster, image.NewRGBA(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(poster.Bytes())
+
+	key, err := storage.PutContent(context.Background(), app.storage, "posters", ".png", bytes.NewReader(poster.Bytes()), "image/png")
+	assert.NilError(t, err)
+
+	report := "a report which is not stored under its hash"
+	err = app.storage.Put(context.Background(), "exports/report.txt", strings.NewReader(report), int64(len(report)), "text/plain")
+	assert.NilError(t, err)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	get := func(path string, header ...string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, body
+	}
+
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	res, body := get("/v1/files/" + key)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.Header.Get("ETag"), etag)
+	assert.Equal(t, res.Header.Get("Cache-Control"), "public, max-age=31536000, immutable")
+	assert.Equal(t, bytes.Equal(body, poster.Bytes()), true)
+
+	res, _ = get("/v1/files/"+key, "If-None-Match", `"other", `+etag)
+	assert.Equal(t, res.StatusCode, http.StatusNotModified)
+
+	res, body = get("/v1/files/"+key, "Range", "bytes=0-9")
+	assert.Equal(t, res.StatusCode, http.StatusPartialContent)
+	assert.Equal(t, res.Header.Get("Content-Range"), "bytes 0-9/"+strconv.Itoa(poster.Len()))
+	assert.Equal(t, bytes.Equal(body, poster.Bytes()[:10]), true)
+
+	// Files not named by their hash are hashed as they are served.
+	reportSum := sha256.Sum256([]byte(report))
+	res, _ = get("/v1/files/exports/report.txt")
+	assert.Equal(t, res.Header.Get("ETag"), `"`+hex.EncodeToString(reportSum[:])+`"`)
+	assert.Equal(t, res.Header.Get("Cache-Control"), "public, max-age=86400")
+
+	res, _ = get("/v1/files/exports/report.txt", "If-None-Match", res.Header.Get("ETag"))
+	assert.Equal(t, res.StatusCode, http.StatusNotModified)
+
+	for i := 0; i < 2; i++ {
+		res, body = get("/v1/files/" + key + "?w=92")
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+		assert.Equal(t, res.Header.Get("Content-Type"), "image/png")
+		assert.Equal(t, res.Header.Get("ETag"), `"`+hex.EncodeToString(sum[:])+`-92x0"`)
+
+		resized, err := png.DecodeConfig(bytes.NewReader(body))
+		assert.NilError(t, err)
+		assert.Equal(t, resized.Width, 92)
+		assert.Equal(t, resized.Height, 46)
+	}
+	assert.Equal(t, app.imageVariants.Len(), 1)
+
+	// Images are not enlarged.
+	_, body = get("/v1/files/" + key + "?w=256&h=256")
+	resized, err := png.DecodeConfig(bytes.NewReader(body))
+	assert.NilError(t, err)
+	assert.Equal(t, resized.Width, 100)
+	assert.Equal(t, resized.Height, 100)
+
+	// Only the supported sizes can be asked for.
+	for _, query := range []string{"?w=5000", "?w=50", "?w=92&h=92", "?h=185"} {
+		res, _ = get("/v1/files/" + key + query)
+		assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	}
+	assert.Equal(t, app.imageVariants.Len(), 2)
+
+	res, _ = get("/v1/files/exports/report.txt?w=92")
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+}
+
+func TestFitImage(t *testing.T) {
+	tests := []struct {
+		size          imageSize
+		width, height int
+	}{
+		{imageSize{width: 100}, 100, 50},
+		{imageSize{height: 100}, 200, 100},
+		{imageSize{width: 50, height: 50}, 50, 50},
+		{imageSize{width: 800}, 400, 200},
+		{imageSize{width: 300, height: 300}, 200, 200},
+	}
+
+	for _, tt := range tests {
+		width, height := fitImage(400, 200, tt.size)
+		assert.Equal(t, width, tt.width)
+		assert.Equal(t, height, tt.height)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+func (app *application) listFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, "followers", app.models.Follows.GetFollowers)
+}
+
+func (app *application) listFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, "following", app.models.Follows.GetFollowing)
+}
+
+// listFollows writes a page of the user's follows, as fetched by get, under
+// key.
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, key string, get func(int64, data.Filters) ([]*data.Follow, data.Metadata, error)) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.Filters.Page = qs.Int("page", 1)
+	input.Filters.PageSize = qs.Int("page_size", 20)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafelist = []string{"-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.withinQueryCost(w, r, input.Filters) {
+		return
+	}
+
+	follows, metadata, err := get(app.contextGetUser(r).ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{key: follows, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// followUserHandler follows the user in the URL and lets them know, unless
+// they have blocked or muted the follower. Users who have blocked someone
+// cannot be followed by them. Following a user twice is not an error.
+func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	v := validator.New()
+
+	if data.ValidateFollow(v, user.ID, id); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	block, err := app.models.Blocks.Get(id, user.ID)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	case block.Kind == data.BlockKindBlock:
+		v.AddError("user_id", "you cannot follow this user")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Follows.Insert(user.ID, id)
+	switch {
+	case errors.Is(err, data.ErrAlreadyFollowing):
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	case block == nil:
+		app.notify(id, data.NotificationNewFollower, fmt.Sprintf("%s started following you", user.Name))
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "user successfully followed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Follows.Delete(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "user successfully unfollowed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listFollowedGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Follows.GetGenres(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// followGenreHandler follows the genre in the URL. Once a user follows any
+// genre, their feed only shows new movies in the genres they follow.
+func (app *application) followGenreHandler(w http.ResponseWriter, r *http.Request) {
+	genre := httprouter.ParamsFromContext(r.Context()).ByName("genre")
+
+	v := validator.New()
+
+	if data.ValidateGenreFollow(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	follow, err := app.models.Follows.InsertGenre(app.contextGetUser(r).ID, genre)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"genre": follow}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) unfollowGenreHandler(w http.ResponseWriter, r *http.Request) {
+	genre := httprouter.ParamsFromContext(r.Context()).ByName("genre")
+
+	err := app.models.Follows.DeleteGenre(app.contextGetUser(r).ID, genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "genre successfully unfollowed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestFollows(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	recorded := make(chan struct{})
+	sub := app.events.SubscribeAll(64)
+	go func() {
+		app.recordActivities(sub)
+		close(recorded)
+	}()
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fan := &data.User{Name: "Fan", Email: "fan@example.com", Locale: "en", Activated: true}
+	if err := fan.Password.Set("pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(fan); err != nil {
+		t.Fatal(err)
+	}
+	fanToken, err := app.models.Tokens.NewAuthentication(fan.ID, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	followPath := fmt.Sprintf("/v1/users/me/following/%d", editor.ID)
+
+	code, _ := ts.do(t, http.MethodPut, fmt.Sprintf("/v1/users/me/following/%d", fan.ID), fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _ = ts.do(t, http.MethodPut, "/v1/users/me/following/999", fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	for i := 0; i < 2; i++ {
+		code, _ = ts.do(t, http.MethodPut, followPath, fanToken.Plaintext, "")
+		assert.Equal(t, code, http.StatusOK)
+	}
+
+	app.wg.Wait()
+
+	code, body := ts.do(t, http.MethodGet, "/v1/users/me/notifications", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	notifications := body["notifications"].([]any)
+	assert.Equal(t, len(notifications), 1)
+	assert.Equal(t, fmt.Sprint(notifications[0].(map[string]any)["message"]), "Fan started following you")
+
+	followed, err := app.models.Users.Get(editor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, followed.FollowersCount, 1)
+	assert.Equal(t, followed.FollowingCount, 0)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/followers", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	followers := body["followers"].([]any)
+	assert.Equal(t, len(followers), 1)
+	assert.Equal(t, fmt.Sprint(followers[0].(map[string]any)["name"]), "Fan")
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/following", fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["following"].([]any)), 1)
+
+	// Following a genre limits new movies in the feed to that genre.
+	code, _ = ts.do(t, http.MethodPut, "/v1/users/me/genres/drama", fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/genres", fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["genres"].([]any)), 1)
+
+	for _, movie := range []*data.Movie{
+		{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusDraft, OrgID: 1},
+		{Title: "Whiplash", Year: 2014, Runtime: 107, Genres: []string{"drama"}, Status: data.MovieStatusDraft, OrgID: 1},
+	} {
+		if err := app.models.Movies.Insert(movie); err != nil {
+			t.Fatal(err)
+		}
+		code, _ = ts.do(t, http.MethodPut, fmt.Sprintf("/v1/movies/%d/status", movie.ID), token, `{"status": "published"}`)
+		assert.Equal(t, code, http.StatusOK)
+	}
+
+	app.events.Close()
+	<-recorded
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/feed", fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	activities := body["activities"].([]any)
+	assert.Equal(t, len(activities), 1)
+	assert.Equal(t, fmt.Sprint(activities[0].(map[string]any)["data"].(map[string]any)["title"]), "Whiplash")
+
+	code, _ = ts.do(t, http.MethodDelete, "/v1/users/me/genres/drama", fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodDelete, "/v1/users/me/genres/drama", fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Blocking a follower ends their follow and stops them following again.
+	code, _ = ts.do(t, http.MethodPut, fmt.Sprintf("/v1/users/me/blocks/%d", fan.ID), token, `{"kind": "block"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	followed, err = app.models.Users.Get(editor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, followed.FollowersCount, 0)
+
+	code, _ = ts.do(t, http.MethodPut, followPath, fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _ = ts.do(t, http.MethodDelete, followPath, fanToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/geoip"
+	"greenlight.bcc/internal/mailer"
+)
+
+// mmdbString encodes s as a MaxMind DB string of fewer than 29 bytes.
+func mmdbString(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+// mmdbMap encodes a map of n pairs, which must follow it.
+func mmdbMap(n int) []byte {
+	return []byte{7<<5 | byte(n)}
+}
+
+// newTestMMDB builds an IPv4 database, with 24 bit records, which knows
+// only that 81.0.0.0/8 is in Paris.
+func newTestMMDB() []byte {
+	const prefix, bits, nodeCount = 81, 8, 8
+
+	var file []byte
+
+	for i := 0; i < bits; i++ {
+		next := i + 1
+		if next == bits {
+			next = nodeCount + 16
+		}
+		records := [2]int{nodeCount, nodeCount}
+		records[prefix>>(bits-1-i)&1] = next
+
+		for _, record := range records {
+			file = append(file, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+
+	file = append(file, make([]byte, 16)...)
+
+	record := [][]byte{
+		mmdbMap(2),
+		mmdbString("country"), mmdbMap(1), mmdbString("iso_code"), mmdbString("FR"),
+		mmdbString("city"), mmdbMap(1), mmdbString("names"), mmdbMap(1), mmdbString("en"), mmdbString("Paris"),
+	}
+	file = append(file, bytes.Join(record, nil)...)
+
+	metadata := [][]byte{
+		[]byte("\xab\xcd\xefMaxMind.com"),
+		mmdbMap(3),
+		mmdbString("node_count"), {6<<5 | 1, nodeCount},
+		mmdbString("record_size"), {5<<5 | 1, 24},
+		mmdbString("ip_version"), {5<<5 | 1, 4},
+	}
+	return append(file, bytes.Join(metadata, nil)...)
+}
+
+func TestGeoIPLocate(t *testing.T) {
+	db, err := geoip.New(newTestMMDB())
+	assert.NilError(t, err)
+
+	tests := []struct {
+		ip   string
+		want geoip.Location
+	}{
+		{"81.2.69.160", geoip.Location{Country: "FR", City: "Paris"}},
+		{"81.255.255.255", geoip.Location{Country: "FR", City: "Paris"}},
+		{"80.2.69.160", geoip.Location{}},
+		{"10.0.0.1", geoip.Location{}},
+		{"2001:db8::1", geoip.Location{}},
+	}
+
+	for _, tt := range tests {
+		got, err := db.Locate(net.ParseIP(tt.ip))
+		assert.NilError(t, err)
+		assert.Equal(t, got, tt.want)
+	}
+
+	_, err = geoip.New([]byte("not a database"))
+	assert.StringContains(t, err.Error(), "malformed")
+}
+
+// fixedLocator places every address in the same, changeable location.
+type fixedLocator struct {
+	location *geoip.Location
+}
+
+func (l fixedLocator) Locate(ip net.IP) (geoip.Location, error) {
+	return *l.location, nil
+}
+
+func TestLoginNewCountry(t *testing.T) {
+	var buf bytes.Buffer
+
+	location := &geoip.Location{Country: "FR", City: "Paris"}
+
+	app, token := newMemoryTestApplication(t)
+	app.geoip = fixedLocator{location}
+	app.mailer = mailer.New(mailer.NewLog(&buf), "test@example.com", time.Second, 0)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	login := func() {
+		t.Helper()
+		code, _ := ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", `{"email": "editor@example.com", "password": "pa55word"}`)
+		assert.Equal(t, code, http.StatusCreated)
+		app.wg.Wait()
+	}
+
+	login()
+	login()
+	assert.Equal(t, strings.Contains(buf.String(), "new country"), false)
+
+	location.Country, location.City = "DE", "Berlin"
+	login()
+	assert.StringContains(t, buf.String(), "Login to your Greenlight account from a new country")
+	assert.StringContains(t, buf.String(), "Berlin, DE")
+
+	code, body := ts.do(t, http.MethodGet, "/v1/users/me/security/events", token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	events := body["events"].([]any)
+	assert.Equal(t, len(events), 4)
+
+	anomaly := events[0].(map[string]any)
+	assert.Equal(t, anomaly["kind"].(string), data.SecurityLoginNewCountry)
+	assert.Equal(t, anomaly["country"].(string), "DE")
+	assert.Equal(t, anomaly["city"].(string), "Berlin")
+}
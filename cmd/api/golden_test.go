@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+// TestResponseShapes compares responses with the golden files in testdata,
+// so that changes to their shape are noticed. Run with -update after a
+// deliberate change.
+func TestResponseShapes(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	request := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := request(http.MethodPost, "/v1/movies", `{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": ["animation", "adventure"]}`)
+	assert.Header(t, res, "Location", "/v1/movies/3")
+	assert.GoldenResponse(t, "movie_created.json", res, http.StatusCreated, "uuid")
+
+	res = request(http.MethodGet, "/v1/movies/3", "")
+	assert.GoldenResponse(t, "movie.json", res, http.StatusOK, "uuid")
+
+	res = request(http.MethodPost, "/v1/movies", `{"title": "", "year": 3000, "runtime": "107 mins", "genres": ["drama"]}`)
+	assert.GoldenResponse(t, "movie_invalid.json", res, http.StatusUnprocessableEntity)
+
+	res = request(http.MethodGet, "/v1/movies/999", "")
+	assert.GoldenResponse(t, "not_found.json", res, http.StatusNotFound)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"net/http"
+
+	"greenlight.bcc/internal/breaker"
 )
 
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -14,8 +16,42 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		},
 	}
 
-	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if len(app.breakers) > 0 {
+		states := app.breakerStates()
+		for _, state := range states {
+			if state != breaker.StateClosed {
+				env["status"] = "degraded"
+			}
+		}
+		env["circuit_breakers"] = states
+	}
+
+	err := app.writeJSON(w, r, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if app.draining.Load() {
+		err := app.writeJSON(w, r, http.StatusServiceUnavailable, envelope{"status": "draining"}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err := app.writeJSON(w, r, http.StatusOK, envelope{"status": "ready"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// breakerStates maps the name of each circuit breaker to its state.
+func (app *application) breakerStates() map[string]string {
+	states := make(map[string]string, len(app.breakers))
+	for _, b := range app.breakers {
+		states[b.Name()] = b.State()
+	}
+	return states
+}
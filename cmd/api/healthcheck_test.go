@@ -3,6 +3,8 @@ package main
 import (
 	"net/http"
 	"testing"
+
+	"greenlight.bcc/internal/assert"
 )
 
 func TestHealthcheck(t *testing.T) {
@@ -23,3 +25,19 @@ func TestHealthcheck(t *testing.T) {
 		t.Errorf("want body to equal %q,\n but got %q", expResp, string(body))
 	}
 }
+
+func TestReadyz(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/v1/readyz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"status":"ready"`)
+
+	app.draining.Store(true)
+
+	code, _, body = ts.get(t, "/v1/readyz")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.StringContains(t, body, `"status":"draining"`)
+}
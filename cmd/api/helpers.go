@@ -5,12 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
-	"greenlight.bcc/internal/validator"
+	"greenlight.bcc/internal/captcha"
+	"greenlight.bcc/internal/msgpack"
 	"io"
 	"net/http"
-	"net/url"
 	"strconv"
-	"strings"
+	"time"
 )
 
 type envelope map[string]any
@@ -25,106 +25,162 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
-func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+func (app *application) readVersionParam(r *http.Request) (int32, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	version, err := strconv.ParseInt(params.ByName("version"), 10, 32)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid version parameter")
+	}
 
-	js, err := json.Marshal(data)
-	if err != nil {
-		return err
+	return int32(version), nil
+}
+
+func (app *application) readMovieIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("movie_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid movie_id parameter")
+	}
+
+	return id, nil
+}
+
+func (app *application) readVideoIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("video_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid video_id parameter")
 	}
 
-	js = append(js, '\n')
+	return id, nil
+}
+
+// writeJSON writes data as the response. List responses get a Link header
+// for their pages. Successful responses lose their envelope if the client
+// asked for that, and otherwise link to the actions on the movie or user
+// they hold; errors always keep the envelope. Clients whose Accept header
+// prefers MessagePack get the same body encoded as MessagePack.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data envelope, headers http.Header) error {
+	// The deprecation middleware may have set a Link header already, so the
+	// page links are added to it rather than going through headers.
+	addPaginationLinks(r, data, w.Header())
+
+	if status < 400 {
+		app.addThumbnails(data)
+	}
+
+	var body any = data
+	switch {
+	case status >= 400:
+	case wantsEnvelope(r):
+		err := app.addResourceLinks(r, data)
+		if err != nil {
+			return err
+		}
+	default:
+		if headers == nil {
+			headers = make(http.Header)
+		}
+		body = unwrapEnvelope(data, headers)
+	}
+
+	contentType := "application/json"
+
+	var payload []byte
+	var err error
+
+	if wantsMsgpack(r) {
+		contentType = mediaTypeMsgpack
+		payload, err = msgpack.Marshal(body)
+		if err != nil {
+			return err
+		}
+	} else {
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = append(payload, '\n')
+	}
 
 	for key, value := range headers {
 		w.Header()[key] = value
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 
-	w.Write(js)
+	w.Write(payload)
 
 	return nil
 }
 
-func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+// readJSON decodes the request body into dst. By default unknown keys,
+// duplicate keys and more than 32 levels of nesting are rejected; opts
+// relax or tighten these for a single endpoint. Errors describing a bad
+// body are *jsonError values.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any, opts ...jsonOption) error {
+	options := jsonOptions{maxBytes: defaultJSONMaxBytes, maxDepth: defaultJSONMaxDepth}
+	for _, opt := range opts {
+		opt(&options)
+	}
 
-	maxBytes := 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
+	r.Body = http.MaxBytesReader(w, r.Body, options.maxBytes)
 
-	err := dec.Decode(dst)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
 		var maxBytesError *http.MaxBytesError
-
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
-		case errors.As(err, &unmarshalTypeError):
-			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		if errors.As(err, &maxBytesError) {
+			return &jsonError{
+				Message: fmt.Sprintf("body must not be larger than %d bytes", maxBytesError.Limit),
+				Offset:  maxBytesError.Limit,
 			}
-			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
-		case strings.HasPrefix(err.Error(), "json: unknown field "):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
-		case errors.As(err, &maxBytesError):
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
-		case errors.As(err, &invalidUnmarshalError):
-			panic(err)
-		default:
-			return err
 		}
+		return err
 	}
 
-	err = dec.Decode(&struct{}{})
-	if err != io.EOF {
-		return errors.New("body must only contain a single JSON value")
+	// MessagePack bodies are converted to JSON, so that they are decoded
+	// and checked exactly like JSON ones.
+	if isMsgpack(mediaType(r)) && len(body) > 0 {
+		body, err = msgpack.ToJSON(body)
+		if err != nil {
+			var syntaxError *msgpack.SyntaxError
+			if errors.As(err, &syntaxError) {
+				return &jsonError{
+					Message: fmt.Sprintf("body contains badly-formed MessagePack (at byte %d)", syntaxError.Offset),
+					Offset:  syntaxError.Offset,
+				}
+			}
+			return err
+		}
 	}
 
-	return nil
+	return decodeJSON(body, dst, options)
 }
 
-func (app *application) readString(qs url.Values, key string, defaultValue string) string {
-	s := qs.Get(key)
-
-	if s == "" {
-		return defaultValue
+// notModified reports whether the client's If-Modified-Since header shows it
+// already has the representation last changed at lastModified. HTTP dates
+// only have second precision.
+func notModified(r *http.Request, lastModified time.Time) bool {
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
 	}
-
-	return s
+	return !lastModified.Truncate(time.Second).After(since)
 }
 
-func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
-
-	csv := qs.Get(key)
-
-	if csv == "" {
-		return defaultValue
+func (app *application) userHasPermission(r *http.Request, code string) (bool, error) {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return false, nil
 	}
 
-	return strings.Split(csv, ",")
-}
-
-func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
-	s := qs.Get(key)
-	if s == "" {
-		return defaultValue
-	}
-	i, err := strconv.Atoi(s)
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
 	if err != nil {
-		v.AddError(key, "must be an integer value")
-		return defaultValue
+		return false, err
 	}
 
-	return i
+	return permissions.Include(code), nil
 }
 
 func (app *application) background(fn func()) {
@@ -140,3 +196,46 @@ func (app *application) background(fn func()) {
 		fn()
 	}()
 }
+
+// passwordBreached reports whether password is known from a data breach.
+// The check fails open: if the breach service cannot be reached in time
+// the error is logged and the password accepted.
+func (app *application) passwordBreached(r *http.Request, password string) bool {
+	if app.pwned == nil {
+		return false
+	}
+
+	breached, err := app.pwned.Breached(r.Context(), password)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"check": "password breach"})
+		return false
+	}
+
+	return breached
+}
+
+// verifyCaptcha checks the captcha token supplied with the request, writing
+// an error response and returning false if verification did not succeed.
+func (app *application) verifyCaptcha(w http.ResponseWriter, r *http.Request, token string) bool {
+	if app.captcha == nil {
+		return true
+	}
+
+	var clientIP string
+	if meta := app.contextGetRequestMeta(r); meta != nil {
+		clientIP = meta.clientIP
+	}
+
+	err := app.captcha.Verify(token, clientIP)
+	if err != nil {
+		switch {
+		case errors.Is(err, captcha.ErrFailed):
+			app.captchaFailedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return false
+	}
+
+	return true
+}
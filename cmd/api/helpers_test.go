@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestReadJSON(t *testing.T) {
+	app := newTestApplication(t)
+
+	type item struct {
+		ID      int64          `json:"id"`
+		Changes map[string]any `json:"changes"`
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		opts        []jsonOption
+		wantMessage string
+		wantField   string
+		wantOffset  int64
+	}{
+		{
+			name: "Valid",
+			body: `{"title": "Moana", "items": [{"id": 1, "changes": {"year": 2016}}]}`,
+		},
+		{
+			name:        "Empty",
+			body:        ` `,
+			wantMessage: "body must not be empty",
+		},
+		{
+			name:        "Badly-formed",
+			body:        `{"title": "Moana",}`,
+			wantMessage: "body contains badly-formed JSON (at character 19)",
+			wantOffset:  19,
+		},
+		{
+			name:        "Unknown key",
+			body:        `{"title": "Moana", "rating": 5}`,
+			wantMessage: `body contains unknown key "rating"`,
+			wantField:   "rating",
+			wantOffset:  19,
+		},
+		{
+			name: "Unknown key allowed",
+			body: `{"title": "Moana", "rating": 5}`,
+			opts: []jsonOption{allowUnknownFields()},
+		},
+		{
+			name:        "Duplicate key",
+			body:        `{"title": "Moana", "title": "Frozen"}`,
+			wantMessage: `body contains duplicate key "title" (at character 19)`,
+			wantField:   "title",
+			wantOffset:  19,
+		},
+		{
+			name:        "Nested duplicate key",
+			body:        `{"items": [{"id": 1}, {"id": 2, "id": 3}]}`,
+			wantMessage: `body contains duplicate key "id" (at character 32)`,
+			wantField:   "items[1].id",
+			wantOffset:  32,
+		},
+		{
+			name:        "Too deep",
+			body:        `{"items": [{"id": 1, "changes": {"a": [[1]]}}]}`,
+			opts:        []jsonOption{maxJSONDepth(4)},
+			wantMessage: "body must not be nested more than 4 levels deep (at character 38)",
+			wantField:   "items[0].changes.a",
+			wantOffset:  38,
+		},
+		{
+			name:        "Number out of range",
+			body:        `{"items": [{"id": 1, "changes": {"year": 1e400}}]}`,
+			wantMessage: "body contains out of range number 1e400 (at character 41)",
+			wantField:   "items[0].changes.year",
+			wantOffset:  41,
+		},
+		{
+			name:        "Wrong type",
+			body:        `{"title": 5}`,
+			wantMessage: `body contains incorrect JSON type for field "title"`,
+			wantField:   "title",
+			wantOffset:  11,
+		},
+		{
+			name:        "Two values",
+			body:        `{"title": "Moana"} {}`,
+			wantMessage: "body must only contain a single JSON value",
+			wantOffset:  19,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input struct {
+				Title string `json:"title"`
+				Items []item `json:"items"`
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			err := app.readJSON(httptest.NewRecorder(), r, &input, tt.opts...)
+
+			if tt.wantMessage == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var jsonErr *jsonError
+			if !errors.As(err, &jsonErr) {
+				t.Fatalf("got %v; want a *jsonError", err)
+			}
+
+			assert.Equal(t, jsonErr.Message, tt.wantMessage)
+			assert.Equal(t, jsonErr.Field, tt.wantField)
+			assert.Equal(t, jsonErr.Offset, tt.wantOffset)
+		})
+	}
+}
+
+func TestReadJSONNumberPrecision(t *testing.T) {
+	app := newTestApplication(t)
+
+	for _, opts := range [][]jsonOption{nil, {useJSONNumber()}} {
+		var input map[string]any
+
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id": 9007199254740993}`))
+		err := app.readJSON(httptest.NewRecorder(), r, &input, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch id := input["id"].(type) {
+		case float64:
+			assert.Equal(t, len(opts), 0)
+		case json.Number:
+			assert.Equal(t, id.String(), "9007199254740993")
+		default:
+			t.Fatalf("unexpected type %T", id)
+		}
+	}
+}
+
+func TestBadRequestResponseJSONError(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	code, _, body := ts.postForm(t, "/v1/movies", []byte(`{"title": "Moana", "title": "Frozen"}`))
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	var resp struct {
+		Error jsonError `json:"error"`
+	}
+	err := json.Unmarshal([]byte(body), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, resp.Error.Field, "title")
+	assert.Equal(t, resp.Error.Offset, int64(19))
+}
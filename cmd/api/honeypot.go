@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHoneypotPaths are paths no client of the API has reason to
+// request, but which scanners looking for vulnerable software routinely do.
+var defaultHoneypotPaths = []string{
+	"/wp-login.php",
+	"/xmlrpc.php",
+	"/.env",
+	"/.git/config",
+	"/phpmyadmin/",
+}
+
+// maxTarpitted bounds how many honeypot requests are held open at once, so
+// a flood of them cannot tie up the server. Beyond it, they are answered
+// straight away.
+const maxTarpitted = 256
+
+// denylist holds client IPs which are refused until a deadline. It is safe
+// for concurrent use, and a nil denylist ignores additions and refuses
+// nobody.
+type denylist struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newDenylist() *denylist {
+	return &denylist{until: make(map[string]time.Time)}
+}
+
+// add refuses ip until duration from now, unless it is already refused for longer.
+func (d *denylist) add(ip string, duration time.Duration, now time.Time) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if until := now.Add(duration); until.After(d.until[ip]) {
+		d.until[ip] = until
+	}
+
+	// Expired entries are otherwise only removed when their IP returns.
+	if len(d.until) > 10000 {
+		for ip, until := range d.until {
+			if !until.After(now) {
+				delete(d.until, ip)
+			}
+		}
+	}
+}
+
+// refused reports whether ip is refused at now, and until when.
+func (d *denylist) refused(ip string, now time.Time) (time.Time, bool) {
+	if d == nil {
+		return time.Time{}, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	until, ok := d.until[ip]
+	if ok && !until.After(now) {
+		delete(d.until, ip)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+// honeypot answers requests for the configured honeypot paths itself: it
+// logs the caller, denylists their IP so that the rate limiter refuses
+// their other requests for a while, and waits before answering, to waste
+// the scanner's time rather than ours.
+func (app *application) honeypot(next http.Handler) http.Handler {
+	cfg := app.config.honeypot
+	if len(cfg.paths) == 0 {
+		return next
+	}
+
+	paths := make(map[string]bool, len(cfg.paths))
+	for _, path := range cfg.paths {
+		paths[path] = true
+	}
+
+	honeypotHits := publishMap("abuse_honeypot_hits")
+	tarpitted := publishInt("abuse_tarpitted")
+
+	slots := make(chan struct{}, maxTarpitted)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !paths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		honeypotHits.Add(r.URL.Path, 1)
+
+		properties := map[string]string{
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"user_agent": r.UserAgent(),
+		}
+
+		if ip, err := app.clientIP(r); err == nil {
+			properties["ip"] = ip.String()
+			if cfg.banDuration > 0 {
+				app.denylist.add(ip.String(), cfg.banDuration, time.Now())
+			}
+		}
+
+		app.logger.PrintInfo("honeypot hit", properties)
+
+		select {
+		case slots <- struct{}{}:
+			tarpitted.Add(1)
+
+			timer := time.NewTimer(cfg.delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+			}
+
+			tarpitted.Add(-1)
+			<-slots
+		default:
+		}
+
+		app.notFoundResponse(w, r)
+	})
+}
+
+func parseHoneypotPaths(val string) []string {
+	paths := strings.Fields(val)
+	for i, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			paths[i] = "/" + path
+		}
+	}
+	return paths
+}
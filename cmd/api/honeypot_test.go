@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"expvar"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/jsonlog"
+)
+
+func TestHoneypot(t *testing.T) {
+	var buf bytes.Buffer
+
+	app := newTestApplication(t)
+	app.logger = jsonlog.New(&buf, jsonlog.LevelInfo)
+	app.config.honeypot.paths = parseHoneypotPaths("wp-login.php /.env")
+	app.config.honeypot.banDuration = time.Minute
+	app.config.honeypot.delay = 50 * time.Millisecond
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/v1/healthcheck")
+	assert.Equal(t, code, http.StatusOK)
+
+	start := time.Now()
+	code, _, _ = ts.get(t, "/.env")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, time.Since(start) >= app.config.honeypot.delay, true)
+
+	assert.StringContains(t, buf.String(), `"message":"honeypot hit"`)
+	assert.StringContains(t, buf.String(), `"path":"/.env"`)
+	assert.Equal(t, expvar.Get("abuse_honeypot_hits").(*expvar.Map).Get("/.env").String(), "1")
+
+	code, header, _ := ts.get(t, "/v1/healthcheck")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("Retry-After"), "60")
+	assert.Equal(t, expvar.Get("abuse_denylist_refusals").String(), "1")
+
+	_, refused := app.denylist.refused("127.0.0.1", time.Now().Add(2*time.Minute))
+	assert.Equal(t, refused, false)
+	_, refused = app.denylist.refused("127.0.0.1", time.Now())
+	assert.Equal(t, refused, false)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	// maxImportMovies bounds the movies in one import, which are inserted
+	// in a single transaction.
+	maxImportMovies = 1000
+	// importJSONMaxBytes leaves room for maxImportMovies movies with long
+	// synopses.
+	importJSONMaxBytes = 16 << 20
+)
+
+// importMoviesHandler creates many draft movies at once. The movies are
+// inserted together, with COPY when the pgx driver is used, so either all
+// of them are created or none are.
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Movies []struct {
+			Title     string       `json:"title"`
+			Year      int32        `json:"year"`
+			Runtime   data.Runtime `json:"runtime"`
+			Genres    []string     `json:"genres"`
+			IMDbID    string       `json:"imdb_id"`
+			TMDbID    int64        `json:"tmdb_id"`
+			Synopsis  string       `json:"synopsis"`
+			Tagline   string       `json:"tagline"`
+			AgeRating string       `json:"age_rating"`
+		} `json:"movies"`
+	}
+
+	err := app.readJSON(w, r, &input, maxJSONBytes(importJSONMaxBytes))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(input.Movies) >= 1, "movies", "must contain at least 1 movie")
+	v.Check(len(input.Movies) <= maxImportMovies, "movies", fmt.Sprintf("must not contain more than %d movies", maxImportMovies))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orgID := app.contextGetUser(r).OrgID
+	movies := make([]*data.Movie, len(input.Movies))
+
+	for i, item := range input.Movies {
+		movie := &data.Movie{
+			Title:     item.Title,
+			Year:      item.Year,
+			Runtime:   item.Runtime,
+			Genres:    item.Genres,
+			Status:    data.MovieStatusDraft,
+			OrgID:     orgID,
+			IMDbID:    item.IMDbID,
+			TMDbID:    item.TMDbID,
+			Synopsis:  item.Synopsis,
+			Tagline:   item.Tagline,
+			AgeRating: item.AgeRating,
+		}
+
+		mv := validator.New()
+		data.ValidateMovie(mv, movie)
+		for key, message := range mv.Errors {
+			v.AddError(fmt.Sprintf("movies[%d].%s", i, key), message)
+		}
+
+		movies[i] = movie
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkQuotaFor(w, r, quotaMovies, len(movies)) {
+		return
+	}
+
+	// The UUIDs are chosen here, as the IDs of copied rows are read back
+	// by them.
+	for _, movie := range movies {
+		movie.UUID, err = data.NewUUID(app.config.movies.uuidStrategy)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Movies.InsertMany(movies)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateIMDbID):
+			v.AddError("movies", "must not contain an IMDb ID which another movie has")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateTMDbID):
+			v.AddError("movies", "must not contain a TMDb ID which another movie has")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, movie := range movies {
+		app.publishMovieEvent(events.TypeMovieCreated, movie)
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"movies": movies}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestImportMovies(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	count := func() int {
+		t.Helper()
+		used, err := app.models.Movies.Count(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return used
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantError string
+		wantCount int
+	}{
+		{
+			name:      "Valid movies",
+			body:      `{"movies": [{"title": "Moana", "year": 2016, "runtime": "107 mins", "imdb_id": "tt3521164"}, {"title": "Coco", "year": 2017, "runtime": "105 mins"}]}`,
+			wantCode:  http.StatusCreated,
+			wantCount: 2,
+		},
+		{
+			name:      "Invalid movie",
+			body:      `{"movies": [{"title": "Arrival", "year": 2016, "runtime": "116 mins"}, {"year": 2016, "runtime": "116 mins"}]}`,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "movies[1].title",
+			wantCount: 2,
+		},
+		{
+			name:      "Duplicate IMDb ID",
+			body:      `{"movies": [{"title": "Arrival", "year": 2016, "runtime": "116 mins"}, {"title": "Moana", "year": 2016, "runtime": "107 mins", "imdb_id": "tt3521164"}]}`,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "IMDb ID",
+			wantCount: 2,
+		},
+		{
+			name:      "No movies",
+			body:      `{"movies": []}`,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "at least 1 movie",
+			wantCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := ts.do(t, http.MethodPost, "/v1/movies/import", token, tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantError != "" {
+				assert.StringContains(t, fmt.Sprint(body["error"]), tt.wantError)
+			}
+			assert.Equal(t, count(), tt.wantCount)
+		})
+	}
+
+	t.Run("Over quota", func(t *testing.T) {
+		app.config.quotas.moviesPerOrg = 3
+
+		code, body := ts.do(t, http.MethodPost, "/v1/movies/import", token, `{"movies": [{"title": "Arrival", "year": 2016, "runtime": "116 mins"}, {"title": "Up", "year": 2009, "runtime": "96 mins"}]}`)
+		assert.Equal(t, code, http.StatusForbidden)
+		assert.StringContains(t, fmt.Sprint(body["error"]), "quota_exceeded")
+		assert.Equal(t, count(), 2)
+	})
+}
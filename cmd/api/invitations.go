@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/mailer"
+	"greenlight.bcc/internal/validator"
+)
+
+func (app *application) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email  string `json:"email"`
+		Locale string `json:"locale"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Locale == "" {
+		input.Locale = mailer.DefaultLocale
+	}
+
+	v := validator.New()
+	data.ValidateEmail(v, input.Email)
+	v.Check(validator.Matches(input.Locale, data.LocaleRX), "locale", "must be a language code such as en or pt-BR")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	invitation, err := app.models.Invitations.New(input.Email, app.contextGetUser(r).ID, app.config.registration.inviteTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		data := map[string]any{
+			"invitationCode": invitation.Plaintext,
+			"expiry":         invitation.Expiry.UTC().Format(time.RFC1123),
+		}
+
+		err := app.mailer.Send(invitation.Email, input.Locale, "user_invitation.tmpl", data)
+		if err != nil && !errors.Is(err, mailer.ErrSuppressed) {
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
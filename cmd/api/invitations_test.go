@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/mailer"
+)
+
+func TestCreateInvitation(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantBody    string
+		wantSubject string
+	}{
+		{
+			name:        "Valid invitation",
+			body:        `{"email": "friend@example.com"}`,
+			wantCode:    http.StatusCreated,
+			wantBody:    `"email":"friend@example.com"`,
+			wantSubject: "Subject: You're invited to Greenlight",
+		},
+		{
+			name:        "French invitation",
+			body:        `{"email": "ami@example.com", "locale": "fr"}`,
+			wantCode:    http.StatusCreated,
+			wantSubject: "Subject: Vous êtes invité sur Greenlight",
+		},
+		{
+			name:     "Invalid email",
+			body:     `{"email": "not-an-email"}`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Badly-formed body",
+			body:     `{"email": `,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			app := newTestApplication(t)
+			app.mailer = mailer.New(mailer.NewLog(&buf), "test@example.com", time.Second, 0)
+			app.config.registration.inviteTTL = time.Hour
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/admin/invitations", strings.NewReader(tt.body))
+			r = app.contextSetUser(r, &data.User{ID: 3, Activated: true})
+
+			app.createInvitationHandler(w, r)
+			app.wg.Wait()
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+			if tt.wantSubject != "" {
+				assert.StringContains(t, buf.String(), tt.wantSubject)
+			}
+		})
+	}
+
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		app := newBrokenTestApplication(t)
+		app.config.registration.inviteTTL = time.Hour
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/v1/admin/invitations", strings.NewReader(`{"email": "friend@example.com"}`))
+		r = app.contextSetUser(r, &data.User{ID: 3, Activated: true})
+
+		app.createInvitationHandler(w, r)
+		app.wg.Wait()
+
+		assert.Equal(t, w.Code, http.StatusInternalServerError)
+	})
+}
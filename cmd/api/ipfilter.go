@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseCIDRs parses a space separated list of CIDR ranges. Bare IP addresses
+// are treated as single-host ranges.
+func parseCIDRs(val string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, field := range strings.Fields(val) {
+		if !strings.Contains(field, "/") {
+			ip := net.ParseIP(field)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", field)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client which made the request. The
+// X-Forwarded-For and X-Real-IP headers are only honoured when the request
+// arrived from a trusted proxy. For X-Forwarded-For the right-most address
+// which is not itself a trusted proxy is used.
+func (app *application) clientIP(r *http.Request) (net.IP, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid remote address %q", r.RemoteAddr)
+	}
+
+	if !containsIP(app.config.proxies.trusted, ip) {
+		return ip, nil
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+			return realIP, nil
+		}
+		return ip, nil
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !containsIP(app.config.proxies.trusted, hop) {
+			break
+		}
+	}
+
+	return ip, nil
+}
+
+func isRestrictedPath(path string) bool {
+	return strings.HasPrefix(path, "/v1/admin/") || path == "/debug/vars" || path == "/metrics" ||
+		strings.HasPrefix(path, "/debug/pprof/")
+}
+
+func (app *application) restrictIPs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isRestrictedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip, err := app.clientIP(r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if containsIP(app.config.admin.denyCIDRs, ip) {
+			app.ipNotAllowedResponse(w, r)
+			return
+		}
+
+		if len(app.config.admin.allowCIDRs) > 0 && !containsIP(app.config.admin.allowCIDRs, ip) {
+			app.ipNotAllowedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultJSONMaxBytes = 1_048_576
+	defaultJSONMaxDepth = 32
+)
+
+// jsonError describes a request body which could not be decoded. Field is
+// the path of the offending key (e.g. "items[2].title") when known, and
+// Offset is the byte offset in the body at which the problem was found.
+type jsonError struct {
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	Offset  int64  `json:"offset,omitempty"`
+}
+
+func (e *jsonError) Error() string {
+	return e.Message
+}
+
+type jsonOptions struct {
+	maxBytes           int64
+	maxDepth           int
+	allowUnknownFields bool
+	useNumber          bool
+}
+
+// jsonOption customises how readJSON decodes a single endpoint's body.
+type jsonOption func(*jsonOptions)
+
+// allowUnknownFields lets the body contain keys which dst has no field for.
+// They are silently dropped.
+func allowUnknownFields() jsonOption {
+	return func(o *jsonOptions) {
+		o.allowUnknownFields = true
+	}
+}
+
+// maxJSONBytes limits the size of the body, for endpoints which take more
+// than the default.
+func maxJSONBytes(n int64) jsonOption {
+	return func(o *jsonOptions) {
+		o.maxBytes = n
+	}
+}
+
+// maxJSONDepth limits how deeply objects and arrays may be nested.
+func maxJSONDepth(depth int) jsonOption {
+	return func(o *jsonOptions) {
+		o.maxDepth = depth
+	}
+}
+
+// useJSONNumber decodes numbers into interface values as json.Number rather
+// than float64, so large integers keep their precision.
+func useJSONNumber() jsonOption {
+	return func(o *jsonOptions) {
+		o.useNumber = true
+	}
+}
+
+// jsonFrame tracks an object or array which scanJSON is inside.
+type jsonFrame struct {
+	object    bool
+	keys      map[string]bool
+	key       string
+	expectKey bool
+	index     int
+}
+
+// jsonPath renders the location of the value currently being scanned.
+func jsonPath(stack []*jsonFrame) string {
+	var b bytes.Buffer
+	for _, frame := range stack {
+		if frame.object {
+			if frame.expectKey {
+				break
+			}
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(frame.key)
+			continue
+		}
+		fmt.Fprintf(&b, "[%d]", frame.index)
+	}
+	return b.String()
+}
+
+// tokenStart returns the offset of the first token at or after offset,
+// skipping the whitespace and separators json.Decoder.InputOffset leaves
+// in front of it.
+func tokenStart(body []byte, offset int64) int64 {
+	for offset < int64(len(body)) {
+		switch body[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// scanJSON walks the tokens of body before it is decoded, rejecting
+// duplicate keys, nesting deeper than opts.maxDepth and numbers which can
+// not be represented. Syntax errors are left for the decoder to report. It
+// returns the offset at which each key name first appears.
+func scanJSON(body []byte, opts jsonOptions) (map[string]int64, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var stack []*jsonFrame
+	keyOffsets := map[string]int64{}
+
+	for {
+		offset := dec.InputOffset()
+
+		tok, err := dec.Token()
+		if err != nil {
+			return keyOffsets, nil
+		}
+		offset = tokenStart(body, offset)
+
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			valueDone(stack)
+			continue
+		}
+
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.object && top.expectKey {
+				key := tok.(string)
+				top.key, top.expectKey = key, false
+				if top.keys[key] {
+					return nil, &jsonError{
+						Message: fmt.Sprintf("body contains duplicate key %q (at character %d)", key, offset),
+						Field:   jsonPath(stack),
+						Offset:  offset,
+					}
+				}
+				top.keys[key] = true
+				if _, ok := keyOffsets[key]; !ok {
+					keyOffsets[key] = offset
+				}
+				continue
+			}
+		}
+
+		switch tok := tok.(type) {
+		case json.Delim:
+			if len(stack) >= opts.maxDepth {
+				return nil, &jsonError{
+					Message: fmt.Sprintf("body must not be nested more than %d levels deep (at character %d)", opts.maxDepth, offset),
+					Field:   jsonPath(stack),
+					Offset:  offset,
+				}
+			}
+			frame := &jsonFrame{object: tok == '{'}
+			if frame.object {
+				frame.keys = map[string]bool{}
+				frame.expectKey = true
+			}
+			stack = append(stack, frame)
+		case json.Number:
+			if _, err := strconv.ParseFloat(tok.String(), 64); err != nil {
+				return nil, &jsonError{
+					Message: fmt.Sprintf("body contains out of range number %s (at character %d)", tok, offset),
+					Field:   jsonPath(stack),
+					Offset:  offset,
+				}
+			}
+			valueDone(stack)
+		default:
+			valueDone(stack)
+		}
+	}
+}
+
+// valueDone records that the current value of the innermost object or
+// array has been scanned.
+func valueDone(stack []*jsonFrame) {
+	if len(stack) == 0 {
+		return
+	}
+	top := stack[len(stack)-1]
+	if top.object {
+		top.expectKey = true
+	} else {
+		top.index++
+	}
+}
+
+// decodeJSON decodes body into dst according to opts, translating decoder
+// errors into jsonErrors which can be shown to the client.
+func decodeJSON(body []byte, dst any, opts jsonOptions) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &jsonError{Message: "body must not be empty"}
+	}
+
+	keyOffsets, err := scanJSON(body, opts)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if !opts.allowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if opts.useNumber {
+		dec.UseNumber()
+	}
+
+	err = dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var invalidUnmarshalError *json.InvalidUnmarshalError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return &jsonError{
+				Message: fmt.Sprintf("body contains badly-formed JSON (at character %d)", syntaxError.Offset),
+				Offset:  syntaxError.Offset,
+			}
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return &jsonError{Message: "body contains badly-formed JSON", Offset: int64(len(body))}
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return &jsonError{
+					Message: fmt.Sprintf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field),
+					Field:   unmarshalTypeError.Field,
+					Offset:  unmarshalTypeError.Offset,
+				}
+			}
+			return &jsonError{
+				Message: fmt.Sprintf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset),
+				Offset:  unmarshalTypeError.Offset,
+			}
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			name, _ := strconv.Unquote(field)
+			return &jsonError{
+				Message: fmt.Sprintf("body contains unknown key %s", field),
+				Field:   name,
+				Offset:  keyOffsets[name],
+			}
+		case errors.As(err, &invalidUnmarshalError):
+			panic(err)
+		default:
+			return err
+		}
+	}
+
+	offset := tokenStart(body, dec.InputOffset())
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return &jsonError{Message: "body must only contain a single JSON value", Offset: offset}
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets, as used by Prometheus client libraries.
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// unmatchedRoute is the route recorded for requests no route matched, so
+// that scanners cannot create a series per path they try.
+const unmatchedRoute = "unmatched"
+
+type latencyKey struct {
+	method string
+	route  string
+}
+
+type latencyHistogram struct {
+	// counts[i] is the number of requests which took no longer than
+	// buckets[i] but longer than buckets[i-1]; the last is for the rest.
+	counts     []uint64
+	sum        time.Duration
+	count      uint64
+	satisfied  uint64
+	tolerating uint64
+}
+
+// apdex returns the Apdex score: satisfied requests count fully, tolerating
+// ones half and the rest not at all.
+func (h *latencyHistogram) apdex() float64 {
+	if h.count == 0 {
+		return 1
+	}
+	return (float64(h.satisfied) + float64(h.tolerating)/2) / float64(h.count)
+}
+
+// latencyHistograms records how long requests take per route and method.
+// It is safe for concurrent use, and a nil *latencyHistograms records
+// nothing.
+type latencyHistograms struct {
+	buckets []float64
+	// apdexThreshold is the Apdex T: requests are satisfied within it and
+	// tolerating within four times it. Server errors always frustrate.
+	apdexThreshold time.Duration
+
+	mu     sync.Mutex
+	series map[latencyKey]*latencyHistogram
+	total  latencyHistogram
+}
+
+func newLatencyHistograms(buckets []float64, apdexThreshold time.Duration) *latencyHistograms {
+	if len(buckets) == 0 {
+		buckets = defaultLatencyBuckets
+	}
+
+	return &latencyHistograms{
+		buckets:        buckets,
+		apdexThreshold: apdexThreshold,
+		series:         make(map[latencyKey]*latencyHistogram),
+		total:          latencyHistogram{counts: make([]uint64, len(buckets)+1)},
+	}
+}
+
+func (l *latencyHistograms) observe(method, route string, status int, d time.Duration) {
+	if l == nil {
+		return
+	}
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	bucket := sort.SearchFloat64s(l.buckets, d.Seconds())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := latencyKey{method, route}
+	h, ok := l.series[key]
+	if !ok {
+		h = &latencyHistogram{counts: make([]uint64, len(l.buckets)+1)}
+		l.series[key] = h
+	}
+
+	for _, h := range []*latencyHistogram{h, &l.total} {
+		h.counts[bucket]++
+		h.sum += d
+		h.count++
+
+		switch {
+		case status >= 500:
+		case d <= l.apdexThreshold:
+			h.satisfied++
+		case d <= 4*l.apdexThreshold:
+			h.tolerating++
+		}
+	}
+}
+
+// sortedKeys returns the keys of the series in a stable order. The caller
+// must hold the lock.
+func (l *latencyHistograms) sortedKeys() []latencyKey {
+	keys := make([]latencyKey, 0, len(l.series))
+	for key := range l.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+	return keys
+}
+
+func formatBucket(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
+// snapshot returns the histograms for expvar, keyed by "METHOD route".
+// Bucket counts are cumulative, as in Prometheus.
+func (l *latencyHistograms) snapshot() any {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	describe := func(h *latencyHistogram) map[string]any {
+		buckets := make(map[string]uint64, len(h.counts))
+		var cumulative uint64
+		for i, count := range h.counts {
+			cumulative += count
+			if i < len(l.buckets) {
+				buckets[formatBucket(l.buckets[i])] = cumulative
+			} else {
+				buckets["+Inf"] = cumulative
+			}
+		}
+
+		return map[string]any{
+			"buckets":     buckets,
+			"count":       h.count,
+			"sum_seconds": h.sum.Seconds(),
+			"apdex":       h.apdex(),
+		}
+	}
+
+	routes := make(map[string]any, len(l.series))
+	for key, h := range l.series {
+		routes[key.method+" "+key.route] = describe(h)
+	}
+
+	return map[string]any{
+		"apdex_threshold_seconds": l.apdexThreshold.Seconds(),
+		"all":                     describe(&l.total),
+		"routes":                  routes,
+	}
+}
+
+// writePrometheus writes the histograms and Apdex scores in the Prometheus
+// text format.
+func (l *latencyHistograms) writePrometheus(w io.Writer) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := l.sortedKeys()
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds How long requests took to handle, by route and method.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, key := range keys {
+		h := l.series[key]
+		labels := fmt.Sprintf("method=%q,route=%q", key.method, key.route)
+
+		var cumulative uint64
+		for i, count := range h.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(l.buckets) {
+				le = formatBucket(l.buckets[i])
+			}
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, le, cumulative)
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum.Seconds(), 'g', -1, 64))
+		fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP http_apdex_score Apdex score of requests, by route and method.")
+	fmt.Fprintln(w, "# TYPE http_apdex_score gauge")
+	for _, key := range keys {
+		fmt.Fprintf(w, "http_apdex_score{method=%q,route=%q} %s\n", key.method, key.route, strconv.FormatFloat(l.series[key].apdex(), 'g', -1, 64))
+	}
+	fmt.Fprintln(w, "# HELP http_apdex_score_all Apdex score of all requests.")
+	fmt.Fprintln(w, "# TYPE http_apdex_score_all gauge")
+	fmt.Fprintf(w, "http_apdex_score_all %s\n", strconv.FormatFloat(l.total.apdex(), 'g', -1, 64))
+}
+
+// parseLatencyBuckets parses a space separated list of increasing bucket
+// bounds in seconds.
+func parseLatencyBuckets(val string) ([]float64, error) {
+	var buckets []float64
+	for _, field := range strings.Fields(val) {
+		bound, err := strconv.ParseFloat(field, 64)
+		if err != nil || bound <= 0 {
+			return nil, fmt.Errorf("invalid bucket %q", field)
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must be in increasing order")
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets, nil
+}
+
+// prometheusHandler serves the request counters and latency histograms in
+// the Prometheus text format.
+func (app *application) prometheusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	if v, ok := expvar.Get("total_requests_received").(*expvar.Int); ok {
+		fmt.Fprintln(w, "# HELP http_requests_total Requests received.")
+		fmt.Fprintln(w, "# TYPE http_requests_total counter")
+		fmt.Fprintf(w, "http_requests_total %d\n", v.Value())
+	}
+
+	if m, ok := expvar.Get("total_responses_sent_by_status").(*expvar.Map); ok {
+		fmt.Fprintln(w, "# HELP http_responses_total Responses sent, by status code.")
+		fmt.Fprintln(w, "# TYPE http_responses_total counter")
+		m.Do(func(kv expvar.KeyValue) {
+			fmt.Fprintf(w, "http_responses_total{status=%q} %s\n", kv.Key, kv.Value.String())
+		})
+	}
+
+	app.latency.writePrometheus(w)
+}
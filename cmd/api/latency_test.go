@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestLatencyHistograms(t *testing.T) {
+	l := newLatencyHistograms([]float64{0.1, 1}, 100*time.Millisecond)
+
+	l.observe(http.MethodGet, "/v1/movies", http.StatusOK, 50*time.Millisecond)
+	l.observe(http.MethodGet, "/v1/movies", http.StatusOK, 300*time.Millisecond)
+	l.observe(http.MethodGet, "/v1/movies", http.StatusOK, 2*time.Second)
+	l.observe(http.MethodGet, "/v1/movies", http.StatusInternalServerError, 10*time.Millisecond)
+	l.observe(http.MethodPost, "", http.StatusNotFound, 10*time.Millisecond)
+
+	h := l.series[latencyKey{http.MethodGet, "/v1/movies"}]
+	assert.Equal(t, h.counts[0], uint64(2))
+	assert.Equal(t, h.counts[1], uint64(1))
+	assert.Equal(t, h.counts[2], uint64(1))
+	assert.Equal(t, h.apdex(), 0.375)
+	assert.Equal(t, l.total.count, uint64(5))
+
+	_, ok := l.series[latencyKey{http.MethodPost, unmatchedRoute}]
+	assert.Equal(t, ok, true)
+
+	_, err := parseLatencyBuckets("0.1 0.05")
+	assert.Equal(t, err != nil, true)
+	buckets, err := parseLatencyBuckets("0.1 0.5 2")
+	assert.NilError(t, err)
+	assert.Equal(t, len(buckets), 3)
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/v1/healthcheck")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, header, body := ts.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, header.Get("Content-Type"), "text/plain")
+	assert.StringContains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/v1/healthcheck",le="+Inf"} 1`)
+	assert.StringContains(t, body, `http_request_duration_seconds_count{method="GET",route="/v1/healthcheck"} 1`)
+	assert.StringContains(t, body, `http_apdex_score{method="GET",route="/v1/healthcheck"}`)
+	assert.StringContains(t, body, "# TYPE http_request_duration_seconds histogram")
+}
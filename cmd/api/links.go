@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"greenlight.bcc/internal/data"
+)
+
+// resourceLink describes an action on a resource as one of the routes the
+// router has registered. The path's :id is replaced with the resource's ID.
+type resourceLink struct {
+	rel    string
+	method string
+	path   string
+}
+
+var movieLinks = []resourceLink{
+	{"self", http.MethodGet, "/v1/movies/:id"},
+	{"update", http.MethodPatch, "/v1/movies/:id"},
+	{"delete", http.MethodDelete, "/v1/movies/:id"},
+	{"status", http.MethodPut, "/v1/movies/:id/status"},
+	{"history", http.MethodGet, "/v1/movies/:id/history"},
+	{"enrich", http.MethodPost, "/v1/movies/:id/enrich"},
+	{"comments", http.MethodGet, "/v1/movies/:id/comments"},
+	{"add_comment", http.MethodPost, "/v1/movies/:id/comments"},
+}
+
+var userLinks = []resourceLink{
+	{"profile", http.MethodGet, "/v1/users/:id/profile"},
+}
+
+// ownUserLinks are only offered to users about themselves.
+var ownUserLinks = []resourceLink{
+	{"update_profile", http.MethodPatch, "/v1/users/me/profile"},
+	{"avatar", http.MethodPut, "/v1/users/me/avatar"},
+	{"feed", http.MethodGet, "/v1/users/me/feed"},
+}
+
+// link is a single entry of a response's links section.
+type link struct {
+	Href   string `json:"href"`
+	Method string `json:"method"`
+}
+
+// addResourceLinks adds a links section to responses holding a movie or a
+// user, listing the actions the caller may take on it. Only routes the
+// router has registered are offered, and routes which need a permission
+// only if the caller has it.
+func (app *application) addResourceLinks(r *http.Request, env envelope) error {
+	var links []resourceLink
+	var id int64
+
+	switch {
+	case env["movie"] != nil:
+		switch movie := env["movie"].(type) {
+		case *data.Movie:
+			id = movie.ID
+		case data.Movie:
+			id = movie.ID
+		}
+		links = movieLinks
+	case env["user"] != nil:
+		user, ok := env["user"].(*data.User)
+		if !ok {
+			return nil
+		}
+		id = user.ID
+		links = userLinks
+		if caller, ok := r.Context().Value(userContextKey).(*data.User); ok && caller.ID == user.ID {
+			links = append(links[:len(links):len(links)], ownUserLinks...)
+		}
+	}
+
+	if id == 0 {
+		return nil
+	}
+
+	var permissions data.Permissions
+	if caller, ok := r.Context().Value(userContextKey).(*data.User); ok && !caller.IsAnonymous() {
+		var err error
+		permissions, err = app.models.Permissions.GetAllForUser(caller.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	section := make(map[string]link)
+	for _, l := range links {
+		code, registered := app.routeTable.permission(l.method, l.path)
+		if !registered || (code != "" && !permissions.Include(code)) {
+			continue
+		}
+
+		section[l.rel] = link{
+			Href:   strings.Replace(l.path, ":id", strconv.FormatInt(id, 10), 1),
+			Method: l.method,
+		}
+	}
+
+	if len(section) > 0 {
+		env["links"] = section
+	}
+
+	return nil
+}
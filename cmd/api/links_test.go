@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestResourceLinks(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	viewer := &data.User{Name: "Viewer", Email: "viewer@example.com", Locale: "en", Activated: true}
+	if err := viewer.Password.Set("pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(viewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(viewer.ID, "movies:read"); err != nil {
+		t.Fatal(err)
+	}
+	viewerToken, err := app.models.Tokens.NewAuthentication(viewer.ID, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rels := func(body map[string]any) []string {
+		links, _ := body["links"].(map[string]any)
+		var rels []string
+		for rel := range links {
+			rels = append(rels, rel)
+		}
+		sort.Strings(rels)
+		return rels
+	}
+
+	code, body := ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Moana", "year": 2016, "runtime": "107 mins"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	assert.Equal(t, fmt.Sprint(rels(body)), "[add_comment comments delete enrich history self status update]")
+
+	movieID := int64(body["movie"].(map[string]any)["id"].(float64))
+	update := body["links"].(map[string]any)["update"].(map[string]any)
+	assert.Equal(t, update["href"].(string), fmt.Sprintf("/v1/movies/%d", movieID))
+	assert.Equal(t, update["method"].(string), http.MethodPatch)
+
+	code, _ = ts.do(t, http.MethodPut, fmt.Sprintf("/v1/movies/%d/status", movieID), token, `{"status": "published"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, body = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d", movieID), viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, fmt.Sprint(rels(body)), "[add_comment comments self]")
+
+	code, body = ts.do(t, http.MethodPut, "/v1/users/me/avatar", viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusUnsupportedMediaType)
+	assert.Equal(t, fmt.Sprint(rels(body)), "[]")
+
+	code, body = ts.do(t, http.MethodDelete, "/v1/users/me/avatar", viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, fmt.Sprint(rels(body)), "[avatar feed profile update_profile]")
+}
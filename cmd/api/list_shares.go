@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	defaultShareTTL = 7 * 24 * time.Hour
+	maxShareTTL     = 30 * 24 * time.Hour
+)
+
+// createListShareHandler issues a link through which anyone can read the
+// list until it expires, whether or not the list is public.
+func (app *application) createListShareHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.ownList(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		TTL string `json:"ttl"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	ttl := defaultShareTTL
+	if input.TTL != "" {
+		ttl, err = time.ParseDuration(input.TTL)
+		v.Check(err == nil, "ttl", "must be a duration such as 24h")
+		v.Check(err != nil || ttl >= time.Minute && ttl <= maxShareTTL, "ttl", "must be between 1m and 720h")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if list.ShareSecret == nil {
+		err = app.models.Lists.RotateShareSecret(list)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	expiry := time.Now().Add(ttl).Truncate(time.Second)
+	token := list.ShareToken(expiry)
+
+	share := envelope{
+		"token":      token,
+		"url":        "/v1/shared/" + token,
+		"expires_at": expiry,
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"share": share}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revokeListSharesHandler rotates the list's share secret, so that every
+// link issued for it so far stops working.
+func (app *application) revokeListSharesHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.ownList(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Lists.RotateShareSecret(list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "share links successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showSharedListHandler serves a list to whoever holds a valid share link.
+// Invalid, expired and revoked links all answer 404.
+func (app *application) showSharedListHandler(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("signature")
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.Filters.Page = qs.Int("page", 1)
+	input.Filters.PageSize = qs.Int("page_size", 20)
+	input.Filters.Sort = "position"
+	input.Filters.SortSafelist = []string{"position"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.withinQueryCost(w, r, input.Filters) {
+		return
+	}
+
+	id, err := data.ParseShareToken(token)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	list, err := app.models.Lists.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if list.VerifyShareToken(token, time.Now()) != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	items, metadata, err := app.models.Lists.GetItems(list.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"list": list, "items": items, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
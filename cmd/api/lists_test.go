@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestMovieLists(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	viewer := &data.User{Name: "Viewer", Email: "viewer@example.com", Locale: "en", Activated: true}
+	if err := viewer.Password.Set("pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(viewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(viewer.ID, "movies:read"); err != nil {
+		t.Fatal(err)
+	}
+	viewerToken, err := app.models.Tokens.NewAuthentication(viewer.ID, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var movieIDs []int64
+	for _, title := range []string{"Moana", "Black Panther", "Coco"} {
+		movie := &data.Movie{Title: title, Year: 2016, Runtime: 100, Genres: []string{"animation"}, Status: data.MovieStatusPublished, OrgID: 1}
+		if err := app.models.Movies.Insert(movie); err != nil {
+			t.Fatal(err)
+		}
+		movieIDs = append(movieIDs, movie.ID)
+	}
+
+	draft := &data.Movie{Title: "Untitled", Year: 2024, Runtime: 90, Genres: []string{"drama"}, Status: data.MovieStatusDraft, OrgID: 1}
+	if err := app.models.Movies.Insert(draft); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := ts.do(t, http.MethodPost, "/v1/lists", token, `{"name": ""}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body := ts.do(t, http.MethodPost, "/v1/lists", token, `{"name": "Favourites", "description": "The best ones"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	listPath := fmt.Sprintf("/v1/lists/%d", int64(body["list"].(map[string]any)["id"].(float64)))
+
+	for i, id := range movieIDs {
+		position := 0
+		if i == 2 {
+			position = 1
+		}
+		code, body = ts.do(t, http.MethodPost, listPath+"/items", token, fmt.Sprintf(`{"movie_id": %d, "position": %d}`, id, position))
+		assert.Equal(t, code, http.StatusCreated)
+	}
+	assert.Equal(t, body["item"].(map[string]any)["position"].(float64), 1)
+
+	code, body = ts.do(t, http.MethodPost, listPath+"/items", token, fmt.Sprintf(`{"movie_id": %d}`, movieIDs[0]))
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "already on this list")
+
+	code, _ = ts.do(t, http.MethodPost, listPath+"/items", token, fmt.Sprintf(`{"movie_id": %d}`, draft.ID))
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	titles := func() string {
+		t.Helper()
+		code, body := ts.do(t, http.MethodGet, listPath+"/items", token, "")
+		assert.Equal(t, code, http.StatusOK)
+		var titles []string
+		for _, item := range body["items"].([]any) {
+			titles = append(titles, item.(map[string]any)["title"].(string))
+		}
+		return fmt.Sprint(titles)
+	}
+
+	assert.Equal(t, titles(), "[Coco Moana Black Panther]")
+
+	code, body = ts.do(t, http.MethodPut, fmt.Sprintf("%s/items/%d", listPath, movieIDs[0]), token, `{"position": 3}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["item"].(map[string]any)["position"].(float64), 3)
+	assert.Equal(t, titles(), "[Coco Black Panther Moana]")
+
+	code, _ = ts.do(t, http.MethodDelete, fmt.Sprintf("%s/items/%d", listPath, movieIDs[2]), token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, titles(), "[Black Panther Moana]")
+
+	code, _ = ts.do(t, http.MethodGet, listPath, viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/lists", viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["lists"].([]any)), 0)
+
+	code, body = ts.do(t, http.MethodPatch, listPath, token, `{"public": true}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["list"].(map[string]any)["name"].(string), "Favourites")
+
+	code, body = ts.do(t, http.MethodGet, "/v1/lists", viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	lists := body["lists"].([]any)
+	assert.Equal(t, len(lists), 1)
+	assert.Equal(t, lists[0].(map[string]any)["item_count"].(float64), 2)
+
+	code, _ = ts.do(t, http.MethodGet, listPath+"/items", viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodPatch, listPath, viewerToken.Plaintext, `{"name": "Mine now"}`)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/lists?mine=true", viewerToken.Plaintext, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["lists"].([]any)), 0)
+
+	code, _ = ts.do(t, http.MethodDelete, listPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodGet, listPath, token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestListShareLinks(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusPublished, OrgID: 1}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := ts.do(t, http.MethodPost, "/v1/lists", token, `{"name": "Private picks"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	listPath := fmt.Sprintf("/v1/lists/%d", int64(body["list"].(map[string]any)["id"].(float64)))
+
+	code, _ = ts.do(t, http.MethodPost, listPath+"/items", token, fmt.Sprintf(`{"movie_id": %d}`, movie.ID))
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, _ = ts.do(t, http.MethodPost, listPath+"/share", token, `{"ttl": "1000h"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body = ts.do(t, http.MethodPost, listPath+"/share", token, `{"ttl": "1h"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	url := body["share"].(map[string]any)["url"].(string)
+
+	code, body = ts.do(t, http.MethodGet, url, "", "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["list"].(map[string]any)["name"].(string), "Private picks")
+	assert.Equal(t, len(body["items"].([]any)), 1)
+
+	code, _ = ts.do(t, http.MethodGet, url+"x", "", "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, body = ts.do(t, http.MethodPost, listPath+"/share", token, `{}`)
+	assert.Equal(t, code, http.StatusCreated)
+	second := body["share"].(map[string]any)["url"].(string)
+
+	code, _ = ts.do(t, http.MethodGet, second, "", "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodDelete, listPath+"/share", token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	for _, revoked := range []string{url, second} {
+		code, _ = ts.do(t, http.MethodGet, revoked, "", "")
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+
+	code, body = ts.do(t, http.MethodGet, listPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	list, err := app.models.Lists.Get(int64(body["list"].(map[string]any)["id"].(float64)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/shared/"+list.ShareToken(time.Now().Add(-time.Minute)), "", "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestUpdatedMovieNotifiesListOwners(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	onList := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Status: data.MovieStatusPublished, OrgID: 1}
+	offList := &data.Movie{Title: "Coco", Year: 2017, Runtime: 105, Status: data.MovieStatusPublished, OrgID: 1}
+	for _, movie := range []*data.Movie{onList, offList} {
+		if err := app.models.Movies.Insert(movie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, body := ts.do(t, http.MethodPost, "/v1/lists", token, `{"name": "Favourites"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	itemsPath := fmt.Sprintf("/v1/lists/%v/items", body["list"].(map[string]any)["id"])
+
+	code, _ = ts.do(t, http.MethodPost, itemsPath, token, fmt.Sprintf(`{"movie_id": %d}`, onList.ID))
+	assert.Equal(t, code, http.StatusCreated)
+
+	for _, movie := range []*data.Movie{onList, offList} {
+		code, _ = ts.do(t, http.MethodPatch, fmt.Sprintf("/v1/movies/%d", movie.ID), token, `{"year": 2015}`)
+		assert.Equal(t, code, http.StatusOK)
+	}
+
+	app.wg.Wait()
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/notifications", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	notifications := body["notifications"].([]any)
+	assert.Equal(t, len(notifications), 1)
+	assert.Equal(t, notifications[0].(map[string]any)["kind"].(string), data.NotificationListMovieUpdated)
+	assert.StringContains(t, notifications[0].(map[string]any)["message"].(string), "Moana")
+}
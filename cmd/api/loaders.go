@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+const includeLists = "lists"
+
+// movieIncludes are the relations which can be included with movies through
+// the include query parameter.
+var movieIncludes = []string{includeLists}
+
+// movieLoader fills in the relations included with movies. Each relation is
+// loaded for all the movies it is given in one query, so including it costs
+// the same however many movies are returned. A loader is made for a single
+// request and keeps what it has loaded until the request ends.
+type movieLoader struct {
+	app      *application
+	userID   int64
+	includes []string
+	lists    map[int64][]*data.MovieList
+}
+
+// newMovieLoader returns a loader for the relations named by the include
+// query parameter of the request. Unknown relations are reported through v.
+func (app *application) newMovieLoader(r *http.Request, v *validator.Validator) *movieLoader {
+	includes := newQueryBinder(r.URL.Query(), v).CSV("include", []string{})
+	for _, include := range includes {
+		v.Check(validator.PermittedValue(include, movieIncludes...), "include", "must only contain lists")
+	}
+
+	return &movieLoader{
+		app:      app,
+		userID:   app.contextGetUser(r).ID,
+		includes: includes,
+		lists:    make(map[int64][]*data.MovieList),
+	}
+}
+
+// load fills in the included relations of the movies.
+func (l *movieLoader) load(movies ...*data.Movie) error {
+	for _, include := range l.includes {
+		switch include {
+		case includeLists:
+			if err := l.loadLists(movies); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *movieLoader) loadLists(movies []*data.Movie) error {
+	var missing []int64
+	for _, movie := range movies {
+		if _, ok := l.lists[movie.ID]; !ok {
+			l.lists[movie.ID] = nil
+			missing = append(missing, movie.ID)
+		}
+	}
+
+	if len(missing) > 0 {
+		lists, err := l.app.models.Lists.GetForMovies(l.userID, missing)
+		if err != nil {
+			return err
+		}
+		for id, movieLists := range lists {
+			l.lists[id] = movieLists
+		}
+	}
+
+	for _, movie := range movies {
+		movie.Lists = l.lists[movie.ID]
+	}
+
+	return nil
+}
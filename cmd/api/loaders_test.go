@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+// countingListModel counts the batches of movies whose lists are loaded.
+type countingListModel struct {
+	data.MemoryListModel
+	batches *int
+}
+
+func (m countingListModel) GetForMovies(userID int64, movieIDs []int64) (map[int64][]*data.MovieList, error) {
+	*m.batches++
+	return m.MemoryListModel.GetForMovies(userID, movieIDs)
+}
+
+func TestIncludeMovieLists(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	var batches int
+	app.models.Lists = countingListModel{app.models.Lists.(data.MemoryListModel), &batches}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	movies := map[string]int64{}
+	for _, title := range []string{"Moana", "Coco", "Arrival"} {
+		movie := &data.Movie{Title: title, Year: 2016, Runtime: 100, Status: data.MovieStatusPublished, OrgID: 1}
+		if err := app.models.Movies.Insert(movie); err != nil {
+			t.Fatal(err)
+		}
+		movies[title] = movie.ID
+	}
+
+	for name, titles := range map[string][]string{"Favourites": {"Moana", "Coco"}, "Watch later": {"Moana"}} {
+		code, body := ts.do(t, http.MethodPost, "/v1/lists", token, fmt.Sprintf(`{"name": %q}`, name))
+		assert.Equal(t, code, http.StatusCreated)
+		itemsPath := fmt.Sprintf("/v1/lists/%v/items", body["list"].(map[string]any)["id"])
+
+		for _, title := range titles {
+			code, _ = ts.do(t, http.MethodPost, itemsPath, token, fmt.Sprintf(`{"movie_id": %d}`, movies[title]))
+			assert.Equal(t, code, http.StatusCreated)
+		}
+	}
+
+	listCount := func(movie any) int {
+		lists, _ := movie.(map[string]any)["lists"].([]any)
+		return len(lists)
+	}
+
+	code, body := ts.do(t, http.MethodGet, "/v1/movies?include=lists&sort=title", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	included := body["movies"].([]any)
+	assert.Equal(t, len(included), 3)
+	assert.Equal(t, listCount(included[0]), 0)
+	assert.Equal(t, listCount(included[1]), 1)
+	assert.Equal(t, listCount(included[2]), 2)
+	assert.Equal(t, batches, 1)
+
+	code, body = ts.do(t, http.MethodGet, fmt.Sprintf("/v1/movies/%d?include=lists", movies["Coco"]), token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, listCount(body["movie"]), 1)
+	assert.Equal(t, batches, 2)
+
+	// Lists are only loaded when asked for.
+	code, body = ts.do(t, http.MethodGet, "/v1/movies", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, listCount(body["movies"].([]any)[0]), 0)
+	assert.Equal(t, batches, 2)
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/movies?include=credits", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/mailer"
+)
+
+// scriptedBackend fails deliveries with errs in turn, then succeeds.
+type scriptedBackend struct {
+	errs  []error
+	calls int
+}
+
+func (b *scriptedBackend) Deliver(ctx context.Context, msg *mailer.Message) error {
+	b.calls++
+	if b.calls <= len(b.errs) {
+		return b.errs[b.calls-1]
+	}
+	return nil
+}
+
+func TestMailerRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"transient reply", &textproto.Error{Code: 451, Msg: "try again later"}, 2},
+		{"permanent reply", &textproto.Error{Code: 550, Msg: "no such user"}, 1},
+		{"network", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}, 2},
+		{"other", errors.New("mail: missing @ in addr-spec"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &scriptedBackend{errs: []error{tt.err}}
+			m := mailer.New(backend, "test@example.com", time.Second, 1)
+
+			m.Send("alice@example.com", "en", "user_welcome.tmpl", map[string]any{"userID": 1, "activationToken": "x"})
+			assert.Equal(t, backend.calls, tt.wantCalls)
+		})
+	}
+}
+
+func TestSMTPDeliverDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// The server accepts connections and never answers.
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	backend := mailer.NewSMTP(addr.IP.String(), addr.Port, "", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = backend.Deliver(ctx, &mailer.Message{From: "test@example.com", To: "alice@example.com", Subject: "Hi"})
+
+	var netErr net.Error
+	assert.Equal(t, errors.As(err, &netErr) && netErr.Timeout(), true)
+	assert.Equal(t, time.Since(start) < time.Second, true)
+}
@@ -2,19 +2,47 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"expvar"
 	"flag"
+	"fmt"
+	"math"
+	"net"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/lib/pq"
+	"greenlight.bcc/internal/breaker"
+	"greenlight.bcc/internal/cache"
+	"greenlight.bcc/internal/captcha"
+	"greenlight.bcc/internal/cluster"
 	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/enrich"
+	"greenlight.bcc/internal/errtrack"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/experiment"
+	"greenlight.bcc/internal/fieldcrypt"
+	"greenlight.bcc/internal/geoip"
+	"greenlight.bcc/internal/jobs"
 	"greenlight.bcc/internal/jsonlog"
-	"greenlight.bcc/internal/mailer" // New import
+	"greenlight.bcc/internal/mailer"
+	"greenlight.bcc/internal/oembed"
+	"greenlight.bcc/internal/plugin"
+	"greenlight.bcc/internal/pwned"
+	"greenlight.bcc/internal/storage"
+	"greenlight.bcc/internal/validator"
+	"greenlight.bcc/internal/webhook"
 )
 
 const version = "1.0.0"
@@ -22,17 +50,71 @@ const version = "1.0.0"
 type config struct {
 	port int
 	env  string
-	db   struct {
-		dsn          string
-		maxOpenConns int
-		maxIdleConns int
-		maxIdleTime  string
+	dev  bool
+	log  struct {
+		level       string
+		levelFile   string
+		stdout      bool
+		file        string
+		fileMaxSize int64
+		fileMaxAge  time.Duration
+		syslogAddr  string
+		bufferSize  int
+		sampleFirst int
+		sampleEvery time.Duration
+	}
+	db struct {
+		driver           string
+		dsn              string
+		maxOpenConns     int
+		maxIdleConns     int
+		maxIdleTime      string
+		statementTimeout time.Duration
+		readRetries      int
+		readRetryDelay   time.Duration
 	}
 	limiter struct {
 		rps     float64
 		burst   int
 		enabled bool
 	}
+	metrics struct {
+		latencyBuckets []float64
+		apdexThreshold time.Duration
+	}
+	seed struct {
+		enabled  bool
+		users    int
+		movies   int
+		lists    int
+		comments int
+		password string
+		randSeed int64
+	}
+	selftest struct {
+		enabled       bool
+		timeout       time.Duration
+		migrationsDir string
+	}
+	watchdog struct {
+		interval      time.Duration
+		maxGoroutines int
+		maxHeapMB     int
+		maxGCPause    time.Duration
+		heapDump      bool
+	}
+	chaos struct {
+		rules map[string]chaosRule
+	}
+	replay struct {
+		groups []string
+		window time.Duration
+	}
+	honeypot struct {
+		paths       []string
+		banDuration time.Duration
+		delay       time.Duration
+	}
 	smtp struct {
 		host     string
 		port     int
@@ -40,17 +122,206 @@ type config struct {
 		password string
 		sender   string
 	}
+	mailer struct {
+		backend string
+		timeout time.Duration
+		retries int
+	}
+	ses struct {
+		region          string
+		accessKeyID     string
+		secretAccessKey string
+		eventsTopicARN  string
+	}
+	sendgrid struct {
+		apiKey           string
+		webhookPublicKey string
+	}
+	proxies struct {
+		trusted []*net.IPNet
+	}
+	admin struct {
+		allowCIDRs []*net.IPNet
+		denyCIDRs  []*net.IPNet
+	}
 	cors struct {
 		trustedOrigins []string
+		rules          []corsRule
+		maxAge         time.Duration
+		strict         bool
+	}
+	errtrack struct {
+		dsn string
+	}
+	captcha struct {
+		provider string
+		secret   string
+	}
+	tmdb struct {
+		token string
+	}
+	registration struct {
+		inviteOnly bool
+		inviteTTL  time.Duration
+	}
+	password struct {
+		policy            data.PasswordPolicy
+		breachCheck       bool
+		breachTimeout     time.Duration
+		argon2Memory      uint
+		argon2Iterations  uint
+		argon2Parallelism uint
+		argon2MemoryLimit uint64
+	}
+	geoip struct {
+		database string
+	}
+	webhooks struct {
+		timeout time.Duration
+	}
+	oembed struct {
+		enabled bool
+		timeout time.Duration
+	}
+	orgs struct {
+		defaultID int64
+	}
+	usage struct {
+		flushInterval time.Duration
+	}
+	rankings struct {
+		refreshInterval time.Duration
+	}
+	tombstones struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	cluster struct {
+		advertiseURL      string
+		heartbeatInterval time.Duration
+	}
+	cache struct {
+		moviesTTL    time.Duration
+		maxEntries   int
+		invalidation bool
+	}
+	experiments struct {
+		defined []experiment.Experiment
+	}
+	quotas struct {
+		moviesPerOrg    int
+		webhooksPerUser int
+	}
+	comments struct {
+		editWindow time.Duration
+	}
+	movies struct {
+		uuidStrategy string
+	}
+	storage struct {
+		backend        string
+		dir            string
+		baseURL        string
+		secret         string
+		maxUploadBytes int64
+	}
+	s3 struct {
+		endpoint        string
+		region          string
+		bucket          string
+		accessKeyID     string
+		secretAccessKey string
+		publicURL       string
+	}
+	avatars struct {
+		maxBytes int64
+	}
+	images struct {
+		variantCacheSize int
+	}
+	thumbnails struct {
+		retries    int
+		retryDelay time.Duration
+	}
+	shutdown struct {
+		readinessDelay    time.Duration
+		drainTimeout      time.Duration
+		backgroundTimeout time.Duration
+	}
+	breaker struct {
+		threshold int
+		cooldown  time.Duration
+	}
+	shed struct {
+		maxConcurrent int
+		maxQueue      int
+		queueTimeout  time.Duration
+	}
+	queryCost struct {
+		maxPageSize        int
+		maxOffset          int
+		maxUnindexedOffset int
+	}
+	encryption struct {
+		keys            string
+		indexKey        string
+		kmsRegion       string
+		accessKeyID     string
+		secretAccessKey string
+		rotateBatchSize int
+	}
+	debug struct {
+		enabled      bool
+		sampleRate   float64
+		routes       []string
+		bufferSize   int
+		maxBodyBytes int
 	}
 }
 
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config   config
+	settings map[string]string
+	logger   *jsonlog.Logger
+	models   data.Models
+	mailer   mailer.Mailer
+	logSink  jsonlog.Sink
+	recorder *requestRecorder
+	errtrack errtrack.Reporter
+	captcha  captcha.Verifier
+	pwned    pwned.Checker
+	denylist *denylist
+	nonces   *nonceStore
+	webhooks *webhook.Client
+	latency  *latencyHistograms
+	watchdog *watchdog
+	geoip    geoip.Locator
+	enricher enrich.Enricher
+	oembed   oembed.Fetcher
+	events   *events.Bus
+	storage  storage.Store
+	usage    *usageAggregator
+	jobs     *jobs.Runner
+	cluster  *cluster.Node
+	plugins  []plugin.Plugin
+	breakers []*breaker.Breaker
+	// routeTable is filled in as the routes are registered.
+	routeTable *routeTable
+	// movieCache holds single-movie reads, and cacheBus carries their
+	// invalidations to the other instances. Either may be nil.
+	movieCache *cache.Cache[data.Movie]
+	cacheBus   cache.Bus
+	exposures  exposureLog
+	// imageVariants holds resized images served from storage.
+	imageVariants *cache.LRU[imageVariant]
+
+	emailEvents struct {
+		ses      mailer.EventSource
+		sendgrid mailer.EventSource
+	}
+	wg       sync.WaitGroup
+	draining atomic.Bool
+	inFlight atomic.Int64
 }
 
 func main() {
@@ -59,39 +330,387 @@ func main() {
 
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+	flag.BoolVar(&cfg.dev, "dev", false, "Run without a database, keeping all data in memory until exit")
+	flag.BoolVar(&cfg.seed.enabled, "seed", false, "Fill the database with fake data and exit (with -dev, fill the in-memory models and keep serving)")
+	flag.IntVar(&cfg.seed.users, "seed-users", 20, "Number of users to seed")
+	flag.IntVar(&cfg.seed.movies, "seed-movies", 200, "Number of movies to seed")
+	flag.IntVar(&cfg.seed.lists, "seed-lists", 30, "Number of lists to seed")
+	flag.IntVar(&cfg.seed.comments, "seed-comments", 500, "Number of comments to seed")
+	flag.StringVar(&cfg.seed.password, "seed-password", devUserPassword, "Password of every seeded user")
+	flag.Int64Var(&cfg.seed.randSeed, "seed-random", 1, "Seed of the random source, so that seeding is repeatable")
+	flag.BoolVar(&cfg.selftest.enabled, "selftest", false, "Check the configured dependencies, print a JSON report and exit, non-zero if a check failed")
+	flag.DurationVar(&cfg.selftest.timeout, "selftest-timeout", 10*time.Second, "How long each self-test check may take")
+	flag.StringVar(&cfg.selftest.migrationsDir, "selftest-migrations-dir", "./migrations", "Directory of migrations the database should be up to date with")
+	flag.StringVar(&cfg.log.level, "log-level", "info", "Minimum log level (info|error|fatal|off)")
+	flag.StringVar(&cfg.log.levelFile, "log-level-file", "", "File containing the minimum log level, re-read on SIGHUP")
+	flag.BoolVar(&cfg.log.stdout, "log-stdout", true, "Write logs to stdout")
+	flag.StringVar(&cfg.log.file, "log-file", "", "Also write logs to this file (reopened on SIGHUP)")
+	flag.Int64Var(&cfg.log.fileMaxSize, "log-file-max-size", 100, "Rotate the log file after this many megabytes (0 disables)")
+	flag.DurationVar(&cfg.log.fileMaxAge, "log-file-max-age", 24*time.Hour, "Rotate the log file after this long (0 disables)")
+	flag.StringVar(&cfg.log.syslogAddr, "log-syslog-addr", "", "Also send logs to this syslog server over UDP (host:port)")
+	flag.IntVar(&cfg.log.bufferSize, "log-buffer-size", 0, "Buffer this many bytes of log output before writing (0 disables)")
+	flag.IntVar(&cfg.log.sampleFirst, "log-sample-first", 10, "Log at most this many identical errors per sampling window (0 disables)")
+	flag.DurationVar(&cfg.log.sampleEvery, "log-sample-window", time.Minute, "Sampling window for identical error messages")
+
+	flag.StringVar(&cfg.db.driver, "db-driver", "pq", "PostgreSQL driver (pq|pgx)")
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
 
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
+	flag.DurationVar(&cfg.db.statementTimeout, "db-statement-timeout", 30*time.Second, "Cancel statements running longer than this in PostgreSQL (0 disables)")
+	flag.IntVar(&cfg.db.readRetries, "db-read-retries", 2, "Retry reads failing with transient errors, such as during a failover, this many times")
+	flag.DurationVar(&cfg.db.readRetryDelay, "db-read-retry-delay", 25*time.Millisecond, "Longest pause before the first read retry, doubling for each one after")
 
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
+	cfg.metrics.latencyBuckets = defaultLatencyBuckets
+	flag.Func("metrics-latency-buckets", "Upper bounds of the request latency histogram buckets in seconds (space separated, increasing)", func(val string) (err error) {
+		cfg.metrics.latencyBuckets, err = parseLatencyBuckets(val)
+		return err
+	})
+	flag.DurationVar(&cfg.metrics.apdexThreshold, "metrics-apdex-threshold", 500*time.Millisecond, "Apdex target: requests answered within this are satisfied, within four times it tolerating")
+
+	flag.DurationVar(&cfg.watchdog.interval, "watchdog-interval", 10*time.Second, "How often to sample goroutines, heap usage and GC pauses (0 disables the watchdog)")
+	flag.IntVar(&cfg.watchdog.maxGoroutines, "watchdog-max-goroutines", 10000, "Warn when more goroutines than this are running (0 disables)")
+	flag.IntVar(&cfg.watchdog.maxHeapMB, "watchdog-max-heap-mb", 1024, "Warn when the heap grows past this many megabytes (0 disables)")
+	flag.DurationVar(&cfg.watchdog.maxGCPause, "watchdog-max-gc-pause", 100*time.Millisecond, "Warn when a garbage collection pause is longer than this (0 disables)")
+	flag.BoolVar(&cfg.watchdog.heapDump, "watchdog-heap-dump", false, "Save a heap profile to storage when the heap threshold is crossed")
+
+	flag.Func("chaos-rules", "Faults to inject for testing clients, e.g. 'GET /v1/movies/:id latency=0.5:300ms error=0.1; * drop=0.01' (not allowed in production)", func(val string) (err error) {
+		cfg.chaos.rules, err = parseChaosRules(val)
+		return err
+	})
+
+	flag.Func("replay-protection", "Route groups whose requests must carry an X-Nonce and X-Timestamp header: deletes, admin, tokens, moderation (space or comma separated)", func(val string) (err error) {
+		cfg.replay.groups, err = parseReplayGroups(val)
+		return err
+	})
+	flag.DurationVar(&cfg.replay.window, "replay-window", 5*time.Minute, "How far a request's X-Timestamp may be from the server's clock")
+
+	cfg.honeypot.paths = defaultHoneypotPaths
+	flag.Func("honeypot-paths", "Paths only scanners request, whose callers are denylisted (space separated, empty disables)", func(val string) error {
+		cfg.honeypot.paths = parseHoneypotPaths(val)
+		return nil
+	})
+	flag.DurationVar(&cfg.honeypot.banDuration, "honeypot-ban-duration", time.Hour, "How long the rate limiter refuses clients which requested a honeypot path (0 disables)")
+	flag.DurationVar(&cfg.honeypot.delay, "honeypot-delay", 10*time.Second, "How long to wait before answering requests for honeypot paths")
+
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
 	flag.StringVar(&cfg.smtp.username, "smtp-username", "6b1b71f5d6687d", "SMTP username")
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "d6db3cd88fa14c", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.alexedwards.net>", "SMTP sender")
 
+	flag.StringVar(&cfg.mailer.backend, "mailer-backend", "smtp", "Mail delivery backend (smtp|ses|sendgrid|log)")
+	flag.DurationVar(&cfg.mailer.timeout, "mailer-timeout", 10*time.Second, "Timeout for a single delivery attempt")
+	flag.IntVar(&cfg.mailer.retries, "mailer-retries", 3, "Retry failed deliveries this many times")
+	flag.StringVar(&cfg.ses.region, "ses-region", "us-east-1", "AWS region for SES")
+	flag.StringVar(&cfg.ses.accessKeyID, "ses-access-key-id", os.Getenv("AWS_ACCESS_KEY_ID"), "AWS access key ID for SES")
+	flag.StringVar(&cfg.ses.secretAccessKey, "ses-secret-access-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), "AWS secret access key for SES")
+	flag.StringVar(&cfg.sendgrid.apiKey, "sendgrid-api-key", os.Getenv("SENDGRID_API_KEY"), "SendGrid API key")
+	flag.StringVar(&cfg.ses.eventsTopicARN, "ses-events-topic-arn", "", "SNS topic ARN for SES bounce and complaint notifications (empty disables)")
+	flag.StringVar(&cfg.sendgrid.webhookPublicKey, "sendgrid-webhook-public-key", "", "SendGrid event webhook verification key (empty disables)")
+
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
 	})
+	flag.Func("cors-rule", "Per-origin CORS rule as origin;methods;headers (repeatable)", func(val string) error {
+		rule, err := parseCORSRule(val)
+		if err != nil {
+			return err
+		}
+		cfg.cors.rules = append(cfg.cors.rules, rule)
+		return nil
+	})
+	flag.DurationVar(&cfg.cors.maxAge, "cors-max-age", 0, "How long browsers may cache preflight responses (0 omits the header)")
+	flag.BoolVar(&cfg.cors.strict, "cors-strict", false, "Reject requests from untrusted origins with 403")
+
+	flag.Func("trusted-proxies", "Proxy CIDR ranges whose X-Forwarded-For header is trusted (space separated)", func(val string) error {
+		var err error
+		cfg.proxies.trusted, err = parseCIDRs(val)
+		return err
+	})
+	flag.Func("admin-allow-cidrs", "Only allow these CIDR ranges to reach admin and debug routes (space separated)", func(val string) error {
+		var err error
+		cfg.admin.allowCIDRs, err = parseCIDRs(val)
+		return err
+	})
+	flag.Func("admin-deny-cidrs", "Deny these CIDR ranges access to admin and debug routes (space separated)", func(val string) error {
+		var err error
+		cfg.admin.denyCIDRs, err = parseCIDRs(val)
+		return err
+	})
+
+	flag.StringVar(&cfg.errtrack.dsn, "sentry-dsn", os.Getenv("GREENLIGHT_SENTRY_DSN"), "Sentry DSN for error reports (optional)")
+
+	flag.StringVar(&cfg.captcha.provider, "captcha-provider", "", "Captcha provider for registration (hcaptcha|recaptcha, empty disables)")
+	flag.StringVar(&cfg.captcha.secret, "captcha-secret", os.Getenv("GREENLIGHT_CAPTCHA_SECRET"), "Captcha provider secret key")
+
+	flag.StringVar(&cfg.tmdb.token, "tmdb-token", os.Getenv("GREENLIGHT_TMDB_TOKEN"), "TMDb API read access token for metadata enrichment (empty disables)")
+
+	flag.BoolVar(&cfg.registration.inviteOnly, "registration-invite-only", false, "Require an invitation code to register")
+	flag.DurationVar(&cfg.registration.inviteTTL, "registration-invite-ttl", 7*24*time.Hour, "How long invitation codes stay valid")
+
+	flag.IntVar(&cfg.password.policy.MinLength, "password-min-length", data.DefaultPasswordPolicy.MinLength, "Fewest characters a new password may have")
+	flag.IntVar(&cfg.password.policy.MaxLength, "password-max-length", data.DefaultPasswordPolicy.MaxLength, "Most bytes a new password may have (at most 72)")
+	flag.IntVar(&cfg.password.policy.MinClasses, "password-min-classes", data.DefaultPasswordPolicy.MinClasses, "Require new passwords to mix this many of lowercase, uppercase, digits and symbols (0 disables)")
+	flag.BoolVar(&cfg.password.breachCheck, "password-breach-check", false, "Reject new passwords found in the Have I Been Pwned breach corpus")
+	flag.DurationVar(&cfg.password.breachTimeout, "password-breach-timeout", 2*time.Second, "Give up on the breach check after this long and accept the password")
+
+	flag.UintVar(&cfg.password.argon2Memory, "password-argon2-memory", uint(data.PasswordHashing.Memory), "Memory in KiB used to hash each password with Argon2id (hashes beyond -password-argon2-memory-limit wait their turn)")
+	flag.UintVar(&cfg.password.argon2Iterations, "password-argon2-iterations", uint(data.PasswordHashing.Iterations), "Passes over the memory when hashing each password with Argon2id")
+	flag.UintVar(&cfg.password.argon2Parallelism, "password-argon2-parallelism", uint(data.PasswordHashing.Parallelism), "Threads used to hash each password with Argon2id (at most 255)")
+	flag.Uint64Var(&cfg.password.argon2MemoryLimit, "password-argon2-memory-limit", data.DefaultPasswordHashingMemoryLimit, "Memory in KiB which the Argon2id hashes and checks running at once may use between them; the rest queue (the default runs 8 hashes at 64 MiB each)")
+	flag.StringVar(&cfg.geoip.database, "geoip-database", "", "Path of a MaxMind DB file, such as GeoLite2-City.mmdb, to locate logins with (empty disables)")
+
+	flag.DurationVar(&cfg.webhooks.timeout, "webhook-timeout", 10*time.Second, "Give up on a webhook delivery after this long")
+
+	flag.BoolVar(&cfg.oembed.enabled, "oembed-enabled", false, "Check movie video URLs with YouTube and Vimeo and fetch their titles and thumbnails")
+	flag.DurationVar(&cfg.oembed.timeout, "oembed-timeout", 5*time.Second, "Timeout for fetching video metadata")
+
+	flag.Int64Var(&cfg.orgs.defaultID, "org-default-id", 1, "Organization new users join when they register (0 disables)")
+
+	flag.IntVar(&cfg.quotas.moviesPerOrg, "quota-movies-per-org", 0, "Maximum number of movies per organization (0 is unlimited)")
+	flag.IntVar(&cfg.quotas.webhooksPerUser, "quota-webhooks-per-user", 0, "Maximum number of webhooks per user (0 is unlimited)")
+
+	flag.DurationVar(&cfg.comments.editWindow, "comments-edit-window", 15*time.Minute, "How long after posting a comment its author may edit it")
+	cfg.movies.uuidStrategy = data.UUIDRandom
+	flag.Func("movies-uuid-strategy", "How UUIDs are generated for movies created without one (random|ordered)", func(val string) error {
+		if !validator.PermittedValue(val, data.UUIDStrategies...) {
+			return fmt.Errorf("unknown UUID strategy %q", val)
+		}
+		cfg.movies.uuidStrategy = val
+		return nil
+	})
+
+	flag.StringVar(&cfg.storage.backend, "storage-backend", "local", "File storage backend (local|s3)")
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory for uploaded files with the local backend")
+	flag.StringVar(&cfg.storage.baseURL, "storage-base-url", "http://localhost:4000/v1/files", "Public URL the files in -storage-dir are served from")
+	flag.StringVar(&cfg.storage.secret, "storage-secret", os.Getenv("GREENLIGHT_STORAGE_SECRET"), "Key for signing local storage URLs (random if empty)")
+	flag.Int64Var(&cfg.storage.maxUploadBytes, "storage-max-upload-bytes", 100<<20, "Maximum size of a file uploaded through a signed URL")
+	flag.StringVar(&cfg.s3.endpoint, "s3-endpoint", "", "S3-compatible endpoint (empty for AWS)")
+	flag.StringVar(&cfg.s3.region, "s3-region", "us-east-1", "AWS region of the S3 bucket")
+	flag.StringVar(&cfg.s3.bucket, "s3-bucket", "", "S3 bucket for uploaded files")
+	flag.StringVar(&cfg.s3.accessKeyID, "s3-access-key-id", os.Getenv("AWS_ACCESS_KEY_ID"), "AWS access key ID for S3")
+	flag.StringVar(&cfg.s3.secretAccessKey, "s3-secret-access-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), "AWS secret access key for S3")
+	flag.StringVar(&cfg.s3.publicURL, "s3-public-url", "", "Public URL of the bucket, such as a CDN (empty uses the bucket URL)")
+	flag.Int64Var(&cfg.avatars.maxBytes, "avatars-max-bytes", 5<<20, "Maximum size of an uploaded avatar image")
+	flag.IntVar(&cfg.images.variantCacheSize, "images-variant-cache-size", 64, "Number of resized images to keep in memory")
+	flag.IntVar(&cfg.thumbnails.retries, "thumbnails-retries", 3, "Number of times to retry generating thumbnails when storage fails")
+	flag.DurationVar(&cfg.thumbnails.retryDelay, "thumbnails-retry-delay", time.Second, "Delay before the first thumbnail retry, doubled for each one after")
+
+	flag.DurationVar(&cfg.usage.flushInterval, "usage-flush-interval", time.Minute, "How often to persist per-user request counts (0 disables usage tracking)")
+
+	flag.DurationVar(&cfg.rankings.refreshInterval, "rankings-refresh-interval", 10*time.Minute, "How often the most-listed and trending movie rankings are recomputed (0 disables)")
+
+	flag.DurationVar(&cfg.tombstones.retention, "tombstones-retention", 30*24*time.Hour, "How long deletions are kept for sync clients (0 keeps them forever)")
+	flag.DurationVar(&cfg.tombstones.purgeInterval, "tombstones-purge-interval", time.Hour, "How often tombstones past their retention are purged")
+
+	flag.StringVar(&cfg.cluster.advertiseURL, "cluster-advertise-url", "", "Base URL other instances reach this one on (empty uses the hostname and port)")
+	flag.DurationVar(&cfg.cluster.heartbeatInterval, "cluster-heartbeat-interval", 10*time.Second, "How often this instance reports itself alive in the cluster registry (0 disables)")
+
+	flag.DurationVar(&cfg.cache.moviesTTL, "cache-movies-ttl", 30*time.Second, "How long single-movie reads are cached (0 disables caching)")
+	flag.IntVar(&cfg.cache.maxEntries, "cache-max-entries", 10000, "Maximum number of movies cached")
+	flag.BoolVar(&cfg.cache.invalidation, "cache-invalidation", true, "Send cache invalidations to the other instances over Postgres NOTIFY")
+
+	flag.Func("experiments", "A/B experiments and their weighted variants (e.g. \"search=control:90,fuzzy:10 poster=small,large\")", func(val string) error {
+		experiments, err := experiment.Parse(val)
+		cfg.experiments.defined = experiments
+		return err
+	})
+
+	flag.DurationVar(&cfg.shutdown.readinessDelay, "shutdown-readiness-delay", 0, "Time to report not-ready before draining on shutdown")
+	flag.DurationVar(&cfg.shutdown.drainTimeout, "shutdown-drain-timeout", 20*time.Second, "Maximum time to wait for in-flight requests on shutdown")
+	flag.DurationVar(&cfg.shutdown.backgroundTimeout, "shutdown-background-timeout", 20*time.Second, "Maximum time to wait for background tasks on shutdown")
+
+	flag.IntVar(&cfg.breaker.threshold, "breaker-threshold", 5, "Consecutive database or mailer failures before failing fast (0 disables)")
+	flag.DurationVar(&cfg.breaker.cooldown, "breaker-cooldown", 30*time.Second, "How long to fail fast before probing a failing dependency again")
+
+	flag.IntVar(&cfg.shed.maxConcurrent, "shed-max-concurrent", 0, "Maximum requests handled at once before queueing (0 disables load shedding)")
+	flag.IntVar(&cfg.shed.maxQueue, "shed-max-queue", 100, "Maximum requests waiting for a slot before shedding straight away")
+	flag.DurationVar(&cfg.shed.queueTimeout, "shed-queue-timeout", 100*time.Millisecond, "How long a request waits for a slot before being shed")
+
+	flag.IntVar(&cfg.queryCost.maxPageSize, "query-max-page-size", 100, "Largest page_size list requests may ask for (0 is unlimited)")
+	flag.IntVar(&cfg.queryCost.maxOffset, "query-max-offset", 100000, "Deepest offset list requests may page to (0 is unlimited)")
+	flag.IntVar(&cfg.queryCost.maxUnindexedOffset, "query-max-unindexed-offset", 10000, "Deepest offset list requests sorted without an index may page to (0 is unlimited)")
+
+	flag.StringVar(&cfg.encryption.keys, "encryption-keys", os.Getenv("GREENLIGHT_ENCRYPTION_KEYS"), "Keys encrypting personal data at rest, primary first, as id:base64 or id:kms:base64 (empty disables)")
+	flag.StringVar(&cfg.encryption.indexKey, "encryption-index-key", os.Getenv("GREENLIGHT_ENCRYPTION_INDEX_KEY"), "Key for blind indexes of encrypted data, as base64 or kms:base64")
+	flag.StringVar(&cfg.encryption.kmsRegion, "encryption-kms-region", "us-east-1", "AWS region of the KMS key wrapping the encryption keys")
+	flag.StringVar(&cfg.encryption.accessKeyID, "encryption-kms-access-key-id", os.Getenv("AWS_ACCESS_KEY_ID"), "AWS access key ID for KMS")
+	flag.StringVar(&cfg.encryption.secretAccessKey, "encryption-kms-secret-access-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), "AWS secret access key for KMS")
+	flag.IntVar(&cfg.encryption.rotateBatchSize, "encryption-rotate-batch-size", 500, "Rows re-encrypted per step of a key rotation job")
+
+	flag.BoolVar(&cfg.debug.enabled, "debug-record-enabled", false, "Record sanitized request/response payloads for debugging")
+	flag.Float64Var(&cfg.debug.sampleRate, "debug-record-sample-rate", 0.01, "Fraction of requests to record (0-1)")
+	flag.IntVar(&cfg.debug.bufferSize, "debug-record-buffer-size", 200, "Number of recorded requests to keep")
+	flag.IntVar(&cfg.debug.maxBodyBytes, "debug-record-max-body-bytes", 8192, "Maximum bytes of each recorded body")
+
+	flag.Func("debug-record-routes", "Path prefixes to always record (space separated)", func(val string) error {
+		cfg.debug.routes = strings.Fields(val)
+		return nil
+	})
 
 	flag.Parse()
 
-	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+	logLevel, err := jsonlog.ParseLevel(cfg.log.level)
+	if err != nil {
+		jsonlog.New(os.Stdout, jsonlog.LevelInfo).PrintFatal(err, nil)
+	}
+
+	logSink, err := openLogSink(cfg)
+	if err != nil {
+		jsonlog.New(os.Stdout, jsonlog.LevelInfo).PrintFatal(err, nil)
+	}
+
+	defer logSink.Close()
+
+	logger := jsonlog.New(logSink, logLevel)
+	logger.SetSampling(cfg.log.sampleFirst, cfg.log.sampleEvery)
+
+	err = loadSecrets(flag.CommandLine)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	if missing := missingSecrets(cfg); len(missing) > 0 {
+		logger.PrintFatal(missingSecretsError(missing), nil)
+	}
+
+	logger.PrintInfo("configuration loaded", redactedFlags(flag.CommandLine, false))
+
+	var dbBreaker, mailBreaker *breaker.Breaker
+	if cfg.breaker.threshold > 0 {
+		dbBreaker = breaker.New("database", cfg.breaker.threshold, cfg.breaker.cooldown)
+		mailBreaker = breaker.New("mailer", cfg.breaker.threshold, cfg.breaker.cooldown)
+	}
+
+	var db *sql.DB
+	var models data.Models
+	var registry cluster.Registry
+
+	if cfg.dev {
+		if cfg.env == "production" {
+			logger.PrintFatal(errors.New("-dev cannot be used in production"), nil)
+		}
+
+		models = data.NewMemoryModels()
+		registry = cluster.NewMemoryRegistry()
+
+		err = seedDevUser(models, logger)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	} else {
+		var pool *pgxpool.Pool
+		statementStats := &data.StatementStats{}
+		db, pool, err = openDB(cfg, dbBreaker, statementStats)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
 
-	db, err := openDB(cfg)
+		if pool != nil {
+			defer pool.Close()
+		}
+		defer db.Close()
+
+		logger.PrintInfo("database connection pool established", nil)
+
+		expvar.Publish("database", expvar.Func(func() any {
+			return db.Stats()
+		}))
+
+		if pool != nil {
+			expvar.Publish("database_pool", expvar.Func(func() any {
+				return poolStats(pool)
+			}))
+		}
+
+		expvar.Publish("database_statements", expvar.Func(func() any {
+			return map[string]int64{
+				"timed_out": statementStats.TimedOut.Load(),
+				"cancelled": statementStats.Cancelled.Load(),
+			}
+		}))
+
+		data.ReadRetryPolicy.Attempts = cfg.db.readRetries + 1
+		data.ReadRetryPolicy.BaseDelay = cfg.db.readRetryDelay
+
+		expvar.Publish("database_retries", expvar.Func(func() any {
+			return data.ReadRetries()
+		}))
+
+		keyring, err := openKeyring(cfg)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		models = data.NewModels(db, pool, keyring)
+		registry = cluster.NewSQLRegistry(db)
+	}
+
+	reporter, err := errtrack.New(cfg.errtrack.dsn, version, cfg.env)
 	if err != nil {
 		logger.PrintFatal(err, nil)
 	}
 
-	defer db.Close()
+	verifier, err := captcha.New(cfg.captcha.provider, cfg.captcha.secret)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
 
-	logger.PrintInfo("database connection pool established", nil)
+	if cfg.env == "production" && len(cfg.chaos.rules) > 0 {
+		logger.PrintFatal(errors.New("-chaos-rules cannot be used in production"), nil)
+	}
+
+	if len(cfg.replay.groups) > 0 && cfg.replay.window <= 0 {
+		logger.PrintFatal(errors.New("-replay-window must be positive when -replay-protection is set"), nil)
+	}
+
+	if cfg.password.policy.MaxLength > 72 {
+		logger.PrintFatal(errors.New("-password-max-length cannot be more than 72 bytes"), nil)
+	}
+
+	if cfg.password.argon2Iterations < 1 || cfg.password.argon2Parallelism < 1 || cfg.password.argon2Parallelism > 255 || cfg.password.argon2Memory < 8*cfg.password.argon2Parallelism || cfg.password.argon2Memory > math.MaxUint32 {
+		logger.PrintFatal(errors.New("-password-argon2-iterations must be at least 1, -password-argon2-parallelism from 1 to 255, and -password-argon2-memory at least 8 KiB per thread"), nil)
+	}
+
+	data.PasswordHashing.Memory = uint32(cfg.password.argon2Memory)
+	data.PasswordHashing.Iterations = uint32(cfg.password.argon2Iterations)
+	data.PasswordHashing.Parallelism = uint8(cfg.password.argon2Parallelism)
+	data.LimitPasswordHashingMemory(cfg.password.argon2MemoryLimit)
+
+	var breachChecker pwned.Checker = pwned.NoopChecker{}
+	if cfg.password.breachCheck {
+		breachChecker = pwned.NewRangeChecker(pwned.DefaultEndpoint, cfg.password.breachTimeout)
+	}
+
+	var locator geoip.Locator = geoip.NoopLocator{}
+	if cfg.geoip.database != "" {
+		locator, err = geoip.Open(cfg.geoip.database)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	var videoMetadata oembed.Fetcher = oembed.NoopFetcher{}
+	if cfg.oembed.enabled {
+		videoMetadata = oembed.New(oembed.DefaultEndpoints, cfg.oembed.timeout)
+	}
+
+	mailBackend, err := openMailBackend(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
 
 	expvar.NewString("version").Set(version)
 
@@ -99,19 +718,132 @@ func main() {
 		return runtime.NumGoroutine()
 	}))
 
-	expvar.Publish("database", expvar.Func(func() any {
-		return db.Stats()
-	}))
-
 	expvar.Publish("timestamp", expvar.Func(func() any {
 		return time.Now().Unix()
 	}))
 
+	mail := mailer.New(mailBackend, cfg.smtp.sender, cfg.mailer.timeout, cfg.mailer.retries).WithSuppressionList(models.Users).WithPreferences(models.Users)
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:   cfg,
+		settings: redactedFlags(flag.CommandLine, true),
+		logger:   logger,
+		logSink:  logSink,
+		models:   models,
+		mailer:   mail,
+		recorder: newRequestRecorder(cfg.debug.bufferSize),
+		errtrack: reporter,
+		captcha:  verifier,
+		pwned:    breachChecker,
+		denylist: newDenylist(),
+		nonces:   newNonceStore(),
+		webhooks: webhook.NewClient(cfg.webhooks.timeout, cfg.dev),
+		latency:  newLatencyHistograms(cfg.metrics.latencyBuckets, cfg.metrics.apdexThreshold),
+		geoip:    locator,
+		enricher: enrich.New(cfg.tmdb.token),
+		oembed:   videoMetadata,
+		events:   events.NewBus(),
+		storage:  store,
+		plugins:  plugin.Plugins(),
+
+		imageVariants: cache.NewLRU[imageVariant](cfg.images.variantCacheSize),
+	}
+
+	for _, p := range app.plugins {
+		logger.PrintInfo("plugin loaded", map[string]string{"name": p.Name()})
+	}
+
+	app.jobs = jobs.NewRunner(app.background)
+
+	expvar.Publish("latency", expvar.Func(app.latency.snapshot))
+
+	if cfg.cluster.heartbeatInterval > 0 {
+		app.cluster, err = newClusterNode(cfg, registry)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	if cfg.cache.moviesTTL > 0 {
+		app.movieCache = cache.New[data.Movie](cfg.cache.moviesTTL, cfg.cache.maxEntries)
+
+		expvar.Publish("movie_cache", expvar.Func(func() any {
+			return app.movieCache.Stats()
+		}))
+
+		// In development there is only ever one instance, and no database
+		// to notify through.
+		if cfg.cache.invalidation && !cfg.dev {
+			app.cacheBus, err = cache.NewPostgresBus(db, cfg.db.dsn, app.cacheOrigin(), app.movieCache)
+			if err != nil {
+				logger.PrintFatal(err, nil)
+			}
+			defer app.cacheBus.Close()
+
+			expvar.Publish("cache_invalidations", expvar.Func(func() any {
+				return app.cacheBus.Stats()
+			}))
+		}
+	}
+
+	if cfg.usage.flushInterval > 0 {
+		app.usage = newUsageAggregator()
+	}
+
+	if cfg.watchdog.interval > 0 {
+		app.watchdog = newWatchdog(cfg.watchdog.maxGoroutines, uint64(cfg.watchdog.maxHeapMB)*1024*1024, cfg.watchdog.maxGCPause)
+
+		expvar.Publish("watchdog", expvar.Func(app.watchdog.snapshot))
+	}
+
+	if cfg.breaker.threshold > 0 {
+		app.mailer = app.mailer.WithBreaker(mailBreaker)
+		app.breakers = append(app.breakers, mailBreaker)
+		if !cfg.dev {
+			app.breakers = append(app.breakers, dbBreaker)
+		}
+	}
+
+	expvar.Publish("circuit_breakers", expvar.Func(func() any {
+		return app.breakerStates()
+	}))
+
+	if cfg.ses.eventsTopicARN != "" {
+		app.emailEvents.ses = mailer.NewSESEvents(cfg.ses.eventsTopicARN)
+	}
+
+	if cfg.sendgrid.webhookPublicKey != "" {
+		app.emailEvents.sendgrid, err = mailer.NewSendGridEvents(cfg.sendgrid.webhookPublicKey)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	if cfg.seed.enabled {
+		err = app.seedAndReport()
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		if !cfg.dev {
+			return
+		}
+	}
+
+	if cfg.selftest.enabled {
+		report := app.selfTest(db)
+
+		js, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		fmt.Println(string(js))
+
+		if !report.Passed {
+			logSink.Close()
+			os.Exit(1)
+		}
+		return
 	}
 
 	err = app.serve()
@@ -120,31 +852,198 @@ func main() {
 	}
 }
 
-func openDB(cfg config) (*sql.DB, error) {
+func openMailBackend(cfg config) (mailer.Backend, error) {
+	switch cfg.mailer.backend {
+	case "smtp":
+		return mailer.NewSMTP(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password), nil
+	case "ses":
+		return mailer.NewSES(cfg.ses.region, cfg.ses.accessKeyID, cfg.ses.secretAccessKey), nil
+	case "sendgrid":
+		return mailer.NewSendGrid(cfg.sendgrid.apiKey), nil
+	case "log":
+		return mailer.NewLog(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer backend %q", cfg.mailer.backend)
+	}
+}
 
-	db, err := sql.Open("postgres", cfg.db.dsn)
+func openStore(cfg config) (storage.Store, error) {
+	switch cfg.storage.backend {
+	case storage.BackendLocal:
+		secret := []byte(cfg.storage.secret)
+		if len(secret) == 0 {
+			secret = make([]byte, 32)
+			_, err := rand.Read(secret)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return storage.NewLocal(cfg.storage.dir, cfg.storage.baseURL, secret), nil
+	case storage.BackendS3:
+		if cfg.s3.bucket == "" {
+			return nil, errors.New("-s3-bucket must be set for the s3 storage backend")
+		}
+		return storage.NewS3(cfg.s3.endpoint, cfg.s3.region, cfg.s3.bucket, cfg.s3.accessKeyID, cfg.s3.secretAccessKey, cfg.s3.publicURL), nil
+	default:
+		return nil, fmt.Errorf("%w %q", storage.ErrUnknownBackend, cfg.storage.backend)
+	}
+}
+
+// openKeyring returns the keyring encrypting personal data, or nil if no
+// keys are configured. Keys wrapped by KMS are unwrapped here, once.
+func openKeyring(cfg config) (*fieldcrypt.Keyring, error) {
+	if cfg.encryption.keys == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	kms := fieldcrypt.NewKMS(cfg.encryption.kmsRegion, cfg.encryption.accessKeyID, cfg.encryption.secretAccessKey)
+
+	keys, err := fieldcrypt.ParseKeys(ctx, cfg.encryption.keys, kms)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(cfg.db.maxOpenConns)
+	indexKey, err := fieldcrypt.ParseSecret(ctx, cfg.encryption.indexKey, kms)
+	if err != nil {
+		return nil, fmt.Errorf("fieldcrypt: index key: %w", err)
+	}
 
-	db.SetMaxIdleConns(cfg.db.maxIdleConns)
+	return fieldcrypt.New(keys, indexKey)
+}
+
+func openLogSink(cfg config) (jsonlog.Sink, error) {
+	var sinks []jsonlog.Sink
+
+	if cfg.log.stdout {
+		sinks = append(sinks, jsonlog.Stdout())
+	}
 
+	if cfg.log.file != "" {
+		file, err := jsonlog.OpenRotatingFile(cfg.log.file, cfg.log.fileMaxSize*1024*1024, cfg.log.fileMaxAge)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, file)
+	}
+
+	if cfg.log.syslogAddr != "" {
+		syslog, err := jsonlog.DialSyslog(cfg.log.syslogAddr, "greenlight")
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, syslog)
+	}
+
+	var sink jsonlog.Sink = jsonlog.NewMultiSink(sinks...)
+
+	if cfg.log.bufferSize > 0 {
+		sink = jsonlog.NewBufferedSink(sink, cfg.log.bufferSize, time.Second)
+	}
+
+	return sink, nil
+}
+
+// openDB connects to PostgreSQL with the configured driver. If b is not nil
+// new connections go through it, so that an unreachable database makes
+// queries fail fast.
+func openDB(cfg config, b *breaker.Breaker, stats *data.StatementStats) (*sql.DB, *pgxpool.Pool, error) {
 	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	db.SetConnMaxIdleTime(duration)
+	var db *sql.DB
+	var pool *pgxpool.Pool
+
+	wrap := func(c driver.Connector) driver.Connector {
+		c = data.WithStatementStats(c, stats)
+		if b == nil {
+			return c
+		}
+		return data.WithBreaker(c, b)
+	}
+
+	switch cfg.db.driver {
+	case "pq":
+		var connector driver.Connector
+		connector, err = pq.NewConnector(cfg.db.dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if cfg.db.statementTimeout > 0 {
+			connector = data.WithStatementTimeout(connector, cfg.db.statementTimeout)
+		}
+
+		db = sql.OpenDB(wrap(connector))
+
+		db.SetMaxOpenConns(cfg.db.maxOpenConns)
+
+		db.SetMaxIdleConns(cfg.db.maxIdleConns)
+
+		db.SetConnMaxIdleTime(duration)
+	case "pgx":
+		// The pgx pool owns the connections; database/sql only borrows
+		// them, so the pool settings are applied here.
+		poolConfig, err := pgxpool.ParseConfig(cfg.db.dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		poolConfig.MaxConns = int32(cfg.db.maxOpenConns)
+		poolConfig.MaxConnIdleTime = duration
+
+		if cfg.db.statementTimeout > 0 {
+			poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.db.statementTimeout.Milliseconds(), 10)
+		}
+
+		pool, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		db = sql.OpenDB(wrap(stdlib.GetPoolConnector(pool)))
+		db.SetMaxIdleConns(0)
+		db.SetMaxOpenConns(cfg.db.maxOpenConns)
+	default:
+		return nil, nil, fmt.Errorf("unknown database driver %q", cfg.db.driver)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	err = db.PingContext(ctx)
 	if err != nil {
-		return nil, err
+		db.Close()
+		if pool != nil {
+			pool.Close()
+		}
+		return nil, nil, err
 	}
 
-	return db, nil
+	return db, pool, nil
+}
+
+// poolStats reports the pgx pool counters which database/sql cannot see,
+// such as how long requests wait to acquire a connection.
+func poolStats(pool *pgxpool.Pool) map[string]any {
+	stat := pool.Stat()
+
+	return map[string]any{
+		"acquire_count":              stat.AcquireCount(),
+		"acquire_duration_ms":        stat.AcquireDuration().Milliseconds(),
+		"acquired_conns":             stat.AcquiredConns(),
+		"canceled_acquire_count":     stat.CanceledAcquireCount(),
+		"constructing_conns":         stat.ConstructingConns(),
+		"empty_acquire_count":        stat.EmptyAcquireCount(),
+		"idle_conns":                 stat.IdleConns(),
+		"max_conns":                  stat.MaxConns(),
+		"max_idle_destroy_count":     stat.MaxIdleDestroyCount(),
+		"max_lifetime_destroy_count": stat.MaxLifetimeDestroyCount(),
+		"new_conns_count":            stat.NewConnsCount(),
+		"total_conns":                stat.TotalConns(),
+	}
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/jobs"
+)
+
+// runMaintenanceHandler starts a database maintenance task as a background
+// job, with a step for each of its statements. It responds with 202 and the
+// job's location, where the progress can be followed.
+func (app *application) runMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	task := httprouter.ParamsFromContext(r.Context()).ByName("task")
+
+	if _, ok := data.MaintenanceTasks[task]; !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	job, err := app.startMaintenance(task)
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrAlreadyRunning):
+			app.jobAlreadyRunningResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.logger.PrintInfo("maintenance task started", map[string]string{
+		"task":    task,
+		"job_id":  strconv.FormatInt(job.ID, 10),
+		"user_id": strconv.FormatInt(app.contextGetUser(r).ID, 10),
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/jobs/%d", job.ID))
+
+	err = app.writeJSON(w, r, http.StatusAccepted, envelope{"job": job}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// startMaintenance starts one of data.MaintenanceTasks as a job with a step
+// for each of its statements.
+func (app *application) startMaintenance(task string) (jobs.Job, error) {
+	statements := data.MaintenanceTasks[task]
+
+	steps := make([]jobs.Step, len(statements))
+	for i, statement := range statements {
+		statement := statement
+		steps[i] = jobs.Step{Name: statement, Run: func() error { return app.models.Maintenance.Exec(statement) }}
+	}
+
+	return app.jobs.Start(task, steps)
+}
+
+func (app *application) listMaintenanceTasksHandler(w http.ResponseWriter, r *http.Request) {
+	tasks := make([]string, 0, len(data.MaintenanceTasks))
+	for task := range data.MaintenanceTasks {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	err := app.writeJSON(w, r, http.StatusOK, envelope{"tasks": tasks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	job, ok := app.jobs.Get(id)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"job": job}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/jobs"
+)
+
+// blockingMaintenanceModel runs each statement once a value is sent on
+// release, failing the ones in fail.
+type blockingMaintenanceModel struct {
+	release chan struct{}
+	fail    map[string]bool
+}
+
+func (m *blockingMaintenanceModel) Exec(statement string) error {
+	<-m.release
+	if m.fail[statement] {
+		return errors.New("statement failed")
+	}
+	return nil
+}
+
+func TestMaintenanceTasks(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(editor.ID, "admin:read", "admin:write"); err != nil {
+		t.Fatal(err)
+	}
+
+	maintenance := &blockingMaintenanceModel{
+		release: make(chan struct{}),
+		fail:    map[string]bool{"VACUUM ANALYZE tokens": true},
+	}
+	app.models.Maintenance = maintenance
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _ := ts.do(t, http.MethodPost, "/v1/admin/maintenance/drop-everything", token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, body := ts.do(t, http.MethodPost, "/v1/admin/maintenance/reindex-search", token, "")
+	assert.Equal(t, code, http.StatusAccepted)
+	jobPath := fmt.Sprintf("/v1/admin/jobs/%v", body["job"].(map[string]any)["id"])
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/admin/maintenance/reindex-search", token, "")
+	assert.Equal(t, code, http.StatusConflict)
+
+	job := func(path string) map[string]any {
+		t.Helper()
+		code, body := ts.do(t, http.MethodGet, path, token, "")
+		assert.Equal(t, code, http.StatusOK)
+		return body["job"].(map[string]any)
+	}
+
+	// waitFor polls the job until it has done the given number of steps or
+	// finished.
+	waitFor := func(path string, done float64) map[string]any {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			j := job(path)
+			if j["done"].(float64) >= done || j["status"] != jobs.StatusRunning || time.Now().After(deadline) {
+				return j
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	maintenance.release <- struct{}{}
+	j := waitFor(jobPath, 1)
+	assert.Equal(t, j["done"].(float64), 1)
+	assert.Equal(t, j["total"].(float64), 2)
+	assert.Equal(t, j["status"].(string), jobs.StatusRunning)
+
+	maintenance.release <- struct{}{}
+	j = waitFor(jobPath, 2)
+	assert.Equal(t, j["status"].(string), jobs.StatusSucceeded)
+
+	// A failing statement ends the job.
+	code, body = ts.do(t, http.MethodPost, "/v1/admin/maintenance/vacuum-analyze", token, "")
+	assert.Equal(t, code, http.StatusAccepted)
+	jobPath = fmt.Sprintf("/v1/admin/jobs/%v", body["job"].(map[string]any)["id"])
+
+	close(maintenance.release)
+	j = waitFor(jobPath, 5)
+	assert.Equal(t, j["status"].(string), jobs.StatusFailed)
+	assert.Equal(t, j["done"].(float64), 2)
+	assert.Equal(t, j["error"].(string), "statement failed")
+}
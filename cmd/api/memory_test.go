@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+// newMemoryTestApplication returns a test application along with an
+// authentication token for an editor in organization 1.
+func newMemoryTestApplication(t *testing.T) (*application, string) {
+	app := newTestApplication(t)
+
+	user := &data.User{Name: "Editor", Email: "editor@example.com", Locale: "en", Activated: true}
+	if err := user.Password.Set("pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(user.ID, "movies:read", "movies:write", "movies:publish"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Organizations.AddMember(1, user.ID, data.RoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := app.models.Tokens.NewAuthentication(user.ID, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return app, token.Plaintext
+}
+
+func (ts *testServer) do(t *testing.T, method, urlPath, token, body string) (int, map[string]any) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]any
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("decoding %q: %v", b, err)
+		}
+	}
+
+	return rs.StatusCode, decoded
+}
+
+func TestMemoryModelsMovieLifecycle(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	code, body := ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": ["animation", "adventure"]}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	id := int64(body["movie"].(map[string]any)["id"].(float64))
+	moviePath := "/v1/movies/" + strconv.FormatInt(id, 10)
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Black Panther", "year": 2018, "runtime": "134 mins", "genres": ["action"]}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/movies?status=draft&title=moana", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["movies"].([]any)), 1)
+
+	code, body = ts.do(t, http.MethodPatch, moviePath, token, `{"year": 2017}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["movie"].(map[string]any)["version"].(float64), 2)
+
+	code, _ = ts.do(t, http.MethodPut, moviePath+"/status", token, `{"status": "published"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/movies?genres=animation", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["movies"].([]any)), 1)
+
+	code, body = ts.do(t, http.MethodGet, moviePath+"/history", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["revisions"].([]any)), 2)
+
+	code, body = ts.do(t, http.MethodPost, moviePath+"/revert/1", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["movie"].(map[string]any)["year"].(float64), 2016)
+
+	code, _ = ts.do(t, http.MethodDelete, moviePath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodGet, moviePath, token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestMemoryModelsOrganizationIsolation(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	other := &data.Movie{Title: "Elsewhere", Year: 2020, Runtime: 90, Genres: []string{"drama"}, Status: data.MovieStatusPublished, OrgID: 2}
+	if err := app.models.Movies.Insert(other); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := ts.do(t, http.MethodGet, "/v1/movies/"+strconv.FormatInt(other.ID, 10), token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	count, err := app.models.Movies.Count(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, count, 0)
+}
+
+func TestMemoryModelsEditConflict(t *testing.T) {
+	models := data.NewMemoryModels()
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusDraft, OrgID: 1}
+	if err := models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := models.Movies.Get(1, movie.ID)
+	second, _ := models.Movies.Get(1, movie.ID)
+
+	first.Year = 2017
+	if err := models.Movies.Update(first, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	second.Year = 2018
+	if err := models.Movies.Update(second, 0); !errors.Is(err, data.ErrEditConflict) {
+		t.Errorf("got %v; want %v", err, data.ErrEditConflict)
+	}
+
+	duplicate := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusDraft, OrgID: 1, IMDbID: "tt3521164"}
+	if err := models.Movies.Insert(duplicate); err != nil {
+		t.Fatal(err)
+	}
+	duplicate.ID = 0
+	if err := models.Movies.Insert(duplicate); !errors.Is(err, data.ErrDuplicateIMDbID) {
+		t.Errorf("got %v; want %v", err, data.ErrDuplicateIMDbID)
+	}
+}
+
+func TestMemoryModelsClientUUIDs(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.config.movies.uuidStrategy = data.UUIDOrdered
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	const uuid = "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b"
+	input := `{"uuid": "` + uuid + `", "title": "Moana", "year": 2016, "runtime": "107 mins"}`
+
+	code, body := ts.do(t, http.MethodPost, "/v1/movies", token, input)
+	assert.Equal(t, code, http.StatusCreated)
+	movie := body["movie"].(map[string]any)
+	assert.Equal(t, movie["uuid"].(string), uuid)
+
+	// Repeating the request returns the movie it created.
+	code, body = ts.do(t, http.MethodPost, "/v1/movies", token, input)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["movie"].(map[string]any)["id"].(float64), movie["id"].(float64))
+
+	code, body = ts.do(t, http.MethodGet, "/v1/movies/"+uuid, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["movie"].(map[string]any)["title"].(string), "Moana")
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/movies", token, `{"uuid": "not-a-uuid", "title": "Moana", "year": 2016, "runtime": "107 mins"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body = ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Arrival", "year": 2016, "runtime": "116 mins"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	generated := body["movie"].(map[string]any)["uuid"].(string)
+	assert.Equal(t, data.UUIDRX.MatchString(generated), true)
+	assert.Equal(t, generated[14:15], "7")
+}
+
+func TestMemoryModelsAgeRatingsAndSearch(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	for _, input := range []string{
+		`{"title": "Ocean Waves", "year": 1993, "runtime": "72 mins", "age_rating": "PG"}`,
+		`{"title": "Moana", "year": 2016, "runtime": "107 mins", "tagline": "The ocean is calling", "age_rating": "PG"}`,
+		`{"title": "Jaws", "year": 1975, "runtime": "124 mins", "synopsis": "A shark terrorizes an ocean resort town.", "age_rating": "GB-12A"}`,
+	} {
+		code, _ := ts.do(t, http.MethodPost, "/v1/movies", token, input)
+		assert.Equal(t, code, http.StatusCreated)
+	}
+
+	code, body := ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Arrival", "year": 2016, "runtime": "116 mins", "age_rating": "PG-14"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "age_rating")
+
+	titles := func(path string) string {
+		t.Helper()
+		code, body := ts.do(t, http.MethodGet, path, token, "")
+		assert.Equal(t, code, http.StatusOK)
+		var titles []string
+		for _, movie := range body["movies"].([]any) {
+			titles = append(titles, movie.(map[string]any)["title"].(string))
+		}
+		return strings.Join(titles, ", ")
+	}
+
+	assert.Equal(t, titles("/v1/movies?status=draft&title=ocean&sort=-id"), "Jaws, Moana, Ocean Waves")
+	assert.Equal(t, titles("/v1/movies?status=draft&title=ocean&sort=-relevance"), "Ocean Waves, Moana, Jaws")
+	assert.Equal(t, titles("/v1/movies?status=draft&age_rating=PG"), "Ocean Waves, Moana")
+	assert.Equal(t, titles("/v1/movies?status=draft&age_rating=GB-12A,R"), "Jaws")
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/movies?status=draft&age_rating=X", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
+
+func TestMemoryModelsCreatedRange(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(editor.ID, "admin:read"); err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _ := ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Moana", "year": 2016, "runtime": "107 mins"}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	time.Sleep(5 * time.Millisecond)
+	since := url.QueryEscape(time.Now().Format(time.RFC3339Nano))
+	time.Sleep(5 * time.Millisecond)
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Arrival", "year": 2016, "runtime": "116 mins"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	if err := app.models.Users.Insert(&data.User{Name: "Late", Email: "late@example.com", Locale: "en"}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := ts.do(t, http.MethodGet, "/v1/movies?status=draft&created_after="+since, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	movies := body["movies"].([]any)
+	assert.Equal(t, len(movies), 1)
+	assert.Equal(t, movies[0].(map[string]any)["title"].(string), "Arrival")
+
+	code, body = ts.do(t, http.MethodGet, "/v1/movies?status=draft&created_before="+since, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["movies"].([]any)[0].(map[string]any)["title"].(string), "Moana")
+
+	code, body = ts.do(t, http.MethodGet, "/v1/admin/users?created_after="+since, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	users := body["users"].([]any)
+	assert.Equal(t, len(users), 1)
+	assert.Equal(t, users[0].(map[string]any)["email"].(string), "late@example.com")
+
+	code, body = ts.do(t, http.MethodGet, "/v1/admin/users?created_after=yesterday&created_before=2024-01-01", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "created_after:must be an RFC 3339 timestamp")
+	assert.StringContains(t, fmt.Sprint(body["error"]), "created_before:must be an RFC 3339 timestamp")
+
+	code, body = ts.do(t, http.MethodGet, "/v1/movies?created_after=2024-02-01T00:00:00Z&created_before=2024-01-01T00:00:00Z", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "must be later than created_after")
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"expvar"
 	"fmt"
-	"net" // New import
 	"net/http"
+	"regexp"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync" // New import
@@ -14,18 +17,58 @@ import (
 	"github.com/felixge/httpsnoop"
 	"golang.org/x/time/rate"
 	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/errtrack"
 	"greenlight.bcc/internal/validator"
 )
 
+var requestIDRX = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+func (app *application) initRequestMeta(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if !validator.Matches(id, requestIDRX) {
+			b := make([]byte, 8)
+			if _, err := rand.Read(b); err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-Id", id)
+
+		meta := &requestMeta{id: id}
+		if ip, err := app.clientIP(r); err == nil {
+			meta.clientIP = ip.String()
+		}
+
+		r = app.contextSetRequestMeta(r, meta)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
+	totalPanicsRecovered := publishInt("total_panics_recovered")
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 
 			if err := recover(); err != nil {
+				stack := debug.Stack()
+
+				totalPanicsRecovered.Add(1)
 
 				w.Header().Set("Connection", "close")
 
-				app.serverErrorResponse(w, r, fmt.Errorf("%s", err))
+				properties := app.requestProperties(r)
+				properties["panic"] = "true"
+
+				panicErr := fmt.Errorf("%s", err)
+				app.logger.PrintError(panicErr, properties)
+				app.reportError(r, panicErr, errtrack.LevelFatal, stack)
+
+				app.errorResponse(w, r, http.StatusInternalServerError, serverErrorMessage)
 			}
 		}()
 
@@ -56,32 +99,75 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 			mu.Unlock()
 		}
 	}()
+	denylistRefusals := publishInt("abuse_denylist_refusals")
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
-			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if app.config.limiter.enabled || app.config.honeypot.banDuration > 0 {
+			addr, err := app.clientIP(r)
 			if err != nil {
 				app.serverErrorResponse(w, r, err)
 				return
 			}
-			mu.Lock()
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
-				}
+			ip := addr.String()
+
+			now := time.Now()
+
+			// Clients caught by the honeypot are refused outright.
+			if until, refused := app.denylist.refused(ip, now); refused {
+				denylistRefusals.Add(1)
+				app.rateLimitExceededResponse(w, r, throttle{
+					limit:      app.config.limiter.burst,
+					reset:      until.Sub(now),
+					retryAfter: until.Sub(now),
+				})
+				return
 			}
 
-			clients[ip].lastSeen = time.Now()
-			if !clients[ip].limiter.Allow() {
+			if app.config.limiter.enabled {
+				mu.Lock()
+				if _, found := clients[ip]; !found {
+					clients[ip] = &client{
+						limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
+					}
+				}
+
+				clients[ip].lastSeen = now
+				if !clients[ip].limiter.AllowN(now, 1) {
+					t := limiterThrottle(clients[ip].limiter, now)
+					mu.Unlock()
+					app.rateLimitExceededResponse(w, r, t)
+					return
+				}
 				mu.Unlock()
-				app.rateLimitExceededResponse(w, r)
-				return
 			}
-			mu.Unlock()
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// limiterThrottle describes the state of a limiter which has just refused
+// a request at now.
+func limiterThrottle(limiter *rate.Limiter, now time.Time) throttle {
+	tokens := limiter.TokensAt(now)
+	if tokens < 0 {
+		tokens = 0
+	}
+
+	t := throttle{limit: limiter.Burst(), remaining: int(tokens)}
+
+	// With no refill rate the limit never resets, so a minute is as good
+	// a hint as any.
+	perSecond := float64(limiter.Limit())
+	if perSecond <= 0 {
+		t.reset, t.retryAfter = time.Minute, time.Minute
+		return t
+	}
+
+	t.reset = time.Duration((float64(t.limit) - tokens) / perSecond * float64(time.Second))
+	t.retryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	return t
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
@@ -119,8 +205,19 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		if user.Banned {
+			app.bannedAccountResponse(w, r)
+			return
+		}
+
 		r = app.contextSetUser(r, user)
 
+		// Every request made with an impersonation token is logged so that
+		// support actions can be told apart from the user's own.
+		if user.ImpersonatorID != 0 {
+			app.logger.PrintInfo("impersonated request", app.requestProperties(r))
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -178,32 +275,65 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 		w.Header().Add("Vary", "Access-Control-Request-Method")
 		origin := r.Header.Get("Origin")
 		if origin != "" {
-			for i := range app.config.cors.trustedOrigins {
-				if origin == app.config.cors.trustedOrigins[i] {
-					w.Header().Set("Access-Control-Allow-Origin", origin)
+			methods, headers, ok := app.corsPolicy(origin)
+			if !ok {
+				if app.config.cors.strict {
+					app.originNotAllowedResponse(w, r)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
 
-					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "Link, X-Total-Count, X-Experiments, X-Anonymous-ID, ETag")
 
-						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 
-						w.WriteHeader(http.StatusOK)
-						return
-					}
-					break
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+
+				if app.config.cors.maxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(app.config.cors.maxAge.Seconds())))
 				}
+
+				w.WriteHeader(http.StatusOK)
+				return
 			}
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+func (app *application) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.inFlight.Add(1)
+		defer app.inFlight.Add(-1)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func publishInt(name string) *expvar.Int {
+	if v, ok := expvar.Get(name).(*expvar.Int); ok {
+		v.Set(0)
+		return v
+	}
+	return expvar.NewInt(name)
+}
+
+func publishMap(name string) *expvar.Map {
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return v.Init()
+	}
+	return expvar.NewMap(name)
+}
+
 func (app *application) metrics(next http.Handler) http.Handler {
-	totalRequestsReceived := expvar.NewInt("total_requests_received")
-	totalResponsesSent := expvar.NewInt("total_responses_sent")
-	totalProcessingTimeMicroseconds := expvar.NewInt("total_processing_time_μs")
+	totalRequestsReceived := publishInt("total_requests_received")
+	totalResponsesSent := publishInt("total_responses_sent")
 
-	totalResponsesSentByStatus := expvar.NewMap("total_responses_sent_by_status")
+	totalResponsesSentByStatus := publishMap("total_responses_sent_by_status")
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		totalRequestsReceived.Add(1)
@@ -212,8 +342,18 @@ func (app *application) metrics(next http.Handler) http.Handler {
 
 		totalResponsesSent.Add(1)
 
-		totalProcessingTimeMicroseconds.Add(metrics.Duration.Microseconds())
-
 		totalResponsesSentByStatus.Add(strconv.Itoa(metrics.Code), 1)
+
+		meta := app.contextGetRequestMeta(r)
+
+		var route string
+		if meta != nil {
+			route = meta.route
+		}
+		app.latency.observe(r.Method, route, metrics.Code, metrics.Duration)
+
+		if meta != nil && app.usage != nil {
+			app.usage.record(meta.userID, meta.orgID, metrics.Code, time.Now())
+		}
 	})
 }
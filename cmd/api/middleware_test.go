@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"expvar"
+	"greenlight.bcc/internal/assert"
 	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/errtrack"
 	"greenlight.bcc/internal/jsonlog"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -178,9 +183,6 @@ func TestRequirePermission(t *testing.T) {
 		Version:   1,
 	}
 
-	mockPermissions := data.MockPermissionModel{}
-	mockPermissions.GetAllForUser(testUser.ID)
-
 	app := newTestApplication(t)
 
 	nextHandlerCalled := false
@@ -249,6 +251,18 @@ func (m *MockedUsersModel) Insert(user *data.User) error {
 	return nil
 }
 
+func (m *MockedUsersModel) InsertWithInvitation(user *data.User, code string) error {
+	return nil
+}
+
+func (m *MockedUsersModel) Get(id int64) (*data.User, error) {
+	return nil, data.ErrRecordNotFound
+}
+
+func (m *MockedUsersModel) GetAll(created data.CreatedRange, filters data.Filters) ([]*data.User, data.Metadata, error) {
+	return nil, data.Metadata{}, nil
+}
+
 func (m *MockedUsersModel) GetByEmail(email string) (*data.User, error) {
 	return nil, nil
 }
@@ -256,6 +270,42 @@ func (m *MockedUsersModel) Update(user *data.User) error {
 	return nil
 }
 
+func (m *MockedUsersModel) MarkEmailUndeliverable(email string) error {
+	return nil
+}
+
+func (m *MockedUsersModel) Ban(id int64) error {
+	return nil
+}
+
+func (m *MockedUsersModel) EmailUndeliverable(email string) (bool, error) {
+	return false, nil
+}
+
+func (m *MockedUsersModel) GetPreferences(userID int64) (*data.NotificationPreferences, error) {
+	return nil, nil
+}
+
+func (m *MockedUsersModel) UpdatePreferences(userID int64, prefs *data.NotificationPreferences) error {
+	return nil
+}
+
+func (m *MockedUsersModel) EmailAllowed(email, category string) (bool, error) {
+	return true, nil
+}
+
+func (m *MockedUsersModel) RehashPassword(user *data.User, plaintextPassword string) error {
+	return nil
+}
+
+func (m *MockedUsersModel) CountStaleEncryption() (int, error) {
+	return 0, nil
+}
+
+func (m *MockedUsersModel) ReencryptBatch(limit int) (int, error) {
+	return 0, nil
+}
+
 func (m *MockedUsersModel) GetForToken(tokenScope string, tokenPlaintext string) (*data.User, error) {
 	switch tokenPlaintext {
 	case "ValidTokenqwerrewwerewqqwe":
@@ -442,6 +492,34 @@ func TestRateLimit_Enabled_Exceeded(t *testing.T) {
 	}
 }
 
+func TestRateLimitHeaders(t *testing.T) {
+	app := newTestApplicationWithLimit(0.5, 2, true)
+	ts := httptest.NewServer(app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})))
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, resp.StatusCode, http.StatusTooManyRequests)
+	assert.Equal(t, resp.Header.Get("Retry-After"), "2")
+	assert.Equal(t, resp.Header.Get("X-RateLimit-Limit"), "2")
+	assert.Equal(t, resp.Header.Get("X-RateLimit-Remaining"), "0")
+	assert.Equal(t, resp.Header.Get("X-RateLimit-Reset"), "4")
+}
+
 func TestRateLimit_Enabled_BadRemoteAddr(t *testing.T) {
 	app := newTestApplicationWithLimit(1, 1, true)
 	app.logger = jsonlog.New(os.Stdout, jsonlog.LevelInfo)
@@ -467,3 +545,237 @@ func TestRateLimit_Enabled_BadRemoteAddr(t *testing.T) {
 		t.Errorf("expected status 500, got %d", resp.Code)
 	}
 }
+
+func TestRecoverPanicLogsRequestContext(t *testing.T) {
+	var buf bytes.Buffer
+
+	app := newTestApplication(t)
+	app.logger = jsonlog.New(&buf, jsonlog.LevelInfo)
+
+	router := app.newRouter()
+	router.HandlerFunc(http.MethodGet, "/v1/panic/:id", func(w http.ResponseWriter, r *http.Request) {
+		app.contextSetUser(r, &data.User{ID: 42})
+		panic("something went wrong")
+	})
+
+	handler := app.initRequestMeta(app.recoverPanic(router))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/panic/1", nil)
+	r.Header.Set("X-Request-Id", "req-123")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, w.Code, http.StatusInternalServerError)
+	assert.Equal(t, w.Header().Get("X-Request-Id"), "req-123")
+
+	var entry struct {
+		Message    string            `json:"message"`
+		Properties map[string]string `json:"properties"`
+		Trace      string            `json:"trace"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, entry.Message, "something went wrong")
+	assert.Equal(t, entry.Properties["request_id"], "req-123")
+	assert.Equal(t, entry.Properties["route"], "/v1/panic/:id")
+	assert.Equal(t, entry.Properties["user_id"], "42")
+	assert.StringContains(t, entry.Trace, "TestRecoverPanicLogsRequestContext")
+	assert.Equal(t, expvar.Get("total_panics_recovered").String(), "1")
+}
+
+type recordingReporter struct {
+	mu     sync.Mutex
+	events []errtrack.Event
+}
+
+func (rr *recordingReporter) Report(event errtrack.Event) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.events = append(rr.events, event)
+	return nil
+}
+
+func TestErrorReporting(t *testing.T) {
+	reporter := &recordingReporter{}
+
+	app := newTestApplication(t)
+	app.errtrack = reporter
+
+	router := app.newRouter()
+	router.HandlerFunc(http.MethodGet, "/v1/panic", func(w http.ResponseWriter, r *http.Request) {
+		app.contextSetUser(r, &data.User{ID: 7})
+		panic("boom")
+	})
+	router.HandlerFunc(http.MethodGet, "/v1/error", func(w http.ResponseWriter, r *http.Request) {
+		app.serverErrorResponse(w, r, errors.New("database unavailable"))
+	})
+
+	handler := app.initRequestMeta(app.recoverPanic(router))
+
+	for _, path := range []string{"/v1/panic", "/v1/error"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	app.wg.Wait()
+
+	assert.Equal(t, len(reporter.events), 2)
+
+	events := map[string]errtrack.Event{}
+	for _, event := range reporter.events {
+		events[event.Tags["route"]] = event
+	}
+
+	assert.Equal(t, events["/v1/panic"].Level, errtrack.LevelFatal)
+	assert.Equal(t, events["/v1/panic"].Err.Error(), "boom")
+	assert.Equal(t, events["/v1/panic"].UserID, int64(7))
+	assert.Equal(t, events["/v1/error"].Level, errtrack.LevelError)
+	assert.Equal(t, events["/v1/error"].Err.Error(), "database unavailable")
+}
+
+func TestEnableCORSPolicies(t *testing.T) {
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name        string
+		origin      string
+		strict      bool
+		wantCode    int
+		wantOrigin  string
+		wantMethods string
+	}{
+		{"wildcard subdomain", "https://app.example.org", false, http.StatusOK, "https://app.example.org", defaultCORSMethods},
+		{"wildcard apex", "https://example.org", false, http.StatusOK, "", ""},
+		{"wildcard wrong scheme", "http://app.example.org", false, http.StatusOK, "", ""},
+		{"per-origin rule", "https://admin.example.net", false, http.StatusOK, "https://admin.example.net", "GET, PUT"},
+		{"untrusted lenient", "https://evil.test", false, http.StatusOK, "", ""},
+		{"untrusted strict", "https://evil.test", true, http.StatusForbidden, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.cors.trustedOrigins = []string{"https://*.example.org"}
+			app.config.cors.maxAge = 10 * time.Minute
+			app.config.cors.strict = tt.strict
+
+			rule, err := parseCORSRule("https://admin.example.net;get,put;Authorization")
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.config.cors.rules = []corsRule{rule}
+
+			r := httptest.NewRequest(http.MethodOptions, "http://example.com", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", "PUT")
+
+			w := httptest.NewRecorder()
+			app.enableCORS(nextHandler).ServeHTTP(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin"), tt.wantOrigin)
+			assert.Equal(t, w.Header().Get("Access-Control-Allow-Methods"), tt.wantMethods)
+
+			if tt.wantOrigin != "" {
+				assert.Equal(t, w.Header().Get("Access-Control-Max-Age"), "600")
+			}
+		})
+	}
+}
+
+func TestRestrictIPs(t *testing.T) {
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		remoteAddr   string
+		forwardedFor string
+		wantCode     int
+	}{
+		{"unrestricted path", "/v1/movies", "203.0.113.9:1234", "", http.StatusOK},
+		{"allowed address", "/v1/admin/log-level", "10.0.0.5:1234", "", http.StatusOK},
+		{"denied address", "/v1/admin/log-level", "10.0.0.66:1234", "", http.StatusForbidden},
+		{"address outside allowlist", "/debug/vars", "203.0.113.9:1234", "", http.StatusForbidden},
+		{"forwarded from trusted proxy", "/debug/vars", "192.0.2.1:1234", "203.0.113.9, 10.0.0.5", http.StatusOK},
+		{"forwarded through trusted proxies", "/debug/vars", "192.0.2.1:1234", "10.0.0.5, 192.0.2.2", http.StatusOK},
+		{"forwarded from untrusted proxy", "/debug/vars", "203.0.113.9:1234", "10.0.0.5", http.StatusForbidden},
+		{"real IP from trusted proxy", "/debug/vars", "192.0.2.1:1234", "", http.StatusOK},
+		{"real IP from untrusted proxy", "/debug/vars", "203.0.113.9:1234", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			var err error
+			app.config.proxies.trusted, err = parseCIDRs("192.0.2.0/24")
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.config.admin.allowCIDRs, err = parseCIDRs("10.0.0.0/8")
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.config.admin.denyCIDRs, err = parseCIDRs("10.0.0.66")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			r.Header.Set("X-Real-IP", "10.0.0.7")
+
+			w := httptest.NewRecorder()
+			app.restrictIPs(nextHandler).ServeHTTP(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+	app := newTestApplicationWithLimit(1, 1, true)
+
+	var err error
+	app.config.proxies.trusted, err = parseCIDRs("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		client   string
+		wantCode int
+	}{
+		{"first client", "203.0.113.1", http.StatusOK},
+		{"second client", "203.0.113.2", http.StatusOK},
+		{"first client again", "203.0.113.1", http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("X-Forwarded-For", tt.client)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantCode, w.Code)
+		}
+	}
+}
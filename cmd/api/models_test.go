@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"greenlight.bcc/internal/data"
+)
+
+// benchmarkModels returns unprepared and prepared models backed by the
+// database in GREENLIGHT_TEST_DB_DSN, skipping the benchmark when it is unset.
+func benchmarkModels(b *testing.B) map[string]data.Models {
+	dsn := os.Getenv("GREENLIGHT_TEST_DB_DSN")
+	if dsn == "" {
+		b.Skip("GREENLIGHT_TEST_DB_DSN is not set")
+	}
+
+	var cfg config
+	cfg.db.driver = "pq"
+	if driver := os.Getenv("GREENLIGHT_TEST_DB_DRIVER"); driver != "" {
+		cfg.db.driver = driver
+	}
+	cfg.db.dsn = dsn
+	cfg.db.maxOpenConns = 25
+	cfg.db.maxIdleConns = 25
+	cfg.db.maxIdleTime = "15m"
+
+	db, pool, err := openDB(cfg, nil, &data.StatementStats{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		db.Close()
+		if pool != nil {
+			pool.Close()
+		}
+	})
+
+	return map[string]data.Models{
+		"unprepared": {Movies: data.MovieModel{DB: db}, Users: data.UserModel{DB: db}},
+		"prepared":   data.NewModels(db, nil, nil),
+	}
+}
+
+func BenchmarkMovieGet(b *testing.B) {
+	for name, models := range benchmarkModels(b) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := models.Movies.Get(1, 1)
+				if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMovieGetAll(b *testing.B) {
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}
+
+	for name, models := range benchmarkModels(b) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _, err := models.Movies.GetAll(data.MovieQuery{OrgID: 1}, filters)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUserGetForToken(b *testing.B) {
+	for name, models := range benchmarkModels(b) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := models.Users.GetForToken(data.ScopeAuthentication, "AAAAAAAAAAAAAAAAAAAAAAAAAA")
+				if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"greenlight.bcc/internal/data"
+)
+
+// cacheOrigin identifies this instance on the invalidation bus, so that it
+// can ignore its own invalidations.
+func (app *application) cacheOrigin() string {
+	if app.cluster != nil {
+		return app.cluster.Self().ID
+	}
+
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func movieCacheKey(orgID, id int64) string {
+	return fmt.Sprintf("movie:%d:%d", orgID, id)
+}
+
+// copyMovie returns a copy of movie which shares nothing with it, so that
+// the copy handed to a handler can be changed without changing the cache.
+func copyMovie(movie data.Movie) *data.Movie {
+	if movie.Genres != nil {
+		movie.Genres = append([]string{}, movie.Genres...)
+	}
+	return &movie
+}
+
+// getMovie is Movies.Get through the movie cache. A read racing a write may
+// cache the movie as it was before the write, but only for the cache's TTL.
+func (app *application) getMovie(orgID, id int64) (*data.Movie, error) {
+	if app.movieCache == nil {
+		return app.models.Movies.Get(orgID, id)
+	}
+
+	key := movieCacheKey(orgID, id)
+
+	if movie, ok := app.movieCache.Get(key); ok {
+		return copyMovie(movie), nil
+	}
+
+	movie, err := app.models.Movies.Get(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	app.movieCache.Set(key, *copyMovie(*movie))
+	return movie, nil
+}
+
+// invalidateMovie evicts the movie from this instance's cache, and from the
+// other instances' in the background.
+func (app *application) invalidateMovie(orgID, id int64) {
+	if app.movieCache == nil {
+		return
+	}
+
+	key := movieCacheKey(orgID, id)
+	app.movieCache.Delete(key)
+
+	if app.cacheBus == nil {
+		return
+	}
+
+	app.background(func() {
+		err := app.cacheBus.Publish(key)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"task": "publish cache invalidation", "key": key})
+		}
+	})
+}
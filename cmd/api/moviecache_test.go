@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/cache"
+	"greenlight.bcc/internal/data"
+)
+
+func TestMovieCacheInvalidation(t *testing.T) {
+	a, token := newMemoryTestApplication(t)
+
+	// b is a second instance sharing a's database.
+	b := newTestApplication(t)
+	b.models = a.models
+
+	hub := cache.NewMemoryHub()
+	for i, app := range []*application{a, b} {
+		app.movieCache = cache.New[data.Movie](time.Minute, 100)
+		app.cacheBus = hub.Connect(fmt.Sprint(i), app.movieCache)
+	}
+
+	tsA := newTestServer(t, a.routesTest())
+	defer tsA.Close()
+	tsB := newTestServer(t, b.routesTest())
+	defer tsB.Close()
+
+	code, body := tsA.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": ["animation"]}`)
+	assert.Equal(t, code, http.StatusCreated)
+	id := int64(body["movie"].(map[string]any)["id"].(float64))
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	title := func(ts *testServer) string {
+		t.Helper()
+		code, body := ts.do(t, http.MethodGet, path, token, "")
+		assert.Equal(t, code, http.StatusOK)
+		return body["movie"].(map[string]any)["title"].(string)
+	}
+
+	assert.Equal(t, title(tsA), "Moana")
+	assert.Equal(t, title(tsB), "Moana")
+	assert.Equal(t, a.movieCache.Stats().Entries, 1)
+	assert.Equal(t, b.movieCache.Stats().Entries, 1)
+
+	code, _ = tsB.do(t, http.MethodPatch, path, token, `{"title": "Moana 2", "genres": ["animation", "adventure"]}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	a.wg.Wait()
+	b.wg.Wait()
+
+	assert.Equal(t, title(tsA), "Moana 2")
+	assert.Equal(t, title(tsB), "Moana 2")
+	assert.Equal(t, b.cacheBus.Stats().Published, int64(1))
+	assert.Equal(t, a.cacheBus.Stats().Received, int64(1))
+
+	// Without the bus, a keeps serving its cached copy until it expires.
+	b.cacheBus = nil
+
+	code, _ = tsB.do(t, http.MethodPatch, path, token, `{"title": "Moana 3"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	assert.Equal(t, title(tsA), "Moana 2")
+	assert.Equal(t, title(tsB), "Moana 3")
+
+	// Handlers change the movies they are given, which must not reach the
+	// cache.
+	cached, ok := a.movieCache.Get(movieCacheKey(1, id))
+	assert.Equal(t, ok, true)
+	movie := copyMovie(cached)
+	movie.Genres[0] = "drama"
+	cached, _ = a.movieCache.Get(movieCacheKey(1, id))
+	assert.Equal(t, cached.Genres[0], "animation")
+}
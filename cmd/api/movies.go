@@ -4,16 +4,27 @@ import (
 	"errors"
 	"fmt"
 	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/jsonpatch"
 	"greenlight.bcc/internal/validator"
 	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
 )
 
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+		Title     string       `json:"title"`
+		Year      int32        `json:"year"`
+		Runtime   data.Runtime `json:"runtime"`
+		Genres    []string     `json:"genres"`
+		IMDbID    string       `json:"imdb_id"`
+		TMDbID    int64        `json:"tmdb_id"`
+		UUID      string       `json:"uuid"`
+		Synopsis  string       `json:"synopsis"`
+		Tagline   string       `json:"tagline"`
+		AgeRating string       `json:"age_rating"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -23,10 +34,18 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	movie := data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:     input.Title,
+		Year:      input.Year,
+		Runtime:   input.Runtime,
+		Genres:    input.Genres,
+		Status:    data.MovieStatusDraft,
+		OrgID:     app.contextGetUser(r).OrgID,
+		IMDbID:    input.IMDbID,
+		TMDbID:    input.TMDbID,
+		UUID:      input.UUID,
+		Synopsis:  input.Synopsis,
+		Tagline:   input.Tagline,
+		AgeRating: input.AgeRating,
 	}
 
 	v := validator.New()
@@ -36,16 +55,59 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// A movie with the client's UUID means the request is being repeated,
+	// so the movie it created is returned instead of a duplicate.
+	if movie.UUID != "" {
+		existing, err := app.models.Movies.GetByUUID(movie.OrgID, movie.UUID)
+		switch {
+		case err == nil:
+			headers := make(http.Header)
+			headers.Set("Location", fmt.Sprintf("/v1/movies/%d", existing.ID))
+
+			err = app.writeJSON(w, r, http.StatusOK, envelope{"movie": existing}, headers)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	} else {
+		movie.UUID, err = data.NewUUID(app.config.movies.uuidStrategy)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if !app.checkQuota(w, r, quotaMovies) {
+		return
+	}
+
 	err = app.models.Movies.Insert(&movie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateIMDbID):
+			v.AddError("imdb_id", "a movie with this IMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateTMDbID):
+			v.AddError("tmdb_id", "a movie with this TMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateUUID):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	app.publishMovieEvent(events.TypeMovieCreated, &movie)
+
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -53,13 +115,25 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 }
 
 func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
-	id, err := app.readIDParam(r)
-	if err != nil {
-		app.notFoundResponse(w, r)
+	// httprouter cannot register /v1/movies/random and the rankings next to
+	// /v1/movies/:id, so those routes are dispatched from here.
+	switch id := httprouter.ParamsFromContext(r.Context()).ByName("id"); id {
+	case "random":
+		withRoute("/v1/movies/random", http.HandlerFunc(app.randomMovieHandler)).ServeHTTP(w, r)
+		return
+	case data.RankingMostListed, data.RankingTrending:
+		withRoute("/v1/movies/"+id, app.listRankedMoviesHandler(id)).ServeHTTP(w, r)
+		return
+	}
+
+	v := validator.New()
+	loader := app.newMovieLoader(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.readMovieParam(r)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -70,7 +144,42 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if !movie.IsPublished() {
+		canEdit, err := app.userHasPermission(r, "movies:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !canEdit {
+			app.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	err = app.translateMovies(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.addCollections(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = loader.load(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	if movie.Locale != "" {
+		w.Header().Set("Content-Language", movie.Locale)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -83,7 +192,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(app.contextGetUser(r).OrgID, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -93,42 +202,54 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
-	}
+	var input data.MovieChanges
 
-	err = app.readJSON(w, r, &input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
+	v := validator.New()
+
+	switch mt := mediaType(r); {
+	case mt == "application/json" || isMsgpack(mt):
+		err = app.readJSON(w, r, &input)
+	case mt == mediaTypeMergePatch || mt == mediaTypeJSONPatch:
+		input, err = app.readMoviePatch(w, r, movie, v)
+	default:
+		w.Header().Set("Accept-Patch", acceptPatch)
+		app.unsupportedMediaTypeResponse(w, r)
 		return
 	}
-	if input.Title != nil {
-		movie.Title = *input.Title
-	}
-	if input.Year != nil {
-		movie.Year = *input.Year
-	}
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			app.patchTestFailedResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
 	}
-	if input.Genres != nil {
-		movie.Genres = input.Genres
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 
-	v := validator.New()
+	data.ValidateMovieChanges(v, input)
+
+	input.Apply(movie)
+
 	if data.ValidateMovie(v, movie); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateIMDbID):
+			v.AddError("imdb_id", "a movie with this IMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateTMDbID):
+			v.AddError("tmdb_id", "a movie with this TMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -136,7 +257,9 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	app.publishMovieEvent(events.TypeMovieUpdated, movie)
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -149,7 +272,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	err = app.models.Movies.Delete(app.contextGetUser(r).OrgID, id, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -160,7 +283,9 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	app.publishMovieEvent(events.TypeMovieDeleted, &data.Movie{ID: id, OrgID: app.contextGetUser(r).OrgID})
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -168,39 +293,465 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieQuery
 		data.Filters
+		Facets bool
 	}
 
 	v := validator.New()
-	qs := r.URL.Query()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.OrgID = app.contextGetUser(r).OrgID
+	input.Title = qs.String("title", "")
+	input.Genres = qs.CSV("genres", []string{})
+	input.GenresAny = qs.CSV("genres_any", []string{})
+	input.GenresNone = qs.CSV("genres_none", []string{})
+	input.Status = qs.Enum("status", data.MovieStatusPublished, data.MovieStatuses...)
+	input.AgeRatings = qs.CSV("age_rating", []string{})
+	input.CollectionID = qs.Int64("collection", 0)
+	input.CreatedAfter = qs.Time("created_after", time.Time{})
+	input.CreatedBefore = qs.Time("created_before", time.Time{})
+	input.Filters.Page = qs.Int("page", 1)
+	input.Filters.PageSize = qs.Int("page_size", 20)
+	input.Filters.Sort = qs.String("sort", "id")
+	input.Facets = qs.Bool("facets", false)
 
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
-	input.Filters.Page = app.readInt(qs, "page", 1, v)
-	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
-	input.Filters.Sort = app.readString(qs, "sort", "id")
+	loader := app.newMovieLoader(r, v)
 
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime", "-relevance"}
+
+	data.ValidateMovieQuery(v, input.MovieQuery)
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	if !app.withinQueryCost(w, r, input.Filters, "id", "-id") {
+		return
+	}
+
+	if input.Status != data.MovieStatusPublished {
+		canEdit, err := app.userHasPermission(r, "movies:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !canEdit {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	lastModified, err := app.models.Movies.LastModified()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Lists change without their movies being modified, so responses which
+	// include them cannot be revalidated against the movies.
+	revalidate := len(loader.includes) == 0
+
+	headers := make(http.Header)
+	if revalidate {
+		headers.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	headers.Set("Cache-Control", "private, no-cache")
+
+	// Vary is added to rather than replaced, as the middleware sets it too.
+	w.Header().Add("Vary", "Accept-Language")
+
+	if revalidate && notModified(r, lastModified) {
+		for key, value := range headers {
+			w.Header()[key] = value
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if wantsNDJSON(r) {
+		app.streamMoviesResponse(w, r, input.MovieQuery, input.Filters, loader, headers)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieQuery, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if input.Facets {
+		metadata.Facets, err = app.models.Movies.GetFacets(input.MovieQuery)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.translateMovies(r, movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.addCollections(movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = loader.load(movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) randomMovieHandler(w http.ResponseWriter, r *http.Request) {
+	var input data.MovieQuery
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.OrgID = app.contextGetUser(r).OrgID
+	input.Title = qs.String("title", "")
+	input.Genres = qs.CSV("genres", []string{})
+	input.GenresAny = qs.CSV("genres_any", []string{})
+	input.GenresNone = qs.CSV("genres_none", []string{})
+	input.AgeRatings = qs.CSV("age_rating", []string{})
+	input.Status = data.MovieStatusPublished
+
+	if data.ValidateMovieQuery(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.GetRandom(input)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.translateMovies(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.addCollections(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
+
+	w.Header().Add("Vary", "Accept-Language")
+	if movie.Locale != "" {
+		headers.Set("Content-Language", movie.Locale)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateMovieStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(app.contextGetUser(r).OrgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Status != "", "status", "must be provided")
+	v.Check(validator.PermittedValue(input.Status, data.MovieStatuses...), "status", "invalid status value")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	wasPublished := movie.IsPublished()
+
+	err = movie.SetStatus(input.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidStatusTransition):
+			v.AddError("status", fmt.Sprintf("cannot change status from %s to %s", movie.Status, input.Status))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	switch {
+	case movie.IsPublished():
+		app.publishMovieEvent(events.TypeMovieUpdated, movie)
+		if !wasPublished {
+			app.publishMovieEvent(events.TypeMoviePublished, movie)
+		}
+	case wasPublished:
+		app.publishMovieEvent(events.TypeMovieDeleted, movie)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) batchUpdateMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input []data.MovieBatchItem
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovieBatch(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	results, err := app.models.Movies.UpdateBatch(user.OrgID, input, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, result := range results {
+		if result.Status == data.BatchStatusUpdated {
+			app.publishMovieEvent(events.TypeMovieUpdated, result.Movie)
+		}
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// upsertMovieHandler creates or replaces the movie with the given external ID
+// so that catalog sync jobs can send the same request repeatedly. The body is
+// the full representation; an existing movie keeps its status. Keys the API
+// does not know about are ignored, since sync jobs usually forward records
+// from another catalog as they are.
+func (app *application) upsertMovieHandler(w http.ResponseWriter, r *http.Request) {
+	externalID := httprouter.ParamsFromContext(r.Context()).ByName("external_id")
+
+	var input struct {
+		Title   string       `json:"title"`
+		Year    int32        `json:"year"`
+		Runtime data.Runtime `json:"runtime"`
+		Genres  []string     `json:"genres"`
+		IMDbID  string       `json:"imdb_id"`
+		TMDbID  int64        `json:"tmdb_id"`
+	}
+
+	err := app.readJSON(w, r, &input, allowUnknownFields())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	movie := data.Movie{
+		Title:      input.Title,
+		Year:       input.Year,
+		Runtime:    input.Runtime,
+		Genres:     input.Genres,
+		Status:     data.MovieStatusDraft,
+		OrgID:      user.OrgID,
+		ExternalID: externalID,
+		IMDbID:     input.IMDbID,
+		TMDbID:     input.TMDbID,
+	}
+
+	v := validator.New()
+	data.ValidateExternalID(v, movie.ExternalID)
+
+	if data.ValidateMovie(v, &movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Movies.GetByExternalID(movie.OrgID, movie.ExternalID)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		if !app.checkQuota(w, r, quotaMovies) {
+			return
+		}
+		movie.UUID, err = data.NewUUID(app.config.movies.uuidStrategy)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var result string
+
+	err = data.WithRetry(3, func() error {
+		result, err = app.models.Movies.Upsert(&movie, user.ID)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateIMDbID):
+			v.AddError("imdb_id", "a movie with this IMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateTMDbID):
+			v.AddError("tmdb_id", "a movie with this TMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	status := http.StatusOK
+	headers := make(http.Header)
+
+	switch result {
+	case data.UpsertCreated:
+		app.publishMovieEvent(events.TypeMovieCreated, &movie)
+		status = http.StatusCreated
+		headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	case data.UpsertUpdated:
+		app.publishMovieEvent(events.TypeMovieUpdated, &movie)
+	}
+
+	err = app.writeJSON(w, r, status, envelope{"movie": movie, "result": result}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readMovieParam fetches the movie named by the id parameter, which may be
+// its ID or its UUID.
+func (app *application) readMovieParam(r *http.Request) (*data.Movie, error) {
+	orgID := app.contextGetUser(r).OrgID
+
+	if param := httprouter.ParamsFromContext(r.Context()).ByName("id"); validator.Matches(param, data.UUIDRX) {
+		return app.models.Movies.GetByUUID(orgID, param)
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return app.getMovie(orgID, id)
+}
+
+// showMovieByIMDbIDHandler looks up a movie by its IMDb title ID so that
+// integrators can map their catalog onto ours.
+func (app *application) showMovieByIMDbIDHandler(w http.ResponseWriter, r *http.Request) {
+	imdbID := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	v := validator.New()
+	if data.ValidateIMDbID(v, imdbID); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.GetByIMDbID(app.contextGetUser(r).OrgID, imdbID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !movie.IsPublished() {
+		canEdit, err := app.userHasPermission(r, "movies:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !canEdit {
+			app.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	err = app.translateMovies(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	if movie.Locale != "" {
+		w.Header().Set("Content-Language", movie.Locale)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
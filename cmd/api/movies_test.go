@@ -2,15 +2,51 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
 )
 
+// testMovie returns the movie most of the movie handler tests start from.
+func testMovie() data.Movie {
+	return data.Movie{Title: "Test Mock", Year: 2023, Runtime: 105, Genres: []string{"comedy"}}
+}
+
+// racingMovieModel is the in-memory movie model, except that someone else
+// saves each movie just before it is updated, so that updates conflict.
+type racingMovieModel struct {
+	data.MemoryMovieModel
+}
+
+func (m racingMovieModel) Update(movie *data.Movie, editorID int64) error {
+	other := *movie
+	if err := m.MemoryMovieModel.Update(&other, editorID); err != nil {
+		return err
+	}
+	return m.MemoryMovieModel.Update(movie, editorID)
+}
+
+// failingUpdateMovieModel is the in-memory movie model, except that updates
+// fail.
+type failingUpdateMovieModel struct {
+	data.MemoryMovieModel
+}
+
+func (m failingUpdateMovieModel) Update(movie *data.Movie, editorID int64) error {
+	return errDatabaseDown
+}
+
 func TestShowMovie(t *testing.T) {
 	app := newTestApplication(t)
+	id := insertMovie(t, app, testMovie())
+	draftID := insertMovie(t, app, data.Movie{Title: "Unreleased Mock", Year: 2023, Runtime: 90, Status: data.MovieStatusDraft})
 
 	ts := newTestServer(t, app.routesTest())
 	defer ts.Close()
@@ -23,12 +59,13 @@ func TestShowMovie(t *testing.T) {
 	}{
 		{
 			name:     "Valid ID",
-			urlPath:  "/v1/movies/1",
+			urlPath:  fmt.Sprintf("/v1/movies/%d", id),
 			wantCode: http.StatusOK,
+			wantBody: `"title":"Test Mock"`,
 		},
 		{
 			name:     "Non-existent ID",
-			urlPath:  "/v1/movies/4",
+			urlPath:  "/v1/movies/1000",
 			wantCode: http.StatusNotFound,
 		},
 		{
@@ -47,9 +84,9 @@ func TestShowMovie(t *testing.T) {
 			wantCode: http.StatusNotFound,
 		},
 		{
-			name:     "Unexpected error from Model",
-			urlPath:  "/v1/movies/2",
-			wantCode: http.StatusInternalServerError,
+			name:     "Draft hidden from readers",
+			urlPath:  fmt.Sprintf("/v1/movies/%d", draftID),
+			wantCode: http.StatusNotFound,
 		},
 	}
 
@@ -67,6 +104,13 @@ func TestShowMovie(t *testing.T) {
 		})
 	}
 
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, fmt.Sprintf("/v1/movies/%d", id))
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
 }
 
 func TestCreateMovie(t *testing.T) {
@@ -122,14 +166,6 @@ func TestCreateMovie(t *testing.T) {
 			Genres:   validGenres,
 			wantCode: http.StatusBadRequest,
 		},
-		{
-			name:     "Unexpected error from Model",
-			Title:    "error",
-			Year:     validYear,
-			Runtime:  validRuntime,
-			Genres:   validGenres,
-			wantCode: http.StatusInternalServerError,
-		},
 	}
 
 	for _, tt := range tests {
@@ -160,10 +196,20 @@ func TestCreateMovie(t *testing.T) {
 
 		})
 	}
+
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.postForm(t, "/v1/movies", []byte(`{"title": "Test Title", "year": 2021, "runtime": "105 mins"}`))
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
 }
 
 func TestDeleteMovie(t *testing.T) {
 	app := newTestApplication(t)
+	id := insertMovie(t, app, testMovie())
+
 	ts := newTestServer(t, app.routesTest())
 	defer ts.Close()
 
@@ -175,18 +221,18 @@ func TestDeleteMovie(t *testing.T) {
 	}{
 		{
 			name:     "Deleting existing movie",
-			urlPath:  "/v1/movies/1",
+			urlPath:  fmt.Sprintf("/v1/movies/%d", id),
 			wantCode: http.StatusOK,
 		},
 		{
-			name:     "Non-existent ID",
-			urlPath:  "/v1/movies/4",
+			name:     "Deleting it again",
+			urlPath:  fmt.Sprintf("/v1/movies/%d", id),
 			wantCode: http.StatusNotFound,
 		},
 		{
-			name:     "Unexpected error from Model",
-			urlPath:  "/v1/movies/2",
-			wantCode: http.StatusInternalServerError,
+			name:     "Non-existent ID",
+			urlPath:  "/v1/movies/1000",
+			wantCode: http.StatusNotFound,
 		},
 		{
 			name:     "Decimal ID",
@@ -209,10 +255,19 @@ func TestDeleteMovie(t *testing.T) {
 		})
 	}
 
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.deleteReq(t, fmt.Sprintf("/v1/movies/%d", id))
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
 }
 
 func TestUpdateMovie(t *testing.T) {
 	app := newTestApplication(t)
+	path := fmt.Sprintf("/v1/movies/%d", insertMovie(t, app, testMovie()))
+
 	ts := newTestServer(t, app.routesTest())
 	defer ts.Close()
 
@@ -238,7 +293,7 @@ func TestUpdateMovie(t *testing.T) {
 	}{
 		{
 			name:     "Valid submission",
-			urlPath:  "/v1/movies/1",
+			urlPath:  path,
 			Title:    validTitle,
 			Year:     validYear,
 			Runtime:  validRuntime,
@@ -247,7 +302,7 @@ func TestUpdateMovie(t *testing.T) {
 		},
 		{
 			name:     "Empty request body",
-			urlPath:  "/v1/movies/1",
+			urlPath:  path,
 			Title:    nilString,
 			Year:     nilInt,
 			Runtime:  nilString,
@@ -256,7 +311,7 @@ func TestUpdateMovie(t *testing.T) {
 		},
 		{
 			name:     "year < 1888",
-			urlPath:  "/v1/movies/1",
+			urlPath:  path,
 			Title:    validTitle,
 			Year:     1500,
 			Runtime:  validRuntime,
@@ -265,25 +320,16 @@ func TestUpdateMovie(t *testing.T) {
 		},
 		{
 			name:     "Non-existent ID",
-			urlPath:  "/v1/movies/4",
+			urlPath:  "/v1/movies/1000",
 			Title:    validTitle,
 			Year:     validYear,
 			Runtime:  validRuntime,
 			Genres:   validGenres,
 			wantCode: http.StatusNotFound,
 		},
-		{
-			name:     "Edit conflict",
-			urlPath:  "/v1/movies/3",
-			Title:    validTitle,
-			Year:     validYear,
-			Runtime:  validRuntime,
-			Genres:   validGenres,
-			wantCode: http.StatusConflict,
-		},
 		{
 			name:     "test for wrong input",
-			urlPath:  "/v1/movies/1",
+			urlPath:  path,
 			Title:    validTitle,
 			Year:     validYear,
 			Runtime:  validRuntime,
@@ -295,20 +341,6 @@ func TestUpdateMovie(t *testing.T) {
 			urlPath:  "/v1/movies/1.5",
 			wantCode: http.StatusNotFound,
 		},
-		{
-			name:     "Unexpected error after Get method from Model",
-			urlPath:  "/v1/movies/2",
-			wantCode: http.StatusInternalServerError,
-		},
-		{
-			name:     "Unexpected error from Update method from Model",
-			urlPath:  "/v1/movies/10",
-			Title:    validTitle,
-			Year:     validYear,
-			Runtime:  validRuntime,
-			Genres:   validGenres,
-			wantCode: http.StatusInternalServerError,
-		},
 	}
 
 	for _, tt := range tests {
@@ -350,6 +382,140 @@ func TestUpdateMovie(t *testing.T) {
 
 		})
 	}
+
+	valid := []byte(`{"title": "Test Title"}`)
+
+	t.Run("Edit conflict", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.models.Movies = racingMovieModel{app.models.Movies.(data.MemoryMovieModel)}
+		path := fmt.Sprintf("/v1/movies/%d", insertMovie(t, app, testMovie()))
+
+		ts := newTestServer(t, app.routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.patchForm(t, path, valid)
+		assert.Equal(t, code, http.StatusConflict)
+	})
+
+	t.Run("Unexpected error after Get method from Model", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.patchForm(t, path, valid)
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
+
+	t.Run("Unexpected error from Update method from Model", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.models.Movies = failingUpdateMovieModel{app.models.Movies.(data.MemoryMovieModel)}
+		path := fmt.Sprintf("/v1/movies/%d", insertMovie(t, app, testMovie()))
+
+		ts := newTestServer(t, app.routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.patchForm(t, path, valid)
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
+}
+
+func TestUpdateMovieNullVersusAbsent(t *testing.T) {
+	app := newTestApplication(t)
+	path := fmt.Sprintf("/v1/movies/%d", insertMovie(t, app, testMovie()))
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"Year absent", `{}`, http.StatusOK, `"title":"Test Mock","year":2023,"runtime":"105 mins"`},
+		{"Year null", `{"year": null}`, http.StatusOK, `"title":"Test Mock","runtime":"105 mins"`},
+		{"Year set", `{"year": 1999}`, http.StatusOK, `"year":1999`},
+		{"Year invalid", `{"year": 1500}`, http.StatusUnprocessableEntity, `"year":"must be at least 1888"`},
+		{"Genres absent", `{"title": "Renamed"}`, http.StatusOK, `"genres":["comedy"]`},
+		{"Genres null", `{"genres": null}`, http.StatusOK, `"runtime":"105 mins","status"`},
+		{"Genres set", `{"genres": ["drama"]}`, http.StatusOK, `"genres":["drama"]`},
+		{"Genres duplicated", `{"genres": ["drama", "drama"]}`, http.StatusUnprocessableEntity, `"genres":"must not contain duplicate values"`},
+		{"Title null", `{"title": null}`, http.StatusUnprocessableEntity, `"title":"must not be null"`},
+		{"Title empty", `{"title": ""}`, http.StatusUnprocessableEntity, `"title":"must be provided"`},
+		{"Runtime null", `{"runtime": null}`, http.StatusUnprocessableEntity, `"runtime":"must not be null"`},
+		{"IMDb ID null", `{"imdb_id": null}`, http.StatusOK, `"status":"published"`},
+		{"Wrong type", `{"year": "1999"}`, http.StatusBadRequest, `body contains incorrect JSON type`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.patchForm(t, path, []byte(tt.body))
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestPatchMovie(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantBody    string
+	}{
+		{"Merge patch", "application/merge-patch+json", `{"title": "Merged", "year": null}`, http.StatusOK, `"title":"Merged","runtime":"105 mins"`},
+		{"Merge patch with charset", "application/merge-patch+json; charset=utf-8", `{"genres": ["drama"]}`, http.StatusOK, `"genres":["drama"]`},
+		{"Merge patch removing title", "application/merge-patch+json", `{"title": null}`, http.StatusUnprocessableEntity, `"title":"must not be null"`},
+		{"Merge patch changing version", "application/merge-patch+json", `{"version": 7}`, http.StatusUnprocessableEntity, `"version":"cannot be changed"`},
+		{"Merge patch of wrong type", "application/merge-patch+json", `{"year": "soon"}`, http.StatusBadRequest, `incorrect JSON type`},
+		{"JSON patch replace", "application/json-patch+json", `[{"op": "replace", "path": "/title", "value": "Patched"}]`, http.StatusOK, `"title":"Patched"`},
+		{"JSON patch append genre", "application/json-patch+json", `[{"op": "add", "path": "/genres/-", "value": "drama"}]`, http.StatusOK, `"genres":["comedy","drama"]`},
+		{"JSON patch remove year", "application/json-patch+json", `[{"op": "remove", "path": "/year"}]`, http.StatusOK, `"title":"Test Mock","runtime":"105 mins"`},
+		{"JSON patch copy", "application/json-patch+json", `[{"op": "copy", "from": "/title", "path": "/imdb_id"}]`, http.StatusUnprocessableEntity, `"imdb_id":"must look like tt0111161"`},
+		{"JSON patch passing test", "application/json-patch+json", `[{"op": "test", "path": "/version", "value": 1}, {"op": "replace", "path": "/year", "value": 2001}]`, http.StatusOK, `"year":2001`},
+		{"JSON patch failing test", "application/json-patch+json", `[{"op": "test", "path": "/version", "value": 2}, {"op": "replace", "path": "/year", "value": 2001}]`, http.StatusConflict, `test operation failed`},
+		{"JSON patch missing path", "application/json-patch+json", `[{"op": "remove", "path": "/synopsis"}]`, http.StatusUnprocessableEntity, `"patch":"operation 0 (remove /synopsis): member \"synopsis\" does not exist"`},
+		{"JSON patch read-only field", "application/json-patch+json", `[{"op": "replace", "path": "/id", "value": 9}]`, http.StatusUnprocessableEntity, `"id":"cannot be changed"`},
+		{"JSON patch unknown op", "application/json-patch+json", `[{"op": "rename", "path": "/title"}]`, http.StatusUnprocessableEntity, `unknown operation`},
+		{"JSON patch missing value", "application/json-patch+json", `[{"op": "add", "path": "/title"}]`, http.StatusUnprocessableEntity, `value must be provided`},
+		{"JSON patch not an array", "application/json-patch+json", `{"op": "remove", "path": "/title"}`, http.StatusBadRequest, `incorrect JSON type`},
+		{"Unsupported content type", "text/plain", `title=Plain`, http.StatusUnsupportedMediaType, `the text/plain content type is not supported`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := fmt.Sprintf("/v1/movies/%d", insertMovie(t, app, testMovie()))
+
+			req, err := http.NewRequest(http.MethodPatch, ts.URL+path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.StringContains(t, string(body), tt.wantBody)
+
+			if tt.wantCode == http.StatusUnsupportedMediaType {
+				assert.Equal(t, rs.Header.Get("Accept-Patch"), "application/json, application/merge-patch+json, application/json-patch+json")
+			}
+		})
+	}
 }
 
 func TestListMovies(t *testing.T) {
@@ -389,9 +555,29 @@ func TestListMovies(t *testing.T) {
 			wantCode: http.StatusOK,
 		},
 		{
-			name:     "Unexpected error from Model",
-			urlPath:  "/v1/movies?title=error",
-			wantCode: http.StatusInternalServerError,
+			name:     "Invalid status",
+			urlPath:  "/v1/movies?status=deleted",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Drafts require write permission",
+			urlPath:  "/v1/movies?status=draft",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Any and none genre filters",
+			urlPath:  "/v1/movies?genres_any=comedy,drama&genres_none=horror",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Genre both required and excluded",
+			urlPath:  "/v1/movies?genres=comedy&genres_none=comedy",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Duplicate genres_any",
+			urlPath:  "/v1/movies?genres_any=comedy,comedy",
+			wantCode: http.StatusUnprocessableEntity,
 		},
 	}
 
@@ -404,4 +590,459 @@ func TestListMovies(t *testing.T) {
 		})
 	}
 
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, "/v1/movies")
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
+}
+
+func TestListMoviesFacets(t *testing.T) {
+	app := newTestApplication(t)
+	insertMovie(t, app, data.Movie{Title: "Test Mock", Year: 2023, Runtime: 105, Genres: []string{"drama", "comedy"}})
+	insertMovie(t, app, data.Movie{Title: "Test Mock 2", Year: 2022, Runtime: 180, Genres: []string{"drama"}})
+	insertMovie(t, app, data.Movie{Title: "Legends from test mock", Year: 1966, Runtime: 100, Genres: []string{"mystery"}})
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Facets requested",
+			urlPath:  "/v1/movies?facets=true",
+			wantCode: http.StatusOK,
+			wantBody: `"decades":{"1960":1,"2020":2}`,
+		},
+		{
+			name:     "Facets follow the filters",
+			urlPath:  "/v1/movies?title=Test&genres=comedy,drama&facets=true",
+			wantCode: http.StatusOK,
+			wantBody: `"genres":{"comedy":1,"drama":1}`,
+		},
+		{
+			name:     "Invalid facets value",
+			urlPath:  "/v1/movies?facets=maybe",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Facets omitted by default", func(t *testing.T) {
+		_, _, body := ts.get(t, "/v1/movies")
+
+		if strings.Contains(body, `"facets"`) {
+			t.Errorf("got facets in %q; want none", body)
+		}
+	})
+}
+
+func TestRandomMovie(t *testing.T) {
+	app := newTestApplication(t)
+	insertMovie(t, app, testMovie())
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Without filters",
+			urlPath:  "/v1/movies/random",
+			wantCode: http.StatusOK,
+			wantBody: `"title":"Test Mock"`,
+		},
+		{
+			name:     "No matching movie",
+			urlPath:  "/v1/movies/random?title=Nothing",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid genre filters",
+			urlPath:  "/v1/movies/random?genres=drama&genres_none=drama",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, "/v1/movies/random")
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
+}
+
+func TestListMoviesLastModified(t *testing.T) {
+	app := newTestApplication(t)
+	insertMovie(t, app, testMovie())
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	_, header, _ := ts.get(t, "/v1/movies")
+
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		ifModifiedSince string
+		wantCode        int
+	}{
+		{"No conditional header", "", http.StatusOK},
+		{"Unchanged since", lastModified.Format(http.TimeFormat), http.StatusNotModified},
+		{"Client copy is newer", lastModified.Add(24 * time.Hour).Format(http.TimeFormat), http.StatusNotModified},
+		{"Changed since", lastModified.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{"Malformed header", "yesterday", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/movies", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("Last-Modified"), lastModified.Format(http.TimeFormat))
+			assert.Equal(t, rs.Header.Get("Cache-Control"), "private, no-cache")
+		})
+	}
+}
+
+func TestUpdateMovieStatus(t *testing.T) {
+	app := newTestApplication(t)
+
+	draft := testMovie()
+	draft.Status = data.MovieStatusDraft
+	draftPath := fmt.Sprintf("/v1/movies/%d/status", insertMovie(t, app, draft))
+	archivedPath := fmt.Sprintf("/v1/movies/%d/status", insertMovie(t, app, testMovie()))
+	path := fmt.Sprintf("/v1/movies/%d/status", insertMovie(t, app, testMovie()))
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Publish draft",
+			urlPath:  draftPath,
+			body:     `{"status": "published"}`,
+			wantCode: http.StatusOK,
+			wantBody: `"status":"published"`,
+		},
+		{
+			name:     "Archive published",
+			urlPath:  archivedPath,
+			body:     `{"status": "archived"}`,
+			wantCode: http.StatusOK,
+			wantBody: `"status":"archived"`,
+		},
+		{
+			name:     "Invalid transition",
+			urlPath:  path,
+			body:     `{"status": "draft"}`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Unknown status",
+			urlPath:  path,
+			body:     `{"status": "deleted"}`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/v1/movies/1000/status",
+			body:     `{"status": "published"}`,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.putForm(t, tt.urlPath, []byte(tt.body))
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Edit conflict", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.models.Movies = racingMovieModel{app.models.Movies.(data.MemoryMovieModel)}
+		path := fmt.Sprintf("/v1/movies/%d/status", insertMovie(t, app, testMovie()))
+
+		ts := newTestServer(t, app.routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.putForm(t, path, []byte(`{"status": "archived"}`))
+		assert.Equal(t, code, http.StatusConflict)
+	})
+}
+
+func TestBatchUpdateMovies(t *testing.T) {
+	app := newTestApplication(t)
+	id := insertMovie(t, app, testMovie())
+	staleID := insertMovie(t, app, testMovie())
+	invalidID := insertMovie(t, app, testMovie())
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantBody  []string
+		wantError string
+	}{
+		{
+			name: "Mixed results",
+			body: fmt.Sprintf(`[
+				{"id": %d, "version": 1, "changes": {"title": "Batch Title"}},
+				{"id": %d, "version": 7, "changes": {"title": "Stale"}},
+				{"id": 1000, "version": 1, "changes": {"title": "Missing"}},
+				{"id": %d, "version": 1, "changes": {"year": 1500}}
+			]`, id, staleID, invalidID),
+			wantCode: http.StatusOK,
+			wantBody: []string{
+				fmt.Sprintf(`{"id":%d,"status":"updated","movie":{"id":%d,`, id, id),
+				`"title":"Batch Title"`,
+				`"version":2}`,
+				fmt.Sprintf(`{"id":%d,"status":"conflict"}`, staleID),
+				`{"id":1000,"status":"not_found"}`,
+				fmt.Sprintf(`{"id":%d,"status":"invalid","errors":{"year":"must be at least 1888"}}`, invalidID),
+			},
+		},
+		{
+			name:     "Empty batch",
+			body:     `[]`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Duplicate ids",
+			body:     fmt.Sprintf(`[{"id": %d, "version": 2, "changes": {}}, {"id": %[1]d, "version": 2, "changes": {}}]`, id),
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Unknown field",
+			body:     fmt.Sprintf(`[{"id": %d, "version": 2, "changes": {"rating": 5}}]`, id),
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.patchForm(t, "/v1/movies", []byte(tt.body))
+
+			assert.Equal(t, code, tt.wantCode)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+		})
+	}
+
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.patchForm(t, "/v1/movies", []byte(fmt.Sprintf(`[{"id": %d, "version": 2, "changes": {}}]`, id)))
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
+}
+
+func TestUpsertMovie(t *testing.T) {
+	app := newTestApplication(t)
+	existingID := insertMovie(t, app, data.Movie{ExternalID: "existing", Title: "Synced", Year: 2020, Runtime: 105, Genres: []string{"drama"}})
+	insertMovie(t, app, data.Movie{ExternalID: "unchanged", Title: "Synced", Year: 2021, Runtime: 105, Genres: []string{"drama"}})
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	validBody := []byte(`{"title": "Synced", "year": 2021, "runtime": "105 mins", "genres": ["drama"]}`)
+
+	tests := []struct {
+		name         string
+		externalID   string
+		body         []byte
+		wantCode     int
+		wantBody     string
+		wantLocation bool
+	}{
+		{"Created", "new-movie", validBody, http.StatusCreated, `"result":"created"`, true},
+		{"Updated", "existing", validBody, http.StatusOK, `"result":"updated"`, false},
+		{"Unchanged", "unchanged", validBody, http.StatusOK, `"result":"unchanged"`, false},
+		{"Keeps external id", "other-movie", validBody, http.StatusCreated, `"external_id":"other-movie"`, true},
+		{"Invalid movie", "new-movie", []byte(`{"title": "", "year": 2021, "runtime": "105 mins", "genres": ["drama"]}`), http.StatusUnprocessableEntity, `"title":"must be provided"`, false},
+		{"External id too long", strings.Repeat("a", 201), validBody, http.StatusUnprocessableEntity, `"external_id":"must not be more than 200 bytes long"`, false},
+		{"Bad JSON", "new-movie", []byte(`{"title":`), http.StatusBadRequest, `"error"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.putForm(t, "/v1/movies/external/"+tt.externalID, tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+
+			if tt.wantLocation {
+				assert.StringContains(t, body, fmt.Sprintf(`"id":%s,`, strings.TrimPrefix(header.Get("Location"), "/v1/movies/")))
+			} else {
+				assert.Equal(t, header.Get("Location"), "")
+			}
+		})
+	}
+
+	t.Run("Other movie routes still match", func(t *testing.T) {
+		code, _, _ := ts.get(t, fmt.Sprintf("/v1/movies/%d", existingID))
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Quota applies to new movies only", func(t *testing.T) {
+		app.config.quotas.moviesPerOrg = 2
+		defer func() { app.config.quotas.moviesPerOrg = 0 }()
+
+		code, _, body := ts.putForm(t, "/v1/movies/external/newer-movie", validBody)
+		assert.Equal(t, code, http.StatusForbidden)
+		assert.StringContains(t, body, `"code":"quota_exceeded"`)
+
+		code, _, _ = ts.putForm(t, "/v1/movies/external/existing", []byte(`{"title": "Resynced", "year": 2021, "runtime": "105 mins"}`))
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Lookup error", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, body := ts.putForm(t, "/v1/movies/external/new-movie", validBody)
+		assert.Equal(t, code, http.StatusInternalServerError)
+		assert.StringContains(t, body, `"error"`)
+	})
+}
+
+func TestMovieCatalogIDs(t *testing.T) {
+	app := newTestApplication(t)
+	insertMovie(t, app, data.Movie{Title: "Catalogued", Year: 1994, Runtime: 142, IMDbID: "tt0000001", TMDbID: 1})
+	path := fmt.Sprintf("/v1/movies/%d", insertMovie(t, app, testMovie()))
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			ids      string
+			wantCode int
+			wantBody string
+		}{
+			{"Valid", `"imdb_id": "tt0111161", "tmdb_id": 278`, http.StatusCreated, `"imdb_id":"tt0111161","tmdb_id":278`},
+			{"Invalid IMDb ID", `"imdb_id": "0111161"`, http.StatusUnprocessableEntity, `"imdb_id":"must look like tt0111161"`},
+			{"Negative TMDb ID", `"tmdb_id": -1`, http.StatusUnprocessableEntity, `"tmdb_id":"must be a positive integer"`},
+			{"Duplicate IMDb ID", `"imdb_id": "tt0000001"`, http.StatusUnprocessableEntity, `"imdb_id":"a movie with this IMDb ID already exists"`},
+			{"Duplicate TMDb ID", `"tmdb_id": 1`, http.StatusUnprocessableEntity, `"tmdb_id":"a movie with this TMDb ID already exists"`},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				body := []byte(`{"title": "Catalog", "year": 1994, "runtime": "142 mins", "genres": ["drama"], ` + tt.ids + `}`)
+
+				code, _, rsBody := ts.postForm(t, "/v1/movies", body)
+
+				assert.Equal(t, code, tt.wantCode)
+				assert.StringContains(t, rsBody, tt.wantBody)
+			})
+		}
+	})
+
+	t.Run("Update duplicate IMDb ID", func(t *testing.T) {
+		code, _, body := ts.patchForm(t, path, []byte(`{"imdb_id": "tt0000001"}`))
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, `"imdb_id":"a movie with this IMDb ID already exists"`)
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			imdbID   string
+			wantCode int
+			wantBody string
+		}{
+			{"Found", "tt0000001", http.StatusOK, `"imdb_id":"tt0000001"`},
+			{"Not found", "tt0068646", http.StatusNotFound, `"error"`},
+			{"Invalid", "shawshank", http.StatusUnprocessableEntity, `"imdb_id":"must look like tt0111161"`},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, _, body := ts.get(t, "/v1/movies/by-external/imdb/"+tt.imdbID)
+
+				assert.Equal(t, code, tt.wantCode)
+				assert.StringContains(t, body, tt.wantBody)
+			})
+		}
+
+		t.Run("Model error", func(t *testing.T) {
+			ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+			defer ts.Close()
+
+			code, _, body := ts.get(t, "/v1/movies/by-external/imdb/tt0111161")
+			assert.Equal(t, code, http.StatusInternalServerError)
+			assert.StringContains(t, body, `"error"`)
+		})
+	})
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediaTypeMsgpack is offered to clients, such as internal consumers, which
+// would rather decode MessagePack than JSON. The older unregistered name
+// application/x-msgpack is understood too.
+const mediaTypeMsgpack = "application/msgpack"
+
+func isMsgpack(mt string) bool {
+	return mt == mediaTypeMsgpack || mt == "application/x-msgpack"
+}
+
+// wantsMsgpack reports whether the Accept header prefers MessagePack to
+// JSON.
+func wantsMsgpack(r *http.Request) bool {
+	return prefersToJSON(r, isMsgpack)
+}
+
+// prefersToJSON reports whether the Accept header prefers a media type
+// matched by match to JSON. Of two equally preferred types, the one listed
+// first wins.
+func prefersToJSON(r *http.Request, match func(mt string) bool) bool {
+	type preference struct {
+		q   float64
+		pos int
+	}
+	other, json := preference{q: -1}, preference{q: -1}
+
+	for pos, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		switch {
+		case match(mt):
+			if q > other.q {
+				other = preference{q, pos}
+			}
+		case mt == "application/json" || strings.HasSuffix(mt, "+json") || mt == "application/*" || mt == "*/*":
+			if q > json.q {
+				json = preference{q, pos}
+			}
+		}
+	}
+
+	return other.q > 0 && (other.q > json.q || (other.q == json.q && other.pos < json.pos))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/msgpack"
+)
+
+func sampleMovies(n int) []*data.Movie {
+	movies := make([]*data.Movie, n)
+	for i := range movies {
+		movies[i] = &data.Movie{
+			ID:        int64(i + 1),
+			UUID:      "0b5c6c1a-7f38-4c4e-9d2b-3f7f0e6a4b1c",
+			CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Title:     fmt.Sprintf("Movie %d", i),
+			Year:      2016,
+			Runtime:   107,
+			Genres:    []string{"animation", "adventure"},
+			Status:    data.MovieStatusPublished,
+			Version:   3,
+			IMDbID:    "tt3521164",
+			Synopsis:  "A spirited teenager sails out on a daring mission to save her people.",
+		}
+	}
+	return movies
+}
+
+// sameAsJSON checks that v encodes to the same document in MessagePack as
+// in JSON.
+func sameAsJSON(t *testing.T, v any) {
+	t.Helper()
+
+	js, err := json.Marshal(v)
+	assert.NilError(t, err)
+
+	mp, err := msgpack.Marshal(v)
+	assert.NilError(t, err)
+
+	converted, err := msgpack.ToJSON(mp)
+	assert.NilError(t, err)
+
+	var want, got any
+	assert.NilError(t, json.Unmarshal(js, &want))
+	assert.NilError(t, json.Unmarshal(converted, &got))
+
+	wantJS, _ := json.Marshal(want)
+	gotJS, _ := json.Marshal(got)
+	assert.Equal(t, string(gotJS), string(wantJS))
+}
+
+func TestMsgpackMatchesJSON(t *testing.T) {
+	movie := sampleMovies(1)[0]
+
+	sameAsJSON(t, movie)
+	sameAsJSON(t, envelope{"movies": sampleMovies(3), "metadata": data.Metadata{CurrentPage: 1, PageSize: 20}})
+	sameAsJSON(t, &data.RankedMovie{Movie: movie, Score: 12})
+	sameAsJSON(t, &data.RankedMovie{Score: 12})
+	sameAsJSON(t, map[int64]any{-70000: []any{nil, true, 1.5, int8(-100), uint32(1 << 31), "x"}})
+
+	_, err := msgpack.Marshal(make(chan int))
+	if err == nil {
+		t.Error("want error encoding a channel")
+	}
+}
+
+func TestMsgpackUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"Truncated", []byte{0xa5, 't'}, "unexpected end of input"},
+		{"Trailing data", []byte{0x01, 0x02}, "unexpected data after top-level value"},
+		{"Integer key", []byte{0x81, 0x01, 0x01}, "map key must be a string"},
+		{"Duplicate key", []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'a', 0x02}, `duplicate map key "a"`},
+		{"Extension", []byte{0xd4, 0x01, 0x00}, "unsupported type byte 0xd4"},
+		{"Forged length", []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, "exceeds input"},
+		{"Too deep", bytes.Repeat([]byte{0x91}, 100), "maximum nesting depth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := msgpack.Unmarshal(tt.input)
+			if err == nil {
+				t.Fatal("want error")
+			}
+			assert.StringContains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestWantsMsgpack(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/msgpack", true},
+		{"application/x-msgpack", true},
+		{"application/msgpack, application/json", true},
+		{"application/json, application/msgpack", false},
+		{"application/json;q=0.5, application/msgpack", true},
+		{"application/msgpack;q=0.5, */*", false},
+		{"application/msgpack;q=0", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		assert.Equal(t, wantsMsgpack(r), tt.want)
+	}
+}
+
+func TestMsgpackRequests(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	send := func(method, path string, body []byte) (int, string, map[string]any) {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", mediaTypeMsgpack)
+		if body != nil {
+			req.Header.Set("Content-Type", mediaTypeMsgpack)
+		}
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		b, err := io.ReadAll(rs.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := msgpack.Unmarshal(b)
+		if err != nil {
+			t.Fatalf("decoding %x: %v", b, err)
+		}
+		return rs.StatusCode, rs.Header.Get("Content-Type"), decoded.(map[string]any)
+	}
+
+	body, err := msgpack.Marshal(map[string]any{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": []string{"animation"}})
+	assert.NilError(t, err)
+
+	code, contentType, response := send(http.MethodPost, "/v1/movies", body)
+	assert.Equal(t, code, http.StatusCreated)
+	assert.Equal(t, contentType, mediaTypeMsgpack)
+	movie := response["movie"].(map[string]any)
+	assert.Equal(t, movie["title"].(string), "Moana")
+	assert.Equal(t, movie["runtime"].(string), "107 mins")
+
+	body, err = msgpack.Marshal(map[string]any{"title": "Moana 2"})
+	assert.NilError(t, err)
+
+	code, _, response = send(http.MethodPatch, fmt.Sprintf("/v1/movies/%d", movie["id"]), body)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, response["movie"].(map[string]any)["title"].(string), "Moana 2")
+
+	code, _, response = send(http.MethodPost, "/v1/movies", []byte{0x81, 0xa5, 't', 'i'})
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, fmt.Sprint(response["error"]), "badly-formed MessagePack")
+
+	body, err = msgpack.Marshal(map[string]any{"title": "Moana", "rating": 5})
+	assert.NilError(t, err)
+
+	code, _, response = send(http.MethodPost, "/v1/movies", body)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, fmt.Sprint(response["error"]), `unknown key "rating"`)
+
+	code, _, response = send(http.MethodGet, "/v2/movies/999", nil)
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, response["error"].(map[string]any)["status"].(int64), int64(http.StatusNotFound))
+}
+
+func BenchmarkEncodeMovies(b *testing.B) {
+	body := envelope{"movies": sampleMovies(100), "metadata": data.Metadata{CurrentPage: 1, PageSize: 100, TotalRecords: 100}}
+
+	encoders := []struct {
+		name   string
+		encode func(any) ([]byte, error)
+	}{
+		{"JSON", json.Marshal},
+		{"MessagePack", msgpack.Marshal},
+	}
+
+	for _, enc := range encoders {
+		b.Run(enc.name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				payload, err := enc.encode(body)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(payload)
+			}
+			b.ReportMetric(float64(size), "payload-bytes")
+		})
+	}
+}
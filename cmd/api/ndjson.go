@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/errtrack"
+)
+
+// mediaTypeNDJSON is offered by list endpoints whose results can be too
+// large to hold in memory. Each line of the response is one JSON document.
+const mediaTypeNDJSON = "application/x-ndjson"
+
+// Streamed rows are translated and written in batches, flushed to the
+// client when a batch fills or has been waiting a second, whichever is
+// first.
+const (
+	ndjsonBatchRows     = 100
+	ndjsonFlushInterval = time.Second
+)
+
+func isNDJSON(mt string) bool {
+	return mt == mediaTypeNDJSON
+}
+
+// wantsNDJSON reports whether the Accept header prefers NDJSON to JSON.
+func wantsNDJSON(r *http.Request) bool {
+	return prefersToJSON(r, isNDJSON)
+}
+
+// streamMoviesResponse writes every movie matching the query, one per line,
+// as they are read from the database. Pagination is ignored; a stream is
+// only cut short by the server's write timeout. The query is cancelled if
+// the client goes away. An error after the first line has been sent can no
+// longer change the status, so it ends the stream with an error line
+// instead.
+func (app *application) streamMoviesResponse(w http.ResponseWriter, r *http.Request, q data.MovieQuery, filters data.Filters, loader *movieLoader, headers http.Header) {
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+	w.Header().Set("Content-Type", mediaTypeNDJSON)
+
+	flusher, _ := w.(http.Flusher)
+
+	enc := json.NewEncoder(w)
+	batch := make([]*data.Movie, 0, ndjsonBatchRows)
+	started := false
+	lastFlush := time.Now()
+
+	send := func() error {
+		if err := app.translateMovies(r, batch...); err != nil {
+			return err
+		}
+		if err := app.addCollections(batch...); err != nil {
+			return err
+		}
+		if err := loader.load(batch...); err != nil {
+			return err
+		}
+		for _, movie := range batch {
+			if err := enc.Encode(movie); err != nil {
+				return err
+			}
+			started = true
+		}
+		batch = batch[:0]
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+		lastFlush = time.Now()
+		return nil
+	}
+
+	err := app.models.Movies.Stream(r.Context(), q, filters, func(movie *data.Movie) error {
+		batch = append(batch, movie)
+		if len(batch) < ndjsonBatchRows && time.Since(lastFlush) < ndjsonFlushInterval {
+			return nil
+		}
+		return send()
+	})
+	if err == nil {
+		err = send()
+	}
+
+	switch {
+	case err == nil:
+		if !started {
+			w.WriteHeader(http.StatusOK)
+		}
+	case r.Context().Err() != nil:
+		// The client went away; there is no one left to tell.
+	case !started:
+		app.serverErrorResponse(w, r, err)
+	default:
+		app.logError(r, err)
+		app.reportError(r, err, errtrack.LevelError, debug.Stack())
+		enc.Encode(envelope{"error": serverErrorMessage})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestStreamMovies(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	for i := 0; i < 250; i++ {
+		movie := &data.Movie{Title: fmt.Sprint("Movie ", i), Year: 2016, Runtime: 100, Status: data.MovieStatusPublished, OrgID: 1}
+		if err := app.models.Movies.Insert(movie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/movies?sort=-id&page_size=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", mediaTypeNDJSON)
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("Content-Type"), mediaTypeNDJSON)
+
+	var ids []int64
+	scanner := bufio.NewScanner(rs.Body)
+	for scanner.Scan() {
+		var movie data.Movie
+		if err := json.Unmarshal(scanner.Bytes(), &movie); err != nil {
+			t.Fatalf("line %d: %v", len(ids)+1, err)
+		}
+		ids = append(ids, movie.ID)
+	}
+	assert.NilError(t, scanner.Err())
+
+	assert.Equal(t, len(ids), 250)
+	for i := 1; i < len(ids); i++ {
+		if ids[i] >= ids[i-1] {
+			t.Fatalf("line %d: id %d follows %d", i+1, ids[i], ids[i-1])
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err = app.models.Movies.Stream(ctx, data.MovieQuery{OrgID: 1}, data.Filters{Sort: "id", SortSafelist: []string{"id"}}, func(*data.Movie) error {
+		calls++
+		cancel()
+		return nil
+	})
+	assert.Equal(t, errors.Is(err, context.Canceled), true)
+	assert.Equal(t, calls, 1)
+}
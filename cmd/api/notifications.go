@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/validator"
+)
+
+// notify records an in-app notification for the user in the background. A
+// failure is logged rather than failing the request which triggered it.
+func (app *application) notify(userID int64, kind, message string) {
+	app.background(func() {
+		app.insertNotification(userID, kind, message)
+	})
+}
+
+// notifyListOwners notifies, in the background, the users with a list
+// which includes the movie that it has been updated.
+func (app *application) notifyListOwners(movie *data.Movie) {
+	app.background(func() {
+		userIDs, err := app.models.Lists.GetOwnersOfMovie(movie.ID)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"kind": data.NotificationListMovieUpdated})
+			return
+		}
+
+		message := fmt.Sprintf("%s, which is on one of your lists, has been updated", movie.Title)
+		for _, userID := range userIDs {
+			app.insertNotification(userID, data.NotificationListMovieUpdated, message)
+		}
+	})
+}
+
+func (app *application) insertNotification(userID int64, kind, message string) {
+	notification := &data.Notification{
+		UserID:  userID,
+		Kind:    kind,
+		Message: message,
+	}
+
+	err := app.models.Notifications.Insert(notification)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"kind": kind})
+		return
+	}
+
+	app.events.Publish(events.Event{Type: events.TypeNotification, UserID: userID, Data: notification})
+}
+
+func (app *application) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Unread bool
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.Unread = qs.Bool("unread", false)
+	input.Filters.Page = qs.Int("page", 1)
+	input.Filters.PageSize = qs.Int("page_size", 20)
+	input.Filters.Sort = "-created_at"
+	input.Filters.SortSafelist = []string{"-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.withinQueryCost(w, r, input.Filters) {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	notifications, metadata, err := app.models.Notifications.GetAllForUser(user.ID, input.Unread, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	unread, err := app.models.Notifications.CountUnread(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"notifications": notifications, "unread_count": unread, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	notification, err := app.models.Notifications.MarkRead(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"notification": notification}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
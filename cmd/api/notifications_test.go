@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+// insertNotification stores a notification for the user and returns its ID.
+func insertNotification(t *testing.T, app *application, userID int64) int64 {
+	notification := &data.Notification{UserID: userID, Kind: data.NotificationNewFollower, Message: "Alice started following you"}
+
+	err := app.models.Notifications.Insert(notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return notification.ID
+}
+
+func TestListNotifications(t *testing.T) {
+	app := newTestApplication(t)
+	insertNotification(t, app, 1)
+
+	tests := []struct {
+		name     string
+		userID   int64
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{"With notifications", 1, "", http.StatusOK, `"unread_count":1`},
+		{"Unread only", 1, "?unread=true", http.StatusOK, `"kind":"new_follower"`},
+		{"Without notifications", 3, "", http.StatusOK, `"notifications":[]`},
+		{"Invalid unread value", 1, "?unread=maybe", http.StatusUnprocessableEntity, `"unread"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/me/notifications"+tt.query, nil)
+			r = app.contextSetUser(r, &data.User{ID: tt.userID, Activated: true})
+
+			w := httptest.NewRecorder()
+			app.listNotificationsHandler(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		app := newBrokenTestApplication(t)
+
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/me/notifications", nil)
+		r = app.contextSetUser(r, &data.User{ID: 1, Activated: true})
+
+		w := httptest.NewRecorder()
+		app.listNotificationsHandler(w, r)
+
+		assert.Equal(t, w.Code, http.StatusInternalServerError)
+	})
+}
+
+func TestMarkNotificationRead(t *testing.T) {
+	app := newTestApplication(t)
+	path := fmt.Sprintf("/v1/notifications/%d/read", insertNotification(t, app, 1))
+
+	tests := []struct {
+		name     string
+		app      *application
+		userID   int64
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Own notification", app, 1, path, http.StatusOK, `"read_at":"`},
+		{"Another user's notification", app, 3, path, http.StatusNotFound, ""},
+		{"Invalid ID", app, 1, "/v1/notifications/abc/read", http.StatusNotFound, ""},
+		{"Unexpected error from Model", newBrokenTestApplication(t), 1, path, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := tt.app
+
+			router := app.newRouter()
+			router.HandlerFunc(http.MethodPut, "/v1/notifications/:id/read", func(w http.ResponseWriter, r *http.Request) {
+				r = app.contextSetUser(r, &data.User{ID: tt.userID, Activated: true})
+				app.markNotificationReadHandler(w, r)
+			})
+
+			r := httptest.NewRequest(http.MethodPut, tt.urlPath, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
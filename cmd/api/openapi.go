@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+// openAPIDocument describes the movie endpoints. The schemas are derived
+// from the validate tags that the handlers check requests against, so the
+// published constraints follow any change to the rules.
+func openAPIDocument() envelope {
+	ref := func(name string) map[string]any {
+		return map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/" + name}}
+	}
+
+	body := func(content map[string]any) map[string]any {
+		return map[string]any{"content": content}
+	}
+
+	id := []any{map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "integer"}}}
+
+	return envelope{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": "Greenlight API", "version": version},
+		"paths": map[string]any{
+			"/v1/movies": map[string]any{
+				"post": map[string]any{
+					"requestBody": body(map[string]any{"application/json": ref("Movie")}),
+					"responses":   map[string]any{"201": map[string]any{"description": "the created movie"}},
+				},
+			},
+			"/v1/movies/{id}": map[string]any{
+				"get": map[string]any{
+					"parameters": id,
+					"responses":  map[string]any{"200": map[string]any{"description": "the movie"}},
+				},
+				"patch": map[string]any{
+					"parameters": id,
+					"requestBody": body(map[string]any{
+						"application/json":  ref("MoviePatch"),
+						mediaTypeMergePatch: ref("MoviePatch"),
+						mediaTypeJSONPatch:  ref("JSONPatch"),
+					}),
+					"responses": map[string]any{"200": map[string]any{"description": "the updated movie"}},
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Movie":      validator.Schema(data.Movie{}),
+				"MoviePatch": validator.Schema(data.MovieChanges{}),
+				"JSONPatch": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type":     "object",
+						"required": []string{"op", "path"},
+						"properties": map[string]any{
+							"op":    map[string]any{"type": "string", "enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+							"path":  map[string]any{"type": "string"},
+							"from":  map[string]any{"type": "string"},
+							"value": map[string]any{},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, r, http.StatusOK, openAPIDocument(), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestOpenAPI(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/v1/openapi.json")
+	assert.Equal(t, code, http.StatusOK)
+
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string                  `json:"required"`
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+
+	err := json.Unmarshal([]byte(body), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie := doc.Components.Schemas["Movie"]
+	assert.Equal(t, len(movie.Required), 3)
+	assert.Equal(t, fmt.Sprint(movie.Properties["title"]["maxLength"]), "500")
+	assert.Equal(t, fmt.Sprint(movie.Properties["year"]["minimum"]), "1888")
+	assert.Equal(t, fmt.Sprint(movie.Properties["genres"]["maxItems"]), "5")
+	assert.Equal(t, fmt.Sprint(movie.Properties["genres"]["uniqueItems"]), "true")
+	assert.Equal(t, fmt.Sprint(movie.Properties["runtime"]["type"]), "string")
+	assert.Equal(t, fmt.Sprint(movie.Properties["imdb_id"]["pattern"]), "^tt[0-9]{7,10}$")
+
+	patch := doc.Components.Schemas["MoviePatch"]
+	assert.Equal(t, len(patch.Required), 2)
+	assert.Equal(t, fmt.Sprint(patch.Properties["title"]["nullable"]), "<nil>")
+	assert.Equal(t, fmt.Sprint(patch.Properties["year"]["nullable"]), "true")
+	assert.Equal(t, fmt.Sprint(patch.Properties["year"]["minimum"]), "1888")
+}
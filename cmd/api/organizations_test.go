@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestListUserOrganizations(t *testing.T) {
+	app := newTestApplication(t)
+
+	err := app.models.Organizations.AddMember(1, 1, data.RoleOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		app      *application
+		userID   int64
+		wantCode int
+		wantBody string
+	}{
+		{"Member of one organization", app, 1, http.StatusOK, `"name":"Default","role":"owner"}]`},
+		{"No memberships", app, 3, http.StatusOK, `"organizations":[]`},
+		{"Unexpected error from Model", newBrokenTestApplication(t), 1, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := tt.app
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/me/organizations", nil)
+			r = app.contextSetUser(r, &data.User{ID: tt.userID, Activated: true})
+
+			app.listUserOrganizationsHandler(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCreateOrganization(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid organization", `{"name": "Film Club"}`, http.StatusCreated, `"role":"owner"`},
+		{"Missing name", `{"name": ""}`, http.StatusUnprocessableEntity, `"name":"must be provided"`},
+		{"Badly-formed body", `{"name": `, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/organizations", strings.NewReader(tt.body))
+			r = app.contextSetUser(r, &data.User{ID: 1, Activated: true})
+
+			app.createOrganizationHandler(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		app := newBrokenTestApplication(t)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/v1/organizations", strings.NewReader(`{"name": "Film Club"}`))
+		r = app.contextSetUser(r, &data.User{ID: 1, Activated: true})
+
+		app.createOrganizationHandler(w, r)
+
+		assert.Equal(t, w.Code, http.StatusInternalServerError)
+	})
+}
+
+func TestAddOrganizationMember(t *testing.T) {
+	app := newTestApplication(t)
+
+	ownerID := insertUser(t, app, "test@example.com")
+	memberID := insertUser(t, app, "member@example.com")
+	outsiderID := insertUser(t, app, "outsider@example.com")
+	insertUser(t, app, "admin@example.com")
+	insertUser(t, app, "friend@example.com")
+
+	org := &data.Organization{Name: "Film Club"}
+
+	err := app.models.Organizations.Insert(org, ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.models.Organizations.AddMember(org.ID, memberID, data.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := fmt.Sprintf("/v1/organizations/%d/members", org.ID)
+
+	tests := []struct {
+		name     string
+		app      *application
+		userID   int64
+		urlPath  string
+		body     string
+		wantCode int
+	}{
+		{"Owner adds a member", app, ownerID, path, `{"email": "admin@example.com", "role": "member"}`, http.StatusCreated},
+		{"Already a member", app, ownerID, path, `{"email": "test@example.com"}`, http.StatusUnprocessableEntity},
+		{"Unknown user", app, ownerID, path, `{"email": "nobody@example.com"}`, http.StatusUnprocessableEntity},
+		{"Invalid role", app, ownerID, path, `{"email": "friend@example.com", "role": "admin"}`, http.StatusUnprocessableEntity},
+		{"Member is not an owner", app, memberID, path, `{"email": "friend@example.com"}`, http.StatusForbidden},
+		{"Not a member", app, outsiderID, path, `{"email": "friend@example.com"}`, http.StatusNotFound},
+		{"Invalid ID", app, ownerID, "/v1/organizations/abc/members", `{"email": "friend@example.com"}`, http.StatusNotFound},
+		{"Unexpected error from Model", newBrokenTestApplication(t), ownerID, path, `{"email": "friend@example.com"}`, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := tt.app
+
+			router := app.newRouter()
+			router.HandlerFunc(http.MethodPost, "/v1/organizations/:id/members", func(w http.ResponseWriter, r *http.Request) {
+				r = app.contextSetUser(r, &data.User{ID: tt.userID, Activated: true})
+				app.addOrganizationMemberHandler(w, r)
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tt.urlPath, strings.NewReader(tt.body))
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+		})
+	}
+}
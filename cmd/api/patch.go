@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/jsonpatch"
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// acceptPatch lists the media types PATCH /v1/movies/:id accepts, for the
+// Accept-Patch header.
+var acceptPatch = strings.Join([]string{"application/json", mediaTypeMergePatch, mediaTypeJSONPatch}, ", ")
+
+// mediaType returns the media type of the request body without parameters.
+// A missing or malformed Content-Type is treated as application/json.
+func mediaType(r *http.Request) string {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "application/json"
+	}
+	return mt
+}
+
+// readMoviePatch applies a JSON Merge Patch or JSON Patch body to the JSON
+// representation of movie and returns the difference as MovieChanges, so
+// that the result goes through the same validation as a plain update.
+// Patches which cannot be applied, or which touch fields that are not
+// editable, are reported through v. A failed test operation is returned as
+// jsonpatch.ErrTestFailed.
+func (app *application) readMoviePatch(w http.ResponseWriter, r *http.Request, movie *data.Movie, v *validator.Validator) (data.MovieChanges, error) {
+	var changes data.MovieChanges
+
+	js, err := json.Marshal(movie)
+	if err != nil {
+		return changes, err
+	}
+
+	original, err := jsonpatch.Decode(js)
+	if err != nil {
+		return changes, err
+	}
+
+	current, err := jsonpatch.Decode(js)
+	if err != nil {
+		return changes, err
+	}
+
+	var patched any
+
+	if mediaType(r) == mediaTypeMergePatch {
+		var patch any
+		err = app.readJSON(w, r, &patch, useJSONNumber())
+		if err != nil {
+			return changes, err
+		}
+		patched = jsonpatch.MergePatch(current, patch)
+	} else {
+		var patch []jsonpatch.Operation
+		err = app.readJSON(w, r, &patch)
+		if err != nil {
+			return changes, err
+		}
+		patched, err = jsonpatch.Apply(current, patch)
+		if err != nil {
+			if errors.Is(err, jsonpatch.ErrTestFailed) {
+				return changes, err
+			}
+			v.AddError("patch", err.Error())
+			return changes, nil
+		}
+	}
+
+	before := original.(map[string]any)
+	after, ok := patched.(map[string]any)
+	if !ok {
+		v.AddError("patch", "must leave the movie a JSON object")
+		return changes, nil
+	}
+
+	editable := map[string]any{}
+	t := reflect.TypeOf(changes)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		editable[name] = after[name]
+	}
+
+	for key := range before {
+		if _, ok := editable[key]; !ok && !reflect.DeepEqual(before[key], after[key]) {
+			v.AddError(key, "cannot be changed")
+		}
+	}
+	for key := range after {
+		if _, ok := editable[key]; !ok && !reflect.DeepEqual(before[key], after[key]) {
+			v.AddError(key, "cannot be changed")
+		}
+	}
+
+	if !v.Valid() {
+		return changes, nil
+	}
+
+	js, err = json.Marshal(editable)
+	if err != nil {
+		return changes, err
+	}
+
+	err = decodeJSON(js, &changes, jsonOptions{maxDepth: defaultJSONMaxDepth})
+	return changes, err
+}
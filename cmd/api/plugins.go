@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"greenlight.bcc/internal/plugin"
+)
+
+// pluginRouter registers a plugin's routes on a router of its own, refusing
+// paths outside the plugin's prefix the way httprouter refuses invalid ones.
+type pluginRouter struct {
+	appRouter
+	prefix string
+}
+
+func (router pluginRouter) checkPath(path string) {
+	if !strings.HasPrefix(path, router.prefix) {
+		panic(fmt.Sprintf("plugin route %s must be under %s", path, router.prefix))
+	}
+}
+
+func (router pluginRouter) Handle(method, path string, handler http.Handler) {
+	router.checkPath(path)
+	router.appRouter.Handler(method, path, handler)
+}
+
+func (router pluginRouter) RequirePermission(method, path, code string, handler http.Handler) {
+	router.checkPath(path)
+	router.appRouter.RequirePermission(method, path, code, handler.ServeHTTP)
+}
+
+// mountPlugins adds the plugins' routes to handler and wraps it in the
+// plugins' own handlers, so that they see every authenticated request.
+func (app *application) mountPlugins(handler http.Handler) http.Handler {
+	if len(app.plugins) == 0 {
+		return handler
+	}
+
+	for _, p := range app.plugins {
+		router := pluginRouter{app.newRouter(), plugin.PathPrefix(p.Name())}
+		p.RegisterRoutes(router)
+		handler = mount(handler, router, router.prefix)
+	}
+
+	for i := len(app.plugins) - 1; i >= 0; i-- {
+		handler = app.plugins[i].WrapHandler(handler)
+	}
+
+	return app.withPluginCaller(handler)
+}
+
+// withPluginCaller tells plugins who is making the request, since they
+// cannot read the application's own context values.
+func (app *application) withPluginCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		caller := plugin.Caller{OrgID: user.OrgID}
+		if !user.IsAnonymous() {
+			caller.UserID = user.ID
+		}
+
+		next.ServeHTTP(w, r.WithContext(plugin.NewContext(r.Context(), caller)))
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/plugin"
+)
+
+type testPlugin struct {
+	name   string
+	header string
+	path   string
+}
+
+func (p testPlugin) Name() string {
+	return p.name
+}
+
+func (p testPlugin) RegisterRoutes(router plugin.Router) {
+	if p.path == "" {
+		return
+	}
+
+	router.RequirePermission(http.MethodGet, p.path, "movies:read", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, _ := plugin.CallerFromContext(r.Context())
+		fmt.Fprintf(w, `{"org_id": %d}`, caller.OrgID)
+	}))
+}
+
+func (p testPlugin) WrapHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Plugins", p.header)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestPlugins(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.plugins = []plugin.Plugin{
+		testPlugin{name: "acme", header: "acme", path: "/v1/plugins/acme/org"},
+		testPlugin{name: "audit", header: "audit"},
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, body := ts.do(t, http.MethodGet, "/v1/plugins/acme/org", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["org_id"].(float64), 1)
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/plugins/acme/org", "", "")
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	rs, err := ts.Client().Get(ts.URL + "/v1/healthcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	assert.Equal(t, fmt.Sprint(rs.Header.Values("X-Plugins")), "[acme audit]")
+
+	defer func() {
+		assert.StringContains(t, fmt.Sprint(recover()), "must be under /v1/plugins/rogue/")
+	}()
+	app.plugins = []plugin.Plugin{testPlugin{name: "rogue", path: "/v1/movies/:id/rogue"}}
+	app.routes()
+	t.Fatal("route outside the plugin's prefix was registered")
+}
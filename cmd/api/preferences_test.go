@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestShowPreferences(t *testing.T) {
+	app := newTestApplication(t)
+	id := insertUser(t, app, "test@example.com")
+
+	tests := []struct {
+		name     string
+		app      *application
+		userID   int64
+		wantCode int
+		wantBody string
+	}{
+		{"Existing user", app, id, http.StatusOK, `"digest_frequency":"weekly"`},
+		{"Unexpected error from Model", newBrokenTestApplication(t), id, http.StatusInternalServerError, ""},
+		{"Missing user", app, 1000, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := tt.app
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/me/preferences", nil)
+			r = app.contextSetUser(r, &data.User{ID: tt.userID, Activated: true})
+
+			w := httptest.NewRecorder()
+			app.showPreferencesHandler(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	app := newTestApplication(t)
+	id := insertUser(t, app, "test@example.com")
+
+	tests := []struct {
+		name     string
+		app      *application
+		userID   int64
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Partial update",
+			app:      app,
+			userID:   id,
+			body:     `{"marketing_emails": true}`,
+			wantCode: http.StatusOK,
+			wantBody: `"marketing_emails":true,"security_alerts":true,"digest_frequency":"weekly"`,
+		},
+		{
+			name:     "Invalid digest frequency",
+			app:      app,
+			userID:   id,
+			body:     `{"digest_frequency": "hourly"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"digest_frequency"`,
+		},
+		{
+			name:     "Unknown field",
+			app:      app,
+			userID:   id,
+			body:     `{"sms": true}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Unexpected error from Model",
+			app:      newBrokenTestApplication(t),
+			userID:   id,
+			body:     `{"security_alerts": false}`,
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := tt.app
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/users/me/preferences", strings.NewReader(tt.body))
+			r = app.contextSetUser(r, &data.User{ID: tt.userID, Activated: true})
+
+			w := httptest.NewRecorder()
+			app.updatePreferencesHandler(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
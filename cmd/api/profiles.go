@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+// profileItems is how many of their lists and comments a profile shows.
+const profileItems = 10
+
+func (app *application) showProfileHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	app.writeProfile(w, r, id)
+}
+
+func (app *application) showOwnProfileHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeProfile(w, r, app.contextGetUser(r).ID)
+}
+
+// writeProfile writes the profile of userID as the current user sees it.
+// Lists and comments only come from the viewer's organization, so anonymous
+// viewers see neither.
+func (app *application) writeProfile(w http.ResponseWriter, r *http.Request, userID int64) {
+	viewer := app.contextGetUser(r)
+
+	profile, err := app.models.Profiles.Get(userID, viewer.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if profile.Visible && !viewer.IsAnonymous() {
+		profile.Lists, err = app.models.Profiles.GetLists(userID, viewer.OrgID, profileItems)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		profile.Comments, err = app.models.Profiles.GetComments(userID, viewer.OrgID, profileItems)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"profile": profile}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Privacy *string `json:"privacy"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if input.Privacy != nil {
+		v := validator.New()
+
+		if data.ValidatePrivacy(v, *input.Privacy); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		err = app.models.Profiles.SetPrivacy(user.ID, *input.Privacy)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	app.writeProfile(w, r, user.ID)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestProfiles(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]int64{}
+	tokens := map[string]string{}
+	for _, name := range []string{"Fan", "Stranger"} {
+		user := &data.User{Name: name, Email: name + "@example.com", Locale: "en", Activated: true}
+		if err := user.Password.Set("pa55word"); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.models.Users.Insert(user); err != nil {
+			t.Fatal(err)
+		}
+		token, err := app.models.Tokens.NewAuthentication(user.ID, 1, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = user.ID
+		tokens[name] = token.Plaintext
+	}
+
+	profilePath := fmt.Sprintf("/v1/users/%d/profile", editor.ID)
+
+	code, _ := ts.do(t, http.MethodPut, fmt.Sprintf("/v1/users/me/following/%d", editor.ID), tokens["Fan"], "")
+	assert.Equal(t, code, http.StatusOK)
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusPublished, OrgID: 1}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ = ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/comments", movie.ID), token, `{"body": "A favourite"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	code, _ = ts.do(t, http.MethodPost, "/v1/lists", token, `{"name": "Favourites", "public": true}`)
+	assert.Equal(t, code, http.StatusCreated)
+	code, _ = ts.do(t, http.MethodPost, "/v1/lists", token, `{"name": "Secret"}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	// visible reports whether the profile came back in full, and how many
+	// lists and comments it had.
+	visible := func(token string) (bool, int, int) {
+		t.Helper()
+		code, body := ts.do(t, http.MethodGet, profilePath, token, "")
+		assert.Equal(t, code, http.StatusOK)
+		profile := body["profile"].(map[string]any)
+		assert.Equal(t, fmt.Sprint(profile["name"]), "Editor")
+		lists, _ := profile["lists"].([]any)
+		comments, _ := profile["comments"].([]any)
+		return profile["stats"] != nil, len(lists), len(comments)
+	}
+
+	ok, lists, comments := visible(tokens["Stranger"])
+	assert.Equal(t, ok, true)
+	assert.Equal(t, lists, 1)
+	assert.Equal(t, comments, 1)
+
+	ok, lists, _ = visible("")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, lists, 0)
+
+	code, _ = ts.do(t, http.MethodPatch, "/v1/users/me/profile", token, `{"privacy": "friends"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body := ts.do(t, http.MethodPatch, "/v1/users/me/profile", token, `{"privacy": "followers"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, fmt.Sprint(body["profile"].(map[string]any)["privacy"]), data.PrivacyFollowers)
+
+	ok, lists, comments = visible(tokens["Stranger"])
+	assert.Equal(t, ok, false)
+	assert.Equal(t, lists, 0)
+	assert.Equal(t, comments, 0)
+
+	ok, _, _ = visible(tokens["Fan"])
+	assert.Equal(t, ok, true)
+
+	code, _ = ts.do(t, http.MethodPatch, "/v1/users/me/profile", token, `{"privacy": "private"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	ok, _, _ = visible(tokens["Fan"])
+	assert.Equal(t, ok, false)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/profile", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	stats := body["profile"].(map[string]any)["stats"].(map[string]any)
+	assert.Equal(t, stats["followers_count"].(float64), 1)
+	assert.Equal(t, len(body["profile"].(map[string]any)["lists"].([]any)), 1)
+
+	code, _ = ts.do(t, http.MethodPut, fmt.Sprintf("/v1/users/me/blocks/%d", ids["Stranger"]), token, `{"kind": "block"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodGet, profilePath, tokens["Stranger"], "")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/users/999/profile", "", "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.bcc/internal/jobs"
+	"greenlight.bcc/internal/storage"
+	"greenlight.bcc/internal/validator"
+)
+
+// debugProfilesPrefix is where captured profiles are stored. They reveal the
+// running code and memory, so the public file route does not serve them.
+const debugProfilesPrefix = "debug/profiles/"
+
+const (
+	profileCPU  = "cpu"
+	profileHeap = "heap"
+)
+
+// defaultCPUProfileDuration matches the default of /debug/pprof/profile,
+// which the server's write timeout cuts short; capturing to storage has no
+// such limit.
+const (
+	defaultCPUProfileDuration = 30 * time.Second
+	maxCPUProfileDuration     = 5 * time.Minute
+)
+
+// pprofHandler serves net/http/pprof's handlers under /debug/pprof/. The
+// command line is redacted as it is on /debug/vars.
+func pprofHandler(w http.ResponseWriter, r *http.Request) {
+	switch httprouter.ParamsFromContext(r.Context()).ByName("name") {
+	case "/cmdline":
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, strings.Join(redactArgs(os.Args), "\x00"))
+	case "/profile":
+		pprof.Profile(w, r)
+	case "/symbol":
+		pprof.Symbol(w, r)
+	case "/trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
+
+// captureDebugProfileHandler captures a CPU profile or heap snapshot to storage
+// as a background job. It responds with 202, the job's location and where
+// the profile can be downloaded once the job has succeeded.
+func (app *application) captureDebugProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Type    string `json:"type"`
+		Seconds *int   `json:"seconds"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	duration := defaultCPUProfileDuration
+	if input.Seconds != nil {
+		duration = time.Duration(*input.Seconds) * time.Second
+	}
+
+	v := validator.New()
+	v.Check(validator.PermittedValue(input.Type, profileCPU, profileHeap), "type", "must be cpu or heap")
+	if input.Seconds != nil {
+		v.Check(input.Type == profileCPU, "seconds", "must only be given for cpu profiles")
+		v.Check(duration > 0, "seconds", "must be greater than zero")
+		v.Check(duration <= maxCPUProfileDuration, "seconds", fmt.Sprintf("must not be more than %d", int(maxCPUProfileDuration.Seconds())))
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	name, err := newProfileName(input.Type, time.Now())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	step := jobs.Step{Name: "write heap profile", Run: func() error {
+		var buf bytes.Buffer
+		runtime.GC()
+		if err := rpprof.WriteHeapProfile(&buf); err != nil {
+			return err
+		}
+		return app.storeProfile(name, &buf)
+	}}
+	if input.Type == profileCPU {
+		step = jobs.Step{Name: "capture cpu profile", Run: func() error {
+			var buf bytes.Buffer
+			if err := rpprof.StartCPUProfile(&buf); err != nil {
+				return err
+			}
+			time.Sleep(duration)
+			rpprof.StopCPUProfile()
+			return app.storeProfile(name, &buf)
+		}}
+	}
+
+	job, err := app.jobs.Start("profile-"+input.Type, []jobs.Step{step})
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrAlreadyRunning):
+			app.jobAlreadyRunningResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.logger.PrintInfo("profile capture started", map[string]string{
+		"type":    input.Type,
+		"name":    name,
+		"user_id": strconv.FormatInt(app.contextGetUser(r).ID, 10),
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/jobs/%d", job.ID))
+
+	profile := envelope{"name": name, "download": "/v1/admin/debug/profiles/" + name}
+
+	err = app.writeJSON(w, r, http.StatusAccepted, envelope{"job": job, "profile": profile}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// newProfileName returns a name for a profile captured at t, random so that
+// it cannot be guessed.
+func newProfileName(profileType string, t time.Time) (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s-%s.pprof", profileType, t.UTC().Format("20060102T150405Z"), hex.EncodeToString(b)), nil
+}
+
+func (app *application) storeProfile(name string, buf *bytes.Buffer) error {
+	return app.storage.Put(context.Background(), debugProfilesPrefix+name, buf, int64(buf.Len()), "application/octet-stream")
+}
+
+// showDebugProfileHandler downloads a captured profile, for go tool pprof.
+func (app *application) showDebugProfileHandler(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	f, err := app.storage.Get(r.Context(), debugProfilesPrefix+name)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrInvalidKey):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+	_, err = io.Copy(w, f)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
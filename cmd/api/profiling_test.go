@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/jobs"
+)
+
+func TestDebugProfiles(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	download := func(path string) (int, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		body, err := io.ReadAll(rs.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rs.StatusCode, body
+	}
+
+	code, _ := download("/debug/pprof/")
+	assert.Equal(t, code, http.StatusForbidden)
+	code, _ = ts.do(t, http.MethodPost, "/v1/admin/debug/profiles", token, `{"type": "heap"}`)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(editor.ID, "admin:read", "admin:write"); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := download("/debug/pprof/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, string(body), "goroutine")
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/admin/debug/profiles", token, `{"type": "heap", "seconds": 10}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	code, _ = ts.do(t, http.MethodPost, "/v1/admin/debug/profiles", token, `{"type": "cpu", "seconds": 3600}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, env := ts.do(t, http.MethodPost, "/v1/admin/debug/profiles", token, `{"type": "heap"}`)
+	assert.Equal(t, code, http.StatusAccepted)
+
+	profile := env["profile"].(map[string]any)
+	jobPath := fmt.Sprintf("/v1/admin/jobs/%v", env["job"].(map[string]any)["id"])
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, env = ts.do(t, http.MethodGet, jobPath, token, "")
+		if status := env["job"].(map[string]any)["status"]; status != jobs.StatusRunning {
+			assert.Equal(t, status.(string), jobs.StatusSucceeded)
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("profile capture did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	code, body = download(profile["download"].(string))
+	assert.Equal(t, code, http.StatusOK)
+	// Profiles are gzipped protocol buffers.
+	assert.Equal(t, bytes.HasPrefix(body, []byte{0x1f, 0x8b}), true)
+
+	code, _, _ = ts.get(t, "/v1/files/"+debugProfilesPrefix+profile["name"].(string))
+	assert.Equal(t, code, http.StatusNotFound)
+}
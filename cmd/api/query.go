@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// queryErrorKind is the type of value a query string parameter failed to
+// parse as.
+type queryErrorKind int
+
+const (
+	queryErrorInt queryErrorKind = iota
+	queryErrorFloat
+	queryErrorBool
+	queryErrorDate
+	queryErrorTime
+	queryErrorEnum
+	queryErrorList
+)
+
+// queryError is a query string parameter whose value could not be bound.
+// Its message is what the 422 response reports for the parameter.
+type queryError struct {
+	Key       string
+	Value     string
+	Kind      queryErrorKind
+	permitted []string
+}
+
+func (e *queryError) Error() string {
+	switch e.Kind {
+	case queryErrorInt:
+		return "must be an integer value"
+	case queryErrorFloat:
+		return "must be a number"
+	case queryErrorBool:
+		return "must be a boolean value"
+	case queryErrorDate:
+		return "must be a date in YYYY-MM-DD format"
+	case queryErrorTime:
+		return "must be an RFC 3339 timestamp"
+	case queryErrorEnum:
+		return "must be one of " + strings.Join(e.permitted, ", ")
+	case queryErrorList:
+		return "must be a comma-separated list without empty values"
+	default:
+		return fmt.Sprintf("invalid value %q", e.Value)
+	}
+}
+
+// queryBinder reads typed values from a query string. A parameter which is
+// missing or empty takes the default, and one which cannot be parsed takes
+// the default and adds a queryError for the parameter to the validator, so
+// that every bad parameter is reported in the same response.
+type queryBinder struct {
+	qs url.Values
+	v  *validator.Validator
+}
+
+func newQueryBinder(qs url.Values, v *validator.Validator) *queryBinder {
+	return &queryBinder{qs: qs, v: v}
+}
+
+func (b *queryBinder) fail(err *queryError) {
+	b.v.AddError(err.Key, err.Error())
+}
+
+func (b *queryBinder) String(key, defaultValue string) string {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+func (b *queryBinder) Int(key string, defaultValue int) int {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		b.fail(&queryError{Key: key, Value: s, Kind: queryErrorInt})
+		return defaultValue
+	}
+	return i
+}
+
+func (b *queryBinder) Int64(key string, defaultValue int64) int64 {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		b.fail(&queryError{Key: key, Value: s, Kind: queryErrorInt})
+		return defaultValue
+	}
+	return i
+}
+
+func (b *queryBinder) Float(key string, defaultValue float64) float64 {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		b.fail(&queryError{Key: key, Value: s, Kind: queryErrorFloat})
+		return defaultValue
+	}
+	return f
+}
+
+func (b *queryBinder) Bool(key string, defaultValue bool) bool {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		b.fail(&queryError{Key: key, Value: s, Kind: queryErrorBool})
+		return defaultValue
+	}
+	return v
+}
+
+// Date parses a YYYY-MM-DD value as midnight UTC.
+func (b *queryBinder) Date(key string, defaultValue time.Time) time.Time {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		b.fail(&queryError{Key: key, Value: s, Kind: queryErrorDate})
+		return defaultValue
+	}
+	return t
+}
+
+// Time parses an RFC 3339 timestamp, such as 2024-01-02T15:04:05Z.
+func (b *queryBinder) Time(key string, defaultValue time.Time) time.Time {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		b.fail(&queryError{Key: key, Value: s, Kind: queryErrorTime})
+		return defaultValue
+	}
+	return t
+}
+
+// Enum reads a value which must be one of permitted.
+func (b *queryBinder) Enum(key, defaultValue string, permitted ...string) string {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	if !validator.PermittedValue(s, permitted...) {
+		b.fail(&queryError{Key: key, Value: s, Kind: queryErrorEnum, permitted: permitted})
+		return defaultValue
+	}
+	return s
+}
+
+// CSV reads a comma-separated list. Spaces around the values are ignored,
+// but empty values, as in "a,,b", are not allowed.
+func (b *queryBinder) CSV(key string, defaultValue []string) []string {
+	s := b.qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	values := strings.Split(s, ",")
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+		if values[i] == "" {
+			b.fail(&queryError{Key: key, Value: s, Kind: queryErrorList})
+			return defaultValue
+		}
+	}
+	return values
+}
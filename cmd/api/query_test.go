@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/validator"
+)
+
+func TestQueryBinder(t *testing.T) {
+	qs, err := url.ParseQuery("page=2&ratio=0.5&facets=yes&from=2024-02-30&status=draft&sort=size&genres=drama,+comedy&tags=a,,b&cursor=9000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := validator.New()
+	b := newQueryBinder(qs, v)
+
+	assert.Equal(t, b.Int("page", 1), 2)
+	assert.Equal(t, b.Int("page_size", 20), 20)
+	assert.Equal(t, b.Int64("cursor", 0), int64(9000000000))
+	assert.Equal(t, b.Float("ratio", 1), 0.5)
+	assert.Equal(t, b.Bool("facets", false), false)
+	assert.Equal(t, b.Date("from", time.Time{}).IsZero(), true)
+	assert.Equal(t, b.Enum("status", "published", "draft", "published"), "draft")
+	assert.Equal(t, b.Enum("sort", "id", "id", "title"), "id")
+	assert.Equal(t, strings.Join(b.CSV("genres", nil), "|"), "drama|comedy")
+	assert.Equal(t, len(b.CSV("tags", nil)), 0)
+
+	// Every bad parameter is reported, not just the first.
+	assert.Equal(t, len(v.Errors), 4)
+	assert.Equal(t, v.Errors["facets"], "must be a boolean value")
+	assert.Equal(t, v.Errors["from"], "must be a date in YYYY-MM-DD format")
+	assert.Equal(t, v.Errors["sort"], "must be one of id, title")
+	assert.Equal(t, v.Errors["tags"], "must be a comma-separated list without empty values")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+
+	"greenlight.bcc/internal/data"
+)
+
+// Reasons a list request is refused as too expensive. Clients can match on
+// them to adjust the request, such as by narrowing the filters instead of
+// paging deeper.
+const (
+	costPageSizeTooLarge = "page_size_too_large"
+	costOffsetTooLarge   = "offset_too_large"
+	costUnindexedSort    = "unindexed_sort"
+)
+
+type costRejection struct {
+	reason string
+	field  string
+	limit  int
+}
+
+// estimateQueryCost checks a list request's paging against the deployment's
+// limits before the query runs. PostgreSQL has to read and throw away every
+// row before the offset, and for a sort no index provides it must also
+// sort every matching row first, so deep pages of unindexed sorts are held
+// to a tighter limit. indexedSorts lists the sort values an index serves in
+// order; endpoints with a fixed sort pass none, and their sort is taken to
+// be indexed. Limits of 0 are not enforced.
+func (app *application) estimateQueryCost(filters data.Filters, indexedSorts ...string) *costRejection {
+	limits := app.config.queryCost
+	offset := (filters.Page - 1) * filters.PageSize
+
+	if limits.maxPageSize > 0 && filters.PageSize > limits.maxPageSize {
+		return &costRejection{costPageSizeTooLarge, "page_size", limits.maxPageSize}
+	}
+
+	if limits.maxOffset > 0 && offset > limits.maxOffset {
+		return &costRejection{costOffsetTooLarge, "page", limits.maxOffset}
+	}
+
+	indexed := len(indexedSorts) == 0
+	for _, sort := range indexedSorts {
+		if filters.Sort == sort {
+			indexed = true
+		}
+	}
+
+	if !indexed && limits.maxUnindexedOffset > 0 && offset > limits.maxUnindexedOffset {
+		return &costRejection{costUnindexedSort, "sort", limits.maxUnindexedOffset}
+	}
+
+	return nil
+}
+
+// withinQueryCost sends a queryTooExpensiveResponse and returns false if
+// the request is refused by estimateQueryCost.
+func (app *application) withinQueryCost(w http.ResponseWriter, r *http.Request, filters data.Filters, indexedSorts ...string) bool {
+	rejection := app.estimateQueryCost(filters, indexedSorts...)
+	if rejection == nil {
+		return true
+	}
+
+	app.queryTooExpensiveResponse(w, r, rejection, indexedSorts)
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestEstimateQueryCost(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.queryCost.maxPageSize = 50
+	app.config.queryCost.maxOffset = 1000
+	app.config.queryCost.maxUnindexedOffset = 100
+
+	tests := []struct {
+		name    string
+		filters data.Filters
+		indexed []string
+		want    string
+	}{
+		{"First page", data.Filters{Page: 1, PageSize: 50, Sort: "title"}, []string{"id"}, ""},
+		{"Page size", data.Filters{Page: 1, PageSize: 51, Sort: "id"}, []string{"id"}, costPageSizeTooLarge},
+		{"Deep indexed page", data.Filters{Page: 21, PageSize: 50, Sort: "id"}, []string{"id"}, ""},
+		{"Too deep", data.Filters{Page: 22, PageSize: 50, Sort: "id"}, []string{"id"}, costOffsetTooLarge},
+		{"Deep unindexed page", data.Filters{Page: 4, PageSize: 50, Sort: "title"}, []string{"id"}, costUnindexedSort},
+		{"Fixed sort", data.Filters{Page: 4, PageSize: 50, Sort: "-created_at"}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if rejection := app.estimateQueryCost(tt.filters, tt.indexed...); rejection != nil {
+				got = rejection.reason
+			}
+			assert.Equal(t, got, tt.want)
+		})
+	}
+
+	app.config.queryCost.maxOffset = 0
+	assert.Equal(t, app.estimateQueryCost(data.Filters{Page: 1_000_000, PageSize: 50, Sort: "id"}, "id") == nil, true)
+}
+
+func TestQueryTooExpensiveResponse(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.config.queryCost.maxUnindexedOffset = 100
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, body := ts.do(t, http.MethodGet, "/v1/movies?sort=-year&page=7&page_size=20", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	rejection := body["error"].(map[string]any)
+	assert.Equal(t, rejection["code"].(string), "query_too_expensive")
+	assert.Equal(t, rejection["reason"].(string), costUnindexedSort)
+	assert.Equal(t, rejection["field"].(string), "sort")
+	assert.Equal(t, rejection["limit"].(float64), 100)
+	assert.StringContains(t, rejection["message"].(string), "sorting by id, -id")
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/movies?sort=-id&page=7&page_size=20", token, "")
+	assert.Equal(t, code, http.StatusOK)
+}
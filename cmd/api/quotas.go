@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+)
+
+const (
+	quotaMovies   = "movies"
+	quotaWebhooks = "webhooks"
+)
+
+type quota struct {
+	Used  int  `json:"used"`
+	Limit *int `json:"limit"`
+}
+
+// exceeded reports whether creating n more resources would go over the
+// limit. A nil limit is unlimited.
+func (q quota) exceeded(n int) bool {
+	return q.Limit != nil && q.Used+n > *q.Limit
+}
+
+// quota returns the caller's current usage and limit for resource. Limits of
+// zero in the configuration mean unlimited.
+func (app *application) quota(r *http.Request, resource string) (quota, error) {
+	var q quota
+
+	switch resource {
+	case quotaMovies:
+		used, err := app.models.Movies.Count(app.contextGetUser(r).OrgID)
+		if err != nil {
+			return q, err
+		}
+		q.Used = used
+		if limit := app.config.quotas.moviesPerOrg; limit > 0 {
+			q.Limit = &limit
+		}
+	case quotaWebhooks:
+		hooks, err := app.models.Webhooks.GetAllForUser(app.contextGetUser(r).ID)
+		if err != nil {
+			return q, err
+		}
+		q.Used = len(hooks)
+		if limit := app.config.quotas.webhooksPerUser; limit > 0 {
+			q.Limit = &limit
+		}
+	}
+
+	return q, nil
+}
+
+// checkQuota sends a quota_exceeded response and returns false if the caller
+// may not create another resource. Concurrent requests can overshoot the
+// limit slightly as the check and the insert are not atomic.
+func (app *application) checkQuota(w http.ResponseWriter, r *http.Request, resource string) bool {
+	return app.checkQuotaFor(w, r, resource, 1)
+}
+
+// checkQuotaFor is checkQuota for creating n resources at once.
+func (app *application) checkQuotaFor(w http.ResponseWriter, r *http.Request, resource string, n int) bool {
+	q, err := app.quota(r, resource)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if q.exceeded(n) {
+		app.quotaExceededResponse(w, r, resource)
+		return false
+	}
+
+	return true
+}
+
+func (app *application) showUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	limits := map[string]quota{}
+
+	for _, resource := range []string{quotaMovies, quotaWebhooks} {
+		q, err := app.quota(r, resource)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		limits[resource] = q
+	}
+
+	err := app.writeJSON(w, r, http.StatusOK, envelope{"limits": limits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
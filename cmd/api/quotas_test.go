@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/webhook"
+)
+
+func TestCreateMovieQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		wantCode int
+		wantBody string
+	}{
+		{"Unlimited", 0, http.StatusCreated, `"title":"Quota Test"`},
+		{"Below limit", 3, http.StatusCreated, `"title":"Quota Test"`},
+		{"At limit", 2, http.StatusForbidden, `"code":"quota_exceeded"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.quotas.moviesPerOrg = tt.limit
+			insertMovie(t, app, testMovie())
+			insertMovie(t, app, testMovie())
+
+			ts := newTestServer(t, app.routesTest())
+			defer ts.Close()
+
+			body := []byte(`{"title": "Quota Test", "year": 2021, "runtime": "105 mins", "genres": ["drama"]}`)
+
+			code, _, rsBody := ts.postForm(t, "/v1/movies", body)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, rsBody, tt.wantBody)
+		})
+	}
+}
+
+func TestCreateWebhookQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		wantCode int
+	}{
+		{"Unlimited", 0, http.StatusCreated},
+		{"Below limit", 2, http.StatusCreated},
+		{"At limit", 1, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := httptest.NewServer(http.NotFoundHandler())
+			defer consumer.Close()
+
+			app, token := newMemoryTestApplication(t)
+			app.config.quotas.webhooksPerUser = tt.limit
+			app.webhooks = webhook.NewClient(time.Second, true)
+			app.config.dev = true
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			body := `{"url": "` + consumer.URL + `", "events": ["movie.created"]}`
+
+			code, _ := ts.do(t, http.MethodPost, "/v1/webhooks", token, body)
+			assert.Equal(t, code, http.StatusCreated)
+
+			code, _ = ts.do(t, http.MethodPost, "/v1/webhooks", token, body)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestShowUserLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		wantBody string
+	}{
+		{"Unlimited", 0, `"movies":{"used":2,"limit":null},"webhooks":{"used":1,"limit":null}`},
+		{"Limited", 10, `"movies":{"used":2,"limit":10},"webhooks":{"used":1,"limit":10}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.quotas.moviesPerOrg = tt.limit
+			app.config.quotas.webhooksPerUser = tt.limit
+			for i := 0; i < 2; i++ {
+				movie := testMovie()
+				movie.OrgID = 1
+				insertMovie(t, app, movie)
+			}
+			userID := insertUser(t, app, "limits@example.com")
+			err := app.models.Webhooks.Insert(&data.Webhook{UserID: userID, OrgID: 1, URL: "https://example.com", Events: []string{"movie.created"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/me/limits", nil)
+			r = app.contextSetUser(r, &data.User{ID: userID, Activated: true, OrgID: 1})
+
+			app.showUserLimitsHandler(w, r)
+
+			assert.Equal(t, w.Code, http.StatusOK)
+			assert.StringContains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+}
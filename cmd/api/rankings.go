@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/jobs"
+	"greenlight.bcc/internal/validator"
+)
+
+// listRankedMoviesHandler serves a ranking of the organization's published
+// movies from the rankings view, so reads need no aggregation.
+func (app *application) listRankedMoviesHandler(ranking string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := validator.New()
+		qs := newQueryBinder(r.URL.Query(), v)
+
+		limit := qs.Int("limit", 10)
+		v.Check(limit > 0 && limit <= 100, "limit", "must be between 1 and 100")
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		ranked, err := app.models.MovieRankings.Get(app.contextGetUser(r).OrgID, ranking, limit)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		movies := make([]*data.Movie, len(ranked))
+		for i, movie := range ranked {
+			movies[i] = movie.Movie
+		}
+
+		err = app.translateMovies(r, movies...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.addCollections(movies...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Language")
+
+		err = app.writeJSON(w, r, http.StatusOK, envelope{"movies": ranked}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+// refreshRankings refreshes the rankings view as a maintenance job, unless
+// an administrator is already refreshing it.
+func (app *application) refreshRankings() {
+	_, err := app.startMaintenance("refresh-rankings")
+	if err != nil && !errors.Is(err, jobs.ErrAlreadyRunning) {
+		app.logger.PrintError(err, nil)
+	}
+}
+
+func (app *application) refreshRankingsPeriodically() {
+	ticker := time.NewTicker(app.config.rankings.refreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.refreshRankings()
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestMovieRankings(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	movies := map[string]int64{}
+	for _, title := range []string{"Moana", "Coco", "Arrival"} {
+		movie := &data.Movie{Title: title, Year: 2016, Runtime: 100, Status: data.MovieStatusPublished, OrgID: 1}
+		if err := app.models.Movies.Insert(movie); err != nil {
+			t.Fatal(err)
+		}
+		movies[title] = movie.ID
+	}
+
+	for _, name := range []string{"Favourites", "Watch later"} {
+		code, body := ts.do(t, http.MethodPost, "/v1/lists", token, fmt.Sprintf(`{"name": %q}`, name))
+		assert.Equal(t, code, http.StatusCreated)
+		itemsPath := fmt.Sprintf("/v1/lists/%v/items", body["list"].(map[string]any)["id"])
+
+		code, _ = ts.do(t, http.MethodPost, itemsPath, token, fmt.Sprintf(`{"movie_id": %d}`, movies["Moana"]))
+		assert.Equal(t, code, http.StatusCreated)
+	}
+
+	for _, title := range []string{"Arrival", "Arrival", "Arrival", "Arrival", "Coco"} {
+		code, _ := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/comments", movies[title]), token, `{"body": "Loved it"}`)
+		assert.Equal(t, code, http.StatusCreated)
+	}
+
+	ranking := func(path string) string {
+		t.Helper()
+		code, body := ts.do(t, http.MethodGet, path, token, "")
+		assert.Equal(t, code, http.StatusOK)
+		var ranked []string
+		for _, movie := range body["movies"].([]any) {
+			movie := movie.(map[string]any)
+			ranked = append(ranked, fmt.Sprintf("%s:%v", movie["title"], movie["score"]))
+		}
+		return strings.Join(ranked, ", ")
+	}
+
+	assert.Equal(t, ranking("/v1/movies/most-listed"), "Moana:2")
+	assert.Equal(t, ranking("/v1/movies/trending"), "Arrival:8, Moana:6, Coco:2")
+	assert.Equal(t, ranking("/v1/movies/trending?limit=1"), "Arrival:8")
+
+	code, _ := ts.do(t, http.MethodGet, "/v1/movies/trending?limit=500", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	// Movies have no ratings, so there is no top-rated ranking.
+	code, _ = ts.do(t, http.MethodGet, "/v1/movies/top-rated", token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
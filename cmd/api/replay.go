@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replayGroups are the route groups whose requests can be required to carry
+// a nonce, by name as given to -replay-protection.
+var replayGroups = map[string]func(method, path string) bool{
+	// deletes is every DELETE route.
+	"deletes": func(method, path string) bool {
+		return method == http.MethodDelete
+	},
+	// admin is the routes which change settings or run tasks as an admin.
+	"admin": func(method, path string) bool {
+		return isWriteMethod(method) && strings.HasPrefix(path, "/v1/admin/")
+	},
+	// tokens is the routes which issue tokens.
+	"tokens": func(method, path string) bool {
+		return method == http.MethodPost && strings.HasPrefix(path, "/v1/tokens/")
+	},
+	// moderation is the routes which act on reported content.
+	"moderation": func(method, path string) bool {
+		return method == http.MethodPost && strings.HasPrefix(path, "/v1/moderation/")
+	},
+}
+
+func isWriteMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// parseReplayGroups parses a space or comma separated list of the names in
+// replayGroups.
+func parseReplayGroups(val string) ([]string, error) {
+	groups := strings.FieldsFunc(val, func(r rune) bool { return r == ' ' || r == ',' })
+
+	for _, group := range groups {
+		if _, ok := replayGroups[group]; !ok {
+			names := make([]string, 0, len(replayGroups))
+			for name := range replayGroups {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown replay protection group %q, must be one of %s", group, strings.Join(names, ", "))
+		}
+	}
+
+	return groups, nil
+}
+
+// replayProtected reports whether the route belongs to one of the groups
+// configured to require a nonce.
+func (app *application) replayProtected(method, path string) bool {
+	for _, group := range app.config.replay.groups {
+		if replayGroups[group](method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// nonceStore remembers the nonces which have been used until their requests'
+// timestamps fall out of the replay window, after which they would be
+// refused anyway. It is safe for concurrent use.
+type nonceStore struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+func newNonceStore() *nonceStore {
+	return &nonceStore{expires: make(map[string]time.Time)}
+}
+
+// use records key as used until expires, and reports whether it was unused.
+func (s *nonceStore) use(key string, expires, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		for key, exp := range s.expires {
+			if !exp.After(now) {
+				delete(s.expires, key)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if exp, ok := s.expires[key]; ok && exp.After(now) {
+		return false
+	}
+
+	s.expires[key] = expires
+	return true
+}
+
+var (
+	nonceRX = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+	totalReplayRejections = publishMap("replay_rejections")
+)
+
+// requireNonce refuses requests which do not carry a fresh X-Nonce header
+// and an X-Timestamp header, in Unix seconds, within the replay window of
+// the server's clock. A nonce can be used once per user, so a captured or
+// repeated request is refused, and a retry must be sent with a new nonce.
+//
+// Nonces are remembered by each instance, so behind a load balancer the
+// protection relies on a user's requests reaching the same instance.
+func (app *application) requireNonce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce := r.Header.Get("X-Nonce")
+		if !nonceRX.MatchString(nonce) {
+			totalReplayRejections.Add("missing", 1)
+			app.invalidNonceResponse(w, r)
+			return
+		}
+
+		seconds, err := strconv.ParseInt(r.Header.Get("X-Timestamp"), 10, 64)
+		if err != nil {
+			totalReplayRejections.Add("missing", 1)
+			app.invalidNonceResponse(w, r)
+			return
+		}
+
+		now := time.Now()
+		window := app.config.replay.window
+
+		timestamp := time.Unix(seconds, 0)
+		if timestamp.Before(now.Add(-window)) || timestamp.After(now.Add(window)) {
+			totalReplayRejections.Add("stale", 1)
+			app.invalidNonceResponse(w, r)
+			return
+		}
+
+		user := app.contextGetUser(r)
+		key := fmt.Sprintf("%d:%s", user.ID, nonce)
+
+		if !app.nonces.use(key, timestamp.Add(window), now) {
+			totalReplayRejections.Add("replayed", 1)
+			app.replayedRequestResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
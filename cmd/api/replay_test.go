@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestParseReplayGroups(t *testing.T) {
+	groups, err := parseReplayGroups("deletes, admin tokens")
+	assert.NilError(t, err)
+	assert.Equal(t, len(groups), 3)
+
+	_, err = parseReplayGroups("deletes everything")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestRequireNonce(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.config.replay.groups = []string{"deletes"}
+	app.config.replay.window = 5 * time.Minute
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	request := func(method, path, nonce string, timestamp time.Time) int {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if nonce != "" {
+			req.Header.Set("X-Nonce", nonce)
+			req.Header.Set("X-Timestamp", strconv.FormatInt(timestamp.Unix(), 10))
+		}
+
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	// Routes outside the configured groups do not need a nonce.
+	for _, title := range []string{"Moana", "Coco"} {
+		code, _ := ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "`+title+`", "year": 2016, "runtime": "107 mins", "genres": ["animation"]}`)
+		assert.Equal(t, code, http.StatusCreated)
+	}
+
+	now := time.Now()
+
+	assert.Equal(t, request(http.MethodDelete, "/v1/movies/3", "", now), http.StatusBadRequest)
+	assert.Equal(t, request(http.MethodDelete, "/v1/movies/3", "short", now), http.StatusBadRequest)
+	assert.Equal(t, request(http.MethodDelete, "/v1/movies/3", "b9c7f1e2a4d6083f", now.Add(-10*time.Minute)), http.StatusBadRequest)
+
+	assert.Equal(t, request(http.MethodDelete, "/v1/movies/3", "b9c7f1e2a4d6083f", now), http.StatusOK)
+	assert.Equal(t, request(http.MethodDelete, "/v1/movies/4", "b9c7f1e2a4d6083f", now), http.StatusConflict)
+	assert.Equal(t, request(http.MethodDelete, "/v1/movies/4", "0d3a9e6b52c1f874", now), http.StatusOK)
+}
+
+func TestNonceStore(t *testing.T) {
+	s := newNonceStore()
+	now := time.Now()
+
+	assert.Equal(t, s.use("1:a", now.Add(time.Minute), now), true)
+	assert.Equal(t, s.use("1:a", now.Add(time.Minute), now), false)
+	assert.Equal(t, s.use("2:a", now.Add(time.Minute), now), true)
+
+	later := now.Add(2 * time.Minute)
+	assert.Equal(t, s.use("1:a", later.Add(time.Minute), later), true)
+	assert.Equal(t, len(s.expires), 1)
+}
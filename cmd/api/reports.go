@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+func (app *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ContentType string `json:"content_type"`
+		ContentID   int64  `json:"content_id"`
+		Reason      string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	report := &data.Report{
+		ReporterID:  app.contextGetUser(r).ID,
+		ContentType: input.ContentType,
+		ContentID:   input.ContentID,
+		Reason:      input.Reason,
+	}
+
+	v := validator.New()
+
+	if data.ValidateReport(v, report); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	comment, err := app.models.Comments.Get(report.ContentID)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound) || err == nil && comment.IsDeleted():
+		v.AddError("content_id", "must refer to an existing comment")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Reports.Insert(report)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateReport):
+			v.AddError("content_id", "you have already reported this content")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.Filters.Page = qs.Int("page", 1)
+	input.Filters.PageSize = qs.Int("page_size", 20)
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafelist = []string{"created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.withinQueryCost(w, r, input.Filters) {
+		return
+	}
+
+	reports, metadata, err := app.models.Reports.GetOpen(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"reports": reports, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// resolveReportHandler carries out a moderator's decision on a report. The
+// action is applied to the reported content before the report is resolved,
+// so that a failure leaves the report in the queue to be tried again.
+func (app *application) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Action string `json:"action"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateReportAction(v, input.Action); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report, err := app.models.Reports.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if report.Status != data.ReportStatusOpen {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.Action != data.ReportActionDismiss {
+		err = app.takeDownComment(report.ContentID, input.Action == data.ReportActionBan)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Reports.Resolve(report, input.Action, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if input.Action == data.ReportActionDismiss {
+		app.notifyCommentApproved(report.ContentID)
+	}
+
+	app.logger.PrintInfo("report resolved", map[string]string{
+		"report_id":    strconv.FormatInt(report.ID, 10),
+		"content_type": report.ContentType,
+		"action":       report.Action,
+	})
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyCommentApproved tells the author of a reported comment that a
+// moderator has reviewed it and left it up.
+func (app *application) notifyCommentApproved(id int64) {
+	comment, err := app.models.Comments.Get(id)
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.PrintError(err, map[string]string{"kind": data.NotificationReviewApproved})
+		}
+		return
+	}
+
+	app.notify(comment.UserID, data.NotificationReviewApproved, "Your comment was reported and a moderator has reviewed and approved it")
+}
+
+// takeDownComment hides a reported comment and, if ban is set, bans its
+// author and signs them out everywhere. A comment which no longer exists
+// leaves nothing to act on.
+func (app *application) takeDownComment(id int64, ban bool) error {
+	comment, err := app.models.Comments.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	err = app.models.Comments.Hide(comment.ID)
+	if err != nil {
+		return err
+	}
+
+	if !ban {
+		return nil
+	}
+
+	err = app.models.Users.Ban(comment.UserID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return err
+	}
+
+	return app.models.Tokens.DeleteAllForUser(data.ScopeAuthentication, comment.UserID)
+}
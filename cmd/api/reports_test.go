@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestModerationQueue(t *testing.T) {
+	app, authorToken := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	newUser := func(email string, permissions ...string) string {
+		t.Helper()
+
+		user := &data.User{Name: email, Email: email, Locale: "en", Activated: true}
+		if err := user.Password.Set("pa55word"); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.models.Users.Insert(user); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.models.Permissions.AddForUser(user.ID, permissions...); err != nil {
+			t.Fatal(err)
+		}
+		token, err := app.models.Tokens.NewAuthentication(user.ID, 1, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token.Plaintext
+	}
+
+	reporterToken := newUser("reporter@example.com")
+	moderatorToken := newUser("moderator@example.com", "content:moderate")
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MovieStatusPublished, OrgID: 1}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/comments", movie.ID), authorToken, `{"body": "buy cheap watches"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	commentID := int64(body["comment"].(map[string]any)["id"].(float64))
+
+	report := fmt.Sprintf(`{"content_type": "comment", "content_id": %d, "reason": "spam"}`, commentID)
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/reports", reporterToken, `{"content_type": "review", "content_id": 1, "reason": "spam"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/reports", reporterToken, report)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, body = ts.do(t, http.MethodPost, "/v1/reports", reporterToken, report)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "already reported")
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/reports", moderatorToken, report)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/moderation/reports", reporterToken, "")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/moderation/reports", moderatorToken, "")
+	assert.Equal(t, code, http.StatusOK)
+	reports := body["reports"].([]any)
+	assert.Equal(t, len(reports), 2)
+	resolvePath := fmt.Sprintf("/v1/moderation/reports/%d/resolve", int64(reports[0].(map[string]any)["id"].(float64)))
+
+	code, _ = ts.do(t, http.MethodPost, resolvePath, moderatorToken, `{"action": "delete"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body = ts.do(t, http.MethodPost, resolvePath, moderatorToken, `{"action": "ban"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["report"].(map[string]any)["status"].(string), data.ReportStatusResolved)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/moderation/reports", moderatorToken, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["reports"].([]any)), 0)
+
+	code, _ = ts.do(t, http.MethodPost, resolvePath, moderatorToken, `{"action": "dismiss"}`)
+	assert.Equal(t, code, http.StatusConflict)
+
+	comment, err := app.models.Comments.Get(commentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, comment.IsDeleted(), true)
+	assert.Equal(t, comment.Body, "")
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/movies", authorToken, "")
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	code, body = ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", `{"email": "editor@example.com", "password": "pa55word"}`)
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.Equal(t, body["error"].(string), "your user account has been banned")
+}
+
+func TestDismissReportNotifiesAuthor(t *testing.T) {
+	app, authorToken := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	moderator := &data.User{Name: "Moderator", Email: "moderator@example.com", Locale: "en", Activated: true}
+	if err := app.models.Users.Insert(moderator); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(moderator.ID, "content:moderate"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.models.Tokens.NewAuthentication(moderator.ID, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moderatorToken := token.Plaintext
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Status: data.MovieStatusPublished, OrgID: 1}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := ts.do(t, http.MethodPost, fmt.Sprintf("/v1/movies/%d/comments", movie.ID), authorToken, `{"body": "A lovely film"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	commentID := int64(body["comment"].(map[string]any)["id"].(float64))
+
+	code, body = ts.do(t, http.MethodPost, "/v1/reports", moderatorToken, fmt.Sprintf(`{"content_type": "comment", "content_id": %d, "reason": "spam"}`, commentID))
+	assert.Equal(t, code, http.StatusCreated)
+	reportID := int64(body["report"].(map[string]any)["id"].(float64))
+
+	code, _ = ts.do(t, http.MethodPost, fmt.Sprintf("/v1/moderation/reports/%d/resolve", reportID), moderatorToken, `{"action": "dismiss"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	app.wg.Wait()
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/notifications", authorToken, "")
+	assert.Equal(t, code, http.StatusOK)
+	notifications := body["notifications"].([]any)
+	assert.Equal(t, len(notifications), 1)
+	assert.Equal(t, notifications[0].(map[string]any)["kind"].(string), data.NotificationReviewApproved)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/breaker"
+	"greenlight.bcc/internal/data"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pq.Error{Code: "40001"}, true},
+		{&pq.Error{Code: "40P01"}, true},
+		{&pq.Error{Code: "57P01"}, true},
+		{&pq.Error{Code: "08006"}, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{driver.ErrBadConn, true},
+		{io.ErrUnexpectedEOF, true},
+		{&pq.Error{Code: "23505"}, false},
+		{&pq.Error{Code: "57014"}, false},
+		{data.ErrRecordNotFound, false},
+		{context.DeadlineExceeded, false},
+		{&breaker.OpenError{Name: "database", RetryAfter: time.Second}, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, data.IsRetryable(tt.err), tt.want)
+	}
+}
+
+// flakyConn fails its first queries with err, then counts one movie.
+type flakyConn struct {
+	failures *int
+	err      error
+}
+
+func (flakyConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (flakyConn) Close() error                              { return nil }
+func (flakyConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (c flakyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if *c.failures > 0 {
+		*c.failures--
+		return nil, c.err
+	}
+	return &countRows{}, nil
+}
+
+type countRows struct {
+	done bool
+}
+
+func (r *countRows) Columns() []string { return []string{"count"} }
+func (r *countRows) Close() error      { return nil }
+
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+type flakyConnector struct {
+	conn flakyConn
+}
+
+func (c flakyConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c flakyConnector) Driver() driver.Driver                        { return nil }
+
+func TestReadRetries(t *testing.T) {
+	defer func(policy data.RetryPolicy) { data.ReadRetryPolicy = policy }(data.ReadRetryPolicy)
+	data.ReadRetryPolicy = data.RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	count := func(failures int, err error) (int, error) {
+		t.Helper()
+		db := sql.OpenDB(flakyConnector{flakyConn{&failures, err}})
+		defer db.Close()
+		return data.MovieModel{DB: db}.Count(1)
+	}
+
+	before := data.ReadRetries()["Movies.Count"]
+
+	n, err := count(2, &pq.Error{Code: "40001", Message: "could not serialize access"})
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+	assert.Equal(t, data.ReadRetries()["Movies.Count"]-before, int64(2))
+
+	_, err = count(3, &pq.Error{Code: "57P01", Message: "terminating connection due to administrator command"})
+	assert.StringContains(t, fmt.Sprint(err), "administrator command")
+	assert.Equal(t, data.ReadRetries()["Movies.Count"]-before, int64(4))
+
+	_, err = count(1, &pq.Error{Code: "42P01", Message: "relation does not exist"})
+	assert.StringContains(t, fmt.Sprint(err), "relation does not exist")
+	assert.Equal(t, data.ReadRetries()["Movies.Count"]-before, int64(4))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+// insertRevisedMovie stores a movie titled "Test Mock (draft)" and renames
+// it, so that its first revision holds the draft title and details. It
+// returns the movie's ID.
+func insertRevisedMovie(t *testing.T, app *application) int64 {
+	draft := testMovie()
+	draft.Title = "Test Mock (draft)"
+	draft.Synopsis = "A draft synopsis."
+	draft.Tagline = "A draft tagline."
+	draft.AgeRating = "PG"
+
+	movie, err := app.models.Movies.Get(0, insertMovie(t, app, draft))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movie.Title = "Test Mock"
+	movie.Synopsis = "The final synopsis."
+	movie.Tagline = ""
+	movie.AgeRating = "R"
+
+	err = app.models.Movies.Update(movie, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return movie.ID
+}
+
+func TestListMovieRevisions(t *testing.T) {
+	app := newTestApplication(t)
+	id := insertRevisedMovie(t, app)
+	unrevisedID := insertMovie(t, app, testMovie())
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Existing movie",
+			urlPath:  fmt.Sprintf("/v1/movies/%d/history", id),
+			wantCode: http.StatusOK,
+			wantBody: `"title":"Test Mock (draft)"`,
+		},
+		{
+			name:     "Movie without revisions",
+			urlPath:  fmt.Sprintf("/v1/movies/%d/history", unrevisedID),
+			wantCode: http.StatusOK,
+			wantBody: `"revisions":[]`,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/v1/movies/1000/history",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Unexpected error from Model", func(t *testing.T) {
+		ts := newTestServer(t, newBrokenTestApplication(t).routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, fmt.Sprintf("/v1/movies/%d/history", id))
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
+}
+
+func TestRevertMovie(t *testing.T) {
+	app := newTestApplication(t)
+	id := insertRevisedMovie(t, app)
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid revert",
+			urlPath:  fmt.Sprintf("/v1/movies/%d/revert/1", id),
+			wantCode: http.StatusOK,
+			wantBody: `"title":"Test Mock (draft)"`,
+		},
+		{
+			name:     "Non-existent version",
+			urlPath:  fmt.Sprintf("/v1/movies/%d/revert/7", id),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid version",
+			urlPath:  fmt.Sprintf("/v1/movies/%d/revert/foo", id),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/v1/movies/1000/revert/1",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.postForm(t, tt.urlPath, nil)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Restores every field", func(t *testing.T) {
+		movie, err := app.models.Movies.Get(0, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, movie.Title, "Test Mock (draft)")
+		assert.Equal(t, movie.Synopsis, "A draft synopsis.")
+		assert.Equal(t, movie.Tagline, "A draft tagline.")
+		assert.Equal(t, movie.AgeRating, "PG")
+	})
+
+	t.Run("Unexpected error from Update method from Model", func(t *testing.T) {
+		app := newTestApplication(t)
+		id := insertRevisedMovie(t, app)
+		app.models.Movies = failingUpdateMovieModel{app.models.Movies.(data.MemoryMovieModel)}
+
+		ts := newTestServer(t, app.routesTest())
+		defer ts.Close()
+
+		code, _, _ := ts.postForm(t, fmt.Sprintf("/v1/movies/%d/revert/1", id), nil)
+		assert.Equal(t, code, http.StatusInternalServerError)
+	})
+}
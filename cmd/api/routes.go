@@ -1,50 +1,321 @@
 package main
 
 import (
-	"expvar"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/julienschmidt/httprouter"
 )
 
-func (app *application) routes() http.Handler {
+type appRouter struct {
+	*httprouter.Router
+	app *application
+}
+
+// routeTable records the routes the application's routers have registered
+// and the permission each one requires, so that responses can link to the
+// actions the caller may take.
+type routeTable struct {
+	mu          sync.RWMutex
+	permissions map[string]string
+}
+
+func (t *routeTable) add(method, path, code string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if existing, ok := t.permissions[method+" "+path]; !ok || existing == "" {
+		t.permissions[method+" "+path] = code
+	}
+}
+
+// permission returns the permission the route requires, which is "" for
+// routes without one. registered is false for unknown routes.
+func (t *routeTable) permission(method, path string) (code string, registered bool) {
+	if t == nil {
+		return "", false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	code, registered = t.permissions[method+" "+path]
+	return code, registered
+}
+
+func (app *application) newRouter() appRouter {
+	if app.routeTable == nil {
+		app.routeTable = &routeTable{permissions: make(map[string]string)}
+	}
 
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	router.GlobalOPTIONS = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return appRouter{router, app}
+}
+
+// Handler registers the handler and, for GET routes, a matching HEAD route so
+// that every readable resource answers HEAD without a body. Routes listed in
+// routeDeprecations announce their deprecation, routes in a replay protected
+// group require a nonce, and routes with a chaos rule have faults injected.
+func (router appRouter) Handler(method, path string, handler http.Handler) {
+	if router.app.replayProtected(method, path) {
+		handler = router.app.requireNonce(handler)
+	}
+
+	if rule, ok := router.app.chaosRule(method, path); ok {
+		handler = router.app.injectFaults(method+" "+path, rule, handler)
+	}
+
+	if d, ok := routeDeprecations[method+" "+path]; ok {
+		handler = router.app.deprecated(method+" "+path, d, handler)
+	}
+
+	router.app.routeTable.add(method, path, "")
+
+	handler = withRoute(path, router.app.servedInVersion(path, handler))
+
+	router.Router.Handler(method, path, handler)
+
+	if method == http.MethodGet {
+		router.Router.Handler(http.MethodHead, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler.ServeHTTP(headResponseWriter{w}, r)
+		}))
+	}
+}
+
+func (router appRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	router.Handler(method, path, handler)
+}
+
+// RequirePermission registers a handler which only users with the
+// permission may use.
+func (router appRouter) RequirePermission(method, path, code string, handler http.HandlerFunc) {
+	router.app.routeTable.add(method, path, code)
+	router.Handler(method, path, router.app.requirePermission(code, handler))
+}
+
+func withRoute(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if meta, ok := r.Context().Value(requestMetaContextKey).(*requestMeta); ok {
+			meta.route = path
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mount sends requests whose path starts with one of prefixes to sub and
+// everything else to next. httprouter does not allow a static segment next to
+// a wildcard such as /v1/movies/:id, so routes like /v1/movies/external/...
+// are registered on a router of their own.
+func mount(next http.Handler, sub http.Handler, prefixes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				sub.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (app *application) routes() http.Handler {
+
+	router := app.newRouter()
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/readyz", app.readyzHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/openapi.json", app.openAPIHandler)
+
+	router.RequirePermission(http.MethodGet, "/v1/movies", "movies:read", app.listMoviesHandler)
+	router.RequirePermission(http.MethodPost, "/v1/movies", "movies:write", app.createMovieHandler)
+	router.RequirePermission(http.MethodPatch, "/v1/movies", "movies:write", app.batchUpdateMoviesHandler)
+	router.RequirePermission(http.MethodGet, "/v1/movies/:id", "movies:read", app.showMovieHandler)
+	router.RequirePermission(http.MethodPatch, "/v1/movies/:id", "movies:write", app.updateMovieHandler)
+	router.RequirePermission(http.MethodDelete, "/v1/movies/:id", "movies:write", app.deleteMovieHandler)
+	router.RequirePermission(http.MethodPut, "/v1/movies/:id/status", "movies:publish", app.updateMovieStatusHandler)
+	router.RequirePermission(http.MethodGet, "/v1/movies/:id/history", "movies:write", app.listMovieRevisionsHandler)
+	router.RequirePermission(http.MethodPost, "/v1/movies/:id/revert/:version", "movies:write", app.revertMovieHandler)
+	router.RequirePermission(http.MethodPost, "/v1/movies/:id/enrich", "movies:write", app.enrichMovieHandler)
+	router.RequirePermission(http.MethodGet, "/v1/movies/:id/translations", "movies:read", app.listMovieTranslationsHandler)
+	router.RequirePermission(http.MethodPut, "/v1/movies/:id/translations/:locale", "movies:write", app.updateMovieTranslationHandler)
+	router.RequirePermission(http.MethodDelete, "/v1/movies/:id/translations/:locale", "movies:write", app.deleteMovieTranslationHandler)
+	router.RequirePermission(http.MethodGet, "/v1/movies/:id/videos", "movies:read", app.listMovieVideosHandler)
+	router.RequirePermission(http.MethodPost, "/v1/movies/:id/videos", "movies:write", app.createMovieVideoHandler)
+	router.RequirePermission(http.MethodDelete, "/v1/movies/:id/videos/:video_id", "movies:write", app.deleteMovieVideoHandler)
+	router.RequirePermission(http.MethodGet, "/v1/movies/:id/comments", "movies:read", app.listMovieCommentsHandler)
+	router.RequirePermission(http.MethodPost, "/v1/movies/:id/comments", "movies:read", app.createMovieCommentHandler)
+
+	router.HandlerFunc(http.MethodPatch, "/v1/comments/:id", app.requireActivatedUser(app.updateCommentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/comments/:id", app.requireActivatedUser(app.deleteCommentHandler))
+
+	router.RequirePermission(http.MethodGet, "/v1/tombstones", "movies:read", app.listTombstonesHandler)
 
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	router.RequirePermission(http.MethodGet, "/v1/collections", "movies:read", app.listCollectionsHandler)
+	router.RequirePermission(http.MethodPost, "/v1/collections", "movies:write", app.createCollectionHandler)
+	router.RequirePermission(http.MethodGet, "/v1/collections/:id", "movies:read", app.showCollectionHandler)
+	router.RequirePermission(http.MethodPatch, "/v1/collections/:id", "movies:write", app.updateCollectionHandler)
+	router.RequirePermission(http.MethodDelete, "/v1/collections/:id", "movies:write", app.deleteCollectionHandler)
+	router.RequirePermission(http.MethodPut, "/v1/collections/:id/movies", "movies:write", app.updateCollectionMoviesHandler)
+
+	router.RequirePermission(http.MethodGet, "/v1/lists", "movies:read", app.listListsHandler)
+	router.RequirePermission(http.MethodPost, "/v1/lists", "movies:read", app.createListHandler)
+	router.RequirePermission(http.MethodGet, "/v1/lists/:id", "movies:read", app.showListHandler)
+	router.RequirePermission(http.MethodPatch, "/v1/lists/:id", "movies:read", app.updateListHandler)
+	router.RequirePermission(http.MethodDelete, "/v1/lists/:id", "movies:read", app.deleteListHandler)
+	router.RequirePermission(http.MethodGet, "/v1/lists/:id/items", "movies:read", app.listListItemsHandler)
+	router.RequirePermission(http.MethodPost, "/v1/lists/:id/items", "movies:read", app.addListItemHandler)
+	router.RequirePermission(http.MethodPut, "/v1/lists/:id/items/:movie_id", "movies:read", app.moveListItemHandler)
+	router.RequirePermission(http.MethodDelete, "/v1/lists/:id/items/:movie_id", "movies:read", app.removeListItemHandler)
+	router.RequirePermission(http.MethodPost, "/v1/lists/:id/share", "movies:read", app.createListShareHandler)
+	router.RequirePermission(http.MethodDelete, "/v1/lists/:id/share", "movies:read", app.revokeListSharesHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/shared/:signature", app.showSharedListHandler)
+
+	router.HandlerFunc(http.MethodPost, "/v1/reports", app.requireActivatedUser(app.createReportHandler))
+	router.RequirePermission(http.MethodGet, "/v1/moderation/reports", "content:moderate", app.listModerationQueueHandler)
+	router.RequirePermission(http.MethodPost, "/v1/moderation/reports/:id/resolve", "content:moderate", app.resolveReportHandler)
 
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/profile", app.showProfileHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/files/*key", app.showFileHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/files/*key", app.uploadFileHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/uploads", app.requireActivatedUser(app.createUploadHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/uploads/:id/content", app.uploadContentHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/uploads/:id/complete", app.requireActivatedUser(app.completeUploadHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/organizations", app.requireActivatedUser(app.createOrganizationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/organizations/:id/members", app.requireActivatedUser(app.addOrganizationMemberHandler))
+
+	router.HandlerFunc(http.MethodPut, "/v1/notifications/:id/read", app.requireActivatedUser(app.markNotificationReadHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/ws", app.requireActivatedUser(app.websocketHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 
-	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks/email-events", app.emailEventsHandler)
+
+	router.RequirePermission(http.MethodGet, "/v1/admin/debug/requests", "admin:read", app.listDebugRequestsHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/log-level", "admin:read", app.showLogLevelHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/config", "admin:read", app.showConfigHandler)
+	router.RequirePermission(http.MethodPut, "/v1/admin/log-level", "admin:write", app.updateLogLevelHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/users", "admin:read", app.listUsersHandler)
+	router.RequirePermission(http.MethodPost, "/v1/admin/users/:id/impersonate", "admin:impersonate", app.impersonateUserHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/usage", "admin:read", app.listUsageHandler)
+	router.RequirePermission(http.MethodPost, "/v1/admin/invitations", "admin:write", app.createInvitationHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/maintenance", "admin:read", app.listMaintenanceTasksHandler)
+	router.RequirePermission(http.MethodPost, "/v1/admin/maintenance/:task", "admin:write", app.runMaintenanceHandler)
+	router.RequirePermission(http.MethodPost, "/v1/admin/encryption/rotate", "admin:write", app.rotateEncryptionHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/jobs/:id", "admin:read", app.showJobHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/cluster", "admin:read", app.listClusterHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/experiments", "admin:read", app.listExperimentsHandler)
 
-	return app.metrics(app.recoverPanic(app.rateLimit(app.enableCORS(app.authenticate(router)))))
+	router.RequirePermission(http.MethodPost, "/v1/admin/debug/profiles", "admin:write", app.captureDebugProfileHandler)
+	router.RequirePermission(http.MethodGet, "/v1/admin/debug/profiles/:name", "admin:read", app.showDebugProfileHandler)
+
+	router.Handler(http.MethodGet, "/debug/vars", varsHandler())
+	router.RequirePermission(http.MethodGet, "/debug/pprof/*name", "admin:read", pprofHandler)
+	router.HandlerFunc(http.MethodGet, "/metrics", app.prometheusHandler)
+
+	external := app.newRouter()
+	external.RequirePermission(http.MethodPut, "/v1/movies/external/:external_id", "movies:write", app.upsertMovieHandler)
+	external.RequirePermission(http.MethodGet, "/v1/movies/by-external/imdb/:id", "movies:read", app.showMovieByIMDbIDHandler)
+	external.RequirePermission(http.MethodPost, "/v1/movies/import", "movies:write", app.importMoviesHandler)
+
+	handler := mount(router, external, "/v1/movies/external/", "/v1/movies/by-external/", "/v1/movies/import")
+
+	// The static routes under /v1/users/ would clash with /v1/users/:id.
+	users := app.newRouter()
+	users.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/preferences", app.requireActivatedUser(app.showPreferencesHandler))
+	users.HandlerFunc(http.MethodPatch, "/v1/users/me/preferences", app.requireActivatedUser(app.updatePreferencesHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/notifications", app.requireActivatedUser(app.listNotificationsHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/organizations", app.requireActivatedUser(app.listUserOrganizationsHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/usage", app.requireActivatedUser(app.showUserUsageHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/limits", app.requireActivatedUser(app.showUserLimitsHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/security/events", app.requireActivatedUser(app.listSecurityEventsHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/sessions", app.requireActivatedUser(app.listSessionsHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/profile", app.requireActivatedUser(app.showOwnProfileHandler))
+	users.HandlerFunc(http.MethodPatch, "/v1/users/me/profile", app.requireActivatedUser(app.updateProfileHandler))
+	users.HandlerFunc(http.MethodPut, "/v1/users/me/avatar", app.requireActivatedUser(app.updateAvatarHandler))
+	users.HandlerFunc(http.MethodDelete, "/v1/users/me/avatar", app.requireActivatedUser(app.deleteAvatarHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/feed", app.requireActivatedUser(app.showFeedHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/followers", app.requireActivatedUser(app.listFollowersHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/following", app.requireActivatedUser(app.listFollowingHandler))
+	users.HandlerFunc(http.MethodPut, "/v1/users/me/following/:id", app.requireActivatedUser(app.followUserHandler))
+	users.HandlerFunc(http.MethodDelete, "/v1/users/me/following/:id", app.requireActivatedUser(app.unfollowUserHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/genres", app.requireActivatedUser(app.listFollowedGenresHandler))
+	users.HandlerFunc(http.MethodPut, "/v1/users/me/genres/:genre", app.requireActivatedUser(app.followGenreHandler))
+	users.HandlerFunc(http.MethodDelete, "/v1/users/me/genres/:genre", app.requireActivatedUser(app.unfollowGenreHandler))
+	users.HandlerFunc(http.MethodGet, "/v1/users/me/blocks", app.requireActivatedUser(app.listBlocksHandler))
+	users.HandlerFunc(http.MethodPut, "/v1/users/me/blocks/:id", app.requireActivatedUser(app.blockUserHandler))
+	users.HandlerFunc(http.MethodDelete, "/v1/users/me/blocks/:id", app.requireActivatedUser(app.unblockUserHandler))
+
+	handler = mount(handler, users, "/v1/users/activated", "/v1/users/me/")
+
+	// Likewise /v1/webhooks/:id would clash with the static email events
+	// route, which stays on the main router.
+	hooks := app.newRouter()
+	hooks.HandlerFunc(http.MethodGet, "/v1/webhooks", app.requireActivatedUser(app.listWebhooksHandler))
+	hooks.HandlerFunc(http.MethodPost, "/v1/webhooks", app.requireActivatedUser(app.createWebhookHandler))
+	hooks.HandlerFunc(http.MethodDelete, "/v1/webhooks/:id", app.requireActivatedUser(app.deleteWebhookHandler))
+	hooks.HandlerFunc(http.MethodGet, "/v1/webhooks/:id/verify-example", app.requireActivatedUser(app.showWebhookVerifyExampleHandler))
+	hooks.HandlerFunc(http.MethodPost, "/v1/webhooks/:id/test", app.requireActivatedUser(app.testWebhookHandler))
+
+	handler = mount(handler, mount(hooks, router, "/v1/webhooks/email-events"), "/v1/webhooks")
+
+	handler = app.mountPlugins(handler)
+
+	return app.initRequestMeta(app.honeypot(app.negotiateVersion(app.trackInFlight(app.metrics(app.recoverPanic(app.shedLoad(app.restrictIPs(app.recordRequests(app.rateLimit(app.enableCORS(app.authenticate(app.assignExperiments(handler)))))))))))))
 }
 
 func (app *application) routesTest() http.Handler {
-	router := httprouter.New()
-
-	router.NotFound = http.HandlerFunc(app.notFoundResponse)
-	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	router := app.newRouter()
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/readyz", app.readyzHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/openapi.json", app.openAPIHandler)
 
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/movies", app.batchUpdateMoviesHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updateMovieHandler)
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/status", app.updateMovieStatusHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/history", app.listMovieRevisionsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/revert/:version", app.revertMovieHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/enrich", app.enrichMovieHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/comments", app.listMovieCommentsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/comments", app.createMovieCommentHandler)
+
+	router.HandlerFunc(http.MethodPatch, "/v1/comments/:id", app.updateCommentHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/comments/:id", app.deleteCommentHandler)
+
+	router.HandlerFunc(http.MethodPost, "/v1/reports", app.createReportHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/moderation/reports", app.listModerationQueueHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/moderation/reports/:id/resolve", app.resolveReportHandler)
+
+	external := app.newRouter()
+	external.HandlerFunc(http.MethodPut, "/v1/movies/external/:external_id", app.upsertMovieHandler)
+	external.HandlerFunc(http.MethodGet, "/v1/movies/by-external/imdb/:id", app.showMovieByIMDbIDHandler)
+	external.HandlerFunc(http.MethodPost, "/v1/movies/import", app.importMoviesHandler)
 
-	return router
+	return app.authenticate(mount(router, external, "/v1/movies/external/", "/v1/movies/by-external/", "/v1/movies/import"))
 }
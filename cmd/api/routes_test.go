@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestHeadRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	id := insertMovie(t, app, testMovie())
+
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Healthcheck",
+			urlPath:  "/v1/healthcheck",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Existing movie",
+			urlPath:  fmt.Sprintf("/v1/movies/%d", id),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Non-existent movie",
+			urlPath:  "/v1/movies/1000",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := ts.Client().Head(ts.URL + tt.urlPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("Content-Type"), "application/json")
+			assert.Equal(t, len(body), 0)
+		})
+	}
+}
+
+func TestOptionsRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routesTest())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantAllow string
+	}{
+		{
+			name:      "Movie collection",
+			urlPath:   "/v1/movies",
+			wantCode:  http.StatusNoContent,
+			wantAllow: "GET, HEAD, OPTIONS, PATCH, POST",
+		},
+		{
+			name:      "Single movie",
+			urlPath:   "/v1/movies/1",
+			wantCode:  http.StatusNoContent,
+			wantAllow: "DELETE, GET, HEAD, OPTIONS, PATCH",
+		},
+		{
+			name:      "Movie revert",
+			urlPath:   "/v1/movies/1/revert/1",
+			wantCode:  http.StatusNoContent,
+			wantAllow: "OPTIONS, POST",
+		},
+		{
+			name:     "Unknown path",
+			urlPath:  "/v1/unknown",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodOptions, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("Allow"), tt.wantAllow)
+		})
+	}
+}
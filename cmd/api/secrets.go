@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// secret is a flag holding a credential or key. Unless it is given on the
+// command line, it is read from the file named by the environment variable
+// env+"_FILE", such as a Docker secret, or else from env itself. Its value
+// is never logged or shown.
+type secret struct {
+	flag string
+	env  string
+}
+
+var secrets = []secret{
+	{"db-dsn", "GREENLIGHT_DB_DSN"},
+	{"smtp-username", "GREENLIGHT_SMTP_USERNAME"},
+	{"smtp-password", "GREENLIGHT_SMTP_PASSWORD"},
+	{"ses-secret-access-key", "AWS_SECRET_ACCESS_KEY"},
+	{"sendgrid-api-key", "SENDGRID_API_KEY"},
+	{"sentry-dsn", "GREENLIGHT_SENTRY_DSN"},
+	{"captcha-secret", "GREENLIGHT_CAPTCHA_SECRET"},
+	{"tmdb-token", "GREENLIGHT_TMDB_TOKEN"},
+	{"storage-secret", "GREENLIGHT_STORAGE_SECRET"},
+	{"s3-secret-access-key", "AWS_SECRET_ACCESS_KEY"},
+	{"encryption-keys", "GREENLIGHT_ENCRYPTION_KEYS"},
+	{"encryption-index-key", "GREENLIGHT_ENCRYPTION_INDEX_KEY"},
+	{"encryption-kms-secret-access-key", "AWS_SECRET_ACCESS_KEY"},
+}
+
+func isSecret(name string) bool {
+	for _, s := range secrets {
+		if s.flag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSecrets sets the secrets in fs which were not given on the command
+// line from their files or environment variables. It must be called after
+// fs has been parsed.
+func loadSecrets(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for _, s := range secrets {
+		if explicit[s.flag] || fs.Lookup(s.flag) == nil {
+			continue
+		}
+
+		value, fromEnv := os.LookupEnv(s.env)
+
+		if path := os.Getenv(s.env + "_FILE"); path != "" {
+			if fromEnv {
+				return fmt.Errorf("only one of %s and %s_FILE may be set", s.env, s.env)
+			}
+
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("-%s: %w", s.flag, err)
+			}
+			value, fromEnv = strings.TrimRight(string(contents), "\r\n"), true
+		}
+
+		if !fromEnv {
+			continue
+		}
+
+		if err := fs.Set(s.flag, value); err != nil {
+			return fmt.Errorf("-%s: %w", s.flag, err)
+		}
+	}
+
+	return nil
+}
+
+// missingSecrets returns the flags of the secrets cfg needs but does not
+// have, so the server can refuse to start rather than fail on first use.
+func missingSecrets(cfg config) []string {
+	var missing []string
+
+	require := func(name, value string) {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+
+	if !cfg.dev {
+		require("db-dsn", cfg.db.dsn)
+	}
+
+	switch cfg.mailer.backend {
+	case "ses":
+		require("ses-access-key-id", cfg.ses.accessKeyID)
+		require("ses-secret-access-key", cfg.ses.secretAccessKey)
+	case "sendgrid":
+		require("sendgrid-api-key", cfg.sendgrid.apiKey)
+	}
+
+	if cfg.captcha.provider != "" {
+		require("captcha-secret", cfg.captcha.secret)
+	}
+
+	if cfg.storage.backend == "s3" {
+		require("s3-access-key-id", cfg.s3.accessKeyID)
+		require("s3-secret-access-key", cfg.s3.secretAccessKey)
+	}
+
+	if cfg.encryption.keys != "" {
+		require("encryption-index-key", cfg.encryption.indexKey)
+	}
+
+	return missing
+}
+
+// missingSecretsError describes missing secrets along with where each can
+// be set.
+func missingSecretsError(missing []string) error {
+	descriptions := make([]string, len(missing))
+	for i, name := range missing {
+		descriptions[i] = "-" + name
+		for _, s := range secrets {
+			if s.flag == name {
+				descriptions[i] += fmt.Sprintf(" (or %s or %s_FILE)", s.env, s.env)
+			}
+		}
+	}
+	return fmt.Errorf("missing required secrets: %s", strings.Join(descriptions, ", "))
+}
+
+// redactedFlags returns the values of the flags in fs with those of secrets
+// replaced, leaving empty ones empty so that it still shows which are
+// unset. If all is false only the flags given on the command line, or set
+// by loadSecrets, are returned.
+func redactedFlags(fs *flag.FlagSet, all bool) map[string]string {
+	values := make(map[string]string)
+
+	visit := fs.Visit
+	if all {
+		visit = fs.VisitAll
+	}
+
+	visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		if isSecret(f.Name) && value != "" {
+			value = redacted
+		}
+		values[f.Name] = value
+	})
+
+	return values
+}
+
+// redactArgs returns a copy of command line arguments with the values of
+// secrets replaced.
+func redactArgs(args []string) []string {
+	redactedArgs := make([]string, len(args))
+	copy(redactedArgs, args)
+
+	for i := 1; i < len(redactedArgs); i++ {
+		arg := redactedArgs[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !isSecret(name) {
+			continue
+		}
+
+		if hasValue {
+			redactedArgs[i] = arg[:strings.Index(arg, "=")+1] + redacted
+		} else if i+1 < len(redactedArgs) {
+			i++
+			redactedArgs[i] = redacted
+		}
+	}
+
+	return redactedArgs
+}
+
+// varsHandler serves expvar's variables like expvar.Handler, except that
+// the command line is redacted, since secrets may have been passed on it.
+func varsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		fmt.Fprintf(w, "{\n")
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if !first {
+				fmt.Fprintf(w, ",\n")
+			}
+			first = false
+
+			value := kv.Value.String()
+			if kv.Key == "cmdline" {
+				js, _ := json.Marshal(redactArgs(os.Args))
+				value = string(js)
+			}
+
+			fmt.Fprintf(w, "%q: %s", kv.Key, value)
+		})
+		fmt.Fprintf(w, "\n}\n")
+	})
+}
+
+// showConfigHandler shows the server's configuration, as the values of its
+// flags, with secrets redacted.
+func (app *application) showConfigHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, r, http.StatusOK, envelope{"config": app.settings}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
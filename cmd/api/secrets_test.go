@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func newSecretFlags(t *testing.T, args ...string) (*flag.FlagSet, *config) {
+	t.Helper()
+
+	var cfg config
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	fs.StringVar(&cfg.db.dsn, "db-dsn", "", "")
+	fs.StringVar(&cfg.smtp.host, "smtp-host", "localhost", "")
+	fs.StringVar(&cfg.smtp.password, "smtp-password", "default", "")
+	fs.StringVar(&cfg.tmdb.token, "tmdb-token", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return fs, &cfg
+}
+
+func TestLoadSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db_dsn")
+	if err := os.WriteFile(path, []byte("postgres://greenlight:pa55word@db/greenlight\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GREENLIGHT_DB_DSN_FILE", path)
+	t.Setenv("GREENLIGHT_SMTP_PASSWORD", "from-env")
+	t.Setenv("GREENLIGHT_TMDB_TOKEN", "from-env")
+
+	fs, cfg := newSecretFlags(t, "-tmdb-token=from-flag")
+	assert.NilError(t, loadSecrets(fs))
+
+	assert.Equal(t, cfg.db.dsn, "postgres://greenlight:pa55word@db/greenlight")
+	assert.Equal(t, cfg.smtp.password, "from-env")
+	assert.Equal(t, cfg.tmdb.token, "from-flag")
+
+	t.Setenv("GREENLIGHT_DB_DSN", "postgres://other")
+	fs, _ = newSecretFlags(t)
+	assert.StringContains(t, loadSecrets(fs).Error(), "only one of GREENLIGHT_DB_DSN and GREENLIGHT_DB_DSN_FILE")
+
+	t.Setenv("GREENLIGHT_DB_DSN", "")
+	os.Unsetenv("GREENLIGHT_DB_DSN")
+	t.Setenv("GREENLIGHT_DB_DSN_FILE", filepath.Join(t.TempDir(), "missing"))
+	fs, _ = newSecretFlags(t)
+	assert.StringContains(t, loadSecrets(fs).Error(), "-db-dsn")
+}
+
+func TestMissingSecrets(t *testing.T) {
+	var cfg config
+	cfg.mailer.backend = "ses"
+	cfg.ses.accessKeyID = "AKID"
+	cfg.captcha.provider = "hcaptcha"
+
+	missing := missingSecrets(cfg)
+	assert.Equal(t, strings.Join(missing, " "), "db-dsn ses-secret-access-key captcha-secret")
+	assert.StringContains(t, missingSecretsError(missing).Error(), "-db-dsn (or GREENLIGHT_DB_DSN or GREENLIGHT_DB_DSN_FILE)")
+
+	cfg.dev = true
+	cfg.ses.secretAccessKey = "secret"
+	cfg.captcha.secret = "secret"
+	assert.Equal(t, len(missingSecrets(cfg)), 0)
+}
+
+func TestSecretRedaction(t *testing.T) {
+	fs, _ := newSecretFlags(t, "-db-dsn", "postgres://greenlight:pa55word@db/greenlight", "-smtp-host=mail")
+
+	set := redactedFlags(fs, false)
+	assert.Equal(t, len(set), 2)
+	assert.Equal(t, set["db-dsn"], redacted)
+	assert.Equal(t, set["smtp-host"], "mail")
+
+	all := redactedFlags(fs, true)
+	assert.Equal(t, all["smtp-password"], redacted)
+	assert.Equal(t, all["tmdb-token"], "")
+
+	args := redactArgs([]string{"api", "-db-dsn", "postgres://u:p@db", "--smtp-password=hunter2", "-port=4000", "-tmdb-token"})
+	assert.Equal(t, strings.Join(args, " "), "api -db-dsn [REDACTED] --smtp-password=[REDACTED] -port=4000 -tmdb-token")
+
+	app, token := newMemoryTestApplication(t)
+	editor, err := app.models.Users.GetByEmail("editor@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Permissions.AddForUser(editor.ID, "admin:read"); err != nil {
+		t.Fatal(err)
+	}
+	app.settings = all
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, body := ts.do(t, http.MethodGet, "/v1/admin/config", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	config := body["config"].(map[string]any)
+	assert.Equal(t, config["db-dsn"].(string), redacted)
+	assert.Equal(t, config["smtp-host"].(string), "mail")
+
+	code, _ = ts.do(t, http.MethodGet, "/debug/vars", "", "")
+	assert.Equal(t, code, http.StatusOK)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/mailer"
+	"greenlight.bcc/internal/validator"
+)
+
+// Reasons a login fails, as logged.
+const (
+	loginUnknownEmail  = "unknown_email"
+	loginWrongPassword = "wrong_password"
+	loginBanned        = "banned"
+)
+
+// maxUserAgentLength bounds the user agents stored with security events
+// and sessions.
+const maxUserAgentLength = 512
+
+// requestDevice returns the device r was made from, with the name the
+// client gave it.
+func (app *application) requestDevice(r *http.Request, name string) data.Device {
+	device := data.Device{UserAgent: r.UserAgent(), Name: name}
+	if meta := app.contextGetRequestMeta(r); meta != nil {
+		device.IP = meta.clientIP
+	}
+	if len(device.UserAgent) > maxUserAgentLength {
+		device.UserAgent = device.UserAgent[:maxUserAgentLength]
+	}
+	return device
+}
+
+// recordLogin logs a login attempt, which failed for failure unless it is
+// empty, and records it as a security event for user, unless the email
+// address matched no user. The event is stored in the background so that
+// it does not add to how long logins for existing users take. A successful
+// login from a country the user has not logged in from before is flagged
+// and the user is emailed about it.
+func (app *application) recordLogin(r *http.Request, user *data.User, failure string) {
+	device := app.requestDevice(r, "")
+
+	event := &data.SecurityEvent{Kind: data.SecurityLoginSucceeded, IP: device.IP, UserAgent: device.UserAgent}
+	if failure != "" {
+		event.Kind = data.SecurityLoginFailed
+	}
+
+	properties := map[string]string{
+		"event":      event.Kind,
+		"ip":         event.IP,
+		"user_agent": event.UserAgent,
+	}
+	if failure != "" {
+		properties["reason"] = failure
+	}
+
+	location, err := app.geoip.Locate(net.ParseIP(event.IP))
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"ip": event.IP})
+	}
+	event.Country, event.City = location.Country, location.City
+	if location.Country != "" {
+		properties["country"] = location.Country
+	}
+	if location.City != "" {
+		properties["city"] = location.City
+	}
+
+	if user == nil {
+		app.logger.PrintInfo("login attempt", properties)
+		return
+	}
+
+	event.UserID = user.ID
+	properties["user_id"] = strconv.FormatInt(user.ID, 10)
+	app.logger.PrintInfo("login attempt", properties)
+
+	app.background(func() {
+		// Checked before the event is stored, since it would otherwise
+		// always match.
+		newCountry := false
+		if failure == "" && event.Country != "" {
+			var err error
+			newCountry, err = app.models.SecurityEvents.IsNewCountry(user.ID, event.Country)
+			if err != nil {
+				app.logger.PrintError(err, properties)
+			}
+		}
+
+		err := app.models.SecurityEvents.Insert(event)
+		if err != nil {
+			app.logger.PrintError(err, properties)
+		}
+
+		if newCountry {
+			app.flagNewCountry(user, *event, properties)
+		}
+	})
+}
+
+// flagNewCountry records that login was from a new country for the user
+// and emails them about it. It is called from a background goroutine.
+func (app *application) flagNewCountry(user *data.User, login data.SecurityEvent, properties map[string]string) {
+	app.logger.PrintInfo("login anomaly", map[string]string{
+		"rule":    data.SecurityLoginNewCountry,
+		"user_id": properties["user_id"],
+		"ip":      login.IP,
+		"country": login.Country,
+	})
+
+	anomaly := login
+	anomaly.Kind = data.SecurityLoginNewCountry
+
+	err := app.models.SecurityEvents.Insert(&anomaly)
+	if err != nil {
+		app.logger.PrintError(err, properties)
+	}
+
+	emailData := map[string]any{
+		"country":   login.Country,
+		"city":      login.City,
+		"ip":        login.IP,
+		"userAgent": login.UserAgent,
+		"time":      anomaly.CreatedAt.UTC().Format(time.RFC1123),
+	}
+
+	err = app.mailer.SendNotification(user.Email, user.Locale, data.NotificationSecurity, "user_new_country.tmpl", emailData)
+	if err != nil && !errors.Is(err, mailer.ErrSuppressed) && !errors.Is(err, mailer.ErrOptedOut) {
+		app.logger.PrintError(err, nil)
+	}
+}
+
+// listSecurityEventsHandler shows the user the login attempts on their
+// account, newest first, so that they can spot ones they did not make.
+func (app *application) listSecurityEventsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	before := qs.Int64("cursor", 0)
+	limit := qs.Int("limit", 20)
+
+	v.Check(before >= 0, "cursor", "must not be negative")
+	v.Check(limit > 0 && limit <= 100, "limit", "must be between 1 and 100")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, err := app.models.SecurityEvents.GetAllForUser(user.ID, before, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	metadata := envelope{}
+	if len(events) == limit {
+		metadata["next_cursor"] = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// sendNewDeviceEmail tells the user they were logged in from a device they
+// have not used before, in case it was not them.
+func (app *application) sendNewDeviceEmail(user *data.User, device data.Device, at time.Time) {
+	app.background(func() {
+		emailData := map[string]any{
+			"deviceName": device.Name,
+			"userAgent":  device.UserAgent,
+			"ip":         device.IP,
+			"time":       at.UTC().Format(time.RFC1123),
+		}
+
+		err := app.mailer.SendNotification(user.Email, user.Locale, data.NotificationSecurity, "user_new_device.tmpl", emailData)
+		if err != nil && !errors.Is(err, mailer.ErrSuppressed) && !errors.Is(err, mailer.ErrOptedOut) {
+			app.logger.PrintError(err, nil)
+		}
+	})
+}
+
+// listSessionsHandler shows the user the devices they are logged in on.
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	sessions, err := app.models.Tokens.GetSessions(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"sessions": sessions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+// The word lists fake data is made from. Everything is picked with the
+// seeded source, so a seed always produces the same data.
+var (
+	seedFirstNames = []string{"Amelia", "Oliver", "Isla", "Noah", "Ava", "Leo", "Mia", "Arthur", "Zoe", "Hugo", "Chloe", "Felix", "Nadia", "Tariq", "Priya", "Mateo", "Ines", "Kenji", "Sofia", "Elias"}
+	seedLastNames  = []string{"Hughes", "Martin", "Okafor", "Dubois", "Kowalski", "Tanaka", "Silva", "Murphy", "Novak", "Haddad", "Larsen", "Rossi", "Fischer", "Moreau", "Patel", "Garcia", "Nguyen", "Walsh", "Bianchi", "Svensson"}
+
+	seedTitleAdjectives = []string{"Silent", "Last", "Crimson", "Hidden", "Broken", "Endless", "Golden", "Midnight", "Distant", "Forgotten", "Burning", "Frozen", "Electric", "Lonely", "Wild"}
+	seedTitleNouns      = []string{"Harbour", "Kingdom", "Summer", "Orchard", "Frontier", "Signal", "Garden", "Empire", "Horizon", "Witness", "Voyage", "River", "Machine", "Letter", "Country"}
+	seedTitlePatterns   = []string{"The %s %s", "%s %s", "A %s %s", "Beyond the %s %s", "Return of the %s %s"}
+
+	seedGenres = []string{"drama", "comedy", "thriller", "romance", "sci-fi", "horror", "animation", "documentary", "crime", "adventure", "fantasy", "western", "musical", "war", "mystery"}
+
+	seedSynopses = []string{
+		"A retired detective is drawn back for one last case that hits closer to home than expected.",
+		"Two strangers stranded by a storm discover they share a secret neither can afford to reveal.",
+		"A small town's annual festival unravels when a long-buried feud resurfaces.",
+		"An engineer on a remote station receives a signal that should not exist.",
+		"Three siblings reunite to settle their late father's affairs and find far more than debts.",
+		"A young chef risks everything to open a restaurant in the city that rejected her.",
+		"When the river floods, a village must decide who it is willing to save.",
+	}
+	seedTaglines = []string{"Some doors stay closed for a reason.", "Nothing stays buried forever.", "Every journey ends somewhere.", "The truth has a long memory.", "One summer changed everything."}
+
+	seedListNames = []string{"Weekend watchlist", "All-time favourites", "Comfort films", "To watch with the family", "Hidden gems", "Best of the decade", "Rainy day picks", "Date night"}
+
+	seedReviews = []string{
+		"Beautifully shot, though the second half drags a little.",
+		"The lead performance alone is worth the ticket.",
+		"I did not expect to be this moved. Highly recommended.",
+		"Clever premise, but the ending fell flat for me.",
+		"A solid genre piece that knows exactly what it wants to be.",
+		"Rewatched it last night and noticed so much I had missed.",
+		"The soundtrack does a lot of heavy lifting here.",
+		"Not for everyone, but I loved every minute.",
+		"Fun enough, if forgettable.",
+		"The best thing I have seen this year.",
+	}
+)
+
+// seedCounts reports how much seed data was created.
+type seedCounts struct {
+	users    int
+	movies   int
+	lists    int
+	comments int
+}
+
+// seed fills the models with fake users, movies, lists and comments in the
+// configured volumes. Everything goes through the models and is validated
+// as the handlers would, so invalid seed data fails loudly. Every user has
+// the seed password; users which already exist are reused.
+func (app *application) seed() (seedCounts, error) {
+	cfg := app.config.seed
+	rnd := rand.New(rand.NewSource(cfg.randSeed))
+
+	var counts seedCounts
+
+	pick := func(words []string) string {
+		return words[rnd.Intn(len(words))]
+	}
+
+	users := make([]*data.User, 0, cfg.users)
+	for i := 1; i <= cfg.users; i++ {
+		first, last := pick(seedFirstNames), pick(seedLastNames)
+
+		user := &data.User{
+			Name:      first + " " + last,
+			Email:     fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), i),
+			Locale:    "en",
+			Activated: true,
+		}
+		if rnd.Intn(5) == 0 {
+			user.Locale = "fr"
+		}
+
+		err := user.Password.Set(cfg.password)
+		if err != nil {
+			return counts, err
+		}
+
+		v := validator.New()
+		data.ValidateUser(v, user)
+		app.config.password.policy.Validate(v, cfg.password)
+		if !v.Valid() {
+			return counts, seedValidationError("user", v)
+		}
+
+		err = app.models.Users.Insert(user)
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			user, err = app.models.Users.GetByEmail(user.Email)
+			if err != nil {
+				return counts, err
+			}
+			users = append(users, user)
+			continue
+		case err != nil:
+			return counts, err
+		}
+
+		// One user in five is an editor.
+		permissions := []string{"movies:read"}
+		if i%5 == 1 {
+			permissions = append(permissions, "movies:write", "movies:publish")
+		}
+
+		err = app.models.Permissions.AddForUser(user.ID, permissions...)
+		if err != nil {
+			return counts, err
+		}
+
+		if app.config.orgs.defaultID != 0 {
+			err = app.models.Organizations.AddMember(app.config.orgs.defaultID, user.ID, data.RoleMember)
+			if err != nil {
+				return counts, err
+			}
+		}
+
+		users = append(users, user)
+		counts.users++
+	}
+
+	var published []*data.Movie
+	for i := 0; i < cfg.movies; i++ {
+		movie := &data.Movie{
+			Title:     fmt.Sprintf(pick(seedTitlePatterns), pick(seedTitleAdjectives), pick(seedTitleNouns)),
+			Year:      int32(1950 + rnd.Intn(time.Now().Year()-1950+1)),
+			Runtime:   data.Runtime(80 + rnd.Intn(100)),
+			Genres:    seedPickDistinct(rnd, seedGenres, 1+rnd.Intn(3)),
+			Status:    data.MovieStatusPublished,
+			OrgID:     app.config.orgs.defaultID,
+			Synopsis:  pick(seedSynopses),
+			Tagline:   pick(seedTaglines),
+			AgeRating: pick(data.AgeRatings),
+		}
+		if rnd.Intn(5) == 0 {
+			movie.Status = data.MovieStatusDraft
+		}
+
+		v := validator.New()
+		data.ValidateMovie(v, movie)
+		if !v.Valid() {
+			return counts, seedValidationError("movie", v)
+		}
+
+		err := app.models.Movies.Insert(movie)
+		if err != nil {
+			return counts, err
+		}
+
+		if movie.IsPublished() {
+			published = append(published, movie)
+		}
+		counts.movies++
+	}
+
+	if len(users) == 0 || len(published) == 0 {
+		return counts, nil
+	}
+
+	for i := 0; i < cfg.lists; i++ {
+		list := &data.List{
+			UserID: users[rnd.Intn(len(users))].ID,
+			OrgID:  app.config.orgs.defaultID,
+			Name:   pick(seedListNames),
+			Public: rnd.Intn(10) < 7,
+		}
+
+		v := validator.New()
+		data.ValidateList(v, list)
+		if !v.Valid() {
+			return counts, seedValidationError("list", v)
+		}
+
+		err := app.models.Lists.Insert(list)
+		if err != nil {
+			return counts, err
+		}
+
+		size := 3 + rnd.Intn(8)
+		if size > len(published) {
+			size = len(published)
+		}
+		for _, j := range rnd.Perm(len(published))[:size] {
+			_, err = app.models.Lists.AddItem(list.ID, published[j].ID, 0)
+			if err != nil {
+				return counts, err
+			}
+		}
+
+		counts.lists++
+	}
+
+	// The catalog has no ratings, so reviews are comments.
+	for i := 0; i < cfg.comments; i++ {
+		comment := &data.Comment{
+			MovieID: published[rnd.Intn(len(published))].ID,
+			UserID:  users[rnd.Intn(len(users))].ID,
+			Body:    pick(seedReviews),
+		}
+
+		v := validator.New()
+		data.ValidateComment(v, comment)
+		if !v.Valid() {
+			return counts, seedValidationError("comment", v)
+		}
+
+		err := app.models.Comments.Insert(comment)
+		if err != nil {
+			return counts, err
+		}
+
+		counts.comments++
+	}
+
+	return counts, nil
+}
+
+// seedPickDistinct returns n different words, in a random order.
+func seedPickDistinct(rnd *rand.Rand, words []string, n int) []string {
+	picked := make([]string, n)
+	for i, j := range rnd.Perm(len(words))[:n] {
+		picked[i] = words[j]
+	}
+	return picked
+}
+
+func seedValidationError(kind string, v *validator.Validator) error {
+	return fmt.Errorf("invalid seed %s: %v", kind, v.Errors)
+}
+
+// seedAndReport seeds the models and logs what was created.
+func (app *application) seedAndReport() error {
+	counts, err := app.seed()
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("seed data created", map[string]string{
+		"users":    strconv.Itoa(counts.users),
+		"movies":   strconv.Itoa(counts.movies),
+		"lists":    strconv.Itoa(counts.lists),
+		"comments": strconv.Itoa(counts.comments),
+		"password": app.config.seed.password,
+	})
+
+	return nil
+}
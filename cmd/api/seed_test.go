@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestSeed(t *testing.T) {
+	app, _ := newMemoryTestApplication(t)
+	app.config.orgs.defaultID = 1
+	app.config.password.policy = data.DefaultPasswordPolicy
+	app.config.seed.users = 5
+	app.config.seed.movies = 20
+	app.config.seed.lists = 4
+	app.config.seed.comments = 10
+	app.config.seed.password = "pa55word"
+	app.config.seed.randSeed = 42
+
+	before, err := app.models.Movies.Count(1)
+	assert.NilError(t, err)
+
+	counts, err := app.seed()
+	assert.NilError(t, err)
+	assert.Equal(t, counts.users, 5)
+	assert.Equal(t, counts.movies, 20)
+	assert.Equal(t, counts.lists, 4)
+	assert.Equal(t, counts.comments, 10)
+
+	after, err := app.models.Movies.Count(1)
+	assert.NilError(t, err)
+	assert.Equal(t, after-before, 20)
+
+	users, _, err := app.models.Users.GetAll(data.CreatedRange{}, data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}})
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 6)
+
+	match, err := users[1].Password.Matches("pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, match, true)
+
+	permissions, err := app.models.Permissions.GetAllForUser(users[1].ID)
+	assert.NilError(t, err)
+	assert.Equal(t, permissions.Include("movies:write"), true)
+
+	// Seeding again with the same source reuses the users.
+	counts, err = app.seed()
+	assert.NilError(t, err)
+	assert.Equal(t, counts.users, 0)
+	assert.Equal(t, counts.movies, 20)
+}
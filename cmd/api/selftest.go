@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/data"
+)
+
+const (
+	selfTestPass = "pass"
+	selfTestFail = "fail"
+	selfTestSkip = "skip"
+)
+
+// errSelfTestSkipped is returned by checks which do not apply to the
+// configuration, such as database checks in development mode.
+var errSelfTestSkipped = errors.New("skipped")
+
+type selfTestResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// selfTestReport is printed by -selftest. Passed is false if any check
+// failed; skipped checks do not count.
+type selfTestReport struct {
+	Passed  bool             `json:"passed"`
+	Version string           `json:"version"`
+	Checks  []selfTestResult `json:"checks"`
+}
+
+type selfTestCheck struct {
+	name string
+	run  func(ctx context.Context) (detail string, err error)
+}
+
+// selfTest checks the configured dependencies one after another, each
+// within the self-test timeout. db is nil in development mode.
+func (app *application) selfTest(db *sql.DB) selfTestReport {
+	checks := []selfTestCheck{
+		{"database_read_write", func(ctx context.Context) (string, error) {
+			if db == nil {
+				return "no database in development mode", errSelfTestSkipped
+			}
+			return "", data.CheckReadWrite(ctx, db)
+		}},
+		{"migrations", func(ctx context.Context) (string, error) {
+			if db == nil {
+				return "no database in development mode", errSelfTestSkipped
+			}
+			return app.checkMigrations(ctx, db)
+		}},
+		{"mailer", func(ctx context.Context) (string, error) {
+			return app.config.mailer.backend, app.mailer.Check(ctx)
+		}},
+		{"storage", func(ctx context.Context) (string, error) {
+			return app.config.storage.backend, app.checkStorage(ctx)
+		}},
+	}
+
+	report := selfTestReport{Passed: true, Version: version}
+
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.selftest.timeout)
+		start := time.Now()
+		detail, err := check.run(ctx)
+		cancel()
+
+		result := selfTestResult{
+			Name:       check.name,
+			Status:     selfTestPass,
+			DurationMs: time.Since(start).Milliseconds(),
+			Detail:     detail,
+		}
+
+		switch {
+		case errors.Is(err, errSelfTestSkipped):
+			result.Status = selfTestSkip
+		case err != nil:
+			result.Status = selfTestFail
+			result.Error = err.Error()
+			report.Passed = false
+		}
+
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
+
+// checkMigrations fails if the last migration failed part way through, or
+// if the database is behind the newest migration in the migrations
+// directory. The directory is optional, as it is not always deployed.
+func (app *application) checkMigrations(ctx context.Context, db *sql.DB) (string, error) {
+	version, dirty, err := data.MigrationVersion(ctx, db)
+	if err != nil {
+		return "", err
+	}
+
+	detail := fmt.Sprintf("version %d", version)
+
+	if dirty {
+		return detail, fmt.Errorf("migration %d is dirty", version)
+	}
+
+	latest, err := latestMigration(app.config.selftest.migrationsDir)
+	if err != nil {
+		return detail + ", migrations directory not readable", nil
+	}
+
+	if version != latest {
+		return detail, fmt.Errorf("database is at migration %d but the latest is %d", version, latest)
+	}
+
+	return detail, nil
+}
+
+// latestMigration returns the highest version among migrate's
+// NNNNNN_name.up.sql files in dir.
+func latestMigration(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".up.sql") {
+			continue
+		}
+
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
+
+// checkStorage stores a random object, reads it back and deletes it.
+func (app *application) checkStorage(ctx context.Context) error {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+
+	key := "selftest/" + hex.EncodeToString(b)
+
+	err = app.storage.Put(ctx, key, bytes.NewReader(b), int64(len(b)), "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	defer app.storage.Delete(context.Background(), key)
+
+	f, err := app.storage.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	got, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(got, b) {
+		return errors.New("object read back differs from the one stored")
+	}
+
+	return app.storage.Delete(ctx, key)
+}
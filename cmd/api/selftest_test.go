@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/storage"
+)
+
+// brokenStore is a store whose writes fail.
+type brokenStore struct {
+	storage.Store
+}
+
+func (s brokenStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	return errors.New("bucket not found")
+}
+
+func TestSelfTest(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.selftest.timeout = time.Second
+
+	report := app.selfTest(nil)
+	assert.Equal(t, report.Passed, true)
+	assert.Equal(t, len(report.Checks), 4)
+	assert.Equal(t, report.Checks[0].Status, selfTestSkip)
+	assert.Equal(t, report.Checks[1].Status, selfTestSkip)
+	assert.Equal(t, report.Checks[2].Status, selfTestPass)
+	assert.Equal(t, report.Checks[3].Status, selfTestPass)
+
+	app.storage = brokenStore{app.storage}
+
+	report = app.selfTest(nil)
+	assert.Equal(t, report.Passed, false)
+	assert.Equal(t, report.Checks[3].Status, selfTestFail)
+	assert.Equal(t, report.Checks[3].Error, "bucket not found")
+}
+
+func TestLatestMigration(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"000001_create_movies.up.sql", "000001_create_movies.down.sql", "000012_add_index.up.sql", "README.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := latestMigration(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, latest, int64(12))
+}
@@ -7,8 +7,11 @@ import (
 	"net/http"
 	"os"        // New import
 	"os/signal" // New import
-	"syscall"   // New import
+	"strconv"
+	"syscall" // New import
 	"time"
+
+	"greenlight.bcc/internal/jsonlog"
 )
 
 func (app *application) serve() error {
@@ -20,6 +23,39 @@ func (app *application) serve() error {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	go app.reloadOnSIGHUP()
+
+	if app.usage != nil {
+		go app.flushUsagePeriodically()
+	}
+
+	if app.watchdog != nil {
+		go app.watchRuntimePeriodically()
+	}
+
+	if app.config.rankings.refreshInterval > 0 {
+		go app.refreshRankingsPeriodically()
+	}
+
+	if app.config.tombstones.retention > 0 {
+		go app.purgeTombstonesPeriodically()
+	}
+
+	if app.cluster != nil {
+		app.joinCluster()
+		go app.heartbeatPeriodically()
+	}
+
+	activity := app.events.SubscribeAll(256)
+	app.background(func() {
+		app.recordActivities(activity)
+	})
+
+	deliveries := app.events.SubscribeAll(256)
+	app.background(func() {
+		app.deliverWebhooks(deliveries)
+	})
+
 	shutdownError := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -31,21 +67,7 @@ func (app *application) serve() error {
 			"signal": s.String(),
 		})
 
-		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-		defer cancel()
-
-		err := srv.Shutdown(ctx)
-		if err != nil {
-			shutdownError <- err
-		}
-
-		app.logger.PrintInfo("completing background tasks", map[string]string{
-			"addr": srv.Addr,
-		})
-
-		app.wg.Wait()
-		shutdownError <- nil
-
+		shutdownError <- app.shutdown(srv)
 	}()
 	app.logger.PrintInfo("starting server", map[string]string{
 		"addr": srv.Addr,
@@ -67,3 +89,114 @@ func (app *application) serve() error {
 	})
 	return nil
 }
+
+// shutdown drains the server in stages: readiness is flipped to failing so
+// load balancers stop routing new traffic, in-flight requests are given
+// drainTimeout to finish, and background tasks are given backgroundTimeout.
+func (app *application) shutdown(srv *http.Server) error {
+	app.draining.Store(true)
+	srv.SetKeepAlivesEnabled(false)
+
+	// Leave the cluster first so peers stop counting on this instance.
+	if app.cluster != nil {
+		app.leaveCluster()
+	}
+
+	// Hijacked WebSocket connections are not tracked by srv.Shutdown, so
+	// close the event bus to make their handlers send a close frame.
+	app.events.Close()
+
+	if app.config.shutdown.readinessDelay > 0 {
+		app.logger.PrintInfo("waiting for load balancers to observe readiness change", map[string]string{
+			"delay": app.config.shutdown.readinessDelay.String(),
+		})
+		time.Sleep(app.config.shutdown.readinessDelay)
+	}
+
+	app.logger.PrintInfo("draining in-flight requests", map[string]string{
+		"addr":      srv.Addr,
+		"in_flight": strconv.FormatInt(app.inFlight.Load(), 10),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdown.drainTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		app.logger.PrintInfo("aborting in-flight requests", map[string]string{
+			"aborted": strconv.FormatInt(app.inFlight.Load(), 10),
+		})
+
+		err = srv.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	app.flushUsage()
+
+	app.logger.PrintInfo("completing background tasks", map[string]string{
+		"addr": srv.Addr,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(app.config.shutdown.backgroundTimeout):
+		app.logger.PrintInfo("background tasks did not complete in time", map[string]string{
+			"timeout": app.config.shutdown.backgroundTimeout.String(),
+		})
+	}
+
+	return nil
+}
+
+func (app *application) reloadOnSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		err := app.reloadLogging()
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			continue
+		}
+
+		app.logger.PrintInfo("reloaded logging configuration", map[string]string{
+			"level": app.logger.Level().String(),
+		})
+	}
+}
+
+// reloadLogging re-reads the minimum level from the level file, if one is
+// configured, and reopens the log sinks so rotated files are released.
+func (app *application) reloadLogging() error {
+	if app.config.log.levelFile != "" {
+		b, err := os.ReadFile(app.config.log.levelFile)
+		if err != nil {
+			return err
+		}
+
+		level, err := jsonlog.ParseLevel(string(b))
+		if err != nil {
+			return err
+		}
+
+		app.logger.SetLevel(level)
+	}
+
+	if app.logSink != nil {
+		return app.logSink.Reopen()
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// shedExempt reports whether a request bypasses load shedding. Health
+// checks must keep answering so that an overloaded instance is not also
+// marked dead, admins need to reach the server to deal with the overload,
+// and WebSocket connections would hold a slot for as long as they stay open.
+func shedExempt(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/v1/healthcheck", "/v1/readyz", "/v1/ws":
+		return true
+	}
+	return isRestrictedPath(r.URL.Path)
+}
+
+// shedLoad caps the number of requests handled at once. A request arriving
+// when all slots are taken waits up to the queue timeout for one to free
+// up; if the queue is already full, or the wait times out, it gets a 503
+// straight away so that the requests being served keep their latency.
+func (app *application) shedLoad(next http.Handler) http.Handler {
+	cfg := app.config.shed
+	if cfg.maxConcurrent <= 0 {
+		return next
+	}
+
+	totalRequestsShed := publishInt("total_requests_shed")
+	requestsQueued := publishInt("requests_queued")
+
+	slots := make(chan struct{}, cfg.maxConcurrent)
+	var queued atomic.Int64
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shedExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			if queued.Add(1) > int64(cfg.maxQueue) {
+				queued.Add(-1)
+				totalRequestsShed.Add(1)
+				app.serviceUnavailableResponse(w, r, time.Second)
+				return
+			}
+			requestsQueued.Add(1)
+
+			timer := time.NewTimer(cfg.queueTimeout)
+
+			select {
+			case slots <- struct{}{}:
+				timer.Stop()
+				queued.Add(-1)
+				requestsQueued.Add(-1)
+			case <-timer.C:
+				queued.Add(-1)
+				requestsQueued.Add(-1)
+				totalRequestsShed.Add(1)
+				app.serviceUnavailableResponse(w, r, time.Second)
+				return
+			case <-r.Context().Done():
+				timer.Stop()
+				queued.Add(-1)
+				requestsQueued.Add(-1)
+				return
+			}
+		}
+
+		defer func() { <-slots }()
+
+		next.ServeHTTP(w, r)
+	})
+}
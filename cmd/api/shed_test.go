@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestShedLoad(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.shed.maxConcurrent = 1
+	app.config.shed.maxQueue = 1
+	app.config.shed.queueTimeout = 50 * time.Millisecond
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := app.shedLoad(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/movies/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	done := make(chan struct{})
+	go func() {
+		serve("/v1/movies/slow")
+		close(done)
+	}()
+	<-started
+
+	rr := serve("/v1/movies")
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "1")
+
+	for _, path := range []string{"/v1/healthcheck", "/v1/readyz", "/v1/admin/users"} {
+		t.Run(path, func(t *testing.T) {
+			assert.Equal(t, serve(path).Code, http.StatusOK)
+		})
+	}
+
+	queued := make(chan int)
+	go func() {
+		queued <- serve("/v1/movies").Code
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-done
+
+	assert.Equal(t, <-queued, http.StatusOK)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+// fakeConn answers queries the way PostgreSQL would when they are cancelled
+// or time out.
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch query {
+	case "slow":
+		<-ctx.Done()
+		return nil, &pq.Error{Code: "57014", Message: "canceling statement due to user request"}
+	case "statement timeout":
+		return nil, &pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"}
+	case "syntax error":
+		return nil, &pq.Error{Code: "42601", Message: "syntax error"}
+	}
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return nil }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+func TestStatementStats(t *testing.T) {
+	stats := &data.StatementStats{}
+	db := sql.OpenDB(data.WithStatementStats(fakeConnector{}, stats))
+	defer db.Close()
+
+	query := func(ctx context.Context, query string) {
+		t.Helper()
+		rows, err := db.QueryContext(ctx, query)
+		if err == nil {
+			rows.Close()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	query(ctx, "slow")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	query(ctx, "slow")
+
+	query(context.Background(), "statement timeout")
+	query(context.Background(), "syntax error")
+	query(context.Background(), "ok")
+
+	assert.Equal(t, stats.TimedOut.Load(), int64(2))
+	assert.Equal(t, stats.Cancelled.Load(), int64(1))
+}
+
+// prepareConn fails to prepare statements with the errors in prepareErrs,
+// one per attempt, and counts the statements it prepares and the queries
+// it runs unprepared.
+type prepareConn struct {
+	prepareErrs *[]error
+	prepared    *int
+	unprepared  *int
+}
+
+func (c prepareConn) Prepare(query string) (driver.Stmt, error) {
+	if len(*c.prepareErrs) > 0 {
+		err := (*c.prepareErrs)[0]
+		*c.prepareErrs = (*c.prepareErrs)[1:]
+		return nil, err
+	}
+	*c.prepared++
+	return fakeStmt{}, nil
+}
+
+func (prepareConn) Close() error              { return nil }
+func (prepareConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c prepareConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	*c.unprepared++
+	return fakeRows{}, nil
+}
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error) { return fakeRows{}, nil }
+
+type prepareConnector struct {
+	conn prepareConn
+}
+
+func (c prepareConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c prepareConnector) Driver() driver.Driver                        { return nil }
+
+func TestStatementPreparation(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantPrepared   int
+		wantUnprepared int
+	}{
+		{"Connection lost", errors.New("connection reset by peer"), 1, 1},
+		{"Server refuses", &pq.Error{Code: "0A000", Message: "prepared statements are not supported"}, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prepareErrs := []error{tt.err}
+			var prepared, unprepared int
+
+			db := sql.OpenDB(prepareConnector{prepareConn{&prepareErrs, &prepared, &unprepared}})
+			defer db.Close()
+
+			models := data.NewModels(db, nil, nil)
+
+			for i := 0; i < 2; i++ {
+				_, err := models.Movies.Get(1, 1)
+				if !errors.Is(err, data.ErrRecordNotFound) {
+					t.Fatalf("got %v; want %v", err, data.ErrRecordNotFound)
+				}
+			}
+
+			assert.Equal(t, prepared, tt.wantPrepared)
+			assert.Equal(t, unprepared, tt.wantUnprepared)
+		})
+	}
+}
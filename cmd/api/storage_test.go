@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/storage"
+)
+
+func TestLocalStorageSignedUploads(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.storage.maxUploadBytes = 1024
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	upload := func(signedURL, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, ts.URL+signedURL[strings.Index(signedURL, "/v1/files/"):], strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "text/plain")
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		return rs.StatusCode
+	}
+
+	signed, err := app.storage.SignedURL("exports/report.txt", http.MethodPut, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, upload(strings.Replace(signed, "signature=", "signature=x", 1), "hello"), http.StatusForbidden)
+	assert.Equal(t, upload(strings.Replace(signed, "report.txt", "other.txt", 1), "hello"), http.StatusForbidden)
+	assert.Equal(t, upload(signed, strings.Repeat("x", 2048)), http.StatusBadRequest)
+	assert.Equal(t, upload(signed, "hello"), http.StatusOK)
+
+	rs, err := ts.Client().Get(ts.URL + "/v1/files/exports/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, string(body), "hello")
+
+	expired, err := app.storage.SignedURL("exports/report.txt", http.MethodPut, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, upload(expired, "hello"), http.StatusForbidden)
+
+	code, _ := ts.do(t, http.MethodGet, "/v1/files/exports/missing.txt", "", "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+// fakeS3 keeps objects in memory and checks that requests are signed.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key-id/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if int64(len(body)) != r.ContentLength {
+			http.Error(w, "wrong length", http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var cfg config
+	cfg.storage.backend = storage.BackendS3
+	cfg.s3.endpoint = server.URL
+	cfg.s3.region = "eu-west-1"
+	cfg.s3.bucket = "greenlight"
+	cfg.s3.accessKeyID = "key-id"
+	cfg.s3.secretAccessKey = "secret"
+
+	store, err := openStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	key, err := storage.PutContent(ctx, store, "posters", ".txt", bytes.NewBufferString("poster"), "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("poster"))
+	assert.Equal(t, key, "posters/"+hex.EncodeToString(sum[:])+".txt")
+
+	f, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(body), "poster")
+	assert.Equal(t, store.URL(key), server.URL+"/greenlight/"+key)
+
+	signed, err := store.SignedURL(key, http.MethodPut, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringContains(t, signed, server.URL+"/greenlight/"+key+"?")
+	assert.StringContains(t, signed, "X-Amz-Expires=300")
+	assert.StringContains(t, signed, "X-Amz-Signature=")
+
+	err = store.Delete(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Get(ctx, key)
+	assert.Equal(t, errors.Is(err, storage.ErrNotFound), true)
+
+	_, err = store.Get(ctx, "../escape")
+	assert.Equal(t, errors.Is(err, storage.ErrInvalidKey), true)
+
+	cfg.s3.bucket = ""
+	_, err = openStore(cfg)
+	assert.StringContains(t, err.Error(), "-s3-bucket")
+
+	cfg.storage.backend = "ftp"
+	_, err = openStore(cfg)
+	assert.Equal(t, errors.Is(err, storage.ErrUnknownBackend), true)
+}
@@ -2,24 +2,99 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"greenlight.bcc/internal/cache"
+	"greenlight.bcc/internal/captcha"
 	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/enrich"
+	"greenlight.bcc/internal/errtrack"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/geoip"
+	"greenlight.bcc/internal/jobs"
 	"greenlight.bcc/internal/jsonlog"
+	"greenlight.bcc/internal/mailer"
+	"greenlight.bcc/internal/oembed"
+	"greenlight.bcc/internal/storage"
+	"greenlight.bcc/internal/webhook"
 )
 
 func newTestApplication(t *testing.T) *application {
 
-	return &application{
-		logger: jsonlog.New(io.Discard, jsonlog.LevelFatal),
-		models: data.NewMockModels(),
-		config: config{
-			cors: struct{ trustedOrigins []string }{
-				trustedOrigins: []string{"http://localhost:3000", "https://example.com"}}},
+	app := &application{
+		logger:   jsonlog.New(io.Discard, jsonlog.LevelFatal),
+		models:   data.NewMemoryModels(),
+		errtrack: errtrack.NoopReporter{},
+		captcha:  captcha.NoopVerifier{},
+		enricher: enrich.NoopEnricher{},
+		oembed:   oembed.NoopFetcher{},
+		geoip:    geoip.NoopLocator{},
+		denylist: newDenylist(),
+		nonces:   newNonceStore(),
+		webhooks: webhook.NewClient(time.Second, true),
+		latency:  newLatencyHistograms(nil, 100*time.Millisecond),
+		events:   events.NewBus(),
+		storage:  storage.NewLocal(t.TempDir(), "http://localhost:4000/v1/files", []byte("storage secret")),
+		mailer:   mailer.New(mailer.NewLog(io.Discard), "test@example.com", time.Second, 0),
+
+		imageVariants: cache.NewLRU[imageVariant](16),
 	}
+	app.config.cors.trustedOrigins = []string{"http://localhost:3000", "https://example.com"}
+	app.jobs = jobs.NewRunner(app.background)
+
+	return app
+}
+
+// newBrokenTestApplication returns a test application whose database is
+// down, so that every call to its models fails.
+func newBrokenTestApplication(t *testing.T) *application {
+	app := newTestApplication(t)
+	app.models = data.NewModels(sql.OpenDB(brokenConnector{}), nil, nil)
+	return app
+}
+
+var errDatabaseDown = errors.New("database down")
+
+type brokenConnector struct{}
+
+func (brokenConnector) Connect(context.Context) (driver.Conn, error) { return nil, errDatabaseDown }
+func (brokenConnector) Driver() driver.Driver                        { return nil }
+
+// insertMovie stores movie in the application's models and returns its ID.
+// The movie is published unless it has a status.
+func insertMovie(t *testing.T, app *application, movie data.Movie) int64 {
+	if movie.Status == "" {
+		movie.Status = data.MovieStatusPublished
+	}
+
+	err := app.models.Movies.Insert(&movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return movie.ID
+}
+
+// insertUser stores an activated user with the email address and returns
+// their ID. The user has no password, as hashing one would slow the tests
+// down.
+func insertUser(t *testing.T, app *application, email string) int64 {
+	user := &data.User{Name: "Test User", Email: email, Locale: "en", Activated: true}
+
+	err := app.models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user.ID
 }
 
 type testServer struct {
@@ -111,3 +186,26 @@ func (ts *testServer) patchForm(t *testing.T, urlPath string, data []byte) (int,
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+func (ts *testServer) putForm(t *testing.T, urlPath string, data []byte) (int, http.Header, string) {
+	reader := bytes.NewReader(data)
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL+urlPath, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/imaging"
+	"greenlight.bcc/internal/jobs"
+	"greenlight.bcc/internal/storage"
+)
+
+// thumbnailSize is one of the standard sizes images are reduced to. Images
+// are cropped to fill it.
+type thumbnailSize struct {
+	name   string
+	width  int
+	height int
+}
+
+// thumbnailSizes are the sizes generated for images, by the prefix of the
+// keys they are stored under.
+var thumbnailSizes = map[string][]thumbnailSize{
+	"posters": {{"small", 92, 138}, {"medium", 185, 278}, {"large", 342, 513}},
+	"avatars": {{"small", 32, 32}, {"medium", 64, 64}, {"large", 128, 128}},
+}
+
+const thumbnailsPrefix = "thumbnails/"
+
+// thumbnailKey returns the key the thumbnail of the image under key is
+// stored under. Images are stored under the hash of their contents, so the
+// same image always has the same thumbnails.
+func thumbnailKey(key, name string) string {
+	return thumbnailsPrefix + key + "/" + name + ".jpg"
+}
+
+// parseThumbnailKey returns the key of the image a thumbnail key was made
+// from and its size.
+func parseThumbnailKey(key string) (string, thumbnailSize, bool) {
+	if !strings.HasPrefix(key, thumbnailsPrefix) {
+		return "", thumbnailSize{}, false
+	}
+
+	source, name := path.Split(strings.TrimPrefix(key, thumbnailsPrefix))
+	source = strings.TrimSuffix(source, "/")
+
+	for _, size := range thumbnailSizes[path.Dir(source)] {
+		if name == size.name+".jpg" && contentHash(source) != "" {
+			return source, size, true
+		}
+	}
+
+	return "", thumbnailSize{}, false
+}
+
+// thumbnailURLs returns the thumbnail URLs of the image at url, by size
+// name, or nil if the image is not one of ours.
+func (app *application) thumbnailURLs(url string) map[string]string {
+	base := app.storage.URL("")
+	if !strings.HasPrefix(url, base) {
+		return nil
+	}
+
+	key := strings.TrimPrefix(url, base)
+	if contentHash(key) == "" {
+		return nil
+	}
+
+	sizes := thumbnailSizes[path.Dir(key)]
+	if len(sizes) == 0 {
+		return nil
+	}
+
+	urls := make(map[string]string, len(sizes))
+	for _, size := range sizes {
+		urls[size.name] = app.storage.URL(thumbnailKey(key, size.name))
+	}
+	return urls
+}
+
+// addThumbnails fills in the thumbnail URLs of the movies, users and
+// profiles in env.
+func (app *application) addThumbnails(env envelope) {
+	for name, value := range env {
+		switch value := value.(type) {
+		case *data.Movie:
+			value.PosterThumbnails = app.thumbnailURLs(value.PosterURL)
+		case data.Movie:
+			value.PosterThumbnails = app.thumbnailURLs(value.PosterURL)
+			env[name] = value
+		case []*data.Movie:
+			for _, movie := range value {
+				movie.PosterThumbnails = app.thumbnailURLs(movie.PosterURL)
+			}
+		case *data.User:
+			value.AvatarThumbnails = app.thumbnailURLs(value.AvatarURL)
+		case []*data.User:
+			for _, user := range value {
+				user.AvatarThumbnails = app.thumbnailURLs(user.AvatarURL)
+			}
+		case *data.Profile:
+			value.AvatarThumbnails = app.thumbnailURLs(value.AvatarURL)
+		}
+	}
+}
+
+// generateThumbnails stores the thumbnails of the image under key before
+// the upload is answered, so that they exist once its URL is handed out.
+// Thumbnails which are already stored are kept, so an image uploaded twice
+// is only reduced once. Failures to write storage are retried in the
+// background, where a job for the same contents which is already running
+// is left to finish.
+func (app *application) generateThumbnails(ctx context.Context, key string) {
+	sum := contentHash(key)
+	sizes := thumbnailSizes[path.Dir(key)]
+	if sum == "" || len(sizes) == 0 {
+		return
+	}
+
+	err := app.storeThumbnails(ctx, key, sizes)
+	if err == nil {
+		return
+	}
+	app.logger.PrintError(err, map[string]string{"task": "generate thumbnails", "key": key})
+	if errors.Is(err, errThumbnailSource) {
+		return
+	}
+
+	_, err = app.jobs.Start("thumbnails-"+sum, []jobs.Step{{
+		Name: "thumbnails",
+		Run: func() error {
+			err := app.storeThumbnailsWithRetries(key, sizes)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"task": "generate thumbnails", "key": key})
+			}
+			return err
+		},
+	}})
+	if err != nil && !errors.Is(err, jobs.ErrAlreadyRunning) {
+		app.logger.PrintError(err, map[string]string{"task": "generate thumbnails", "key": key})
+	}
+}
+
+var errThumbnailSource = errors.New("thumbnail source is not a usable image")
+
+// storeThumbnailsWithRetries retries failures to read or write storage,
+// waiting twice as long before each attempt. Images which cannot be decoded
+// are not retried.
+func (app *application) storeThumbnailsWithRetries(key string, sizes []thumbnailSize) error {
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := app.storeThumbnails(ctx, key, sizes)
+		cancel()
+
+		if err == nil || errors.Is(err, errThumbnailSource) || errors.Is(err, storage.ErrNotFound) || attempt >= app.config.thumbnails.retries {
+			return err
+		}
+
+		totalThumbnailRetries.Add(1)
+		time.Sleep(app.config.thumbnails.retryDelay * time.Duration(1<<attempt))
+	}
+}
+
+var totalThumbnailRetries = publishInt("thumbnail_retries")
+
+// storeThumbnails stores the thumbnails of the image under key which are
+// not stored yet.
+func (app *application) storeThumbnails(ctx context.Context, key string, sizes []thumbnailSize) error {
+	var missing []thumbnailSize
+	for _, size := range sizes {
+		f, err := app.storage.Get(ctx, thumbnailKey(key, size.name))
+		switch {
+		case err == nil:
+			f.Close()
+		case errors.Is(err, storage.ErrNotFound):
+			missing = append(missing, size)
+		default:
+			return err
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	img, err := app.decodeStoredImage(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	for _, size := range missing {
+		var buf bytes.Buffer
+
+		err := jpeg.Encode(&buf, imaging.Fill(img, size.width, size.height), &jpeg.Options{Quality: 85})
+		if err != nil {
+			return err
+		}
+
+		err = app.storage.Put(ctx, thumbnailKey(key, size.name), &buf, int64(buf.Len()), "image/jpeg")
+		if err != nil {
+			return fmt.Errorf("storing %s thumbnail of %s: %w", size.name, key, err)
+		}
+	}
+
+	return nil
+}
+
+func (app *application) decodeStoredImage(ctx context.Context, key string) (image.Image, error) {
+	f, err := app.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil || config.Width < 1 || config.Height < 1 || config.Width*config.Height > maxAvatarPixels {
+		return nil, errThumbnailSource
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, errThumbnailSource
+	}
+
+	return img, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestThumbnails(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.config.avatars.maxBytes = 1 << 20
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	var upload bytes.Buffer
+	if err := png.Encode(&upload, image.NewRGBA(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/v1/users/me/avatar", bytes.NewReader(upload.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "image/png")
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	// The thumbnails are stored before the upload is answered.
+	code, body := ts.do(t, http.MethodGet, "/v1/users/me/profile", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	thumbnails := body["profile"].(map[string]any)["avatar_thumbnails"].(map[string]any)
+	assert.Equal(t, len(thumbnails), 3)
+
+	fetch := func(url string) (int, image.Config) {
+		t.Helper()
+		res, err := ts.Client().Get(ts.URL + url[strings.Index(url, "/v1/files/"):])
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return res.StatusCode, image.Config{}
+		}
+		config, err := jpeg.DecodeConfig(res.Body)
+		assert.NilError(t, err)
+		return res.StatusCode, config
+	}
+
+	for _, size := range thumbnailSizes["avatars"] {
+		code, config := fetch(thumbnails[size.name].(string))
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, config.Width, size.width)
+		assert.Equal(t, config.Height, size.height)
+	}
+
+	// Reading a file never generates a thumbnail which is missing.
+	large := thumbnails["large"].(string)
+	key, _, ok := parseThumbnailKey(strings.TrimPrefix(large, app.storage.URL("")))
+	assert.Equal(t, ok, true)
+	assert.NilError(t, app.storage.Delete(context.Background(), thumbnailKey(key, "large")))
+
+	code, _ = fetch(large)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _ = fetch(strings.Replace(large, "large.jpg", "huge.jpg", 1))
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestParseThumbnailKey(t *testing.T) {
+	source := "posters/" + strings.Repeat("ab", 32) + ".png"
+
+	key, size, ok := parseThumbnailKey(thumbnailKey(source, "medium"))
+	assert.Equal(t, ok, true)
+	assert.Equal(t, key, source)
+	assert.Equal(t, size, thumbnailSizes["posters"][1])
+
+	for _, key := range []string{
+		source,
+		thumbnailKey(source, "huge"),
+		thumbnailKey("posters/poster.png", "small"),
+		thumbnailKey("exports/"+strings.Repeat("ab", 32)+".png", "small"),
+	} {
+		_, _, ok := parseThumbnailKey(key)
+		assert.Equal(t, ok, false)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"greenlight.bcc/internal/data"
@@ -11,8 +12,10 @@ import (
 
 func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email          string `json:"email"`
+		Password       string `json:"password"`
+		OrganizationID int64  `json:"organization_id"`
+		DeviceName     string `json:"device_name"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -24,6 +27,7 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	v := validator.New()
 	data.ValidateEmail(v, input.Email)
 	data.ValidatePasswordPlaintext(v, input.Password)
+	data.ValidateDeviceName(v, input.DeviceName)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -33,6 +37,10 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			// A password is checked either way, so that unknown email
+			// addresses cannot be told apart by how quickly they fail.
+			data.MatchesNoPassword(input.Password)
+			app.recordLogin(r, nil, loginUnknownEmail)
 			app.invalidCredentialsResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -47,18 +55,82 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	}
 
 	if !match {
+		app.recordLogin(r, user, loginWrongPassword)
 		app.invalidCredentialsResponse(w, r)
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	if user.Banned {
+		app.recordLogin(r, user, loginBanned)
+		app.bannedAccountResponse(w, r)
+		return
+	}
+
+	// Hashes made with bcrypt, or with weaker Argon2id parameters than
+	// configured, are upgraded now that the password is known. A failure
+	// only delays that until the next login.
+	if user.Password.NeedsRehash() {
+		err = app.models.Users.RehashPassword(user, input.Password)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"user_id": strconv.FormatInt(user.ID, 10)})
+		}
+	}
+
+	orgID, err := app.resolveOrganization(user.ID, input.OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("organization_id", "you are not a member of this organization")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	device := app.requestDevice(r, input.DeviceName)
+
+	// Checked before the token is stored, since it would otherwise always
+	// match. Failing to check only means no email is sent.
+	newDevice, err := app.models.Tokens.IsNewDevice(user.ID, device)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"user_id": strconv.FormatInt(user.ID, 10)})
+	}
+
+	token, err := app.models.Tokens.NewSession(user.ID, orgID, 24*time.Hour, device)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	app.recordLogin(r, user, "")
+
+	if newDevice {
+		app.sendNewDeviceEmail(user, device, token.CreatedAt)
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"authentication_token": token}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// resolveOrganization returns the organization a new token should act in:
+// the requested one if the user is a member of it, otherwise the one they
+// joined first. Users without any membership get a token without an
+// organization, which sees no movies.
+func (app *application) resolveOrganization(userID, requested int64) (int64, error) {
+	if requested != 0 {
+		_, err := app.models.Organizations.GetRole(requested, userID)
+		if err != nil {
+			return 0, err
+		}
+		return requested, nil
+	}
+
+	orgID, err := app.models.Organizations.GetDefaultForUser(userID)
+	if errors.Is(err, data.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return orgID, err
+}
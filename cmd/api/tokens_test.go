@@ -1 +1,281 @@
 package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/jsonlog"
+	"greenlight.bcc/internal/mailer"
+)
+
+func TestPasswordHashingMemoryLimit(t *testing.T) {
+	defer data.LimitPasswordHashingMemory(data.DefaultPasswordHashingMemoryLimit)
+
+	// Hashes queue for room, and one needing more than the limit runs on
+	// its own rather than never.
+	for _, limit := range []uint64{uint64(data.PasswordHashing.Memory), 1} {
+		data.LimitPasswordHashingMemory(limit)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				var user data.User
+				if err := user.Password.Set("pa55word"); err != nil {
+					t.Error(err)
+					return
+				}
+				if ok, err := user.Password.Matches("pa55word"); !ok || err != nil {
+					t.Errorf("want a match; got %v, %v", ok, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestPasswordRehashOnLogin(t *testing.T) {
+	app, _ := newMemoryTestApplication(t)
+
+	defer func(params data.Argon2idParams) { data.PasswordHashing = params }(data.PasswordHashing)
+	data.PasswordHashing.Iterations++
+
+	user, err := app.models.Users.GetByEmail("editor@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Password.NeedsRehash(), true)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _ := ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", `{"email": "editor@example.com", "password": "wrong password"}`)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	user, err = app.models.Users.GetByEmail("editor@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Password.NeedsRehash(), true)
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", `{"email": "editor@example.com", "password": "pa55word"}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	user, err = app.models.Users.GetByEmail("editor@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Password.NeedsRehash(), false)
+
+	match, err := user.Password.Matches("pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, match, true)
+}
+
+// legacyUserConn holds a single user whose password was hashed with bcrypt,
+// and records the statements executed against it.
+type legacyUserConn struct {
+	hash []byte
+	exec *[]driver.NamedValue
+}
+
+func (legacyUserConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (legacyUserConn) Close() error              { return nil }
+func (legacyUserConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c legacyUserConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &userRows{values: []driver.Value{
+		int64(7), time.Now(), "Legacy", "legacy@example.com", "", "en", "", c.hash, true, int64(1), false, int64(0), int64(0),
+	}}, nil
+}
+
+func (c legacyUserConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	*c.exec = args
+	return driver.RowsAffected(1), nil
+}
+
+type userRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (r *userRows) Columns() []string { return make([]string, len(r.values)) }
+func (r *userRows) Close() error      { return nil }
+
+func (r *userRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
+
+type legacyUserConnector struct {
+	conn legacyUserConn
+}
+
+func (c legacyUserConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c legacyUserConnector) Driver() driver.Driver                        { return nil }
+
+func TestBcryptPasswordRehash(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("pa55word"), bcrypt.MinCost)
+	assert.NilError(t, err)
+
+	var exec []driver.NamedValue
+	db := sql.OpenDB(legacyUserConnector{legacyUserConn{hash: bcryptHash, exec: &exec}})
+	defer db.Close()
+
+	users := data.UserModel{DB: db}
+
+	user, err := users.GetByEmail("legacy@example.com")
+	assert.NilError(t, err)
+
+	match, err := user.Password.Matches("pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, match, true)
+	assert.Equal(t, user.Password.NeedsRehash(), true)
+
+	assert.NilError(t, users.RehashPassword(user, "pa55word"))
+	assert.Equal(t, len(exec), 3)
+
+	newHash := string(exec[0].Value.([]byte))
+	assert.Equal(t, strings.HasPrefix(newHash, "$argon2id$v=19$m=65536,t=3,p=2$"), true)
+	assert.Equal(t, exec[1].Value.(int64), int64(7))
+	assert.Equal(t, string(exec[2].Value.([]byte)), string(bcryptHash))
+
+	match, err = user.Password.Matches("pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, match, true)
+	assert.Equal(t, user.Password.NeedsRehash(), false)
+
+	match, err = user.Password.Matches("pa55words")
+	assert.NilError(t, err)
+	assert.Equal(t, match, false)
+}
+
+func TestLoginSecurityEvents(t *testing.T) {
+	var buf bytes.Buffer
+
+	app, token := newMemoryTestApplication(t)
+	app.logger = jsonlog.New(&buf, jsonlog.LevelInfo)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	login := func(email, password string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/tokens/authentication", strings.NewReader(`{"email": "`+email+`", "password": "`+password+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", "security-test/1.0")
+
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, login("editor@example.com", "wrong password"), http.StatusUnauthorized)
+	assert.Equal(t, login("nobody@example.com", "pa55word"), http.StatusUnauthorized)
+	assert.Equal(t, login("editor@example.com", "pa55word"), http.StatusCreated)
+	app.wg.Wait()
+
+	assert.StringContains(t, buf.String(), `"reason":"wrong_password"`)
+	assert.StringContains(t, buf.String(), `"reason":"unknown_email"`)
+	assert.StringContains(t, buf.String(), `"event":"login_succeeded"`)
+
+	code, body := ts.do(t, http.MethodGet, "/v1/users/me/security/events", token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	events := body["events"].([]any)
+	assert.Equal(t, len(events), 2)
+
+	latest := events[0].(map[string]any)
+	assert.Equal(t, latest["kind"].(string), data.SecurityLoginSucceeded)
+	assert.Equal(t, latest["ip"].(string), "127.0.0.1")
+	assert.Equal(t, latest["user_agent"].(string), "security-test/1.0")
+	assert.Equal(t, events[1].(map[string]any)["kind"].(string), data.SecurityLoginFailed)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/security/events?limit=1", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["events"].([]any)), 1)
+
+	cursor := body["metadata"].(map[string]any)["next_cursor"].(string)
+	code, body = ts.do(t, http.MethodGet, "/v1/users/me/security/events?cursor="+cursor, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["events"].([]any)), 1)
+	assert.Equal(t, body["events"].([]any)[0].(map[string]any)["kind"].(string), data.SecurityLoginFailed)
+}
+
+func TestLoginNewDevice(t *testing.T) {
+	var buf bytes.Buffer
+
+	app, token := newMemoryTestApplication(t)
+	app.mailer = mailer.New(mailer.NewLog(&buf), "test@example.com", time.Second, 0).WithPreferences(app.models.Users)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	login := func(userAgent, deviceName string) int {
+		t.Helper()
+		body := `{"email": "editor@example.com", "password": "pa55word", "device_name": "` + deviceName + `"}`
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/tokens/authentication", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", userAgent)
+
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		app.wg.Wait()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, login("device-test/1.0", "Work laptop"), http.StatusCreated)
+	assert.StringContains(t, buf.String(), "New login to your Greenlight account")
+	assert.StringContains(t, buf.String(), "Work laptop")
+
+	buf.Reset()
+	assert.Equal(t, login("device-test/1.0", "Work laptop"), http.StatusCreated)
+	assert.Equal(t, buf.String(), "")
+
+	assert.Equal(t, login("device-test/1.0", strings.Repeat("x", 101)), http.StatusUnprocessableEntity)
+
+	code, body := ts.do(t, http.MethodGet, "/v1/users/me/sessions", token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	sessions := body["sessions"].([]any)
+	assert.Equal(t, len(sessions), 3)
+
+	latest := sessions[0].(map[string]any)
+	assert.Equal(t, latest["device_name"].(string), "Work laptop")
+	assert.Equal(t, latest["user_agent"].(string), "device-test/1.0")
+	assert.Equal(t, latest["ip"].(string), "127.0.0.1")
+	assert.Equal(t, len(latest["id"].(string)), 16)
+
+	// Users who turned security alerts off are not told.
+	code, _ = ts.do(t, http.MethodPatch, "/v1/users/me/preferences", token, `{"security_alerts": false}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	buf.Reset()
+	assert.Equal(t, login("device-test/2.0", "Phone"), http.StatusCreated)
+	assert.Equal(t, buf.String(), "")
+}
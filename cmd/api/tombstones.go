@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+// listTombstonesHandler lists the movies or lists deleted since a time, for
+// clients syncing changes. Lists are private, so only the caller's own list
+// deletions are returned.
+func (app *application) listTombstonesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.TombstoneQuery
+		data.Filters
+	}
+
+	user := app.contextGetUser(r)
+
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	input.OrgID = user.OrgID
+	input.ResourceType = qs.Enum("resource_type", data.TombstoneMovie, data.TombstoneTypes...)
+	input.DeletedAfter = qs.Time("deleted_after", time.Time{})
+	input.Filters.Page = qs.Int("page", 1)
+	input.Filters.PageSize = qs.Int("page_size", 20)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.ResourceType == data.TombstoneList {
+		input.DeletedBy = user.ID
+	}
+
+	input.Filters.Sort = "deleted_at"
+	input.Filters.SortSafelist = []string{"deleted_at"}
+
+	data.ValidateTombstoneQuery(v, input.TombstoneQuery)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.withinQueryCost(w, r, input.Filters) {
+		return
+	}
+
+	tombstones, metadata, err := app.models.Tombstones.GetAll(input.TombstoneQuery, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"tombstones": tombstones, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeTombstones deletes the tombstones older than the retention period.
+func (app *application) purgeTombstones() {
+	purged, err := app.models.Tombstones.DeleteOlderThan(time.Now().Add(-app.config.tombstones.retention))
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	if purged > 0 {
+		app.logger.PrintInfo("purged tombstones", map[string]string{
+			"count": strconv.FormatInt(purged, 10),
+		})
+	}
+}
+
+func (app *application) purgeTombstonesPeriodically() {
+	ticker := time.NewTicker(app.config.tombstones.purgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.purgeTombstones()
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestTombstones(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.config.tombstones.retention = time.Hour
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	create := func(path, body, key string) string {
+		t.Helper()
+		code, decoded := ts.do(t, http.MethodPost, path, token, body)
+		assert.Equal(t, code, http.StatusCreated)
+		return fmt.Sprint(decoded[key].(map[string]any)["id"])
+	}
+
+	moana := create("/v1/movies", `{"title": "Moana", "year": 2016, "runtime": "107 mins"}`, "movie")
+	arrival := create("/v1/movies", `{"title": "Arrival", "year": 2016, "runtime": "116 mins"}`, "movie")
+	list := create("/v1/lists", `{"name": "Favourites"}`, "list")
+
+	code, _ := ts.do(t, http.MethodDelete, "/v1/movies/"+moana, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	time.Sleep(5 * time.Millisecond)
+	since := url.QueryEscape(time.Now().Format(time.RFC3339Nano))
+	time.Sleep(5 * time.Millisecond)
+
+	code, _ = ts.do(t, http.MethodDelete, "/v1/movies/"+arrival, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	code, _ = ts.do(t, http.MethodDelete, "/v1/lists/"+list, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	ids := func(path string) []string {
+		t.Helper()
+		code, body := ts.do(t, http.MethodGet, path, token, "")
+		assert.Equal(t, code, http.StatusOK)
+		var ids []string
+		for _, tombstone := range body["tombstones"].([]any) {
+			ids = append(ids, fmt.Sprint(tombstone.(map[string]any)["resource_id"]))
+		}
+		return ids
+	}
+
+	assert.Equal(t, fmt.Sprint(ids("/v1/tombstones")), fmt.Sprint([]string{moana, arrival}))
+	assert.Equal(t, fmt.Sprint(ids("/v1/tombstones?deleted_after="+since)), fmt.Sprint([]string{arrival}))
+	assert.Equal(t, fmt.Sprint(ids("/v1/tombstones?resource_type=list")), fmt.Sprint([]string{list}))
+
+	code, _ = ts.do(t, http.MethodGet, "/v1/tombstones?resource_type=comment", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	// Tombstones are kept for the retention period and then purged.
+	app.purgeTombstones()
+	assert.Equal(t, len(ids("/v1/tombstones")), 2)
+
+	app.config.tombstones.retention = -time.Minute
+	app.purgeTombstones()
+	assert.Equal(t, len(ids("/v1/tombstones")), 0)
+}
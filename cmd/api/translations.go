@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/text/language"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+// requestedLocales returns the locales in the Accept-Language header, most
+// preferred first, in the form translations are stored under.
+func requestedLocales(r *http.Request) []string {
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil {
+		return nil
+	}
+
+	var locales []string
+	for _, tag := range tags {
+		base, confidence := tag.Base()
+		if confidence == language.No {
+			continue
+		}
+
+		locale := base.String()
+		if region, confidence := tag.Region(); confidence == language.Exact {
+			locale += "-" + region.String()
+		}
+
+		if validator.Matches(locale, data.LocaleRX) {
+			locales = append(locales, locale)
+		}
+	}
+
+	return locales
+}
+
+// translateMovies replaces the text of movies with their translations into
+// the languages the client accepts.
+func (app *application) translateMovies(r *http.Request, movies ...*data.Movie) error {
+	locales := requestedLocales(r)
+	if len(locales) == 0 {
+		return nil
+	}
+
+	return app.models.MovieTranslations.Apply(movies, locales)
+}
+
+func (app *application) listMovieTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, ok := app.visibleMovie(w, r, id)
+	if !ok {
+		return
+	}
+
+	translations, err := app.models.MovieTranslations.GetAllForMovie(movie.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"translations": translations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateMovieTranslationHandler creates or replaces the movie's translation
+// into the locale in the URL.
+func (app *application) updateMovieTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, ok := app.visibleMovie(w, r, id)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title    string `json:"title"`
+		Synopsis string `json:"synopsis"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	translation := &data.MovieTranslation{
+		MovieID:  movie.ID,
+		Locale:   httprouter.ParamsFromContext(r.Context()).ByName("locale"),
+		Title:    input.Title,
+		Synopsis: input.Synopsis,
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovieTranslation(v, translation); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	created, err := app.models.MovieTranslations.Upsert(translation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	err = app.writeJSON(w, r, status, envelope{"translation": translation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, ok := app.visibleMovie(w, r, id)
+	if !ok {
+		return
+	}
+
+	err = app.models.MovieTranslations.Delete(movie.ID, httprouter.ParamsFromContext(r.Context()).ByName("locale"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "translation successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
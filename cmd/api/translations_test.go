@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestMovieTranslations(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, body := ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "The Lion King", "year": 1994, "runtime": "88 mins", "imdb_id": "tt0110357"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	moviePath := fmt.Sprintf("/v1/movies/%d", int64(body["movie"].(map[string]any)["id"].(float64)))
+
+	code, _ = ts.do(t, http.MethodPut, moviePath+"/status", token, `{"status": "published"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodPut, moviePath+"/translations/french", token, `{"title": "Le Roi lion"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _ = ts.do(t, http.MethodPut, moviePath+"/translations/fr", token, `{"title": "Le Roi Lion"}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, body = ts.do(t, http.MethodPut, moviePath+"/translations/fr", token, `{"title": "Le Roi lion"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["translation"].(map[string]any)["title"].(string), "Le Roi lion")
+
+	get := func(path, acceptLanguage string) (http.Header, map[string]any) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept-Language", acceptLanguage)
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		assert.Equal(t, rs.StatusCode, http.StatusOK)
+
+		var decoded map[string]any
+		if err := json.NewDecoder(rs.Body).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		return rs.Header, decoded
+	}
+
+	headers, body := get(moviePath, "fr-CA, en;q=0.5")
+	movie := body["movie"].(map[string]any)
+	assert.Equal(t, movie["title"].(string), "Le Roi lion")
+	assert.Equal(t, movie["locale"].(string), "fr")
+	assert.Equal(t, headers.Get("Content-Language"), "fr")
+	assert.StringContains(t, fmt.Sprint(headers.Values("Vary")), "Accept-Language")
+
+	headers, body = get(moviePath, "de")
+	assert.Equal(t, body["movie"].(map[string]any)["title"].(string), "The Lion King")
+	assert.Equal(t, headers.Get("Content-Language"), "")
+
+	_, body = get("/v1/movies", "fr")
+	assert.Equal(t, body["movies"].([]any)[0].(map[string]any)["title"].(string), "Le Roi lion")
+
+	for _, path := range []string{"/v1/movies/random", "/v1/movies/by-external/imdb/tt0110357"} {
+		headers, body = get(path, "fr")
+		assert.Equal(t, body["movie"].(map[string]any)["title"].(string), "Le Roi lion")
+		assert.Equal(t, headers.Get("Content-Language"), "fr")
+	}
+
+	code, body = ts.do(t, http.MethodGet, moviePath+"/translations", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["translations"].([]any)), 1)
+
+	code, _ = ts.do(t, http.MethodDelete, moviePath+"/translations/fr", token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodDelete, moviePath+"/translations/fr", token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
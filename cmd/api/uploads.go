@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/storage"
+	"greenlight.bcc/internal/validator"
+)
+
+// uploadTTL is how long a client has to send the file after creating an
+// upload.
+const uploadTTL = 15 * time.Minute
+
+// uploadExtensions gives uploaded files an extension, so that they are
+// served with the right content type.
+var uploadExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// createUploadHandler lets a client send a poster or avatar straight to
+// storage rather than through the API. With S3 the response holds a
+// pre-signed PUT URL; with local storage it holds a URL carrying a one-time
+// token. Either way the file is only used once the upload is completed.
+func (app *application) createUploadHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Purpose     string `json:"purpose"`
+		MovieID     int64  `json:"movie_id"`
+		ContentType string `json:"content_type"`
+		Size        int64  `json:"size"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	upload := &data.Upload{
+		ExpiresAt:   time.Now().Add(uploadTTL).Truncate(time.Second),
+		UserID:      user.ID,
+		Purpose:     input.Purpose,
+		MovieID:     input.MovieID,
+		ContentType: input.ContentType,
+		Size:        input.Size,
+	}
+
+	v := validator.New()
+
+	data.ValidateUpload(v, upload)
+	v.Check(validator.PermittedValue(upload.ContentType, avatarContentTypes...), "content_type", "must be image/jpeg, image/png or image/gif")
+
+	maxBytes := app.config.storage.maxUploadBytes
+	if upload.Purpose == data.UploadAvatar {
+		maxBytes = app.config.avatars.maxBytes
+	}
+	v.Check(upload.Size <= maxBytes, "size", fmt.Sprintf("must not be more than %d bytes", maxBytes))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if upload.Purpose == data.UploadPoster {
+		if _, ok := app.editableMovie(w, r, upload.MovieID); !ok {
+			return
+		}
+	}
+
+	upload.Key, err = newUploadKey(upload.ContentType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var uploadURL, token string
+
+	if _, ok := app.storage.(*storage.Local); ok {
+		token, err = upload.NewToken()
+	} else {
+		uploadURL, err = app.storage.SignedURL(upload.Key, http.MethodPut, uploadTTL)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Uploads.Insert(upload)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if token != "" {
+		uploadURL = fmt.Sprintf("/v1/uploads/%d/content?token=%s", upload.ID, token)
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"upload": upload, "upload_url": uploadURL}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadContentHandler receives the file for an upload with local storage.
+// The token in the URL is the only credential and works once the file has
+// been stored. It is claimed first, so that two requests cannot both write
+// the file, and given back if storing the file fails.
+func (app *application) uploadContentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	local, ok := app.storage.(*storage.Local)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	upload, err := app.models.Uploads.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !upload.MatchesToken(r.URL.Query().Get("token")) || time.Now().After(upload.ExpiresAt) {
+		app.invalidUploadTokenResponse(w, r)
+		return
+	}
+
+	switch {
+	case r.ContentLength < 0:
+		app.errorResponse(w, r, http.StatusLengthRequired, "the Content-Length header must be set")
+		return
+	case r.ContentLength != upload.Size:
+		app.badRequestResponse(w, r, fmt.Errorf("body must be %d bytes", upload.Size))
+		return
+	}
+
+	err = app.models.Uploads.MarkUploaded(upload)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUploadClaimed):
+			app.invalidUploadTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = local.Put(r.Context(), upload.Key, r.Body, r.ContentLength, upload.ContentType)
+	if err != nil {
+		if err := app.models.Uploads.UnmarkUploaded(upload); err != nil {
+			app.logger.PrintError(err, map[string]string{"upload_id": strconv.FormatInt(upload.ID, 10)})
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// completeUploadHandler checks the uploaded file against the size and
+// content type declared when the upload was created, then attaches it to
+// the movie or user.
+func (app *application) completeUploadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	upload, err := app.models.Uploads.Get(id)
+	if err != nil || upload.UserID != user.ID {
+		switch {
+		case err == nil, errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if upload.CompletedAt != nil {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var movie *data.Movie
+	if upload.Purpose == data.UploadPoster {
+		var ok bool
+		if movie, ok = app.editableMovie(w, r, upload.MovieID); !ok {
+			return
+		}
+	}
+
+	v := validator.New()
+
+	body, err := app.inspectUpload(r, upload, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Images are stored again under the hash of their contents.
+	var imageKey string
+	switch upload.Purpose {
+	case data.UploadAvatar:
+		imageKey, err = app.storeAvatar(r.Context(), body, v)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	case data.UploadPoster:
+		imageKey, err = app.storePoster(r.Context(), upload)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Uploads.Complete(upload)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUploadClaimed):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"upload": upload}
+
+	switch upload.Purpose {
+	case data.UploadAvatar:
+		user.AvatarURL = app.storage.URL(imageKey)
+		err = app.models.Users.Update(user)
+		env["user"] = user
+	case data.UploadPoster:
+		movie.PosterURL = app.storage.URL(imageKey)
+		err = app.models.Movies.Update(movie, user.ID)
+		env["movie"] = movie
+	}
+
+	// The image has been stored under its own key, so the original is no
+	// longer needed.
+	if err := app.storage.Delete(r.Context(), upload.Key); err != nil {
+		app.logError(r, err)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if movie != nil {
+		app.publishMovieEvent(events.TypeMovieUpdated, movie)
+	}
+
+	app.generateThumbnails(r.Context(), imageKey)
+
+	err = app.writeJSON(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// storePoster copies an uploaded poster under the hash of its contents, as
+// avatars are stored, so that it can be cached for good and its thumbnails
+// are only generated once.
+func (app *application) storePoster(ctx context.Context, upload *data.Upload) (string, error) {
+	f, err := app.storage.Get(ctx, upload.Key)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return storage.PutContent(ctx, app.storage, "posters", path.Ext(upload.Key), f, upload.ContentType)
+}
+
+// inspectUpload reads the uploaded file and reports through v if it is
+// missing, has the wrong size or does not sniff as the declared content
+// type. Avatars are returned whole for resizing; posters are only counted.
+func (app *application) inspectUpload(r *http.Request, upload *data.Upload, v *validator.Validator) ([]byte, error) {
+	f, err := app.storage.Get(r.Context(), upload.Key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			v.AddError("upload", "the file has not been uploaded")
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+
+	dst := io.Discard
+	if upload.Purpose == data.UploadAvatar {
+		dst = &body
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+	dst.Write(head)
+
+	// Reading one byte past the declared size is enough to tell that the
+	// file is too large.
+	rest, err := io.Copy(dst, io.LimitReader(f, upload.Size+1-int64(n)))
+	if err != nil {
+		return nil, err
+	}
+
+	v.Check(int64(n)+rest == upload.Size, "size", fmt.Sprintf("the uploaded file must be %d bytes", upload.Size))
+	v.Check(http.DetectContentType(head) == upload.ContentType, "content_type", fmt.Sprintf("the uploaded file must be %s", upload.ContentType))
+
+	return body.Bytes(), nil
+}
+
+// editableMovie fetches a movie in the user's organization which the user
+// may change, writing the error response if there is none.
+func (app *application) editableMovie(w http.ResponseWriter, r *http.Request, id int64) (*data.Movie, bool) {
+	canEdit, err := app.userHasPermission(r, "movies:write")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+	if !canEdit {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	movie, err := app.models.Movies.Get(app.contextGetUser(r).OrgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return movie, true
+}
+
+// newUploadKey returns a random, unguessable key for an upload.
+func newUploadKey(contentType string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "uploads/" + hex.EncodeToString(b) + uploadExtensions[contentType], nil
+}
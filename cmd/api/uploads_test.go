@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestUploads(t *testing.T) {
+	app, token := newMemoryTestApplication(t)
+	app.config.avatars.maxBytes = 1 << 20
+	app.config.storage.maxUploadBytes = 1 << 20
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(body string) (int, map[string]any, string) {
+		t.Helper()
+		code, decoded := ts.do(t, http.MethodPost, "/v1/uploads", token, body)
+		uploadURL, _ := decoded["upload_url"].(string)
+		return code, decoded, uploadURL
+	}
+
+	send := func(uploadURL string, body []byte) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, ts.URL+uploadURL, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		return rs.StatusCode
+	}
+
+	uploadID := func(body map[string]any) string {
+		return fmt.Sprint(body["upload"].(map[string]any)["id"])
+	}
+
+	code, body, _ := create(`{"purpose": "avatar", "content_type": "text/plain", "size": 10}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "content_type")
+
+	code, _, _ = create(`{"purpose": "avatar", "content_type": "image/png", "size": 2097152}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _, _ = create(`{"purpose": "poster", "movie_id": 999, "content_type": "image/png", "size": 10}`)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// An avatar goes through a one-time token and is resized on completion.
+	code, body, uploadURL := create(fmt.Sprintf(`{"purpose": "avatar", "content_type": "image/png", "size": %d}`, img.Len()))
+	assert.Equal(t, code, http.StatusCreated)
+	assert.StringContains(t, uploadURL, "/content?token=")
+	id := uploadID(body)
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/uploads/"+id+"/complete", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	assert.Equal(t, send(uploadURL+"x", img.Bytes()), http.StatusForbidden)
+	assert.Equal(t, send(uploadURL, img.Bytes()[:10]), http.StatusBadRequest)
+
+	// A body which ends early is not stored, and the token can be used again.
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, uploadURL, bytes.NewReader(img.Bytes()[:10]))
+	r.ContentLength = int64(img.Len())
+	app.routes().ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+
+	assert.Equal(t, send(uploadURL, img.Bytes()), http.StatusNoContent)
+	assert.Equal(t, send(uploadURL, img.Bytes()), http.StatusForbidden)
+
+	code, body = ts.do(t, http.MethodPost, "/v1/uploads/"+id+"/complete", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, fmt.Sprint(body["user"].(map[string]any)["avatar_url"]), "/v1/files/avatars/")
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/uploads/"+id+"/complete", token, "")
+	assert.Equal(t, code, http.StatusConflict)
+
+	// A poster which is not what it claims to be is rejected.
+	code, body = ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": ["animation"]}`)
+	assert.Equal(t, code, http.StatusCreated)
+	movieID := fmt.Sprint(body["movie"].(map[string]any)["id"])
+
+	code, body, uploadURL = create(fmt.Sprintf(`{"purpose": "poster", "movie_id": %s, "content_type": "image/jpeg", "size": %d}`, movieID, img.Len()))
+	assert.Equal(t, code, http.StatusCreated)
+	assert.Equal(t, send(uploadURL, img.Bytes()), http.StatusNoContent)
+
+	code, body = ts.do(t, http.MethodPost, "/v1/uploads/"+uploadID(body)+"/complete", token, "")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, fmt.Sprint(body["error"]), "must be image/jpeg")
+
+	code, body, uploadURL = create(fmt.Sprintf(`{"purpose": "poster", "movie_id": %s, "content_type": "image/png", "size": %d}`, movieID, img.Len()))
+	assert.Equal(t, code, http.StatusCreated)
+	assert.Equal(t, send(uploadURL, img.Bytes()), http.StatusNoContent)
+
+	code, body = ts.do(t, http.MethodPost, "/v1/uploads/"+uploadID(body)+"/complete", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	posterURL := fmt.Sprint(body["movie"].(map[string]any)["poster_url"])
+	assert.StringContains(t, posterURL, "/v1/files/posters/")
+	assert.Equal(t, len(body["movie"].(map[string]any)["poster_thumbnails"].(map[string]any)), 3)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/movies/"+movieID, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, fmt.Sprint(body["movie"].(map[string]any)["poster_url"]), posterURL)
+
+	// The thumbnails are written to the test's storage directory.
+	app.wg.Wait()
+}
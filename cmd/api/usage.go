@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/validator"
+)
+
+type usageKey struct {
+	day    string
+	userID int64
+	orgID  int64
+}
+
+// usageAggregator counts authenticated requests in memory so that the usage
+// table is written once per flush instead of once per request.
+type usageAggregator struct {
+	mu     sync.Mutex
+	counts map[usageKey]*data.UsageRecord
+}
+
+func newUsageAggregator() *usageAggregator {
+	return &usageAggregator{counts: make(map[usageKey]*data.UsageRecord)}
+}
+
+func (a *usageAggregator) record(userID, orgID int64, status int, now time.Time) {
+	if userID == 0 {
+		return
+	}
+
+	key := usageKey{day: now.UTC().Format("2006-01-02"), userID: userID, orgID: orgID}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	record, ok := a.counts[key]
+	if !ok {
+		record = &data.UsageRecord{Day: key.day, UserID: userID, OrgID: orgID}
+		a.counts[key] = record
+	}
+
+	record.Requests++
+	if status >= 400 {
+		record.Errors++
+	}
+}
+
+// drain returns the counts collected since the last drain and resets them.
+func (a *usageAggregator) drain() []*data.UsageRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	records := make([]*data.UsageRecord, 0, len(a.counts))
+	for _, record := range a.counts {
+		records = append(records, record)
+	}
+	a.counts = make(map[usageKey]*data.UsageRecord)
+
+	return records
+}
+
+// restore adds records which could not be persisted back into the counts, so
+// they are retried on the next flush.
+func (a *usageAggregator) restore(records []*data.UsageRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, record := range records {
+		key := usageKey{day: record.Day, userID: record.UserID, orgID: record.OrgID}
+		if existing, ok := a.counts[key]; ok {
+			existing.Requests += record.Requests
+			existing.Errors += record.Errors
+			continue
+		}
+		a.counts[key] = record
+	}
+}
+
+func (app *application) flushUsage() {
+	if app.usage == nil {
+		return
+	}
+
+	records := app.usage.drain()
+	if len(records) == 0 {
+		return
+	}
+
+	err := app.models.Usage.Add(records)
+	if err != nil {
+		app.usage.restore(records)
+		app.logger.PrintError(err, nil)
+	}
+}
+
+func (app *application) flushUsagePeriodically() {
+	ticker := time.NewTicker(app.config.usage.flushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.flushUsage()
+	}
+}
+
+// readUsageFilter reads the from and to query parameters, which default to
+// the last 30 days.
+func (app *application) readUsageFilter(r *http.Request, v *validator.Validator) data.UsageFilter {
+	qs := newQueryBinder(r.URL.Query(), v)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	return data.UsageFilter{
+		From: qs.Date("from", today.AddDate(0, 0, -29)),
+		To:   qs.Date("to", today),
+	}
+}
+
+func (app *application) showUserUsageHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	filter := app.readUsageFilter(r, v)
+	filter.UserID = app.contextGetUser(r).ID
+
+	if data.ValidateUsageFilter(v, filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	records, err := app.models.Usage.GetAll(filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"usage": records}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listUsageHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := newQueryBinder(r.URL.Query(), v)
+
+	filter := app.readUsageFilter(r, v)
+	filter.UserID = qs.Int64("user_id", 0)
+	filter.OrgID = qs.Int64("org_id", 0)
+
+	if data.ValidateUsageFilter(v, filter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	records, err := app.models.Usage.GetAll(filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"usage": records}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
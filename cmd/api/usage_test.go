@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+)
+
+func TestUsageAggregator(t *testing.T) {
+	agg := newUsageAggregator()
+
+	day := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	agg.record(1, 1, http.StatusOK, day)
+	agg.record(1, 1, http.StatusNotFound, day)
+	agg.record(1, 1, http.StatusOK, day.Add(2*time.Hour))
+	agg.record(0, 0, http.StatusOK, day)
+
+	records := agg.drain()
+	assert.Equal(t, len(records), 2)
+
+	counts := map[string]data.UsageRecord{}
+	for _, record := range records {
+		counts[record.Day] = *record
+	}
+	assert.Equal(t, counts["2024-01-01"], data.UsageRecord{Day: "2024-01-01", UserID: 1, OrgID: 1, Requests: 2, Errors: 1})
+	assert.Equal(t, counts["2024-01-02"], data.UsageRecord{Day: "2024-01-02", UserID: 1, OrgID: 1, Requests: 1})
+
+	assert.Equal(t, len(agg.drain()), 0)
+
+	agg.record(1, 1, http.StatusOK, day)
+	agg.restore(records)
+
+	records = agg.drain()
+	assert.Equal(t, len(records), 2)
+	for _, record := range records {
+		if record.Day == "2024-01-01" {
+			assert.Equal(t, record.Requests, int64(3))
+		}
+	}
+}
+
+func TestMetricsRecordsUsage(t *testing.T) {
+	app := newTestApplication(t)
+	app.usage = newUsageAggregator()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.contextSetUser(r, &data.User{ID: 7, OrgID: 3})
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	handler := app.initRequestMeta(app.metrics(next))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/movies", nil))
+
+	records := app.usage.drain()
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, records[0].UserID, int64(7))
+	assert.Equal(t, records[0].OrgID, int64(3))
+	assert.Equal(t, records[0].Errors, int64(1))
+}
+
+// insertUsage records usage by user 1 in organization 1, today and on
+// 1 January 2024.
+func insertUsage(t *testing.T, app *application) {
+	err := app.models.Usage.Add([]*data.UsageRecord{
+		{Day: time.Now().UTC().Format("2006-01-02"), UserID: 1, OrgID: 1, Requests: 42, Errors: 2},
+		{Day: "2024-01-01", UserID: 1, OrgID: 1, Requests: 7},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShowUserUsage(t *testing.T) {
+	app := newTestApplication(t)
+	insertUsage(t, app)
+
+	tests := []struct {
+		name     string
+		app      *application
+		userID   int64
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Default range", app, 1, "/v1/users/me/usage", http.StatusOK, `"requests":42`},
+		{"Explicit range", app, 1, "/v1/users/me/usage?from=2024-01-01&to=2024-01-31", http.StatusOK, `"day":"2024-01-01"`},
+		{"No usage", app, 3, "/v1/users/me/usage", http.StatusOK, `"usage":[]`},
+		{"Malformed date", app, 1, "/v1/users/me/usage?from=01/01/2024", http.StatusUnprocessableEntity, `"from":"must be a date in YYYY-MM-DD format"`},
+		{"Reversed range", app, 1, "/v1/users/me/usage?from=2024-02-01&to=2024-01-01", http.StatusUnprocessableEntity, `"to":"must not be before from"`},
+		{"Range too long", app, 1, "/v1/users/me/usage?from=2022-01-01&to=2024-01-01", http.StatusUnprocessableEntity, `"to":"must be within 366 days of from"`},
+		{"Unexpected error from Model", newBrokenTestApplication(t), 1, "/v1/users/me/usage", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := tt.app
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.urlPath, nil)
+			r = app.contextSetUser(r, &data.User{ID: tt.userID, Activated: true})
+
+			app.showUserUsageHandler(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestListUsage(t *testing.T) {
+	app := newTestApplication(t)
+	insertUsage(t, app)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Filter by user", "/v1/admin/usage?user_id=1", http.StatusOK, `"user_id":1`},
+		{"All users", "/v1/admin/usage?org_id=1", http.StatusOK, `"requests":42`},
+		{"No usage", "/v1/admin/usage?org_id=2", http.StatusOK, `"usage":[]`},
+		{"Invalid user_id", "/v1/admin/usage?user_id=abc", http.StatusUnprocessableEntity, `"user_id":"must be an integer value"`},
+		{"Negative org_id", "/v1/admin/usage?org_id=-1", http.StatusUnprocessableEntity, `"org_id":"must not be negative"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.urlPath, nil)
+
+			app.listUsageHandler(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			assert.StringContains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+}
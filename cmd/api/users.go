@@ -6,14 +6,18 @@ import (
 	"time"
 
 	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/mailer"
 	"greenlight.bcc/internal/validator"
 )
 
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Name           string `json:"name"`
+		Email          string `json:"email"`
+		Password       string `json:"password"`
+		Locale         string `json:"locale"`
+		CaptchaToken   string `json:"captcha_token"`
+		InvitationCode string `json:"invitation_code"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -22,9 +26,18 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !app.verifyCaptcha(w, r, input.CaptchaToken) {
+		return
+	}
+
+	if input.Locale == "" {
+		input.Locale = mailer.DefaultLocale
+	}
+
 	user := &data.User{
 		Name:      input.Name,
 		Email:     input.Email,
+		Locale:    input.Locale,
 		Activated: false,
 	}
 
@@ -35,17 +48,37 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 	v := validator.New()
 
-	if data.ValidateUser(v, user); !v.Valid() {
+	data.ValidateUser(v, user)
+	app.config.password.policy.Validate(v, input.Password)
+
+	if app.config.registration.inviteOnly {
+		data.ValidateInvitationCode(v, input.InvitationCode)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if app.passwordBreached(r, input.Password) {
+		v.AddError("password", "has appeared in a data breach, choose a different one")
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Users.Insert(user)
+	if app.config.registration.inviteOnly {
+		err = app.models.Users.InsertWithInvitation(user, input.InvitationCode)
+	} else {
+		err = app.models.Users.Insert(user)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
 			v.AddError("email", "a user with this email address already exists")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidInvitation):
+			v.AddError("invitation_code", "invalid, expired or already used invitation code")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -58,6 +91,14 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if app.config.orgs.defaultID != 0 {
+		err = app.models.Organizations.AddMember(app.config.orgs.defaultID, user.ID, data.RoleMember)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -71,12 +112,12 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 			"userID":          user.ID,
 		}
 
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", data)
-		if err != nil {
+		err := app.mailer.Send(user.Email, user.Locale, "user_welcome.tmpl", data)
+		if err != nil && !errors.Is(err, mailer.ErrSuppressed) {
 			app.logger.PrintError(err, nil)
 		}
 	})
-	err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -99,23 +140,26 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	user, err := app.models.Users.GetForToken(data.ScopeActivation, input.TokenPlaintext)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			v.AddError("token", "invalid or expired activation token")
-			app.failedValidationResponse(w, r, v.Errors)
-		default:
-			app.serverErrorResponse(w, r, err)
+	var user *data.User
+
+	// Activation doesn't depend on the rest of the user record, so a
+	// concurrent change such as a bounce being recorded is retried.
+	err = data.WithRetry(3, func() error {
+		var err error
+		user, err = app.models.Users.GetForToken(data.ScopeActivation, input.TokenPlaintext)
+		if err != nil {
+			return err
 		}
-		return
-	}
 
-	user.Activated = true
+		user.Activated = true
 
-	err = app.models.Users.Update(user)
+		return app.models.Users.Update(user)
+	})
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			app.failedValidationResponse(w, r, v.Errors)
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
 		default:
@@ -130,7 +174,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -3,8 +3,13 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	_ "errors"
+	"errors"
+	"fmt"
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/captcha"
 	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/mailer"
+	"greenlight.bcc/internal/pwned"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -97,9 +102,231 @@ func TestActivateUserHandler(t *testing.T) {
 	}
 
 	// Check the response body is as expected
-	expected := `{"user":{"id":1,"name":"test user","email":"test@example.com","activated":true}}`
+	expected := fmt.Sprintf(`{"user":{"id":%d,"created_at":%q,"name":"test user","email":"test@example.com","locale":"","activated":true}}
+`, user.ID, user.CreatedAt.Format(time.RFC3339Nano))
 	if rr.Body.String() != expected {
 		t.Errorf("unexpected response body: %s", rr.Body.String())
 	}
 
 }
+
+type stubVerifier struct {
+	err error
+}
+
+func (v stubVerifier) Verify(token, remoteIP string) error {
+	return v.err
+}
+
+func TestRegisterUserCaptcha(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{"verified", nil, http.StatusCreated, `"email":"test@example.com"`},
+		{"rejected", captcha.ErrFailed, http.StatusUnprocessableEntity, `"code":"captcha_failed"`},
+		{"provider error", errors.New("provider unavailable"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.captcha = stubVerifier{err: tt.err}
+
+			jsonPayload := `{"name": "test user", "email": "test@example.com", "password": "testpass123", "captcha_token": "token"}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(jsonPayload))
+
+			rr := httptest.NewRecorder()
+			app.registerUserHandler(rr, req)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRegisterUserPasswordPolicy(t *testing.T) {
+	// The SHA-1 of testpass123 is 1C58B followed by this suffix.
+	const suffix = "D92003BBAA0538E249FFF6EE19A270DEC5F"
+
+	tests := []struct {
+		name     string
+		policy   data.PasswordPolicy
+		rangeAPI http.HandlerFunc
+		wantCode int
+		wantBody string
+	}{
+		{"default policy", data.DefaultPasswordPolicy, nil, http.StatusCreated, ""},
+		{"too short", data.PasswordPolicy{MinLength: 12}, nil, http.StatusUnprocessableEntity, "must be at least 12 characters long"},
+		{"too long", data.PasswordPolicy{MaxLength: 10}, nil, http.StatusUnprocessableEntity, "must not be more than 10 bytes long"},
+		{"too few classes", data.PasswordPolicy{MinClasses: 3}, nil, http.StatusUnprocessableEntity, "must contain at least 3 of"},
+		{"breached", data.DefaultPasswordPolicy, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + suffix + ":42\r\n"))
+		}, http.StatusUnprocessableEntity, "has appeared in a data breach"},
+		{"padding only", data.DefaultPasswordPolicy, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(suffix + ":0\r\n"))
+		}, http.StatusCreated, ""},
+		{"not breached", data.DefaultPasswordPolicy, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n"))
+		}, http.StatusCreated, ""},
+		{"service down", data.DefaultPasswordPolicy, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, http.StatusCreated, ""},
+		{"service slow", data.DefaultPasswordPolicy, func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}, http.StatusCreated, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.password.policy = tt.policy
+
+			if tt.rangeAPI != nil {
+				ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, r.URL.Path, "/range/1C58B")
+					assert.Equal(t, r.Header.Get("Add-Padding"), "true")
+					tt.rangeAPI(w, r)
+				}))
+				defer ts.Close()
+				app.pwned = pwned.NewRangeChecker(ts.URL+"/range/", 50*time.Millisecond)
+			}
+
+			jsonPayload := `{"name": "test user", "email": "test@example.com", "password": "testpass123"}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(jsonPayload))
+
+			rr := httptest.NewRecorder()
+			app.registerUserHandler(rr, req)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRegisterUserWelcomeEmailLocale(t *testing.T) {
+	tests := []struct {
+		name        string
+		locale      string
+		wantSubject string
+	}{
+		{"default", "", "Subject: Welcome to Greenlight!"},
+		{"french", "fr", "Subject: Bienvenue sur Greenlight !"},
+		{"regional variant", "fr-CA", "Subject: Bienvenue sur Greenlight !"},
+		{"no variant", "de", "Subject: Welcome to Greenlight!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			app := newTestApplication(t)
+			app.mailer = mailer.New(mailer.NewLog(&buf), "test@example.com", time.Second, 0)
+
+			jsonPayload := `{"name": "test user", "email": "test@example.com", "password": "testpass123", "locale": "` + tt.locale + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(jsonPayload))
+
+			rr := httptest.NewRecorder()
+			app.registerUserHandler(rr, req)
+			app.wg.Wait()
+
+			assert.Equal(t, rr.Code, http.StatusCreated)
+			assert.StringContains(t, buf.String(), tt.wantSubject)
+		})
+	}
+}
+
+func TestRegisterUserInviteOnly(t *testing.T) {
+	tests := []struct {
+		name       string
+		inviteOnly bool
+		invited    bool
+		code       string
+		wantCode   int
+		wantBody   string
+	}{
+		{"open registration", false, false, "", http.StatusCreated, `"email":"test@example.com"`},
+		{"valid invitation", true, true, "", http.StatusCreated, `"email":"test@example.com"`},
+		{"missing invitation", true, false, "", http.StatusUnprocessableEntity, `"invitation_code":"must be provided"`},
+		{"used or expired invitation", true, false, "USEDINVITATIONCODE00000000", http.StatusUnprocessableEntity, `"invitation_code":"invalid, expired or already used invitation code"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.registration.inviteOnly = tt.inviteOnly
+
+			if tt.invited {
+				invitation, err := app.models.Invitations.New("test@example.com", 1, time.Hour)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.code = invitation.Plaintext
+			}
+
+			jsonPayload := `{"name": "test user", "email": "test@example.com", "password": "testpass123", "invitation_code": "` + tt.code + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(jsonPayload))
+
+			rr := httptest.NewRecorder()
+			app.registerUserHandler(rr, req)
+			app.wg.Wait()
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.StringContains(t, rr.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+type conflictingUsersModel struct {
+	MockedUsersModel
+	conflicts int
+	updates   int
+}
+
+func (m *conflictingUsersModel) GetForToken(tokenScope, tokenPlaintext string) (*data.User, error) {
+	return &data.User{ID: 1, Email: "test@example.com", Version: m.updates}, nil
+}
+
+func (m *conflictingUsersModel) Update(user *data.User) error {
+	m.updates++
+	if m.updates <= m.conflicts {
+		return data.ErrEditConflict
+	}
+	return nil
+}
+
+func TestActivateUserRetriesEditConflicts(t *testing.T) {
+	tests := []struct {
+		name        string
+		conflicts   int
+		wantCode    int
+		wantUpdates int
+	}{
+		{"no conflict", 0, http.StatusOK, 1},
+		{"one conflict", 1, http.StatusOK, 2},
+		{"persistent conflict", 5, http.StatusConflict, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &conflictingUsersModel{conflicts: tt.conflicts}
+
+			app := newTestApplication(t)
+			app.models.Users = users
+
+			req := httptest.NewRequest(http.MethodPut, "/v1/users/activated", strings.NewReader(`{"token": "ABCDEFGHIJKLMNOPQRSTUVWXYZ"}`))
+
+			rr := httptest.NewRecorder()
+			app.activateUserHandler(rr, req)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.Equal(t, users.updates, tt.wantUpdates)
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"greenlight.bcc/internal/msgpack"
+)
+
+const (
+	apiV1            = 1
+	apiV2            = 2
+	latestAPIVersion = apiV2
+
+	// vendorMediaType selects an API version through the Accept header,
+	// either as application/vnd.greenlight.v2+json or as
+	// application/vnd.greenlight+json; version=2.
+	vendorMediaType = "application/vnd.greenlight"
+)
+
+// responseTransformer rewrites the decoded JSON body a v1 handler produced
+// into the shape a later version promises.
+type responseTransformer func(status int, body map[string]any) map[string]any
+
+// apiVersionSpec describes how a version differs from v1. Every version is
+// served by the v1 handlers; routes are named by their v1 pattern.
+type apiVersionSpec struct {
+	// transformers are applied in order to the responses of a route. Those
+	// registered under "" apply to every route.
+	transformers map[string][]responseTransformer
+	// removed lists the v1 routes the version does not serve.
+	removed map[string]bool
+}
+
+var apiVersions = map[int]apiVersionSpec{
+	apiV1: {},
+	apiV2: {
+		transformers: map[string][]responseTransformer{
+			"": {v2ErrorFormat},
+		},
+		removed: map[string]bool{
+			// The WebSocket handshake cannot pass through the buffering
+			// that response transformers need.
+			"/v1/ws": true,
+		},
+	},
+}
+
+// v2ErrorFormat gives every error the same shape. In v1 the error member is
+// a message, a map of field errors or a JSON decoding error; in v2 it is
+// always an object with the status and a message, plus the field errors of
+// failed validations.
+func v2ErrorFormat(status int, body map[string]any) map[string]any {
+	e, ok := body["error"]
+	if !ok || status < 400 {
+		return body
+	}
+
+	out := map[string]any{"status": status}
+
+	switch e := e.(type) {
+	case string:
+		out["message"] = e
+	case map[string]any:
+		if _, ok := e["message"].(string); ok {
+			for key, value := range e {
+				out[key] = value
+			}
+		} else {
+			out["message"] = "one or more fields failed validation"
+			out["fields"] = e
+		}
+	default:
+		out["message"] = http.StatusText(status)
+	}
+
+	body["error"] = out
+	return body
+}
+
+// requestedAPIVersion works out which version a request is for. A /vN/ path
+// prefix takes precedence over the Accept header, and requests with neither
+// get v1. ok is false if the Accept header asks for a version that does not
+// exist.
+func requestedAPIVersion(r *http.Request) (version int, ok bool) {
+	if strings.HasPrefix(r.URL.Path, "/v2/") {
+		return apiV2, true
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil || !strings.HasPrefix(mt, vendorMediaType) || !strings.HasSuffix(mt, "+json") {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(mt, vendorMediaType), "+json")
+
+		var n int
+		switch {
+		case name == "" && params["version"] != "":
+			n, err = strconv.Atoi(params["version"])
+		case strings.HasPrefix(name, ".v"):
+			n, err = strconv.Atoi(strings.TrimPrefix(name, ".v"))
+		case name == "":
+			return latestAPIVersion, true
+		default:
+			continue
+		}
+
+		if _, exists := apiVersions[n]; err != nil || !exists {
+			return 0, false
+		}
+		return n, true
+	}
+
+	return apiV1, true
+}
+
+// negotiateVersion routes requests for later API versions to the v1
+// handlers and rewrites their responses with the version's transformers.
+func (app *application) negotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, ok := requestedAPIVersion(r)
+		if !ok {
+			app.notAcceptableResponse(w, r)
+			return
+		}
+
+		w.Header().Set("API-Version", strconv.Itoa(version))
+		w.Header().Add("Vary", "Accept")
+
+		if version == apiV1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		meta := app.contextGetRequestMeta(r)
+		if meta != nil {
+			meta.apiVersion = version
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/v2/") {
+			u := *r.URL
+			u.Path = "/v1/" + strings.TrimPrefix(u.Path, "/v2/")
+			u.RawPath = ""
+			r2 := r.Clone(r.Context())
+			r2.URL = &u
+			r = r2
+		}
+
+		bw := &bufferedResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, r)
+
+		if bw.passthrough {
+			return
+		}
+
+		body := bw.body.Bytes()
+
+		var route string
+		if meta != nil {
+			route = meta.route
+		}
+
+		var transformers []responseTransformer
+		transformers = append(transformers, apiVersions[version].transformers[""]...)
+		transformers = append(transformers, apiVersions[version].transformers[route]...)
+
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+
+		if len(transformers) > 0 && len(body) > 0 {
+			switch contentType := w.Header().Get("Content-Type"); {
+			case strings.HasPrefix(contentType, "application/json"):
+				var decoded map[string]any
+				if err := json.Unmarshal(body, &decoded); err == nil {
+					for _, transform := range transformers {
+						decoded = transform(bw.status, decoded)
+					}
+					if js, err := json.Marshal(decoded); err == nil {
+						body = append(js, '\n')
+					}
+				}
+			case contentType == mediaTypeMsgpack:
+				if decoded, err := msgpack.Unmarshal(body); err == nil {
+					if decoded, ok := decoded.(map[string]any); ok {
+						for _, transform := range transformers {
+							decoded = transform(bw.status, decoded)
+						}
+						if encoded, err := msgpack.Marshal(decoded); err == nil {
+							body = encoded
+						}
+					}
+				}
+			}
+		}
+
+		w.Header().Del("Content-Length")
+		w.WriteHeader(bw.status)
+		w.Write(body)
+	})
+}
+
+// servedInVersion answers 404 for routes the requested API version has
+// removed.
+func (app *application) servedInVersion(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if meta := app.contextGetRequestMeta(r); meta != nil && apiVersions[meta.apiVersion].removed[path] {
+			app.notFoundResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// transformable reports whether responses of the content type can be
+// rewritten by response transformers.
+func transformable(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json") || contentType == mediaTypeMsgpack
+}
+
+// bufferedResponseWriter holds back the response so that it can be
+// rewritten once the handler has finished. Responses the transformers
+// cannot rewrite, such as NDJSON streams and files, are written straight
+// through instead, so that they are not held in memory and can be flushed.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	passthrough bool
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+
+	w.status = status
+	if !transformable(w.Header().Get("Content-Type")) {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+// Flush flushes responses which are written straight through. Buffered
+// ones are sent when the handler has finished.
+func (w *bufferedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && w.passthrough {
+		f.Flush()
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestAPIVersions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		urlPath     string
+		accept      string
+		body        string
+		wantCode    int
+		wantVersion string
+		wantBody    string
+	}{
+		{"v1 by default", http.MethodGet, "/v1/healthcheck", "", "", http.StatusOK, "1", `"status":"available"`},
+		{"v2 by path", http.MethodGet, "/v2/healthcheck", "", "", http.StatusOK, "2", `"status":"available"`},
+		{"v2 by Accept", http.MethodGet, "/v1/healthcheck", "application/vnd.greenlight.v2+json", "", http.StatusOK, "2", `"status":"available"`},
+		{"v2 by Accept parameter", http.MethodGet, "/v1/healthcheck", "text/html, application/vnd.greenlight+json; version=2", "", http.StatusOK, "2", `"status":"available"`},
+		{"Latest by Accept", http.MethodGet, "/v1/healthcheck", "application/vnd.greenlight+json", "", http.StatusOK, "2", `"status":"available"`},
+		{"Unknown version", http.MethodGet, "/v1/healthcheck", "application/vnd.greenlight.v9+json", "", http.StatusNotAcceptable, "", `"error":"the requested API version is not supported"`},
+		{"Unknown path version", http.MethodGet, "/v9/healthcheck", "", "", http.StatusNotFound, "1", `"error":"the requested resource could not be found"`},
+		{"v1 error", http.MethodGet, "/v1/movies", "", "", http.StatusUnauthorized, "1", `{"error":"you must be authenticated to access this resource"}`},
+		{"v2 error", http.MethodGet, "/v2/movies", "", "", http.StatusUnauthorized, "2", `{"error":{"message":"you must be authenticated to access this resource","status":401}}`},
+		{"v2 bad request", http.MethodPost, "/v2/users", "", `{"name": 1}`, http.StatusBadRequest, "2", `"field":"name"`},
+		{"v2 validation error", http.MethodPost, "/v2/users", "", `{"name": "", "email": "alice@example.com", "password": "pa55word1234"}`, http.StatusUnprocessableEntity, "2", `"fields":{"name":"must be provided"},"message":"one or more fields failed validation","status":422`},
+		{"v2 removed route", http.MethodGet, "/v2/ws", "", "", http.StatusNotFound, "2", `"status":404`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			var header http.Header
+			var body string
+
+			switch tt.method {
+			case http.MethodPost:
+				code, header, body = ts.postForm(t, tt.urlPath, []byte(tt.body))
+			default:
+				req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.accept != "" {
+					req.Header.Set("Accept", tt.accept)
+				}
+
+				rs, err := ts.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer rs.Body.Close()
+
+				b, err := io.ReadAll(rs.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				code, header, body = rs.StatusCode, rs.Header, string(b)
+			}
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("API-Version"), tt.wantVersion)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestNegotiateVersionStreams(t *testing.T) {
+	app := newTestApplication(t)
+
+	rr := httptest.NewRecorder()
+	handler := app.negotiateVersion(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaTypeNDJSON)
+		w.Write([]byte("{\"id\":1}\n"))
+		w.(http.Flusher).Flush()
+
+		// The first line has reached the client before the stream ends.
+		assert.Equal(t, rr.Body.String(), "{\"id\":1}\n")
+		assert.Equal(t, rr.Flushed, true)
+
+		w.Write([]byte("{\"id\":2}\n"))
+	}))
+
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v2/movies", nil))
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("API-Version"), "2")
+	assert.Equal(t, rr.Body.String(), "{\"id\":1}\n{\"id\":2}\n")
+}
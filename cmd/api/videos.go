@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/oembed"
+	"greenlight.bcc/internal/validator"
+)
+
+func (app *application) listMovieVideosHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, ok := app.visibleMovie(w, r, id)
+	if !ok {
+		return
+	}
+
+	videos, err := app.models.MovieVideos.GetAllForMovie(movie.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"videos": videos}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createMovieVideoHandler adds a YouTube or Vimeo video to the movie. When
+// fetching video metadata is enabled, videos the provider does not know, or
+// does not allow to be embedded, are rejected, and the others get their
+// title and thumbnail. The provider being unreachable does not stop the
+// video being added without them.
+func (app *application) createMovieVideoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, ok := app.visibleMovie(w, r, id)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	video := &data.MovieVideo{
+		MovieID: movie.ID,
+		Type:    input.Type,
+	}
+	video.SetURL(input.URL)
+
+	v := validator.New()
+
+	if data.ValidateMovieVideo(v, video); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	metadata, err := app.oembed.Fetch(r.Context(), video.Provider, video.URL)
+	switch {
+	case err == nil:
+		video.Title = metadata.Title
+		video.ThumbnailURL = metadata.ThumbnailURL
+	case errors.Is(err, oembed.ErrNotFound):
+		v.AddError("url", "must be an existing video which can be embedded")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	case errors.Is(err, oembed.ErrNotConfigured):
+		// The video is added as it is.
+	default:
+		app.logger.PrintError(err, map[string]string{"task": "fetch video metadata", "url": video.URL})
+	}
+
+	err = app.models.MovieVideos.Insert(video)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateVideo):
+			v.AddError("url", "has already been added to this movie")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"video": video}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieVideoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	videoID, err := app.readVideoIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, ok := app.visibleMovie(w, r, id)
+	if !ok {
+		return
+	}
+
+	err = app.models.MovieVideos.Delete(movie.ID, videoID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "video successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/oembed"
+)
+
+func TestMovieVideos(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("url") != "https://www.youtube.com/watch?v=4sj1MT05lAA" {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"title":         "The Lion King (1994) Trailer",
+			"thumbnail_url": "https://i.ytimg.com/vi/4sj1MT05lAA/hqdefault.jpg",
+		})
+	}))
+	defer provider.Close()
+
+	app, token := newMemoryTestApplication(t)
+	app.oembed = oembed.New(map[string]string{"youtube": provider.URL}, time.Second)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, body := ts.do(t, http.MethodPost, "/v1/movies", token, `{"title": "The Lion King", "year": 1994, "runtime": "88 mins"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	videosPath := fmt.Sprintf("/v1/movies/%d/videos", int64(body["movie"].(map[string]any)["id"].(float64)))
+
+	code, body = ts.do(t, http.MethodPost, videosPath, token, `{"type": "trailer", "url": "https://youtu.be/4sj1MT05lAA"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	video := body["video"].(map[string]any)
+	assert.Equal(t, video["url"].(string), "https://www.youtube.com/watch?v=4sj1MT05lAA")
+	assert.Equal(t, video["provider"].(string), "youtube")
+	assert.Equal(t, video["title"].(string), "The Lion King (1994) Trailer")
+	assert.Equal(t, video["thumbnail_url"].(string), "https://i.ytimg.com/vi/4sj1MT05lAA/hqdefault.jpg")
+
+	code, _ = ts.do(t, http.MethodPost, videosPath, token, `{"type": "teaser", "url": "https://www.youtube.com/embed/4sj1MT05lAA"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	// Videos the provider does not know are rejected.
+	code, _ = ts.do(t, http.MethodPost, videosPath, token, `{"type": "clip", "url": "https://www.youtube.com/watch?v=aaaaaaaaaaa"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _ = ts.do(t, http.MethodPost, videosPath, token, `{"type": "clip", "url": "https://example.com/watch?v=4sj1MT05lAA"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, _ = ts.do(t, http.MethodPost, videosPath, token, `{"type": "review", "url": "https://vimeo.com/76979871"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	// Providers without an endpoint fail open, and the video is added
+	// without metadata.
+	code, body = ts.do(t, http.MethodPost, videosPath, token, `{"type": "clip", "url": "https://player.vimeo.com/video/76979871"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	video = body["video"].(map[string]any)
+	assert.Equal(t, video["url"].(string), "https://vimeo.com/76979871")
+	_, ok := video["title"]
+	assert.Equal(t, ok, false)
+
+	code, body = ts.do(t, http.MethodGet, videosPath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["videos"].([]any)), 2)
+
+	deletePath := fmt.Sprintf("%s/%d", videosPath, int64(video["id"].(float64)))
+
+	code, _ = ts.do(t, http.MethodDelete, deletePath, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodDelete, deletePath, token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestMovieVideoSetURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		provider   string
+		providerID string
+	}{
+		{"https://www.youtube.com/watch?v=4sj1MT05lAA&t=10s", data.VideoProviderYouTube, "4sj1MT05lAA"},
+		{"https://m.youtube.com/watch?v=4sj1MT05lAA", data.VideoProviderYouTube, "4sj1MT05lAA"},
+		{"https://youtu.be/4sj1MT05lAA", data.VideoProviderYouTube, "4sj1MT05lAA"},
+		{"https://www.youtube.com/shorts/4sj1MT05lAA", data.VideoProviderYouTube, "4sj1MT05lAA"},
+		{"https://vimeo.com/76979871", data.VideoProviderVimeo, "76979871"},
+		{"https://player.vimeo.com/video/76979871", data.VideoProviderVimeo, "76979871"},
+		{"https://www.youtube.com/watch?v=short", "", ""},
+		{"https://www.youtube.com/channel/UC4sj1MT05lAA", "", ""},
+		{"https://vimeo.com/channels/staffpicks", "", ""},
+		{"ftp://youtu.be/4sj1MT05lAA", "", ""},
+		{"not a url", "", ""},
+	}
+
+	for _, tt := range tests {
+		var video data.MovieVideo
+		video.SetURL(tt.url)
+		assert.Equal(t, video.Provider, tt.provider)
+		assert.Equal(t, video.ProviderID, tt.providerID)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Thresholds the watchdog checks samples against.
+const (
+	watchGoroutines = "goroutines"
+	watchHeap       = "heap"
+	watchGCPause    = "gc_pause"
+)
+
+// runtimeSample is a reading of the runtime's health.
+type runtimeSample struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+	HeapBytes  uint64    `json:"heap_bytes"`
+	// MaxGCPauseMs is the longest garbage collection pause since the
+	// previous sample.
+	MaxGCPauseMs float64 `json:"max_gc_pause_ms"`
+	NumGC        uint32  `json:"num_gc"`
+}
+
+// watchdog keeps the latest runtime sample and which thresholds it is over.
+// A zero threshold is not checked.
+type watchdog struct {
+	maxGoroutines int
+	maxHeapBytes  uint64
+	maxGCPause    time.Duration
+
+	mu       sync.Mutex
+	last     runtimeSample
+	exceeded map[string]bool
+	// breaches counts the times each threshold has been crossed.
+	breaches map[string]int64
+}
+
+func newWatchdog(maxGoroutines int, maxHeapBytes uint64, maxGCPause time.Duration) *watchdog {
+	return &watchdog{
+		maxGoroutines: maxGoroutines,
+		maxHeapBytes:  maxHeapBytes,
+		maxGCPause:    maxGCPause,
+		exceeded:      make(map[string]bool),
+		breaches:      make(map[string]int64),
+	}
+}
+
+// sample reads the runtime's statistics. ReadMemStats stops the world
+// briefly, which is why the interval should not be too short.
+func (wd *watchdog) sample(now time.Time) runtimeSample {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	wd.mu.Lock()
+	prevGC := wd.last.NumGC
+	wd.mu.Unlock()
+
+	// PauseNs is a circular buffer of the most recent 256 pauses.
+	var maxPause uint64
+	for n := stats.NumGC; n > prevGC && stats.NumGC-n < uint32(len(stats.PauseNs)); n-- {
+		if pause := stats.PauseNs[(n+255)%256]; pause > maxPause {
+			maxPause = pause
+		}
+	}
+
+	return runtimeSample{
+		Time:         now,
+		Goroutines:   runtime.NumGoroutine(),
+		HeapBytes:    stats.HeapAlloc,
+		MaxGCPauseMs: float64(maxPause) / float64(time.Millisecond),
+		NumGC:        stats.NumGC,
+	}
+}
+
+// record stores the sample and returns the thresholds it newly crossed.
+// A threshold is only reported again once a sample has come back under it.
+func (wd *watchdog) record(s runtimeSample) []string {
+	over := map[string]bool{
+		watchGoroutines: wd.maxGoroutines > 0 && s.Goroutines > wd.maxGoroutines,
+		watchHeap:       wd.maxHeapBytes > 0 && s.HeapBytes > wd.maxHeapBytes,
+		watchGCPause:    wd.maxGCPause > 0 && s.MaxGCPauseMs > float64(wd.maxGCPause)/float64(time.Millisecond),
+	}
+
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+
+	wd.last = s
+
+	var crossed []string
+	for _, name := range []string{watchGoroutines, watchHeap, watchGCPause} {
+		if over[name] && !wd.exceeded[name] {
+			crossed = append(crossed, name)
+			wd.breaches[name]++
+		}
+		wd.exceeded[name] = over[name]
+	}
+
+	return crossed
+}
+
+func (wd *watchdog) snapshot() any {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+
+	exceeded := make(map[string]bool, len(wd.exceeded))
+	for name, over := range wd.exceeded {
+		exceeded[name] = over
+	}
+	breaches := make(map[string]int64, len(wd.breaches))
+	for name, count := range wd.breaches {
+		breaches[name] = count
+	}
+
+	return map[string]any{
+		"last":     wd.last,
+		"exceeded": exceeded,
+		"breaches": breaches,
+		"thresholds": map[string]any{
+			"goroutines":      wd.maxGoroutines,
+			"heap_bytes":      wd.maxHeapBytes,
+			"max_gc_pause_ms": float64(wd.maxGCPause) / float64(time.Millisecond),
+		},
+	}
+}
+
+func (app *application) watchRuntimePeriodically() {
+	ticker := time.NewTicker(app.config.watchdog.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.checkRuntime()
+	}
+}
+
+// checkRuntime samples the runtime and logs the thresholds it crossed. If
+// heap dumps are enabled, crossing the heap threshold saves a heap profile
+// to storage alongside the ones captured by admins.
+func (app *application) checkRuntime() {
+	s := app.watchdog.sample(time.Now())
+
+	for _, name := range app.watchdog.record(s) {
+		app.logger.PrintInfo("runtime threshold exceeded", map[string]string{
+			"threshold":       name,
+			"goroutines":      strconv.Itoa(s.Goroutines),
+			"heap_bytes":      strconv.FormatUint(s.HeapBytes, 10),
+			"max_gc_pause_ms": strconv.FormatFloat(s.MaxGCPauseMs, 'f', 3, 64),
+		})
+
+		if name == watchHeap && app.config.watchdog.heapDump {
+			app.dumpHeap(s.Time)
+		}
+	}
+}
+
+func (app *application) dumpHeap(t time.Time) {
+	name, err := newProfileName(profileHeap, t)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = rpprof.WriteHeapProfile(&buf)
+	if err == nil {
+		err = app.storeProfile(name, &buf)
+	}
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"profile": name})
+		return
+	}
+
+	app.logger.PrintInfo("heap dump saved", map[string]string{
+		"name":     name,
+		"download": "/v1/admin/debug/profiles/" + name,
+	})
+}
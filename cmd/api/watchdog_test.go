@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/jsonlog"
+)
+
+func TestWatchdogRecord(t *testing.T) {
+	wd := newWatchdog(10, 1000, 5*time.Millisecond)
+
+	crossed := wd.record(runtimeSample{Goroutines: 5, HeapBytes: 500, MaxGCPauseMs: 1})
+	assert.Equal(t, len(crossed), 0)
+
+	crossed = wd.record(runtimeSample{Goroutines: 20, HeapBytes: 500, MaxGCPauseMs: 10})
+	assert.Equal(t, len(crossed), 2)
+	assert.Equal(t, crossed[0], watchGoroutines)
+	assert.Equal(t, crossed[1], watchGCPause)
+
+	// Staying over a threshold is not reported again until it recovers.
+	crossed = wd.record(runtimeSample{Goroutines: 20, HeapBytes: 500})
+	assert.Equal(t, len(crossed), 0)
+	wd.record(runtimeSample{Goroutines: 5, HeapBytes: 500})
+	crossed = wd.record(runtimeSample{Goroutines: 20, HeapBytes: 500})
+	assert.Equal(t, len(crossed), 1)
+
+	assert.Equal(t, wd.breaches[watchGoroutines], int64(2))
+	assert.Equal(t, wd.breaches[watchGCPause], int64(1))
+	assert.Equal(t, wd.breaches[watchHeap], int64(0))
+}
+
+func TestWatchdogHeapDump(t *testing.T) {
+	var buf bytes.Buffer
+
+	app := newTestApplication(t)
+	app.logger = jsonlog.New(&buf, jsonlog.LevelInfo)
+	app.config.watchdog.heapDump = true
+	app.watchdog = newWatchdog(0, 1, 0)
+
+	app.checkRuntime()
+
+	assert.StringContains(t, buf.String(), `"message":"runtime threshold exceeded"`)
+	assert.StringContains(t, buf.String(), `"threshold":"heap"`)
+	assert.StringContains(t, buf.String(), `"message":"heap dump saved"`)
+
+	name := regexp.MustCompile(`"name":"(heap-[^"]+\.pprof)"`).FindStringSubmatch(buf.String())
+	if name == nil {
+		t.Fatalf("no heap dump name logged: %s", buf.String())
+	}
+
+	f, err := app.storage.Get(context.Background(), debugProfilesPrefix+name[1])
+	assert.NilError(t, err)
+	f.Close()
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/validator"
+	"greenlight.bcc/internal/webhook"
+)
+
+// typeWebhookTest is the type of the events sent by the test-delivery
+// endpoint and used in the verification example.
+const typeWebhookTest = "webhook.test"
+
+// webhookEventTypes are the events webhooks may subscribe to.
+var webhookEventTypes = []string{
+	events.TypeNotification,
+	events.TypeMovieCreated,
+	events.TypeMovieUpdated,
+	events.TypeMovieDeleted,
+	events.TypeMoviePublished,
+	events.TypeCommentReply,
+	events.TypeListUpdated,
+}
+
+// webhookPayload is the body of a webhook request.
+type webhookPayload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func newWebhookPayload(eventType string, eventData any) (*webhookPayload, []byte, error) {
+	id, err := webhook.NewDeliveryID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload := &webhookPayload{ID: id, Type: eventType, CreatedAt: time.Now().UTC().Truncate(time.Second), Data: eventData}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return payload, body, nil
+}
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	hook := &data.Webhook{
+		UserID: user.ID,
+		OrgID:  user.OrgID,
+		URL:    input.URL,
+		Events: input.Events,
+	}
+
+	v := validator.New()
+	data.ValidateWebhook(v, hook)
+	for _, eventType := range hook.Events {
+		v.Check(validator.PermittedValue(eventType, webhookEventTypes...), "events", "must only contain known event types")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Plain http is only accepted in development, as are the local
+	// addresses the client would otherwise refuse.
+	v.Check(app.config.dev || strings.HasPrefix(hook.URL, "https://"), "url", "must be an https URL")
+
+	err = app.webhooks.CheckURL(r.Context(), hook.URL)
+	switch {
+	case errors.Is(err, webhook.ErrForbiddenAddress):
+		v.AddError("url", "must not point to a loopback, private or link-local address")
+	case err != nil:
+		v.AddError("url", "must have a host which resolves")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkQuota(w, r, quotaWebhooks) {
+		return
+	}
+
+	hook.Secret, err = webhook.NewSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Webhooks.Insert(hook)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", "/v1/webhooks/"+strconv.FormatInt(hook.ID, 10))
+
+	// The secret is only ever shown here.
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"webhook": hook, "secret": hook.Secret}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	hooks, err := app.models.Webhooks.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"webhooks": hooks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Webhooks.Delete(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "webhook successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ownWebhook fetches the webhook named in the URL if it belongs to the
+// user, otherwise responding with 404 Not Found.
+func (app *application) ownWebhook(w http.ResponseWriter, r *http.Request) (*data.Webhook, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	hook, err := app.models.Webhooks.Get(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return hook, true
+}
+
+const verifyExampleGo = `func verify(secret string, r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var timestamp, signature string
+	for _, part := range strings.Split(r.Header.Get("X-Signature"), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, errors.New("invalid signature")
+	}
+
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(t, 0)).Abs() > 5*time.Minute {
+		return nil, errors.New("signature too old")
+	}
+
+	return body, nil
+}
+`
+
+const verifyExamplePython = `import hashlib, hmac, time
+
+def verify(secret: str, header: str, body: bytes) -> bool:
+    parts = dict(part.split("=", 1) for part in header.split(","))
+    timestamp, signature = parts.get("t", ""), parts.get("v1", "")
+    expected = hmac.new(secret.encode(), timestamp.encode() + b"." + body, hashlib.sha256).hexdigest()
+    if not hmac.compare_digest(signature, expected):
+        return False
+    return abs(time.time() - int(timestamp)) <= 300
+`
+
+// showWebhookVerifyExampleHandler explains how to verify the webhook's
+// signatures, with code samples and a request signed with its secret to
+// check an implementation against.
+func (app *application) showWebhookVerifyExampleHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := app.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	_, body, err := newWebhookPayload(typeWebhookTest, envelope{"webhook_id": hook.ID})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	example := envelope{
+		"header":            webhook.SignatureHeader,
+		"format":            "t=<unix time>,v1=<hex HMAC-SHA256 of the unix time, a full stop and the body, keyed with the secret>",
+		"tolerance_seconds": int(webhook.DefaultTolerance.Seconds()),
+		"sample": envelope{
+			"body":      string(body),
+			"signature": webhook.Sign(hook.Secret, body, time.Now()),
+		},
+		"code": envelope{
+			"go":     verifyExampleGo,
+			"python": verifyExamplePython,
+		},
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"example": example}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// testWebhookHandler sends the webhook a test event straight away and
+// reports whether its consumer accepted it. Why a delivery failed is only
+// logged, so the endpoint cannot be used to probe the network.
+func (app *application) testWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := app.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	payload, body, err := newWebhookPayload(typeWebhookTest, envelope{"webhook_id": hook.ID})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.webhooks.Deliver(r.Context(), hook.URL, hook.Secret, payload.ID, payload.Type, body)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"webhook_id": strconv.FormatInt(hook.ID, 10),
+			"delivery":   payload.ID,
+			"event":      payload.Type,
+		})
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"delivery": envelope{"id": payload.ID, "delivered": err == nil}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deliverWebhooks sends events from the bus to the webhooks subscribed to
+// them, as long as the event is visible to the webhook's owner. Failed
+// deliveries are logged and not retried.
+func (app *application) deliverWebhooks(sub *events.Subscription) {
+	for event := range sub.C {
+		hooks, err := app.models.Webhooks.GetAllForEvent(event.Type)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"event": event.Type})
+			continue
+		}
+
+		for _, hook := range hooks {
+			if event.UserID != 0 && event.UserID != hook.UserID {
+				continue
+			}
+			if event.OrgID != 0 && event.OrgID != hook.OrgID {
+				continue
+			}
+
+			payload, body, err := newWebhookPayload(event.Type, event.Data)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"event": event.Type})
+				continue
+			}
+
+			hook := hook
+			app.background(func() {
+				_, err := app.webhooks.Deliver(context.Background(), hook.URL, hook.Secret, payload.ID, payload.Type, body)
+				if err != nil {
+					app.logger.PrintError(err, map[string]string{
+						"webhook_id": strconv.FormatInt(hook.ID, 10),
+						"delivery":   payload.ID,
+						"event":      payload.Type,
+					})
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/webhook"
+)
+
+// webhookConsumer records the webhook requests it receives.
+type webhookConsumer struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (c *webhookConsumer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, r)
+	c.bodies = append(c.bodies, body)
+}
+
+func (c *webhookConsumer) received() ([]*http.Request, [][]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, c.bodies
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	consumer := &webhookConsumer{}
+	cs := httptest.NewServer(consumer)
+	defer cs.Close()
+
+	app, token := newMemoryTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _ := ts.do(t, http.MethodPost, "/v1/webhooks", token, `{"url": "ftp://example.com", "events": ["movie.created"]}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	// Outside development only https URLs of public hosts are accepted.
+	code, _ = ts.do(t, http.MethodPost, "/v1/webhooks", token, `{"url": "`+cs.URL+`", "events": ["movie.created"]}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	app.webhooks = webhook.NewClient(time.Second, false)
+	for _, url := range []string{"https://127.0.0.1/hook", "https://10.0.0.8/hook", "https://169.254.169.254/latest", "https://[::1]/hook", "https://0.0.0.0/hook"} {
+		code, _ = ts.do(t, http.MethodPost, "/v1/webhooks", token, `{"url": "`+url+`", "events": ["movie.created"]}`)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// Addresses are checked again when connecting, whatever the host
+	// resolved to before.
+	_, err := app.webhooks.Deliver(context.Background(), cs.URL, "secret", "1", typeWebhookTest, []byte("{}"))
+	assert.Equal(t, errors.Is(err, webhook.ErrForbiddenAddress), true)
+
+	app.webhooks = webhook.NewClient(time.Second, true)
+	app.config.dev = true
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/webhooks", token, `{"url": "`+cs.URL+`", "events": ["movie.exploded"]}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, body := ts.do(t, http.MethodPost, "/v1/webhooks", token, `{"url": "`+cs.URL+`", "events": ["movie.created", "notification"]}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	secret := body["secret"].(string)
+	id := strconv.FormatInt(int64(body["webhook"].(map[string]any)["id"].(float64)), 10)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/webhooks", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(body["webhooks"].([]any)), 1)
+	_, shown := body["webhooks"].([]any)[0].(map[string]any)["secret"]
+	assert.Equal(t, shown, false)
+
+	code, body = ts.do(t, http.MethodGet, "/v1/webhooks/"+id+"/verify-example", token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	sample := body["example"].(map[string]any)["sample"].(map[string]any)
+	err = webhook.Verify(secret, sample["signature"].(string), []byte(sample["body"].(string)), time.Now(), webhook.DefaultTolerance)
+	assert.NilError(t, err)
+
+	code, body = ts.do(t, http.MethodPost, "/v1/webhooks/"+id+"/test", token, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body["delivery"].(map[string]any)["delivered"].(bool), true)
+
+	requests, bodies := consumer.received()
+	assert.Equal(t, len(requests), 1)
+	assert.Equal(t, requests[0].Header.Get(webhook.EventHeader), typeWebhookTest)
+
+	signature := requests[0].Header.Get(webhook.SignatureHeader)
+	assert.NilError(t, webhook.Verify(secret, signature, bodies[0], time.Now(), webhook.DefaultTolerance))
+	assert.Equal(t, errors.Is(webhook.Verify(secret, signature, append(bodies[0], ' '), time.Now(), webhook.DefaultTolerance), webhook.ErrInvalidSignature), true)
+	assert.Equal(t, errors.Is(webhook.Verify(secret, signature, bodies[0], time.Now().Add(time.Hour), webhook.DefaultTolerance), webhook.ErrExpiredSignature), true)
+
+	sub := app.events.SubscribeAll(8)
+	app.background(func() {
+		app.deliverWebhooks(sub)
+	})
+
+	app.events.Publish(events.Event{Type: events.TypeMovieCreated, Data: envelope{"id": 1}})
+	app.events.Publish(events.Event{Type: events.TypeMovieUpdated, Data: envelope{"id": 1}})
+	app.events.Publish(events.Event{Type: events.TypeNotification, UserID: 999, Data: envelope{"id": 2}})
+	sub.Close()
+	app.wg.Wait()
+
+	requests, _ = consumer.received()
+	assert.Equal(t, len(requests), 2)
+	assert.Equal(t, requests[1].Header.Get(webhook.EventHeader), events.TypeMovieCreated)
+
+	code, _ = ts.do(t, http.MethodDelete, "/v1/webhooks/"+id, token, "")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _ = ts.do(t, http.MethodPost, "/v1/webhooks/"+id+"/test", token, "")
+	assert.Equal(t, code, http.StatusNotFound)
+}
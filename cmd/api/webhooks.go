@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"greenlight.bcc/internal/mailer"
+)
+
+func (app *application) emailEventsHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var source mailer.EventSource
+	switch {
+	case r.Header.Get(mailer.SNSMessageTypeHeader) != "":
+		source = app.emailEvents.ses
+	case r.Header.Get(mailer.SendGridSignatureHeader) != "":
+		source = app.emailEvents.sendgrid
+	}
+
+	if source == nil {
+		app.invalidWebhookSignatureResponse(w, r)
+		return
+	}
+
+	events, err := source.Parse(r.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, mailer.ErrInvalidSignature):
+			app.invalidWebhookSignatureResponse(w, r)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, event := range events {
+		err = app.models.Users.MarkEmailUndeliverable(event.Email)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.logger.PrintInfo("email marked undeliverable", map[string]string{
+			"email": maskEmail(event.Email),
+			"kind":  event.Kind,
+		})
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"processed": len(events)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// maskEmail keeps the first character of the local part and the domain of
+// an address, which is enough to tell bounces apart in the logs without
+// recording who they were for.
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "***"
+	}
+	return string([]rune(local)[0]) + "***@" + domain
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/mailer"
+)
+
+func TestEmailEventsHandler(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(timestamp, body string) string {
+		digest := sha256.Sum256([]byte(timestamp + body))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+
+	app := newTestApplication(t)
+	app.emailEvents.sendgrid, err = mailer.NewSendGridEvents(base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := `[{"email":"a@example.com","event":"bounce","type":"bounce"},{"email":"b@example.com","event":"bounce","type":"blocked"},{"email":"c@example.com","event":"spamreport"},{"email":"d@example.com","event":"delivered"}]`
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		snsType   string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Valid signature",
+			body:      events,
+			signature: sign("1700000000", events),
+			wantCode:  http.StatusOK,
+			wantBody:  `"processed":2`,
+		},
+		{
+			name:      "Tampered body",
+			body:      strings.Replace(events, "a@example.com", "z@example.com", 1),
+			signature: sign("1700000000", events),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:     "Unsigned request",
+			body:     events,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "SES not configured",
+			body:     `{}`,
+			snsType:  "Notification",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/webhooks/email-events", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				r.Header.Set(mailer.SendGridSignatureHeader, tt.signature)
+				r.Header.Set(mailer.SendGridTimestampHeader, "1700000000")
+			}
+			if tt.snsType != "" {
+				r.Header.Set(mailer.SNSMessageTypeHeader, tt.snsType)
+			}
+
+			w := httptest.NewRecorder()
+			app.emailEventsHandler(w, r)
+
+			assert.Equal(t, w.Code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Database error", func(t *testing.T) {
+		app := newBrokenTestApplication(t)
+		app.emailEvents.sendgrid, err = mailer.NewSendGridEvents(base64.StdEncoding.EncodeToString(der))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := `[{"email":"c@example.com","event":"spamreport"}]`
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/webhooks/email-events", strings.NewReader(body))
+		r.Header.Set(mailer.SendGridSignatureHeader, sign("1700000000", body))
+		r.Header.Set(mailer.SendGridTimestampHeader, "1700000000")
+
+		w := httptest.NewRecorder()
+		app.emailEventsHandler(w, r)
+
+		assert.Equal(t, w.Code, http.StatusInternalServerError)
+	})
+}
+
+func TestSuppressedRecipient(t *testing.T) {
+	app := newTestApplication(t)
+	insertUser(t, app, "bounced@example.com")
+	insertUser(t, app, "test@example.com")
+
+	err := app.models.Users.MarkEmailUndeliverable("bounced@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := mailer.New(mailer.NewLog(&strings.Builder{}), "test@example.com", 0, 0).WithSuppressionList(app.models.Users)
+
+	err = m.Send("bounced@example.com", "en", "user_welcome.tmpl", nil)
+	if !errors.Is(err, mailer.ErrSuppressed) {
+		t.Errorf("got %v; want %v", err, mailer.ErrSuppressed)
+	}
+
+	err = m.Send("test@example.com", "en", "user_welcome.tmpl", map[string]any{"userID": 1, "activationToken": "token"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"alice@example.com", "a***@example.com"},
+		{"é@example.com", "é***@example.com"},
+		{"@example.com", "***"},
+		{"not-an-address", "***"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, maskEmail(tt.email), tt.want)
+	}
+}
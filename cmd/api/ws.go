@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+	"greenlight.bcc/internal/websocket"
+)
+
+const (
+	wsPongWait     = 60 * time.Second
+	wsPingInterval = wsPongWait * 9 / 10
+)
+
+func (app *application) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sub := app.events.Subscribe(user.ID, user.OrgID, 16)
+	defer sub.Close()
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.OnPong = func() {
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	}
+
+	// Clients are not expected to send anything, but reading is needed to
+	// process pongs and close frames.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				conn.Close(websocket.CloseGoingAway, "server shutting down")
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ticker.C:
+			if err := conn.Ping(); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-done:
+			conn.Close(websocket.CloseNormal, "")
+			return
+		}
+	}
+}
+
+// publishMovieEvent announces a change to a movie. Changes to movies which
+// are not published are not announced since subscribers may not be allowed to
+// see them; deletions only carry the movie ID.
+func (app *application) publishMovieEvent(eventType string, movie *data.Movie) {
+	// Every change to a movie is published here, so this is also where
+	// cached copies of it are evicted.
+	app.invalidateMovie(movie.OrgID, movie.ID)
+
+	switch {
+	case eventType == events.TypeMovieDeleted:
+		app.events.Publish(events.Event{Type: eventType, OrgID: movie.OrgID, Data: envelope{"id": movie.ID}})
+	case movie.IsPublished():
+		app.events.Publish(events.Event{Type: eventType, OrgID: movie.OrgID, Data: movie})
+		if eventType == events.TypeMovieUpdated {
+			app.notifyListOwners(movie)
+		}
+	}
+}
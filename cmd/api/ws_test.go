@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+	"greenlight.bcc/internal/data"
+	"greenlight.bcc/internal/events"
+)
+
+// readServerFrame reads a single unmasked frame sent by the server.
+func readServerFrame(t *testing.T, br *bufio.Reader) (int, []byte) {
+	t.Helper()
+
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	return int(head[0] & 0x0F), payload
+}
+
+func TestWebsocketHandler(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = app.contextSetUser(r, &data.User{ID: 1, Activated: true, OrgID: 1})
+		app.websocketHandler(w, r)
+	}))
+	defer ts.Close()
+
+	t.Run("Plain request is rejected", func(t *testing.T) {
+		code, _, _ := (&testServer{ts}).get(t, "/v1/ws")
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	handshake := "GET /v1/ws HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+
+	rs, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, rs.StatusCode, http.StatusSwitchingProtocols)
+	assert.Equal(t, rs.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+
+	// Wait for the handler to subscribe before publishing.
+	time.Sleep(50 * time.Millisecond)
+
+	app.events.Publish(events.Event{Type: events.TypeNotification, UserID: 2, Data: "for someone else"})
+	app.events.Publish(events.Event{Type: events.TypeMovieCreated, OrgID: 2, Data: "for another organization"})
+	app.events.Publish(events.Event{Type: events.TypeNotification, UserID: 1, Data: "hello"})
+
+	opcode, payload := readServerFrame(t, br)
+	assert.Equal(t, opcode, 0x1)
+	assert.Equal(t, string(payload), `{"type":"notification","data":"hello"}`)
+
+	app.events.Close()
+
+	opcode, payload = readServerFrame(t, br)
+	assert.Equal(t, opcode, 0x8)
+	assert.Equal(t, int(binary.BigEndian.Uint16(payload)), 1001)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// do sends a request and decodes a JSON response into dst, if given. It
+// returns the status code even when the request did not succeed.
+func (c *client) do(ctx context.Context, method, path string, body any, dst any) (int, error) {
+	var r io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		r = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if method != http.MethodGet {
+		// Servers run with -replay-protection require these.
+		nonce := make([]byte, 16)
+		if _, err := crand.Read(nonce); err != nil {
+			return 0, err
+		}
+		req.Header.Set("X-Nonce", hex.EncodeToString(nonce))
+		req.Header.Set("X-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		io.Copy(io.Discard, res.Body)
+		return res.StatusCode, fmt.Errorf("%s %s: status %d", method, path, res.StatusCode)
+	}
+
+	if dst == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return res.StatusCode, err
+	}
+
+	return res.StatusCode, json.NewDecoder(res.Body).Decode(dst)
+}
+
+func (c *client) authenticate(email, password string) error {
+	var output struct {
+		Token struct {
+			Token string `json:"token"`
+		} `json:"authentication_token"`
+	}
+
+	input := map[string]string{"email": email, "password": password}
+
+	_, err := c.do(context.Background(), http.MethodPost, "/v1/tokens/authentication", input, &output)
+	if err != nil {
+		return err
+	}
+
+	c.token = output.Token.Token
+	return nil
+}
+
+// movieIDs returns the IDs of the first page of movies, for the scenarios
+// which read a single movie.
+func (c *client) movieIDs() ([]int64, error) {
+	var output struct {
+		Movies []struct {
+			ID int64 `json:"id"`
+		} `json:"movies"`
+	}
+
+	_, err := c.do(context.Background(), http.MethodGet, "/v1/movies?page_size=100", nil, &output)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(output.Movies))
+	for i, movie := range output.Movies {
+		ids[i] = movie.ID
+	}
+	return ids, nil
+}
+
+// worker runs scenarios one after another until the context is done.
+type worker struct {
+	client *client
+	rec    *recorder
+	rnd    *rand.Rand
+	ids    []int64
+	writes float64
+}
+
+var searchTerms = []string{"the", "love", "night", "war", "city", "man", "star"}
+
+func (w *worker) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if w.rnd.Float64() < w.writes {
+			w.write(ctx)
+		} else {
+			w.read(ctx)
+		}
+	}
+}
+
+// timed sends a request and records it under scenario. Requests cut short
+// by the end of the test are not recorded.
+func (w *worker) timed(ctx context.Context, scenario, method, path string, body, dst any) error {
+	start := time.Now()
+	status, err := w.client.do(ctx, method, path, body, dst)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	w.rec.record(scenario, time.Since(start), status, err)
+	return err
+}
+
+func (w *worker) read(ctx context.Context) {
+	switch n := w.rnd.Intn(10); {
+	case n < 5 && len(w.ids) > 0:
+		id := w.ids[w.rnd.Intn(len(w.ids))]
+		w.timed(ctx, "show_movie", http.MethodGet, fmt.Sprintf("/v1/movies/%d", id), nil, nil)
+	case n < 8:
+		page := 1 + w.rnd.Intn(5)
+		w.timed(ctx, "list_movies", http.MethodGet, fmt.Sprintf("/v1/movies?page=%d&page_size=20", page), nil, nil)
+	default:
+		term := searchTerms[w.rnd.Intn(len(searchTerms))]
+		w.timed(ctx, "search_movies", http.MethodGet, "/v1/movies?title="+term, nil, nil)
+	}
+}
+
+// write creates a movie, updates it and deletes it, so that the database
+// does not grow during the test.
+func (w *worker) write(ctx context.Context) {
+	var created struct {
+		Movie struct {
+			ID int64 `json:"id"`
+		} `json:"movie"`
+	}
+
+	movie := map[string]any{
+		"title":   fmt.Sprintf("Load test %d", w.rnd.Int63()),
+		"year":    1950 + w.rnd.Intn(70),
+		"runtime": fmt.Sprintf("%d mins", 80+w.rnd.Intn(100)),
+		"genres":  []string{"drama"},
+	}
+
+	err := w.timed(ctx, "create_movie", http.MethodPost, "/v1/movies", movie, &created)
+	if err != nil {
+		return
+	}
+
+	path := fmt.Sprintf("/v1/movies/%d", created.Movie.ID)
+
+	w.timed(ctx, "update_movie", http.MethodPatch, path, map[string]any{"title": "Updated load test"}, nil)
+	if ctx.Err() == nil {
+		w.timed(ctx, "delete_movie", http.MethodDelete, path, nil, nil)
+	}
+
+	// The movie is deleted even if the test ended part way through.
+	if ctx.Err() != nil {
+		w.client.do(context.Background(), http.MethodDelete, path, nil, nil)
+	}
+}
@@ -0,0 +1,125 @@
+// Command loadtest generates HTTP load against a running API server and
+// reports the latency of each scenario, to check the effect of changes to
+// the rate limiter, caches and database layer. Run the server with the rate
+// limiter disabled (-limiter-enabled=false) unless it is what is measured.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type config struct {
+	url         string
+	email       string
+	password    string
+	token       string
+	concurrency int
+	duration    time.Duration
+	writes      float64
+	timeout     time.Duration
+	json        bool
+	seed        int64
+}
+
+func main() {
+	var cfg config
+
+	flag.StringVar(&cfg.url, "url", "http://localhost:4000", "Base URL of the API server")
+	flag.StringVar(&cfg.email, "email", "dev@example.com", "Email of the user to authenticate as")
+	flag.StringVar(&cfg.password, "password", "pa55word", "Password of the user to authenticate as")
+	flag.StringVar(&cfg.token, "token", os.Getenv("GREENLIGHT_TOKEN"), "Authentication token to use instead of logging in")
+	flag.IntVar(&cfg.concurrency, "concurrency", 10, "Number of workers sending requests at once")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "How long to generate load for")
+	flag.Float64Var(&cfg.writes, "writes", 0.1, "Fraction of iterations which create, update and delete a movie instead of reading (0-1)")
+	flag.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "Give up on a request after this long")
+	flag.BoolVar(&cfg.json, "json", false, "Print the report as JSON")
+	flag.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "Seed of the random source choosing scenarios")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "loadtest:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg config) error {
+	if cfg.concurrency < 1 {
+		return errors.New("-concurrency must be at least 1")
+	}
+	if cfg.writes < 0 || cfg.writes > 1 {
+		return errors.New("-writes must be from 0 to 1")
+	}
+
+	c := &client{
+		baseURL: strings.TrimSuffix(cfg.url, "/"),
+		token:   cfg.token,
+		http: &http.Client{
+			Timeout: cfg.timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        cfg.concurrency,
+				MaxIdleConnsPerHost: cfg.concurrency,
+			},
+		},
+	}
+
+	if c.token == "" {
+		err := c.authenticate(cfg.email, cfg.password)
+		if err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	ids, err := c.movieIDs()
+	if err != nil {
+		return fmt.Errorf("listing movies: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.duration)
+	defer cancel()
+
+	results := make([]*recorder, cfg.concurrency)
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := range results {
+		rec := newRecorder()
+		results[i] = rec
+
+		w := &worker{
+			client: c,
+			rec:    rec,
+			rnd:    rand.New(rand.NewSource(cfg.seed + int64(i))),
+			ids:    ids,
+			writes: cfg.writes,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	rep := newReport(results, time.Since(start), cfg.concurrency)
+
+	if cfg.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(rep)
+	}
+
+	rep.print(os.Stdout)
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects one worker's results, so that workers do not contend
+// for a lock.
+type recorder struct {
+	scenarios map[string]*samples
+}
+
+type samples struct {
+	latencies []time.Duration
+	errors    int
+	statuses  map[int]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{scenarios: make(map[string]*samples)}
+}
+
+func (r *recorder) record(scenario string, d time.Duration, status int, err error) {
+	s, ok := r.scenarios[scenario]
+	if !ok {
+		s = &samples{statuses: make(map[int]int)}
+		r.scenarios[scenario] = s
+	}
+
+	s.latencies = append(s.latencies, d)
+	if err != nil {
+		s.errors++
+	}
+	if status != 0 {
+		s.statuses[status]++
+	}
+}
+
+// scenarioReport summarises the requests of a scenario. Latencies are in
+// milliseconds.
+type scenarioReport struct {
+	Name      string         `json:"name"`
+	Requests  int            `json:"requests"`
+	Errors    int            `json:"errors"`
+	PerSecond float64        `json:"per_second"`
+	Statuses  map[string]int `json:"statuses"`
+	P50       float64        `json:"p50_ms"`
+	P90       float64        `json:"p90_ms"`
+	P99       float64        `json:"p99_ms"`
+	Max       float64        `json:"max_ms"`
+}
+
+type report struct {
+	Duration    float64          `json:"duration_seconds"`
+	Concurrency int              `json:"concurrency"`
+	Total       scenarioReport   `json:"total"`
+	Scenarios   []scenarioReport `json:"scenarios"`
+}
+
+func newReport(recorders []*recorder, elapsed time.Duration, concurrency int) report {
+	merged := make(map[string]*samples)
+	total := &samples{statuses: make(map[int]int)}
+
+	for _, r := range recorders {
+		for name, s := range r.scenarios {
+			m, ok := merged[name]
+			if !ok {
+				m = &samples{statuses: make(map[int]int)}
+				merged[name] = m
+			}
+
+			for _, into := range []*samples{m, total} {
+				into.latencies = append(into.latencies, s.latencies...)
+				into.errors += s.errors
+				for status, count := range s.statuses {
+					into.statuses[status] += count
+				}
+			}
+		}
+	}
+
+	rep := report{
+		Duration:    elapsed.Seconds(),
+		Concurrency: concurrency,
+		Total:       summarise("total", total, elapsed),
+	}
+
+	for name, s := range merged {
+		rep.Scenarios = append(rep.Scenarios, summarise(name, s, elapsed))
+	}
+	sort.Slice(rep.Scenarios, func(i, j int) bool {
+		return rep.Scenarios[i].Name < rep.Scenarios[j].Name
+	})
+
+	return rep
+}
+
+func summarise(name string, s *samples, elapsed time.Duration) scenarioReport {
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+
+	statuses := make(map[string]int, len(s.statuses))
+	for status, count := range s.statuses {
+		statuses[strconv.Itoa(status)] = count
+	}
+
+	return scenarioReport{
+		Name:      name,
+		Requests:  len(s.latencies),
+		Errors:    s.errors,
+		PerSecond: float64(len(s.latencies)) / elapsed.Seconds(),
+		Statuses:  statuses,
+		P50:       percentile(s.latencies, 50),
+		P90:       percentile(s.latencies, 90),
+		P99:       percentile(s.latencies, 99),
+		Max:       percentile(s.latencies, 100),
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies, in
+// milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+
+	return float64(sorted[rank]) / float64(time.Millisecond)
+}
+
+func (rep report) print(w io.Writer) {
+	fmt.Fprintf(w, "%d workers for %.1fs\n\n", rep.Concurrency, rep.Duration)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "scenario\trequests\terrors\treq/s\tp50 ms\tp90 ms\tp99 ms\tmax ms\tstatuses\t")
+
+	for _, s := range append(rep.Scenarios, rep.Total) {
+		codes := make([]string, 0, len(s.Statuses))
+		for code := range s.Statuses {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+
+		var statuses string
+		for i, code := range codes {
+			if i > 0 {
+				statuses += " "
+			}
+			statuses += fmt.Sprintf("%s×%d", code, s.Statuses[code])
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%s\t\n", s.Name, s.Requests, s.Errors, s.PerSecond, s.P50, s.P90, s.P99, s.Max, statuses)
+	}
+
+	tw.Flush()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"greenlight.bcc/internal/assert"
+)
+
+func TestReport(t *testing.T) {
+	a, b := newRecorder(), newRecorder()
+	for i := 1; i <= 100; i++ {
+		a.record("show_movie", time.Duration(i)*time.Millisecond, 200, nil)
+	}
+	b.record("create_movie", 5*time.Millisecond, 201, nil)
+	b.record("create_movie", 50*time.Millisecond, 429, errors.New("status 429"))
+
+	rep := newReport([]*recorder{a, b}, 2*time.Second, 2)
+
+	assert.Equal(t, rep.Total.Requests, 102)
+	assert.Equal(t, rep.Total.Errors, 1)
+	assert.Equal(t, rep.Total.Statuses["429"], 1)
+	assert.Equal(t, len(rep.Scenarios), 2)
+
+	show := rep.Scenarios[1]
+	assert.Equal(t, show.Name, "show_movie")
+	assert.Equal(t, show.PerSecond, 50.0)
+	assert.Equal(t, show.P50, 50.0)
+	assert.Equal(t, show.P99, 99.0)
+	assert.Equal(t, show.Max, 100.0)
+}
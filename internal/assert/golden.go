@@ -0,0 +1,85 @@
+package assert
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update makes the golden helpers write what they were given instead of
+// comparing it, as in go test ./... -update.
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func goldenPath(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+// writeGolden stores contents as the golden file name.
+func writeGolden(t *testing.T, name string, contents []byte) {
+	t.Helper()
+
+	path := goldenPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readGolden returns the golden file name, failing the test if it has not
+// been written yet.
+func readGolden(t *testing.T, name string) ([]byte, bool) {
+	t.Helper()
+
+	contents, err := os.ReadFile(goldenPath(name))
+	if err != nil {
+		t.Errorf("reading golden file: %v (run the test with -update to create it)", err)
+		return nil, false
+	}
+	return contents, true
+}
+
+// Golden checks actual against testdata/name.golden byte for byte. With
+// -update the file is written instead.
+func Golden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+
+	if *update {
+		writeGolden(t, name, actual)
+		return
+	}
+
+	expected, ok := readGolden(t, name)
+	if !ok {
+		return
+	}
+
+	if string(actual) != string(expected) {
+		t.Errorf("got:\n%s\nwant (%s):\n%s", actual, goldenPath(name), expected)
+	}
+}
+
+// GoldenJSON checks the JSON in actual against testdata/name.golden with
+// JSONEqual, skipping the fields in ignore. With -update the file is
+// written indented, with the ignored fields' values replaced.
+func GoldenJSON(t *testing.T, name string, actual []byte, ignore ...string) {
+	t.Helper()
+
+	if *update {
+		normalized, err := normalizeJSON(string(actual), ignore...)
+		if err != nil {
+			t.Fatalf("got invalid JSON: %v\n%s", err, actual)
+		}
+		writeGolden(t, name, normalized)
+		return
+	}
+
+	expected, ok := readGolden(t, name)
+	if !ok {
+		return
+	}
+
+	JSONEqual(t, string(actual), string(expected), ignore...)
+}
@@ -0,0 +1,57 @@
+package assert
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Response reads res's body, checking its status code. It works with
+// recorders too, through httptest.ResponseRecorder.Result.
+func Response(t *testing.T, res *http.Response, status int) []byte {
+	t.Helper()
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != status {
+		t.Errorf("got status: %d; want: %d\n%s", res.StatusCode, status, body)
+	}
+
+	return body
+}
+
+// JSONResponse is Response for JSON responses, also checking the content
+// type.
+func JSONResponse(t *testing.T, res *http.Response, status int) []byte {
+	t.Helper()
+
+	body := Response(t, res, status)
+
+	if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("got content type: %q; want: application/json", contentType)
+	}
+
+	return body
+}
+
+// Header checks that res has the header key set to value.
+func Header(t *testing.T, res *http.Response, key, value string) {
+	t.Helper()
+
+	if got := res.Header.Get(key); got != value {
+		t.Errorf("got %s: %q; want: %q", key, got, value)
+	}
+}
+
+// GoldenResponse checks res's status code and content type, and its JSON
+// body against testdata/name.golden as GoldenJSON does.
+func GoldenResponse(t *testing.T, name string, res *http.Response, status int, ignore ...string) {
+	t.Helper()
+
+	GoldenJSON(t, name, JSONResponse(t, res, status), ignore...)
+}
@@ -0,0 +1,184 @@
+package assert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// maxDifferences is how many differences a failing JSON comparison lists.
+const maxDifferences = 20
+
+// ignored replaces the values of ignored fields, so that golden files show
+// where they were.
+const ignored = "<ignored>"
+
+// JSONEqual checks that actual and expected hold the same JSON value, with
+// any order of object keys. Fields named in ignore, such as timestamps, are
+// skipped at any depth. Each difference is reported with its path.
+func JSONEqual(t *testing.T, actual, expected string, ignore ...string) {
+	t.Helper()
+
+	got, err := decodeJSON(actual)
+	if err != nil {
+		t.Errorf("got invalid JSON: %v\n%s", err, actual)
+		return
+	}
+
+	want, err := decodeJSON(expected)
+	if err != nil {
+		t.Errorf("want invalid JSON: %v\n%s", err, expected)
+		return
+	}
+
+	skip := make(map[string]bool, len(ignore))
+	for _, field := range ignore {
+		skip[field] = true
+	}
+
+	differences := diffJSON("$", got, want, skip, nil)
+	if len(differences) == 0 {
+		return
+	}
+
+	if len(differences) > maxDifferences {
+		differences = append(differences[:maxDifferences], fmt.Sprintf("and %d more", len(differences)-maxDifferences))
+	}
+	t.Errorf("JSON differs:\n\t%s", strings.Join(differences, "\n\t"))
+}
+
+func decodeJSON(s string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+func diffJSON(path string, got, want any, skip map[string]bool, differences []string) []string {
+	switch want := want.(type) {
+	case map[string]any:
+		got, ok := got.(map[string]any)
+		if !ok {
+			return append(differences, fmt.Sprintf("%s: got %s; want an object", path, describeJSON(got)))
+		}
+
+		keys := make(map[string]bool, len(got)+len(want))
+		for key := range got {
+			keys[key] = true
+		}
+		for key := range want {
+			keys[key] = true
+		}
+
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			if skip[key] {
+				continue
+			}
+
+			g, inGot := got[key]
+			w, inWant := want[key]
+			switch {
+			case !inGot:
+				differences = append(differences, fmt.Sprintf("%s.%s: missing; want %s", path, key, describeJSON(w)))
+			case !inWant:
+				differences = append(differences, fmt.Sprintf("%s.%s: got %s; want no field", path, key, describeJSON(g)))
+			default:
+				differences = diffJSON(path+"."+key, g, w, skip, differences)
+			}
+		}
+
+		return differences
+	case []any:
+		got, ok := got.([]any)
+		if !ok {
+			return append(differences, fmt.Sprintf("%s: got %s; want an array", path, describeJSON(got)))
+		}
+
+		if len(got) != len(want) {
+			differences = append(differences, fmt.Sprintf("%s: got %d elements; want %d", path, len(got), len(want)))
+		}
+
+		for i := 0; i < len(got) && i < len(want); i++ {
+			differences = diffJSON(fmt.Sprintf("%s[%d]", path, i), got[i], want[i], skip, differences)
+		}
+
+		return differences
+	default:
+		if want == ignored {
+			return differences
+		}
+
+		if describeJSON(got) != describeJSON(want) {
+			differences = append(differences, fmt.Sprintf("%s: got %s; want %s", path, describeJSON(got), describeJSON(want)))
+		}
+
+		return differences
+	}
+}
+
+func describeJSON(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	}
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(js)
+}
+
+// normalizeJSON indents s and replaces the values of ignored fields, for
+// writing golden files which do not change between runs.
+func normalizeJSON(s string, ignore ...string) ([]byte, error) {
+	v, err := decodeJSON(s)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool, len(ignore))
+	for _, field := range ignore {
+		skip[field] = true
+	}
+
+	var replace func(v any) any
+	replace = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				if skip[key] {
+					v[key] = ignored
+				} else {
+					v[key] = replace(value)
+				}
+			}
+		case []any:
+			for i, value := range v {
+				v[i] = replace(value)
+			}
+		}
+		return v
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+
+	err = enc.Encode(replace(v))
+	return buf.Bytes(), err
+}
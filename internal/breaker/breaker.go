@@ -0,0 +1,126 @@
+// Package breaker implements a circuit breaker which stops calls to a
+// failing dependency for a while, so that requests fail fast instead of
+// piling up behind timeouts.
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// ErrOpen matches the errors returned while a breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError is returned by Allow while the breaker is open. RetryAfter is
+// how long until the breaker lets a probe through.
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, ErrOpen)
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// Breaker opens after threshold consecutive failures. Once cooldown has
+// passed it half-opens and lets a single probe through: success closes it
+// again and failure reopens it for another cooldown.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     StateClosed,
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns StateClosed, StateOpen or StateHalfOpen.
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Allow reports whether a call may go ahead, returning an *OpenError if not.
+// Every allowed call must be followed by Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		elapsed := b.now().Sub(b.openedAt)
+		if elapsed < b.cooldown {
+			return &OpenError{Name: b.name, RetryAfter: b.cooldown - elapsed}
+		}
+		b.state = StateHalfOpen
+		return nil
+	case StateHalfOpen:
+		// A probe is already in flight.
+		return &OpenError{Name: b.name, RetryAfter: b.cooldown}
+	}
+
+	return nil
+}
+
+// Record reports the outcome of a call which Allow let through.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Do runs fn if the breaker allows it and records the outcome.
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+
+	err := fn()
+	b.Record(err)
+	return err
+}
@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// channel is the Postgres NOTIFY channel invalidations are sent on.
+const channel = "cache_invalidations"
+
+// Evicter is what invalidations are applied to, such as a Cache.
+type Evicter interface {
+	Delete(keys ...string)
+	Purge()
+}
+
+// Invalidation announces that the values under Keys have changed on the
+// instance Origin.
+type Invalidation struct {
+	Origin string    `json:"origin"`
+	Keys   []string  `json:"keys"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Bus sends invalidations to the other instances, and applies theirs to
+// this instance's caches. Instances never receive their own invalidations,
+// so callers evict their own entries themselves.
+type Bus interface {
+	Publish(keys ...string) error
+	Stats() BusStats
+	Close() error
+}
+
+// BusStats counts the invalidations sent and received, and how long the
+// received ones took to arrive. Latencies are measured against the sender's
+// clock, so they are only as accurate as the instances' clocks agree.
+type BusStats struct {
+	Published        int64   `json:"published"`
+	Received         int64   `json:"received"`
+	Resets           int64   `json:"resets"`
+	LatencyAverageMS float64 `json:"latency_average_ms"`
+	LatencyMaxMS     float64 `json:"latency_max_ms"`
+}
+
+type busMetrics struct {
+	mu           sync.Mutex
+	stats        BusStats
+	totalLatency time.Duration
+}
+
+func (m *busMetrics) published() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Published++
+}
+
+func (m *busMetrics) received(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.Received++
+	m.totalLatency += latency
+	m.stats.LatencyAverageMS = float64(m.totalLatency) / float64(m.stats.Received) / float64(time.Millisecond)
+	if ms := float64(latency) / float64(time.Millisecond); ms > m.stats.LatencyMaxMS {
+		m.stats.LatencyMaxMS = ms
+	}
+}
+
+func (m *busMetrics) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Resets++
+}
+
+func (m *busMetrics) snapshot() BusStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+func deliver(metrics *busMetrics, inv Invalidation, targets []Evicter) {
+	for _, target := range targets {
+		target.Delete(inv.Keys...)
+	}
+	metrics.received(time.Since(inv.SentAt))
+}
+
+// PostgresBus sends invalidations with NOTIFY and listens for them on a
+// dedicated connection.
+type PostgresBus struct {
+	db       *sql.DB
+	listener *pq.Listener
+	origin   string
+	targets  []Evicter
+	metrics  busMetrics
+}
+
+// NewPostgresBus connects to the database at dsn to listen for the
+// invalidations of other instances, and applies them to targets. While the
+// connection is down invalidations are missed, so the targets are purged
+// whenever it is re-established.
+func NewPostgresBus(db *sql.DB, dsn, origin string, targets ...Evicter) (*PostgresBus, error) {
+	b := &PostgresBus{db: db, origin: origin, targets: targets}
+
+	b.listener = pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if event == pq.ListenerEventReconnected {
+			b.purge()
+		}
+	})
+
+	err := b.listener.Listen(channel)
+	if err != nil {
+		b.listener.Close()
+		return nil, err
+	}
+
+	go b.receive()
+
+	return b, nil
+}
+
+func (b *PostgresBus) receive() {
+	for n := range b.listener.Notify {
+		// A nil notification follows a reconnection, which purge handles.
+		if n == nil {
+			continue
+		}
+
+		var inv Invalidation
+		if err := json.Unmarshal([]byte(n.Extra), &inv); err != nil || inv.Origin == b.origin {
+			continue
+		}
+
+		deliver(&b.metrics, inv, b.targets)
+	}
+}
+
+func (b *PostgresBus) purge() {
+	for _, target := range b.targets {
+		target.Purge()
+	}
+	b.metrics.reset()
+}
+
+func (b *PostgresBus) Publish(keys ...string) error {
+	payload, err := json.Marshal(Invalidation{Origin: b.origin, Keys: keys, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = b.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, string(payload))
+	if err != nil {
+		return err
+	}
+
+	b.metrics.published()
+	return nil
+}
+
+func (b *PostgresBus) Stats() BusStats {
+	return b.metrics.snapshot()
+}
+
+func (b *PostgresBus) Close() error {
+	return b.listener.Close()
+}
+
+// MemoryHub connects buses in the same process. It stands in for Postgres
+// in development and tests.
+type MemoryHub struct {
+	mu    sync.Mutex
+	buses []*MemoryBus
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{}
+}
+
+// Connect returns a bus for the instance origin, applying the invalidations
+// of the hub's other buses to targets.
+func (h *MemoryHub) Connect(origin string, targets ...Evicter) *MemoryBus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b := &MemoryBus{hub: h, origin: origin, targets: targets}
+	h.buses = append(h.buses, b)
+	return b
+}
+
+type MemoryBus struct {
+	hub     *MemoryHub
+	origin  string
+	targets []Evicter
+	metrics busMetrics
+}
+
+func (b *MemoryBus) Publish(keys ...string) error {
+	inv := Invalidation{Origin: b.origin, Keys: keys, SentAt: time.Now()}
+
+	b.hub.mu.Lock()
+	defer b.hub.mu.Unlock()
+
+	for _, other := range b.hub.buses {
+		if other.origin != b.origin {
+			deliver(&other.metrics, inv, other.targets)
+		}
+	}
+
+	b.metrics.published()
+	return nil
+}
+
+func (b *MemoryBus) Stats() BusStats {
+	return b.metrics.snapshot()
+}
+
+func (b *MemoryBus) Close() error {
+	b.hub.mu.Lock()
+	defer b.hub.mu.Unlock()
+
+	for i, other := range b.hub.buses {
+		if other == b {
+			b.hub.buses = append(b.hub.buses[:i], b.hub.buses[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
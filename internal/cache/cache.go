@@ -0,0 +1,102 @@
+// Package cache keeps recently read values in memory for a short time, and
+// carries invalidations between the instances of a deployment so that a
+// change on one evicts the stale copies held by the others.
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type entry[V any] struct {
+	value  V
+	expiry time.Time
+}
+
+// Cache holds up to maxEntries values, each for at most ttl. It is safe for
+// concurrent use.
+type Cache[V any] struct {
+	mu         sync.Mutex
+	entries    map[string]entry[V]
+	ttl        time.Duration
+	maxEntries int
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func New[V any](ttl time.Duration, maxEntries int) *Cache[V] {
+	return &Cache[V]{
+		entries:    make(map[string]entry[V]),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expiry) {
+		c.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Set stores value under key. When the cache is full, expired entries are
+// dropped, and if that frees no room everything is.
+func (c *Cache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expiry) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			c.entries = make(map[string]entry[V])
+		}
+	}
+
+	c.entries[key] = entry[V]{value: value, expiry: time.Now().Add(c.ttl)}
+}
+
+func (c *Cache[V]) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+}
+
+// Purge drops every entry.
+func (c *Cache[V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]entry[V])
+}
+
+// Stats is a snapshot of a cache's use, for publishing as a metric.
+type Stats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+func (c *Cache[V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{Entries: len(c.entries), Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
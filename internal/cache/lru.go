@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+// LRU holds up to maxEntries values, dropping the least recently used one
+// to make room. Unlike Cache, values do not expire, so it suits values
+// which never go stale, such as those derived from content-addressed data.
+// It is safe for concurrent use.
+type LRU[V any] struct {
+	mu         sync.Mutex
+	order      *list.List
+	entries    map[string]*list.Element
+	maxEntries int
+}
+
+func NewLRU[V any](maxEntries int) *LRU[V] {
+	return &LRU[V]{
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry[V]).value, true
+}
+
+// Set stores value under key as the most recently used value.
+func (c *LRU[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruEntry[V]).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value})
+
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *LRU[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
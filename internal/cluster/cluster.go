@@ -0,0 +1,192 @@
+// Package cluster keeps track of the API instances in a deployment. Each
+// instance registers itself in a shared registry and heartbeats while it
+// runs, so that any instance can list its peers and tell which are healthy.
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp          = "up"
+	StatusDraining    = "draining"
+	StatusStale       = "stale"
+	StatusUnreachable = "unreachable"
+)
+
+// ErrNotRegistered is returned by Registry.Heartbeat when the instance is
+// not in the registry, such as after it was pruned for missing heartbeats.
+var ErrNotRegistered = errors.New("cluster: instance not registered")
+
+// Instance is an API instance as recorded in the registry. Addr is the base
+// URL peers reach it on.
+type Instance struct {
+	ID         string    `json:"id"`
+	Addr       string    `json:"addr"`
+	MetricsURL string    `json:"metrics_url"`
+	Version    string    `json:"version"`
+	StartedAt  time.Time `json:"started_at"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Registry is where instances record themselves.
+type Registry interface {
+	// Register adds the instance, or replaces it if already registered,
+	// and marks it seen now.
+	Register(ctx context.Context, instance Instance) error
+	// Heartbeat marks the instance seen now.
+	Heartbeat(ctx context.Context, id string) error
+	// Deregister removes the instance. Removing a missing instance is not
+	// an error.
+	Deregister(ctx context.Context, id string) error
+	// List returns every registered instance.
+	List(ctx context.Context) ([]Instance, error)
+	// Prune removes the instances last seen before the given time.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Peer is an instance with its health as seen from this one.
+type Peer struct {
+	Instance
+	Self    bool   `json:"self"`
+	Healthy bool   `json:"healthy"`
+	Status  string `json:"status"`
+}
+
+// Node is this instance's membership of the cluster.
+type Node struct {
+	self     Instance
+	registry Registry
+	ttl      time.Duration
+	client   *http.Client
+}
+
+// New returns a node for self. Instances not seen for ttl are reported
+// stale, and those not seen for ten times as long are pruned.
+func New(registry Registry, self Instance, ttl time.Duration) *Node {
+	if self.ID == "" {
+		self.ID = newID()
+	}
+	if self.StartedAt.IsZero() {
+		self.StartedAt = time.Now()
+	}
+	self.Addr = strings.TrimSuffix(self.Addr, "/")
+
+	return &Node{
+		self:     self,
+		registry: registry,
+		ttl:      ttl,
+		client:   &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Self returns this instance as it is registered.
+func (n *Node) Self() Instance {
+	return n.self
+}
+
+// Join registers this instance.
+func (n *Node) Join(ctx context.Context) error {
+	return n.registry.Register(ctx, n.self)
+}
+
+// Heartbeat marks this instance seen, registering it again if it was
+// pruned, and prunes instances which have long stopped heartbeating.
+func (n *Node) Heartbeat(ctx context.Context) error {
+	err := n.registry.Heartbeat(ctx, n.self.ID)
+	if errors.Is(err, ErrNotRegistered) {
+		err = n.Join(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	return n.registry.Prune(ctx, time.Now().Add(-10*n.ttl))
+}
+
+// Leave deregisters this instance.
+func (n *Node) Leave(ctx context.Context) error {
+	return n.registry.Deregister(ctx, n.self.ID)
+}
+
+// Peers returns every registered instance, this one included, ordered by ID.
+// Instances which have heartbeated recently are probed on their readiness
+// endpoint, so that one which is draining or cannot be reached from here
+// is not reported healthy.
+func (n *Node) Peers(ctx context.Context) ([]Peer, error) {
+	instances, err := n.registry.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+
+	peers := make([]Peer, len(instances))
+	var wg sync.WaitGroup
+
+	for i, instance := range instances {
+		peers[i] = Peer{Instance: instance, Self: instance.ID == n.self.ID}
+
+		switch {
+		case peers[i].Self:
+			peers[i].Status = StatusUp
+		case time.Since(instance.LastSeen) > n.ttl:
+			peers[i].Status = StatusStale
+		default:
+			wg.Add(1)
+			go func(p *Peer) {
+				defer wg.Done()
+				p.Status = n.probe(ctx, p.Addr)
+			}(&peers[i])
+		}
+	}
+
+	wg.Wait()
+
+	for i := range peers {
+		peers[i].Healthy = peers[i].Status == StatusUp
+	}
+
+	return peers, nil
+}
+
+func (n *Node) probe(ctx context.Context, addr string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/readyz", nil)
+	if err != nil {
+		return StatusUnreachable
+	}
+
+	rs, err := n.client.Do(req)
+	if err != nil {
+		return StatusUnreachable
+	}
+	rs.Body.Close()
+
+	switch rs.StatusCode {
+	case http.StatusOK:
+		return StatusUp
+	case http.StatusServiceUnavailable:
+		return StatusDraining
+	default:
+		return StatusUnreachable
+	}
+}
@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRegistry keeps the registry in memory. It only sees the instances
+// sharing it, so it is meant for development and tests.
+type MemoryRegistry struct {
+	mu        sync.Mutex
+	instances map[string]Instance
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{instances: make(map[string]Instance)}
+}
+
+func (r *MemoryRegistry) Register(ctx context.Context, instance Instance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	instance.LastSeen = time.Now()
+	r.instances[instance.ID] = instance
+	return nil
+}
+
+func (r *MemoryRegistry) Heartbeat(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	instance, ok := r.instances[id]
+	if !ok {
+		return ErrNotRegistered
+	}
+
+	instance.LastSeen = time.Now()
+	r.instances[id] = instance
+	return nil
+}
+
+func (r *MemoryRegistry) Deregister(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.instances, id)
+	return nil
+}
+
+func (r *MemoryRegistry) List(ctx context.Context) ([]Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	instances := make([]Instance, 0, len(r.instances))
+	for _, instance := range r.instances {
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+func (r *MemoryRegistry) Prune(ctx context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, instance := range r.instances {
+		if instance.LastSeen.Before(before) {
+			delete(r.instances, id)
+		}
+	}
+	return nil
+}
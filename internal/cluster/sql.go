@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SQLRegistry keeps the registry in the cluster_instances table. Times are
+// taken from the database clock, so that instances with skewed clocks agree
+// on when a peer was last seen.
+type SQLRegistry struct {
+	DB *sql.DB
+}
+
+func NewSQLRegistry(db *sql.DB) *SQLRegistry {
+	return &SQLRegistry{DB: db}
+}
+
+func (r *SQLRegistry) Register(ctx context.Context, instance Instance) error {
+	query := `
+		INSERT INTO cluster_instances (id, address, metrics_url, version, started_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE
+		SET address = EXCLUDED.address, metrics_url = EXCLUDED.metrics_url,
+			version = EXCLUDED.version, last_seen_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, instance.ID, instance.Addr, instance.MetricsURL, instance.Version, instance.StartedAt)
+	return err
+}
+
+func (r *SQLRegistry) Heartbeat(ctx context.Context, id string) error {
+	query := `
+		UPDATE cluster_instances
+		SET last_seen_at = NOW()
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotRegistered
+	}
+
+	return nil
+}
+
+func (r *SQLRegistry) Deregister(ctx context.Context, id string) error {
+	query := `
+		DELETE FROM cluster_instances
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// List reports each instance's last_seen relative to the database clock,
+// shifted onto the local one, so that comparing it with time.Now is sound.
+func (r *SQLRegistry) List(ctx context.Context) ([]Instance, error) {
+	query := `
+		SELECT id, address, metrics_url, version, started_at, EXTRACT(EPOCH FROM NOW() - last_seen_at)
+		FROM cluster_instances`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	instances := []Instance{}
+
+	for rows.Next() {
+		var instance Instance
+		var age float64
+
+		err := rows.Scan(
+			&instance.ID,
+			&instance.Addr,
+			&instance.MetricsURL,
+			&instance.Version,
+			&instance.StartedAt,
+			&age,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		instance.LastSeen = now.Add(-time.Duration(age * float64(time.Second))).Truncate(time.Second)
+		instances = append(instances, instance)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return instances, nil
+}
+
+func (r *SQLRegistry) Prune(ctx context.Context, before time.Time) error {
+	query := `
+		DELETE FROM cluster_instances
+		WHERE last_seen_at < NOW() - $1 * INTERVAL '1 second'`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, query, time.Since(before).Seconds())
+	return err
+}
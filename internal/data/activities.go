@@ -0,0 +1,134 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ActivityMoviePublished = "movie_published"
+	ActivityCommentReply   = "comment_reply"
+	ActivityListUpdated    = "list_updated"
+)
+
+var ActivityKinds = []string{ActivityMoviePublished, ActivityCommentReply, ActivityListUpdated}
+
+// Activity is an entry in the activity feeds. Activity with a RecipientID
+// is addressed to that user alone; the rest is shared with everyone in the
+// organization. Genres are those of the movie the activity is about, if any.
+type Activity struct {
+	ID          int64          `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Kind        string         `json:"kind"`
+	OrgID       int64          `json:"-"`
+	ActorID     int64          `json:"actor_id,omitempty"`
+	RecipientID int64          `json:"-"`
+	Genres      []string       `json:"-"`
+	Data        map[string]any `json:"data"`
+}
+
+// FeedQuery selects a user's feed: the activity addressed to them and the
+// activity of their organization, leaving out their own and that of users
+// they have blocked or muted. Of the organization's activity, published
+// movies are limited to the genres the user follows, if they follow any,
+// and list updates to the users they follow.
+type FeedQuery struct {
+	UserID int64
+	OrgID  int64
+	// Kinds, if set, limits the feed to these kinds of activity.
+	Kinds []string
+	// Before is the cursor: only activity with a lower ID is returned.
+	Before int64
+	Limit  int
+}
+
+type ActivityModel struct {
+	DB *sql.DB
+}
+
+func (m ActivityModel) Insert(activity *Activity) error {
+	data, err := json.Marshal(activity.Data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO activities (kind, org_id, actor_id, recipient_id, genres, data)
+	VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0), $5, $6)
+	RETURNING id, created_at`
+
+	args := []any{activity.Kind, activity.OrgID, activity.ActorID, activity.RecipientID, pq.Array(activity.Genres), data}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&activity.ID, &activity.CreatedAt)
+}
+
+// GetFeed returns up to q.Limit activities, newest first.
+func (m ActivityModel) GetFeed(q FeedQuery) ([]*Activity, error) {
+	query := `
+	SELECT id, created_at, kind, coalesce(org_id, 0), coalesce(actor_id, 0), coalesce(recipient_id, 0), genres, data
+	FROM activities
+	WHERE (recipient_id = $1 OR (recipient_id IS NULL AND org_id = $2))
+	AND (kind <> 'movie_published'
+		OR NOT EXISTS (SELECT 1 FROM genre_follows WHERE user_id = $1)
+		OR genres && ARRAY(SELECT genre FROM genre_follows WHERE user_id = $1))
+	AND (kind <> 'list_updated'
+		OR EXISTS (SELECT 1 FROM user_follows WHERE follower_id = $1 AND followed_id = activities.actor_id))
+	AND (cardinality($3::text[]) = 0 OR kind = ANY($3))
+	AND ($4::bigint = 0 OR id < $4)
+	AND actor_id IS DISTINCT FROM $1
+	AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocks.blocker_id = $1 AND blocks.blocked_id = activities.actor_id)
+	ORDER BY id DESC
+	LIMIT $5`
+
+	args := []any{q.UserID, q.OrgID, pq.Array(q.Kinds), q.Before, q.Limit}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []*Activity{}
+
+	for rows.Next() {
+		var activity Activity
+		var data []byte
+
+		err := rows.Scan(
+			&activity.ID,
+			&activity.CreatedAt,
+			&activity.Kind,
+			&activity.OrgID,
+			&activity.ActorID,
+			&activity.RecipientID,
+			pq.Array(&activity.Genres),
+			&data,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(data, &activity.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		activities = append(activities, &activity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return activities, nil
+}
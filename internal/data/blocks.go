@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// Muting a user hides their comments from the muter and silences the
+// notifications they cause. Blocking does the same and also stops them
+// from replying to the blocker's comments.
+const (
+	BlockKindBlock = "block"
+	BlockKindMute  = "mute"
+)
+
+type Block struct {
+	BlockerID int64     `json:"-"`
+	BlockedID int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Kind      string    `json:"kind" validate:"required,oneof=block mute"`
+}
+
+func ValidateBlock(v *validator.Validator, block *Block) {
+	v.Struct(block)
+	v.Check(block.BlockedID != block.BlockerID, "user_id", "must not be yourself")
+}
+
+type BlockModel struct {
+	DB *sql.DB
+}
+
+// Insert blocks or mutes a user, replacing any earlier block or mute of the
+// same user.
+func (m BlockModel) Insert(block *Block) error {
+	query := `
+	INSERT INTO blocks (blocker_id, blocked_id, kind)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (blocker_id, blocked_id) DO UPDATE SET kind = EXCLUDED.kind
+	RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, block.BlockerID, block.BlockedID, block.Kind).Scan(&block.CreatedAt)
+}
+
+func (m BlockModel) Delete(blockerID, blockedID int64) error {
+	query := `
+	DELETE FROM blocks
+	WHERE blocker_id = $1 AND blocked_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m BlockModel) GetAllForUser(blockerID int64) ([]*Block, error) {
+	query := `
+	SELECT blocker_id, blocked_id, created_at, kind
+	FROM blocks
+	WHERE blocker_id = $1
+	ORDER BY created_at DESC, blocked_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []*Block{}
+
+	for rows.Next() {
+		var block Block
+
+		err := rows.Scan(&block.BlockerID, &block.BlockedID, &block.CreatedAt, &block.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, &block)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// Get returns how blockerID has blocked blockedID, or ErrRecordNotFound if
+// they have not.
+func (m BlockModel) Get(blockerID, blockedID int64) (*Block, error) {
+	query := `
+	SELECT blocker_id, blocked_id, created_at, kind
+	FROM blocks
+	WHERE blocker_id = $1 AND blocked_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var block Block
+
+	err := m.DB.QueryRowContext(ctx, query, blockerID, blockedID).Scan(&block.BlockerID, &block.BlockedID, &block.CreatedAt, &block.Kind)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &block, nil
+}
@@ -0,0 +1,32 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"greenlight.bcc/internal/breaker"
+)
+
+// WithBreaker wraps a connector so that no new connections are attempted
+// while b is open, and failed attempts count towards opening it. Queries on
+// a dead pooled connection fail with driver.ErrBadConn, which makes
+// database/sql dial again, so an unreachable database trips the breaker and
+// models then fail fast with a *breaker.OpenError.
+func WithBreaker(c driver.Connector, b *breaker.Breaker) driver.Connector {
+	return breakerConnector{c, b}
+}
+
+type breakerConnector struct {
+	driver.Connector
+	breaker *breaker.Breaker
+}
+
+func (c breakerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	conn, err := c.Connector.Connect(ctx)
+	c.breaker.Record(err)
+	return conn, err
+}
@@ -0,0 +1,345 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"greenlight.bcc/internal/validator"
+)
+
+var (
+	// ErrMovieInOtherCollection is returned for movies which already belong
+	// to a different collection. A movie is in at most one collection.
+	ErrMovieInOtherCollection = errors.New("movie in other collection")
+	// ErrCollectionMovieNotFound is returned for movies which do not exist
+	// in the collection's organization.
+	ErrCollectionMovieNotFound = errors.New("collection movie not found")
+)
+
+// Collection groups the movies of a franchise, such as a trilogy, in their
+// order within it. Collections belong to an organization.
+type Collection struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	OrgID       int64     `json:"-"`
+	Name        string    `json:"name" validate:"required,max=200"`
+	Description string    `json:"description" validate:"max=2000"`
+	MovieCount  int       `json:"movie_count"`
+	Version     int32     `json:"version"`
+}
+
+// CollectionMovie is a movie in a collection. Positions start at 1.
+type CollectionMovie struct {
+	MovieID  int64  `json:"movie_id"`
+	Title    string `json:"title"`
+	Year     int32  `json:"year,omitempty"`
+	Status   string `json:"status"`
+	Position int    `json:"position"`
+}
+
+// MovieCollection is the collection a movie belongs to, as embedded in the
+// movie.
+type MovieCollection struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Struct(collection)
+}
+
+// ValidateCollectionMovies checks the IDs of the movies a collection is set
+// to hold, in order.
+func ValidateCollectionMovies(v *validator.Validator, movieIDs []int64) {
+	v.Check(len(movieIDs) <= 100, "movie_ids", "must not contain more than 100 movies")
+	v.Check(validator.Unique(movieIDs), "movie_ids", "must not contain duplicate values")
+
+	for _, id := range movieIDs {
+		v.Check(id > 0, "movie_ids", "must contain only positive IDs")
+	}
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func (m CollectionModel) Insert(collection *Collection) error {
+	query := `
+	INSERT INTO collections (org_id, name, description)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, version`
+
+	args := []any{collection.OrgID, collection.Name, collection.Description}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&collection.ID, &collection.CreatedAt, &collection.Version)
+}
+
+const collectionColumns = `id, created_at, org_id, name, description, version,
+	(SELECT count(*) FROM collection_movies WHERE collection_movies.collection_id = collections.id)`
+
+func (c *Collection) dest() []any {
+	return []any{&c.ID, &c.CreatedAt, &c.OrgID, &c.Name, &c.Description, &c.Version, &c.MovieCount}
+}
+
+func (m CollectionModel) Get(orgID, id int64) (*Collection, error) {
+	query := `
+	SELECT ` + collectionColumns + `
+	FROM collections
+	WHERE id = $1 AND org_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var collection Collection
+
+	err := m.DB.QueryRowContext(ctx, query, id, orgID).Scan(collection.dest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &collection, nil
+}
+
+// GetAll returns the organization's collections by name.
+func (m CollectionModel) GetAll(orgID int64, filters Filters) ([]*Collection, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), ` + collectionColumns + `
+	FROM collections
+	WHERE org_id = $1
+	ORDER BY name, id
+	LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, orgID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var collection Collection
+
+		err := rows.Scan(append([]any{&totalRecords}, collection.dest()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return collections, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m CollectionModel) Update(collection *Collection) error {
+	query := `
+	UPDATE collections
+	SET name = $1, description = $2, version = version + 1
+	WHERE id = $3 AND org_id = $4 AND version = $5
+	RETURNING version`
+
+	args := []any{collection.Name, collection.Description, collection.ID, collection.OrgID, collection.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the collection. Its movies are kept.
+func (m CollectionModel) Delete(orgID, id int64) error {
+	query := `
+	DELETE FROM collections
+	WHERE id = $1 AND org_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, orgID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetMovies returns the collection's movies in order.
+func (m CollectionModel) GetMovies(collectionID int64) ([]*CollectionMovie, error) {
+	query := `
+	SELECT movies.id, movies.title, coalesce(movies.year, 0), movies.status, collection_movies.position
+	FROM collection_movies
+	INNER JOIN movies ON movies.id = collection_movies.movie_id
+	WHERE collection_movies.collection_id = $1
+	ORDER BY collection_movies.position`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*CollectionMovie{}
+
+	for rows.Next() {
+		var movie CollectionMovie
+
+		err := rows.Scan(&movie.MovieID, &movie.Title, &movie.Year, &movie.Status, &movie.Position)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// SetMovies replaces the movies of the collection with movieIDs, in that
+// order. The movies must belong to the collection's organization and not to
+// another collection.
+func (m CollectionModel) SetMovies(collection *Collection, movieIDs []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+
+	err = tx.QueryRowContext(ctx, `SELECT id FROM collections WHERE id = $1 AND org_id = $2 FOR UPDATE`, collection.ID, collection.OrgID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM collection_movies WHERE collection_id = $1`, collection.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO collection_movies (collection_id, movie_id, position)
+	SELECT $1, movies.id, ids.position
+	FROM unnest($2::bigint[]) WITH ORDINALITY AS ids(movie_id, position)
+	INNER JOIN movies ON movies.id = ids.movie_id AND movies.org_id = $3`
+
+	result, err := tx.ExecContext(ctx, query, collection.ID, pq.Array(movieIDs), collection.OrgID)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "collection_movies_pkey"):
+			return ErrMovieInOtherCollection
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if int(rowsAffected) != len(movieIDs) {
+		return ErrCollectionMovieNotFound
+	}
+
+	collection.MovieCount = len(movieIDs)
+
+	return tx.Commit()
+}
+
+// Apply sets the collection of each of the movies which belongs to one.
+func (m CollectionModel) Apply(movies []*Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Movie, len(movies))
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		byID[movie.ID] = movie
+		ids = append(ids, movie.ID)
+	}
+
+	query := `
+	SELECT collection_movies.movie_id, collections.id, collections.name, collection_movies.position
+	FROM collection_movies
+	INNER JOIN collections ON collections.id = collection_movies.collection_id
+	WHERE collection_movies.movie_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movieID int64
+		var collection MovieCollection
+
+		err := rows.Scan(&movieID, &collection.ID, &collection.Name, &collection.Position)
+		if err != nil {
+			return err
+		}
+
+		byID[movieID].Collection = &collection
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,243 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// MaxCommentDepth is how deeply replies may nest. Top-level comments have a
+// depth of 0.
+const MaxCommentDepth = 4
+
+// Comment is a comment on a movie. Replies form threads: ThreadID is the ID
+// of the top-level comment a reply belongs to, and is 0 for top-level
+// comments themselves. Deleted comments keep their place in the thread so
+// that the replies to them still make sense, but lose their body; so do
+// comments hidden by a moderator.
+type Comment struct {
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	HiddenAt  *time.Time `json:"hidden_at,omitempty"`
+	MovieID   int64      `json:"movie_id"`
+	UserID    int64      `json:"user_id"`
+	ParentID  int64      `json:"parent_id,omitempty"`
+	ThreadID  int64      `json:"-"`
+	Depth     int        `json:"depth"`
+	Body      string     `json:"body" validate:"required,max=4000"`
+	Replies   []*Comment `json:"replies,omitempty"`
+}
+
+// IsDeleted reports whether the comment has been deleted by its author or
+// hidden by a moderator.
+func (c *Comment) IsDeleted() bool {
+	return c.DeletedAt != nil || c.HiddenAt != nil
+}
+
+// ReplyTo places the comment in the thread of parent, one level below it.
+func (c *Comment) ReplyTo(parent *Comment) {
+	c.ParentID = parent.ID
+	c.ThreadID = parent.ThreadID
+	if c.ThreadID == 0 {
+		c.ThreadID = parent.ID
+	}
+	c.Depth = parent.Depth + 1
+}
+
+func ValidateComment(v *validator.Validator, comment *Comment) {
+	v.Struct(comment)
+	v.Check(comment.Depth <= MaxCommentDepth, "parent_id", fmt.Sprintf("replies must not be nested more than %d levels deep", MaxCommentDepth))
+}
+
+// buildThreads nests comments under their parents. comments must hold whole
+// threads, with every parent before its replies; the top-level comments are
+// returned in the order they appear. Replies whose parent is missing are
+// dropped.
+func buildThreads(comments []*Comment) []*Comment {
+	byID := make(map[int64]*Comment, len(comments))
+	threads := []*Comment{}
+
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+
+		if parent, ok := byID[comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		} else if comment.ParentID == 0 {
+			threads = append(threads, comment)
+		}
+	}
+
+	return threads
+}
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+func (m CommentModel) Insert(comment *Comment) error {
+	query := `
+	INSERT INTO comments (movie_id, user_id, parent_id, thread_id, depth, body)
+	VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), $5, $6)
+	RETURNING id, created_at`
+
+	args := []any{comment.MovieID, comment.UserID, comment.ParentID, comment.ThreadID, comment.Depth, comment.Body}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.CreatedAt)
+}
+
+func (m CommentModel) Get(id int64) (*Comment, error) {
+	query := `
+	SELECT id, created_at, edited_at, deleted_at, hidden_at, movie_id, user_id, coalesce(parent_id, 0), coalesce(thread_id, 0), depth, body
+	FROM comments
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var comment Comment
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(comment.dest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &comment, nil
+}
+
+// GetThreads returns a page of the movie's threads, newest first, with the
+// replies nested under the comments they answer in the order they were made.
+// The metadata counts threads rather than comments. Comments by users the
+// viewer has blocked or muted are left out along with the replies to them.
+func (m CommentModel) GetThreads(movieID, viewerID int64, filters Filters) ([]*Comment, Metadata, error) {
+	query := `
+	WITH threads AS (
+		SELECT id, count(*) OVER() AS total, row_number() OVER(ORDER BY created_at DESC, id DESC) AS position
+		FROM comments
+		WHERE movie_id = $1 AND parent_id IS NULL
+		AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocks.blocker_id = $2 AND blocks.blocked_id = comments.user_id)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	)
+	SELECT threads.total, c.id, c.created_at, c.edited_at, c.deleted_at, c.hidden_at, c.movie_id, c.user_id,
+		coalesce(c.parent_id, 0), coalesce(c.thread_id, 0), c.depth, c.body
+	FROM comments c
+	JOIN threads ON coalesce(c.thread_id, c.id) = threads.id
+	WHERE NOT EXISTS (SELECT 1 FROM blocks WHERE blocks.blocker_id = $2 AND blocks.blocked_id = c.user_id)
+	ORDER BY threads.position, c.depth, c.created_at, c.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, viewerID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var comment Comment
+
+		err := rows.Scan(append([]any{&totalRecords}, comment.dest()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		comments = append(comments, &comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return buildThreads(comments), calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Update saves a new body for the comment and records when it was edited.
+// Deleted and hidden comments cannot be edited.
+func (m CommentModel) Update(comment *Comment) error {
+	query := `
+	UPDATE comments
+	SET body = $1, edited_at = NOW()
+	WHERE id = $2 AND deleted_at IS NULL AND hidden_at IS NULL
+	RETURNING edited_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, comment.Body, comment.ID).Scan(&comment.EditedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete marks the comment as deleted and clears its body.
+func (m CommentModel) Delete(id int64) error {
+	query := `
+	UPDATE comments
+	SET body = '', deleted_at = NOW()
+	WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL`
+
+	return m.exec(query, id)
+}
+
+// Hide takes a comment down on a moderator's behalf. Hiding a comment which
+// is already deleted or hidden is not an error.
+func (m CommentModel) Hide(id int64) error {
+	query := `
+	UPDATE comments
+	SET body = '', hidden_at = coalesce(hidden_at, NOW())
+	WHERE id = $1`
+
+	return m.exec(query, id)
+}
+
+// exec runs an update of a single comment, returning ErrRecordNotFound if
+// it did not match.
+func (m CommentModel) exec(query string, id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (c *Comment) dest() []any {
+	return []any{&c.ID, &c.CreatedAt, &c.EditedAt, &c.DeletedAt, &c.HiddenAt, &c.MovieID, &c.UserID, &c.ParentID, &c.ThreadID, &c.Depth, &c.Body}
+}
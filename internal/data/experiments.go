@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ExperimentExposure records the first time a subject was assigned a
+// variant of an experiment. Subjects are users, or anonymous clients
+// identified by an ID of their own choosing.
+type ExperimentExposure struct {
+	Experiment string
+	Subject    string
+	Variant    string
+	UserID     int64
+	ExposedAt  time.Time
+}
+
+// VariantExposures counts the subjects exposed to a variant.
+type VariantExposures struct {
+	Variant  string `json:"variant"`
+	Subjects int    `json:"subjects"`
+}
+
+type ExperimentModel struct {
+	DB *sql.DB
+}
+
+// Record adds the exposure unless the subject has already been exposed to
+// the experiment, in which case the first exposure is kept.
+func (m ExperimentModel) Record(exposure *ExperimentExposure) error {
+	query := `
+	INSERT INTO experiment_exposures (experiment, subject, variant, user_id)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (experiment, subject) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	userID := sql.NullInt64{Int64: exposure.UserID, Valid: exposure.UserID > 0}
+
+	_, err := m.DB.ExecContext(ctx, query, exposure.Experiment, exposure.Subject, exposure.Variant, userID)
+	return err
+}
+
+// Counts returns the number of subjects exposed to each variant of the
+// experiment, ordered by variant.
+func (m ExperimentModel) Counts(experiment string) ([]*VariantExposures, error) {
+	query := `
+	SELECT variant, count(*)
+	FROM experiment_exposures
+	WHERE experiment = $1
+	GROUP BY variant
+	ORDER BY variant`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, experiment)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*VariantExposures{}
+
+	for rows.Next() {
+		var count VariantExposures
+
+		err := rows.Scan(&count.Variant, &count.Subjects)
+		if err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, &count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
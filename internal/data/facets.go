@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Facets holds the number of movies per genre and per decade among all the
+// movies matching a MovieQuery, ignoring pagination. Decades are keyed by
+// their first year, e.g. 1990.
+type Facets struct {
+	Genres  map[string]int `json:"genres"`
+	Decades map[int]int    `json:"decades"`
+}
+
+func (m MovieModel) GetFacets(q MovieQuery) (*Facets, error) {
+	return retryRead("Movies.GetFacets", func() (*Facets, error) { return m.getFacets(q) })
+}
+
+func (m MovieModel) getFacets(q MovieQuery) (*Facets, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	facets := &Facets{Genres: map[string]int{}, Decades: map[int]int{}}
+
+	query := `
+	SELECT genre, count(*)
+	FROM movies CROSS JOIN unnest(genres) AS genre` + movieQueryWhere + `
+	GROUP BY genre`
+
+	rows, err := m.DB.QueryContext(ctx, query, q.args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			genre string
+			count int
+		)
+		if err := rows.Scan(&genre, &count); err != nil {
+			return nil, err
+		}
+		facets.Genres[genre] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+	SELECT (year / 10) * 10 AS decade, count(*)
+	FROM movies` + movieQueryWhere + `
+	AND year IS NOT NULL
+	GROUP BY decade`
+
+	rows, err = m.DB.QueryContext(ctx, query, q.args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var decade, count int
+		if err := rows.Scan(&decade, &count); err != nil {
+			return nil, err
+		}
+		facets.Decades[decade] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
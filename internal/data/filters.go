@@ -3,6 +3,8 @@ package data
 import "greenlight.bcc/internal/validator"
 import "strings"
 import "math"
+import "time"
+import "database/sql"
 
 type Filters struct {
 	Page         int
@@ -45,11 +47,12 @@ func (f Filters) offset() int {
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int     `json:"current_page,omitempty"`
+	PageSize     int     `json:"page_size,omitempty"`
+	FirstPage    int     `json:"first_page,omitempty"`
+	LastPage     int     `json:"last_page,omitempty"`
+	TotalRecords int     `json:"total_records,omitempty"`
+	Facets       *Facets `json:"facets,omitempty"`
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -64,3 +67,29 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 		TotalRecords: totalRecords,
 	}
 }
+
+// CreatedRange narrows a listing to records created strictly after After
+// and strictly before Before. A zero time leaves that end open.
+type CreatedRange struct {
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func ValidateCreatedRange(v *validator.Validator, r CreatedRange) {
+	if !r.CreatedAfter.IsZero() && !r.CreatedBefore.IsZero() {
+		v.Check(r.CreatedAfter.Before(r.CreatedBefore), "created_before", "must be later than created_after")
+	}
+}
+
+// contains reports whether a record created at t is in the range.
+func (r CreatedRange) contains(t time.Time) bool {
+	return (r.CreatedAfter.IsZero() || t.After(r.CreatedAfter)) && (r.CreatedBefore.IsZero() || t.Before(r.CreatedBefore))
+}
+
+// args returns the range's ends as SQL parameters, NULL when open.
+func (r CreatedRange) args() []any {
+	return []any{
+		sql.NullTime{Time: r.CreatedAfter, Valid: !r.CreatedAfter.IsZero()},
+		sql.NullTime{Time: r.CreatedBefore, Valid: !r.CreatedBefore.IsZero()},
+	}
+}
@@ -0,0 +1,241 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+var ErrAlreadyFollowing = errors.New("already following")
+
+// Follow is a user in a list of followers or of followed users, with the
+// time the follow began.
+type Follow struct {
+	UserID     int64     `json:"user_id"`
+	Name       string    `json:"name"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type GenreFollow struct {
+	Genre      string    `json:"genre"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+func ValidateFollow(v *validator.Validator, followerID, followedID int64) {
+	v.Check(followerID != followedID, "user_id", "must not be yourself")
+}
+
+func ValidateGenreFollow(v *validator.Validator, genre string) {
+	v.Check(genre != "", "genre", "must be provided")
+	v.Check(len(genre) <= 100, "genre", "must not be more than 100 bytes long")
+}
+
+type FollowModel struct {
+	DB *sql.DB
+}
+
+// Insert makes followerID follow followedID and updates both users'
+// counts. It returns ErrAlreadyFollowing if the follow already exists.
+func (m FollowModel) Insert(followerID, followedID int64) error {
+	return m.update(followerID, followedID, 1, `
+	INSERT INTO user_follows (follower_id, followed_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`)
+}
+
+// Delete ends a follow and updates both users' counts. It returns
+// ErrRecordNotFound if followerID does not follow followedID.
+func (m FollowModel) Delete(followerID, followedID int64) error {
+	return m.update(followerID, followedID, -1, `
+	DELETE FROM user_follows
+	WHERE follower_id = $1 AND followed_id = $2`)
+}
+
+// update runs query, which inserts or deletes a follow, and adds delta to
+// the counts if it did. Both happen in one transaction so the counts cannot
+// drift from the follows.
+func (m FollowModel) update(followerID, followedID int64, delta int, query string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, followerID, followedID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		if delta > 0 {
+			return ErrAlreadyFollowing
+		}
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET following_count = following_count + $2 WHERE id = $1`, followerID, delta)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET followers_count = followers_count + $2 WHERE id = $1`, followedID, delta)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetFollowers returns the users following userID, most recent first.
+func (m FollowModel) GetFollowers(userID int64, filters Filters) ([]*Follow, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), users.id, users.name, user_follows.created_at
+	FROM user_follows
+	INNER JOIN users ON users.id = user_follows.follower_id
+	WHERE user_follows.followed_id = $1
+	ORDER BY user_follows.created_at DESC, users.id DESC
+	LIMIT $2 OFFSET $3`
+
+	return m.getAll(query, userID, filters)
+}
+
+// GetFollowing returns the users userID follows, most recent first.
+func (m FollowModel) GetFollowing(userID int64, filters Filters) ([]*Follow, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), users.id, users.name, user_follows.created_at
+	FROM user_follows
+	INNER JOIN users ON users.id = user_follows.followed_id
+	WHERE user_follows.follower_id = $1
+	ORDER BY user_follows.created_at DESC, users.id DESC
+	LIMIT $2 OFFSET $3`
+
+	return m.getAll(query, userID, filters)
+}
+
+func (m FollowModel) getAll(query string, userID int64, filters Filters) ([]*Follow, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	follows := []*Follow{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var follow Follow
+
+		err := rows.Scan(&totalRecords, &follow.UserID, &follow.Name, &follow.FollowedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		follows = append(follows, &follow)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return follows, metadata, nil
+}
+
+// InsertGenre makes userID follow genre. Following a genre twice is not an
+// error.
+func (m FollowModel) InsertGenre(userID int64, genre string) (*GenreFollow, error) {
+	query := `
+	INSERT INTO genre_follows (user_id, genre)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id, genre) DO UPDATE SET genre = EXCLUDED.genre
+	RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	follow := &GenreFollow{Genre: genre}
+
+	err := m.DB.QueryRowContext(ctx, query, userID, genre).Scan(&follow.FollowedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return follow, nil
+}
+
+func (m FollowModel) DeleteGenre(userID int64, genre string) error {
+	query := `
+	DELETE FROM genre_follows
+	WHERE user_id = $1 AND genre = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, genre)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetGenres returns the genres userID follows in alphabetical order.
+func (m FollowModel) GetGenres(userID int64) ([]*GenreFollow, error) {
+	query := `
+	SELECT genre, created_at
+	FROM genre_follows
+	WHERE user_id = $1
+	ORDER BY genre`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	follows := []*GenreFollow{}
+
+	for rows.Next() {
+		var follow GenreFollow
+
+		err := rows.Scan(&follow.Genre, &follow.FollowedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		follows = append(follows, &follow)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return follows, nil
+}
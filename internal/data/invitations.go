@@ -0,0 +1,155 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight.bcc/internal/fieldcrypt"
+	"greenlight.bcc/internal/validator"
+)
+
+var ErrInvalidInvitation = errors.New("invalid invitation")
+
+type Invitation struct {
+	Plaintext string    `json:"-"`
+	Hash      []byte    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedBy int64     `json:"created_by"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+func ValidateInvitationCode(v *validator.Validator, code string) {
+	v.Check(code != "", "invitation_code", "must be provided")
+	v.Check(len(code) == 26, "invitation_code", "must be 26 bytes long")
+}
+
+type InvitationModel struct {
+	DB *sql.DB
+	// Keys, if set, encrypts email addresses at rest in the same way as
+	// UserModel's: email_encrypted holds the sealed address and email its
+	// blind index. Invitations expire, so rows written without keys are
+	// not re-encrypted but age out.
+	Keys *fieldcrypt.Keyring
+}
+
+// invitationEmailContext is authenticated with every sealed invitation
+// address.
+const invitationEmailContext = "invitations.email"
+
+// storedEmail returns the values to store in the email and email_encrypted
+// columns for email.
+func (m InvitationModel) storedEmail(email string) (string, string, error) {
+	if m.Keys == nil {
+		return email, "", nil
+	}
+
+	sealed, err := m.Keys.Seal(email, invitationEmailContext)
+	if err != nil {
+		return "", "", err
+	}
+
+	return m.Keys.BlindIndex(email), sealed, nil
+}
+
+func (m InvitationModel) New(email string, createdBy int64, ttl time.Duration) (*Invitation, error) {
+	invitation := &Invitation{
+		Email:     email,
+		CreatedBy: createdBy,
+		Expiry:    time.Now().Add(ttl),
+	}
+
+	var err error
+	invitation.Plaintext, invitation.Hash, err = generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(invitation)
+	return invitation, err
+}
+
+func (m InvitationModel) Insert(invitation *Invitation) error {
+	email, emailEncrypted, err := m.storedEmail(invitation.Email)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO invitations (hash, email, email_encrypted, created_by, expiry)
+	VALUES ($1, $2, $3, $4, $5)`
+	args := []any{invitation.Hash, email, emailEncrypted, invitation.CreatedBy, invitation.Expiry}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// InsertWithInvitation creates the user and marks the invitation as used in
+// a single transaction. The invitation must be unused, unexpired and issued
+// for the user's email address, otherwise ErrInvalidInvitation is returned
+// and the user is not created.
+func (m UserModel) InsertWithInvitation(user *User, code string) error {
+	email, emailEncrypted, err := m.storedEmail(user.Email)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO users (name, email, email_encrypted, locale, password_hash, activated)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at, version`
+	args := []any{user.Name, email, emailEncrypted, user.Locale, user.Password.hash, user.Activated}
+
+	err = m.checkPlaintextEmail(ctx, tx, user.Email, 0)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "users_email_key"):
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	hash := sha256.Sum256([]byte(code))
+
+	// The invitation's email column holds the address or, if it was
+	// encrypted, its blind index, which is the same as a user's.
+	query = `
+	UPDATE invitations
+	SET used_by = $1, used_at = NOW()
+	WHERE hash = $2 AND email IN ($3, $4) AND used_at IS NULL AND expiry > NOW()`
+
+	plaintext, index := m.emailLookup(user.Email)
+	result, err := tx.ExecContext(ctx, query, user.ID, hash[:], plaintext, index)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrInvalidInvitation
+	}
+
+	return tx.Commit()
+}
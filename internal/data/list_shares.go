@@ -0,0 +1,84 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidShareToken is returned for share tokens which are malformed,
+// forged, expired or signed with a secret which has since been rotated.
+var ErrInvalidShareToken = errors.New("invalid share token")
+
+// A share token lets anyone read a list until it expires, without signing
+// in. It has the form <list id>.<expiry>.<signature>, the signature being an
+// HMAC-SHA256 of the list ID and expiry keyed with the list's share secret.
+// Rotating the secret revokes every token issued for the list.
+
+func newShareSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	return secret, err
+}
+
+func shareSignature(secret []byte, listID int64, expiry time.Time) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(listID, 10) + "." + strconv.FormatInt(expiry.Unix(), 10)))
+	return mac.Sum(nil)
+}
+
+// ShareToken returns a token for the list which expires at expiry. The list
+// must have a share secret.
+func (l *List) ShareToken(expiry time.Time) string {
+	return strconv.FormatInt(l.ID, 10) + "." +
+		strconv.FormatInt(expiry.Unix(), 10) + "." +
+		base64.RawURLEncoding.EncodeToString(shareSignature(l.ShareSecret, l.ID, expiry))
+}
+
+// ParseShareToken returns the ID of the list a token was issued for. The
+// token still has to be checked with VerifyShareToken once the list has
+// been loaded.
+func ParseShareToken(token string) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidShareToken
+	}
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || id < 1 {
+		return 0, ErrInvalidShareToken
+	}
+
+	return id, nil
+}
+
+// VerifyShareToken checks that token was signed with the list's current
+// share secret and has not expired.
+func (l *List) VerifyShareToken(token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || l.ShareSecret == nil || parts[0] != strconv.FormatInt(l.ID, 10) {
+		return ErrInvalidShareToken
+	}
+
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ErrInvalidShareToken
+	}
+	expiry := time.Unix(unix, 0)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, shareSignature(l.ShareSecret, l.ID, expiry)) {
+		return ErrInvalidShareToken
+	}
+
+	if !now.Before(expiry) {
+		return ErrInvalidShareToken
+	}
+
+	return nil
+}
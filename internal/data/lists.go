@@ -0,0 +1,543 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.bcc/internal/validator"
+)
+
+var ErrDuplicateListItem = errors.New("duplicate list item")
+
+// List is a user's ordered selection of movies. Lists belong to the
+// organization they were made in, and public lists can be browsed by its
+// other members.
+type List struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserID      int64     `json:"user_id"`
+	OrgID       int64     `json:"-"`
+	Name        string    `json:"name" validate:"required,max=100"`
+	Description string    `json:"description" validate:"max=1000"`
+	Public      bool      `json:"public"`
+	ItemCount   int       `json:"item_count"`
+	Version     int32     `json:"version"`
+	// ShareSecret keys the signatures of the list's share tokens. It is nil
+	// until the list is first shared.
+	ShareSecret []byte `json:"-"`
+}
+
+// MovieList is one of a user's lists a movie is on, as included with the
+// movie.
+type MovieList struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+}
+
+// ListItem is a movie on a list. Positions start at 1 and have no gaps.
+type ListItem struct {
+	MovieID  int64     `json:"movie_id"`
+	Title    string    `json:"title"`
+	Year     int32     `json:"year,omitempty"`
+	Position int       `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	v.Struct(list)
+}
+
+// clampPosition fits a requested position into 1..max. A position of 0
+// means the end of the list.
+func clampPosition(position, max int) int {
+	if position < 1 || position > max {
+		return max
+	}
+	return position
+}
+
+type ListModel struct {
+	DB *sql.DB
+}
+
+func (m ListModel) Insert(list *List) error {
+	query := `
+	INSERT INTO lists (user_id, org_id, name, description, public)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, version`
+
+	args := []any{list.UserID, list.OrgID, list.Name, list.Description, list.Public}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.Version)
+}
+
+const listColumns = `id, created_at, user_id, org_id, name, description, public, version,
+	(SELECT count(*) FROM list_items WHERE list_items.list_id = lists.id), share_secret`
+
+func (l *List) dest() []any {
+	return []any{&l.ID, &l.CreatedAt, &l.UserID, &l.OrgID, &l.Name, &l.Description, &l.Public, &l.Version, &l.ItemCount, &l.ShareSecret}
+}
+
+func (m ListModel) Get(id int64) (*List, error) {
+	query := `
+	SELECT ` + listColumns + `
+	FROM lists
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var list List
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(list.dest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &list, nil
+}
+
+// GetAllForUser returns the user's own lists, public or not, newest first.
+func (m ListModel) GetAllForUser(userID int64, filters Filters) ([]*List, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), ` + listColumns + `
+	FROM lists
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+
+	return m.getAll(query, userID, filters)
+}
+
+// GetPublic returns the public lists of an organization, newest first.
+func (m ListModel) GetPublic(orgID int64, filters Filters) ([]*List, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), ` + listColumns + `
+	FROM lists
+	WHERE org_id = $1 AND public
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+
+	return m.getAll(query, orgID, filters)
+}
+
+func (m ListModel) getAll(query string, id int64, filters Filters) ([]*List, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var list List
+
+		err := rows.Scan(append([]any{&totalRecords}, list.dest()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		lists = append(lists, &list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return lists, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m ListModel) Update(list *List) error {
+	query := `
+	UPDATE lists
+	SET name = $1, description = $2, public = $3, version = version + 1
+	WHERE id = $4 AND version = $5
+	RETURNING version`
+
+	args := []any{list.Name, list.Description, list.Public, list.ID, list.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the list and records a tombstone for it in the same
+// transaction.
+func (m ListModel) Delete(id, deletedBy int64) error {
+	query := `
+	DELETE FROM lists
+	WHERE id = $1
+	RETURNING org_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var orgID int64
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&orgID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = insertTombstoneTx(ctx, tx, &Tombstone{ResourceType: TombstoneList, ResourceID: id, OrgID: orgID, DeletedBy: deletedBy})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RotateShareSecret gives the list a new share secret, which revokes the
+// tokens signed with the previous one.
+func (m ListModel) RotateShareSecret(list *List) error {
+	secret, err := newShareSecret()
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE lists
+	SET share_secret = $1
+	WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, secret, list.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	list.ShareSecret = secret
+	return nil
+}
+
+// GetItems returns a page of the list's movies in list order.
+func (m ListModel) GetItems(listID int64, filters Filters) ([]*ListItem, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), list_items.movie_id, movies.title, coalesce(movies.year, 0),
+		row_number() OVER(ORDER BY list_items.position, list_items.added_at), list_items.added_at
+	FROM list_items
+	INNER JOIN movies ON movies.id = list_items.movie_id
+	WHERE list_items.list_id = $1
+	ORDER BY list_items.position, list_items.added_at
+	LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	items := []*ListItem{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var item ListItem
+
+		err := rows.Scan(&totalRecords, &item.MovieID, &item.Title, &item.Year, &item.Position, &item.AddedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return items, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// lockItems locks the list against concurrent changes to its items and
+// closes any gaps left in the positions by movies which have since been
+// deleted. It returns the number of items on the list.
+func (m ListModel) lockItems(ctx context.Context, tx *sql.Tx, listID int64) (int, error) {
+	var id int64
+
+	err := tx.QueryRowContext(ctx, `SELECT id FROM lists WHERE id = $1 FOR UPDATE`, listID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	query := `
+	UPDATE list_items
+	SET position = numbered.position
+	FROM (
+		SELECT movie_id, row_number() OVER(ORDER BY position, added_at) AS position
+		FROM list_items
+		WHERE list_id = $1
+	) numbered
+	WHERE list_items.list_id = $1 AND list_items.movie_id = numbered.movie_id`
+
+	result, err := tx.ExecContext(ctx, query, listID)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// AddItem puts the movie on the list at position, moving the items from
+// there on down by one. A position of 0 or past the end appends the movie.
+func (m ListModel) AddItem(listID, movieID int64, position int) (*ListItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	count, err := m.lockItems(ctx, tx, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &ListItem{MovieID: movieID, Position: clampPosition(position, count+1)}
+
+	_, err = tx.ExecContext(ctx, `UPDATE list_items SET position = position + 1 WHERE list_id = $1 AND position >= $2`, listID, item.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO list_items (list_id, movie_id, position)
+	VALUES ($1, $2, $3)
+	RETURNING added_at, (SELECT title FROM movies WHERE id = $2), (SELECT coalesce(year, 0) FROM movies WHERE id = $2)`
+
+	err = tx.QueryRowContext(ctx, query, listID, movieID, item.Position).Scan(&item.AddedAt, &item.Title, &item.Year)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "list_items_pkey"):
+			return nil, ErrDuplicateListItem
+		default:
+			return nil, err
+		}
+	}
+
+	return item, tx.Commit()
+}
+
+// MoveItem moves the movie to position, shifting the items in between. A
+// position of 0 or past the end moves it to the end.
+func (m ListModel) MoveItem(listID, movieID int64, position int) (*ListItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	count, err := m.lockItems(ctx, tx, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &ListItem{MovieID: movieID}
+
+	query := `
+	SELECT list_items.position, list_items.added_at, movies.title, coalesce(movies.year, 0)
+	FROM list_items
+	INNER JOIN movies ON movies.id = list_items.movie_id
+	WHERE list_items.list_id = $1 AND list_items.movie_id = $2`
+
+	var from int
+
+	err = tx.QueryRowContext(ctx, query, listID, movieID).Scan(&from, &item.AddedAt, &item.Title, &item.Year)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	item.Position = clampPosition(position, count)
+
+	query = `
+	UPDATE list_items
+	SET position = CASE
+		WHEN movie_id = $2 THEN $4
+		WHEN $3 < $4 THEN position - 1
+		ELSE position + 1
+	END
+	WHERE list_id = $1 AND position BETWEEN least($3, $4) AND greatest($3, $4)`
+
+	_, err = tx.ExecContext(ctx, query, listID, movieID, from, item.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, tx.Commit()
+}
+
+func (m ListModel) RemoveItem(listID, movieID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = m.lockItems(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	var position int
+
+	err = tx.QueryRowContext(ctx, `DELETE FROM list_items WHERE list_id = $1 AND movie_id = $2 RETURNING position`, listID, movieID).Scan(&position)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE list_items SET position = position - 1 WHERE list_id = $1 AND position > $2`, listID, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetForMovies returns the user's lists which include each of the movies, by
+// movie ID, in one query.
+func (m ListModel) GetForMovies(userID int64, movieIDs []int64) (map[int64][]*MovieList, error) {
+	lists := make(map[int64][]*MovieList, len(movieIDs))
+	if userID < 1 || len(movieIDs) == 0 {
+		return lists, nil
+	}
+
+	query := `
+	SELECT list_items.movie_id, lists.id, lists.name, list_items.position
+	FROM list_items
+	INNER JOIN lists ON lists.id = list_items.list_id
+	WHERE lists.user_id = $1 AND list_items.movie_id = ANY($2)
+	ORDER BY lists.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movieID int64
+		var list MovieList
+
+		err := rows.Scan(&movieID, &list.ID, &list.Name, &list.Position)
+		if err != nil {
+			return nil, err
+		}
+
+		lists[movieID] = append(lists[movieID], &list)
+	}
+
+	return lists, rows.Err()
+}
+
+// GetOwnersOfMovie returns the IDs of the users with a list which includes
+// the movie.
+func (m ListModel) GetOwnersOfMovie(movieID int64) ([]int64, error) {
+	query := `
+	SELECT DISTINCT lists.user_id
+	FROM list_items
+	INNER JOIN lists ON lists.id = list_items.list_id
+	WHERE list_items.movie_id = $1
+	ORDER BY lists.user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []int64{}
+
+	for rows.Next() {
+		var userID int64
+
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
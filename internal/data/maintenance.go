@@ -0,0 +1,44 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MaintenanceTasks are the database maintenance operations administrators
+// can run, each made of statements run one after another. None of them may
+// run inside a transaction.
+var MaintenanceTasks = map[string][]string{
+	"reindex-search": {
+		"REINDEX INDEX CONCURRENTLY movies_search_vector_idx",
+		"REINDEX INDEX CONCURRENTLY movies_genres_idx",
+	},
+	"refresh-rankings": {
+		"REFRESH MATERIALIZED VIEW CONCURRENTLY movie_rankings",
+	},
+	"vacuum-analyze": {
+		"VACUUM ANALYZE movies",
+		"VACUUM ANALYZE users",
+		"VACUUM ANALYZE tokens",
+		"VACUUM ANALYZE activities",
+		"VACUUM ANALYZE usage",
+	},
+}
+
+// maintenanceTimeout bounds a single maintenance statement, which may take
+// much longer than a request's queries. It stands in for the connection's
+// statement_timeout too.
+const maintenanceTimeout = 30 * time.Minute
+
+type MaintenanceModel struct {
+	DB *sql.DB
+}
+
+// Exec runs one statement of a maintenance task.
+func (m MaintenanceModel) Exec(statement string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), maintenanceTimeout)
+	defer cancel()
+
+	return execWithStatementTimeout(ctx, m.DB, maintenanceTimeout, statement)
+}
@@ -0,0 +1,1283 @@
+package data
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore holds the state shared by the in-memory models. A single lock
+// guards everything, which keeps operations spanning several tables, such as
+// creating a user with an invitation, as atomic as their SQL counterparts.
+type memoryStore struct {
+	mu sync.Mutex
+
+	nextID int64
+
+	movies         map[int64]*Movie
+	revisions      map[int64][]*MovieRevision
+	translations   map[int64]map[string]*MovieTranslation
+	videos         []*MovieVideo
+	collections    map[int64]*memoryCollection
+	moviesModified time.Time
+
+	users       map[int64]*memoryUser
+	tokens      []*Token
+	invitations []*memoryInvitation
+	orgs        map[int64]*Organization
+	memberships []*memoryMembership
+	usage       map[usageKey]*UsageRecord
+	permissions map[int64]Permissions
+
+	notifications []*Notification
+	comments      []*Comment
+	reports       []*Report
+	blocks        []*Block
+	lists         map[int64]*memoryList
+	follows       []*memoryFollow
+	genreFollows  []*memoryGenreFollow
+	uploads       map[int64]*Upload
+	activities    []*Activity
+	tombstones    []*Tombstone
+	exposures     map[string]*ExperimentExposure
+	security      []*SecurityEvent
+	webhooks      []*Webhook
+}
+
+type memoryUser struct {
+	user          User
+	prefs         NotificationPreferences
+	privacy       string
+	undeliverable bool
+}
+
+type memoryInvitation struct {
+	invitation Invitation
+	usedBy     int64
+}
+
+type memoryMembership struct {
+	orgID  int64
+	userID int64
+	role   string
+}
+
+type usageKey struct {
+	day    string
+	userID int64
+	orgID  int64
+}
+
+// permissionCodes are the permissions which exist in a migrated database.
+// AddForUser ignores any other code, as the SQL version does.
+var permissionCodes = []string{"movies:read", "movies:write", "movies:publish", "content:moderate", "admin:read", "admin:write", "admin:impersonate"}
+
+// NewMemoryModels returns models which keep everything in memory. They
+// behave like the PostgreSQL models, including versioning and filtering, so
+// they can stand in for a database in tests and in development. Like a
+// migrated database, they start with a single organization with ID 1.
+func NewMemoryModels() Models {
+	s := &memoryStore{
+		movies:       make(map[int64]*Movie),
+		revisions:    make(map[int64][]*MovieRevision),
+		translations: make(map[int64]map[string]*MovieTranslation),
+		collections:  make(map[int64]*memoryCollection),
+		users:        make(map[int64]*memoryUser),
+		orgs:         make(map[int64]*Organization),
+		usage:        make(map[usageKey]*UsageRecord),
+		permissions:  make(map[int64]Permissions),
+		lists:        make(map[int64]*memoryList),
+		uploads:      make(map[int64]*Upload),
+		exposures:    make(map[string]*ExperimentExposure),
+	}
+
+	s.orgs[1] = &Organization{ID: 1, CreatedAt: time.Now(), Name: "Default"}
+	s.nextID = 1
+	s.moviesModified = time.Now()
+
+	return Models{
+		Movies:            MemoryMovieModel{s},
+		MovieRankings:     MemoryMovieRankingModel{s},
+		MovieRevisions:    MemoryMovieRevisionModel{s},
+		MovieTranslations: MemoryMovieTranslationModel{s},
+		MovieVideos:       MemoryMovieVideoModel{s},
+		Collections:       MemoryCollectionModel{s},
+		Users:             MemoryUserModel{s},
+		Tokens:            MemoryTokenModel{s},
+		Invitations:       MemoryInvitationModel{s},
+		Organizations:     MemoryOrganizationModel{s},
+		Usage:             MemoryUsageModel{s},
+		Permissions:       MemoryPermissionModel{s},
+		Notifications:     MemoryNotificationModel{s},
+		Comments:          MemoryCommentModel{s},
+		Reports:           MemoryReportModel{s},
+		Blocks:            MemoryBlockModel{s},
+		Lists:             MemoryListModel{s},
+		Follows:           MemoryFollowModel{s},
+		Profiles:          MemoryProfileModel{s},
+		Uploads:           MemoryUploadModel{s},
+		Tombstones:        MemoryTombstoneModel{s},
+		Maintenance:       MemoryMaintenanceModel{},
+		Activities:        MemoryActivityModel{s},
+		Experiments:       MemoryExperimentModel{s},
+		SecurityEvents:    MemorySecurityEventModel{s},
+		Webhooks:          MemoryWebhookModel{s},
+	}
+}
+
+// id returns the next identifier. IDs are shared between tables, which is
+// harmless and makes mixing them up in tests fail loudly.
+func (s *memoryStore) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+func paginate[T any](records []T, filters Filters) ([]T, Metadata) {
+	metadata := calculateMetadata(len(records), filters.Page, filters.PageSize)
+
+	start := filters.offset()
+	if start > len(records) {
+		start = len(records)
+	}
+	end := start + filters.limit()
+	if end > len(records) {
+		end = len(records)
+	}
+
+	return records[start:end], metadata
+}
+
+type MemoryUserModel struct {
+	s *memoryStore
+}
+
+func (m MemoryUserModel) insert(user *User) error {
+	for _, existing := range m.s.users {
+		if existing.user.Email == user.Email {
+			return ErrDuplicateEmail
+		}
+	}
+
+	user.ID = m.s.id()
+	user.CreatedAt = time.Now()
+	user.Version = 1
+
+	stored := *user
+	stored.Password.plaintext = nil
+	m.s.users[user.ID] = &memoryUser{user: stored, prefs: DefaultNotificationPreferences(), privacy: PrivacyPublic}
+
+	return nil
+}
+
+func (m MemoryUserModel) Insert(user *User) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.insert(user)
+}
+
+func (m MemoryUserModel) InsertWithInvitation(user *User, code string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	hash := sha256.Sum256([]byte(code))
+
+	var match *memoryInvitation
+	for _, inv := range m.s.invitations {
+		if string(inv.invitation.Hash) == string(hash[:]) && inv.invitation.Email == user.Email && inv.usedBy == 0 && inv.invitation.Expiry.After(time.Now()) {
+			match = inv
+			break
+		}
+	}
+
+	for _, existing := range m.s.users {
+		if existing.user.Email == user.Email {
+			return ErrDuplicateEmail
+		}
+	}
+
+	if match == nil {
+		return ErrInvalidInvitation
+	}
+
+	err := m.insert(user)
+	if err != nil {
+		return err
+	}
+
+	match.usedBy = user.ID
+
+	return nil
+}
+
+func (m MemoryUserModel) Get(id int64) (*User, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	user := stored.user
+	return &user, nil
+}
+
+func (m MemoryUserModel) GetAll(created CreatedRange, filters Filters) ([]*User, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	users := []*User{}
+	for _, stored := range m.s.users {
+		if created.contains(stored.user.CreatedAt) {
+			user := stored.user
+			users = append(users, &user)
+		}
+	}
+
+	column, desc := filters.sortColumn(), filters.sortDirection() == "DESC"
+
+	sort.Slice(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+
+		var less, equal bool
+		switch column {
+		case "created_at":
+			less, equal = a.CreatedAt.Before(b.CreatedAt), a.CreatedAt.Equal(b.CreatedAt)
+		default:
+			less, equal = a.ID < b.ID, a.ID == b.ID
+		}
+
+		if equal {
+			return a.ID < b.ID
+		}
+		return less != desc
+	})
+
+	page, metadata := paginate(users, filters)
+	return page, metadata, nil
+}
+
+func (m MemoryUserModel) GetByEmail(email string) (*User, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.users {
+		if stored.user.Email == email {
+			user := stored.user
+			return &user, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryUserModel) Update(user *User) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[user.ID]
+	if !ok || stored.user.Version != user.Version {
+		return ErrEditConflict
+	}
+
+	for id, existing := range m.s.users {
+		if id != user.ID && existing.user.Email == user.Email {
+			return ErrDuplicateEmail
+		}
+	}
+
+	user.Version++
+
+	followers, following := stored.user.FollowersCount, stored.user.FollowingCount
+
+	stored.user = *user
+	stored.user.Password.plaintext = nil
+	stored.user.FollowersCount = followers
+	stored.user.FollowingCount = following
+	stored.user.ImpersonatorID = 0
+	stored.user.OrgID = 0
+
+	return nil
+}
+
+func (m MemoryUserModel) RehashPassword(user *User, plaintextPassword string) error {
+	previous := user.Password.hash
+
+	err := user.Password.Set(plaintextPassword)
+	if err != nil {
+		return err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[user.ID]
+	if ok && bytes.Equal(stored.user.Password.hash, previous) {
+		stored.user.Password.hash = user.Password.hash
+	}
+
+	return nil
+}
+
+func (m MemoryUserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	for _, token := range m.s.tokens {
+		if string(token.Hash) != string(hash[:]) || token.Scope != tokenScope || !token.Expiry.After(time.Now()) {
+			continue
+		}
+
+		stored, ok := m.s.users[token.UserID]
+		if !ok {
+			return nil, ErrRecordNotFound
+		}
+
+		user := stored.user
+		user.ImpersonatorID = token.ImpersonatorID
+		user.OrgID = token.OrgID
+		return &user, nil
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryUserModel) MarkEmailUndeliverable(email string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.users {
+		if stored.user.Email == email && !stored.undeliverable {
+			stored.undeliverable = true
+			stored.user.Version++
+		}
+	}
+
+	return nil
+}
+
+func (m MemoryUserModel) Ban(id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	stored.user.Banned = true
+	stored.user.Version++
+
+	return nil
+}
+
+func (m MemoryUserModel) EmailUndeliverable(email string) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.users {
+		if stored.user.Email == email {
+			return stored.undeliverable, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m MemoryUserModel) GetPreferences(userID int64) (*NotificationPreferences, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[userID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	prefs := stored.prefs
+	return &prefs, nil
+}
+
+func (m MemoryUserModel) UpdatePreferences(userID int64, prefs *NotificationPreferences) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[userID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	stored.prefs = *prefs
+
+	return nil
+}
+
+func (m MemoryUserModel) EmailAllowed(email, category string) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.users {
+		if stored.user.Email == email {
+			return stored.prefs.Allows(category), nil
+		}
+	}
+
+	return true, nil
+}
+
+// The memory store keeps nothing at rest, so there is nothing to encrypt.
+func (m MemoryUserModel) CountStaleEncryption() (int, error) {
+	return 0, nil
+}
+
+func (m MemoryUserModel) ReencryptBatch(limit int) (int, error) {
+	return 0, nil
+}
+
+type MemoryTokenModel struct {
+	s *memoryStore
+}
+
+func (m MemoryTokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m MemoryTokenModel) NewAuthentication(userID, orgID int64, ttl time.Duration) (*Token, error) {
+	return m.NewSession(userID, orgID, ttl, Device{})
+}
+
+func (m MemoryTokenModel) NewSession(userID, orgID int64, ttl time.Duration, device Device) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	token.OrgID = orgID
+	token.Device = device
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m MemoryTokenModel) NewImpersonation(userID, impersonatorID, orgID int64, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	token.ImpersonatorID = impersonatorID
+	token.OrgID = orgID
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m MemoryTokenModel) Insert(token *Token) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.users[token.UserID]; !ok {
+		return ErrRecordNotFound
+	}
+
+	stored := *token
+	stored.Plaintext = ""
+	m.s.tokens = append(m.s.tokens, &stored)
+
+	return nil
+}
+
+func (m MemoryTokenModel) DeleteAllForUser(scope string, userID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	tokens := m.s.tokens[:0]
+	for _, token := range m.s.tokens {
+		if token.Scope != scope || token.UserID != userID {
+			tokens = append(tokens, token)
+		}
+	}
+	m.s.tokens = tokens
+
+	return nil
+}
+
+func (m MemoryTokenModel) GetSessions(userID int64) ([]*Session, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	sessions := []*Session{}
+
+	for i := len(m.s.tokens) - 1; i >= 0; i-- {
+		token := m.s.tokens[i]
+		if token.UserID != userID || token.Scope != ScopeAuthentication || token.ImpersonatorID != 0 || !token.Expiry.After(time.Now()) {
+			continue
+		}
+		sessions = append(sessions, newSession(token))
+	}
+
+	return sessions, nil
+}
+
+func (m MemoryTokenModel) IsNewDevice(userID int64, device Device) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	loggedIn := false
+
+	for _, token := range m.s.tokens {
+		if token.UserID != userID || token.Scope != ScopeAuthentication || token.ImpersonatorID != 0 {
+			continue
+		}
+		if token.Device.UserAgent == device.UserAgent && token.Device.Name == device.Name {
+			return false, nil
+		}
+		loggedIn = true
+	}
+
+	return loggedIn, nil
+}
+
+type MemoryInvitationModel struct {
+	s *memoryStore
+}
+
+func (m MemoryInvitationModel) New(email string, createdBy int64, ttl time.Duration) (*Invitation, error) {
+	invitation := &Invitation{
+		Email:     email,
+		CreatedBy: createdBy,
+		Expiry:    time.Now().Add(ttl),
+	}
+
+	var err error
+	invitation.Plaintext, invitation.Hash, err = generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(invitation)
+	return invitation, err
+}
+
+func (m MemoryInvitationModel) Insert(invitation *Invitation) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored := *invitation
+	stored.Plaintext = ""
+	m.s.invitations = append(m.s.invitations, &memoryInvitation{invitation: stored})
+
+	return nil
+}
+
+type MemoryOrganizationModel struct {
+	s *memoryStore
+}
+
+func (m MemoryOrganizationModel) Insert(org *Organization, ownerID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	org.ID = m.s.id()
+	org.CreatedAt = time.Now()
+	org.Role = RoleOwner
+
+	stored := *org
+	stored.Role = ""
+	m.s.orgs[org.ID] = &stored
+	m.s.memberships = append(m.s.memberships, &memoryMembership{orgID: org.ID, userID: ownerID, role: RoleOwner})
+
+	return nil
+}
+
+func (m MemoryOrganizationModel) GetAllForUser(userID int64) ([]*Organization, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	orgs := []*Organization{}
+	for _, membership := range m.s.memberships {
+		if membership.userID == userID {
+			org := *m.s.orgs[membership.orgID]
+			org.Role = membership.role
+			orgs = append(orgs, &org)
+		}
+	}
+
+	return orgs, nil
+}
+
+func (m MemoryOrganizationModel) GetRole(orgID, userID int64) (string, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, membership := range m.s.memberships {
+		if membership.orgID == orgID && membership.userID == userID {
+			return membership.role, nil
+		}
+	}
+
+	return "", ErrRecordNotFound
+}
+
+func (m MemoryOrganizationModel) GetDefaultForUser(userID int64) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, membership := range m.s.memberships {
+		if membership.userID == userID {
+			return membership.orgID, nil
+		}
+	}
+
+	return 0, ErrRecordNotFound
+}
+
+func (m MemoryOrganizationModel) AddMember(orgID, userID int64, role string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.orgs[orgID]; !ok {
+		return ErrRecordNotFound
+	}
+
+	for _, membership := range m.s.memberships {
+		if membership.orgID == orgID && membership.userID == userID {
+			return ErrDuplicateMembership
+		}
+	}
+
+	m.s.memberships = append(m.s.memberships, &memoryMembership{orgID: orgID, userID: userID, role: role})
+
+	return nil
+}
+
+type MemoryUsageModel struct {
+	s *memoryStore
+}
+
+func (m MemoryUsageModel) Add(records []*UsageRecord) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, record := range records {
+		key := usageKey{day: record.Day, userID: record.UserID, orgID: record.OrgID}
+		if existing, ok := m.s.usage[key]; ok {
+			existing.Requests += record.Requests
+			existing.Errors += record.Errors
+			continue
+		}
+		stored := *record
+		m.s.usage[key] = &stored
+	}
+
+	return nil
+}
+
+func (m MemoryUsageModel) GetAll(filter UsageFilter) ([]*UsageRecord, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	from := filter.From.Format("2006-01-02")
+	to := filter.To.Format("2006-01-02")
+
+	records := []*UsageRecord{}
+	for _, stored := range m.s.usage {
+		switch {
+		case filter.UserID != 0 && stored.UserID != filter.UserID:
+		case filter.OrgID != 0 && stored.OrgID != filter.OrgID:
+		case stored.Day < from || stored.Day > to:
+		default:
+			record := *stored
+			records = append(records, &record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.OrgID < b.OrgID
+	})
+
+	return records, nil
+}
+
+type MemoryPermissionModel struct {
+	s *memoryStore
+}
+
+func (m MemoryPermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return append(Permissions(nil), m.s.permissions[userID]...), nil
+}
+
+func (m MemoryPermissionModel) AddForUser(userID int64, codes ...string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.users[userID]; !ok {
+		return ErrRecordNotFound
+	}
+
+	for _, code := range codes {
+		if Permissions(permissionCodes).Include(code) && !m.s.permissions[userID].Include(code) {
+			m.s.permissions[userID] = append(m.s.permissions[userID], code)
+		}
+	}
+
+	return nil
+}
+
+type MemoryNotificationModel struct {
+	s *memoryStore
+}
+
+func (m MemoryNotificationModel) Insert(notification *Notification) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	notification.ID = m.s.id()
+	notification.CreatedAt = time.Now()
+
+	stored := *notification
+	m.s.notifications = append(m.s.notifications, &stored)
+
+	return nil
+}
+
+func (m MemoryNotificationModel) GetAllForUser(userID int64, unreadOnly bool, filters Filters) ([]*Notification, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	notifications := []*Notification{}
+	for i := len(m.s.notifications) - 1; i >= 0; i-- {
+		stored := m.s.notifications[i]
+		if stored.UserID == userID && (stored.ReadAt == nil || !unreadOnly) {
+			notification := *stored
+			notifications = append(notifications, &notification)
+		}
+	}
+
+	page, metadata := paginate(notifications, filters)
+	return page, metadata, nil
+}
+
+func (m MemoryNotificationModel) CountUnread(userID int64) (int, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	count := 0
+	for _, stored := range m.s.notifications {
+		if stored.UserID == userID && stored.ReadAt == nil {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m MemoryNotificationModel) MarkRead(id, userID int64) (*Notification, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.notifications {
+		if stored.ID == id && stored.UserID == userID {
+			if stored.ReadAt == nil {
+				now := time.Now()
+				stored.ReadAt = &now
+			}
+			notification := *stored
+			return &notification, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+type MemoryCommentModel struct {
+	s *memoryStore
+}
+
+func (m MemoryCommentModel) Insert(comment *Comment) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	comment.ID = m.s.id()
+	comment.CreatedAt = time.Now()
+
+	stored := *comment
+	stored.Replies = nil
+	m.s.comments = append(m.s.comments, &stored)
+
+	return nil
+}
+
+func (m MemoryCommentModel) Get(id int64) (*Comment, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.comments {
+		if stored.ID == id {
+			comment := *stored
+			return &comment, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryCommentModel) GetThreads(movieID, viewerID int64, filters Filters) ([]*Comment, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	roots := []*Comment{}
+	for i := len(m.s.comments) - 1; i >= 0; i-- {
+		stored := m.s.comments[i]
+		if stored.MovieID == movieID && stored.ParentID == 0 && !m.s.blocked(viewerID, stored.UserID) {
+			roots = append(roots, stored)
+		}
+	}
+
+	page, metadata := paginate(roots, filters)
+
+	comments := []*Comment{}
+	for _, root := range page {
+		thread := []*Comment{}
+		for _, stored := range m.s.comments {
+			if (stored.ID == root.ID || stored.ThreadID == root.ID) && !m.s.blocked(viewerID, stored.UserID) {
+				comment := *stored
+				thread = append(thread, &comment)
+			}
+		}
+		sort.SliceStable(thread, func(i, j int) bool {
+			return thread[i].Depth < thread[j].Depth
+		})
+		comments = append(comments, thread...)
+	}
+
+	return buildThreads(comments), metadata, nil
+}
+
+func (m MemoryCommentModel) Update(comment *Comment) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.comments {
+		if stored.ID == comment.ID && !stored.IsDeleted() {
+			now := time.Now()
+			stored.Body = comment.Body
+			stored.EditedAt = &now
+			comment.EditedAt = &now
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+func (m MemoryCommentModel) Delete(id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.comments {
+		if stored.ID == id && !stored.IsDeleted() {
+			now := time.Now()
+			stored.Body = ""
+			stored.DeletedAt = &now
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+func (m MemoryCommentModel) Hide(id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.comments {
+		if stored.ID == id {
+			if stored.HiddenAt == nil {
+				now := time.Now()
+				stored.HiddenAt = &now
+			}
+			stored.Body = ""
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+type MemoryReportModel struct {
+	s *memoryStore
+}
+
+func (m MemoryReportModel) Insert(report *Report) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.reports {
+		if stored.ReporterID == report.ReporterID && stored.ContentType == report.ContentType && stored.ContentID == report.ContentID {
+			return ErrDuplicateReport
+		}
+	}
+
+	report.ID = m.s.id()
+	report.CreatedAt = time.Now()
+	report.Status = ReportStatusOpen
+
+	stored := *report
+	m.s.reports = append(m.s.reports, &stored)
+
+	return nil
+}
+
+func (m MemoryReportModel) Get(id int64) (*Report, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.reports {
+		if stored.ID == id {
+			report := *stored
+			return &report, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryReportModel) GetOpen(filters Filters) ([]*Report, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	reports := []*Report{}
+	for _, stored := range m.s.reports {
+		if stored.Status == ReportStatusOpen {
+			report := *stored
+			reports = append(reports, &report)
+		}
+	}
+
+	page, metadata := paginate(reports, filters)
+	return page, metadata, nil
+}
+
+func (m MemoryReportModel) Resolve(report *Report, action string, moderatorID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	resolved := false
+
+	for _, stored := range m.s.reports {
+		if stored.ContentType != report.ContentType || stored.ContentID != report.ContentID || stored.Status != ReportStatusOpen {
+			continue
+		}
+
+		stored.Status = ReportStatusResolved
+		stored.Action = action
+		stored.ResolvedBy = moderatorID
+		stored.ResolvedAt = &now
+
+		if stored.ID == report.ID {
+			*report = *stored
+			resolved = true
+		}
+	}
+
+	if !resolved {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+type MemoryBlockModel struct {
+	s *memoryStore
+}
+
+// blocked reports whether blockerID has blocked or muted blockedID. The
+// caller must hold the lock.
+func (s *memoryStore) blocked(blockerID, blockedID int64) bool {
+	for _, stored := range s.blocks {
+		if stored.BlockerID == blockerID && stored.BlockedID == blockedID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m MemoryBlockModel) Insert(block *Block) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.blocks {
+		if stored.BlockerID == block.BlockerID && stored.BlockedID == block.BlockedID {
+			stored.Kind = block.Kind
+			block.CreatedAt = stored.CreatedAt
+			return nil
+		}
+	}
+
+	block.CreatedAt = time.Now()
+
+	stored := *block
+	m.s.blocks = append(m.s.blocks, &stored)
+
+	return nil
+}
+
+func (m MemoryBlockModel) Delete(blockerID, blockedID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for i, stored := range m.s.blocks {
+		if stored.BlockerID == blockerID && stored.BlockedID == blockedID {
+			m.s.blocks = append(m.s.blocks[:i], m.s.blocks[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+func (m MemoryBlockModel) GetAllForUser(blockerID int64) ([]*Block, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	blocks := []*Block{}
+	for i := len(m.s.blocks) - 1; i >= 0; i-- {
+		if stored := m.s.blocks[i]; stored.BlockerID == blockerID {
+			block := *stored
+			blocks = append(blocks, &block)
+		}
+	}
+
+	return blocks, nil
+}
+
+func (m MemoryBlockModel) Get(blockerID, blockedID int64) (*Block, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.blocks {
+		if stored.BlockerID == blockerID && stored.BlockedID == blockedID {
+			block := *stored
+			return &block, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+type MemoryActivityModel struct {
+	s *memoryStore
+}
+
+func (m MemoryActivityModel) Insert(activity *Activity) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	activity.ID = m.s.id()
+	activity.CreatedAt = time.Now()
+
+	stored := *activity
+	m.s.activities = append(m.s.activities, &stored)
+
+	return nil
+}
+
+func (m MemoryActivityModel) GetFeed(q FeedQuery) ([]*Activity, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	genres := m.s.followedGenres(q.UserID)
+
+	activities := []*Activity{}
+	for i := len(m.s.activities) - 1; i >= 0 && len(activities) < q.Limit; i-- {
+		stored := m.s.activities[i]
+
+		switch {
+		case stored.RecipientID != q.UserID && (stored.RecipientID != 0 || stored.OrgID != q.OrgID):
+			continue
+		case stored.Kind == ActivityMoviePublished && len(genres) > 0 && !containsAny(stored.Genres, genres):
+			continue
+		case stored.Kind == ActivityListUpdated && !m.s.following(q.UserID, stored.ActorID):
+			continue
+		case len(q.Kinds) > 0 && !containsAny([]string{stored.Kind}, q.Kinds):
+			continue
+		case q.Before != 0 && stored.ID >= q.Before:
+			continue
+		case stored.ActorID == q.UserID || m.s.blocked(q.UserID, stored.ActorID):
+			continue
+		}
+
+		activity := *stored
+		activities = append(activities, &activity)
+	}
+
+	return activities, nil
+}
+
+type MemorySecurityEventModel struct {
+	s *memoryStore
+}
+
+func (m MemorySecurityEventModel) Insert(event *SecurityEvent) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	event.ID = m.s.id()
+	event.CreatedAt = time.Now()
+
+	stored := *event
+	m.s.security = append(m.s.security, &stored)
+
+	return nil
+}
+
+func (m MemorySecurityEventModel) GetAllForUser(userID, before int64, limit int) ([]*SecurityEvent, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	events := []*SecurityEvent{}
+	for i := len(m.s.security) - 1; i >= 0 && len(events) < limit; i-- {
+		stored := m.s.security[i]
+		if stored.UserID != userID || (before != 0 && stored.ID >= before) {
+			continue
+		}
+
+		event := *stored
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
+
+func (m MemorySecurityEventModel) IsNewCountry(userID int64, country string) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	located := false
+
+	for _, event := range m.s.security {
+		if event.UserID != userID || event.Kind != SecurityLoginSucceeded || event.Country == "" {
+			continue
+		}
+		if event.Country == country {
+			return false, nil
+		}
+		located = true
+	}
+
+	return located, nil
+}
+
+type MemoryWebhookModel struct {
+	s *memoryStore
+}
+
+func (m MemoryWebhookModel) Insert(webhook *Webhook) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.users[webhook.UserID]; !ok {
+		return ErrRecordNotFound
+	}
+
+	webhook.ID = m.s.id()
+	webhook.CreatedAt = time.Now()
+
+	stored := *webhook
+	stored.Events = append([]string(nil), webhook.Events...)
+	m.s.webhooks = append(m.s.webhooks, &stored)
+
+	return nil
+}
+
+func (m MemoryWebhookModel) Get(id, userID int64) (*Webhook, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.webhooks {
+		if stored.ID == id && stored.UserID == userID {
+			webhook := *stored
+			return &webhook, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryWebhookModel) GetAllForUser(userID int64) ([]*Webhook, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	webhooks := []*Webhook{}
+	for _, stored := range m.s.webhooks {
+		if stored.UserID == userID {
+			webhook := *stored
+			webhooks = append(webhooks, &webhook)
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (m MemoryWebhookModel) GetAllForEvent(eventType string) ([]*Webhook, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	webhooks := []*Webhook{}
+	for _, stored := range m.s.webhooks {
+		if containsAny(stored.Events, []string{eventType}) {
+			webhook := *stored
+			webhooks = append(webhooks, &webhook)
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (m MemoryWebhookModel) Delete(id, userID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for i, stored := range m.s.webhooks {
+		if stored.ID == id && stored.UserID == userID {
+			m.s.webhooks = append(m.s.webhooks[:i], m.s.webhooks[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+// titleWords splits s into lower case words the way the 'simple' text
+// search configuration does, closely enough for title filtering.
+func titleWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	})
+}
+
+// MemoryMaintenanceModel accepts every maintenance statement, as the
+// in-memory store has no indexes or tables to maintain.
+type MemoryMaintenanceModel struct{}
+
+func (m MemoryMaintenanceModel) Exec(statement string) error {
+	return nil
+}
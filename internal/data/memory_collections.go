@@ -0,0 +1,198 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+type memoryCollection struct {
+	collection Collection
+	movieIDs   []int64
+}
+
+type MemoryCollectionModel struct {
+	s *memoryStore
+}
+
+func (m MemoryCollectionModel) get(orgID, id int64) (*Collection, bool) {
+	stored, ok := m.s.collections[id]
+	if !ok || stored.collection.OrgID != orgID {
+		return nil, false
+	}
+
+	collection := stored.collection
+	collection.MovieCount = len(stored.movieIDs)
+	return &collection, true
+}
+
+func (m MemoryCollectionModel) Insert(collection *Collection) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	collection.ID = m.s.id()
+	collection.CreatedAt = time.Now()
+	collection.Version = 1
+
+	m.s.collections[collection.ID] = &memoryCollection{collection: *collection}
+
+	return nil
+}
+
+func (m MemoryCollectionModel) Get(orgID, id int64) (*Collection, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	collection, ok := m.get(orgID, id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return collection, nil
+}
+
+func (m MemoryCollectionModel) GetAll(orgID int64, filters Filters) ([]*Collection, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	collections := []*Collection{}
+	for id := range m.s.collections {
+		if collection, ok := m.get(orgID, id); ok {
+			collections = append(collections, collection)
+		}
+	}
+
+	sort.Slice(collections, func(i, j int) bool {
+		if collections[i].Name == collections[j].Name {
+			return collections[i].ID < collections[j].ID
+		}
+		return collections[i].Name < collections[j].Name
+	})
+
+	page, metadata := paginate(collections, filters)
+	return page, metadata, nil
+}
+
+func (m MemoryCollectionModel) Update(collection *Collection) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.collections[collection.ID]
+	if !ok || stored.collection.OrgID != collection.OrgID || stored.collection.Version != collection.Version {
+		return ErrEditConflict
+	}
+
+	collection.Version++
+
+	stored.collection.Name = collection.Name
+	stored.collection.Description = collection.Description
+	stored.collection.Version = collection.Version
+	m.s.moviesModified = time.Now()
+
+	return nil
+}
+
+func (m MemoryCollectionModel) Delete(orgID, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.get(orgID, id); !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.s.collections, id)
+	m.s.moviesModified = time.Now()
+
+	return nil
+}
+
+func (m MemoryCollectionModel) GetMovies(collectionID int64) ([]*CollectionMovie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	movies := []*CollectionMovie{}
+
+	stored, ok := m.s.collections[collectionID]
+	if !ok {
+		return movies, nil
+	}
+
+	for i, id := range stored.movieIDs {
+		movie := m.s.movies[id]
+		movies = append(movies, &CollectionMovie{
+			MovieID:  movie.ID,
+			Title:    movie.Title,
+			Year:     movie.Year,
+			Status:   movie.Status,
+			Position: i + 1,
+		})
+	}
+
+	return movies, nil
+}
+
+func (m MemoryCollectionModel) SetMovies(collection *Collection, movieIDs []int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.collections[collection.ID]
+	if !ok || stored.collection.OrgID != collection.OrgID {
+		return ErrRecordNotFound
+	}
+
+	for _, id := range movieIDs {
+		movie, ok := m.s.movies[id]
+		if !ok || movie.OrgID != collection.OrgID {
+			return ErrCollectionMovieNotFound
+		}
+
+		if other, _ := m.s.collectionOf(id); other != nil && other != stored {
+			return ErrMovieInOtherCollection
+		}
+	}
+
+	stored.movieIDs = append([]int64(nil), movieIDs...)
+	collection.MovieCount = len(movieIDs)
+	m.s.moviesModified = time.Now()
+
+	return nil
+}
+
+func (m MemoryCollectionModel) Apply(movies []*Movie) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, movie := range movies {
+		if stored, position := m.s.collectionOf(movie.ID); stored != nil {
+			movie.Collection = &MovieCollection{ID: stored.collection.ID, Name: stored.collection.Name, Position: position}
+		}
+	}
+
+	return nil
+}
+
+// collectionOf returns the collection the movie belongs to, if any, and the
+// movie's position in it.
+func (s *memoryStore) collectionOf(movieID int64) (*memoryCollection, int) {
+	for _, stored := range s.collections {
+		for i, id := range stored.movieIDs {
+			if id == movieID {
+				return stored, i + 1
+			}
+		}
+	}
+	return nil, 0
+}
+
+// inCollection reports whether the movie belongs to the collection.
+func (s *memoryStore) inCollection(collectionID, movieID int64) bool {
+	stored, _ := s.collectionOf(movieID)
+	return stored != nil && stored.collection.ID == collectionID
+}
+
+// removeFromCollections drops a deleted movie from its collection, as the
+// foreign key does in the database.
+func (s *memoryStore) removeFromCollections(movieID int64) {
+	if stored, position := s.collectionOf(movieID); stored != nil {
+		stored.movieIDs = append(stored.movieIDs[:position-1], stored.movieIDs[position:]...)
+	}
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+type MemoryExperimentModel struct {
+	s *memoryStore
+}
+
+func (m MemoryExperimentModel) Record(exposure *ExperimentExposure) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	key := exposure.Experiment + "\x00" + exposure.Subject
+	if _, ok := m.s.exposures[key]; ok {
+		return nil
+	}
+
+	stored := *exposure
+	stored.ExposedAt = time.Now()
+	m.s.exposures[key] = &stored
+	return nil
+}
+
+func (m MemoryExperimentModel) Counts(experiment string) ([]*VariantExposures, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	subjects := make(map[string]int)
+	for _, exposure := range m.s.exposures {
+		if exposure.Experiment == experiment {
+			subjects[exposure.Variant]++
+		}
+	}
+
+	counts := []*VariantExposures{}
+	for variant, n := range subjects {
+		counts = append(counts, &VariantExposures{Variant: variant, Subjects: n})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Variant < counts[j].Variant
+	})
+
+	return counts, nil
+}
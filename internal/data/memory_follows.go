@@ -0,0 +1,174 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+type memoryFollow struct {
+	followerID int64
+	followedID int64
+	createdAt  time.Time
+}
+
+type memoryGenreFollow struct {
+	userID int64
+	follow GenreFollow
+}
+
+type MemoryFollowModel struct {
+	s *memoryStore
+}
+
+// following reports whether followerID follows followedID. The caller must
+// hold the lock.
+func (s *memoryStore) following(followerID, followedID int64) bool {
+	for _, stored := range s.follows {
+		if stored.followerID == followerID && stored.followedID == followedID {
+			return true
+		}
+	}
+	return false
+}
+
+// followedGenres returns the genres userID follows. The caller must hold
+// the lock.
+func (s *memoryStore) followedGenres(userID int64) []string {
+	var genres []string
+	for _, stored := range s.genreFollows {
+		if stored.userID == userID {
+			genres = append(genres, stored.follow.Genre)
+		}
+	}
+	return genres
+}
+
+// count adds delta to the users' follow counts. The caller must hold the
+// lock.
+func (m MemoryFollowModel) count(followerID, followedID int64, delta int) {
+	if stored, ok := m.s.users[followerID]; ok {
+		stored.user.FollowingCount += delta
+	}
+	if stored, ok := m.s.users[followedID]; ok {
+		stored.user.FollowersCount += delta
+	}
+}
+
+func (m MemoryFollowModel) Insert(followerID, followedID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if m.s.following(followerID, followedID) {
+		return ErrAlreadyFollowing
+	}
+
+	m.s.follows = append(m.s.follows, &memoryFollow{followerID: followerID, followedID: followedID, createdAt: time.Now()})
+	m.count(followerID, followedID, 1)
+
+	return nil
+}
+
+func (m MemoryFollowModel) Delete(followerID, followedID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for i, stored := range m.s.follows {
+		if stored.followerID == followerID && stored.followedID == followedID {
+			m.s.follows = append(m.s.follows[:i], m.s.follows[i+1:]...)
+			m.count(followerID, followedID, -1)
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+func (m MemoryFollowModel) GetFollowers(userID int64, filters Filters) ([]*Follow, Metadata, error) {
+	return m.getAll(filters, func(stored *memoryFollow) (int64, bool) {
+		return stored.followerID, stored.followedID == userID
+	})
+}
+
+func (m MemoryFollowModel) GetFollowing(userID int64, filters Filters) ([]*Follow, Metadata, error) {
+	return m.getAll(filters, func(stored *memoryFollow) (int64, bool) {
+		return stored.followedID, stored.followerID == userID
+	})
+}
+
+// getAll lists the follows for which match returns true, as the user
+// returned by match, most recent first.
+func (m MemoryFollowModel) getAll(filters Filters, match func(*memoryFollow) (int64, bool)) ([]*Follow, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	follows := []*Follow{}
+	for i := len(m.s.follows) - 1; i >= 0; i-- {
+		stored := m.s.follows[i]
+
+		id, ok := match(stored)
+		if !ok {
+			continue
+		}
+
+		user, ok := m.s.users[id]
+		if !ok {
+			continue
+		}
+
+		follows = append(follows, &Follow{UserID: id, Name: user.user.Name, FollowedAt: stored.createdAt})
+	}
+
+	follows, metadata := paginate(follows, filters)
+	return follows, metadata, nil
+}
+
+func (m MemoryFollowModel) InsertGenre(userID int64, genre string) (*GenreFollow, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.genreFollows {
+		if stored.userID == userID && stored.follow.Genre == genre {
+			follow := stored.follow
+			return &follow, nil
+		}
+	}
+
+	stored := &memoryGenreFollow{userID: userID, follow: GenreFollow{Genre: genre, FollowedAt: time.Now()}}
+	m.s.genreFollows = append(m.s.genreFollows, stored)
+
+	follow := stored.follow
+	return &follow, nil
+}
+
+func (m MemoryFollowModel) DeleteGenre(userID int64, genre string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for i, stored := range m.s.genreFollows {
+		if stored.userID == userID && stored.follow.Genre == genre {
+			m.s.genreFollows = append(m.s.genreFollows[:i], m.s.genreFollows[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+func (m MemoryFollowModel) GetGenres(userID int64) ([]*GenreFollow, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	follows := []*GenreFollow{}
+	for _, stored := range m.s.genreFollows {
+		if stored.userID == userID {
+			follow := stored.follow
+			follows = append(follows, &follow)
+		}
+	}
+
+	sort.Slice(follows, func(i, j int) bool {
+		return follows[i].Genre < follows[j].Genre
+	})
+
+	return follows, nil
+}
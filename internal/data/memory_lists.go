@@ -0,0 +1,294 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+type memoryList struct {
+	list  List
+	items []*ListItem
+}
+
+type MemoryListModel struct {
+	s *memoryStore
+}
+
+// items returns the list's items in order, dropping those whose movie has
+// been deleted and renumbering the rest, as the cascading delete and
+// lockItems do in PostgreSQL. The caller must hold the lock.
+func (m MemoryListModel) items(stored *memoryList) []*ListItem {
+	kept := stored.items[:0]
+	for _, item := range stored.items {
+		if movie, ok := m.s.movies[item.MovieID]; ok {
+			item.Title = movie.Title
+			item.Year = movie.Year
+			item.Position = len(kept) + 1
+			kept = append(kept, item)
+		}
+	}
+	stored.items = kept
+	return kept
+}
+
+func (m MemoryListModel) get(id int64) (*List, bool) {
+	stored, ok := m.s.lists[id]
+	if !ok {
+		return nil, false
+	}
+
+	list := stored.list
+	list.ItemCount = len(m.items(stored))
+	return &list, true
+}
+
+func (m MemoryListModel) Insert(list *List) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	list.ID = m.s.id()
+	list.CreatedAt = time.Now()
+	list.Version = 1
+
+	m.s.lists[list.ID] = &memoryList{list: *list}
+
+	return nil
+}
+
+func (m MemoryListModel) Get(id int64) (*List, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	list, ok := m.get(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return list, nil
+}
+
+func (m MemoryListModel) getAll(include func(*List) bool, filters Filters) ([]*List, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	lists := []*List{}
+	for id := range m.s.lists {
+		if list, _ := m.get(id); include(list) {
+			lists = append(lists, list)
+		}
+	}
+
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].ID > lists[j].ID
+	})
+
+	page, metadata := paginate(lists, filters)
+	return page, metadata, nil
+}
+
+func (m MemoryListModel) GetAllForUser(userID int64, filters Filters) ([]*List, Metadata, error) {
+	return m.getAll(func(list *List) bool { return list.UserID == userID }, filters)
+}
+
+func (m MemoryListModel) GetPublic(orgID int64, filters Filters) ([]*List, Metadata, error) {
+	return m.getAll(func(list *List) bool { return list.OrgID == orgID && list.Public }, filters)
+}
+
+func (m MemoryListModel) Update(list *List) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.lists[list.ID]
+	if !ok || stored.list.Version != list.Version {
+		return ErrEditConflict
+	}
+
+	list.Version++
+
+	stored.list.Name = list.Name
+	stored.list.Description = list.Description
+	stored.list.Public = list.Public
+	stored.list.Version = list.Version
+
+	return nil
+}
+
+func (m MemoryListModel) Delete(id, deletedBy int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.lists[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.s.lists, id)
+	m.s.addTombstone(TombstoneList, id, stored.list.OrgID, deletedBy)
+
+	return nil
+}
+
+func (m MemoryListModel) RotateShareSecret(list *List) error {
+	secret, err := newShareSecret()
+	if err != nil {
+		return err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.lists[list.ID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	stored.list.ShareSecret = secret
+	list.ShareSecret = secret
+
+	return nil
+}
+
+func (m MemoryListModel) GetItems(listID int64, filters Filters) ([]*ListItem, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	items := []*ListItem{}
+	if stored, ok := m.s.lists[listID]; ok {
+		for _, item := range m.items(stored) {
+			c := *item
+			items = append(items, &c)
+		}
+	}
+
+	page, metadata := paginate(items, filters)
+	return page, metadata, nil
+}
+
+func (m MemoryListModel) AddItem(listID, movieID int64, position int) (*ListItem, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.lists[listID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	items := m.items(stored)
+	for _, item := range items {
+		if item.MovieID == movieID {
+			return nil, ErrDuplicateListItem
+		}
+	}
+
+	i := clampPosition(position, len(items)+1) - 1
+
+	items = append(items, nil)
+	copy(items[i+1:], items[i:])
+	items[i] = &ListItem{MovieID: movieID, AddedAt: time.Now()}
+	stored.items = items
+
+	item := *m.items(stored)[i]
+	return &item, nil
+}
+
+func (m MemoryListModel) MoveItem(listID, movieID int64, position int) (*ListItem, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.lists[listID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	items := m.items(stored)
+	for from, moving := range items {
+		if moving.MovieID != movieID {
+			continue
+		}
+
+		to := clampPosition(position, len(items)) - 1
+
+		items = append(items[:from], items[from+1:]...)
+		items = append(items, nil)
+		copy(items[to+1:], items[to:])
+		items[to] = moving
+		stored.items = items
+
+		item := *m.items(stored)[to]
+		return &item, nil
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryListModel) RemoveItem(listID, movieID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.lists[listID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	items := m.items(stored)
+	for i, item := range items {
+		if item.MovieID == movieID {
+			stored.items = append(items[:i], items[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+func (m MemoryListModel) GetForMovies(userID int64, movieIDs []int64) (map[int64][]*MovieList, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	wanted := make(map[int64]bool, len(movieIDs))
+	for _, id := range movieIDs {
+		wanted[id] = true
+	}
+
+	ids := make([]int64, 0, len(m.s.lists))
+	for id, stored := range m.s.lists {
+		if stored.list.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	lists := make(map[int64][]*MovieList, len(movieIDs))
+	for _, id := range ids {
+		stored := m.s.lists[id]
+		for _, item := range m.items(stored) {
+			if wanted[item.MovieID] {
+				lists[item.MovieID] = append(lists[item.MovieID], &MovieList{ID: id, Name: stored.list.Name, Position: item.Position})
+			}
+		}
+	}
+
+	return lists, nil
+}
+
+func (m MemoryListModel) GetOwnersOfMovie(movieID int64) ([]int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	owners := map[int64]bool{}
+	for _, stored := range m.s.lists {
+		for _, item := range m.items(stored) {
+			if item.MovieID == movieID {
+				owners[stored.list.UserID] = true
+			}
+		}
+	}
+
+	userIDs := make([]int64, 0, len(owners))
+	for userID := range owners {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	return userIDs, nil
+}
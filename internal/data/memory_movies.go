@@ -0,0 +1,502 @@
+package data
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+type MemoryMovieModel struct {
+	s *memoryStore
+}
+
+func copyMovie(movie *Movie) *Movie {
+	c := *movie
+	c.Genres = append([]string(nil), movie.Genres...)
+	return &c
+}
+
+// checkCatalogIDs returns ErrDuplicateIMDbID, ErrDuplicateTMDbID or
+// ErrDuplicateUUID if another movie in the organization already uses the
+// movie's catalog IDs or UUID.
+func (m MemoryMovieModel) checkCatalogIDs(movie *Movie) error {
+	for _, existing := range m.s.movies {
+		if existing.ID == movie.ID || existing.OrgID != movie.OrgID {
+			continue
+		}
+		if movie.IMDbID != "" && existing.IMDbID == movie.IMDbID {
+			return ErrDuplicateIMDbID
+		}
+		if movie.TMDbID != 0 && existing.TMDbID == movie.TMDbID {
+			return ErrDuplicateTMDbID
+		}
+		if existing.UUID == movie.UUID {
+			return ErrDuplicateUUID
+		}
+	}
+	return nil
+}
+
+func (m MemoryMovieModel) insert(movie *Movie) error {
+	if movie.UUID == "" {
+		uuid, err := NewUUID(UUIDRandom)
+		if err != nil {
+			return err
+		}
+		movie.UUID = uuid
+	}
+
+	err := m.checkCatalogIDs(movie)
+	if err != nil {
+		return err
+	}
+
+	movie.ID = m.s.id()
+	movie.CreatedAt = time.Now()
+	movie.Version = 1
+
+	m.s.movies[movie.ID] = copyMovie(movie)
+	m.s.moviesModified = time.Now()
+
+	return nil
+}
+
+func (m MemoryMovieModel) update(movie *Movie, editorID int64) error {
+	stored, ok := m.s.movies[movie.ID]
+	if !ok || stored.Version != movie.Version || stored.OrgID != movie.OrgID {
+		return ErrEditConflict
+	}
+
+	err := m.checkCatalogIDs(movie)
+	if err != nil {
+		return err
+	}
+
+	m.s.revisions[movie.ID] = append(m.s.revisions[movie.ID], &MovieRevision{
+		MovieID:   stored.ID,
+		Version:   stored.Version,
+		Title:     stored.Title,
+		Year:      stored.Year,
+		Runtime:   stored.Runtime,
+		Genres:    append([]string(nil), stored.Genres...),
+		IMDbID:    stored.IMDbID,
+		TMDbID:    stored.TMDbID,
+		Synopsis:  stored.Synopsis,
+		Tagline:   stored.Tagline,
+		AgeRating: stored.AgeRating,
+		PosterURL: stored.PosterURL,
+		EditorID:  editorID,
+		CreatedAt: time.Now(),
+	})
+
+	movie.Version++
+
+	updated := copyMovie(movie)
+	updated.CreatedAt = stored.CreatedAt
+	updated.ExternalID = stored.ExternalID
+	updated.UUID = stored.UUID
+	m.s.movies[movie.ID] = updated
+	m.s.moviesModified = time.Now()
+
+	return nil
+}
+
+func (m MemoryMovieModel) get(orgID, id int64) (*Movie, error) {
+	stored, ok := m.s.movies[id]
+	if !ok || stored.OrgID != orgID {
+		return nil, ErrRecordNotFound
+	}
+	return copyMovie(stored), nil
+}
+
+// matching returns copies of the movies matching q, ordered by ID.
+func (m MemoryMovieModel) matching(q MovieQuery) []*Movie {
+	words := titleWords(q.Title)
+
+	movies := []*Movie{}
+	for _, stored := range m.s.movies {
+		if stored.OrgID == q.OrgID && q.matches(stored, words) && (q.CollectionID == 0 || m.s.inCollection(q.CollectionID, stored.ID)) {
+			movies = append(movies, copyMovie(stored))
+		}
+	}
+
+	sort.Slice(movies, func(i, j int) bool { return movies[i].ID < movies[j].ID })
+
+	return movies
+}
+
+func (q MovieQuery) matches(movie *Movie, words []string) bool {
+	if len(words) > 0 && !containsAll(searchWords(movie), words) {
+		return false
+	}
+	if !containsAll(movie.Genres, q.Genres) {
+		return false
+	}
+	if len(q.GenresAny) > 0 && !containsAny(movie.Genres, q.GenresAny) {
+		return false
+	}
+	if containsAny(movie.Genres, q.GenresNone) {
+		return false
+	}
+	if len(q.AgeRatings) > 0 && !containsAny([]string{movie.AgeRating}, q.AgeRatings) {
+		return false
+	}
+	if !q.CreatedRange.contains(movie.CreatedAt) {
+		return false
+	}
+	return q.Status == "" || movie.Status == q.Status
+}
+
+// searchWords returns the words of the movie's title, tagline and synopsis,
+// which a title search looks for.
+func searchWords(movie *Movie) []string {
+	words := titleWords(movie.Title)
+	words = append(words, titleWords(movie.Tagline)...)
+	return append(words, titleWords(movie.Synopsis)...)
+}
+
+// searchRank scores how well the movie matches the words of a title search
+// with the weights PostgreSQL's ts_rank gives to the title, tagline and
+// synopsis.
+func searchRank(movie *Movie, words []string) float64 {
+	var rank float64
+	for _, w := range words {
+		switch {
+		case containsAny(titleWords(movie.Title), []string{w}):
+			rank += 1.0
+		case containsAny(titleWords(movie.Tagline), []string{w}):
+			rank += 0.4
+		case containsAny(titleWords(movie.Synopsis), []string{w}):
+			rank += 0.2
+		}
+	}
+	return rank
+}
+
+func containsAll(values, wanted []string) bool {
+	for _, w := range wanted {
+		if !containsAny(values, []string{w}) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAny(values, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m MemoryMovieModel) Insert(movie *Movie) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.insert(movie)
+}
+
+func (m MemoryMovieModel) InsertMany(movies []*Movie) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for i, movie := range movies {
+		if err := m.insert(movie); err != nil {
+			for _, inserted := range movies[:i] {
+				delete(m.s.movies, inserted.ID)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m MemoryMovieModel) Get(orgID, id int64) (*Movie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.get(orgID, id)
+}
+
+func (m MemoryMovieModel) Update(movie *Movie, editorID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.update(movie, editorID)
+}
+
+func (m MemoryMovieModel) Delete(orgID, id, deletedBy int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.movies[id]
+	if !ok || stored.OrgID != orgID {
+		return ErrRecordNotFound
+	}
+
+	delete(m.s.movies, id)
+	delete(m.s.revisions, id)
+	delete(m.s.translations, id)
+	m.s.videos = removeMovieVideos(m.s.videos, id)
+	m.s.removeFromCollections(id)
+	m.s.addTombstone(TombstoneMovie, id, orgID, deletedBy)
+	m.s.moviesModified = time.Now()
+
+	return nil
+}
+
+func (m MemoryMovieModel) GetAll(q MovieQuery, filters Filters) ([]*Movie, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	page, metadata := paginate(m.sorted(q, filters), filters)
+	return page, metadata, nil
+}
+
+// Stream takes its copies of the matching movies up front, so that fn is
+// called without the store locked.
+func (m MemoryMovieModel) Stream(ctx context.Context, q MovieQuery, filters Filters, fn func(*Movie) error) error {
+	m.s.mu.Lock()
+	movies := m.sorted(q, filters)
+	m.s.mu.Unlock()
+
+	for _, movie := range movies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(movie); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m MemoryMovieModel) sorted(q MovieQuery, filters Filters) []*Movie {
+	movies := m.matching(q)
+	words := titleWords(q.Title)
+
+	column, desc := filters.sortColumn(), filters.sortDirection() == "DESC"
+
+	sort.SliceStable(movies, func(i, j int) bool {
+		a, b := movies[i], movies[j]
+
+		var less, equal bool
+		switch column {
+		case "title":
+			less, equal = a.Title < b.Title, a.Title == b.Title
+		case "year":
+			less, equal = a.Year < b.Year, a.Year == b.Year
+		case "runtime":
+			less, equal = a.Runtime < b.Runtime, a.Runtime == b.Runtime
+		case "relevance":
+			ra, rb := searchRank(a, words), searchRank(b, words)
+			less, equal = ra < rb, ra == rb
+		default:
+			less, equal = a.ID < b.ID, a.ID == b.ID
+		}
+
+		if equal {
+			return a.ID < b.ID
+		}
+		return less != desc
+	})
+
+	return movies
+}
+
+func (m MemoryMovieModel) GetFacets(q MovieQuery) (*Facets, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	facets := &Facets{Genres: map[string]int{}, Decades: map[int]int{}}
+
+	for _, movie := range m.matching(q) {
+		for _, genre := range movie.Genres {
+			facets.Genres[genre]++
+		}
+		if movie.Year != 0 {
+			facets.Decades[int(movie.Year)/10*10]++
+		}
+	}
+
+	return facets, nil
+}
+
+func (m MemoryMovieModel) GetRandom(q MovieQuery) (*Movie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	movies := m.matching(q)
+	if len(movies) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return movies[rand.Intn(len(movies))], nil
+}
+
+func (m MemoryMovieModel) UpdateBatch(orgID int64, items []MovieBatchItem, editorID int64) ([]*MovieBatchResult, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	results := make([]*MovieBatchResult, 0, len(items))
+
+	for _, item := range items {
+		movie, err := m.get(orgID, item.ID)
+		if err != nil {
+			movie = nil
+		}
+
+		result := applyBatchItem(item, movie)
+		if result.Status == BatchStatusUpdated {
+			err = m.update(result.Movie, editorID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (m MemoryMovieModel) GetByExternalID(orgID int64, externalID string) (*Movie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.movies {
+		if stored.OrgID == orgID && stored.ExternalID == externalID {
+			return copyMovie(stored), nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryMovieModel) GetByUUID(orgID int64, uuid string) (*Movie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.movies {
+		if stored.OrgID == orgID && stored.UUID == uuid {
+			return copyMovie(stored), nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryMovieModel) GetByIMDbID(orgID int64, imdbID string) (*Movie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.movies {
+		if stored.OrgID == orgID && stored.IMDbID == imdbID {
+			return copyMovie(stored), nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryMovieModel) Upsert(movie *Movie, editorID int64) (string, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var existing *Movie
+	for _, stored := range m.s.movies {
+		if stored.OrgID == movie.OrgID && stored.ExternalID == movie.ExternalID {
+			existing = stored
+			break
+		}
+	}
+
+	if existing == nil {
+		err := m.insert(movie)
+		if err != nil {
+			return "", err
+		}
+		return UpsertCreated, nil
+	}
+
+	movie.ID = existing.ID
+	movie.UUID = existing.UUID
+	movie.CreatedAt = existing.CreatedAt
+	movie.Status = existing.Status
+	movie.Version = existing.Version
+	movie.Synopsis = existing.Synopsis
+	movie.Tagline = existing.Tagline
+	movie.AgeRating = existing.AgeRating
+	movie.PosterURL = existing.PosterURL
+
+	if sameMovieDetails(movie, existing) {
+		return UpsertUnchanged, nil
+	}
+
+	err := m.update(movie, editorID)
+	if err != nil {
+		return "", err
+	}
+
+	return UpsertUpdated, nil
+}
+
+func (m MemoryMovieModel) LastModified() (time.Time, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.moviesModified, nil
+}
+
+func (m MemoryMovieModel) Count(orgID int64) (int, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	count := 0
+	for _, stored := range m.s.movies {
+		if stored.OrgID == orgID {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+type MemoryMovieRevisionModel struct {
+	s *memoryStore
+}
+
+func (m MemoryMovieRevisionModel) GetAllForMovie(movieID int64) ([]*MovieRevision, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored := m.s.revisions[movieID]
+
+	revisions := make([]*MovieRevision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revision := *stored[i]
+		revisions = append(revisions, &revision)
+	}
+
+	return revisions, nil
+}
+
+func (m MemoryMovieRevisionModel) Get(movieID int64, version int32) (*MovieRevision, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, stored := range m.s.revisions[movieID] {
+		if stored.Version == version {
+			revision := *stored
+			return &revision, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
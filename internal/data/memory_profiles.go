@@ -0,0 +1,93 @@
+package data
+
+type MemoryProfileModel struct {
+	s *memoryStore
+}
+
+func (m MemoryProfileModel) Get(userID, viewerID int64) (*Profile, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[userID]
+	if !ok || stored.user.Banned {
+		return nil, ErrRecordNotFound
+	}
+
+	for _, block := range m.s.blocks {
+		if block.BlockerID == userID && block.BlockedID == viewerID && block.Kind == BlockKindBlock {
+			return nil, ErrRecordNotFound
+		}
+	}
+
+	profile := &Profile{ID: userID, Name: stored.user.Name, AvatarURL: stored.user.AvatarURL, Privacy: stored.privacy}
+
+	switch {
+	case userID == viewerID, stored.privacy == PrivacyPublic:
+		profile.Visible = true
+	case stored.privacy == PrivacyFollowers:
+		profile.Visible = m.s.following(viewerID, userID)
+	}
+
+	if profile.Visible {
+		profile.Stats = &ProfileStats{
+			MemberSince:    stored.user.CreatedAt,
+			FollowersCount: stored.user.FollowersCount,
+			FollowingCount: stored.user.FollowingCount,
+		}
+	}
+
+	return profile, nil
+}
+
+func (m MemoryProfileModel) SetPrivacy(userID int64, privacy string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[userID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	stored.privacy = privacy
+
+	return nil
+}
+
+func (m MemoryProfileModel) GetLists(userID, orgID int64, limit int) ([]*List, error) {
+	lists, _, err := MemoryListModel(m).GetPublic(orgID, Filters{Page: 1, PageSize: 100_000})
+	if err != nil {
+		return nil, err
+	}
+
+	owned := []*List{}
+	for _, list := range lists {
+		if list.UserID == userID && len(owned) < limit {
+			owned = append(owned, list)
+		}
+	}
+
+	return owned, nil
+}
+
+func (m MemoryProfileModel) GetComments(userID, orgID int64, limit int) ([]*Comment, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	comments := []*Comment{}
+	for i := len(m.s.comments) - 1; i >= 0 && len(comments) < limit; i-- {
+		stored := m.s.comments[i]
+		if stored.UserID != userID || stored.IsDeleted() {
+			continue
+		}
+
+		movie, ok := m.s.movies[stored.MovieID]
+		if !ok || movie.OrgID != orgID || !movie.IsPublished() {
+			continue
+		}
+
+		comment := *stored
+		comments = append(comments, &comment)
+	}
+
+	return comments, nil
+}
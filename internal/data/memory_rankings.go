@@ -0,0 +1,65 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+// MemoryMovieRankingModel computes the rankings on every read, as the
+// in-memory store has no materialized view to refresh.
+type MemoryMovieRankingModel struct {
+	s *memoryStore
+}
+
+func (m MemoryMovieRankingModel) Get(orgID int64, ranking string, limit int) ([]*RankedMovie, error) {
+	if _, ok := rankingColumns[ranking]; !ok {
+		panic("unknown ranking: " + ranking)
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	since := time.Now().Add(-trendingWindow)
+	listCounts := map[int64]int{}
+	trending := map[int64]int{}
+
+	for _, stored := range m.s.lists {
+		for _, item := range stored.items {
+			listCounts[item.MovieID]++
+			if item.AddedAt.After(since) {
+				trending[item.MovieID] += 3
+			}
+		}
+	}
+
+	for _, comment := range m.s.comments {
+		if comment.CreatedAt.After(since) && comment.DeletedAt == nil && comment.HiddenAt == nil {
+			trending[comment.MovieID] += 2
+		}
+	}
+
+	scores := listCounts
+	if ranking == RankingTrending {
+		scores = trending
+	}
+
+	ranked := []*RankedMovie{}
+	for _, stored := range m.s.movies {
+		if stored.OrgID == orgID && stored.IsPublished() && scores[stored.ID] > 0 {
+			ranked = append(ranked, &RankedMovie{Movie: copyMovie(stored), Score: scores[stored.ID]})
+		}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked, nil
+}
@@ -0,0 +1,60 @@
+package data
+
+import "time"
+
+type MemoryTombstoneModel struct {
+	s *memoryStore
+}
+
+// addTombstone records a deletion. The caller must hold the store's lock,
+// so that the tombstone is written along with the deletion.
+func (s *memoryStore) addTombstone(resourceType string, resourceID, orgID, deletedBy int64) {
+	s.tombstones = append(s.tombstones, &Tombstone{
+		ID:           s.id(),
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		OrgID:        orgID,
+		DeletedAt:    time.Now(),
+		DeletedBy:    deletedBy,
+	})
+}
+
+func (m MemoryTombstoneModel) GetAll(q TombstoneQuery, filters Filters) ([]*Tombstone, Metadata, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	tombstones := []*Tombstone{}
+	for _, stored := range m.s.tombstones {
+		if stored.OrgID != q.OrgID || stored.ResourceType != q.ResourceType {
+			continue
+		}
+		if !q.DeletedAfter.IsZero() && !stored.DeletedAt.After(q.DeletedAfter) {
+			continue
+		}
+		if q.DeletedBy != 0 && stored.DeletedBy != q.DeletedBy {
+			continue
+		}
+		tombstone := *stored
+		tombstones = append(tombstones, &tombstone)
+	}
+
+	page, metadata := paginate(tombstones, filters)
+	return page, metadata, nil
+}
+
+func (m MemoryTombstoneModel) DeleteOlderThan(cutoff time.Time) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var kept []*Tombstone
+	for _, stored := range m.s.tombstones {
+		if !stored.DeletedAt.Before(cutoff) {
+			kept = append(kept, stored)
+		}
+	}
+
+	deleted := int64(len(m.s.tombstones) - len(kept))
+	m.s.tombstones = kept
+
+	return deleted, nil
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+type MemoryMovieTranslationModel struct {
+	s *memoryStore
+}
+
+func (m MemoryMovieTranslationModel) Upsert(translation *MovieTranslation) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	translations := m.s.translations[translation.MovieID]
+	if translations == nil {
+		translations = make(map[string]*MovieTranslation)
+		m.s.translations[translation.MovieID] = translations
+	}
+
+	_, exists := translations[translation.Locale]
+
+	translation.UpdatedAt = time.Now()
+	stored := *translation
+	translations[translation.Locale] = &stored
+	m.s.moviesModified = time.Now()
+
+	return !exists, nil
+}
+
+func (m MemoryMovieTranslationModel) Delete(movieID int64, locale string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.translations[movieID][locale]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(m.s.translations[movieID], locale)
+	m.s.moviesModified = time.Now()
+
+	return nil
+}
+
+func (m MemoryMovieTranslationModel) GetAllForMovie(movieID int64) ([]*MovieTranslation, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	translations := []*MovieTranslation{}
+	for _, stored := range m.s.translations[movieID] {
+		t := *stored
+		translations = append(translations, &t)
+	}
+
+	sort.Slice(translations, func(i, j int) bool { return translations[i].Locale < translations[j].Locale })
+
+	return translations, nil
+}
+
+func (m MemoryMovieTranslationModel) Apply(movies []*Movie, locales []string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, movie := range movies {
+		if t := pickTranslation(m.s.translations[movie.ID], locales); t != nil {
+			applyTranslation(movie, t)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package data
+
+import "time"
+
+type MemoryUploadModel struct {
+	s *memoryStore
+}
+
+func (m MemoryUploadModel) Insert(upload *Upload) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	upload.ID = m.s.id()
+	upload.CreatedAt = time.Now()
+
+	stored := *upload
+	m.s.uploads[upload.ID] = &stored
+
+	return nil
+}
+
+func (m MemoryUploadModel) Get(id int64) (*Upload, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.uploads[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	upload := *stored
+	return &upload, nil
+}
+
+func (m MemoryUploadModel) MarkUploaded(upload *Upload) error {
+	return m.mark(upload.ID, func(stored *Upload) **time.Time { return &stored.UploadedAt }, &upload.UploadedAt)
+}
+
+func (m MemoryUploadModel) UnmarkUploaded(upload *Upload) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if stored, ok := m.s.uploads[upload.ID]; ok && stored.CompletedAt == nil {
+		stored.UploadedAt = nil
+	}
+	upload.UploadedAt = nil
+
+	return nil
+}
+
+func (m MemoryUploadModel) Complete(upload *Upload) error {
+	return m.mark(upload.ID, func(stored *Upload) **time.Time { return &stored.CompletedAt }, &upload.CompletedAt)
+}
+
+// mark sets the time field picks out on the stored upload, unless it is
+// already set, and copies it to dest.
+func (m MemoryUploadModel) mark(id int64, field func(*Upload) **time.Time, dest **time.Time) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.uploads[id]
+	if !ok || *field(stored) != nil {
+		return ErrUploadClaimed
+	}
+
+	now := time.Now()
+	*field(stored) = &now
+	*dest = &now
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package data
+
+import "time"
+
+type MemoryMovieVideoModel struct {
+	s *memoryStore
+}
+
+func (m MemoryMovieVideoModel) Insert(video *MovieVideo) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.movies[video.MovieID]; !ok {
+		return ErrRecordNotFound
+	}
+
+	for _, stored := range m.s.videos {
+		if stored.MovieID == video.MovieID && stored.Provider == video.Provider && stored.ProviderID == video.ProviderID {
+			return ErrDuplicateVideo
+		}
+	}
+
+	video.ID = m.s.id()
+	video.CreatedAt = time.Now()
+	stored := *video
+	m.s.videos = append(m.s.videos, &stored)
+
+	return nil
+}
+
+func (m MemoryMovieVideoModel) GetAllForMovie(movieID int64) ([]*MovieVideo, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	videos := []*MovieVideo{}
+	for _, stored := range m.s.videos {
+		if stored.MovieID == movieID {
+			video := *stored
+			videos = append(videos, &video)
+		}
+	}
+
+	return videos, nil
+}
+
+func (m MemoryMovieVideoModel) Delete(movieID, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for i, stored := range m.s.videos {
+		if stored.ID == id && stored.MovieID == movieID {
+			m.s.videos = append(m.s.videos[:i], m.s.videos[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+// removeMovieVideos drops the videos of a deleted movie, as the foreign key
+// does in the database.
+func removeMovieVideos(videos []*MovieVideo, movieID int64) []*MovieVideo {
+	kept := videos[:0]
+	for _, video := range videos {
+		if video.MovieID != movieID {
+			kept = append(kept, video)
+		}
+	}
+	return kept
+}
@@ -1,9 +1,16 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lib/pq"
+
+	"greenlight.bcc/internal/fieldcrypt"
 )
 
 var (
@@ -11,45 +18,240 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// isUniqueViolation reports whether err is a unique_violation of the named
+// constraint. It understands errors from both lib/pq and pgx.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" && pqErr.Constraint == constraint
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+	}
+
+	return false
+}
+
 type Models struct {
 	Movies interface {
 		Insert(movie *Movie) error
-		Get(id int64) (*Movie, error)
-		Update(movie *Movie) error
-		Delete(id int64) error
-		GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error)
+		InsertMany(movies []*Movie) error
+		Get(orgID, id int64) (*Movie, error)
+		Update(movie *Movie, editorID int64) error
+		Delete(orgID, id, deletedBy int64) error
+		GetAll(q MovieQuery, filters Filters) ([]*Movie, Metadata, error)
+		Stream(ctx context.Context, q MovieQuery, filters Filters, fn func(*Movie) error) error
+		GetFacets(q MovieQuery) (*Facets, error)
+		GetRandom(q MovieQuery) (*Movie, error)
+		UpdateBatch(orgID int64, items []MovieBatchItem, editorID int64) ([]*MovieBatchResult, error)
+		GetByExternalID(orgID int64, externalID string) (*Movie, error)
+		GetByIMDbID(orgID int64, imdbID string) (*Movie, error)
+		GetByUUID(orgID int64, uuid string) (*Movie, error)
+		Upsert(movie *Movie, editorID int64) (string, error)
+		LastModified() (time.Time, error)
+		Count(orgID int64) (int, error)
+	}
+	MovieTranslations interface {
+		Upsert(translation *MovieTranslation) (bool, error)
+		Delete(movieID int64, locale string) error
+		GetAllForMovie(movieID int64) ([]*MovieTranslation, error)
+		Apply(movies []*Movie, locales []string) error
+	}
+	MovieVideos interface {
+		Insert(video *MovieVideo) error
+		GetAllForMovie(movieID int64) ([]*MovieVideo, error)
+		Delete(movieID, id int64) error
+	}
+	Collections interface {
+		Insert(collection *Collection) error
+		Get(orgID, id int64) (*Collection, error)
+		GetAll(orgID int64, filters Filters) ([]*Collection, Metadata, error)
+		Update(collection *Collection) error
+		Delete(orgID, id int64) error
+		GetMovies(collectionID int64) ([]*CollectionMovie, error)
+		SetMovies(collection *Collection, movieIDs []int64) error
+		Apply(movies []*Movie) error
+	}
+	MovieRankings interface {
+		Get(orgID int64, ranking string, limit int) ([]*RankedMovie, error)
+	}
+	MovieRevisions interface {
+		GetAllForMovie(movieID int64) ([]*MovieRevision, error)
+		Get(movieID int64, version int32) (*MovieRevision, error)
 	}
 	Users interface {
 		Insert(user *User) error
+		InsertWithInvitation(user *User, code string) error
+		Get(id int64) (*User, error)
+		GetAll(created CreatedRange, filters Filters) ([]*User, Metadata, error)
 		GetByEmail(email string) (*User, error)
 		Update(user *User) error
 		GetForToken(tokenScope, tokenPlaintext string) (*User, error)
+		MarkEmailUndeliverable(email string) error
+		Ban(id int64) error
+		EmailUndeliverable(email string) (bool, error)
+		GetPreferences(userID int64) (*NotificationPreferences, error)
+		UpdatePreferences(userID int64, prefs *NotificationPreferences) error
+		EmailAllowed(email, category string) (bool, error)
+		CountStaleEncryption() (int, error)
+		RehashPassword(user *User, plaintextPassword string) error
+		ReencryptBatch(limit int) (int, error)
 	}
 	Tokens interface {
 		DeleteAllForUser(scope string, userID int64) error
+		GetSessions(userID int64) ([]*Session, error)
 		Insert(token *Token) error
+		IsNewDevice(userID int64, device Device) (bool, error)
 		New(userID int64, ttl time.Duration, scope string) (*Token, error)
+		NewAuthentication(userID, orgID int64, ttl time.Duration) (*Token, error)
+		NewImpersonation(userID, impersonatorID, orgID int64, ttl time.Duration) (*Token, error)
+		NewSession(userID, orgID int64, ttl time.Duration, device Device) (*Token, error)
+	}
+	Invitations interface {
+		New(email string, createdBy int64, ttl time.Duration) (*Invitation, error)
+		Insert(invitation *Invitation) error
+	}
+	Organizations interface {
+		Insert(org *Organization, ownerID int64) error
+		GetAllForUser(userID int64) ([]*Organization, error)
+		GetRole(orgID, userID int64) (string, error)
+		GetDefaultForUser(userID int64) (int64, error)
+		AddMember(orgID, userID int64, role string) error
+	}
+	Usage interface {
+		Add(records []*UsageRecord) error
+		GetAll(filter UsageFilter) ([]*UsageRecord, error)
 	}
 	Permissions interface {
 		GetAllForUser(userID int64) (Permissions, error)
 		AddForUser(userID int64, codes ...string) error
 	}
-}
-
-func NewModels(db *sql.DB) Models {
-	return Models{
-		Movies:      MovieModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+	Notifications interface {
+		Insert(notification *Notification) error
+		GetAllForUser(userID int64, unreadOnly bool, filters Filters) ([]*Notification, Metadata, error)
+		CountUnread(userID int64) (int, error)
+		MarkRead(id, userID int64) (*Notification, error)
+	}
+	Comments interface {
+		Insert(comment *Comment) error
+		Get(id int64) (*Comment, error)
+		GetThreads(movieID, viewerID int64, filters Filters) ([]*Comment, Metadata, error)
+		Update(comment *Comment) error
+		Delete(id int64) error
+		Hide(id int64) error
+	}
+	Blocks interface {
+		Insert(block *Block) error
+		Delete(blockerID, blockedID int64) error
+		GetAllForUser(blockerID int64) ([]*Block, error)
+		Get(blockerID, blockedID int64) (*Block, error)
+	}
+	Lists interface {
+		Insert(list *List) error
+		Get(id int64) (*List, error)
+		GetAllForUser(userID int64, filters Filters) ([]*List, Metadata, error)
+		GetPublic(orgID int64, filters Filters) ([]*List, Metadata, error)
+		Update(list *List) error
+		Delete(id, deletedBy int64) error
+		RotateShareSecret(list *List) error
+		GetItems(listID int64, filters Filters) ([]*ListItem, Metadata, error)
+		AddItem(listID, movieID int64, position int) (*ListItem, error)
+		MoveItem(listID, movieID int64, position int) (*ListItem, error)
+		RemoveItem(listID, movieID int64) error
+		GetForMovies(userID int64, movieIDs []int64) (map[int64][]*MovieList, error)
+		GetOwnersOfMovie(movieID int64) ([]int64, error)
+	}
+	Follows interface {
+		Insert(followerID, followedID int64) error
+		Delete(followerID, followedID int64) error
+		GetFollowers(userID int64, filters Filters) ([]*Follow, Metadata, error)
+		GetFollowing(userID int64, filters Filters) ([]*Follow, Metadata, error)
+		InsertGenre(userID int64, genre string) (*GenreFollow, error)
+		DeleteGenre(userID int64, genre string) error
+		GetGenres(userID int64) ([]*GenreFollow, error)
+	}
+	Profiles interface {
+		Get(userID, viewerID int64) (*Profile, error)
+		SetPrivacy(userID int64, privacy string) error
+		GetLists(userID, orgID int64, limit int) ([]*List, error)
+		GetComments(userID, orgID int64, limit int) ([]*Comment, error)
+	}
+	Uploads interface {
+		Insert(upload *Upload) error
+		Get(id int64) (*Upload, error)
+		MarkUploaded(upload *Upload) error
+		UnmarkUploaded(upload *Upload) error
+		Complete(upload *Upload) error
+	}
+	Activities interface {
+		Insert(activity *Activity) error
+		GetFeed(q FeedQuery) ([]*Activity, error)
+	}
+	Tombstones interface {
+		GetAll(q TombstoneQuery, filters Filters) ([]*Tombstone, Metadata, error)
+		DeleteOlderThan(cutoff time.Time) (int64, error)
+	}
+	Maintenance interface {
+		Exec(statement string) error
+	}
+	Experiments interface {
+		Record(exposure *ExperimentExposure) error
+		Counts(experiment string) ([]*VariantExposures, error)
+	}
+	SecurityEvents interface {
+		Insert(event *SecurityEvent) error
+		GetAllForUser(userID, before int64, limit int) ([]*SecurityEvent, error)
+		IsNewCountry(userID int64, country string) (bool, error)
+	}
+	Webhooks interface {
+		Insert(webhook *Webhook) error
+		Get(id, userID int64) (*Webhook, error)
+		GetAllForUser(userID int64) ([]*Webhook, error)
+		GetAllForEvent(eventType string) ([]*Webhook, error)
+		Delete(id, userID int64) error
+	}
+	Reports interface {
+		Insert(report *Report) error
+		Get(id int64) (*Report, error)
+		GetOpen(filters Filters) ([]*Report, Metadata, error)
+		Resolve(report *Report, action string, moderatorID int64) error
 	}
 }
 
-func NewMockModels() Models {
+// NewModels returns the models backed by db. pool is the pgx pool behind db
+// when the pgx driver is used, and nil otherwise. keys, if not nil, encrypts
+// personal data at rest.
+func NewModels(db *sql.DB, pool *pgxpool.Pool, keys *fieldcrypt.Keyring) Models {
+	stmts := newStmtCache(db)
+
 	return Models{
-		Movies:      MockMovieModel{},
-		Users:       MockUserModel{},
-		Tokens:      MockTokenModel{},
-		Permissions: MockPermissionModel{},
+		Movies:            MovieModel{DB: db, Pool: pool, stmts: stmts},
+		MovieRankings:     MovieRankingModel{DB: db},
+		MovieRevisions:    MovieRevisionModel{DB: db},
+		MovieTranslations: MovieTranslationModel{DB: db},
+		MovieVideos:       MovieVideoModel{DB: db},
+		Collections:       CollectionModel{DB: db},
+		Users:             UserModel{DB: db, stmts: stmts, Keys: keys},
+		Tokens:            TokenModel{DB: db},
+		Invitations:       InvitationModel{DB: db, Keys: keys},
+		Organizations:     OrganizationModel{DB: db},
+		Usage:             UsageModel{DB: db},
+		Permissions:       PermissionModel{DB: db},
+		Notifications:     NotificationModel{DB: db},
+		Comments:          CommentModel{DB: db},
+		Reports:           ReportModel{DB: db},
+		Blocks:            BlockModel{DB: db},
+		Lists:             ListModel{DB: db},
+		Follows:           FollowModel{DB: db},
+		Profiles:          ProfileModel{DB: db},
+		Uploads:           UploadModel{DB: db},
+		Activities:        ActivityModel{DB: db},
+		Tombstones:        TombstoneModel{DB: db},
+		Maintenance:       MaintenanceModel{DB: db},
+		Experiments:       ExperimentModel{DB: db},
+		SecurityEvents:    SecurityEventModel{DB: db},
+		Webhooks:          WebhookModel{DB: db},
 	}
 }
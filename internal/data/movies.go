@@ -1,7 +1,8 @@
 package data
 
 import (
-	"reflect"
+	"regexp"
+	"strings"
 	"time"
 )
 import "database/sql"
@@ -10,73 +11,193 @@ import "github.com/lib/pq"
 import "errors"
 import "context"
 import "fmt"
+import "github.com/jackc/pgx/v5/pgxpool"
+
+const (
+	MovieStatusDraft     = "draft"
+	MovieStatusPublished = "published"
+	MovieStatusArchived  = "archived"
+)
+
+var MovieStatuses = []string{MovieStatusDraft, MovieStatusPublished, MovieStatusArchived}
+
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+var (
+	ErrDuplicateIMDbID = errors.New("duplicate imdb id")
+	ErrDuplicateTMDbID = errors.New("duplicate tmdb id")
+	ErrDuplicateUUID   = errors.New("duplicate uuid")
+)
+
+var IMDbIDRX = regexp.MustCompile(`^tt[0-9]{7,10}$`)
+
+// AgeRatings are the age ratings a movie may have. MPAA ratings are used as
+// they are, and the ratings of other countries' boards are prefixed with the
+// country's ISO 3166 code.
+var AgeRatings = []string{
+	"G", "PG", "PG-13", "R", "NC-17",
+	"GB-U", "GB-PG", "GB-12A", "GB-12", "GB-15", "GB-18", "GB-R18",
+	"DE-0", "DE-6", "DE-12", "DE-16", "DE-18",
+	"FR-U", "FR-10", "FR-12", "FR-16", "FR-18",
+	"AU-G", "AU-PG", "AU-M", "AU-MA15+", "AU-R18+",
+}
+
+// AgeRatingRX matches exactly the values in AgeRatings.
+var AgeRatingRX = func() *regexp.Regexp {
+	quoted := make([]string, len(AgeRatings))
+	for i, rating := range AgeRatings {
+		quoted[i] = regexp.QuoteMeta(rating)
+	}
+	return regexp.MustCompile(`^(` + strings.Join(quoted, "|") + `)$`)
+}()
+
+var movieStatusTransitions = map[string][]string{
+	MovieStatusDraft:     {MovieStatusPublished, MovieStatusArchived},
+	MovieStatusPublished: {MovieStatusArchived},
+	MovieStatusArchived:  {MovieStatusDraft, MovieStatusPublished},
+}
 
 type Movie struct {
-	ID        int64     `json:"id"`
+	ID int64 `json:"id"`
+	// UUID identifies the movie within its organization. Clients may choose
+	// it when creating the movie, so that they can refer to it before it
+	// has been created and can safely repeat the request.
+	UUID      string    `json:"uuid,omitempty" validate:"pattern=uuid"`
 	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
+	Title     string    `json:"title" validate:"required,max=500"`
+	Year      int32     `json:"year,omitempty" validate:"min=1888"`
+	Runtime   Runtime   `json:"runtime,omitempty" validate:"required,positive"`
+	Genres    []string  `json:"genres,omitempty" validate:"max=5,unique"`
+	Status    string    `json:"status" validate:"required,oneof=draft published archived"`
 	Version   int32     `json:"version"`
+	OrgID     int64     `json:"-"`
+	// ExternalID identifies the movie in an external catalog it is synced
+	// from. It is unique within an organization.
+	ExternalID string `json:"external_id,omitempty" validate:"max=200"`
+	IMDbID     string `json:"imdb_id,omitempty" validate:"pattern=imdb_id"`
+	TMDbID     int64  `json:"tmdb_id,omitempty" validate:"positive"`
+	Synopsis   string `json:"synopsis,omitempty" validate:"max=5000"`
+	Tagline    string `json:"tagline,omitempty" validate:"max=300"`
+	AgeRating  string `json:"age_rating,omitempty" validate:"pattern=age_rating"`
+	PosterURL  string `json:"poster_url,omitempty"`
+	// PosterThumbnails are the URLs of the poster's thumbnails by size. They
+	// are filled in by the API rather than stored.
+	PosterThumbnails map[string]string `json:"poster_thumbnails,omitempty"`
+	// Locale is the language the title and synopsis have been translated
+	// into, if any.
+	Locale string `json:"locale,omitempty"`
+	// Collection is the collection the movie belongs to, if any. It is
+	// filled in by the API rather than stored with the movie.
+	Collection *MovieCollection `json:"collection,omitempty"`
+	// Lists are the caller's lists the movie is on. They are only filled in
+	// when asked for with include=lists.
+	Lists []*MovieList `json:"lists,omitempty"`
 }
 
+func (m *Movie) IsPublished() bool {
+	return m.Status == MovieStatusPublished
+}
+
+func (m *Movie) SetStatus(status string) error {
+	if !validator.PermittedValue(status, movieStatusTransitions[m.Status]...) {
+		return ErrInvalidStatusTransition
+	}
+	m.Status = status
+	return nil
+}
+
+func init() {
+	validator.RegisterPattern("imdb_id", IMDbIDRX, "must look like tt0111161")
+	validator.RegisterPattern("age_rating", AgeRatingRX, "must be a known age rating")
+}
+
+// ValidateMovie applies the validate tags on Movie along with the checks
+// that depend on the current time.
 func ValidateMovie(v *validator.Validator, movie *Movie) {
-	v.Check(movie.Title != "", "title", "must be provided")
-	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
-	v.Check(movie.Year != 0, "year", "must be provided")
-	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
+	v.Struct(movie)
 	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
-	v.Check(movie.Genres != nil, "genres", "must be provided")
-	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
-	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+}
+
+// movieWriteError maps violations of the unique external catalog ID indexes
+// to ErrDuplicateIMDbID and ErrDuplicateTMDbID.
+func movieWriteError(err error) error {
+	switch {
+	case isUniqueViolation(err, "movies_org_id_imdb_id_idx"):
+		return ErrDuplicateIMDbID
+	case isUniqueViolation(err, "movies_org_id_tmdb_id_idx"):
+		return ErrDuplicateTMDbID
+	case isUniqueViolation(err, "movies_org_id_uuid_idx"):
+		return ErrDuplicateUUID
+	default:
+		return err
+	}
 }
 
 type MovieModel struct {
 	DB *sql.DB
+	// Pool is the pgx pool DB borrows its connections from, if the pgx
+	// driver is used. InsertMany copies rows through it.
+	Pool  *pgxpool.Pool
+	stmts *stmtCache
 }
 
 func (m MovieModel) Insert(movie *Movie) error {
 	query := `
-INSERT INTO movies (title, year, runtime, genres)
-VALUES ($1, $2, $3, $4)
-RETURNING id, created_at, version`
+INSERT INTO movies (title, year, runtime, genres, status, org_id, imdb_id, tmdb_id, synopsis, poster_url, uuid, tagline, age_rating)
+VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8::bigint, 0), $9, $10, coalesce(NULLIF($11, '')::uuid, gen_random_uuid()), $12, NULLIF($13, ''))
+RETURNING id, uuid, created_at, version`
 
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.Status, movie.OrgID, movie.IMDbID, movie.TMDbID, movie.Synopsis, movie.PosterURL, movie.UUID, movie.Tagline, movie.AgeRating}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.UUID, &movie.CreatedAt, &movie.Version)
+	if err != nil {
+		return movieWriteError(err)
+	}
+
+	return nil
 }
 
 // Add a placeholder method for fetching a specific record from the movies table.
-func (m MovieModel) Get(id int64) (*Movie, error) {
+func (m MovieModel) Get(orgID, id int64) (*Movie, error) {
+	return retryRead("Movies.Get", func() (*Movie, error) { return m.get(orgID, id) })
+}
+
+func (m MovieModel) get(orgID, id int64) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
+		SELECT id, uuid, created_at, title, coalesce(year, 0), runtime, genres, status, version, org_id, coalesce(external_id, ''), coalesce(imdb_id, ''), coalesce(tmdb_id, 0), synopsis, poster_url, tagline, coalesce(age_rating, '')
 		FROM movies
-		WHERE id = $1`
+		WHERE id = $1 AND org_id = $2`
 
 	var movie Movie
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	err := prepared(m.DB, m.stmts).QueryRowContext(ctx, query, id, orgID).Scan(
 		&movie.ID,
+		&movie.UUID,
 		&movie.CreatedAt,
 		&movie.Title,
 		&movie.Year,
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
+		&movie.Status,
 		&movie.Version,
+		&movie.OrgID,
+		&movie.ExternalID,
+		&movie.IMDbID,
+		&movie.TMDbID,
+		&movie.Synopsis,
+		&movie.PosterURL,
+		&movie.Tagline,
+		&movie.AgeRating,
 	)
 
 	if err != nil {
@@ -92,11 +213,42 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 }
 
 // Add a placeholder method for updating a specific record in the movies table.
-func (m MovieModel) Update(movie *Movie) error {
+func (m MovieModel) Update(movie *Movie, editorID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateMovieTx(ctx, tx, movie, editorID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updateMovieTx(ctx context.Context, tx *sql.Tx, movie *Movie, editorID int64) error {
 	query := `
+INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres, imdb_id, tmdb_id, synopsis, tagline, age_rating, poster_url, editor_id)
+SELECT id, version, title, year, runtime, genres, imdb_id, tmdb_id, synopsis, tagline, age_rating, poster_url, $3
+FROM movies
+WHERE id = $1 AND version = $2`
+
+	editor := sql.NullInt64{Int64: editorID, Valid: editorID > 0}
+
+	_, err := tx.ExecContext(ctx, query, movie.ID, movie.Version, editor)
+	if err != nil {
+		return err
+	}
+
+	query = `
 UPDATE movies
-SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-WHERE id = $5 AND version = $6
+SET title = $1, year = NULLIF($2, 0), runtime = $3, genres = $4, status = $5, imdb_id = NULLIF($9, ''), tmdb_id = NULLIF($10::bigint, 0), synopsis = $11, poster_url = $12, tagline = $13, age_rating = NULLIF($14, ''), version = version + 1
+WHERE id = $6 AND version = $7 AND org_id = $8
 RETURNING version`
 
 	args := []any{
@@ -104,40 +256,52 @@ RETURNING version`
 		movie.Year,
 		movie.Runtime,
 		pq.Array(movie.Genres),
+		movie.Status,
 		movie.ID,
 		movie.Version,
+		movie.OrgID,
+		movie.IMDbID,
+		movie.TMDbID,
+		movie.Synopsis,
+		movie.PosterURL,
+		movie.Tagline,
+		movie.AgeRating,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return movieWriteError(err)
 		}
 	}
 
 	return nil
 }
 
-// Add a placeholder method for deleting a specific record from the movies table.
-func (m MovieModel) Delete(id int64) error {
+// Delete removes the movie and records a tombstone for it in the same
+// transaction.
+func (m MovieModel) Delete(orgID, id, deletedBy int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
 	DELETE FROM movies
-	WHERE id = $1`
+	WHERE id = $1 AND org_id = $2`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id, orgID)
 	if err != nil {
 		return err
 	}
@@ -151,24 +315,129 @@ func (m MovieModel) Delete(id int64) error {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	err = insertTombstoneTx(ctx, tx, &Tombstone{ResourceType: TombstoneMovie, ResourceID: id, OrgID: orgID, DeletedBy: deletedBy})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	query := fmt.Sprintf(`
-	SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+// MovieQuery narrows the movies returned by GetAll. Empty fields match every
+// movie, except OrgID which is always applied. Title is searched for in the
+// title, tagline and synopsis. Genres must all be present, at least one of
+// GenresAny must be present and none of GenresNone may be. AgeRatings
+// matches movies with any of the ratings.
+type MovieQuery struct {
+	OrgID      int64
+	Title      string
+	Genres     []string
+	GenresAny  []string
+	GenresNone []string
+	Status     string
+	AgeRatings []string
+	// CollectionID limits the movies to those in the collection, if it is
+	// not 0.
+	CollectionID int64
+	CreatedRange
+}
+
+func ValidateMovieQuery(v *validator.Validator, q MovieQuery) {
+	for key, genres := range map[string][]string{"genres": q.Genres, "genres_any": q.GenresAny, "genres_none": q.GenresNone} {
+		v.Check(len(genres) <= 10, key, "must not contain more than 10 genres")
+		v.Check(validator.Unique(genres), key, "must not contain duplicate values")
+	}
+
+	for _, genre := range q.GenresNone {
+		v.Check(!validator.PermittedValue(genre, q.Genres...), "genres_none", "must not contain genres which are also required")
+	}
+
+	for _, rating := range q.AgeRatings {
+		v.Check(validator.PermittedValue(rating, AgeRatings...), "age_rating", "must contain only known age ratings")
+	}
+
+	v.Check(q.CollectionID >= 0, "collection", "must be a positive integer")
+
+	ValidateCreatedRange(v, q.CreatedRange)
+}
+
+// Count returns the number of movies in the organization, whatever their
+// status.
+func (m MovieModel) Count(orgID int64) (int, error) {
+	return retryRead("Movies.Count", func() (int, error) { return m.count(orgID) })
+}
+
+func (m MovieModel) count(orgID int64) (int, error) {
+	query := `
+	SELECT count(*)
 	FROM movies
-	WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	WHERE org_id = $1`
+
+	var count int
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, orgID).Scan(&count)
+	return count, err
+}
+
+// movieQueryWhere filters movies by a MovieQuery, taking its values from
+// the first ten placeholders in the order returned by MovieQuery.args.
+const movieQueryWhere = `
+	WHERE (search_vector @@ plainto_tsquery('simple', $1) OR $1 = '')
 	AND (genres @> $2 OR $2 = '{}')
-	ORDER BY %s %s, id ASC
-	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	AND (genres && $3 OR $3 = '{}')
+	AND (NOT genres && $4 OR $4 = '{}')
+	AND (status = $5 OR $5 = '')
+	AND (age_rating = ANY($6) OR $6 = '{}')
+	AND org_id = $7
+	AND (created_at > $8 OR $8::timestamptz IS NULL)
+	AND (created_at < $9 OR $9::timestamptz IS NULL)
+	AND (id IN (SELECT movie_id FROM collection_movies WHERE collection_id = $10) OR $10 = 0)`
+
+func (q MovieQuery) args() []any {
+	args := []any{q.Title, pq.Array(q.Genres), pq.Array(q.GenresAny), pq.Array(q.GenresNone), q.Status, pq.Array(q.AgeRatings), q.OrgID}
+	args = append(args, q.CreatedRange.args()...)
+	return append(args, q.CollectionID)
+}
+
+// movieOrderBy returns the ORDER BY expression for the filters' sort. The
+// relevance sort ranks movies by how well they match the title search,
+// weighted by where the words were found.
+func movieOrderBy(filters Filters) string {
+	column := filters.sortColumn()
+	if column == "relevance" {
+		column = "ts_rank(search_vector, plainto_tsquery('simple', $1))"
+	}
+	return column + " " + filters.sortDirection()
+}
+
+func (m MovieModel) GetAll(q MovieQuery, filters Filters) ([]*Movie, Metadata, error) {
+	var metadata Metadata
+
+	movies, err := retryRead("Movies.GetAll", func() ([]*Movie, error) {
+		var movies []*Movie
+		var err error
+		movies, metadata, err = m.getAll(q, filters)
+		return movies, err
+	})
+	return movies, metadata, err
+}
+
+func (m MovieModel) getAll(q MovieQuery, filters Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, uuid, created_at, title, coalesce(year, 0), runtime, genres, status, version, coalesce(external_id, ''), coalesce(imdb_id, ''), coalesce(tmdb_id, 0), synopsis, poster_url, tagline, coalesce(age_rating, '')
+	FROM movies %s
+	ORDER BY %s, id ASC
+	LIMIT $11 OFFSET $12`, movieQueryWhere, movieOrderBy(filters))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := append(q.args(), filters.limit(), filters.offset())
 
-	rows, err := m.DB.QueryContext(ctx, query, args...)
+	rows, err := prepared(m.DB, m.stmts).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -184,12 +453,21 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 		err := rows.Scan(
 			&totalRecords,
 			&movie.ID,
+			&movie.UUID,
 			&movie.CreatedAt,
 			&movie.Title,
 			&movie.Year,
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
+			&movie.Status,
 			&movie.Version,
+			&movie.ExternalID,
+			&movie.IMDbID,
+			&movie.TMDbID,
+			&movie.Synopsis,
+			&movie.PosterURL,
+			&movie.Tagline,
+			&movie.AgeRating,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -207,118 +485,27 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	return movies, metadata, nil
 }
 
-type MockMovieModel struct{}
-
-func (m MockMovieModel) Insert(movie *Movie) error {
-	switch movie.Title {
-	case "error":
-		return errors.New("any other errors")
-	}
-	return nil
+// LastModified returns when the movies table was last changed. It is kept
+// up to date by a statement level trigger.
+func (m MovieModel) LastModified() (time.Time, error) {
+	return retryRead("Movies.LastModified", m.lastModified)
 }
 
-func (m MockMovieModel) Get(id int64) (*Movie, error) {
-	switch id {
-	case 1:
-		return &Movie{
-			ID:        1,
-			CreatedAt: time.Now(),
-			Year:      2023,
-			Runtime:   105,
-			Title:     "Test Mock",
-			Genres:    []string{""},
-		}, nil
-	case 2:
-		return nil, errors.New("any other errors")
-	case 3:
-		return &Movie{
-			ID:        3,
-			CreatedAt: time.Now(),
-			Year:      2022,
-			Runtime:   180,
-			Title:     "Test Mock 2",
-			Genres:    []string{"drama"},
-		}, nil
-	case 10:
-		return &Movie{
-			ID:        10,
-			CreatedAt: time.Now(),
-			Year:      1966,
-			Runtime:   100,
-			Title:     "Legends from test mock",
-			Genres:    []string{"mystery"},
-		}, nil
-	default:
-		return nil, ErrRecordNotFound
-	}
-}
-func (m MockMovieModel) Update(movie *Movie) error {
-	switch movie.ID {
-	case 1:
-		return nil
-	case 10:
-		return errors.New("any other errors")
-	default:
-		return ErrEditConflict
-	}
-}
+func (m MovieModel) lastModified() (time.Time, error) {
+	query := `
+	SELECT modified_at
+	FROM table_modifications
+	WHERE table_name = 'movies'`
 
-func (m MockMovieModel) Delete(id int64) error {
-	switch id {
-	case 1:
-		return nil
-	case 2:
-		return errors.New("any other errors")
-	default:
-		return ErrRecordNotFound
-	}
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-func (m MockMovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	if title == "Test" && reflect.DeepEqual(genres, []string{"comedy", "drama"}) {
-		return []*Movie{
-				{
-					ID:        1,
-					CreatedAt: time.Now(),
-					Year:      2023,
-					Runtime:   105,
-					Title:     "Test Mock",
-					Genres:    []string{"drama", "comedy"},
-				},
-			},
-			Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, FirstPage: 1, LastPage: 1, TotalRecords: 1},
-			nil
-	} else if title == "" {
-		return []*Movie{
-				{
-					ID:        1,
-					CreatedAt: time.Now(),
-					Year:      2023,
-					Runtime:   105,
-					Title:     "Test Mock",
-					Genres:    []string{"drama", "comedy"},
-				},
-				{
-					ID:        3,
-					CreatedAt: time.Now(),
-					Year:      2022,
-					Runtime:   180,
-					Title:     "Test Mock 2",
-					Genres:    []string{"drama"},
-				},
-				{
-					ID:        10,
-					CreatedAt: time.Now(),
-					Year:      1966,
-					Runtime:   100,
-					Title:     "Legends from test mock",
-					Genres:    []string{"mystery"},
-				},
-			},
-			Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, FirstPage: 1, LastPage: 1, TotalRecords: 2},
-			nil
-	} else if title == "error" {
-		return nil, Metadata{}, errors.New("any other errors")
+	var modifiedAt time.Time
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(&modifiedAt)
+	if err != nil {
+		return time.Time{}, err
 	}
-	return nil, Metadata{}, nil
+
+	return modifiedAt, nil
 }
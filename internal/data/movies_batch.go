@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	BatchStatusUpdated  = "updated"
+	BatchStatusNotFound = "not_found"
+	BatchStatusConflict = "conflict"
+	BatchStatusInvalid  = "invalid"
+)
+
+// MovieChanges is a partial update of a movie. Fields left out of the input
+// are kept, and all but the title and runtime can be cleared with null.
+type MovieChanges struct {
+	Title     Optional[string]   `json:"title" validate:"notnull,required,max=500"`
+	Year      Optional[int32]    `json:"year" validate:"min=1888"`
+	Runtime   Optional[Runtime]  `json:"runtime" validate:"notnull,required,positive"`
+	Genres    Optional[[]string] `json:"genres" validate:"max=5,unique"`
+	IMDbID    Optional[string]   `json:"imdb_id" validate:"pattern=imdb_id"`
+	TMDbID    Optional[int64]    `json:"tmdb_id" validate:"positive"`
+	Synopsis  Optional[string]   `json:"synopsis" validate:"max=5000"`
+	Tagline   Optional[string]   `json:"tagline" validate:"max=300"`
+	AgeRating Optional[string]   `json:"age_rating" validate:"pattern=age_rating"`
+}
+
+func ValidateMovieChanges(v *validator.Validator, c MovieChanges) {
+	v.Struct(c)
+}
+
+func (c MovieChanges) Apply(movie *Movie) {
+	c.Title.Apply(&movie.Title)
+	c.Year.Apply(&movie.Year)
+	c.Runtime.Apply(&movie.Runtime)
+	c.Genres.Apply(&movie.Genres)
+	c.IMDbID.Apply(&movie.IMDbID)
+	c.TMDbID.Apply(&movie.TMDbID)
+	c.Synopsis.Apply(&movie.Synopsis)
+	c.Tagline.Apply(&movie.Tagline)
+	c.AgeRating.Apply(&movie.AgeRating)
+
+	if movie.Genres == nil {
+		movie.Genres = []string{}
+	}
+}
+
+type MovieBatchItem struct {
+	ID      int64        `json:"id"`
+	Version int32        `json:"version"`
+	Changes MovieChanges `json:"changes"`
+}
+
+type MovieBatchResult struct {
+	ID     int64             `json:"id"`
+	Status string            `json:"status"`
+	Movie  *Movie            `json:"movie,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+func ValidateMovieBatch(v *validator.Validator, items []MovieBatchItem) {
+	v.Check(len(items) >= 1, "items", "must contain at least 1 item")
+	v.Check(len(items) <= 100, "items", "must not contain more than 100 items")
+
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		v.Check(item.ID > 0, "items", "every item must have a positive id")
+		v.Check(item.Version > 0, "items", "every item must have a positive version")
+		ids[i] = item.ID
+	}
+
+	v.Check(validator.Unique(ids), "items", "must not contain duplicate ids")
+}
+
+// applyBatchItem decides the outcome for a single item against the current
+// state of the movie. A nil movie means the record does not exist.
+func applyBatchItem(item MovieBatchItem, movie *Movie) *MovieBatchResult {
+	result := &MovieBatchResult{ID: item.ID}
+
+	switch {
+	case movie == nil:
+		result.Status = BatchStatusNotFound
+		return result
+	case movie.Version != item.Version:
+		result.Status = BatchStatusConflict
+		return result
+	}
+
+	v := validator.New()
+	ValidateMovieChanges(v, item.Changes)
+
+	item.Changes.Apply(movie)
+
+	if ValidateMovie(v, movie); !v.Valid() {
+		result.Status = BatchStatusInvalid
+		result.Errors = v.Errors
+		return result
+	}
+
+	result.Status = BatchStatusUpdated
+	result.Movie = movie
+	return result
+}
+
+func (m MovieModel) UpdateBatch(orgID int64, items []MovieBatchItem, editorID int64) ([]*MovieBatchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT id, uuid, created_at, title, coalesce(year, 0), runtime, genres, status, version, org_id, coalesce(external_id, ''), coalesce(imdb_id, ''), coalesce(tmdb_id, 0), synopsis, poster_url, tagline, coalesce(age_rating, '')
+	FROM movies
+	WHERE id = $1 AND org_id = $2
+	FOR UPDATE`
+
+	results := make([]*MovieBatchResult, 0, len(items))
+
+	for _, item := range items {
+		var movie Movie
+
+		err := tx.QueryRowContext(ctx, query, item.ID, orgID).Scan(
+			&movie.ID,
+			&movie.UUID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.Version,
+			&movie.OrgID,
+			&movie.ExternalID,
+			&movie.IMDbID,
+			&movie.TMDbID,
+			&movie.Synopsis,
+			&movie.PosterURL,
+			&movie.Tagline,
+			&movie.AgeRating,
+		)
+
+		var result *MovieBatchResult
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			result = applyBatchItem(item, nil)
+		case err != nil:
+			return nil, err
+		default:
+			result = applyBatchItem(item, &movie)
+		}
+
+		if result.Status == BatchStatusUpdated {
+			err = updateMovieTx(ctx, tx, result.Movie, editorID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
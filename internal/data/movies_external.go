@@ -0,0 +1,185 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	UpsertCreated   = "created"
+	UpsertUpdated   = "updated"
+	UpsertUnchanged = "unchanged"
+)
+
+func ValidateIMDbID(v *validator.Validator, imdbID string) {
+	v.Check(validator.Matches(imdbID, IMDbIDRX), "imdb_id", "must look like tt0111161")
+}
+
+func ValidateExternalID(v *validator.Validator, externalID string) {
+	v.Check(externalID != "", "external_id", "must be provided")
+	v.Check(len(externalID) <= 200, "external_id", "must not be more than 200 bytes long")
+}
+
+func (m MovieModel) GetByExternalID(orgID int64, externalID string) (*Movie, error) {
+	return retryRead("Movies.GetByExternalID", func() (*Movie, error) { return m.getByColumn(orgID, "external_id", externalID) })
+}
+
+// GetByUUID returns the movie in the organization with the UUID.
+func (m MovieModel) GetByUUID(orgID int64, uuid string) (*Movie, error) {
+	return retryRead("Movies.GetByUUID", func() (*Movie, error) { return m.getByColumn(orgID, "uuid", uuid) })
+}
+
+// GetByIMDbID returns the movie mapped to the IMDb title ID, such as
+// tt0111161.
+func (m MovieModel) GetByIMDbID(orgID int64, imdbID string) (*Movie, error) {
+	return retryRead("Movies.GetByIMDbID", func() (*Movie, error) { return m.getByColumn(orgID, "imdb_id", imdbID) })
+}
+
+// getByColumn fetches the movie in the organization whose column equals
+// value. column must be one of the uniquely indexed catalog ID columns.
+func (m MovieModel) getByColumn(orgID int64, column string, value any) (*Movie, error) {
+	query := fmt.Sprintf(`
+	SELECT id, uuid, created_at, title, coalesce(year, 0), runtime, genres, status, version, org_id, coalesce(external_id, ''), coalesce(imdb_id, ''), coalesce(tmdb_id, 0), synopsis, poster_url, tagline, coalesce(age_rating, '')
+	FROM movies
+	WHERE org_id = $1 AND %s = $2`, column)
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, orgID, value).Scan(
+		&movie.ID,
+		&movie.UUID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Status,
+		&movie.Version,
+		&movie.OrgID,
+		&movie.ExternalID,
+		&movie.IMDbID,
+		&movie.TMDbID,
+		&movie.Synopsis,
+		&movie.PosterURL,
+		&movie.Tagline,
+		&movie.AgeRating,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &movie, nil
+}
+
+// Upsert creates the movie identified by movie.OrgID and movie.ExternalID, or
+// replaces the details and catalog IDs of the existing one. It returns
+// UpsertCreated, UpsertUpdated or UpsertUnchanged and fills movie with the
+// stored record. New movies take movie.Status; existing movies keep theirs.
+// ErrEditConflict is returned if a concurrent upsert won the race, in which
+// case the call can safely be repeated.
+func (m MovieModel) Upsert(movie *Movie, editorID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT id, uuid, created_at, status, version, title, coalesce(year, 0), runtime, genres, coalesce(imdb_id, ''), coalesce(tmdb_id, 0), synopsis, poster_url, tagline, coalesce(age_rating, '')
+	FROM movies
+	WHERE org_id = $1 AND external_id = $2
+	FOR UPDATE`
+
+	var existing Movie
+
+	err = tx.QueryRowContext(ctx, query, movie.OrgID, movie.ExternalID).Scan(
+		&existing.ID,
+		&existing.UUID,
+		&existing.CreatedAt,
+		&existing.Status,
+		&existing.Version,
+		&existing.Title,
+		&existing.Year,
+		&existing.Runtime,
+		pq.Array(&existing.Genres),
+		&existing.IMDbID,
+		&existing.TMDbID,
+		&existing.Synopsis,
+		&existing.PosterURL,
+		&existing.Tagline,
+		&existing.AgeRating,
+	)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		query = `
+		INSERT INTO movies (title, year, runtime, genres, status, org_id, external_id, imdb_id, tmdb_id, uuid)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9::bigint, 0), coalesce(NULLIF($10, '')::uuid, gen_random_uuid()))
+		ON CONFLICT (org_id, external_id) DO NOTHING
+		RETURNING id, uuid, created_at, version`
+
+		args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.Status, movie.OrgID, movie.ExternalID, movie.IMDbID, movie.TMDbID, movie.UUID}
+
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.UUID, &movie.CreatedAt, &movie.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return "", ErrEditConflict
+			default:
+				return "", movieWriteError(err)
+			}
+		}
+
+		return UpsertCreated, tx.Commit()
+	case err != nil:
+		return "", err
+	}
+
+	movie.ID = existing.ID
+	movie.UUID = existing.UUID
+	movie.CreatedAt = existing.CreatedAt
+	movie.Status = existing.Status
+	movie.Version = existing.Version
+	movie.Synopsis = existing.Synopsis
+	movie.Tagline = existing.Tagline
+	movie.AgeRating = existing.AgeRating
+	movie.PosterURL = existing.PosterURL
+
+	if sameMovieDetails(movie, &existing) {
+		return UpsertUnchanged, nil
+	}
+
+	err = updateMovieTx(ctx, tx, movie, editorID)
+	if err != nil {
+		return "", err
+	}
+
+	return UpsertUpdated, tx.Commit()
+}
+
+func sameMovieDetails(a, b *Movie) bool {
+	return a.Title == b.Title &&
+		a.Year == b.Year &&
+		a.Runtime == b.Runtime &&
+		reflect.DeepEqual(a.Genres, b.Genres) &&
+		a.IMDbID == b.IMDbID &&
+		a.TMDbID == b.TMDbID
+}
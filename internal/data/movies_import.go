@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lib/pq"
+)
+
+// importColumns are the movies columns InsertMany fills in. The rest keep
+// their defaults.
+var importColumns = []string{"title", "year", "runtime", "genres", "status", "org_id", "imdb_id", "tmdb_id", "synopsis", "poster_url", "uuid", "tagline", "age_rating"}
+
+// InsertMany inserts the movies in one transaction, so that either all of
+// them are created or none are. Every movie must have a UUID, by which the
+// IDs are read back. With the pgx driver the rows are sent with COPY, and
+// otherwise they are inserted one at a time.
+func (m MovieModel) InsertMany(movies []*Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if m.Pool == nil {
+		return m.insertMany(ctx, movies)
+	}
+
+	tx, err := m.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows := make([][]any, len(movies))
+	for i, movie := range movies {
+		var uuid pgtype.UUID
+		if err := uuid.Scan(movie.UUID); err != nil {
+			return err
+		}
+
+		rows[i] = []any{
+			movie.Title,
+			nullIfZero(movie.Year),
+			int32(movie.Runtime),
+			movie.Genres,
+			movie.Status,
+			movie.OrgID,
+			nullIfZero(movie.IMDbID),
+			nullIfZero(movie.TMDbID),
+			movie.Synopsis,
+			movie.PosterURL,
+			uuid,
+			movie.Tagline,
+			nullIfZero(movie.AgeRating),
+		}
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"movies"}, importColumns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return movieWriteError(err)
+	}
+
+	uuids := make([]string, len(movies))
+	byUUID := make(map[string]*Movie, len(movies))
+	for i, movie := range movies {
+		uuids[i] = movie.UUID
+		byUUID[movie.UUID] = movie
+	}
+
+	query := `
+	SELECT id, uuid::text, created_at, version
+	FROM movies
+	WHERE org_id = $1 AND uuid = ANY($2::uuid[])`
+
+	result, err := tx.Query(ctx, query, movies[0].OrgID, uuids)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var id int64
+		var uuid string
+		var createdAt time.Time
+		var version int32
+
+		err := result.Scan(&id, &uuid, &createdAt, &version)
+		if err != nil {
+			return err
+		}
+
+		if movie, ok := byUUID[uuid]; ok {
+			movie.ID = id
+			movie.CreatedAt = createdAt
+			movie.Version = version
+		}
+	}
+
+	if err = result.Err(); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (m MovieModel) insertMany(ctx context.Context, movies []*Movie) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+INSERT INTO movies (title, year, runtime, genres, status, org_id, imdb_id, tmdb_id, synopsis, poster_url, uuid, tagline, age_rating)
+VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8::bigint, 0), $9, $10, $11, $12, NULLIF($13, ''))
+RETURNING id, created_at, version`
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, movie := range movies {
+		args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.Status, movie.OrgID, movie.IMDbID, movie.TMDbID, movie.Synopsis, movie.PosterURL, movie.UUID, movie.Tagline, movie.AgeRating}
+
+		err := stmt.QueryRowContext(ctx, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+		if err != nil {
+			return movieWriteError(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// nullIfZero returns nil for the zero value, which COPY writes as NULL, as
+// NULLIF does for the other queries.
+func nullIfZero[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// GetRandom returns one movie matching the query. Rather than sorting the
+// whole table with ORDER BY random(), it picks a random id between the
+// lowest and highest id and returns the first match at or after it, wrapping
+// around to the start of the table. Both lookups walk the primary key index.
+// Movies that follow large gaps in the id sequence are slightly favoured.
+func (m MovieModel) GetRandom(q MovieQuery) (*Movie, error) {
+	return retryRead("Movies.GetRandom", func() (*Movie, error) { return m.getRandom(q) })
+}
+
+func (m MovieModel) getRandom(q MovieQuery) (*Movie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var lo, hi int64
+
+	err := m.DB.QueryRowContext(ctx, `SELECT coalesce(min(id), 0), coalesce(max(id), 0) FROM movies`).Scan(&lo, &hi)
+	if err != nil {
+		return nil, err
+	}
+
+	if hi == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	(SELECT id, uuid, created_at, title, coalesce(year, 0), runtime, genres, status, version, coalesce(external_id, ''), coalesce(imdb_id, ''), coalesce(tmdb_id, 0), synopsis, poster_url, tagline, coalesce(age_rating, '')
+	FROM movies` + movieQueryWhere + `
+	AND id >= $11
+	ORDER BY id
+	LIMIT 1)
+	UNION ALL
+	(SELECT id, uuid, created_at, title, coalesce(year, 0), runtime, genres, status, version, coalesce(external_id, ''), coalesce(imdb_id, ''), coalesce(tmdb_id, 0), synopsis, poster_url, tagline, coalesce(age_rating, '')
+	FROM movies` + movieQueryWhere + `
+	AND id < $11
+	ORDER BY id
+	LIMIT 1)
+	LIMIT 1`
+
+	args := append(q.args(), lo+rand.Int63n(hi-lo+1))
+
+	var movie Movie
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&movie.ID,
+		&movie.UUID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Status,
+		&movie.Version,
+		&movie.ExternalID,
+		&movie.IMDbID,
+		&movie.TMDbID,
+		&movie.Synopsis,
+		&movie.PosterURL,
+		&movie.Tagline,
+		&movie.AgeRating,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &movie, nil
+}
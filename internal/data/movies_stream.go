@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Stream calls fn with each movie matching the query, in the filters' sort
+// order, as the rows are read from the database. Unlike GetAll it ignores
+// the page and page size and does not count the matches, so that rows can
+// be sent on before the last is found. It stops at the first error fn
+// returns, or when ctx is done.
+func (m MovieModel) Stream(ctx context.Context, q MovieQuery, filters Filters, fn func(*Movie) error) error {
+	query := fmt.Sprintf(`
+	SELECT id, uuid, created_at, title, coalesce(year, 0), runtime, genres, status, version, coalesce(external_id, ''), coalesce(imdb_id, ''), coalesce(tmdb_id, 0), synopsis, poster_url, tagline, coalesce(age_rating, '')
+	FROM movies %s
+	ORDER BY %s, id ASC`, movieQueryWhere, movieOrderBy(filters))
+
+	rows, err := prepared(m.DB, m.stmts).QueryContext(ctx, query, q.args()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.UUID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.Version,
+			&movie.ExternalID,
+			&movie.IMDbID,
+			&movie.TMDbID,
+			&movie.Synopsis,
+			&movie.PosterURL,
+			&movie.Tagline,
+			&movie.AgeRating,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(&movie); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	NotificationCommentReply     = "comment_reply"
+	NotificationNewFollower      = "new_follower"
+	NotificationReviewApproved   = "review_approved"
+	NotificationListMovieUpdated = "list_movie_updated"
+)
+
+type Notification struct {
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    int64      `json:"-"`
+	Kind      string     `json:"kind"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+func (m NotificationModel) Insert(notification *Notification) error {
+	query := `
+	INSERT INTO notifications (user_id, kind, message)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at`
+
+	args := []any{notification.UserID, notification.Kind, notification.Message}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&notification.ID, &notification.CreatedAt)
+}
+
+func (m NotificationModel) GetAllForUser(userID int64, unreadOnly bool, filters Filters) ([]*Notification, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, created_at, user_id, kind, message, read_at
+	FROM notifications
+	WHERE user_id = $1
+	AND (read_at IS NULL OR NOT $2)
+	ORDER BY created_at DESC, id DESC
+	LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, unreadOnly, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var notification Notification
+
+		err := rows.Scan(
+			&totalRecords,
+			&notification.ID,
+			&notification.CreatedAt,
+			&notification.UserID,
+			&notification.Kind,
+			&notification.Message,
+			&notification.ReadAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		notifications = append(notifications, &notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return notifications, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m NotificationModel) CountUnread(userID int64) (int, error) {
+	query := `
+	SELECT count(*)
+	FROM notifications
+	WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks the notification as read if it belongs to the user. Reading
+// an already read notification keeps its original read time.
+func (m NotificationModel) MarkRead(id, userID int64) (*Notification, error) {
+	query := `
+	UPDATE notifications
+	SET read_at = COALESCE(read_at, NOW())
+	WHERE id = $1 AND user_id = $2
+	RETURNING id, created_at, user_id, kind, message, read_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var notification Notification
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&notification.ID,
+		&notification.CreatedAt,
+		&notification.UserID,
+		&notification.Kind,
+		&notification.Message,
+		&notification.ReadAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &notification, nil
+}
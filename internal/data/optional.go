@@ -0,0 +1,51 @@
+package data
+
+import "encoding/json"
+
+// Optional is a field of a partial update. It tells apart a JSON field that
+// was left out, which leaves the stored value alone, from one that was set to
+// null, which clears it.
+type Optional[T any] struct {
+	Value T
+	Set   bool
+	Null  bool
+}
+
+// Some returns an Optional holding value, as if it had been decoded from
+// JSON.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{Value: value, Set: true}
+}
+
+func (o *Optional[T]) UnmarshalJSON(b []byte) error {
+	o.Set = true
+
+	if string(b) == "null" {
+		var zero T
+		o.Value, o.Null = zero, true
+		return nil
+	}
+
+	o.Null = false
+	return json.Unmarshal(b, &o.Value)
+}
+
+// Apply copies the value into dst if the field was present in the input. A
+// null resets dst to the zero value.
+func (o Optional[T]) Apply(dst *T) {
+	if o.Set {
+		*dst = o.Value
+	}
+}
+
+func (o Optional[T]) IsSet() bool {
+	return o.Set
+}
+
+func (o Optional[T]) IsNull() bool {
+	return o.Null
+}
+
+func (o Optional[T]) Interface() any {
+	return o.Value
+}
@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleMember = "member"
+)
+
+var Roles = []string{RoleOwner, RoleMember}
+
+var ErrDuplicateMembership = errors.New("duplicate membership")
+
+type Organization struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"`
+}
+
+func ValidateOrganization(v *validator.Validator, org *Organization) {
+	v.Check(org.Name != "", "name", "must be provided")
+	v.Check(len(org.Name) <= 200, "name", "must not be more than 200 bytes long")
+}
+
+type OrganizationModel struct {
+	DB *sql.DB
+}
+
+// Insert creates the organization and makes ownerID its owner.
+func (m OrganizationModel) Insert(org *Organization, ownerID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO organizations (name)
+	VALUES ($1)
+	RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, org.Name).Scan(&org.ID, &org.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO memberships (organization_id, user_id, role)
+	VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, org.ID, ownerID, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	org.Role = RoleOwner
+
+	return tx.Commit()
+}
+
+func (m OrganizationModel) GetAllForUser(userID int64) ([]*Organization, error) {
+	query := `
+	SELECT organizations.id, organizations.created_at, organizations.name, memberships.role
+	FROM organizations
+	INNER JOIN memberships ON memberships.organization_id = organizations.id
+	WHERE memberships.user_id = $1
+	ORDER BY memberships.created_at, organizations.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []*Organization{}
+	for rows.Next() {
+		var org Organization
+		err := rows.Scan(&org.ID, &org.CreatedAt, &org.Name, &org.Role)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, &org)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// GetRole returns the user's role in the organization, or ErrRecordNotFound
+// if they are not a member.
+func (m OrganizationModel) GetRole(orgID, userID int64) (string, error) {
+	query := `
+	SELECT role
+	FROM memberships
+	WHERE organization_id = $1 AND user_id = $2`
+
+	var role string
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, orgID, userID).Scan(&role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return role, nil
+}
+
+// GetDefaultForUser returns the organization the user joined first, which is
+// used when they log in without choosing one.
+func (m OrganizationModel) GetDefaultForUser(userID int64) (int64, error) {
+	query := `
+	SELECT organization_id
+	FROM memberships
+	WHERE user_id = $1
+	ORDER BY created_at, organization_id
+	LIMIT 1`
+
+	var orgID int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&orgID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return orgID, nil
+}
+
+func (m OrganizationModel) AddMember(orgID, userID int64, role string) error {
+	query := `
+	INSERT INTO memberships (organization_id, user_id, role)
+	VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, orgID, userID, role)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "memberships_pkey"):
+			return ErrDuplicateMembership
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package data
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"greenlight.bcc/internal/validator"
+)
+
+// PasswordPolicy is what a new password must satisfy, on top of
+// ValidatePasswordPlaintext. Passwords are only checked against it when
+// they are set, so tightening it does not lock anyone out. Zero fields
+// disable their checks.
+type PasswordPolicy struct {
+	// MinLength is the fewest characters a password may have.
+	MinLength int
+	// MaxLength is the most bytes a password may have.
+	MaxLength int
+	// MinClasses is how many of lowercase letters, uppercase letters,
+	// digits and symbols a password must contain.
+	MinClasses int
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8, MaxLength: 72}
+
+func (p PasswordPolicy) Validate(v *validator.Validator, password string) {
+	if p.MinLength > 0 {
+		v.Check(utf8.RuneCountInString(password) >= p.MinLength, "password", fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 {
+		v.Check(len(password) <= p.MaxLength, "password", fmt.Sprintf("must not be more than %d bytes long", p.MaxLength))
+	}
+	if p.MinClasses > 0 {
+		v.Check(passwordClasses(password) >= p.MinClasses, "password", fmt.Sprintf("must contain at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses))
+	}
+}
+
+func passwordClasses(password string) int {
+	var lower, upper, digit, symbol int
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
+
+// Argon2idParams are the cost parameters of Argon2id password hashes. A
+// hash records the parameters it was made with, so they can be raised
+// without invalidating existing hashes; see password.NeedsRehash.
+type Argon2idParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// PasswordHashing is the parameters new password hashes are made with.
+var PasswordHashing = Argon2idParams{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+
+// DefaultPasswordHashingMemoryLimit is 512 MiB, in KiB: eight hashes with
+// the default parameters.
+const DefaultPasswordHashingMemoryLimit = 512 * 1024
+
+// argon2idMemory bounds the memory used by the Argon2id hashes computed at
+// once. Each hash holds its Memory parameter until it is done, and the rest
+// wait, so a burst of logins cannot run the process out of memory.
+var argon2idMemory = newMemoryLimiter(DefaultPasswordHashingMemoryLimit)
+
+// LimitPasswordHashingMemory sets the KiB which the Argon2id hashes being
+// computed at once may use between them. A hash needing more than that runs
+// on its own.
+func LimitPasswordHashingMemory(kib uint64) {
+	argon2idMemory.mu.Lock()
+	defer argon2idMemory.mu.Unlock()
+
+	argon2idMemory.limit = kib
+	argon2idMemory.cond.Broadcast()
+}
+
+// memoryLimiter is a semaphore weighted by KiB.
+type memoryLimiter struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit uint64
+	used  uint64
+}
+
+func newMemoryLimiter(kib uint64) *memoryLimiter {
+	l := &memoryLimiter{limit: kib}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *memoryLimiter) acquire(kib uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.used > 0 && l.used+kib > l.limit {
+		l.cond.Wait()
+	}
+	l.used += kib
+}
+
+func (l *memoryLimiter) release(kib uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.used -= kib
+	l.cond.Broadcast()
+}
+
+// idKey computes an Argon2id key within the memory limit.
+func (p Argon2idParams) idKey(password string, salt []byte) []byte {
+	argon2idMemory.acquire(uint64(p.Memory))
+	defer argon2idMemory.release(uint64(p.Memory))
+
+	return argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+}
+
+const argon2idPrefix = "$argon2id$"
+
+var errInvalidArgon2idHash = errors.New("invalid argon2id hash")
+
+// hash returns the hash of password in the PHC string format, as in
+// $argon2id$v=19$m=65536,t=3,p=2$salt$key.
+func (p Argon2idParams) hash(password string) ([]byte, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := p.idKey(password, salt)
+
+	encoded := fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+
+	return []byte(encoded), nil
+}
+
+func isArgon2idHash(hash []byte) bool {
+	return bytes.HasPrefix(hash, []byte(argon2idPrefix))
+}
+
+// parseArgon2idHash returns the parameters, salt and key of a hash made by
+// Argon2idParams.hash.
+func parseArgon2idHash(hash []byte) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errInvalidArgon2idHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errInvalidArgon2idHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, errInvalidArgon2idHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, errInvalidArgon2idHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, errInvalidArgon2idHash
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
+
+func matchesArgon2id(hash []byte, password string) (bool, error) {
+	p, salt, key, err := parseArgon2idHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := p.idKey(password, salt)
+
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+var dummyPassword struct {
+	sync.Mutex
+	params Argon2idParams
+	hash   []byte
+}
+
+// MatchesNoPassword takes as long as checking plaintextPassword against a
+// user's hash would, for when there is no such user, so that the time a
+// login takes does not reveal whether an account exists. The hash it
+// checks against is made on first use, and again if PasswordHashing
+// changes.
+func MatchesNoPassword(plaintextPassword string) {
+	dummyPassword.Lock()
+	if dummyPassword.hash == nil || dummyPassword.params != PasswordHashing {
+		hash, err := PasswordHashing.hash("not a password")
+		if err == nil {
+			dummyPassword.params, dummyPassword.hash = PasswordHashing, hash
+		}
+	}
+	hash := dummyPassword.hash
+	dummyPassword.Unlock()
+
+	if hash != nil {
+		matchesArgon2id(hash, plaintextPassword)
+	}
+}
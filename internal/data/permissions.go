@@ -24,6 +24,10 @@ type PermissionModel struct {
 }
 
 func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	return retryRead("Permissions.GetAllForUser", func() (Permissions, error) { return m.getAllForUser(userID) })
+}
+
+func (m PermissionModel) getAllForUser(userID int64) (Permissions, error) {
 	query := `
 	SELECT permissions.code
 	FROM permissions
@@ -67,13 +71,3 @@ func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
 	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
 	return err
 }
-
-type MockPermissionModel struct{}
-
-func (m MockPermissionModel) GetAllForUser(userID int64) (Permissions, error) {
-	return nil, nil
-}
-
-func (m MockPermissionModel) AddForUser(userID int64, codes ...string) error {
-	return nil
-}
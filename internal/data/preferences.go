@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	NotificationMarketing = "marketing"
+	NotificationSecurity  = "security"
+	NotificationDigest    = "digest"
+)
+
+const (
+	DigestNever  = "never"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+var DigestFrequencies = []string{DigestNever, DigestDaily, DigestWeekly}
+
+type NotificationPreferences struct {
+	MarketingEmails bool   `json:"marketing_emails"`
+	SecurityAlerts  bool   `json:"security_alerts"`
+	DigestFrequency string `json:"digest_frequency"`
+}
+
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{
+		MarketingEmails: false,
+		SecurityAlerts:  true,
+		DigestFrequency: DigestWeekly,
+	}
+}
+
+// Allows reports whether the user wants to receive non-transactional mail
+// of the given category.
+func (p NotificationPreferences) Allows(category string) bool {
+	switch category {
+	case NotificationMarketing:
+		return p.MarketingEmails
+	case NotificationSecurity:
+		return p.SecurityAlerts
+	case NotificationDigest:
+		return p.DigestFrequency != DigestNever
+	default:
+		return true
+	}
+}
+
+func (p NotificationPreferences) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *NotificationPreferences) Scan(src any) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("notification preferences: unsupported source type")
+	}
+	return json.Unmarshal(b, p)
+}
+
+func ValidateNotificationPreferences(v *validator.Validator, p NotificationPreferences) {
+	v.Check(validator.PermittedValue(p.DigestFrequency, DigestFrequencies...), "digest_frequency", "must be one of never, daily or weekly")
+}
+
+func (m UserModel) GetPreferences(userID int64) (*NotificationPreferences, error) {
+	query := `
+	SELECT notification_preferences
+	FROM users
+	WHERE id = $1`
+
+	var prefs NotificationPreferences
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&prefs)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &prefs, nil
+}
+
+// UpdatePreferences stores the user's notification preferences. Like a
+// password rehash, it leaves the user's version alone, so it does not
+// conflict with edits of the profile.
+func (m UserModel) UpdatePreferences(userID int64, prefs *NotificationPreferences) error {
+	query := `
+	UPDATE users
+	SET notification_preferences = $1
+	WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, prefs, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// EmailAllowed reports whether the owner of email accepts mail of the given
+// category. Addresses which do not belong to a user are allowed.
+func (m UserModel) EmailAllowed(email, category string) (bool, error) {
+	query := `
+	SELECT notification_preferences
+	FROM users
+	WHERE email IN ($1, $2)`
+
+	var prefs NotificationPreferences
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	plaintext, index := m.emailLookup(email)
+	err := m.DB.QueryRowContext(ctx, query, plaintext, index).Scan(&prefs)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return true, nil
+		default:
+			return false, err
+		}
+	}
+
+	return prefs.Allows(category), nil
+}
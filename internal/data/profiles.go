@@ -0,0 +1,200 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// A public profile can be seen by anyone, a followers-only profile by the
+// user's followers and a private profile by the user alone. Other viewers
+// only see the user's name.
+const (
+	PrivacyPublic    = "public"
+	PrivacyFollowers = "followers"
+	PrivacyPrivate   = "private"
+)
+
+var PrivacySettings = []string{PrivacyPublic, PrivacyFollowers, PrivacyPrivate}
+
+// Profile is what other users can see of a user. Stats, Lists and Comments
+// are left empty unless the profile is visible to the viewer.
+type Profile struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	AvatarURL string        `json:"avatar_url,omitempty"`
+	Privacy   string        `json:"privacy"`
+	Visible   bool          `json:"-"`
+	Stats     *ProfileStats `json:"stats,omitempty"`
+	Lists     []*List       `json:"lists,omitempty"`
+	Comments  []*Comment    `json:"comments,omitempty"`
+	// AvatarThumbnails are filled in by the API, as for users.
+	AvatarThumbnails map[string]string `json:"avatar_thumbnails,omitempty"`
+}
+
+type ProfileStats struct {
+	MemberSince    time.Time `json:"member_since"`
+	FollowersCount int       `json:"followers_count"`
+	FollowingCount int       `json:"following_count"`
+}
+
+func ValidatePrivacy(v *validator.Validator, privacy string) {
+	v.Check(validator.PermittedValue(privacy, PrivacySettings...), "privacy", "must be one of public, followers or private")
+}
+
+type ProfileModel struct {
+	DB *sql.DB
+}
+
+// Get returns the profile of userID as viewerID sees it, deciding whether it
+// is visible to them. Banned users and users who have blocked the viewer
+// have no profile.
+func (m ProfileModel) Get(userID, viewerID int64) (*Profile, error) {
+	query := `
+	SELECT id, name, avatar_url, profile_privacy, created_at, followers_count, following_count,
+		id = $2 OR profile_privacy = 'public' OR (profile_privacy = 'followers'
+			AND EXISTS (SELECT 1 FROM user_follows WHERE follower_id = $2 AND followed_id = users.id))
+	FROM users
+	WHERE id = $1 AND NOT banned
+	AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = users.id AND blocked_id = $2 AND kind = 'block')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var profile Profile
+	var stats ProfileStats
+
+	err := m.DB.QueryRowContext(ctx, query, userID, viewerID).Scan(
+		&profile.ID,
+		&profile.Name,
+		&profile.AvatarURL,
+		&profile.Privacy,
+		&stats.MemberSince,
+		&stats.FollowersCount,
+		&stats.FollowingCount,
+		&profile.Visible,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if profile.Visible {
+		profile.Stats = &stats
+	}
+
+	return &profile, nil
+}
+
+func (m ProfileModel) SetPrivacy(userID int64, privacy string) error {
+	query := `
+	UPDATE users
+	SET profile_privacy = $1
+	WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, privacy, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetLists returns the user's most recent lists which are public in orgID.
+func (m ProfileModel) GetLists(userID, orgID int64, limit int) ([]*List, error) {
+	query := `
+	SELECT ` + listColumns + `
+	FROM lists
+	WHERE user_id = $1 AND org_id = $2 AND public
+	ORDER BY created_at DESC, id DESC
+	LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, orgID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+
+	for rows.Next() {
+		var list List
+
+		err := rows.Scan(list.dest()...)
+		if err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, &list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// GetComments returns the user's most recent comments on the published
+// movies of orgID, leaving out deleted and hidden ones.
+func (m ProfileModel) GetComments(userID, orgID int64, limit int) ([]*Comment, error) {
+	query := `
+	SELECT c.id, c.created_at, c.edited_at, c.deleted_at, c.hidden_at, c.movie_id, c.user_id,
+		coalesce(c.parent_id, 0), coalesce(c.thread_id, 0), c.depth, c.body
+	FROM comments c
+	INNER JOIN movies ON movies.id = c.movie_id
+	WHERE c.user_id = $1 AND movies.org_id = $2 AND movies.status = 'published'
+	AND c.deleted_at IS NULL AND c.hidden_at IS NULL
+	ORDER BY c.created_at DESC, c.id DESC
+	LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, orgID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		var comment Comment
+
+		err := rows.Scan(comment.dest()...)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, &comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	RankingMostListed = "most-listed"
+	RankingTrending   = "trending"
+)
+
+// trendingWindow is how far back comments and list additions count towards
+// a movie trending. It matches the movie_rankings view.
+const trendingWindow = 7 * 24 * time.Hour
+
+// rankingColumns are the movie_rankings columns the rankings are ordered by.
+var rankingColumns = map[string]string{
+	RankingMostListed: "list_count",
+	RankingTrending:   "trending_score",
+}
+
+// RankedMovie is a published movie with its score in a ranking.
+type RankedMovie struct {
+	*Movie
+	Score int `json:"score"`
+}
+
+// MovieRankingModel reads the movie_rankings materialized view, which is
+// refreshed on a schedule rather than on every request.
+type MovieRankingModel struct {
+	DB *sql.DB
+}
+
+// Get returns the highest scoring movies of the organization in the
+// ranking. Movies scoring nothing are left out.
+func (m MovieRankingModel) Get(orgID int64, ranking string, limit int) ([]*RankedMovie, error) {
+	column, ok := rankingColumns[ranking]
+	if !ok {
+		panic("unknown ranking: " + ranking)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT r.%[1]s, m.id, m.uuid, m.created_at, m.title, coalesce(m.year, 0), m.runtime, m.genres, m.status, m.version, coalesce(m.external_id, ''), coalesce(m.imdb_id, ''), coalesce(m.tmdb_id, 0), m.synopsis, m.poster_url, m.tagline, coalesce(m.age_rating, '')
+	FROM movie_rankings r
+	INNER JOIN movies m ON m.id = r.movie_id
+	WHERE r.org_id = $1 AND r.%[1]s > 0
+	ORDER BY r.%[1]s DESC, r.movie_id ASC
+	LIMIT $2`, column)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, orgID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranked := []*RankedMovie{}
+
+	for rows.Next() {
+		movie := RankedMovie{Movie: &Movie{OrgID: orgID}}
+
+		err := rows.Scan(
+			&movie.Score,
+			&movie.ID,
+			&movie.UUID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Status,
+			&movie.Version,
+			&movie.ExternalID,
+			&movie.IMDbID,
+			&movie.TMDbID,
+			&movie.Synopsis,
+			&movie.PosterURL,
+			&movie.Tagline,
+			&movie.AgeRating,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		ranked = append(ranked, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ranked, nil
+}
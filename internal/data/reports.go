@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// ReportContentComment is the only kind of content which can be reported
+// for now.
+const ReportContentComment = "comment"
+
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+)
+
+// The actions a moderator can take on a report. Hiding takes the content
+// down; banning does so too and stops its author from signing in.
+const (
+	ReportActionDismiss = "dismiss"
+	ReportActionHide    = "hide"
+	ReportActionBan     = "ban"
+)
+
+var ErrDuplicateReport = errors.New("duplicate report")
+
+// Report flags a piece of content for the moderators. A report is resolved
+// along with every other open report on the same content.
+type Report struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReporterID  int64      `json:"reporter_id"`
+	ContentType string     `json:"content_type" validate:"required,oneof=comment"`
+	ContentID   int64      `json:"content_id" validate:"required,positive"`
+	Reason      string     `json:"reason" validate:"required,max=1000"`
+	Status      string     `json:"status"`
+	Action      string     `json:"action,omitempty"`
+	ResolvedBy  int64      `json:"resolved_by,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+func ValidateReport(v *validator.Validator, report *Report) {
+	v.Struct(report)
+}
+
+func ValidateReportAction(v *validator.Validator, action string) {
+	v.Check(action != "", "action", "must be provided")
+	v.Check(validator.PermittedValue(action, ReportActionDismiss, ReportActionHide, ReportActionBan), "action", "must be one of dismiss, hide or ban")
+}
+
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// Insert files the report. A user can report the same content only once.
+func (m ReportModel) Insert(report *Report) error {
+	query := `
+	INSERT INTO reports (reporter_id, content_type, content_id, reason)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, status`
+
+	args := []any{report.ReporterID, report.ContentType, report.ContentID, report.Reason}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&report.ID, &report.CreatedAt, &report.Status)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "reports_reporter_content_key"):
+			return ErrDuplicateReport
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ReportModel) Get(id int64) (*Report, error) {
+	query := `
+	SELECT id, created_at, reporter_id, content_type, content_id, reason, status, action, coalesce(resolved_by, 0), resolved_at
+	FROM reports
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var report Report
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(report.dest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &report, nil
+}
+
+// GetOpen returns the moderation queue: the open reports, oldest first.
+func (m ReportModel) GetOpen(filters Filters) ([]*Report, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, created_at, reporter_id, content_type, content_id, reason, status, action, coalesce(resolved_by, 0), resolved_at
+	FROM reports
+	WHERE status = 'open'
+	ORDER BY created_at, id
+	LIMIT $1 OFFSET $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	reports := []*Report{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var report Report
+
+		err := rows.Scan(append([]any{&totalRecords}, report.dest()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reports = append(reports, &report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return reports, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Resolve records the moderator's action on the report and on every other
+// open report about the same content. It returns ErrEditConflict if the
+// report has been resolved in the meantime.
+func (m ReportModel) Resolve(report *Report, action string, moderatorID int64) error {
+	query := `
+	UPDATE reports
+	SET status = 'resolved', action = $1, resolved_by = $2, resolved_at = NOW()
+	WHERE content_type = $3 AND content_id = $4 AND status = 'open'
+	RETURNING id, status, action, resolved_by, resolved_at`
+
+	args := []any{action, moderatorID, report.ContentType, report.ContentID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	resolved := false
+
+	for rows.Next() {
+		var r Report
+
+		err := rows.Scan(&r.ID, &r.Status, &r.Action, &r.ResolvedBy, &r.ResolvedAt)
+		if err != nil {
+			return err
+		}
+
+		if r.ID == report.ID {
+			report.Status, report.Action, report.ResolvedBy, report.ResolvedAt = r.Status, r.Action, r.ResolvedBy, r.ResolvedAt
+			resolved = true
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if !resolved {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+func (r *Report) dest() []any {
+	return []any{&r.ID, &r.CreatedAt, &r.ReporterID, &r.ContentType, &r.ContentID, &r.Reason, &r.Status, &r.Action, &r.ResolvedBy, &r.ResolvedAt}
+}
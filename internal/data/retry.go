@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// WithRetry calls fn until it returns something other than ErrEditConflict,
+// at most attempts times, pausing briefly between attempts. fn must re-fetch
+// the record and reapply its change each time it is called. Only use it where
+// applying the change to whatever the latest version is remains correct, such
+// as server-initiated updates; client edits should still surface the
+// conflict.
+func WithRetry(attempts int, fn func() error) error {
+	var err error
+
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			backoff := time.Duration(10<<i) * time.Millisecond
+			time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2))))
+		}
+
+		err = fn()
+		if !errors.Is(err, ErrEditConflict) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// RetryPolicy says how often and how patiently idempotent reads are retried
+// after a transient error. Attempts includes the first try. The pause
+// before each retry is picked at random up to an exponentially growing
+// bound, starting at BaseDelay and capped at MaxDelay, so that instances
+// reconnecting after a failover do not all arrive at once.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// ReadRetryPolicy applies to the model methods which retry their reads. It
+// is set once at startup.
+var ReadRetryPolicy = RetryPolicy{Attempts: 3, BaseDelay: 25 * time.Millisecond, MaxDelay: time.Second}
+
+// IsRetryable reports whether err is a transient database failure, after
+// which the same read may well succeed:
+//
+//   - serialization failures and deadlocks;
+//   - the server shutting down, restarting or not yet accepting
+//     connections, as happens while a replica is promoted;
+//   - connection exceptions, connection resets and refused connections.
+//
+// Everything else is not, including constraint violations, syntax errors,
+// ErrRecordNotFound, an open circuit breaker and context cancellation or
+// deadlines, since a statement which has already used up its time would
+// only add to the load by running again.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var code string
+
+	var pqErr *pq.Error
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pqErr):
+		code = string(pqErr.Code)
+	case errors.As(err, &pgErr):
+		code = pgErr.Code
+	}
+
+	if code != "" {
+		switch code {
+		case "40001", "40P01", "57P01", "57P02", "57P03", "53300":
+			return true
+		}
+		return code[:2] == "08"
+	}
+
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE),
+		errors.As(err, &opErr),
+		pgconn.SafeToRetry(err):
+		return true
+	}
+
+	return false
+}
+
+var readRetries = struct {
+	sync.Mutex
+	counts map[string]int64
+}{counts: map[string]int64{}}
+
+// ReadRetries returns how many times each model method has retried a read,
+// keyed by names such as "Movies.Get".
+func ReadRetries() map[string]int64 {
+	readRetries.Lock()
+	defer readRetries.Unlock()
+
+	counts := make(map[string]int64, len(readRetries.counts))
+	for method, n := range readRetries.counts {
+		counts[method] = n
+	}
+	return counts
+}
+
+// retryRead calls fn, which must be an idempotent read, again under
+// ReadRetryPolicy for as long as it fails with a retryable error. method
+// names the caller in ReadRetries.
+func retryRead[T any](method string, fn func() (T, error)) (T, error) {
+	policy := ReadRetryPolicy
+
+	value, err := fn()
+
+	for attempt := 1; attempt < policy.Attempts && IsRetryable(err); attempt++ {
+		readRetries.Lock()
+		readRetries.counts[method]++
+		readRetries.Unlock()
+
+		bound := policy.BaseDelay << (attempt - 1)
+		if bound <= 0 || bound > policy.MaxDelay {
+			bound = policy.MaxDelay
+		}
+		if bound > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(bound))))
+		}
+
+		value, err = fn()
+	}
+
+	return value, err
+}
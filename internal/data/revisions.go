@@ -0,0 +1,140 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type MovieRevision struct {
+	MovieID   int64     `json:"movie_id"`
+	Version   int32     `json:"version"`
+	Title     string    `json:"title"`
+	Year      int32     `json:"year,omitempty"`
+	Runtime   Runtime   `json:"runtime,omitempty"`
+	Genres    []string  `json:"genres,omitempty"`
+	IMDbID    string    `json:"imdb_id,omitempty"`
+	TMDbID    int64     `json:"tmdb_id,omitempty"`
+	Synopsis  string    `json:"synopsis,omitempty"`
+	Tagline   string    `json:"tagline,omitempty"`
+	AgeRating string    `json:"age_rating,omitempty"`
+	PosterURL string    `json:"poster_url,omitempty"`
+	EditorID  int64     `json:"editor_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Restore sets the fields of movie which are kept in the revision back to
+// their values at the time.
+func (r *MovieRevision) Restore(movie *Movie) {
+	movie.Title = r.Title
+	movie.Year = r.Year
+	movie.Runtime = r.Runtime
+	movie.Genres = r.Genres
+	movie.IMDbID = r.IMDbID
+	movie.TMDbID = r.TMDbID
+	movie.Synopsis = r.Synopsis
+	movie.Tagline = r.Tagline
+	movie.AgeRating = r.AgeRating
+	movie.PosterURL = r.PosterURL
+}
+
+type MovieRevisionModel struct {
+	DB *sql.DB
+}
+
+func (m MovieRevisionModel) GetAllForMovie(movieID int64) ([]*MovieRevision, error) {
+	query := `
+	SELECT movie_id, version, title, COALESCE(year, 0), runtime, genres, COALESCE(imdb_id, ''), COALESCE(tmdb_id, 0), synopsis, tagline, COALESCE(age_rating, ''), poster_url, COALESCE(editor_id, 0), created_at
+	FROM movie_revisions
+	WHERE movie_id = $1
+	ORDER BY version DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*MovieRevision{}
+
+	for rows.Next() {
+		var revision MovieRevision
+
+		err := rows.Scan(
+			&revision.MovieID,
+			&revision.Version,
+			&revision.Title,
+			&revision.Year,
+			&revision.Runtime,
+			pq.Array(&revision.Genres),
+			&revision.IMDbID,
+			&revision.TMDbID,
+			&revision.Synopsis,
+			&revision.Tagline,
+			&revision.AgeRating,
+			&revision.PosterURL,
+			&revision.EditorID,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (m MovieRevisionModel) Get(movieID int64, version int32) (*MovieRevision, error) {
+	if movieID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT movie_id, version, title, COALESCE(year, 0), runtime, genres, COALESCE(imdb_id, ''), COALESCE(tmdb_id, 0), synopsis, tagline, COALESCE(age_rating, ''), poster_url, COALESCE(editor_id, 0), created_at
+	FROM movie_revisions
+	WHERE movie_id = $1 AND version = $2`
+
+	var revision MovieRevision
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, version).Scan(
+		&revision.MovieID,
+		&revision.Version,
+		&revision.Title,
+		&revision.Year,
+		&revision.Runtime,
+		pq.Array(&revision.Genres),
+		&revision.IMDbID,
+		&revision.TMDbID,
+		&revision.Synopsis,
+		&revision.Tagline,
+		&revision.AgeRating,
+		&revision.PosterURL,
+		&revision.EditorID,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
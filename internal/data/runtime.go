@@ -37,3 +37,8 @@ func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
 	*r = Runtime(i)
 	return nil
 }
+
+// JSONSchema describes the "<n> mins" string a Runtime is marshalled as.
+func (r Runtime) JSONSchema() map[string]any {
+	return map[string]any{"type": "string", "pattern": `^[0-9]+ mins$`, "example": "102 mins"}
+}
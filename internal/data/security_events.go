@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	SecurityLoginSucceeded = "login_succeeded"
+	SecurityLoginFailed    = "login_failed"
+	// SecurityLoginNewCountry flags a successful login from a country the
+	// user has not logged in from before.
+	SecurityLoginNewCountry = "login_new_country"
+)
+
+// SecurityEvent records something that happened to a user's account, such
+// as a login attempt, for the user to review.
+type SecurityEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    int64     `json:"-"`
+	Kind      string    `json:"kind"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Country   string    `json:"country"`
+	City      string    `json:"city"`
+}
+
+type SecurityEventModel struct {
+	DB *sql.DB
+}
+
+func (m SecurityEventModel) Insert(event *SecurityEvent) error {
+	query := `
+	INSERT INTO security_events (user_id, kind, ip, user_agent, country, city)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`
+
+	args := []any{event.UserID, event.Kind, event.IP, event.UserAgent, event.Country, event.City}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+// GetAllForUser returns up to limit of the user's events, newest first.
+// before is the cursor: only events with a lower ID are returned.
+func (m SecurityEventModel) GetAllForUser(userID, before int64, limit int) ([]*SecurityEvent, error) {
+	query := `
+	SELECT id, created_at, user_id, kind, ip, user_agent, country, city
+	FROM security_events
+	WHERE user_id = $1
+	AND ($2::bigint = 0 OR id < $2)
+	ORDER BY id DESC
+	LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*SecurityEvent{}
+
+	for rows.Next() {
+		var event SecurityEvent
+
+		err := rows.Scan(&event.ID, &event.CreatedAt, &event.UserID, &event.Kind, &event.IP, &event.UserAgent, &event.Country, &event.City)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// IsNewCountry reports whether the user has logged in successfully from a
+// known country before, but never from country. Logins which could not be
+// located are ignored.
+func (m SecurityEventModel) IsNewCountry(userID int64, country string) (bool, error) {
+	query := `
+	SELECT count(*) > 0, count(*) FILTER (WHERE country = $3) = 0
+	FROM security_events
+	WHERE user_id = $1 AND kind = $2 AND country <> ''`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var located, unseen bool
+
+	err := m.DB.QueryRowContext(ctx, query, userID, SecurityLoginSucceeded, country).Scan(&located, &unseen)
+	if err != nil {
+		return false, err
+	}
+
+	return located && unseen, nil
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// CheckReadWrite writes a row to a scratch table and reads it back. The
+// table is temporary and the transaction is rolled back, so nothing is
+// left behind.
+func CheckReadWrite(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		CREATE TEMPORARY TABLE selftest_scratch (
+			id bigserial PRIMARY KEY,
+			value text NOT NULL
+		) ON COMMIT DROP`)
+	if err != nil {
+		return err
+	}
+
+	const want = "greenlight self-test"
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `INSERT INTO selftest_scratch (value) VALUES ($1) RETURNING id`, want).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	var got string
+	err = tx.QueryRowContext(ctx, `SELECT value FROM selftest_scratch WHERE id = $1`, id).Scan(&got)
+	if err != nil {
+		return err
+	}
+
+	if got != want {
+		return fmt.Errorf("read back %q, wrote %q", got, want)
+	}
+
+	return nil
+}
+
+// MigrationVersion returns the version of the last migration applied by
+// migrate, and whether it failed part way through.
+func MigrationVersion(ctx context.Context, db *sql.DB) (version int64, dirty bool, err error) {
+	err = db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
@@ -0,0 +1,308 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// Both drivers send PostgreSQL a cancel request when a query's context is
+// done, so the backend stops working on it rather than running on after the
+// caller has given up. statement_timeout bounds statements whose context
+// has no deadline, or whose cancel request never arrives.
+
+// WithStatementTimeout wraps a connector so that every connection it opens
+// gives up on statements running longer than d. It is for connectors which
+// open a new connection each time; pgx pools set the statement_timeout
+// runtime parameter instead.
+func WithStatementTimeout(c driver.Connector, d time.Duration) driver.Connector {
+	return timeoutConnector{c, d}
+}
+
+type timeoutConnector struct {
+	driver.Connector
+	timeout time.Duration
+}
+
+func (c timeoutConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("data: driver cannot set statement_timeout")
+	}
+
+	_, err = execer.ExecContext(ctx, fmt.Sprintf("SET statement_timeout = %d", c.timeout.Milliseconds()), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// execWithStatementTimeout runs a statement which may legitimately take
+// longer than the connection's statement_timeout, with timeout in its
+// place. The connection's own setting is restored afterwards.
+func execWithStatementTimeout(ctx context.Context, db *sql.DB, timeout time.Duration, statement string) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var previous string
+
+	err = conn.QueryRowContext(ctx, "SHOW statement_timeout").Scan(&previous)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, statement)
+
+	// The statement's context may have run out, so the setting is restored
+	// on one of its own.
+	restoreCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if _, restoreErr := conn.ExecContext(restoreCtx, "SELECT set_config('statement_timeout', $1, false)", previous); restoreErr != nil {
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+
+	return err
+}
+
+// StatementStats counts statements which did not run to completion.
+// TimedOut are those stopped by their context's deadline or by
+// statement_timeout; Cancelled are those whose context was cancelled, such
+// as when a client disconnected, or which were cancelled on the server.
+type StatementStats struct {
+	TimedOut  atomic.Int64
+	Cancelled atomic.Int64
+}
+
+// WithStatementStats wraps a connector so that statements on its
+// connections which are cancelled or time out are counted in stats.
+func WithStatementStats(c driver.Connector, stats *StatementStats) driver.Connector {
+	return statsConnector{c, stats}
+}
+
+// observe counts err if it reports the statement being stopped.
+func (s *StatementStats) observe(ctx context.Context, err error) {
+	var code, message string
+
+	var pqErr *pq.Error
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pqErr):
+		code, message = string(pqErr.Code), pqErr.Message
+	case errors.As(err, &pgErr):
+		code, message = pgErr.Code, pgErr.Message
+	}
+
+	stopped := code == "57014" || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	if !stopped {
+		return
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		s.TimedOut.Add(1)
+	case ctx.Err() != nil:
+		s.Cancelled.Add(1)
+	case strings.Contains(message, "statement timeout"):
+		s.TimedOut.Add(1)
+	default:
+		s.Cancelled.Add(1)
+	}
+}
+
+type statsConnector struct {
+	driver.Connector
+	stats *StatementStats
+}
+
+func (c statsConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &statsConn{conn, c.stats}, nil
+}
+
+// statsConn passes everything through to the driver's connection,
+// observing the errors of statements run on it. The optional interfaces
+// it implements fall back to database/sql's defaults when the driver's
+// connection lacks them.
+type statsConn struct {
+	driver.Conn
+	stats *StatementStats
+}
+
+func (c *statsConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		c.stats.observe(ctx, err)
+		return nil, err
+	}
+	return &statsRows{rows, ctx, c.stats}, nil
+}
+
+func (c *statsConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != nil {
+		c.stats.observe(ctx, err)
+	}
+	return result, err
+}
+
+func (c *statsConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.stats.observe(ctx, err)
+		return nil, err
+	}
+	return &statsStmt{stmt, c.stats}, nil
+}
+
+func (c *statsConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("data: driver does not support transaction options")
+	}
+	return c.Conn.Begin()
+}
+
+func (c *statsConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *statsConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *statsConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *statsConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+type statsStmt struct {
+	driver.Stmt
+	stats *StatementStats
+}
+
+func (s *statsStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	if err != nil {
+		s.stats.observe(ctx, err)
+		return nil, err
+	}
+	return &statsRows{rows, ctx, s.stats}, nil
+}
+
+func (s *statsStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	var err error
+
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			result, err = s.Stmt.Exec(values)
+		}
+	}
+	if err != nil {
+		s.stats.observe(ctx, err)
+	}
+	return result, err
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("data: driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// statsRows observes errors while reading rows, which is when a statement
+// streaming its results is stopped.
+type statsRows struct {
+	driver.Rows
+	ctx   context.Context
+	stats *StatementStats
+}
+
+func (r *statsRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil && err != io.EOF {
+		r.stats.observe(r.ctx, err)
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// queryer is satisfied by *sql.DB and *stmtCache, so a model can run its hot
+// queries through whichever it has.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// stmtCache prepares each query the first time it is run and reuses the
+// statement afterwards, so Postgres parses and plans it once per connection
+// rather than on every call. If the server refuses to prepare a query, for
+// example behind a pooler in transaction mode, it is remembered and run
+// unprepared from then on. Queries inside a transaction should use the *sql.Tx directly.
+type stmtCache struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStmtCache(db *sql.DB) *stmtCache {
+	return &stmtCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// stmt returns the prepared statement for query, or nil if it could not be
+// prepared.
+func (c *stmtCache) stmt(ctx context.Context, query string) *sql.Stmt {
+	c.mu.RLock()
+	stmt, ok := c.stmts[query]
+	c.mu.RUnlock()
+
+	if ok {
+		return stmt
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		// Only the server refusing to prepare the query says it never
+		// will. Anything else, such as a dropped connection or a cancelled
+		// context, may pass, so try again next time.
+		if !cannotPrepare(err) {
+			return nil
+		}
+		stmt = nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.stmts[query]; ok {
+		if stmt != nil {
+			stmt.Close()
+		}
+		return existing
+	}
+	c.stmts[query] = stmt
+
+	return stmt
+}
+
+// cannotPrepare reports whether err is the server declining to prepare a
+// statement, as PgBouncer in transaction mode does.
+func cannotPrepare(err error) bool {
+	var code string
+
+	var pqErr *pq.Error
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pqErr):
+		code = string(pqErr.Code)
+	case errors.As(err, &pgErr):
+		code = pgErr.Code
+	}
+
+	switch code {
+	case "0A000", "26000", "42P05":
+		// feature_not_supported, invalid_sql_statement_name and
+		// duplicate_prepared_statement.
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *stmtCache) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if stmt := c.stmt(ctx, query); stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+func (c *stmtCache) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if stmt := c.stmt(ctx, query); stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return c.db.QueryRowContext(ctx, query, args...)
+}
+
+// prepared returns the model's statement cache, or db if it was constructed
+// without one.
+func prepared(db *sql.DB, stmts *stmtCache) queryer {
+	if stmts == nil {
+		return db
+	}
+	return stmts
+}
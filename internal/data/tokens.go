@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"database/sql" // New import
 	"encoding/base32"
+	"encoding/hex"
 	"greenlight.bcc/internal/validator" // New import
 	"time"
 )
@@ -19,29 +20,82 @@ type Token struct {
 	Plaintext string    `json:"token"`
 	Hash      []byte    `json:"-"`
 	UserID    int64     `json:"-"`
+	CreatedAt time.Time `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	// ImpersonatorID is the admin acting through this token, or zero for a
+	// token issued to the user themselves.
+	ImpersonatorID int64 `json:"-"`
+	// OrgID is the organization an authentication token acts in.
+	OrgID int64 `json:"organization_id,omitempty"`
+	// Device is the client an authentication token was issued to.
+	Device Device `json:"-"`
+}
+
+// Device describes the client a user logged in from. Name is chosen by
+// the client, such as "Alice's phone", and may be empty.
+type Device struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Name      string `json:"device_name"`
+}
+
+// Session is an unexpired authentication token as shown to its user. ID
+// identifies the token without revealing it.
+type Session struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Expiry    time.Time `json:"expiry"`
+	Device
+}
+
+func newSession(token *Token) *Session {
+	return &Session{
+		ID:        sessionID(token.Hash),
+		CreatedAt: token.CreatedAt,
+		Expiry:    token.Expiry,
+		Device:    token.Device,
+	}
+}
+
+// sessionID derives a session's ID from its token's hash.
+func sessionID(hash []byte) string {
+	return hex.EncodeToString(hash[:8])
 }
 
 func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	now := time.Now()
+
 	token := &Token{
-		UserID: userID,
-		Expiry: time.Now().Add(ttl),
-		Scope:  scope,
+		UserID:    userID,
+		CreatedAt: now,
+		Expiry:    now.Add(ttl),
+		Scope:     scope,
+	}
+
+	var err error
+	token.Plaintext, token.Hash, err = generateSecret()
+	if err != nil {
+		return nil, err
 	}
 
+	return token, nil
+}
+
+// generateSecret returns a random 26 character code and its SHA-256 hash,
+// which is what gets stored.
+func generateSecret() (string, []byte, error) {
 	randomBytes := make([]byte, 16)
 
 	_, err := rand.Read(randomBytes)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
-	hash := sha256.Sum256([]byte(token.Plaintext))
-	token.Hash = hash[:]
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	hash := sha256.Sum256([]byte(plaintext))
 
-	return token, nil
+	return plaintext, hash[:], nil
 }
 
 func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
@@ -49,6 +103,10 @@ func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
 	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
 }
 
+func ValidateDeviceName(v *validator.Validator, name string) {
+	v.Check(len(name) <= 100, "device_name", "must not be more than 100 bytes long")
+}
+
 type TokenModel struct {
 	DB *sql.DB
 }
@@ -62,12 +120,45 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 	return token, err
 }
 
+// NewAuthentication issues an authentication token for userID acting in the
+// organization orgID.
+func (m TokenModel) NewAuthentication(userID, orgID int64, ttl time.Duration) (*Token, error) {
+	return m.NewSession(userID, orgID, ttl, Device{})
+}
+
+// NewSession is like NewAuthentication, but records the device the token
+// was issued to.
+func (m TokenModel) NewSession(userID, orgID int64, ttl time.Duration, device Device) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	token.OrgID = orgID
+	token.Device = device
+	err = m.Insert(token)
+	return token, err
+}
+
+// NewImpersonation issues an authentication token for userID which is
+// flagged as being used by the impersonator.
+func (m TokenModel) NewImpersonation(userID, impersonatorID, orgID int64, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	token.ImpersonatorID = impersonatorID
+	token.OrgID = orgID
+	err = m.Insert(token)
+	return token, err
+}
+
 // Insert() adds the data for a specific token to the tokens table.
 func (m TokenModel) Insert(token *Token) error {
 	query := `
-	INSERT INTO tokens (hash, user_id, expiry, scope)
-	VALUES ($1, $2, $3, $4)`
-	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
+	INSERT INTO tokens (hash, user_id, created_at, expiry, scope, impersonator_id, org_id, ip, user_agent, device_name)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6::bigint, 0), NULLIF($7::bigint, 0), $8, $9, $10)`
+	args := []any{token.Hash, token.UserID, token.CreatedAt, token.Expiry, token.Scope, token.ImpersonatorID, token.OrgID,
+		token.Device.IP, token.Device.UserAgent, token.Device.Name}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	_, err := m.DB.ExecContext(ctx, query, args...)
@@ -84,18 +175,63 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	return err
 }
 
-type MockTokenModel struct {
-	DB *sql.DB
-}
+// GetSessions returns the user's unexpired authentication tokens, newest
+// first. Tokens used by an impersonating admin are left out.
+func (m TokenModel) GetSessions(userID int64) ([]*Session, error) {
+	query := `
+	SELECT hash, created_at, expiry, ip, user_agent, device_name
+	FROM tokens
+	WHERE user_id = $1 AND scope = $2 AND expiry > NOW() AND impersonator_id IS NULL
+	ORDER BY created_at DESC, expiry DESC`
 
-func (m MockTokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
-	return nil, nil
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
 
-func (m MockTokenModel) Insert(token *Token) error {
-	return nil
+	for rows.Next() {
+		var token Token
+
+		err := rows.Scan(&token.Hash, &token.CreatedAt, &token.Expiry, &token.Device.IP, &token.Device.UserAgent, &token.Device.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, newSession(&token))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
 }
 
-func (m MockTokenModel) DeleteAllForUser(scope string, userID int64) error {
-	return nil
+// IsNewDevice reports whether the user has logged in before, but never
+// from a client with the same user agent and device name. Expired tokens
+// count, since they are kept; a user's very first login is not from a new
+// device, as there is nothing to compare it with.
+func (m TokenModel) IsNewDevice(userID int64, device Device) (bool, error) {
+	query := `
+	SELECT count(*) > 0, count(*) FILTER (WHERE user_agent = $3 AND device_name = $4) = 0
+	FROM tokens
+	WHERE user_id = $1 AND scope = $2 AND impersonator_id IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var loggedIn, unseen bool
+
+	err := m.DB.QueryRowContext(ctx, query, userID, ScopeAuthentication, device.UserAgent, device.Name).Scan(&loggedIn, &unseen)
+	if err != nil {
+		return false, err
+	}
+
+	return loggedIn && unseen, nil
 }
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	TombstoneMovie = "movie"
+	TombstoneList  = "list"
+)
+
+var TombstoneTypes = []string{TombstoneMovie, TombstoneList}
+
+// Tombstone records that a resource was deleted, so that clients syncing
+// changes and webhook consumers can learn about deletions after the fact.
+// Tombstones are written in the same transaction as the deletion.
+type Tombstone struct {
+	ID           int64     `json:"-"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   int64     `json:"resource_id"`
+	OrgID        int64     `json:"-"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	DeletedBy    int64     `json:"deleted_by,omitempty"`
+}
+
+// TombstoneQuery narrows the tombstones returned by GetAll to those of a
+// resource type in an organization, deleted after DeletedAfter and, if
+// DeletedBy is set, by that user.
+type TombstoneQuery struct {
+	OrgID        int64
+	ResourceType string
+	DeletedAfter time.Time
+	DeletedBy    int64
+}
+
+func ValidateTombstoneQuery(v *validator.Validator, q TombstoneQuery) {
+	v.Check(validator.PermittedValue(q.ResourceType, TombstoneTypes...), "resource_type", "must be movie or list")
+}
+
+// insertTombstoneTx records a deletion as part of the transaction which
+// performs it.
+func insertTombstoneTx(ctx context.Context, tx *sql.Tx, tombstone *Tombstone) error {
+	query := `
+	INSERT INTO tombstones (resource_type, resource_id, org_id, deleted_by)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, deleted_at`
+
+	deletedBy := sql.NullInt64{Int64: tombstone.DeletedBy, Valid: tombstone.DeletedBy > 0}
+
+	return tx.QueryRowContext(ctx, query, tombstone.ResourceType, tombstone.ResourceID, tombstone.OrgID, deletedBy).Scan(&tombstone.ID, &tombstone.DeletedAt)
+}
+
+type TombstoneModel struct {
+	DB *sql.DB
+}
+
+// GetAll returns the tombstones matching q, oldest first.
+func (m TombstoneModel) GetAll(q TombstoneQuery, filters Filters) ([]*Tombstone, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, resource_type, resource_id, org_id, deleted_at, coalesce(deleted_by, 0)
+	FROM tombstones
+	WHERE org_id = $1 AND resource_type = $2
+	AND (deleted_at > $3 OR $3::timestamptz IS NULL)
+	AND (deleted_by = $4 OR $4 = 0)
+	ORDER BY deleted_at ASC, id ASC
+	LIMIT $5 OFFSET $6`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	deletedAfter := sql.NullTime{Time: q.DeletedAfter, Valid: !q.DeletedAfter.IsZero()}
+
+	rows, err := m.DB.QueryContext(ctx, query, q.OrgID, q.ResourceType, deletedAfter, q.DeletedBy, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	tombstones := []*Tombstone{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var tombstone Tombstone
+
+		err := rows.Scan(
+			&totalRecords,
+			&tombstone.ID,
+			&tombstone.ResourceType,
+			&tombstone.ResourceID,
+			&tombstone.OrgID,
+			&tombstone.DeletedAt,
+			&tombstone.DeletedBy,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		tombstones = append(tombstones, &tombstone)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return tombstones, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// DeleteOlderThan removes the tombstones of deletions before cutoff and
+// returns how many there were.
+func (m TombstoneModel) DeleteOlderThan(cutoff time.Time) (int64, error) {
+	query := `
+	DELETE FROM tombstones
+	WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
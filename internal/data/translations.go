@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.bcc/internal/validator"
+)
+
+// MovieTranslation holds a movie's title and synopsis in another language.
+// Movies fall back to their original text in languages without one.
+type MovieTranslation struct {
+	MovieID   int64     `json:"movie_id"`
+	Locale    string    `json:"locale"`
+	Title     string    `json:"title" validate:"required,max=500"`
+	Synopsis  string    `json:"synopsis,omitempty" validate:"max=5000"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func ValidateMovieTranslation(v *validator.Validator, translation *MovieTranslation) {
+	v.Check(validator.Matches(translation.Locale, LocaleRX), "locale", "must be a language code such as en or pt-BR")
+	v.Struct(translation)
+}
+
+// pickTranslation returns the translation for the first of locales, in order
+// of preference, that the movie has been translated into. A locale with a
+// region, such as pt-BR, also accepts a translation into its language.
+func pickTranslation(translations map[string]*MovieTranslation, locales []string) *MovieTranslation {
+	for _, locale := range locales {
+		if t, ok := translations[locale]; ok {
+			return t
+		}
+		if base, _, ok := strings.Cut(locale, "-"); ok {
+			if t, ok := translations[base]; ok {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// applyTranslation replaces the movie's text with the translation's and
+// records its locale.
+func applyTranslation(movie *Movie, t *MovieTranslation) {
+	movie.Title = t.Title
+	if t.Synopsis != "" {
+		movie.Synopsis = t.Synopsis
+	}
+	movie.Locale = t.Locale
+}
+
+type MovieTranslationModel struct {
+	DB *sql.DB
+}
+
+// Upsert saves the translation, replacing any earlier one for the locale.
+// created reports whether there was none.
+func (m MovieTranslationModel) Upsert(translation *MovieTranslation) (created bool, err error) {
+	query := `
+	INSERT INTO movie_translations (movie_id, locale, title, synopsis)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (movie_id, locale) DO UPDATE
+	SET title = EXCLUDED.title, synopsis = EXCLUDED.synopsis, updated_at = NOW()
+	RETURNING updated_at, xmax = 0`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{translation.MovieID, translation.Locale, translation.Title, translation.Synopsis}
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&translation.UpdatedAt, &created)
+	return created, err
+}
+
+func (m MovieTranslationModel) Delete(movieID int64, locale string) error {
+	query := `
+	DELETE FROM movie_translations
+	WHERE movie_id = $1 AND locale = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, locale)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m MovieTranslationModel) GetAllForMovie(movieID int64) ([]*MovieTranslation, error) {
+	query := `
+	SELECT movie_id, locale, title, synopsis, updated_at
+	FROM movie_translations
+	WHERE movie_id = $1
+	ORDER BY locale`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := []*MovieTranslation{}
+
+	for rows.Next() {
+		var t MovieTranslation
+
+		err := rows.Scan(&t.MovieID, &t.Locale, &t.Title, &t.Synopsis, &t.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		translations = append(translations, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+// Apply translates movies into the first of locales, in order of
+// preference, that each has a translation for. Movies without one keep
+// their original text.
+func (m MovieTranslationModel) Apply(movies []*Movie, locales []string) error {
+	if len(movies) == 0 || len(locales) == 0 {
+		return nil
+	}
+
+	candidates := make([]string, 0, len(locales)*2)
+	for _, locale := range locales {
+		candidates = append(candidates, locale)
+		if base, _, ok := strings.Cut(locale, "-"); ok {
+			candidates = append(candidates, base)
+		}
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	query := `
+	SELECT movie_id, locale, title, synopsis, updated_at
+	FROM movie_translations
+	WHERE movie_id = ANY($1) AND locale = ANY($2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), pq.Array(candidates))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	translations := make(map[int64]map[string]*MovieTranslation)
+
+	for rows.Next() {
+		var t MovieTranslation
+
+		err := rows.Scan(&t.MovieID, &t.Locale, &t.Title, &t.Synopsis, &t.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if translations[t.MovieID] == nil {
+			translations[t.MovieID] = make(map[string]*MovieTranslation)
+		}
+		translations[t.MovieID][t.Locale] = &t
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		if t := pickTranslation(translations[movie.ID], locales); t != nil {
+			applyTranslation(movie, t)
+		}
+	}
+
+	return nil
+}
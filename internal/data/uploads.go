@@ -0,0 +1,168 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// An upload's purpose says what the file is attached to once the upload is
+// completed.
+const (
+	UploadPoster = "poster"
+	UploadAvatar = "avatar"
+)
+
+var ErrUploadClaimed = errors.New("upload already claimed")
+
+// Upload is a file a client sends straight to storage, through a pre-signed
+// URL or, with local storage, a one-time token. The file is only attached to
+// its movie or user once the client completes the upload and the file has
+// been checked against the declared size and content type.
+type Upload struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UserID      int64      `json:"-"`
+	Purpose     string     `json:"purpose" validate:"required,oneof=poster avatar"`
+	MovieID     int64      `json:"movie_id,omitempty"`
+	Key         string     `json:"-"`
+	ContentType string     `json:"content_type" validate:"required"`
+	Size        int64      `json:"size" validate:"positive"`
+	TokenHash   []byte     `json:"-"`
+	UploadedAt  *time.Time `json:"uploaded_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func ValidateUpload(v *validator.Validator, upload *Upload) {
+	v.Struct(upload)
+	v.Check(upload.Purpose != UploadPoster || upload.MovieID > 0, "movie_id", "must be provided for posters")
+	v.Check(upload.Purpose != UploadAvatar || upload.MovieID == 0, "movie_id", "must not be provided for avatars")
+}
+
+// NewToken gives the upload a one-time token for sending the file and
+// returns its plaintext.
+func (u *Upload) NewToken() (string, error) {
+	plaintext, hash, err := generateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	u.TokenHash = hash
+	return plaintext, nil
+}
+
+// MatchesToken reports whether plaintext is the upload's token.
+func (u *Upload) MatchesToken(plaintext string) bool {
+	hash := sha256.Sum256([]byte(plaintext))
+	return u.TokenHash != nil && string(hash[:]) == string(u.TokenHash)
+}
+
+type UploadModel struct {
+	DB *sql.DB
+}
+
+const uploadColumns = `id, created_at, expires_at, user_id, purpose, coalesce(movie_id, 0), key, content_type, size, token_hash, uploaded_at, completed_at`
+
+func (u *Upload) dest() []any {
+	return []any{&u.ID, &u.CreatedAt, &u.ExpiresAt, &u.UserID, &u.Purpose, &u.MovieID, &u.Key, &u.ContentType, &u.Size, &u.TokenHash, &u.UploadedAt, &u.CompletedAt}
+}
+
+func (m UploadModel) Insert(upload *Upload) error {
+	query := `
+	INSERT INTO uploads (expires_at, user_id, purpose, movie_id, key, content_type, size, token_hash)
+	VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8)
+	RETURNING id, created_at`
+
+	args := []any{upload.ExpiresAt, upload.UserID, upload.Purpose, upload.MovieID, upload.Key, upload.ContentType, upload.Size, upload.TokenHash}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&upload.ID, &upload.CreatedAt)
+}
+
+func (m UploadModel) Get(id int64) (*Upload, error) {
+	query := `
+	SELECT ` + uploadColumns + `
+	FROM uploads
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var upload Upload
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(upload.dest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &upload, nil
+}
+
+// MarkUploaded uses up the upload's token. It returns ErrUploadClaimed if
+// the token has already been used.
+func (m UploadModel) MarkUploaded(upload *Upload) error {
+	return m.mark(upload, `
+	UPDATE uploads
+	SET uploaded_at = NOW()
+	WHERE id = $1 AND uploaded_at IS NULL
+	RETURNING uploaded_at`, &upload.UploadedAt)
+}
+
+// UnmarkUploaded gives the upload's token back after its file could not be
+// stored, so that the client can send it again.
+func (m UploadModel) UnmarkUploaded(upload *Upload) error {
+	query := `
+	UPDATE uploads
+	SET uploaded_at = NULL
+	WHERE id = $1 AND completed_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, upload.ID)
+	if err != nil {
+		return err
+	}
+
+	upload.UploadedAt = nil
+	return nil
+}
+
+// Complete records that the file has been attached. It returns
+// ErrUploadClaimed if the upload has already been completed.
+func (m UploadModel) Complete(upload *Upload) error {
+	return m.mark(upload, `
+	UPDATE uploads
+	SET completed_at = NOW()
+	WHERE id = $1 AND completed_at IS NULL
+	RETURNING completed_at`, &upload.CompletedAt)
+}
+
+func (m UploadModel) mark(upload *Upload, query string, dest **time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, upload.ID).Scan(dest)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrUploadClaimed
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
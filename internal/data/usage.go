@@ -0,0 +1,103 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// UsageRecord counts the requests a user made in an organization on one UTC
+// day. Errors counts the responses with a 4xx or 5xx status.
+type UsageRecord struct {
+	Day      string `json:"day"`
+	UserID   int64  `json:"user_id"`
+	OrgID    int64  `json:"organization_id"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
+
+// UsageFilter selects usage records between From and To inclusive. A zero
+// UserID or OrgID matches every user or organization.
+type UsageFilter struct {
+	UserID int64
+	OrgID  int64
+	From   time.Time
+	To     time.Time
+}
+
+func ValidateUsageFilter(v *validator.Validator, f UsageFilter) {
+	v.Check(!f.To.Before(f.From), "to", "must not be before from")
+	v.Check(f.To.Sub(f.From) <= 366*24*time.Hour, "to", "must be within 366 days of from")
+	v.Check(f.UserID >= 0, "user_id", "must not be negative")
+	v.Check(f.OrgID >= 0, "org_id", "must not be negative")
+}
+
+type UsageModel struct {
+	DB *sql.DB
+}
+
+// Add adds the counts in records to the stored totals for the same day, user
+// and organization.
+func (m UsageModel) Add(records []*UsageRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO usage (day, user_id, org_id, requests, errors)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (day, user_id, org_id) DO UPDATE
+	SET requests = usage.requests + EXCLUDED.requests, errors = usage.errors + EXCLUDED.errors`
+
+	for _, record := range records {
+		_, err = tx.ExecContext(ctx, query, record.Day, record.UserID, record.OrgID, record.Requests, record.Errors)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m UsageModel) GetAll(filter UsageFilter) ([]*UsageRecord, error) {
+	query := `
+	SELECT to_char(day, 'YYYY-MM-DD'), user_id, org_id, requests, errors
+	FROM usage
+	WHERE (user_id = $1 OR $1 = 0)
+	AND (org_id = $2 OR $2 = 0)
+	AND day BETWEEN $3 AND $4
+	ORDER BY day, user_id, org_id`
+
+	args := []any{filter.UserID, filter.OrgID, filter.From.Format("2006-01-02"), filter.To.Format("2006-01-02")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*UsageRecord{}
+	for rows.Next() {
+		var record UsageRecord
+		err := rows.Scan(&record.Day, &record.UserID, &record.OrgID, &record.Requests, &record.Errors)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
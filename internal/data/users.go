@@ -5,9 +5,12 @@ import (
 	"crypto/sha256"
 	"database/sql" // New import
 	"errors"
+	"fmt"
+	"regexp"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	"greenlight.bcc/internal/fieldcrypt"
 	"greenlight.bcc/internal/validator"
 )
 
@@ -17,14 +20,33 @@ var (
 
 var AnonymousUser = &User{}
 
+var LocaleRX = regexp.MustCompile("^[a-z]{2}(-[A-Z]{2})?$")
+
 type User struct {
 	ID        int64     `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
+	Locale    string    `json:"locale"`
+	AvatarURL string    `json:"avatar_url,omitempty"`
 	Password  password  `json:"-"`
 	Activated bool      `json:"activated"`
 	Version   int       `json:"-"`
+	// AvatarThumbnails are the URLs of the avatar's thumbnails by size. They
+	// are filled in by the API rather than stored.
+	AvatarThumbnails map[string]string `json:"avatar_thumbnails,omitempty"`
+	// FollowersCount and FollowingCount are kept up to date by the Follows
+	// model so that profiles need not count the follows.
+	FollowersCount int `json:"-"`
+	FollowingCount int `json:"-"`
+	// Banned users can no longer sign in or use their tokens.
+	Banned bool `json:"-"`
+	// ImpersonatorID is set by GetForToken when the token was issued to an
+	// admin impersonating this user.
+	ImpersonatorID int64 `json:"-"`
+	// OrgID is the organization the user's authentication token acts in, as
+	// set by GetForToken.
+	OrgID int64 `json:"-"`
 }
 
 func (u *User) IsAnonymous() bool {
@@ -48,6 +70,8 @@ func ValidateUser(v *validator.Validator, user *User) {
 
 	ValidateEmail(v, user.Email)
 
+	v.Check(validator.Matches(user.Locale, LocaleRX), "locale", "must be a language code such as en or pt-BR")
+
 	if user.Password.plaintext != nil {
 		ValidatePasswordPlaintext(v, *user.Password.plaintext)
 	}
@@ -63,8 +87,9 @@ type password struct {
 	hash      []byte
 }
 
+// Set hashes plaintextPassword with Argon2id and PasswordHashing.
 func (p *password) Set(plaintextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	hash, err := PasswordHashing.hash(plaintextPassword)
 	if err != nil {
 		return err
 	}
@@ -73,7 +98,13 @@ func (p *password) Set(plaintextPassword string) error {
 	return nil
 }
 
+// Matches checks plaintextPassword against the hash, which may be either
+// an Argon2id hash or a bcrypt one made before Argon2id was adopted.
 func (p *password) Matches(plaintextPassword string) (bool, error) {
+	if isArgon2idHash(p.hash) {
+		return matchesArgon2id(p.hash, plaintextPassword)
+	}
+
 	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
 	if err != nil {
 		switch {
@@ -86,23 +117,49 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
+// NeedsRehash reports whether the hash should be replaced by one made with
+// Set: it is a bcrypt hash, or an Argon2id one with other parameters than
+// PasswordHashing. Users' hashes are upgraded as they log in, since only
+// then is the plaintext known.
+func (p *password) NeedsRehash() bool {
+	if !isArgon2idHash(p.hash) {
+		return true
+	}
+
+	params, _, _, err := parseArgon2idHash(p.hash)
+	return err != nil || params != PasswordHashing
+}
+
 type UserModel struct {
-	DB *sql.DB
+	DB    *sql.DB
+	stmts *stmtCache
+	// Keys, if set, encrypts email addresses at rest. See storedEmail.
+	Keys *fieldcrypt.Keyring
 }
 
 func (m UserModel) Insert(user *User) error {
+	email, emailEncrypted, err := m.storedEmail(user.Email)
+	if err != nil {
+		return err
+	}
+
 	query := `
-	INSERT INTO users (name, email, password_hash, activated)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO users (name, email, email_encrypted, locale, password_hash, activated)
+	VALUES ($1, $2, $3, $4, $5, $6)
 	RETURNING id, created_at, version`
-	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+	args := []any{user.Name, email, emailEncrypted, user.Locale, user.Password.hash, user.Activated}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	err = m.checkPlaintextEmail(ctx, m.DB, user.Email, 0)
+	if err != nil {
+		return err
+	}
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case isUniqueViolation(err, "users_email_key"):
 			return ErrDuplicateEmail
 		default:
 			return err
@@ -112,22 +169,101 @@ func (m UserModel) Insert(user *User) error {
 	return nil
 }
 
+func (m UserModel) Get(id int64) (*User, error) {
+	return retryRead("Users.Get", func() (*User, error) { return m.get(id) })
+}
+
+func (m UserModel) get(id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, created_at, name, email, email_encrypted, locale, avatar_url, password_hash, activated, version, banned, followers_count, following_count
+	FROM users
+	WHERE id = $1`
+	var user User
+	var emailEncrypted string
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&emailEncrypted,
+		&user.Locale,
+		&user.AvatarURL,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+		&user.Banned,
+		&user.FollowersCount,
+		&user.FollowingCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, m.openEmail(&user, emailEncrypted)
+}
+
+// RehashPassword replaces the user's password hash with one made by
+// password.Set, for when NeedsRehash reports it is out of date. It leaves
+// the hash alone if it has changed since the user was read, and does not
+// change the user's version, since nothing they can see has changed.
+func (m UserModel) RehashPassword(user *User, plaintextPassword string) error {
+	previous := user.Password.hash
+
+	err := user.Password.Set(plaintextPassword)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE users
+	SET password_hash = $1
+	WHERE id = $2 AND password_hash = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, user.Password.hash, user.ID, previous)
+	return err
+}
+
 func (m UserModel) GetByEmail(email string) (*User, error) {
+	return retryRead("Users.GetByEmail", func() (*User, error) { return m.getByEmail(email) })
+}
+
+func (m UserModel) getByEmail(email string) (*User, error) {
 	query := `
-	SELECT id, created_at, name, email, password_hash, activated, version
+	SELECT id, created_at, name, email, email_encrypted, locale, avatar_url, password_hash, activated, version, banned, followers_count, following_count
 	FROM users
-	WHERE email = $1`
+	WHERE email IN ($1, $2)`
 	var user User
+	var emailEncrypted string
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+	plaintext, index := m.emailLookup(email)
+	err := m.DB.QueryRowContext(ctx, query, plaintext, index).Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.Name,
 		&user.Email,
+		&emailEncrypted,
+		&user.Locale,
+		&user.AvatarURL,
 		&user.Password.hash,
 		&user.Activated,
 		&user.Version,
+		&user.Banned,
+		&user.FollowersCount,
+		&user.FollowingCount,
 	)
 	if err != nil {
 		switch {
@@ -137,29 +273,40 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 			return nil, err
 		}
 	}
-	return &user, nil
+	return &user, m.openEmail(&user, emailEncrypted)
 }
 
 func (m UserModel) Update(user *User) error {
 	query := `
 	UPDATE users
-	SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
-	WHERE id = $5 AND version = $6
+	SET name = $1, email = $2, locale = $3, password_hash = $4, activated = $5, avatar_url = $8, email_encrypted = $9, version = version + 1
+	WHERE id = $6 AND version = $7
 	RETURNING version`
+	email, emailEncrypted, err := m.storedEmail(user.Email)
+	if err != nil {
+		return err
+	}
 	args := []any{
 		user.Name,
-		user.Email,
+		email,
+		user.Locale,
 		user.Password.hash,
 		user.Activated,
 		user.ID,
 		user.Version,
+		user.AvatarURL,
+		emailEncrypted,
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	err = m.checkPlaintextEmail(ctx, m.DB, user.Email, user.ID)
+	if err != nil {
+		return err
+	}
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case isUniqueViolation(err, "users_email_key"):
 			return ErrDuplicateEmail
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
@@ -171,10 +318,15 @@ func (m UserModel) Update(user *User) error {
 }
 
 func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	return retryRead("Users.GetForToken", func() (*User, error) { return m.getForToken(tokenScope, tokenPlaintext) })
+}
+
+func (m UserModel) getForToken(tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
 	query := `
-	SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+	SELECT users.id, users.created_at, users.name, users.email, users.email_encrypted, users.locale, users.avatar_url, users.password_hash, users.activated, users.version, users.banned, users.followers_count, users.following_count,
+	coalesce(tokens.impersonator_id, 0), coalesce(tokens.org_id, 0)
 	FROM users
 	INNER JOIN tokens
 	ON users.id = tokens.user_id
@@ -184,17 +336,26 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 
 	args := []any{tokenHash[:], tokenScope, time.Now()}
 	var user User
+	var emailEncrypted string
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+	err := prepared(m.DB, m.stmts).QueryRowContext(ctx, query, args...).Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.Name,
 		&user.Email,
+		&emailEncrypted,
+		&user.Locale,
+		&user.AvatarURL,
 		&user.Password.hash,
 		&user.Activated,
 		&user.Version,
+		&user.Banned,
+		&user.FollowersCount,
+		&user.FollowingCount,
+		&user.ImpersonatorID,
+		&user.OrgID,
 	)
 	if err != nil {
 		switch {
@@ -205,25 +366,130 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		}
 	}
 
-	return &user, nil
+	return &user, m.openEmail(&user, emailEncrypted)
 }
 
-type MockUserModel struct {
-	DB *sql.DB
+func (m UserModel) MarkEmailUndeliverable(email string) error {
+	query := `
+	UPDATE users
+	SET email_undeliverable = true, version = version + 1
+	WHERE email IN ($1, $2) AND NOT email_undeliverable`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	plaintext, index := m.emailLookup(email)
+	_, err := m.DB.ExecContext(ctx, query, plaintext, index)
+	return err
 }
 
-func (m MockUserModel) Insert(user *User) error {
+// Ban stops the user from signing in or using their tokens.
+func (m UserModel) Ban(id int64) error {
+	query := `
+	UPDATE users
+	SET banned = true, version = version + 1
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
 	return nil
 }
 
-func (m MockUserModel) GetByEmail(email string) (*User, error) {
-	return nil, nil
-}
+func (m UserModel) EmailUndeliverable(email string) (bool, error) {
+	query := `
+	SELECT email_undeliverable
+	FROM users
+	WHERE email IN ($1, $2)`
 
-func (m MockUserModel) Update(user *User) error {
-	return nil
+	var undeliverable bool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	plaintext, index := m.emailLookup(email)
+	err := m.DB.QueryRowContext(ctx, query, plaintext, index).Scan(&undeliverable)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return undeliverable, nil
 }
 
-func (m MockUserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
-	return nil, nil
+// GetAll returns the users created within the range, for administrators.
+func (m UserModel) GetAll(created CreatedRange, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, name, email, email_encrypted, locale, avatar_url, activated, version, banned
+	FROM users
+	WHERE (created_at > $1 OR $1::timestamptz IS NULL)
+	AND (created_at < $2 OR $2::timestamptz IS NULL)
+	ORDER BY %s %s, id ASC
+	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := append(created.args(), filters.limit(), filters.offset())
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	totalRecords := 0
+
+	for rows.Next() {
+		var user User
+		var emailEncrypted string
+
+		err := rows.Scan(
+			&totalRecords,
+			&user.ID,
+			&user.CreatedAt,
+			&user.Name,
+			&user.Email,
+			&emailEncrypted,
+			&user.Locale,
+			&user.AvatarURL,
+			&user.Activated,
+			&user.Version,
+			&user.Banned,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if err := m.openEmail(&user, emailEncrypted); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return users, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
 }
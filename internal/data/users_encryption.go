@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// emailContext is authenticated with every sealed email address, so a
+// sealed value cannot be copied into another column and opened there.
+const emailContext = "users.email"
+
+// When the model has a keyring, a user's email address is sealed into
+// email_encrypted and the email column, which keeps its unique constraint,
+// holds a blind index of it instead. Rows written before the keyring was
+// configured keep their plaintext address in email and an empty
+// email_encrypted until they are re-encrypted.
+
+// storedEmail returns the values to store in the email and email_encrypted
+// columns for email.
+func (m UserModel) storedEmail(email string) (string, string, error) {
+	if m.Keys == nil {
+		return email, "", nil
+	}
+
+	sealed, err := m.Keys.Seal(email, emailContext)
+	if err != nil {
+		return "", "", err
+	}
+
+	return m.Keys.BlindIndex(email), sealed, nil
+}
+
+// emailLookup returns the two values the email column may hold for email:
+// the address itself, for rows not yet encrypted, and its blind index.
+func (m UserModel) emailLookup(email string) (string, string) {
+	if m.Keys == nil {
+		return email, email
+	}
+	return email, m.Keys.BlindIndex(email)
+}
+
+// openEmail sets user.Email from email_encrypted, if the row was encrypted.
+func (m UserModel) openEmail(user *User, emailEncrypted string) error {
+	if emailEncrypted == "" {
+		return nil
+	}
+	if m.Keys == nil {
+		return fmt.Errorf("data: user %d has an encrypted email but no keys are configured", user.ID)
+	}
+
+	email, err := m.Keys.Open(emailEncrypted, emailContext)
+	if err != nil {
+		return fmt.Errorf("data: user %d: %w", user.ID, err)
+	}
+
+	user.Email = email
+	return nil
+}
+
+// checkPlaintextEmail returns ErrDuplicateEmail if a row other than id's
+// still holds email in plaintext. The unique constraint cannot catch it,
+// since the new row stores the blind index instead.
+func (m UserModel) checkPlaintextEmail(ctx context.Context, q queryer, email string, id int64) error {
+	if m.Keys == nil {
+		return nil
+	}
+
+	var exists bool
+
+	err := q.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 AND id <> $2)`, email, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrDuplicateEmail
+	}
+	return nil
+}
+
+// CountStaleEncryption returns the number of users whose email address is
+// not sealed with the primary key.
+func (m UserModel) CountStaleEncryption() (int, error) {
+	if m.Keys == nil {
+		return 0, nil
+	}
+
+	query := `
+	SELECT count(*)
+	FROM users
+	WHERE email_encrypted NOT LIKE 'enc:' || $1 || ':%'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, query, m.Keys.Primary()).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// ReencryptBatch seals the email addresses of up to limit users with the
+// primary key, whether they were in plaintext or sealed with an older key.
+// It returns the number of users updated; rows locked by another batch are
+// skipped, so batches may run concurrently.
+func (m UserModel) ReencryptBatch(limit int) (int, error) {
+	if m.Keys == nil {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT id, email, email_encrypted
+	FROM users
+	WHERE email_encrypted NOT LIKE 'enc:' || $1 || ':%'
+	ORDER BY id
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, query, m.Keys.Primary(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var users []*User
+
+	for rows.Next() {
+		var user User
+		var emailEncrypted string
+
+		if err := rows.Scan(&user.ID, &user.Email, &emailEncrypted); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		if err := m.openEmail(&user, emailEncrypted); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		users = append(users, &user)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, user := range users {
+		email, emailEncrypted, err := m.storedEmail(user.Email)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE users SET email = $1, email_encrypted = $2 WHERE id = $3`, email, emailEncrypted, user.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(users), nil
+}
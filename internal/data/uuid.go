@@ -0,0 +1,51 @@
+package data
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// UUID strategies decide how the server generates the UUIDs of movies that
+// clients create without one. Random UUIDs (version 4) reveal nothing;
+// ordered UUIDs (version 7) start with the creation time, so that they sort
+// by age and keep index inserts together.
+const (
+	UUIDRandom  = "random"
+	UUIDOrdered = "ordered"
+)
+
+var UUIDStrategies = []string{UUIDRandom, UUIDOrdered}
+
+var UUIDRX = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+func init() {
+	validator.RegisterPattern("uuid", UUIDRX, "must be a lowercase UUID such as 0189d6b4-7f5e-7c3a-9b1e-5d2f8a6c4e10")
+}
+
+// NewUUID returns a UUID generated with the strategy.
+func NewUUID(strategy string) (string, error) {
+	var b [16]byte
+
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+
+	version := byte(4)
+	if strategy == UUIDOrdered {
+		version = 7
+		var ms [8]byte
+		binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+		copy(b[:6], ms[2:])
+	}
+
+	b[6] = b[6]&0x0f | version<<4
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/validator"
+)
+
+const (
+	VideoTrailer = "trailer"
+	VideoTeaser  = "teaser"
+	VideoClip    = "clip"
+
+	VideoProviderYouTube = "youtube"
+	VideoProviderVimeo   = "vimeo"
+)
+
+var VideoTypes = []string{VideoTrailer, VideoTeaser, VideoClip}
+
+var ErrDuplicateVideo = errors.New("duplicate video")
+
+var (
+	youTubeIDRX = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	vimeoIDRX   = regexp.MustCompile(`^[0-9]{1,12}$`)
+)
+
+// MovieVideo is a trailer, teaser or clip of a movie hosted on YouTube or
+// Vimeo. Title and ThumbnailURL are the provider's, when they were fetched.
+type MovieVideo struct {
+	ID           int64     `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	MovieID      int64     `json:"movie_id"`
+	Type         string    `json:"type"`
+	URL          string    `json:"url"`
+	Provider     string    `json:"provider"`
+	ProviderID   string    `json:"provider_id"`
+	Title        string    `json:"title,omitempty"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+}
+
+// SetURL sets the video's provider and its ID there from a YouTube or Vimeo
+// URL in any of the usual forms, such as a youtu.be link or an embed URL,
+// and replaces the URL with the provider's canonical one. URLs which do not
+// match leave Provider empty.
+func (video *MovieVideo) SetURL(raw string) {
+	video.URL = raw
+	video.Provider, video.ProviderID = "", ""
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return
+	}
+
+	host := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."), "m.")
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	var id string
+
+	switch host {
+	case "youtube.com":
+		switch {
+		case u.Path == "/watch":
+			id = u.Query().Get("v")
+		case len(segments) == 2 && (segments[0] == "embed" || segments[0] == "shorts"):
+			id = segments[1]
+		}
+		if youTubeIDRX.MatchString(id) {
+			video.Provider = VideoProviderYouTube
+		}
+	case "youtu.be":
+		id = segments[0]
+		if len(segments) == 1 && youTubeIDRX.MatchString(id) {
+			video.Provider = VideoProviderYouTube
+		}
+	case "vimeo.com":
+		id = segments[0]
+		if len(segments) == 1 && vimeoIDRX.MatchString(id) {
+			video.Provider = VideoProviderVimeo
+		}
+	case "player.vimeo.com":
+		if len(segments) == 2 && segments[0] == "video" {
+			id = segments[1]
+		}
+		if vimeoIDRX.MatchString(id) {
+			video.Provider = VideoProviderVimeo
+		}
+	}
+
+	switch video.Provider {
+	case VideoProviderYouTube:
+		video.ProviderID = id
+		video.URL = "https://www.youtube.com/watch?v=" + id
+	case VideoProviderVimeo:
+		video.ProviderID = id
+		video.URL = "https://vimeo.com/" + id
+	}
+}
+
+func ValidateMovieVideo(v *validator.Validator, video *MovieVideo) {
+	v.Check(video.URL != "", "url", "must be provided")
+	v.Check(video.URL == "" || video.Provider != "", "url", "must be a YouTube or Vimeo video URL")
+	v.Check(validator.PermittedValue(video.Type, VideoTypes...), "type", "must be trailer, teaser or clip")
+}
+
+type MovieVideoModel struct {
+	DB *sql.DB
+}
+
+// Insert adds the video to its movie. Each video can be added to a movie
+// once.
+func (m MovieVideoModel) Insert(video *MovieVideo) error {
+	query := `
+	INSERT INTO movie_videos (movie_id, type, url, provider, provider_id, title, thumbnail_url)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at`
+
+	args := []any{video.MovieID, video.Type, video.URL, video.Provider, video.ProviderID, video.Title, video.ThumbnailURL}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&video.ID, &video.CreatedAt)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "movie_videos_movie_provider_key"):
+			return ErrDuplicateVideo
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m MovieVideoModel) GetAllForMovie(movieID int64) ([]*MovieVideo, error) {
+	query := `
+	SELECT id, created_at, movie_id, type, url, provider, provider_id, title, thumbnail_url
+	FROM movie_videos
+	WHERE movie_id = $1
+	ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	videos := []*MovieVideo{}
+
+	for rows.Next() {
+		var video MovieVideo
+
+		err := rows.Scan(
+			&video.ID,
+			&video.CreatedAt,
+			&video.MovieID,
+			&video.Type,
+			&video.URL,
+			&video.Provider,
+			&video.ProviderID,
+			&video.Title,
+			&video.ThumbnailURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		videos = append(videos, &video)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+func (m MovieVideoModel) Delete(movieID, id int64) error {
+	query := `
+	DELETE FROM movie_videos
+	WHERE id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
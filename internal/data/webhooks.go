@@ -0,0 +1,173 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+
+	"greenlight.bcc/internal/validator"
+)
+
+// Webhook is a user's subscription to events, which are POSTed to URL and
+// signed with Secret.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    int64     `json:"-"`
+	// OrgID is the organization the user was acting in when subscribing;
+	// events about other organizations are not delivered.
+	OrgID  int64    `json:"-"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"-"`
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	u, err := url.Parse(webhook.URL)
+	v.Check(webhook.URL != "", "url", "must be provided")
+	v.Check(len(webhook.URL) <= 2048, "url", "must not be more than 2048 bytes long")
+	v.Check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "", "url", "must be an absolute http or https URL")
+
+	v.Check(len(webhook.Events) > 0, "events", "must contain at least 1 event")
+	v.Check(validator.Unique(webhook.Events), "events", "must not contain duplicate values")
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	query := `
+	INSERT INTO webhooks (user_id, org_id, url, events, secret)
+	VALUES ($1, NULLIF($2::bigint, 0), $3, $4, $5)
+	RETURNING id, created_at`
+
+	args := []any{webhook.UserID, webhook.OrgID, webhook.URL, pq.Array(webhook.Events), webhook.Secret}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.ID, &webhook.CreatedAt)
+}
+
+// Get returns the user's webhook with the given ID.
+func (m WebhookModel) Get(id, userID int64) (*Webhook, error) {
+	query := `
+	SELECT id, created_at, user_id, COALESCE(org_id, 0), url, events, secret
+	FROM webhooks
+	WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var webhook Webhook
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&webhook.ID,
+		&webhook.CreatedAt,
+		&webhook.UserID,
+		&webhook.OrgID,
+		&webhook.URL,
+		pq.Array(&webhook.Events),
+		&webhook.Secret,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func (m WebhookModel) GetAllForUser(userID int64) ([]*Webhook, error) {
+	query := `
+	SELECT id, created_at, user_id, COALESCE(org_id, 0), url, events, secret
+	FROM webhooks
+	WHERE user_id = $1
+	ORDER BY id`
+
+	return m.query(query, userID)
+}
+
+// GetAllForEvent returns every webhook subscribed to events of eventType,
+// whoever owns it.
+func (m WebhookModel) GetAllForEvent(eventType string) ([]*Webhook, error) {
+	query := `
+	SELECT id, created_at, user_id, COALESCE(org_id, 0), url, events, secret
+	FROM webhooks
+	WHERE $1 = ANY(events)
+	ORDER BY id`
+
+	return m.query(query, eventType)
+}
+
+func (m WebhookModel) query(query string, args ...any) ([]*Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(
+			&webhook.ID,
+			&webhook.CreatedAt,
+			&webhook.UserID,
+			&webhook.OrgID,
+			&webhook.URL,
+			pq.Array(&webhook.Events),
+			&webhook.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (m WebhookModel) Delete(id, userID int64) error {
+	query := `
+	DELETE FROM webhooks
+	WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
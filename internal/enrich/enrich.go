@@ -0,0 +1,225 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrNotConfigured = errors.New("metadata enrichment is not configured")
+	ErrNotFound      = errors.New("no matching movie found")
+)
+
+// Query identifies the movie to look up. The TMDb ID is preferred, then the
+// IMDb ID, then a search by title and year.
+type Query struct {
+	TMDbID int64
+	IMDbID string
+	Title  string
+	Year   int32
+}
+
+func (q Query) key() string {
+	switch {
+	case q.TMDbID != 0:
+		return "tmdb:" + strconv.FormatInt(q.TMDbID, 10)
+	case q.IMDbID != "":
+		return "imdb:" + q.IMDbID
+	default:
+		return fmt.Sprintf("search:%s:%d", q.Title, q.Year)
+	}
+}
+
+type Metadata struct {
+	TMDbID    int64
+	IMDbID    string
+	Synopsis  string
+	PosterURL string
+	Runtime   int32
+}
+
+type Enricher interface {
+	Lookup(q Query) (*Metadata, error)
+}
+
+type NoopEnricher struct{}
+
+func (NoopEnricher) Lookup(q Query) (*Metadata, error) {
+	return nil, ErrNotConfigured
+}
+
+// New returns a TMDb client authenticating with the given API read access
+// token, or a NoopEnricher when no token is configured.
+func New(token string) Enricher {
+	if token == "" {
+		return NoopEnricher{}
+	}
+
+	return &TMDb{
+		token:      token,
+		baseURL:    "https://api.themoviedb.org/3",
+		imageURL:   "https://image.tmdb.org/t/p/w500",
+		client:     &http.Client{Timeout: 5 * time.Second},
+		limiter:    rate.NewLimiter(rate.Limit(4), 8),
+		cacheTTL:   24 * time.Hour,
+		cacheLimit: 10000,
+		cache:      make(map[string]cacheEntry),
+	}
+}
+
+type cacheEntry struct {
+	metadata *Metadata
+	err      error
+	expiry   time.Time
+}
+
+// TMDb looks movies up in The Movie Database. Requests are rate limited to
+// stay well under the API's limits, and results, including misses, are
+// cached in memory.
+type TMDb struct {
+	token      string
+	baseURL    string
+	imageURL   string
+	client     *http.Client
+	limiter    *rate.Limiter
+	cacheTTL   time.Duration
+	cacheLimit int
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func (t *TMDb) Lookup(q Query) (*Metadata, error) {
+	key := q.key()
+
+	t.mu.Lock()
+	entry, ok := t.cache[key]
+	t.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expiry) {
+		return entry.metadata, entry.err
+	}
+
+	metadata, err := t.lookup(q)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= t.cacheLimit {
+		t.cache = make(map[string]cacheEntry)
+	}
+	t.cache[key] = cacheEntry{metadata: metadata, err: err, expiry: time.Now().Add(t.cacheTTL)}
+	t.mu.Unlock()
+
+	return metadata, err
+}
+
+func (t *TMDb) lookup(q Query) (*Metadata, error) {
+	id := q.TMDbID
+
+	switch {
+	case id != 0:
+	case q.IMDbID != "":
+		var result struct {
+			MovieResults []struct {
+				ID int64 `json:"id"`
+			} `json:"movie_results"`
+		}
+
+		err := t.get("/find/"+url.PathEscape(q.IMDbID), url.Values{"external_source": {"imdb_id"}}, &result)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.MovieResults) == 0 {
+			return nil, ErrNotFound
+		}
+		id = result.MovieResults[0].ID
+	default:
+		params := url.Values{"query": {q.Title}}
+		if q.Year != 0 {
+			params.Set("year", strconv.Itoa(int(q.Year)))
+		}
+
+		var result struct {
+			Results []struct {
+				ID int64 `json:"id"`
+			} `json:"results"`
+		}
+
+		err := t.get("/search/movie", params, &result)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Results) == 0 {
+			return nil, ErrNotFound
+		}
+		id = result.Results[0].ID
+	}
+
+	var movie struct {
+		ID         int64  `json:"id"`
+		IMDbID     string `json:"imdb_id"`
+		Overview   string `json:"overview"`
+		PosterPath string `json:"poster_path"`
+		Runtime    int32  `json:"runtime"`
+	}
+
+	err := t.get("/movie/"+strconv.FormatInt(id, 10), nil, &movie)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &Metadata{
+		TMDbID:   movie.ID,
+		IMDbID:   movie.IMDbID,
+		Synopsis: movie.Overview,
+		Runtime:  movie.Runtime,
+	}
+	if movie.PosterPath != "" {
+		metadata.PosterURL = t.imageURL + movie.PosterPath
+	}
+
+	return metadata, nil
+}
+
+func (t *TMDb) get(path string, params url.Values, dst any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := t.limiter.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.token)
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("tmdb responded with status %d", res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
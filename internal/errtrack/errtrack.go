@@ -0,0 +1,129 @@
+package errtrack
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+var ErrInvalidDSN = errors.New("invalid error tracking dsn")
+
+type Event struct {
+	Err    error
+	Level  string
+	Stack  []byte
+	UserID int64
+	Tags   map[string]string
+}
+
+type Reporter interface {
+	Report(event Event) error
+}
+
+type NoopReporter struct{}
+
+func (NoopReporter) Report(event Event) error {
+	return nil
+}
+
+// New returns a Sentry reporter for the given DSN, or a NoopReporter when
+// the DSN is empty.
+func New(dsn, release, environment string) (Reporter, error) {
+	if dsn == "" {
+		return NoopReporter{}, nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.User.Username() == "" {
+		return nil, ErrInvalidDSN
+	}
+
+	projectID := strings.TrimPrefix(u.Path, "/")
+	if projectID == "" {
+		return nil, ErrInvalidDSN
+	}
+
+	return &SentryReporter{
+		endpoint:    fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, projectID),
+		publicKey:   u.User.Username(),
+		release:     release,
+		environment: environment,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+type SentryReporter struct {
+	endpoint    string
+	publicKey   string
+	release     string
+	environment string
+	client      *http.Client
+}
+
+func (s *SentryReporter) Report(event Event) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	level := event.Level
+	if level == "" {
+		level = LevelError
+	}
+
+	payload := map[string]any{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		"level":       level,
+		"platform":    "go",
+		"release":     s.release,
+		"environment": s.environment,
+		"message":     event.Err.Error(),
+		"tags":        event.Tags,
+	}
+
+	if event.UserID != 0 {
+		payload["user"] = map[string]string{"id": strconv.FormatInt(event.UserID, 10)}
+	}
+
+	if event.Stack != nil {
+		payload["extra"] = map[string]string{"stack": string(event.Stack)}
+	}
+
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_key=%s, sentry_client=greenlight/%s", s.publicKey, s.release))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("error tracker responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
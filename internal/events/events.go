@@ -0,0 +1,130 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	TypeNotification = "notification"
+	TypeMovieCreated = "movie.created"
+	TypeMovieUpdated = "movie.updated"
+	TypeMovieDeleted = "movie.deleted"
+	// TypeMoviePublished is published when a draft movie is published.
+	// Movies created already published only get TypeMovieCreated.
+	TypeMoviePublished = "movie.published"
+	TypeCommentReply   = "comment.reply"
+	TypeListUpdated    = "list.updated"
+)
+
+// Event is published on the bus. Events with a UserID are delivered only to
+// that user's subscriptions and events with an OrgID only to subscriptions in
+// that organization; the rest are broadcast.
+type Event struct {
+	Type   string `json:"type"`
+	UserID int64  `json:"-"`
+	OrgID  int64  `json:"-"`
+	Data   any    `json:"data"`
+}
+
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	userID int64
+	orgID  int64
+	all    bool
+	bus    *Bus
+}
+
+// Close unsubscribes from the bus. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+type Bus struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	closed  bool
+	dropped atomic.Int64
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription receiving broadcast events and events for
+// userID or orgID. Its channel is closed when the bus is closed.
+func (b *Bus) Subscribe(userID, orgID int64, buffer int) *Subscription {
+	return b.subscribe(&Subscription{userID: userID, orgID: orgID}, buffer)
+}
+
+func (b *Bus) subscribe(sub *Subscription, buffer int) *Subscription {
+	ch := make(chan Event, buffer)
+	sub.C, sub.ch, sub.bus = ch, ch, b
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return sub
+	}
+
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// SubscribeAll returns a subscription receiving every event, whoever it is
+// for. It is meant for consumers inside the application, such as the
+// activity recorder, and must never be exposed to clients.
+func (b *Bus) SubscribeAll(buffer int) *Subscription {
+	return b.subscribe(&Subscription{all: true}, buffer)
+}
+
+func (b *Bus) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish delivers the event without blocking. Subscribers whose buffer is
+// full miss the event, which is counted in Dropped.
+func (b *Bus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if event.UserID != 0 && event.UserID != sub.userID && !sub.all {
+			continue
+		}
+		if event.OrgID != 0 && event.OrgID != sub.orgID && !sub.all {
+			continue
+		}
+
+		select {
+		case sub.ch <- event:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+func (b *Bus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Close closes every subscription and rejects new ones.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
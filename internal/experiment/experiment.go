@@ -0,0 +1,98 @@
+// Package experiment assigns subjects, such as users, to the variants of
+// A/B experiments. A subject's variant is derived from a hash of the
+// experiment and subject, so every instance assigns it the same variant
+// without having to store or share assignments.
+package experiment
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var nameRX = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+type Experiment struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+}
+
+// Parse reads experiments from a space separated list such as
+// "search=control:90,fuzzy:10 poster=small,large", where each experiment
+// names its variants with their relative weights. Variants without a
+// weight weigh 1.
+func Parse(spec string) ([]Experiment, error) {
+	var experiments []Experiment
+	seen := make(map[string]bool)
+
+	for _, field := range strings.Fields(spec) {
+		name, variants, ok := strings.Cut(field, "=")
+		if !ok || !nameRX.MatchString(name) {
+			return nil, fmt.Errorf("experiment: invalid experiment %q", field)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("experiment: %s defined twice", name)
+		}
+		seen[name] = true
+
+		e := Experiment{Name: name}
+		names := make(map[string]bool)
+
+		for _, variant := range strings.Split(variants, ",") {
+			variantName, weight, hasWeight := strings.Cut(variant, ":")
+			if !nameRX.MatchString(variantName) || names[variantName] {
+				return nil, fmt.Errorf("experiment: invalid or repeated variant %q in %s", variantName, name)
+			}
+			names[variantName] = true
+
+			v := Variant{Name: variantName, Weight: 1}
+			if hasWeight {
+				w, err := strconv.Atoi(weight)
+				if err != nil || w < 1 {
+					return nil, fmt.Errorf("experiment: invalid weight %q in %s", weight, name)
+				}
+				v.Weight = w
+			}
+
+			e.Variants = append(e.Variants, v)
+		}
+
+		if len(e.Variants) < 2 {
+			return nil, fmt.Errorf("experiment: %s needs at least two variants", name)
+		}
+
+		experiments = append(experiments, e)
+	}
+
+	return experiments, nil
+}
+
+// Assign returns the variant the subject is in. The same subject is always
+// assigned the same variant for as long as the experiment's variants are
+// unchanged.
+func (e Experiment) Assign(subject string) string {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+
+	sum := sha256.Sum256([]byte(e.Name + "\x00" + subject))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+
+	for _, v := range e.Variants {
+		if bucket < v.Weight {
+			return v.Name
+		}
+		bucket -= v.Weight
+	}
+
+	return e.Variants[len(e.Variants)-1].Name
+}
@@ -0,0 +1,163 @@
+// Package fieldcrypt encrypts individual database values, such as personal
+// data, with AES-256-GCM before they are stored. Each sealed value names the
+// key it was sealed with, so that keys can be rotated: new values are sealed
+// with the primary key while values sealed with older keys can still be
+// opened until they are re-encrypted.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// KeySize is the length of encryption and index keys in bytes.
+const KeySize = 32
+
+const (
+	sealedPrefix = "enc:"
+	indexPrefix  = "hmac:"
+)
+
+var (
+	ErrUnknownKey = errors.New("fieldcrypt: value sealed with an unknown key")
+	ErrMalformed  = errors.New("fieldcrypt: malformed sealed value")
+	ErrDecrypt    = errors.New("fieldcrypt: value could not be decrypted")
+)
+
+var keyIDRX = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Key is an encryption key and the ID sealed values refer to it by.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Keyring seals values with its primary key and opens values sealed with
+// any of its keys. It is safe for concurrent use.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+	index   []byte
+}
+
+// New returns a keyring whose primary key is the first of keys. indexKey is
+// used for blind indexes; unlike the encryption keys it cannot be rotated
+// without rebuilding every index.
+func New(keys []Key, indexKey []byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("fieldcrypt: at least one key is required")
+	}
+	if len(indexKey) != KeySize {
+		return nil, fmt.Errorf("fieldcrypt: index key must be %d bytes", KeySize)
+	}
+
+	k := &Keyring{primary: keys[0].ID, aeads: make(map[string]cipher.AEAD), index: indexKey}
+
+	for _, key := range keys {
+		if !keyIDRX.MatchString(key.ID) {
+			return nil, fmt.Errorf("fieldcrypt: invalid key ID %q", key.ID)
+		}
+		if _, exists := k.aeads[key.ID]; exists {
+			return nil, fmt.Errorf("fieldcrypt: duplicate key ID %q", key.ID)
+		}
+		if len(key.Secret) != KeySize {
+			return nil, fmt.Errorf("fieldcrypt: key %q must be %d bytes", key.ID, KeySize)
+		}
+
+		block, err := aes.NewCipher(key.Secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[key.ID] = aead
+	}
+
+	return k, nil
+}
+
+// Primary returns the ID of the key new values are sealed with.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Seal encrypts plaintext with the primary key. context, such as the table
+// and column, is authenticated but not stored, so a value only opens with
+// the context it was sealed with and cannot be moved to another column.
+func (k *Keyring) Seal(plaintext, context string) (string, error) {
+	aead := k.aeads[k.primary]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(context))
+
+	return sealedPrefix + k.primary + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value returned by Seal.
+func (k *Keyring) Open(sealed, context string) (string, error) {
+	keyID, payload, ok := split(sealed)
+	if !ok {
+		return "", ErrMalformed
+	}
+
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(data) < aead.NonceSize()+aead.Overhead() {
+		return "", ErrMalformed
+	}
+
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(context))
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(plaintext), nil
+}
+
+// Stale reports whether value needs re-encrypting: it is either not sealed
+// at all or sealed with a key other than the primary one.
+func (k *Keyring) Stale(value string) bool {
+	keyID, _, ok := split(value)
+	return !ok || keyID != k.primary
+}
+
+// BlindIndex returns a keyed hash of value which can be stored alongside
+// its sealed form and looked up by equality, without revealing value.
+// Values are compared case-insensitively, as email addresses are.
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(strings.ToLower(value)))
+	return indexPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsSealed reports whether value was returned by Seal.
+func IsSealed(value string) bool {
+	_, _, ok := split(value)
+	return ok
+}
+
+func split(sealed string) (keyID, payload string, ok bool) {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		return "", "", false
+	}
+	return strings.Cut(sealed[len(sealedPrefix):], ":")
+}
@@ -0,0 +1,119 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/sigv4"
+)
+
+// Unwrapper decrypts a data key which is itself encrypted, such as by a key
+// management service.
+type Unwrapper interface {
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// ParseKeys parses a list of keys separated by commas or spaces, primary
+// first. Each is written id:secret, where secret is the base64 encoded key,
+// or id:kms:blob, where blob is the base64 encoded key as encrypted by the
+// key management service, to be decrypted by unwrap. unwrap may be nil if
+// no keys are wrapped.
+func ParseKeys(ctx context.Context, spec string, unwrap Unwrapper) ([]Key, error) {
+	var keys []Key
+
+	for _, field := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' }) {
+		id, value, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("fieldcrypt: key %q must be written id:secret", field)
+		}
+
+		secret, err := ParseSecret(ctx, value, unwrap)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q: %w", id, err)
+		}
+
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+
+	return keys, nil
+}
+
+// ParseSecret decodes a single key written as in ParseKeys, without its ID.
+func ParseSecret(ctx context.Context, value string, unwrap Unwrapper) ([]byte, error) {
+	wrapped := strings.HasPrefix(value, "kms:")
+
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, "kms:"))
+	if err != nil {
+		return nil, err
+	}
+
+	if !wrapped {
+		return secret, nil
+	}
+
+	if unwrap == nil {
+		return nil, fmt.Errorf("no key management service is configured")
+	}
+	return unwrap.Unwrap(ctx, secret)
+}
+
+// KMS unwraps data keys with AWS KMS. The data keys are typically created
+// with its GenerateDataKey operation, keeping the CiphertextBlob.
+type KMS struct {
+	region   string
+	creds    sigv4.Credentials
+	endpoint string
+	client   *http.Client
+}
+
+func NewKMS(region, accessKeyID, secretAccessKey string) *KMS {
+	return &KMS{
+		region:   region,
+		creds:    sigv4.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey},
+		endpoint: fmt.Sprintf("https://kms.%s.amazonaws.com/", region),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (k *KMS) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	body, err := json.Marshal(map[string][]byte{"CiphertextBlob": wrapped})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+
+	sigv4.Sign(req, body, k.creds, k.region, "kms", time.Now())
+
+	res, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("kms: decrypt failed with status %d: %s", res.StatusCode, message)
+	}
+
+	var output struct {
+		Plaintext []byte
+	}
+	if err := json.NewDecoder(res.Body).Decode(&output); err != nil {
+		return nil, err
+	}
+
+	return output.Plaintext, nil
+}
@@ -0,0 +1,401 @@
+// Package geoip locates IP addresses using a MaxMind DB file, such as
+// GeoLite2-City.mmdb. Only the country and city are read from its records.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// Location is where an IP address is. Either field may be empty if the
+// database does not know it.
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code, such as "FR".
+	Country string
+	// City is the city's English name.
+	City string
+}
+
+type Locator interface {
+	Locate(ip net.IP) (Location, error)
+}
+
+type NoopLocator struct{}
+
+func (NoopLocator) Locate(ip net.IP) (Location, error) {
+	return Location{}, nil
+}
+
+var ErrMalformed = errors.New("geoip: malformed database")
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// DB is a MaxMind DB file held in memory. It is safe for concurrent use.
+type DB struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+}
+
+// Open reads the database at path.
+func Open(path string) (*DB, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(contents)
+}
+
+// New parses a database from its contents.
+func New(contents []byte) (*DB, error) {
+	i := bytes.LastIndex(contents, metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no metadata", ErrMalformed)
+	}
+
+	metadata := contents[i+len(metadataMarker):]
+	value, _, err := decoder{metadata}.decode(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrMalformed)
+	}
+
+	db := &DB{
+		nodeCount:  uintField(fields, "node_count"),
+		recordSize: uintField(fields, "record_size"),
+		ipVersion:  uintField(fields, "ip_version"),
+	}
+
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrMalformed, db.recordSize)
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", ErrMalformed, db.ipVersion)
+	}
+
+	// The search tree is followed by 16 zero bytes, then the data section.
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+16 > uint(i) {
+		return nil, fmt.Errorf("%w: search tree is larger than the file", ErrMalformed)
+	}
+	db.tree = contents[:treeSize]
+	db.data = contents[treeSize+16 : i]
+
+	return db, nil
+}
+
+func uintField(fields map[string]any, name string) uint {
+	n, _ := fields[name].(uint64)
+	return uint(n)
+}
+
+// Locate returns the location of ip, which is empty if the database has no
+// record of it.
+func (db *DB) Locate(ip net.IP) (Location, error) {
+	offset, ok, err := db.find(ip)
+	if err != nil || !ok {
+		return Location{}, err
+	}
+
+	value, _, err := decoder{db.data}.decode(offset, 0)
+	if err != nil {
+		return Location{}, err
+	}
+
+	return Location{
+		Country: lookupString(value, "country", "iso_code"),
+		City:    lookupString(value, "city", "names", "en"),
+	}, nil
+}
+
+func lookupString(value any, path ...string) string {
+	for _, key := range path {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		value = m[key]
+	}
+	s, _ := value.(string)
+	return s
+}
+
+// find walks the search tree for ip, returning the offset of its record in
+// the data section.
+func (db *DB) find(ip net.IP) (uint, bool, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	} else if db.ipVersion == 4 || ip.To16() == nil {
+		return 0, false, nil
+	}
+
+	node := uint(0)
+
+	// IPv4 addresses are found under ::/96 in an IPv6 tree.
+	if db.ipVersion == 6 && len(ip) == net.IPv4len {
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.record(node, 0)
+		}
+	}
+
+	for i := 0; i < len(ip)*8 && node < db.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-i%8)) & 1
+		node = db.record(node, bit)
+	}
+
+	switch {
+	case node == db.nodeCount:
+		return 0, false, nil
+	case node < db.nodeCount:
+		return 0, false, fmt.Errorf("%w: search tree is too deep", ErrMalformed)
+	}
+
+	offset := node - db.nodeCount - 16
+	if offset >= uint(len(db.data)) {
+		return 0, false, fmt.Errorf("%w: record points past the data section", ErrMalformed)
+	}
+	return offset, true, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (db *DB) record(node, bit uint) uint {
+	b := db.tree[node*db.recordSize/4:]
+
+	switch db.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// Data section field types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds how deeply values, and pointers to them, may nest, so a
+// malformed file cannot recurse forever.
+const maxDepth = 32
+
+type decoder struct {
+	buf []byte
+}
+
+// decode decodes the value at offset, returning it and the offset after it.
+func (d decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("%w: values nested too deeply", ErrMalformed)
+	}
+
+	ctrl, offset, err := d.byte(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	kind := uint(ctrl >> 5)
+
+	if kind == typePointer {
+		target, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	if kind == typeExtended {
+		var b byte
+		b, offset, err = d.byte(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		kind = 7 + uint(b)
+	}
+
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch kind {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			var key, value any
+			key, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", ErrMalformed)
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+
+	case typeArray:
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value any
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	b, next, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch kind {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes, typeUint128:
+		return b, next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: double of %d bytes", ErrMalformed, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: float of %d bytes", ErrMalformed, size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%w: integer of %d bytes", ErrMalformed, size)
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: integer of %d bytes", ErrMalformed, size)
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), next, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: unsupported type %d", ErrMalformed, kind)
+	}
+}
+
+// pointer returns the offset a pointer refers to and the offset after it.
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint((ctrl>>3)&0x3) + 1
+
+	b, next, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var target uint
+	if n < 4 {
+		target = uint(ctrl & 0x7)
+	}
+	for _, c := range b {
+		target = target<<8 | uint(c)
+	}
+
+	switch n {
+	case 2:
+		target += 2048
+	case 3:
+		target += 526336
+	}
+
+	return target, next, nil
+}
+
+// size returns the payload size encoded in ctrl and the bytes after it.
+func (d decoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	b, next, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var extra uint
+	for _, c := range b {
+		extra = extra<<8 | uint(c)
+	}
+
+	switch n {
+	case 1:
+		return 29 + extra, next, nil
+	case 2:
+		return 285 + extra, next, nil
+	default:
+		return 65821 + extra, next, nil
+	}
+}
+
+func (d decoder) byte(offset uint) (byte, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("%w: value past the end of the data", ErrMalformed)
+	}
+	return d.buf[offset], offset + 1, nil
+}
+
+func (d decoder) bytes(offset, n uint) ([]byte, uint, error) {
+	if offset+n > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("%w: value past the end of the data", ErrMalformed)
+	}
+	return d.buf[offset : offset+n], offset + n, nil
+}
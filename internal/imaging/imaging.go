@@ -0,0 +1,60 @@
+// Package imaging resizes uploaded images.
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// Fill scales and crops img to exactly width by height pixels, keeping the
+// centre of the image. Each output pixel is the average of the source pixels
+// it covers, which is good enough for shrinking photos to avatar sizes.
+func Fill(img image.Image, width, height int) *image.RGBA {
+	src := img.Bounds()
+	crop := src
+
+	if src.Dx()*height > src.Dy()*width {
+		w := src.Dy() * width / height
+		crop.Min.X += (src.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := src.Dx() * height / width
+		crop.Min.Y += (src.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0, y1 := span(crop.Min.Y, crop.Dy(), y, height)
+
+		for x := 0; x < width; x++ {
+			x0, x1 := span(crop.Min.X, crop.Dx(), x, width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// span returns the source pixels [from, to) which output pixel i of n
+// covers, when the source starts at min and is size pixels long. Sources
+// smaller than the output repeat pixels.
+func span(min, size, i, n int) (int, int) {
+	from := min + i*size/n
+	to := min + (i+1)*size/n
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
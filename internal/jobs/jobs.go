@@ -0,0 +1,142 @@
+// Package jobs runs long operations in the background, keeping track of
+// their progress so that clients can poll for it.
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrAlreadyRunning is returned by Start when a job of the same name has not
+// finished yet.
+var ErrAlreadyRunning = errors.New("job already running")
+
+// maxFinished is the number of finished jobs kept for clients to look up.
+const maxFinished = 100
+
+// Job is a snapshot of a job's state. Done counts the steps completed out of
+// Total, and Step names the one being run.
+type Job struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Step       string     `json:"step,omitempty"`
+	Done       int        `json:"done"`
+	Total      int        `json:"total"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Step is a unit of a job's work. Progress is reported after each step, and
+// a failing step ends the job.
+type Step struct {
+	Name string
+	Run  func() error
+}
+
+type Runner struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*Job
+	spawn  func(func())
+}
+
+// NewRunner returns a runner which starts jobs with spawn, such as a
+// function tracking goroutines for graceful shutdown.
+func NewRunner(spawn func(func())) *Runner {
+	return &Runner{jobs: make(map[int64]*Job), spawn: spawn}
+}
+
+// Start runs the steps one after another in the background and returns the
+// job as it starts.
+func (r *Runner) Start(name string, steps []Step) (Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range r.jobs {
+		if job.Name == name && job.Status == StatusRunning {
+			return Job{}, ErrAlreadyRunning
+		}
+	}
+
+	r.nextID++
+	job := &Job{ID: r.nextID, Name: name, Status: StatusRunning, Total: len(steps), StartedAt: time.Now()}
+	r.jobs[job.ID] = job
+	r.prune()
+
+	r.spawn(func() { r.run(job, steps) })
+
+	return *job, nil
+}
+
+func (r *Runner) run(job *Job, steps []Step) {
+	var err error
+
+	for _, step := range steps {
+		r.update(job, func() { job.Step = step.Name })
+
+		err = step.Run()
+		if err != nil {
+			break
+		}
+
+		r.update(job, func() { job.Done++ })
+	}
+
+	r.update(job, func() {
+		now := time.Now()
+		job.FinishedAt = &now
+		job.Step = ""
+		job.Status = StatusSucceeded
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+		}
+	})
+}
+
+func (r *Runner) update(job *Job, fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn()
+}
+
+// Get returns the job with the ID, if it is running or recently finished.
+func (r *Runner) Get(id int64) (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// prune forgets the oldest finished jobs beyond maxFinished.
+func (r *Runner) prune() {
+	var finished []int64
+	for id, job := range r.jobs {
+		if job.Status != StatusRunning {
+			finished = append(finished, id)
+		}
+	}
+
+	if len(finished) <= maxFinished {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool { return finished[i] < finished[j] })
+	for _, id := range finished[:len(finished)-maxFinished] {
+		delete(r.jobs, id)
+	}
+}
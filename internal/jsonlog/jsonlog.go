@@ -2,10 +2,13 @@ package jsonlog
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,22 +29,51 @@ func (l Level) String() string {
 		return "ERROR"
 	case LevelFatal:
 		return "FATAL"
+	case LevelOff:
+		return "OFF"
 	default:
 		return ""
 	}
 }
 
+var ErrInvalidLevel = errors.New("invalid log level")
+
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "INFO":
+		return LevelInfo, nil
+	case "ERROR":
+		return LevelError, nil
+	case "FATAL":
+		return LevelFatal, nil
+	case "OFF":
+		return LevelOff, nil
+	default:
+		return 0, ErrInvalidLevel
+	}
+}
+
 type Logger struct {
 	out      io.Writer
-	minLevel Level
+	minLevel atomic.Int32
 	mu       sync.Mutex
+	sampler  *sampler
 }
 
 func New(out io.Writer, minLevel Level) *Logger {
-	return &Logger{
-		out:      out,
-		minLevel: minLevel,
+	l := &Logger{
+		out: out,
 	}
+	l.SetLevel(minLevel)
+	return l
+}
+
+func (l *Logger) Level() Level {
+	return Level(l.minLevel.Load())
+}
+
+func (l *Logger) SetLevel(level Level) {
+	l.minLevel.Store(int32(level))
 }
 
 func (l *Logger) PrintInfo(message string, properties map[string]string) {
@@ -52,15 +84,56 @@ func (l *Logger) PrintError(err error, properties map[string]string) {
 }
 func (l *Logger) PrintFatal(err error, properties map[string]string) {
 	l.print(LevelFatal, err.Error(), properties)
+	if f, ok := l.out.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
 	os.Exit(1)
 }
 
+// SetSampling limits identical error messages to the first n per window.
+// Further occurrences are dropped and reported in a single summary line once
+// the window has passed. It must be called before the logger is shared.
+func (l *Logger) SetSampling(first int, window time.Duration) {
+	if first < 1 || window <= 0 {
+		l.sampler = nil
+		return
+	}
+
+	l.sampler = newSampler(first, window)
+
+	go func() {
+		ticker := time.NewTicker(window)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			for _, summary := range l.sampler.sweep(now) {
+				l.write(LevelError, "suppressed similar messages", summary.properties(), false)
+			}
+		}
+	}()
+}
+
 func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
 
-	if level < l.minLevel {
+	if level < l.Level() {
 		return 0, nil
 	}
 
+	if level == LevelError && l.sampler != nil {
+		allowed, summary := l.sampler.allow(message, time.Now())
+		if summary != nil {
+			l.write(LevelError, "suppressed similar messages", summary.properties(), false)
+		}
+		if !allowed {
+			return 0, nil
+		}
+	}
+
+	return l.write(level, message, properties, level >= LevelError)
+}
+
+func (l *Logger) write(level Level, message string, properties map[string]string, trace bool) (int, error) {
+
 	aux := struct {
 		Level      string            `json:"level"`
 		Time       string            `json:"time"`
@@ -74,7 +147,7 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 		Properties: properties,
 	}
 
-	if level >= LevelError {
+	if trace {
 		aux.Trace = string(debug.Stack())
 	}
 
@@ -0,0 +1,85 @@
+package jsonlog
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+type sampler struct {
+	first  int
+	window time.Duration
+	mu     sync.Mutex
+	counts map[string]*sampleCount
+}
+
+type sampleCount struct {
+	start time.Time
+	seen  int
+}
+
+type sampleSummary struct {
+	message    string
+	suppressed int
+	window     time.Duration
+}
+
+func (s sampleSummary) properties() map[string]string {
+	return map[string]string{
+		"message":    s.message,
+		"suppressed": strconv.Itoa(s.suppressed),
+		"window":     s.window.String(),
+	}
+}
+
+func newSampler(first int, window time.Duration) *sampler {
+	return &sampler{
+		first:  first,
+		window: window,
+		counts: make(map[string]*sampleCount),
+	}
+}
+
+// allow records an occurrence of message and reports whether it should be
+// logged. When the occurrence starts a new window and messages were dropped
+// in the previous one, a summary of them is returned as well.
+func (s *sampler) allow(message string, now time.Time) (bool, *sampleSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summary *sampleSummary
+
+	c, ok := s.counts[message]
+	if !ok || now.Sub(c.start) >= s.window {
+		if ok && c.seen > s.first {
+			summary = &sampleSummary{message: message, suppressed: c.seen - s.first, window: s.window}
+		}
+		c = &sampleCount{start: now}
+		s.counts[message] = c
+	}
+
+	c.seen++
+
+	return c.seen <= s.first, summary
+}
+
+// sweep forgets messages whose window has passed, returning summaries for
+// those which had occurrences dropped.
+func (s *sampler) sweep(now time.Time) []sampleSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summaries []sampleSummary
+
+	for message, c := range s.counts {
+		if now.Sub(c.start) < s.window {
+			continue
+		}
+		if c.seen > s.first {
+			summaries = append(summaries, sampleSummary{message: message, suppressed: c.seen - s.first, window: s.window})
+		}
+		delete(s.counts, message)
+	}
+
+	return summaries
+}
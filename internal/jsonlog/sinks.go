@@ -0,0 +1,305 @@
+package jsonlog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink is a log destination that can be flushed, reopened (for example
+// after external log rotation) and closed on shutdown.
+type Sink interface {
+	io.Writer
+	Flush() error
+	Reopen() error
+	Close() error
+}
+
+// Stdout returns a Sink writing to standard output. Closing it is a no-op.
+func Stdout() Sink {
+	return writerSink{os.Stdout}
+}
+
+type writerSink struct {
+	io.Writer
+}
+
+func (writerSink) Flush() error  { return nil }
+func (writerSink) Reopen() error { return nil }
+func (writerSink) Close() error  { return nil }
+
+// MultiSink writes every log line to each of its sinks. A failing sink does
+// not prevent the line from reaching the others.
+type MultiSink struct {
+	sinks []Sink
+}
+
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+func (m *MultiSink) Write(p []byte) (int, error) {
+	var firstErr error
+	for _, s := range m.sinks {
+		if _, err := s.Write(p); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return len(p), firstErr
+}
+
+func (m *MultiSink) Flush() error {
+	return m.each(Sink.Flush)
+}
+
+func (m *MultiSink) Reopen() error {
+	return m.each(Sink.Reopen)
+}
+
+func (m *MultiSink) Close() error {
+	return m.each(Sink.Close)
+}
+
+func (m *MultiSink) each(fn func(Sink) error) error {
+	var firstErr error
+	for _, s := range m.sinks {
+		if err := fn(s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// RotatingFile writes to a file on disk, moving it aside with a timestamp
+// suffix once it grows beyond maxSize bytes or has been open longer than
+// maxAge. A zero limit disables that check.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func OpenRotatingFile(path string, maxSize int64, maxAge time.Duration) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, maxSize: maxSize, maxAge: maxAge}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.f = f
+	rf.size = info.Size()
+	rf.opened = time.Now()
+	return nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.shouldRotate(len(p)) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) shouldRotate(next int) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.maxSize > 0 && rf.size+int64(next) > rf.maxSize {
+		return true
+	}
+	return rf.maxAge > 0 && time.Since(rf.opened) > rf.maxAge
+}
+
+func (rf *RotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+
+	rotated := rf.path + "." + time.Now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(rf.path, rotated); err != nil {
+		return err
+	}
+
+	return rf.open()
+}
+
+func (rf *RotatingFile) Flush() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Sync()
+}
+
+func (rf *RotatingFile) Reopen() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	return rf.open()
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
+
+// SyslogSink sends each log line as an RFC 5424 message over UDP.
+type SyslogSink struct {
+	conn     net.Conn
+	tag      string
+	hostname string
+	mu       sync.Mutex
+}
+
+func DialSyslog(addr, tag string) (*SyslogSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	return &SyslogSink{conn: conn, tag: tag, hostname: hostname}, nil
+}
+
+func (s *SyslogSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		msg := fmt.Sprintf("<%d>1 %s %s %s - - - %s",
+			syslogPriority(line), time.Now().UTC().Format(time.RFC3339), s.hostname, s.tag, line)
+
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// syslogPriority maps the jsonlog level in a line to a syslog priority in
+// the local0 facility.
+func syslogPriority(line []byte) int {
+	const local0 = 16 * 8
+
+	switch {
+	case bytes.Contains(line, []byte(`"level":"FATAL"`)):
+		return local0 + 2
+	case bytes.Contains(line, []byte(`"level":"ERROR"`)):
+		return local0 + 3
+	default:
+		return local0 + 6
+	}
+}
+
+func (s *SyslogSink) Flush() error  { return nil }
+func (s *SyslogSink) Reopen() error { return nil }
+
+func (s *SyslogSink) Close() error {
+	return s.conn.Close()
+}
+
+// BufferedSink batches writes in memory and flushes them to the underlying
+// sink when the buffer fills, every interval, and on Flush or Close.
+type BufferedSink struct {
+	mu   sync.Mutex
+	buf  *bufio.Writer
+	dst  Sink
+	done chan struct{}
+}
+
+func NewBufferedSink(dst Sink, size int, interval time.Duration) *BufferedSink {
+	b := &BufferedSink{
+		buf:  bufio.NewWriterSize(dst, size),
+		dst:  dst,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				b.Flush()
+			case <-b.done:
+				return
+			}
+		}
+	}()
+
+	return b
+}
+
+func (b *BufferedSink) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *BufferedSink) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.buf.Flush(); err != nil {
+		return err
+	}
+	return b.dst.Flush()
+}
+
+func (b *BufferedSink) Reopen() error {
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	return b.dst.Reopen()
+}
+
+func (b *BufferedSink) Close() error {
+	close(b.done)
+
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	return b.dst.Close()
+}
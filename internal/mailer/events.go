@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const (
+	EventBounce    = "bounce"
+	EventComplaint = "complaint"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// DeliveryEvent reports that mail to Email should no longer be sent.
+type DeliveryEvent struct {
+	Email string
+	Kind  string
+}
+
+// EventSource verifies and parses the body of a provider's delivery status
+// callback.
+type EventSource interface {
+	Parse(header http.Header, body []byte) ([]DeliveryEvent, error)
+}
+
+const (
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// SendGridEvents verifies signed event webhook requests using the
+// verification key from the SendGrid mail settings.
+type SendGridEvents struct {
+	publicKey *ecdsa.PublicKey
+}
+
+func NewSendGridEvents(publicKey string) (*SendGridEvents, error) {
+	der, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("sendgrid verification key must be an ECDSA public key")
+	}
+
+	return &SendGridEvents{publicKey: ecdsaKey}, nil
+}
+
+func (s *SendGridEvents) Parse(header http.Header, body []byte) ([]DeliveryEvent, error) {
+	signature, err := base64.StdEncoding.DecodeString(header.Get(SendGridSignatureHeader))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	digest := sha256.Sum256(append([]byte(header.Get(SendGridTimestampHeader)), body...))
+	if !ecdsa.VerifyASN1(s.publicKey, digest[:], signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	var input []struct {
+		Email string `json:"email"`
+		Event string `json:"event"`
+		Type  string `json:"type"`
+	}
+
+	err = json.Unmarshal(body, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []DeliveryEvent
+	for _, e := range input {
+		switch {
+		case e.Event == "bounce" && e.Type != "blocked":
+			events = append(events, DeliveryEvent{Email: e.Email, Kind: EventBounce})
+		case e.Event == "spamreport":
+			events = append(events, DeliveryEvent{Email: e.Email, Kind: EventComplaint})
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LogBackend writes messages to w instead of sending them. It is intended
+// for local development.
+type LogBackend struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLog(w io.Writer) *LogBackend {
+	return &LogBackend{w: w}
+}
+
+// Check always passes, as there is nothing to connect to.
+func (b *LogBackend) Check(ctx context.Context) error {
+	return nil
+}
+
+func (b *LogBackend) Deliver(ctx context.Context, msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, err := fmt.Fprintf(b.w, "From: %s\nTo: %s\nSubject: %s\n\n%s\n", msg.From, msg.To, msg.Subject, msg.PlainBody)
+	return err
+}
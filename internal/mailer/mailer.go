@@ -2,32 +2,174 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"embed"
-	"github.com/go-mail/mail/v2"
+	"errors"
+	"expvar"
+	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
 	"time"
+
+	"greenlight.bcc/internal/breaker"
 )
 
 //go:embed "templates"
 var templateFS embed.FS
 
-type Mailer struct {
-	dialer *mail.Dialer
-	sender string
+// DefaultLocale is used for recipients whose locale has no template variant.
+const DefaultLocale = "en"
+
+var (
+	totalEmailsSent       = expvar.NewInt("total_emails_sent")
+	totalEmailsFailed     = expvar.NewInt("total_emails_failed")
+	totalEmailsRetried    = expvar.NewInt("total_emails_retried")
+	totalEmailsSuppressed = expvar.NewInt("total_emails_suppressed")
+)
+
+var (
+	ErrSuppressed = errors.New("recipient address is marked as undeliverable")
+	ErrOptedOut   = errors.New("recipient has opted out of this category of email")
+)
+
+// SuppressionList reports whether mail to an address should not be sent,
+// typically because it previously bounced or complained.
+type SuppressionList interface {
+	EmailUndeliverable(email string) (bool, error)
+}
+
+// Preferences reports whether a recipient accepts non-transactional mail of
+// a given category.
+type Preferences interface {
+	EmailAllowed(email, category string) (bool, error)
+}
+
+type Message struct {
+	From      string
+	To        string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// Backend delivers a rendered message. Implementations should honour the
+// context deadline.
+type Backend interface {
+	Deliver(ctx context.Context, msg *Message) error
+}
+
+// Checker is implemented by backends which can check that the provider is
+// reachable and accepts the credentials, without sending anything.
+type Checker interface {
+	Check(ctx context.Context) error
 }
 
-func New(host string, port int, username, password, sender string) Mailer {
-	dialer := mail.NewDialer(host, port, username, password)
-	dialer.Timeout = 5 * time.Second
+type Mailer struct {
+	backend    Backend
+	suppressed SuppressionList
+	prefs      Preferences
+	sender     string
+	timeout    time.Duration
+	retries    int
+	retryDelay time.Duration
+	breaker    *breaker.Breaker
+}
 
+func New(backend Backend, sender string, timeout time.Duration, retries int) Mailer {
 	return Mailer{
-		dialer: dialer,
-		sender: sender,
+		backend:    backend,
+		sender:     sender,
+		timeout:    timeout,
+		retries:    retries,
+		retryDelay: time.Second,
+	}
+}
+
+// templatePath returns the variant of templateFile for locale, falling back
+// to the base language (so "pt-BR" uses "pt") and then DefaultLocale.
+func templatePath(locale, templateFile string) string {
+	candidates := []string{locale}
+	if lang, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, lang)
+	}
+	candidates = append(candidates, DefaultLocale)
+
+	for _, candidate := range candidates {
+		path := "templates/" + candidate + "/" + templateFile
+		if _, err := fs.Stat(templateFS, path); err == nil {
+			return path
+		}
+	}
+
+	return "templates/" + DefaultLocale + "/" + templateFile
+}
+
+// WithSuppressionList returns a copy of the mailer which refuses to send to
+// addresses on the list.
+func (m Mailer) WithSuppressionList(list SuppressionList) Mailer {
+	m.suppressed = list
+	return m
+}
+
+// WithPreferences returns a copy of the mailer which consults recipient
+// preferences before sending non-transactional mail.
+func (m Mailer) WithPreferences(prefs Preferences) Mailer {
+	m.prefs = prefs
+	return m
+}
+
+// WithBreaker returns a copy of the mailer which stops calling the backend
+// while b is open. Deliveries then fail at once without being retried.
+func (m Mailer) WithBreaker(b *breaker.Breaker) Mailer {
+	m.breaker = b
+	return m
+}
+
+// Check checks the backend, if it implements Checker. Backends which do not
+// pass.
+func (m Mailer) Check(ctx context.Context) error {
+	checker, ok := m.backend.(Checker)
+	if !ok {
+		return nil
 	}
+	return checker.Check(ctx)
 }
 
-func (m Mailer) Send(recipient, templateFile string, data any) error {
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+// SendNotification sends mail of the given category, returning ErrOptedOut
+// if the recipient has turned that category off.
+func (m Mailer) SendNotification(recipient, locale, category, templateFile string, data any) error {
+	if m.prefs != nil {
+		allowed, err := m.prefs.EmailAllowed(recipient, category)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			totalEmailsSuppressed.Add(1)
+			return ErrOptedOut
+		}
+	}
+
+	return m.Send(recipient, locale, templateFile, data)
+}
+
+func (m Mailer) Send(recipient, locale, templateFile string, data any) error {
+	if m.suppressed != nil {
+		suppressed, err := m.suppressed.EmailUndeliverable(recipient)
+		if err != nil {
+			return err
+		}
+		if suppressed {
+			totalEmailsSuppressed.Add(1)
+			return ErrSuppressed
+		}
+	}
+
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/base.tmpl", templatePath(locale, templateFile))
 	if err != nil {
 		return err
 	}
@@ -50,16 +192,89 @@ func (m Mailer) Send(recipient, templateFile string, data any) error {
 		return err
 	}
 
-	msg := mail.NewMessage()
-	msg.SetHeader("To", recipient)
-	msg.SetHeader("From", m.sender)
-	msg.SetHeader("Subject", subject.String())
-	msg.SetBody("text/plain", plainBody.String())
-	msg.AddAlternative("text/html", htmlBody.String())
+	msg := &Message{
+		From:      m.sender,
+		To:        recipient,
+		Subject:   subject.String(),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+	}
 
-	err = m.dialer.DialAndSend(msg)
-	if err != nil {
+	for attempt := 0; ; attempt++ {
+		err = m.deliver(msg)
+		if err == nil {
+			totalEmailsSent.Add(1)
+			return nil
+		}
+
+		if attempt >= m.retries || !retryable(err) {
+			totalEmailsFailed.Add(1)
+			return err
+		}
+
+		totalEmailsRetried.Add(1)
+		time.Sleep(m.retryDelay * time.Duration(1<<attempt))
+	}
+}
+
+func (m Mailer) deliver(msg *Message) error {
+	ctx := context.Background()
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	if m.breaker == nil {
+		return m.backend.Deliver(ctx, msg)
+	}
+
+	if err := m.breaker.Allow(); err != nil {
 		return err
 	}
-	return nil
+
+	// A provider rejecting a message says nothing about its health.
+	err := m.backend.Deliver(ctx, msg)
+	if err != nil && !retryable(err) {
+		m.breaker.Record(nil)
+	} else {
+		m.breaker.Record(err)
+	}
+	return err
+}
+
+// StatusError is returned by HTTP based backends when the provider rejects a
+// request.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded with status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// retryable reports whether a failed delivery is worth attempting again:
+// server errors and rate limiting from HTTP providers, transient (4xx)
+// replies from SMTP servers, and network failures. Anything else, such as
+// a permanent (5xx) SMTP reply or an invalid address, will fail again.
+func retryable(err error) bool {
+	if errors.Is(err, breaker.ErrOpen) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var replyErr *textproto.Error
+	if errors.As(err, &replyErr) {
+		return replyErr.Code >= 400 && replyErr.Code < 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded)
 }
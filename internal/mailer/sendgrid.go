@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/mail"
+)
+
+const (
+	sendGridEndpoint       = "https://api.sendgrid.com/v3/mail/send"
+	sendGridScopesEndpoint = "https://api.sendgrid.com/v3/scopes"
+)
+
+type SendGridBackend struct {
+	apiKey   string
+	endpoint string
+	client   *http.Client
+}
+
+func NewSendGrid(apiKey string) *SendGridBackend {
+	return &SendGridBackend{
+		apiKey:   apiKey,
+		endpoint: sendGridEndpoint,
+		client:   &http.Client{},
+	}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// Check lists the API key's scopes, which fails if the key is not valid.
+func (b *SendGridBackend) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sendGridScopesEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.apiKey)
+
+	return doRequest(b.client, req, "sendgrid")
+}
+
+func (b *SendGridBackend) Deliver(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"personalizations": []map[string]any{
+			{"to": []sendGridAddress{{Email: msg.To}}},
+		},
+		"from":    sendGridAddress{Email: from.Address, Name: from.Name},
+		"subject": msg.Subject,
+		"content": []map[string]string{
+			{"type": "text/plain", "value": msg.PlainBody},
+			{"type": "text/html", "value": msg.HTMLBody},
+		},
+	}
+
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+b.apiKey)
+
+	return doRequest(b.client, req, "sendgrid")
+}
+
+// doRequest sends req and converts any non-2xx response into a StatusError.
+func doRequest(client *http.Client, req *http.Request, provider string) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return &StatusError{Provider: provider, StatusCode: res.StatusCode, Body: string(body)}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"greenlight.bcc/internal/sigv4"
+)
+
+// SESBackend sends mail through the Amazon SES v2 API.
+type SESBackend struct {
+	region   string
+	creds    sigv4.Credentials
+	endpoint string
+	client   *http.Client
+}
+
+func NewSES(region, accessKeyID, secretAccessKey string) *SESBackend {
+	return &SESBackend{
+		region:   region,
+		creds:    sigv4.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey},
+		endpoint: fmt.Sprintf("https://email.%s.amazonaws.com/v2/email/outbound-emails", region),
+		client:   &http.Client{},
+	}
+}
+
+type sesContent struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+// Check fetches the account's sending status, which fails if the
+// credentials are not valid.
+func (b *SESBackend) Check(ctx context.Context) error {
+	url := fmt.Sprintf("https://email.%s.amazonaws.com/v2/email/account", b.region)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	sigv4.Sign(req, nil, b.creds, b.region, "ses", time.Now())
+
+	return doRequest(b.client, req, "ses")
+}
+
+func (b *SESBackend) Deliver(ctx context.Context, msg *Message) error {
+	payload := map[string]any{
+		"FromEmailAddress": msg.From,
+		"Destination": map[string][]string{
+			"ToAddresses": {msg.To},
+		},
+		"Content": map[string]any{
+			"Simple": map[string]any{
+				"Subject": sesContent{Data: msg.Subject, Charset: "UTF-8"},
+				"Body": map[string]sesContent{
+					"Text": {Data: msg.PlainBody, Charset: "UTF-8"},
+					"Html": {Data: msg.HTMLBody, Charset: "UTF-8"},
+				},
+			},
+		},
+	}
+
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	sigv4.Sign(req, js, b.creds, b.region, "ses", time.Now())
+
+	return doRequest(b.client, req, "ses")
+}
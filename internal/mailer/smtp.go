@@ -0,0 +1,174 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// SMTPBackend delivers messages over a new SMTP connection each time. Port
+// 465 uses implicit TLS, other ports upgrade with STARTTLS when the server
+// offers it.
+type SMTPBackend struct {
+	host     string
+	port     int
+	username string
+	password string
+	// timeout bounds a delivery whose context has no deadline.
+	timeout time.Duration
+}
+
+func NewSMTP(host string, port int, username, password string) *SMTPBackend {
+	return &SMTPBackend{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		timeout:  5 * time.Second,
+	}
+}
+
+// dial connects and authenticates to the SMTP server. Every read and write
+// on the connection fails once ctx is done, so a delivery is never left
+// running in the background after Deliver has returned, where it could
+// still succeed and be sent again on a retry. The returned stop function
+// must be called once the client is no longer used.
+func (b *SMTPBackend) dial(ctx context.Context) (*smtp.Client, func(), error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(b.timeout)
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(b.host, strconv.Itoa(b.port)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn.SetDeadline(deadline)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	stop := func() { close(done) }
+
+	tlsConfig := &tls.Config{ServerName: b.host}
+
+	if b.port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, b.host)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if b.port != 465 {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				stop()
+				c.Close()
+				return nil, nil, err
+			}
+		}
+	}
+
+	if b.username != "" {
+		if ok, mechanisms := c.Extension("AUTH"); ok {
+			var auth smtp.Auth
+			if strings.Contains(mechanisms, "CRAM-MD5") {
+				auth = smtp.CRAMMD5Auth(b.username, b.password)
+			} else {
+				auth = smtp.PlainAuth("", b.username, b.password, b.host)
+			}
+
+			if err := c.Auth(auth); err != nil {
+				stop()
+				c.Close()
+				return nil, nil, err
+			}
+		}
+	}
+
+	return c, stop, nil
+}
+
+// Check connects and authenticates to the SMTP server, then hangs up.
+func (b *SMTPBackend) Check(ctx context.Context) error {
+	c, stop, err := b.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer c.Close()
+
+	return c.Quit()
+}
+
+func (b *SMTPBackend) Deliver(ctx context.Context, msg *Message) error {
+	m := mail.NewMessage()
+	m.SetHeader("To", msg.To)
+	m.SetHeader("From", msg.From)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetBody("text/plain", msg.PlainBody)
+	m.AddAlternative("text/html", msg.HTMLBody)
+
+	from, err := netmail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	c, stop, err := b.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer c.Close()
+
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	// The message has been accepted, so failing to hang up politely must
+	// not cause it to be sent again.
+	c.Quit()
+	return nil
+}
@@ -0,0 +1,259 @@
+package mailer
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SNSMessageTypeHeader = "X-Amz-Sns-Message-Type"
+
+var snsHostRX = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// SESEvents handles SES bounce and complaint notifications delivered through
+// an SNS topic. Only messages signed by SNS for the configured topic are
+// accepted.
+type SESEvents struct {
+	topicARN string
+	client   *http.Client
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+}
+
+func NewSESEvents(topicARN string) *SESEvents {
+	return &SESEvents{
+		topicARN: topicARN,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		certs:    make(map[string]*x509.Certificate),
+	}
+}
+
+// Parse verifies an SNS message and returns the delivery events it carries.
+// Subscription confirmations are acknowledged by visiting the subscribe URL
+// and yield no events.
+func (s *SESEvents) Parse(header http.Header, body []byte) ([]DeliveryEvent, error) {
+	var msg snsMessage
+
+	err := json.Unmarshal(body, &msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.TopicArn != s.topicARN {
+		return nil, ErrInvalidSignature
+	}
+
+	err = s.verify(&msg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch msg.Type {
+	case "SubscriptionConfirmation":
+		return nil, s.confirm(msg.SubscribeURL)
+	case "Notification":
+		return parseSESNotification(msg.Message)
+	default:
+		return nil, nil
+	}
+}
+
+func (s *SESEvents) verify(msg *snsMessage) error {
+	cert, err := s.certificate(msg.SigningCertURL)
+	if err != nil {
+		return err
+	}
+
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	payload := []byte(snsStringToSign(msg))
+
+	switch msg.SignatureVersion {
+	case "1":
+		digest := sha1.Sum(payload)
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA1, digest[:], signature)
+	case "2":
+		digest := sha256.Sum256(payload)
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	default:
+		return ErrInvalidSignature
+	}
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func snsStringToSign(msg *snsMessage) string {
+	fields := [][2]string{{"Message", msg.Message}, {"MessageId", msg.MessageID}}
+
+	if msg.Type == "Notification" {
+		if msg.Subject != "" {
+			fields = append(fields, [2]string{"Subject", msg.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", msg.SubscribeURL})
+	}
+
+	fields = append(fields, [2]string{"Timestamp", msg.Timestamp})
+	if msg.Type != "Notification" {
+		fields = append(fields, [2]string{"Token", msg.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", msg.TopicArn}, [2]string{"Type", msg.Type})
+
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String()
+}
+
+// certificate fetches and caches the SNS signing certificate, refusing any
+// URL which is not served over https by SNS itself.
+func (s *SESEvents) certificate(certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || !snsHostRX.MatchString(u.Host) {
+		return nil, ErrInvalidSignature
+	}
+
+	s.mu.Lock()
+	cert, ok := s.certs[certURL]
+	s.mu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	res, err := s.client.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching SNS certificate: status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("fetching SNS certificate: no PEM data found")
+	}
+
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.certs[certURL] = cert
+	s.mu.Unlock()
+
+	return cert, nil
+}
+
+func (s *SESEvents) confirm(subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil || u.Scheme != "https" || !snsHostRX.MatchString(u.Host) {
+		return ErrInvalidSignature
+	}
+
+	res, err := s.client.Get(subscribeURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("confirming SNS subscription: status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+type sesRecipient struct {
+	EmailAddress string `json:"emailAddress"`
+}
+
+func parseSESNotification(message string) ([]DeliveryEvent, error) {
+	var notification struct {
+		NotificationType string `json:"notificationType"`
+		EventType        string `json:"eventType"`
+		Bounce           struct {
+			BounceType        string         `json:"bounceType"`
+			BouncedRecipients []sesRecipient `json:"bouncedRecipients"`
+		} `json:"bounce"`
+		Complaint struct {
+			ComplainedRecipients []sesRecipient `json:"complainedRecipients"`
+		} `json:"complaint"`
+	}
+
+	err := json.Unmarshal([]byte(message), &notification)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := notification.NotificationType
+	if kind == "" {
+		kind = notification.EventType
+	}
+
+	var events []DeliveryEvent
+
+	switch kind {
+	case "Bounce":
+		if notification.Bounce.BounceType != "Permanent" {
+			return nil, nil
+		}
+		for _, r := range notification.Bounce.BouncedRecipients {
+			events = append(events, DeliveryEvent{Email: r.EmailAddress, Kind: EventBounce})
+		}
+	case "Complaint":
+		for _, r := range notification.Complaint.ComplainedRecipients {
+			events = append(events, DeliveryEvent{Email: r.EmailAddress, Kind: EventComplaint})
+		}
+	}
+
+	return events, nil
+}
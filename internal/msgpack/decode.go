@@ -0,0 +1,265 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// maxDepth bounds the nesting of arrays and maps Unmarshal accepts.
+const maxDepth = 64
+
+// SyntaxError describes malformed MessagePack, and where in the input it
+// was found.
+type SyntaxError struct {
+	Message string
+	Offset  int64
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("msgpack: %s at offset %d", e.Message, e.Offset)
+}
+
+// Unmarshal decodes data into the generic values encoding/json decodes
+// into: maps are map[string]any, arrays []any, and integers int64, or
+// uint64 for those too large for an int64. Maps must have string keys, each only once, and extension types
+// are not supported. data must hold exactly one value.
+func Unmarshal(data []byte) (any, error) {
+	d := &decoder{data: data}
+
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.off != len(d.data) {
+		return nil, d.errorf("unexpected data after top-level value")
+	}
+
+	return v, nil
+}
+
+// ToJSON converts a MessagePack document to JSON.
+func ToJSON(data []byte) ([]byte, error) {
+	v, err := Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+type decoder struct {
+	data []byte
+	off  int
+}
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Offset: int64(d.off)}
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.off < n {
+		return nil, d.errorf("unexpected end of input")
+	}
+	b := d.data[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// length reads a big-endian length of size bytes.
+func (d *decoder) length(size int) (int, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	default:
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(len(d.data)) {
+			return 0, d.errorf("length %d exceeds input", n)
+		}
+		return int(n), nil
+	}
+}
+
+func (d *decoder) decode(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, d.errorf("exceeded maximum nesting depth of %d", maxDepth)
+	}
+
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return d.object(int(c&0x0f), depth)
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xca:
+		raw, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), nil
+	case 0xcb:
+		raw, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		raw, err := d.next(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		u := readUint(raw)
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		raw, err := d.next(1 << (c - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		return readInt(raw), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(n, depth)
+	}
+
+	d.off--
+	return nil, d.errorf("unsupported type byte 0x%02x", c)
+}
+
+func readUint(raw []byte) uint64 {
+	switch len(raw) {
+	case 1:
+		return uint64(raw[0])
+	case 2:
+		return uint64(binary.BigEndian.Uint16(raw))
+	case 4:
+		return uint64(binary.BigEndian.Uint32(raw))
+	default:
+		return binary.BigEndian.Uint64(raw)
+	}
+}
+
+func readInt(raw []byte) int64 {
+	switch len(raw) {
+	case 1:
+		return int64(int8(raw[0]))
+	case 2:
+		return int64(int16(binary.BigEndian.Uint16(raw)))
+	case 4:
+		return int64(int32(binary.BigEndian.Uint32(raw)))
+	default:
+		return int64(binary.BigEndian.Uint64(raw))
+	}
+}
+
+func (d *decoder) str(n int) (string, error) {
+	raw, err := d.next(n)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func (d *decoder) array(n, depth int) ([]any, error) {
+	// Every element takes at least a byte, which bounds what a forged
+	// length can make us allocate.
+	if n > len(d.data)-d.off {
+		return nil, d.errorf("array length %d exceeds input", n)
+	}
+
+	values := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (d *decoder) object(n, depth int) (map[string]any, error) {
+	if n > (len(d.data)-d.off)/2 {
+		return nil, d.errorf("map length %d exceeds input", n)
+	}
+
+	m := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		keyOffset := d.off
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := key.(string)
+		if !ok {
+			d.off = keyOffset
+			return nil, d.errorf("map key must be a string")
+		}
+		if _, exists := m[s]; exists {
+			d.off = keyOffset
+			return nil, d.errorf("duplicate map key %q", s)
+		}
+
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[s] = v
+	}
+	return m, nil
+}
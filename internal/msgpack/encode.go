@@ -0,0 +1,576 @@
+// Package msgpack encodes values as MessagePack in the shape encoding/json
+// gives them: struct fields are named and omitted by their json tags, and
+// types with a MarshalJSON or MarshalText method are encoded as what those
+// produce. Decoding is into generic values only, which is enough to turn a
+// MessagePack request body into JSON for the usual decoding and validation.
+package msgpack
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnsupportedTypeError is returned by Marshal for values encoding/json
+// cannot encode either, such as channels and functions.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "msgpack: unsupported type " + e.Type.String()
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Marshal returns the MessagePack encoding of v.
+func Marshal(v any) ([]byte, error) {
+	e := &encoder{}
+	err := e.encode(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf     bytes.Buffer
+	scratch [9]byte
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf.WriteByte(0xc0)
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		e.buf.WriteByte(0xc0)
+		return nil
+	}
+
+	m := cachedMarshalers(v.Type())
+	if m.json {
+		return e.encodeJSONMarshaler(v.Interface().(json.Marshaler))
+	}
+	if m.addrJSON && v.CanAddr() {
+		return e.encodeJSONMarshaler(v.Addr().Interface().(json.Marshaler))
+	}
+	if m.text {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.writeString(string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf.WriteByte(0xc3)
+		} else {
+			e.buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(v.Uint())
+	case reflect.Float32:
+		e.buf.WriteByte(0xca)
+		binary.BigEndian.PutUint32(e.scratch[:4], math.Float32bits(float32(v.Float())))
+		e.buf.Write(e.scratch[:4])
+	case reflect.Float64:
+		e.writeFloat(v.Float())
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			e.buf.WriteByte(0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBinary(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf.WriteByte(0xc0)
+			return nil
+		}
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return &UnsupportedTypeError{v.Type()}
+	}
+
+	return nil
+}
+
+// marshalers records which marshaling methods a type has. Looking them up
+// is slow enough to be worth caching.
+type marshalers struct {
+	json     bool
+	addrJSON bool
+	text     bool
+}
+
+var marshalerCache sync.Map
+
+func cachedMarshalers(t reflect.Type) marshalers {
+	if cached, ok := marshalerCache.Load(t); ok {
+		return cached.(marshalers)
+	}
+
+	m := marshalers{
+		json:     t.Implements(jsonMarshalerType),
+		addrJSON: t.Kind() != reflect.Pointer && reflect.PtrTo(t).Implements(jsonMarshalerType),
+		text:     t.Implements(textMarshalerType),
+	}
+	marshalerCache.Store(t, m)
+	return m
+}
+
+// encodeJSONMarshaler encodes what m marshals to, so that types such as
+// time.Time and data.Runtime look the same as in JSON.
+func (e *encoder) encodeJSONMarshaler(m json.Marshaler) error {
+	js, err := m.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	// Most marshalers produce a string, which needs no generic decoding.
+	if len(js) > 0 && js[0] == '"' {
+		var s string
+		err = json.Unmarshal(js, &s)
+		if err != nil {
+			return err
+		}
+		e.writeString(s)
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var generic any
+	err = dec.Decode(&generic)
+	if err != nil {
+		return err
+	}
+
+	return e.encodeGeneric(generic)
+}
+
+// encodeGeneric encodes a value decoded from JSON with UseNumber.
+func (e *encoder) encodeGeneric(v any) error {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			e.writeInt(i)
+			return nil
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return err
+		}
+		e.writeFloat(f)
+		return nil
+	}
+	return e.encode(reflect.ValueOf(v))
+}
+
+func (e *encoder) encodeArray(v reflect.Value) error {
+	e.writeArrayHeader(v.Len())
+	for i := 0; i < v.Len(); i++ {
+		err := e.encode(v.Index(i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encodeMap(v reflect.Value) error {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+
+	// Keys are sorted, as encoding/json sorts them, so that the output is
+	// the same every time.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	e.writeMapHeader(len(entries))
+	for _, entry := range entries {
+		e.writeString(entry.key)
+		err := e.encode(entry.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapKey turns a map key into a string the way encoding/json does.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+
+	return "", &UnsupportedTypeError{k.Type()}
+}
+
+func (e *encoder) encodeStruct(v reflect.Value) error {
+	type present struct {
+		name  string
+		value reflect.Value
+	}
+
+	fields := cachedFields(v.Type())
+	values := make([]present, 0, len(fields))
+
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		values = append(values, present{f.name, fv})
+	}
+
+	e.writeMapHeader(len(values))
+	for _, p := range values {
+		e.writeString(p.name)
+		err := e.encode(p.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but reports false rather
+// than panicking when an embedded pointer on the way is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	tagged    bool
+}
+
+var fieldCache sync.Map
+
+func cachedFields(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+	fields, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return fields.([]field)
+}
+
+// typeFields lists the fields of t encoding/json would encode, in order,
+// including those promoted from embedded structs. As in encoding/json, of
+// several fields with the same name the shallowest wins, and a tie between
+// them is broken by a json tag or else drops them all.
+func typeFields(t reflect.Type) []field {
+	type candidate struct {
+		field
+		depth int
+	}
+
+	var candidates []candidate
+
+	var walk func(t reflect.Type, index []int, visited map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(tag, ",")
+			fieldIndex := append(append([]int(nil), index...), i)
+
+			if sf.Anonymous && name == "" {
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft, fieldIndex, visited)
+					continue
+				}
+			}
+
+			if !sf.IsExported() {
+				continue
+			}
+
+			f := field{name: name, index: fieldIndex, tagged: name != ""}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			for _, opt := range strings.Split(opts, ",") {
+				if opt == "omitempty" {
+					f.omitEmpty = true
+				}
+			}
+
+			candidates = append(candidates, candidate{f, len(fieldIndex)})
+		}
+
+		visited[t] = false
+	}
+
+	walk(t, nil, make(map[reflect.Type]bool))
+
+	// dominant returns the field which wins among those sharing a name.
+	dominant := func(cs []candidate) (candidate, bool) {
+		depth := cs[0].depth
+		for _, c := range cs {
+			if c.depth < depth {
+				depth = c.depth
+			}
+		}
+
+		var shallowest, tagged []candidate
+		for _, c := range cs {
+			if c.depth == depth {
+				shallowest = append(shallowest, c)
+				if c.tagged {
+					tagged = append(tagged, c)
+				}
+			}
+		}
+
+		switch {
+		case len(shallowest) == 1:
+			return shallowest[0], true
+		case len(tagged) == 1:
+			return tagged[0], true
+		default:
+			return candidate{}, false
+		}
+	}
+
+	byName := make(map[string][]candidate)
+	for _, c := range candidates {
+		byName[c.name] = append(byName[c.name], c)
+	}
+
+	var fields []field
+	for _, c := range candidates {
+		if winner, ok := dominant(byName[c.name]); ok && equalIndex(winner.index, c.index) {
+			fields = append(fields, c.field)
+		}
+	}
+
+	return fields
+}
+
+func equalIndex(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *encoder) writeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.writeUint(uint64(i))
+	case i >= -32:
+		e.buf.WriteByte(byte(i))
+	case i >= math.MinInt8:
+		e.buf.WriteByte(0xd0)
+		e.buf.WriteByte(byte(i))
+	case i >= math.MinInt16:
+		e.buf.WriteByte(0xd1)
+		binary.BigEndian.PutUint16(e.scratch[:2], uint16(i))
+		e.buf.Write(e.scratch[:2])
+	case i >= math.MinInt32:
+		e.buf.WriteByte(0xd2)
+		binary.BigEndian.PutUint32(e.scratch[:4], uint32(i))
+		e.buf.Write(e.scratch[:4])
+	default:
+		e.buf.WriteByte(0xd3)
+		binary.BigEndian.PutUint64(e.scratch[:8], uint64(i))
+		e.buf.Write(e.scratch[:8])
+	}
+}
+
+func (e *encoder) writeUint(u uint64) {
+	switch {
+	case u < 128:
+		e.buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		e.buf.WriteByte(0xcc)
+		e.buf.WriteByte(byte(u))
+	case u <= math.MaxUint16:
+		e.buf.WriteByte(0xcd)
+		binary.BigEndian.PutUint16(e.scratch[:2], uint16(u))
+		e.buf.Write(e.scratch[:2])
+	case u <= math.MaxUint32:
+		e.buf.WriteByte(0xce)
+		binary.BigEndian.PutUint32(e.scratch[:4], uint32(u))
+		e.buf.Write(e.scratch[:4])
+	default:
+		e.buf.WriteByte(0xcf)
+		binary.BigEndian.PutUint64(e.scratch[:8], u)
+		e.buf.Write(e.scratch[:8])
+	}
+}
+
+func (e *encoder) writeFloat(f float64) {
+	e.buf.WriteByte(0xcb)
+	binary.BigEndian.PutUint64(e.scratch[:8], math.Float64bits(f))
+	e.buf.Write(e.scratch[:8])
+}
+
+func (e *encoder) writeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.buf.WriteByte(0xd9)
+		e.buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(0xda)
+		binary.BigEndian.PutUint16(e.scratch[:2], uint16(n))
+		e.buf.Write(e.scratch[:2])
+	default:
+		e.buf.WriteByte(0xdb)
+		binary.BigEndian.PutUint32(e.scratch[:4], uint32(n))
+		e.buf.Write(e.scratch[:4])
+	}
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) writeBinary(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf.WriteByte(0xc4)
+		e.buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(0xc5)
+		binary.BigEndian.PutUint16(e.scratch[:2], uint16(n))
+		e.buf.Write(e.scratch[:2])
+	default:
+		e.buf.WriteByte(0xc6)
+		binary.BigEndian.PutUint32(e.scratch[:4], uint32(n))
+		e.buf.Write(e.scratch[:4])
+	}
+	e.buf.Write(b)
+}
+
+func (e *encoder) writeArrayHeader(n int) {
+	e.writeHeader(n, 0x90, 0xdc, 0xdd)
+}
+
+func (e *encoder) writeMapHeader(n int) {
+	e.writeHeader(n, 0x80, 0xde, 0xdf)
+}
+
+func (e *encoder) writeHeader(n int, fix, b16, b32 byte) {
+	switch {
+	case n < 16:
+		e.buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(b16)
+		binary.BigEndian.PutUint16(e.scratch[:2], uint16(n))
+		e.buf.Write(e.scratch[:2])
+	default:
+		e.buf.WriteByte(b32)
+		binary.BigEndian.PutUint32(e.scratch[:4], uint32(n))
+		e.buf.Write(e.scratch[:4])
+	}
+}
@@ -0,0 +1,99 @@
+// Package oembed fetches the title and thumbnail of videos from their
+// providers' oEmbed endpoints, which also confirms that a video exists and
+// may be embedded.
+package oembed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrNotConfigured = errors.New("oembed: fetching video metadata is not configured")
+	// ErrNotFound is returned for videos which do not exist or which their
+	// owner does not allow to be embedded.
+	ErrNotFound = errors.New("oembed: video not found or not embeddable")
+	// ErrUnknownProvider is returned for providers without an endpoint.
+	ErrUnknownProvider = errors.New("oembed: unknown provider")
+)
+
+// DefaultEndpoints are the oEmbed endpoints of the supported providers.
+var DefaultEndpoints = map[string]string{
+	"youtube": "https://www.youtube.com/oembed",
+	"vimeo":   "https://vimeo.com/api/oembed.json",
+}
+
+type Metadata struct {
+	Title        string
+	ThumbnailURL string
+}
+
+type Fetcher interface {
+	Fetch(ctx context.Context, provider, videoURL string) (*Metadata, error)
+}
+
+type NoopFetcher struct{}
+
+func (NoopFetcher) Fetch(ctx context.Context, provider, videoURL string) (*Metadata, error) {
+	return nil, ErrNotConfigured
+}
+
+// Client asks the endpoints, by provider, about videos.
+type Client struct {
+	endpoints map[string]string
+	client    *http.Client
+}
+
+func New(endpoints map[string]string, timeout time.Duration) *Client {
+	return &Client{
+		endpoints: endpoints,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *Client) Fetch(ctx context.Context, provider, videoURL string) (*Metadata, error) {
+	endpoint, ok := c.endpoints[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	params := url.Values{"url": {videoURL}, "format": {"json"}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound, res.StatusCode == http.StatusUnauthorized, res.StatusCode == http.StatusForbidden:
+		return nil, ErrNotFound
+	case res.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("oembed: %s responded with status %d: %s", provider, res.StatusCode, body)
+	}
+
+	var output struct {
+		Title        string `json:"title"`
+		ThumbnailURL string `json:"thumbnail_url"`
+	}
+
+	err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&output)
+	if err != nil {
+		return nil, fmt.Errorf("oembed: decoding %s response: %w", provider, err)
+	}
+
+	return &Metadata{Title: output.Title, ThumbnailURL: output.ThumbnailURL}, nil
+}
@@ -0,0 +1,96 @@
+// Package plugin lets deployments compile in endpoints and response
+// decorations of their own without changing cmd/api. A plugin registers
+// itself from an init function, and is compiled in by importing its package
+// for its side effects from a file added to cmd/api, usually behind a build
+// tag:
+//
+//	//go:build acme
+//
+//	package main
+//
+//	import _ "greenlight.bcc/plugins/acme"
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// Router is what plugins register their routes on. Paths must be under the
+// plugin's PathPrefix, so that they cannot clash with the API's own routes.
+type Router interface {
+	// Handle registers a route anyone may use.
+	Handle(method, path string, handler http.Handler)
+	// RequirePermission registers a route only activated users holding the
+	// permission code may use.
+	RequirePermission(method, path, code string, handler http.Handler)
+}
+
+type Plugin interface {
+	// Name identifies the plugin in its routes and logs. It must be lower
+	// case letters, digits and hyphens.
+	Name() string
+	RegisterRoutes(router Router)
+	// WrapHandler wraps every request the API serves, once the caller has
+	// been authenticated. Plugins which only add routes return next.
+	WrapHandler(next http.Handler) http.Handler
+}
+
+var nameRX = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+var (
+	mu      sync.Mutex
+	plugins []Plugin
+)
+
+// Register adds a plugin. Plugins wrap requests in the order they are
+// registered, the first outermost. It panics if the name is invalid or
+// already taken.
+func Register(p Plugin) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !nameRX.MatchString(p.Name()) {
+		panic("plugin: invalid name " + p.Name())
+	}
+	for _, other := range plugins {
+		if other.Name() == p.Name() {
+			panic("plugin: Register called twice for " + p.Name())
+		}
+	}
+
+	plugins = append(plugins, p)
+}
+
+// Plugins returns the registered plugins.
+func Plugins() []Plugin {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]Plugin(nil), plugins...)
+}
+
+// PathPrefix is the path the named plugin's routes live under.
+func PathPrefix(name string) string {
+	return "/v1/plugins/" + name + "/"
+}
+
+// Caller is the user making a request. UserID is 0 for anonymous callers.
+type Caller struct {
+	UserID int64
+	OrgID  int64
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, contextKey{}, caller)
+}
+
+// CallerFromContext returns the caller of the request with the context.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(contextKey{}).(Caller)
+	return caller, ok
+}
@@ -0,0 +1,76 @@
+// Package pwned checks whether passwords have appeared in known data
+// breaches, using the Have I Been Pwned range API. Only the first five hex
+// characters of a password's SHA-1 hash are sent, so the service never
+// learns which password was checked.
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DefaultEndpoint = "https://api.pwnedpasswords.com/range/"
+
+type Checker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+type NoopChecker struct{}
+
+func (NoopChecker) Breached(ctx context.Context, password string) (bool, error) {
+	return false, nil
+}
+
+// RangeChecker queries a range API endpoint, to which the hash prefix is
+// appended.
+type RangeChecker struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewRangeChecker(endpoint string, timeout time.Duration) *RangeChecker {
+	return &RangeChecker{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *RangeChecker) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides from observers how many suffixes share the prefix.
+	req.Header.Set("Add-Padding", "true")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("pwned: range API responded with status %d", res.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok && candidate == suffix {
+			// Padding entries have a count of zero.
+			return count != "0", nil
+		}
+	}
+
+	return false, scanner.Err()
+}
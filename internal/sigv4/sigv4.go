@@ -0,0 +1,183 @@
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// UnsignedPayload stands in for the payload hash when the body is streamed
+// and cannot be hashed up front. S3 accepts it over HTTPS.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Sign adds AWS Signature Version 4 headers to req. The body must be the
+// exact bytes which will be sent with the request.
+func Sign(req *http.Request, body []byte, creds Credentials, region, service string, now time.Time) {
+	sign(req, hashHex(body), creds, region, service, now)
+}
+
+// SignUnsigned is Sign for requests whose body is streamed: the body is
+// left out of the signature.
+func SignUnsigned(req *http.Request, creds Credentials, region, service string, now time.Time) {
+	sign(req, UnsignedPayload, creds, region, service, now)
+}
+
+func sign(req *http.Request, payloadHash string, creds Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+
+	req.Header.Set("X-Amz-Date", now.Format(timeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", now.Format(dateFormat), region, service)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKeyID, scope, signedHeaders, signature(creds, region, service, now, scope, canonicalRequest)))
+}
+
+// Presign returns req's URL with a signature in the query string which
+// allows anyone holding it to make the request until expires has passed, as
+// S3 pre-signed URLs do. Only the host header is signed, so the client may
+// send any other headers, and the payload is not signed.
+func Presign(req *http.Request, creds Credentials, region, service string, now time.Time, expires time.Duration) *url.URL {
+	now = now.UTC()
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", now.Format(dateFormat), region, service)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(timeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if creds.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req),
+		canonicalQuery(query),
+		"host:" + host + "\n",
+		"host",
+		UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", signature(creds, region, service, now, scope, canonicalRequest))
+
+	u := *req.URL
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func signature(creds Credentials, region, service string, now time.Time, scope, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		algorithm,
+		now.Format(timeFormat),
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(signingKey(creds.SecretAccessKey, now, region, service), stringToSign))
+}
+
+// canonicalQuery encodes the query sorted by key, with spaces as %20 rather
+// than +.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func canonicalPath(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string]string{"host": host}
+	for key, vals := range req.Header {
+		key = strings.ToLower(key)
+		if key == "authorization" || key == "user-agent" {
+			continue
+		}
+		trimmed := make([]string, len(vals))
+		for i, val := range vals {
+			trimmed[i] = strings.TrimSpace(val)
+		}
+		values[key] = strings.Join(trimmed, ",")
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key + ":" + values[key] + "\n")
+	}
+
+	return b.String(), strings.Join(keys, ";")
+}
+
+func signingKey(secret string, now time.Time, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), now.Format(dateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
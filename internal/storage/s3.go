@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"greenlight.bcc/internal/sigv4"
+)
+
+// S3 keeps objects in an S3 bucket, or any service with the same API, using
+// path-style addressing. Bodies are streamed with unsigned payloads.
+type S3 struct {
+	endpoint  string
+	bucket    string
+	region    string
+	publicURL string
+	creds     sigv4.Credentials
+	client    *http.Client
+}
+
+// NewS3 returns a store for bucket. An empty endpoint means AWS's own, and
+// an empty publicURL means objects are fetched from the bucket directly
+// rather than through a CDN.
+func NewS3(endpoint, region, bucket, accessKeyID, secretAccessKey, publicURL string) *S3 {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	s := &S3{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		bucket:    bucket,
+		region:    region,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		creds:     sigv4.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey},
+		client:    &http.Client{},
+	}
+
+	if s.publicURL == "" {
+		s.publicURL = s.endpoint + "/" + bucket
+	}
+
+	return s
+}
+
+func (s *S3) objectURL(key string) string {
+	return s.endpoint + "/" + s.bucket + "/" + escapeKey(key)
+}
+
+// escapeKey escapes each segment of key but keeps the slashes, as S3
+// expects in the canonical path.
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	sigv4.SignUnsigned(req, s.creds, s.region, "s3", time.Now())
+
+	return s.client.Do(req)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	res, err := s.do(ctx, http.MethodPut, key, io.NopCloser(r), size, contentType)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res, key)
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkResponse(res, key)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res, key)
+}
+
+func (s *S3) URL(key string) string {
+	return s.publicURL + "/" + escapeKey(key)
+}
+
+func (s *S3) SignedURL(key, method string, expiry time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	req, err := http.NewRequest(method, s.objectURL(key), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return sigv4.Presign(req, s.creds, s.region, "s3", time.Now(), expiry).String(), nil
+}
+
+func checkResponse(res *http.Response, key string) error {
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case res.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("storage: s3 returned %s for %s: %s", res.Status, key, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
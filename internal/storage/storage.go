@@ -0,0 +1,98 @@
+// Package storage keeps uploaded files such as posters, avatars and exports
+// under slash-separated keys, on local disk or in S3.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	ErrNotFound         = errors.New("storage: object not found")
+	ErrInvalidKey       = errors.New("storage: invalid key")
+	ErrInvalidSignature = errors.New("storage: invalid or expired signature")
+	ErrUnknownBackend   = errors.New("storage: unknown backend")
+)
+
+// Store reads and writes objects as streams, so that large files never
+// have to be held in memory.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any earlier
+	// object.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key. Deleting a missing object is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address public objects are fetched from.
+	URL(key string) string
+	// SignedURL returns an address which allows method, GET or PUT, on the
+	// object under key until expiry has passed.
+	SignedURL(key, method string, expiry time.Duration) (string, error)
+}
+
+// validKey reports whether key is a relative, clean path which cannot
+// escape the store.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// ContentKey returns the key for contents with the given SHA-256 sum:
+// prefix/<hex sum><ext>.
+func ContentKey(prefix string, sum []byte, ext string) string {
+	return prefix + "/" + hex.EncodeToString(sum) + ext
+}
+
+// PutContent stores the contents of r under a key derived from their hash,
+// so that identical files are stored once, and returns the key. The
+// contents are spooled to a temporary file while they are hashed.
+func PutContent(ctx context.Context, s Store, prefix, ext string, r io.Reader, contentType string) (string, error) {
+	tmp, err := os.CreateTemp("", "greenlight-upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	key := ContentKey(prefix, hash.Sum(nil), ext)
+
+	err = s.Put(ctx, key, tmp, size, contentType)
+	if err != nil {
+		return "", fmt.Errorf("storing %s: %w", key, err)
+	}
+
+	return key, nil
+}
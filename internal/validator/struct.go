@@ -0,0 +1,335 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A validate struct tag holds a comma-separated list of rules, checked in
+// order until one fails:
+//
+//	required      the field must not be its zero value (or nil)
+//	min=N, max=N  bounds on a number, a string's length in bytes or a
+//	              slice's number of elements
+//	positive      a number must be greater than zero
+//	oneof=a b c   a string must be one of the space-separated values
+//	unique        a slice must not contain duplicate values
+//	pattern=NAME  a string must match the pattern registered as NAME
+//	notnull       a Nullable field may be left out but not set to null
+//
+// Apart from required, rules are skipped for fields holding their zero
+// value, so optional fields are only checked when they are set. Nullable
+// fields are skipped when they were left out of the input and otherwise
+// checked by the value they hold. Errors are keyed by the field's JSON name.
+//
+// Struct applies the rules at runtime and Schema publishes the same rules
+// as a JSON Schema, so the checks and the documentation cannot drift apart.
+
+type pattern struct {
+	rx      *regexp.Regexp
+	message string
+}
+
+var patterns = map[string]pattern{
+	"email": {EmailRX, "must be a valid email address"},
+}
+
+// RegisterPattern makes rx available to the pattern rule as name, reporting
+// message when a value does not match. It is meant to be called from init
+// functions.
+func RegisterPattern(name string, rx *regexp.Regexp, message string) {
+	patterns[name] = pattern{rx, message}
+}
+
+// Nullable is implemented by the fields of partial updates, such as
+// data.Optional, which record whether a value was left out of the input or
+// explicitly set to null.
+type Nullable interface {
+	IsSet() bool
+	IsNull() bool
+	Interface() any
+}
+
+var nullableType = reflect.TypeOf((*Nullable)(nil)).Elem()
+
+type rule struct {
+	name string
+	arg  string
+	n    int64
+}
+
+type field struct {
+	index    int
+	name     string
+	required bool
+	notNull  bool
+	rules    []rule
+}
+
+var fieldCache sync.Map
+
+// fieldsOf parses the validate and json tags of t's exported fields.
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var fields []field
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		f := field{index: i, name: name}
+
+		tag := sf.Tag.Get("validate")
+		if tag != "" {
+			for _, spec := range strings.Split(tag, ",") {
+				r := rule{name: spec}
+				if before, after, found := strings.Cut(spec, "="); found {
+					r.name, r.arg = before, after
+				}
+
+				switch r.name {
+				case "required":
+					f.required = true
+				case "notnull":
+					f.notNull = true
+				case "min", "max":
+					n, err := strconv.ParseInt(r.arg, 10, 64)
+					if err != nil {
+						panic(fmt.Sprintf("validator: invalid %s rule on %s.%s", r.name, t.Name(), sf.Name))
+					}
+					r.n = n
+				case "pattern":
+					if _, ok := patterns[r.arg]; !ok {
+						panic(fmt.Sprintf("validator: unknown pattern %q on %s.%s", r.arg, t.Name(), sf.Name))
+					}
+				case "positive", "oneof", "unique":
+				default:
+					panic(fmt.Sprintf("validator: unknown rule %q on %s.%s", r.name, t.Name(), sf.Name))
+				}
+
+				f.rules = append(f.rules, r)
+			}
+		}
+
+		fields = append(fields, f)
+	}
+
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// Struct checks the fields of value, a struct or pointer to one, against
+// the rules in their validate tags.
+func (v *Validator) Struct(value any) {
+	rv := reflect.Indirect(reflect.ValueOf(value))
+
+	for _, f := range fieldsOf(rv.Type()) {
+		fv := rv.Field(f.index)
+
+		if n, ok := fv.Interface().(Nullable); ok {
+			switch {
+			case !n.IsSet():
+				continue
+			case n.IsNull():
+				v.Check(!f.notNull, f.name, "must not be null")
+				continue
+			}
+			fv = reflect.ValueOf(n.Interface())
+		}
+
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				v.Check(!f.required, f.name, "must be provided")
+				continue
+			}
+			fv = fv.Elem()
+		} else if fv.IsZero() {
+			v.Check(!f.required, f.name, "must be provided")
+			continue
+		}
+
+		for _, r := range f.rules {
+			if message, ok := r.check(fv, f.name); !ok {
+				v.AddError(f.name, message)
+				break
+			}
+		}
+	}
+}
+
+// check reports whether fv satisfies r and, if not, the error message.
+func (r rule) check(fv reflect.Value, name string) (string, bool) {
+	switch r.name {
+	case "min", "max":
+		var n int64
+		var unit string
+
+		switch fv.Kind() {
+		case reflect.String:
+			n, unit = int64(fv.Len()), " bytes long"
+		case reflect.Slice:
+			n = int64(fv.Len())
+			if r.name == "min" {
+				return fmt.Sprintf("must contain at least %d values", r.n), n >= r.n
+			}
+			return fmt.Sprintf("must not contain more than %d values", r.n), n <= r.n
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = fv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = int64(fv.Uint())
+		}
+
+		if r.name == "min" {
+			return fmt.Sprintf("must be at least %d%s", r.n, unit), n >= r.n
+		}
+		return fmt.Sprintf("must not be more than %d%s", r.n, unit), n <= r.n
+	case "positive":
+		switch fv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return "must be a positive number", fv.Float() > 0
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return "must be a positive integer", fv.Uint() > 0
+		}
+		return "must be a positive integer", fv.Int() > 0
+	case "oneof":
+		return fmt.Sprintf("invalid %s value", name), PermittedValue(fv.String(), strings.Fields(r.arg)...)
+	case "unique":
+		seen := make(map[any]bool, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			value := fv.Index(i).Interface()
+			if seen[value] {
+				return "must not contain duplicate values", false
+			}
+			seen[value] = true
+		}
+	case "pattern":
+		p := patterns[r.arg]
+		return p.message, Matches(fv.String(), p.rx)
+	}
+
+	return "", true
+}
+
+// Schemer is implemented by types whose JSON representation differs from
+// their Go type, such as a number marshalled as a string. Schema uses the
+// returned schema for them in place of one derived from the type.
+type Schemer interface {
+	JSONSchema() map[string]any
+}
+
+var (
+	schemerType = reflect.TypeOf((*Schemer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// Schema describes value, a struct or pointer to one, as a JSON Schema
+// object suitable for the components section of an OpenAPI document,
+// including the constraints from its validate tags.
+func Schema(value any) map[string]any {
+	t := reflect.TypeOf(value)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return typeSchema(t)
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	if t.Implements(schemerType) {
+		return reflect.Zero(t).Interface().(Schemer).JSONSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+
+		properties := map[string]any{}
+		required := []string{}
+
+		for _, f := range fieldsOf(t) {
+			ft := t.Field(f.index).Type
+			nullable := ft.Implements(nullableType)
+			if nullable {
+				ft = reflect.TypeOf(reflect.Zero(ft).Interface().(Nullable).Interface())
+			}
+
+			schema := typeSchema(ft)
+			if nullable && !f.notNull {
+				schema["nullable"] = true
+			}
+			if !ft.Implements(schemerType) {
+				for _, r := range f.rules {
+					r.constrain(schema)
+				}
+			}
+			properties[f.name] = schema
+			if f.required {
+				required = append(required, f.name)
+			}
+		}
+
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+
+	return map[string]any{}
+}
+
+// constrain adds the JSON Schema keywords equivalent to r to schema.
+func (r rule) constrain(schema map[string]any) {
+	switch r.name {
+	case "min", "max":
+		keyword := map[string]string{"string": "Length", "array": "Items"}[fmt.Sprint(schema["type"])]
+		if keyword == "" {
+			keyword = map[string]string{"min": "minimum", "max": "maximum"}[r.name]
+		} else {
+			keyword = r.name + keyword
+		}
+		schema[keyword] = r.n
+	case "positive":
+		schema["exclusiveMinimum"] = 0
+	case "oneof":
+		schema["enum"] = strings.Fields(r.arg)
+	case "unique":
+		schema["uniqueItems"] = true
+	case "pattern":
+		schema["pattern"] = patterns[r.arg].rx.String()
+	}
+}
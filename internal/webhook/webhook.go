@@ -0,0 +1,257 @@
+// Package webhook signs and delivers webhook requests. Each request carries
+// an X-Signature header of the form
+//
+//	t=1700000000,v1=5257a869e7ecebeda32affa62cdca3fa51cad7e77a0e56ff536d0ce8e108d8bd
+//
+// where t is the Unix time the request was signed at and v1 is the hex
+// encoded HMAC-SHA256, keyed with the subscription's secret, of t, a full
+// stop and the request body. Including the time lets consumers reject
+// requests replayed long after they were sent.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// DefaultTolerance is how old a signature Verify accepts.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrExpiredSignature = errors.New("webhook: signature too old")
+	// ErrForbiddenAddress is returned for URLs whose host is, or resolves
+	// to, a loopback, private, link-local or unspecified address.
+	ErrForbiddenAddress = errors.New("webhook: forbidden address")
+	ErrUnresolvableHost = errors.New("webhook: unresolvable host")
+)
+
+// NewSecret returns a random secret to sign a subscription's requests with.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// NewDeliveryID returns a random ID for a delivery, which consumers can use
+// to recognise deliveries they have already processed.
+func NewDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Signature header value for body sent at t.
+func Sign(secret string, body []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac(secret, timestamp, body))
+}
+
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Verify checks an X-Signature header value against body, rejecting
+// signatures made more than tolerance before now. It is what consumers are
+// expected to do, and is shown to them as an example.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp string
+	var signatures [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if signature, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	expected := mac(secret, timestamp, body)
+
+	valid := false
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(t, 0)); age > tolerance || age < -tolerance {
+		return ErrExpiredSignature
+	}
+
+	return nil
+}
+
+// Result describes how a consumer answered a delivery.
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+}
+
+type Client struct {
+	client       *http.Client
+	allowPrivate bool
+}
+
+// NewClient returns a client which gives up on deliveries after timeout.
+// Unless allowPrivate is set, which is only meant for development, it
+// refuses to connect to loopback, private, link-local and unspecified
+// addresses. The check is made on the address actually dialed, so a host
+// which resolved to a public address when the webhook was created cannot
+// later be pointed at an internal one.
+func NewClient(timeout time.Duration, allowPrivate bool) *Client {
+	c := &Client{allowPrivate: allowPrivate}
+
+	dialer := &net.Dialer{Timeout: timeout, Control: c.control}
+
+	c.client = &http.Client{
+		Timeout: timeout,
+		// Connections go straight to the consumer, not through a proxy,
+		// so that the address checked is the consumer's.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// A redirect would be followed without the signature being
+		// checked against the new URL, so consumers must not rely on one.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return c
+}
+
+func forbidden(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// control is called with the resolved address just before each connection
+// is made.
+func (c *Client) control(network, address string, conn syscall.RawConn) error {
+	if c.allowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || forbidden(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// CheckURL resolves the host of rawURL and returns ErrForbiddenAddress if
+// any of its addresses is one the client refuses to connect to, or
+// ErrUnresolvableHost if it has none. Deliveries are checked again when
+// they are made.
+func (c *Client) CheckURL(ctx context.Context, rawURL string) error {
+	if c.allowPrivate {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if forbidden(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return ErrUnresolvableHost
+	}
+
+	for _, addr := range addrs {
+		if forbidden(addr.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// Deliver POSTs body, signed with secret, to url. An error is returned if
+// the request failed or the consumer did not answer with a 2xx status.
+func (c *Client) Deliver(ctx context.Context, url, secret, deliveryID, eventType string, body []byte) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+
+	start := time.Now()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Greenlight-Webhooks/1.0")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(secret, body, start))
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return Result{Duration: time.Since(start)}, err
+	}
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+	res.Body.Close()
+
+	result := Result{StatusCode: res.StatusCode, Duration: time.Since(start)}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return result, fmt.Errorf("webhook: %s answered with status %d", url, res.StatusCode)
+	}
+
+	return result, nil
+}
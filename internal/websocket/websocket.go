@@ -0,0 +1,291 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+const (
+	CloseNormal      = 1000
+	CloseGoingAway   = 1001
+	CloseProtocol    = 1002
+	CloseMessageSize = 1009
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	ErrBadHandshake = errors.New("websocket: not a valid upgrade request")
+	ErrClosed       = errors.New("websocket: connection closed")
+	ErrProtocol     = errors.New("websocket: protocol error")
+	ErrTooLarge     = errors.New("websocket: message too large")
+)
+
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	writeMu      sync.Mutex
+	writeTimeout time.Duration
+
+	// MaxMessageSize limits the size of messages read from the client.
+	MaxMessageSize int64
+
+	// OnPong is called for every pong frame received.
+	OnPong func()
+}
+
+func isTokenListed(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade completes the opening handshake and takes over the underlying
+// connection. If it returns an error nothing has been written to w.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+
+	if r.Method != http.MethodGet ||
+		!isTokenListed(r.Header, "Connection", "upgrade") ||
+		!isTokenListed(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		key == "" {
+		return nil, ErrBadHandshake
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// The server's read and write timeouts still apply to the hijacked
+	// connection, so clear them and manage deadlines from here on.
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + acceptGUID))
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+
+	_, err = conn.Write([]byte(response))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Conn{
+		conn:           conn,
+		br:             rw.Reader,
+		writeTimeout:   10 * time.Second,
+		MaxMessageSize: 64 * 1024,
+	}, nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := []byte{0x80 | byte(opcode), 0}
+
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+func (c *Conn) WriteText(data []byte) error {
+	return c.writeFrame(OpText, data)
+}
+
+func (c *Conn) WriteJSON(v any) error {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteText(js)
+}
+
+func (c *Conn) Ping() error {
+	return c.writeFrame(OpPing, nil)
+}
+
+// Close sends a close frame with the given code and reason and then closes
+// the connection without waiting for the client's reply.
+func (c *Conn) Close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+
+	err := c.writeFrame(OpClose, payload)
+	closeErr := c.conn.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+func (c *Conn) readFrame() (frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{fin: head[0]&0x80 != 0, opcode: int(head[0] & 0x0F)}
+
+	if head[0]&0x70 != 0 || head[1]&0x80 == 0 {
+		// Reserved bits must be clear and clients must mask their frames.
+		return frame{}, ErrProtocol
+	}
+
+	length := int64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if f.opcode >= OpClose && (length > 125 || !f.fin) {
+		return frame{}, ErrProtocol
+	}
+	if length < 0 || length > c.MaxMessageSize {
+		return frame{}, ErrTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return frame{}, err
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return frame{}, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+
+	return f, nil
+}
+
+// ReadMessage returns the next text or binary message from the client.
+// Control frames are handled transparently: pings are answered, pongs are
+// passed to OnPong and a close frame is acknowledged and reported as
+// ErrClosed.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		opcode  int
+		message []byte
+	)
+
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrProtocol):
+				c.Close(CloseProtocol, "")
+			case errors.Is(err, ErrTooLarge):
+				c.Close(CloseMessageSize, "")
+			}
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case OpPing:
+			if err := c.writeFrame(OpPong, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			if c.OnPong != nil {
+				c.OnPong()
+			}
+			continue
+		case OpClose:
+			c.Close(CloseNormal, "")
+			return 0, nil, ErrClosed
+		case OpText, OpBinary:
+			if message != nil {
+				return 0, nil, ErrProtocol
+			}
+			opcode = f.opcode
+			message = f.payload
+		case OpContinuation:
+			if message == nil {
+				return 0, nil, ErrProtocol
+			}
+			message = append(message, f.payload...)
+			if int64(len(message)) > c.MaxMessageSize {
+				c.Close(CloseMessageSize, "")
+				return 0, nil, ErrTooLarge
+			}
+		default:
+			c.Close(CloseProtocol, "")
+			return 0, nil, ErrProtocol
+		}
+
+		if f.fin {
+			return opcode, message, nil
+		}
+	}
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS movie_revisions (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+version integer NOT NULL,
+title text NOT NULL,
+year integer NOT NULL,
+runtime integer NOT NULL,
+genres text[] NOT NULL,
+editor_id bigint REFERENCES users ON DELETE SET NULL,
+UNIQUE (movie_id, version)
+);
\ No newline at end of file
@@ -0,0 +1,4 @@
+INSERT INTO permissions (code)
+VALUES
+('admin:read'),
+('admin:write');
\ No newline at end of file
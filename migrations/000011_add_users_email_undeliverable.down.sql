@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS email_undeliverable;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_undeliverable boolean NOT NULL DEFAULT false;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS notification_preferences;
\ No newline at end of file
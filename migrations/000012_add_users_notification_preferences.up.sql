@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notification_preferences jsonb NOT NULL DEFAULT '{"marketing_emails": false, "security_alerts": true, "digest_frequency": "weekly"}';
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS notifications (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+kind text NOT NULL,
+message text NOT NULL,
+read_at timestamp(0) with time zone
+);
+CREATE INDEX IF NOT EXISTS notifications_user_id_created_at_idx ON notifications (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
\ No newline at end of file
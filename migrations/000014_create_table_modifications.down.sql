@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS movies_touch_modification ON movies;
+DROP FUNCTION IF EXISTS touch_table_modification();
+DROP TABLE IF EXISTS table_modifications;
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS table_modifications (
+table_name text PRIMARY KEY,
+modified_at timestamp(6) with time zone NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO table_modifications (table_name) VALUES ('movies') ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION touch_table_modification() RETURNS trigger AS $$
+BEGIN
+UPDATE table_modifications SET modified_at = NOW() WHERE table_name = TG_TABLE_NAME;
+RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER movies_touch_modification
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON movies
+FOR EACH STATEMENT EXECUTE FUNCTION touch_table_modification();
\ No newline at end of file
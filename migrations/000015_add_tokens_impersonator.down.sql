@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE code = 'admin:impersonate';
+ALTER TABLE tokens DROP COLUMN IF EXISTS impersonator_id;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS impersonator_id bigint REFERENCES users ON DELETE CASCADE;
+INSERT INTO permissions (code)
+VALUES
+('admin:impersonate');
\ No newline at end of file
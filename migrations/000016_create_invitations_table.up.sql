@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS invitations (
+hash bytea PRIMARY KEY,
+email citext NOT NULL,
+created_by bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+expiry timestamp(0) with time zone NOT NULL,
+used_by bigint REFERENCES users ON DELETE SET NULL,
+used_at timestamp(0) with time zone
+);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE tokens DROP COLUMN IF EXISTS org_id;
+ALTER TABLE movies DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
\ No newline at end of file
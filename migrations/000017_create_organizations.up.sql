@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS organizations (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+name text NOT NULL
+);
+CREATE TABLE IF NOT EXISTS memberships (
+organization_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+role text NOT NULL DEFAULT 'member',
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+PRIMARY KEY (organization_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS memberships_user_id_idx ON memberships (user_id);
+INSERT INTO organizations (id, name) VALUES (1, 'Default');
+SELECT setval('organizations_id_seq', 1);
+INSERT INTO memberships (organization_id, user_id) SELECT 1, id FROM users;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS org_id bigint NOT NULL DEFAULT 1 REFERENCES organizations ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS movies_org_id_idx ON movies (org_id);
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS org_id bigint REFERENCES organizations ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS usage;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS usage (
+day date NOT NULL,
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+org_id bigint NOT NULL DEFAULT 0,
+requests bigint NOT NULL DEFAULT 0,
+errors bigint NOT NULL DEFAULT 0,
+PRIMARY KEY (day, user_id, org_id)
+);
+CREATE INDEX IF NOT EXISTS usage_user_id_day_idx ON usage (user_id, day);
+CREATE INDEX IF NOT EXISTS usage_org_id_day_idx ON usage (org_id, day);
\ No newline at end of file
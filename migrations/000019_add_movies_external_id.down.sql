@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS movies_org_id_external_id_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS external_id;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS external_id text;
+CREATE UNIQUE INDEX IF NOT EXISTS movies_org_id_external_id_idx ON movies (org_id, external_id);
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS movies_org_id_tmdb_id_idx;
+DROP INDEX IF EXISTS movies_org_id_imdb_id_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS tmdb_id;
+ALTER TABLE movies DROP COLUMN IF EXISTS imdb_id;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS imdb_id text;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS tmdb_id bigint;
+CREATE UNIQUE INDEX IF NOT EXISTS movies_org_id_imdb_id_idx ON movies (org_id, imdb_id);
+CREATE UNIQUE INDEX IF NOT EXISTS movies_org_id_tmdb_id_idx ON movies (org_id, tmdb_id);
\ No newline at end of file
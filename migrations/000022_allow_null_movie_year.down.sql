@@ -0,0 +1,2 @@
+ALTER TABLE movie_revisions ALTER COLUMN year SET NOT NULL;
+ALTER TABLE movies ALTER COLUMN year SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE movies ALTER COLUMN year DROP NOT NULL;
+ALTER TABLE movie_revisions ALTER COLUMN year DROP NOT NULL;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS comments (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+edited_at timestamp(0) with time zone,
+deleted_at timestamp(0) with time zone,
+movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+parent_id bigint REFERENCES comments ON DELETE CASCADE,
+thread_id bigint REFERENCES comments ON DELETE CASCADE,
+depth integer NOT NULL DEFAULT 0,
+body text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS comments_movie_id_threads_idx ON comments (movie_id, created_at DESC) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS comments_thread_id_idx ON comments (thread_id);
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code = 'content:moderate';
+ALTER TABLE users DROP COLUMN IF EXISTS banned;
+ALTER TABLE comments DROP COLUMN IF EXISTS hidden_at;
+DROP TABLE IF EXISTS reports;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS reports (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+reporter_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+content_type text NOT NULL,
+content_id bigint NOT NULL,
+reason text NOT NULL,
+status text NOT NULL DEFAULT 'open',
+action text NOT NULL DEFAULT '',
+resolved_by bigint REFERENCES users ON DELETE SET NULL,
+resolved_at timestamp(0) with time zone,
+CONSTRAINT reports_reporter_content_key UNIQUE (reporter_id, content_type, content_id)
+);
+CREATE INDEX IF NOT EXISTS reports_open_idx ON reports (created_at) WHERE status = 'open';
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden_at timestamp(0) with time zone;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned boolean NOT NULL DEFAULT false;
+
+INSERT INTO permissions (code)
+VALUES
+('content:moderate');
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS blocks;
\ No newline at end of file
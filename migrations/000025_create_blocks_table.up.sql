@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS blocks (
+blocker_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+blocked_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+kind text NOT NULL,
+PRIMARY KEY (blocker_id, blocked_id)
+);
+CREATE INDEX IF NOT EXISTS blocks_blocked_id_idx ON blocks (blocked_id);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS lists (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+org_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+name text NOT NULL,
+description text NOT NULL DEFAULT '',
+public boolean NOT NULL DEFAULT false,
+version integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS lists_user_id_idx ON lists (user_id);
+CREATE INDEX IF NOT EXISTS lists_public_idx ON lists (org_id, created_at DESC) WHERE public;
+
+CREATE TABLE IF NOT EXISTS list_items (
+list_id bigint NOT NULL REFERENCES lists ON DELETE CASCADE,
+movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+position integer NOT NULL,
+added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+PRIMARY KEY (list_id, movie_id)
+);
+CREATE INDEX IF NOT EXISTS list_items_list_id_position_idx ON list_items (list_id, position);
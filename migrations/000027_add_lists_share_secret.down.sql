@@ -0,0 +1 @@
+ALTER TABLE lists DROP COLUMN IF EXISTS share_secret;
\ No newline at end of file
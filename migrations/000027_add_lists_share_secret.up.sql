@@ -0,0 +1 @@
+ALTER TABLE lists ADD COLUMN IF NOT EXISTS share_secret bytea;
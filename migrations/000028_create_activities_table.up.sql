@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS activities (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+kind text NOT NULL,
+org_id bigint REFERENCES organizations ON DELETE CASCADE,
+actor_id bigint REFERENCES users ON DELETE CASCADE,
+recipient_id bigint REFERENCES users ON DELETE CASCADE,
+genres text[] NOT NULL DEFAULT '{}',
+data jsonb NOT NULL DEFAULT '{}'
+);
+CREATE INDEX IF NOT EXISTS activities_recipient_id_idx ON activities (recipient_id, id DESC) WHERE recipient_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS activities_org_id_idx ON activities (org_id, id DESC) WHERE recipient_id IS NULL;
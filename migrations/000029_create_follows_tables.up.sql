@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_follows (
+follower_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+followed_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+PRIMARY KEY (follower_id, followed_id),
+CONSTRAINT user_follows_self_check CHECK (follower_id <> followed_id)
+);
+CREATE INDEX IF NOT EXISTS user_follows_followed_id_idx ON user_follows (followed_id);
+
+CREATE TABLE IF NOT EXISTS genre_follows (
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+genre text NOT NULL,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+PRIMARY KEY (user_id, genre)
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count integer NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS following_count integer NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_profile_privacy_check;
+ALTER TABLE users DROP COLUMN IF EXISTS profile_privacy;
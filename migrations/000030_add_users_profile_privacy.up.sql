@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_privacy text NOT NULL DEFAULT 'public';
+ALTER TABLE users ADD CONSTRAINT users_profile_privacy_check CHECK (profile_privacy IN ('public', 'followers', 'private'));
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS uploads (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+expires_at timestamp(0) with time zone NOT NULL,
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+purpose text NOT NULL,
+movie_id bigint REFERENCES movies ON DELETE CASCADE,
+key text NOT NULL,
+content_type text NOT NULL,
+size bigint NOT NULL,
+token_hash bytea,
+uploaded_at timestamp(0) with time zone,
+completed_at timestamp(0) with time zone
+);
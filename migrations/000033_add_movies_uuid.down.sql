@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS movies_org_id_uuid_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS uuid;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS movie_translations (
+movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+locale text NOT NULL,
+title text NOT NULL,
+synopsis text NOT NULL DEFAULT '',
+updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+PRIMARY KEY (movie_id, locale)
+);
+
+-- Translations change what movie listings return, so they count as
+-- modifications of the movies table.
+CREATE OR REPLACE FUNCTION touch_movies_modification() RETURNS trigger AS $$
+BEGIN
+UPDATE table_modifications SET modified_at = NOW() WHERE table_name = 'movies';
+RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER movie_translations_touch_modification
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON movie_translations
+FOR EACH STATEMENT EXECUTE FUNCTION touch_movies_modification();
@@ -0,0 +1,14 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS tagline text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS age_rating text;
+
+-- Title search also looks at the tagline and synopsis, with matches in the
+-- title ranked above matches in the tagline, and those above the synopsis.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+setweight(to_tsvector('simple', title), 'A') ||
+setweight(to_tsvector('simple', tagline), 'B') ||
+setweight(to_tsvector('simple', synopsis), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx ON movies USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS movies_age_rating_idx ON movies (age_rating);
+DROP INDEX IF EXISTS movies_title_idx;
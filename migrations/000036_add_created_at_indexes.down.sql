@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_created_at_idx;
+DROP INDEX IF EXISTS movies_org_id_created_at_idx;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS movies_org_id_created_at_idx ON movies (org_id, created_at);
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tombstones (
+id bigserial PRIMARY KEY,
+resource_type text NOT NULL,
+resource_id bigint NOT NULL,
+org_id bigint NOT NULL,
+deleted_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+deleted_by bigint REFERENCES users ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS tombstones_org_id_resource_type_deleted_at_idx ON tombstones (org_id, resource_type, deleted_at);
+CREATE INDEX IF NOT EXISTS tombstones_deleted_at_idx ON tombstones (deleted_at);
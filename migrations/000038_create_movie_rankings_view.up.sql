@@ -0,0 +1,25 @@
+-- The catalog has no ratings, so a movie's standing is how many lists it
+-- has been added to. Trending weighs the comments and list additions of
+-- the last week.
+CREATE MATERIALIZED VIEW IF NOT EXISTS movie_rankings AS
+SELECT m.id AS movie_id, m.org_id,
+coalesce(l.list_count, 0) AS list_count,
+coalesce(c.recent_comments, 0) * 2 + coalesce(l.recent_additions, 0) * 3 AS trending_score
+FROM movies m
+LEFT JOIN (
+SELECT movie_id, count(*) AS list_count, count(*) FILTER (WHERE added_at > NOW() - interval '7 days') AS recent_additions
+FROM list_items
+GROUP BY movie_id
+) l ON l.movie_id = m.id
+LEFT JOIN (
+SELECT movie_id, count(*) AS recent_comments
+FROM comments
+WHERE created_at > NOW() - interval '7 days' AND deleted_at IS NULL AND hidden_at IS NULL
+GROUP BY movie_id
+) c ON c.movie_id = m.id
+WHERE m.status = 'published';
+
+-- A unique index lets the view be refreshed concurrently with reads.
+CREATE UNIQUE INDEX IF NOT EXISTS movie_rankings_movie_id_idx ON movie_rankings (movie_id);
+CREATE INDEX IF NOT EXISTS movie_rankings_top_rated_idx ON movie_rankings (org_id, list_count DESC, movie_id);
+CREATE INDEX IF NOT EXISTS movie_rankings_trending_idx ON movie_rankings (org_id, trending_score DESC, movie_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS cluster_instances (
+id text PRIMARY KEY,
+address text NOT NULL,
+metrics_url text NOT NULL,
+version text NOT NULL DEFAULT '',
+started_at timestamp(0) with time zone NOT NULL,
+last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS cluster_instances_last_seen_at_idx ON cluster_instances (last_seen_at);
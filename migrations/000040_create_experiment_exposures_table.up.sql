@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS experiment_exposures (
+experiment text NOT NULL,
+subject text NOT NULL,
+variant text NOT NULL,
+user_id bigint REFERENCES users ON DELETE CASCADE,
+exposed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+PRIMARY KEY (experiment, subject)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS security_events (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+kind text NOT NULL,
+ip text NOT NULL DEFAULT '',
+user_agent text NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS security_events_user_id_idx ON security_events (user_id, id DESC);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE tokens DROP COLUMN IF EXISTS device_name;
+ALTER TABLE tokens DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE tokens DROP COLUMN IF EXISTS ip;
+ALTER TABLE tokens DROP COLUMN IF EXISTS created_at;
\ No newline at end of file
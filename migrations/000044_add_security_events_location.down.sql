@@ -0,0 +1,2 @@
+ALTER TABLE security_events DROP COLUMN IF EXISTS city;
+ALTER TABLE security_events DROP COLUMN IF EXISTS country;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE security_events ADD COLUMN IF NOT EXISTS country text NOT NULL DEFAULT '';
+ALTER TABLE security_events ADD COLUMN IF NOT EXISTS city text NOT NULL DEFAULT '';
\ No newline at end of file
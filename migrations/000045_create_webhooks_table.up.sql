@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+org_id bigint REFERENCES organizations ON DELETE CASCADE,
+url text NOT NULL,
+events text[] NOT NULL,
+secret text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhooks_user_id_idx ON webhooks (user_id);
+CREATE INDEX IF NOT EXISTS webhooks_events_idx ON webhooks USING GIN (events);
\ No newline at end of file
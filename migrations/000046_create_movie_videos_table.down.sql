@@ -0,0 +1 @@
+DROP TABLE IF EXISTS movie_videos;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS movie_videos (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+type text NOT NULL,
+url text NOT NULL,
+provider text NOT NULL,
+provider_id text NOT NULL,
+title text NOT NULL DEFAULT '',
+thumbnail_url text NOT NULL DEFAULT '',
+CONSTRAINT movie_videos_movie_provider_key UNIQUE (movie_id, provider, provider_id)
+);
\ No newline at end of file
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS collections (
+id bigserial PRIMARY KEY,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+org_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+name text NOT NULL,
+description text NOT NULL DEFAULT '',
+version integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS collections_org_id_name_idx ON collections (org_id, name);
+
+-- A movie belongs to at most one collection.
+CREATE TABLE IF NOT EXISTS collection_movies (
+movie_id bigint PRIMARY KEY REFERENCES movies ON DELETE CASCADE,
+collection_id bigint NOT NULL REFERENCES collections ON DELETE CASCADE,
+position integer NOT NULL
+);
+CREATE INDEX IF NOT EXISTS collection_movies_collection_id_position_idx ON collection_movies (collection_id, position);
+
+-- Movies embed their collection and can be filtered by it, so changes to
+-- collections count as modifications of the movies table.
+CREATE TRIGGER collections_touch_modification
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON collections
+FOR EACH STATEMENT EXECUTE FUNCTION touch_movies_modification();
+
+CREATE TRIGGER collection_movies_touch_modification
+AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON collection_movies
+FOR EACH STATEMENT EXECUTE FUNCTION touch_movies_modification();
\ No newline at end of file
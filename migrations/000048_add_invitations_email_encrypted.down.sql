@@ -0,0 +1 @@
+ALTER TABLE invitations DROP COLUMN IF EXISTS email_encrypted;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE invitations ADD COLUMN IF NOT EXISTS email_encrypted text NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE movie_revisions DROP COLUMN IF EXISTS imdb_id;
+ALTER TABLE movie_revisions DROP COLUMN IF EXISTS tmdb_id;
+ALTER TABLE movie_revisions DROP COLUMN IF EXISTS synopsis;
+ALTER TABLE movie_revisions DROP COLUMN IF EXISTS tagline;
+ALTER TABLE movie_revisions DROP COLUMN IF EXISTS age_rating;
+ALTER TABLE movie_revisions DROP COLUMN IF EXISTS poster_url;
\ No newline at end of file
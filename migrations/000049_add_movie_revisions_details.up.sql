@@ -0,0 +1,6 @@
+ALTER TABLE movie_revisions ADD COLUMN IF NOT EXISTS imdb_id text;
+ALTER TABLE movie_revisions ADD COLUMN IF NOT EXISTS tmdb_id bigint;
+ALTER TABLE movie_revisions ADD COLUMN IF NOT EXISTS synopsis text NOT NULL DEFAULT '';
+ALTER TABLE movie_revisions ADD COLUMN IF NOT EXISTS tagline text NOT NULL DEFAULT '';
+ALTER TABLE movie_revisions ADD COLUMN IF NOT EXISTS age_rating text;
+ALTER TABLE movie_revisions ADD COLUMN IF NOT EXISTS poster_url text NOT NULL DEFAULT '';
\ No newline at end of file
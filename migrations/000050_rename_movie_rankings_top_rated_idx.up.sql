@@ -0,0 +1 @@
+ALTER INDEX IF EXISTS movie_rankings_top_rated_idx RENAME TO movie_rankings_most_listed_idx;
\ No newline at end of file